@@ -6,8 +6,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
+	"bot-go/internal/cli"
 	"bot-go/internal/codeapi"
 	"bot-go/internal/config"
 	"bot-go/internal/controller"
@@ -19,7 +22,6 @@ import (
 	"bot-go/pkg/mcp"
 
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 )
 
 // stringSliceFlag is a custom flag type that allows multiple values
@@ -45,23 +47,28 @@ func main() {
 	var useHead = flag.Bool("head", false, "Use git HEAD version instead of working directory (only valid with --build-index)")
 	var testDump = flag.String("test-dump", "", "Path to output file for dumping code graph after index building (only valid with --build-index)")
 	var clean = flag.Bool("clean", false, "Clean up all DB entries (MySQL, Neo4j, Qdrant) for the repository after processing (only valid with --build-index)")
+	var naturalness = flag.String("naturalness", "", "Path to a file to score against a saved n-gram model, printing per-line surprise and the z-score")
+	var modelRepo = flag.String("repo", "", "Repository or corpus group whose saved n-gram model scores the file (only valid with --naturalness)")
+	var language = flag.String("language", "", "Language of the file, detected from the file when empty (only valid with --naturalness)")
+	var mergeModels = flag.String("merge-models", "", "Two comma-separated repositories whose saved n-gram models are merged without re-tokenizing")
+	var mergeOutput = flag.String("merge-output", "", "Name the merged n-gram model is saved under (only valid with --merge-models)")
 	flag.Parse()
 
-	//logger, err := zap.NewProduction()
-	cfgZap := zap.NewProductionConfig()
-	//cfgZap.Level.SetLevel(zapcore.DebugLevel)
-	cfgZap.Level.SetLevel(zapcore.InfoLevel)
-	cfgZap.OutputPaths = []string{"stdout", "all.log"}
-	logger, err := cfgZap.Build()
+	// The logger is configured by app.yaml, so configuration errors go to the standard logger
+	cfg, err := config.LoadConfig(*appConfigPath, *sourceConfigPath)
 	if err != nil {
-		log.Fatal("Failed to initialize logger:", err)
+		log.Fatal("Failed to load configuration: ", err)
+	}
+
+	logger, err := config.NewLogger(cfg.Logging)
+	if err != nil {
+		log.Fatal("Failed to initialize logger: ", err)
 	}
 
 	defer logger.Sync()
 
-	cfg, err := config.LoadConfig(*appConfigPath, *sourceConfigPath)
-	if err != nil {
-		logger.Fatal("Failed to load configuration", zap.Error(err))
+	for _, adjustment := range cfg.Adjustments() {
+		logger.Warn("Adjusted configuration value", zap.String("adjustment", adjustment))
 	}
 
 	// Override workdir from command line if provided
@@ -77,6 +84,22 @@ func main() {
 		return
 	}
 
+	if *naturalness != "" {
+		NaturalnessCommand(cfg, logger, *modelRepo, *naturalness, *language)
+		return
+	}
+	if *modelRepo != "" || *language != "" {
+		logger.Fatal("--repo and --language flags are only valid with --naturalness")
+	}
+
+	if *mergeModels != "" {
+		MergeModelsCommand(cfg, logger, *mergeModels, *mergeOutput)
+		return
+	}
+	if *mergeOutput != "" {
+		logger.Fatal("--merge-output flag is only valid with --merge-models")
+	}
+
 	// Check if we're in CLI mode (build-index specified)
 	if len(buildIndex) > 0 {
 		logger.Info("Running in CLI mode - build-index")
@@ -124,12 +147,22 @@ func main() {
 
 	// Initialize CodeAPI controller if CodeGraph is available
 	var codeAPIController *controller.CodeAPIController
+	var graphController *controller.GraphController
 	if container.CodeGraph != nil {
+		repoController.SetCodeGraph(container.CodeGraph)
+		container.RepoService.SetCodeGraph(container.CodeGraph)
 		codeAPI := codeapi.NewCodeAPI(container.CodeGraph, logger)
 		codeAPIController = controller.NewCodeAPIController(codeAPI, logger)
+		graphController = controller.NewGraphController(container.CodeGraph, logger)
 	}
 
-	router := handler.SetupRouter(repoController, mcpServer, codeAPIController, logger)
+	// Reprocess repositories whose HEAD moves while the server runs
+	if cfg.App.RefreshInterval > 0 {
+		refresher := controller.NewRepoRefresher(repoController, time.Duration(cfg.App.RefreshInterval)*time.Second, logger)
+		go refresher.Run(context.Background())
+	}
+
+	router := handler.SetupRouter(repoController, mcpServer, codeAPIController, graphController, logger)
 
 	logger.Info("Starting server", zap.Int("port", cfg.App.Port))
 	if err := http.ListenAndServe(fmt.Sprintf(":%d", cfg.App.Port), router); err != nil {
@@ -220,7 +253,7 @@ func BuildIndexCommand(cfg *config.Config, logger *zap.Logger, repoNames []strin
 		// Get git info if using HEAD mode
 		var gitInfo *util.GitInfo
 		if useHead {
-			gitInfo, err = util.GetGitInfo(repo.Path)
+			gitInfo, err = util.GetGitInfo(repo.Path, repo.Ref)
 			if err != nil {
 				logger.Error("Failed to get git info",
 					zap.String("repo_name", repo.Name),
@@ -317,6 +350,56 @@ func BuildIndexCommand(cfg *config.Config, logger *zap.Logger, repoNames []strin
 	logger.Info("Build index command completed")
 }
 
+// NaturalnessCommand prints how natural a file is under a repository's saved
+// n-gram model without starting the server
+func NaturalnessCommand(cfg *config.Config, logger *zap.Logger, repoName, filePath, language string) {
+	if repoName == "" {
+		logger.Fatal("--repo is required with --naturalness")
+	}
+
+	container, err := init_services.NewServiceContainer(cfg, init_services.GetNaturalnessOptions(), logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize services", zap.Error(err))
+	}
+	defer container.Close(context.Background())
+
+	if err := cli.Naturalness(context.Background(), os.Stdout, container.NgramService, repoName, filePath, language); err != nil {
+		logger.Fatal("Failed to score file",
+			zap.String("repo_name", repoName),
+			zap.String("path", filePath),
+			zap.Error(err))
+	}
+}
+
+// MergeModelsCommand merges the saved n-gram models of two repositories into
+// a new saved model without starting the server
+func MergeModelsCommand(cfg *config.Config, logger *zap.Logger, repos, outputName string) {
+	repoNames := strings.Split(repos, ",")
+	if len(repoNames) != 2 || repoNames[0] == "" || repoNames[1] == "" {
+		logger.Fatal("--merge-models takes two comma-separated repositories", zap.String("merge_models", repos))
+	}
+	if outputName == "" {
+		logger.Fatal("--merge-output is required with --merge-models")
+	}
+
+	container, err := init_services.NewServiceContainer(cfg, init_services.GetNaturalnessOptions(), logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize services", zap.Error(err))
+	}
+	defer container.Close(context.Background())
+
+	merged, err := container.NgramService.MergeModels(repoNames[0], repoNames[1], outputName)
+	if err != nil {
+		logger.Fatal("Failed to merge n-gram models",
+			zap.Strings("repos", repoNames),
+			zap.String("output", outputName),
+			zap.Error(err))
+	}
+	stats := merged.GetStats(context.Background())
+	fmt.Printf("Merged %s and %s into %s: %d files, %d tokens, vocabulary of %d\n",
+		repoNames[0], repoNames[1], outputName, stats.TotalFiles, stats.GlobalModel.TotalTokens, stats.GlobalModel.VocabularySize)
+}
+
 func CodeGraphEntry(cfg *config.Config, logger *zap.Logger, container *init_services.ServiceContainer) {
 	if !cfg.App.CodeGraph {
 		logger.Info("CodeGraph is disabled in the configuration")
@@ -92,10 +92,11 @@ func main() {
 
 	// Initialize embedding model (same as in main.go)
 	embeddingModel, err := service.NewOllamaEmbedding(service.OllamaEmbeddingConfig{
-		APIURL:    cfg.Ollama.URL,
-		APIKey:    cfg.Ollama.APIKey,
-		Model:     cfg.Ollama.Model,
-		Dimension: cfg.Ollama.Dimension,
+		APIURL:          cfg.Ollama.URL,
+		APIKey:          cfg.Ollama.APIKey,
+		Model:           cfg.Ollama.Model,
+		Dimension:       cfg.Ollama.Dimension,
+		StrictDimension: cfg.Ollama.StrictDimension,
 	}, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize Ollama embedding model", zap.Error(err))
@@ -0,0 +1,200 @@
+package chunk
+
+import (
+	"bot-go/internal/model"
+	"bot-go/pkg/lsp/base"
+	"strings"
+	"unicode/utf8"
+)
+
+// ContentLimitStrategy is how a chunk longer than the content limit is shortened
+type ContentLimitStrategy string
+
+const (
+	// ContentLimitTruncateTail keeps the start of the content and drops the rest
+	ContentLimitTruncateTail ContentLimitStrategy = "truncate-tail"
+	// ContentLimitTruncateMiddle keeps the first line (usually the signature)
+	// and the end of the content, dropping the middle
+	ContentLimitTruncateMiddle ContentLimitStrategy = "truncate-middle"
+	// ContentLimitSplit splits the content at line boundaries into several chunks
+	ContentLimitSplit ContentLimitStrategy = "split"
+)
+
+// truncationMarker is the line left where truncated content was removed
+const truncationMarker = "..."
+
+// LimitContentLength shortens every chunk whose content is longer than
+// maxChars characters (runes, not bytes) using strategy, recording the strategy in the chunk's
+// "content_limit" metadata along with its "original_content_length". Split
+// parts keep the metadata of the original chunk; the first part keeps the
+// original ID. A maxChars of 0 or less disables the limit.
+func LimitContentLength(chunks []*model.CodeChunk, maxChars int, strategy ContentLimitStrategy) []*model.CodeChunk {
+	if maxChars <= 0 {
+		return chunks
+	}
+
+	result := make([]*model.CodeChunk, 0, len(chunks))
+	for _, c := range chunks {
+		originalLength := utf8.RuneCountInString(c.Content)
+		if originalLength <= maxChars {
+			result = append(result, c)
+			continue
+		}
+
+		switch strategy {
+		case ContentLimitSplit:
+			parts := splitChunkContent(c, maxChars)
+			for _, part := range parts {
+				part.WithMetadata("content_limit", string(strategy)).
+					WithMetadata("original_content_length", originalLength)
+			}
+			result = append(result, parts...)
+			continue
+		case ContentLimitTruncateTail:
+			c.Content = truncateTail(c.Content, maxChars)
+		default:
+			strategy = ContentLimitTruncateMiddle
+			c.Content = truncateMiddle(c.Content, maxChars)
+		}
+		c.WithMetadata("content_limit", string(strategy)).
+			WithMetadata("original_content_length", originalLength)
+		result = append(result, c)
+	}
+	return result
+}
+
+// truncateTail keeps as many whole leading lines of content as fit in maxChars
+// together with a closing truncation marker
+func truncateTail(content string, maxChars int) string {
+	budget := maxChars - utf8.RuneCountInString("\n"+truncationMarker)
+	if budget <= 0 {
+		return prefix(content, maxChars)
+	}
+	head := prefix(content, budget)
+	if i := strings.LastIndexByte(head, '\n'); i > 0 {
+		head = head[:i]
+	}
+	return head + "\n" + truncationMarker
+}
+
+// truncateMiddle keeps the first line of content and as many whole trailing
+// lines as fit in maxChars, separated by a truncation marker. Content whose
+// first line alone does not fit is truncated at the tail instead.
+func truncateMiddle(content string, maxChars int) string {
+	first, _, _ := strings.Cut(content, "\n")
+	separator := "\n" + truncationMarker + "\n"
+	budget := maxChars - utf8.RuneCountInString(first) - utf8.RuneCountInString(separator)
+	if budget <= 0 {
+		return truncateTail(content, maxChars)
+	}
+	tail := suffix(content, budget)
+	if i := strings.IndexByte(tail, '\n'); i >= 0 && i < len(tail)-1 {
+		tail = tail[i+1:]
+	}
+	return first + separator + tail
+}
+
+// splitChunkContent splits c into parts of at most maxChars characters,
+// breaking between lines; a single line longer than maxChars is cut apart
+func splitChunkContent(c *model.CodeChunk, maxChars int) []*model.CodeChunk {
+	type piece struct {
+		content   string
+		startLine int // Offset of the piece's first line within the chunk
+		endLine   int
+	}
+
+	var pieces []piece
+	var current strings.Builder
+	currentChars := 0 // Characters written to current
+	currentStart := 0
+	flush := func(endLine int) {
+		if current.Len() > 0 {
+			pieces = append(pieces, piece{content: current.String(), startLine: currentStart, endLine: endLine})
+			current.Reset()
+			currentChars = 0
+		}
+	}
+	for i, line := range strings.Split(c.Content, "\n") {
+		lineChars := utf8.RuneCountInString(line)
+		for lineChars > maxChars {
+			flush(i - 1)
+			currentStart = i
+			head := prefix(line, maxChars)
+			current.WriteString(head)
+			flush(i)
+			line = line[len(head):]
+			lineChars -= maxChars
+		}
+		if current.Len() > 0 && currentChars+1+lineChars > maxChars {
+			flush(i - 1)
+		}
+		if current.Len() == 0 {
+			currentStart = i
+		} else {
+			current.WriteByte('\n')
+			currentChars++
+		}
+		current.WriteString(line)
+		currentChars += lineChars
+	}
+	flush(strings.Count(c.Content, "\n"))
+
+	parts := make([]*model.CodeChunk, 0, len(pieces))
+	for index, p := range pieces {
+		lastLine := p.content[strings.LastIndexByte(p.content, '\n')+1:]
+		rng := base.Range{
+			Start: base.Position{Line: c.Range.Start.Line + p.startLine},
+			End:   base.Position{Line: c.Range.Start.Line + p.endLine, Character: len(lastLine)},
+		}
+		if index == 0 {
+			rng.Start = c.Range.Start
+		}
+		if index == len(pieces)-1 {
+			rng.End = c.Range.End
+		}
+
+		id := c.ID
+		if index > 0 {
+			id = derivedChunkID(c.ID, "part", index)
+		}
+
+		part := model.NewCodeChunk(id, c.ChunkType, c.Level, p.content, c.Language, c.FilePath, rng).
+			WithFileID(c.FileID).
+			WithParent(c.ParentID).
+			WithName(c.Name).
+			WithSignature(c.Signature).
+			WithDocstring(c.Docstring).
+			WithContext(c.ModuleName, c.ClassName)
+
+		for k, v := range c.Metadata {
+			part.WithMetadata(k, v)
+		}
+		part.WithMetadata("part_index", index).
+			WithMetadata("part_count", len(pieces)).
+			WithMetadata("original_id", c.ID)
+
+		parts = append(parts, part)
+	}
+	return parts
+}
+
+// prefix returns the first n characters of s
+func prefix(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// suffix returns the last n characters of s
+func suffix(s string, n int) string {
+	end := len(s)
+	for ; n > 0 && end > 0; n-- {
+		_, size := utf8.DecodeLastRuneInString(s[:end])
+		end -= size
+	}
+	return s[end:]
+}
@@ -0,0 +1,147 @@
+package chunk
+
+import (
+	"bot-go/internal/model"
+	"bot-go/pkg/lsp/base"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// longFunction returns a chunk of a Go function with a body of bodyLines lines
+func longFunction(bodyLines int) *model.CodeChunk {
+	lines := []string{"func process(ctx context.Context, items []Item) error {"}
+	for i := 0; i < bodyLines; i++ {
+		lines = append(lines, fmt.Sprintf("\tif err := handle(ctx, items[%d]); err != nil {", i))
+	}
+	lines = append(lines, "\treturn nil", "}")
+	rng := base.Range{
+		Start: base.Position{Line: 10},
+		End:   base.Position{Line: 10 + len(lines) - 1, Character: 1},
+	}
+	return model.NewCodeChunk("chunk-1", model.ChunkTypeFunction, 2, strings.Join(lines, "\n"), "go", "process.go", rng)
+}
+
+func TestLimitContentLength(t *testing.T) {
+	const maxChars = 200
+	signature := "func process(ctx context.Context, items []Item) error {"
+
+	tests := []struct {
+		name     string
+		strategy ContentLimitStrategy
+		check    func(t *testing.T, content string)
+	}{
+		{
+			name:     "truncate middle keeps the signature and the end",
+			strategy: ContentLimitTruncateMiddle,
+			check: func(t *testing.T, content string) {
+				if !strings.HasPrefix(content, signature+"\n...\n") {
+					t.Errorf("content does not start with the signature and marker:\n%s", content)
+				}
+				if !strings.HasSuffix(content, "\treturn nil\n}") {
+					t.Errorf("content does not keep the end of the function:\n%s", content)
+				}
+			},
+		},
+		{
+			name:     "truncate tail keeps whole leading lines",
+			strategy: ContentLimitTruncateTail,
+			check: func(t *testing.T, content string) {
+				if !strings.HasPrefix(content, signature+"\n") || !strings.HasSuffix(content, "{\n...") {
+					t.Errorf("content is not whole leading lines and a marker:\n%s", content)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := longFunction(20)
+			originalLength := len(original.Content)
+
+			chunks := LimitContentLength([]*model.CodeChunk{original}, maxChars, tt.strategy)
+			if len(chunks) != 1 {
+				t.Fatalf("got %d chunks, want 1", len(chunks))
+			}
+			c := chunks[0]
+			if len(c.Content) > maxChars {
+				t.Errorf("content is %d characters, limit is %d", len(c.Content), maxChars)
+			}
+			tt.check(t, c.Content)
+			if c.Metadata["content_limit"] != string(tt.strategy) || c.Metadata["original_content_length"] != originalLength {
+				t.Errorf("metadata = %v", c.Metadata)
+			}
+		})
+	}
+
+	t.Run("split", func(t *testing.T) {
+		original := longFunction(20)
+		content := original.Content
+
+		parts := LimitContentLength([]*model.CodeChunk{original}, maxChars, ContentLimitSplit)
+		if len(parts) < 2 {
+			t.Fatalf("got %d parts, want the content split", len(parts))
+		}
+		var joined []string
+		for i, part := range parts {
+			if len(part.Content) > maxChars {
+				t.Errorf("part %d is %d characters, limit is %d", i, len(part.Content), maxChars)
+			}
+			if part.Metadata["content_limit"] != "split" || part.Metadata["original_id"] != "chunk-1" {
+				t.Errorf("part %d metadata = %v", i, part.Metadata)
+			}
+			if i > 0 && part.StartLine != parts[i-1].EndLine+1 {
+				t.Errorf("part %d starts at line %d, previous part ends at %d", i, part.StartLine, parts[i-1].EndLine)
+			}
+			joined = append(joined, part.Content)
+		}
+		if parts[0].ID != "chunk-1" || parts[1].ID == "chunk-1" {
+			t.Errorf("part IDs = %q, %q; want the first to keep the original ID", parts[0].ID, parts[1].ID)
+		}
+		if parts[0].StartLine != 10 || parts[len(parts)-1].EndLine != original.EndLine {
+			t.Errorf("parts span lines %d-%d, want %d-%d", parts[0].StartLine, parts[len(parts)-1].EndLine, 10, original.EndLine)
+		}
+		if strings.Join(joined, "\n") != content {
+			t.Error("parts do not rejoin to the original content")
+		}
+	})
+
+	t.Run("limit counts characters, not bytes", func(t *testing.T) {
+		line := "\t// " + strings.Repeat("é", 30) // 34 characters, 64 bytes
+		fits := model.NewCodeChunk("chunk-2", model.ChunkTypeFunction, 2, line, "go", "process.go", base.Range{})
+		chunks := LimitContentLength([]*model.CodeChunk{fits}, 34, ContentLimitTruncateTail)
+		if chunks[0].Content != line || chunks[0].Metadata["content_limit"] != nil {
+			t.Errorf("content within the character limit was changed: %q", chunks[0].Content)
+		}
+		chunks = LimitContentLength([]*model.CodeChunk{fits}, 20, ContentLimitTruncateTail)
+		if n := utf8.RuneCountInString(chunks[0].Content); n != 20 || !utf8.ValidString(chunks[0].Content) {
+			t.Errorf("truncated content %q is %d characters, want 20", chunks[0].Content, n)
+		}
+
+		content := strings.Repeat(line+"\n", 5) + "}"
+		long := model.NewCodeChunk("chunk-3", model.ChunkTypeFunction, 2, content, "go", "process.go", base.Range{})
+		parts := LimitContentLength([]*model.CodeChunk{long}, 50, ContentLimitSplit)
+		var joined []string
+		for i, part := range parts {
+			if n := utf8.RuneCountInString(part.Content); n > 50 || !utf8.ValidString(part.Content) {
+				t.Errorf("part %d is %d characters (valid UTF-8: %v), limit is 50", i, n, utf8.ValidString(part.Content))
+			}
+			joined = append(joined, part.Content)
+		}
+		if strings.Join(joined, "\n") != content {
+			t.Error("parts do not rejoin to the original content")
+		}
+		if parts[0].Metadata["original_content_length"] != utf8.RuneCountInString(content) {
+			t.Errorf("original_content_length = %v, want %d", parts[0].Metadata["original_content_length"], utf8.RuneCountInString(content))
+		}
+	})
+
+	t.Run("short content is unchanged", func(t *testing.T) {
+		short := longFunction(1)
+		chunks := LimitContentLength([]*model.CodeChunk{short}, maxChars, ContentLimitTruncateMiddle)
+		if len(chunks) != 1 || chunks[0].Content != short.Content || chunks[0].Metadata["content_limit"] != nil {
+			t.Errorf("short chunk was changed: %+v", chunks[0])
+		}
+	})
+}
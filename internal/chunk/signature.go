@@ -0,0 +1,174 @@
+package chunk
+
+import (
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// SignatureExtractor builds the one-line signature stored on function and
+// method chunks from the function's tree-sitter node
+type SignatureExtractor interface {
+	Extract(tsNode *tree_sitter.Node, source []byte) string
+}
+
+// NewSignatureExtractor returns the signature extractor for a language; languages
+// without one get an extractor that returns an empty signature
+func NewSignatureExtractor(language string) SignatureExtractor {
+	switch language {
+	case "go":
+		return goSignatureExtractor{}
+	case "python":
+		return pythonSignatureExtractor{}
+	case "java":
+		return javaSignatureExtractor{}
+	case "javascript", "typescript":
+		return jsSignatureExtractor{}
+	default:
+		return noSignatureExtractor{}
+	}
+}
+
+type noSignatureExtractor struct{}
+
+func (noSignatureExtractor) Extract(*tree_sitter.Node, []byte) string {
+	return ""
+}
+
+// goSignatureExtractor produces "Name(params) result"
+type goSignatureExtractor struct{}
+
+func (goSignatureExtractor) Extract(tsNode *tree_sitter.Node, source []byte) string {
+	sig := fieldText(tsNode, "name", source)
+	sig += fieldText(tsNode, "parameters", source)
+	if result := fieldText(tsNode, "result", source); result != "" {
+		sig += " " + result
+	}
+	return sig
+}
+
+// pythonSignatureExtractor produces "name(params) -> return_type"
+type pythonSignatureExtractor struct{}
+
+func (pythonSignatureExtractor) Extract(tsNode *tree_sitter.Node, source []byte) string {
+	sig := fieldText(tsNode, "name", source)
+	sig += fieldText(tsNode, "parameters", source)
+	if returnType := fieldText(tsNode, "return_type", source); returnType != "" {
+		sig += " -> " + returnType
+	}
+	return sig
+}
+
+// javaSignatureExtractor produces "modifiers <T> ReturnType name(Type param, ...) throws E".
+// Annotations are left out; they describe the method rather than its shape.
+type javaSignatureExtractor struct{}
+
+func (javaSignatureExtractor) Extract(tsNode *tree_sitter.Node, source []byte) string {
+	var parts []string
+
+	for i := uint(0); i < tsNode.ChildCount(); i++ {
+		child := tsNode.Child(i)
+		if child.Kind() != "modifiers" {
+			continue
+		}
+		for j := uint(0); j < child.ChildCount(); j++ {
+			modifier := child.Child(j)
+			if kind := modifier.Kind(); kind != "marker_annotation" && kind != "annotation" {
+				parts = append(parts, modifier.Utf8Text(source))
+			}
+		}
+	}
+
+	for _, field := range []string{"type_parameters", "type", "name"} {
+		if text := fieldText(tsNode, field, source); text != "" {
+			parts = append(parts, text)
+		}
+	}
+
+	sig := strings.Join(parts, " ") + compactText(fieldText(tsNode, "parameters", source))
+
+	for i := uint(0); i < tsNode.ChildCount(); i++ {
+		if child := tsNode.Child(i); child.Kind() == "throws" {
+			sig += " " + compactText(child.Utf8Text(source))
+		}
+	}
+
+	return sig
+}
+
+// jsSignatureExtractor produces "async name<T>(params): ReturnType" for function
+// declarations, methods, and function or arrow function values. Anonymous
+// functions take the name they are assigned to. TypeScript type annotations are
+// kept as written, so the same extractor serves JavaScript.
+type jsSignatureExtractor struct{}
+
+func (jsSignatureExtractor) Extract(tsNode *tree_sitter.Node, source []byte) string {
+	sig := ""
+	if hasChildKind(tsNode, "async") {
+		sig = "async "
+	}
+
+	name := fieldText(tsNode, "name", source)
+	if name == "" {
+		name = assignedName(tsNode, source)
+	}
+	sig += name + fieldText(tsNode, "type_parameters", source)
+
+	if params := fieldText(tsNode, "parameters", source); params != "" {
+		sig += compactText(params)
+	} else {
+		// Single unparenthesized arrow function parameter
+		sig += "(" + fieldText(tsNode, "parameter", source) + ")"
+	}
+
+	// The return type annotation includes its leading colon
+	if returnType := fieldText(tsNode, "return_type", source); returnType != "" {
+		sig += ": " + strings.TrimSpace(strings.TrimPrefix(returnType, ":"))
+	}
+
+	return sig
+}
+
+// assignedName returns the name an anonymous function value is bound to
+func assignedName(tsNode *tree_sitter.Node, source []byte) string {
+	parent := tsNode.Parent()
+	if parent == nil {
+		return ""
+	}
+
+	switch parent.Kind() {
+	case "variable_declarator", "public_field_definition":
+		return fieldText(parent, "name", source)
+	case "field_definition":
+		return fieldText(parent, "property", source)
+	case "pair":
+		return fieldText(parent, "key", source)
+	case "assignment_expression":
+		return fieldText(parent, "left", source)
+	default:
+		return ""
+	}
+}
+
+func fieldText(tsNode *tree_sitter.Node, field string, source []byte) string {
+	child := tsNode.ChildByFieldName(field)
+	if child == nil {
+		return ""
+	}
+	return child.Utf8Text(source)
+}
+
+func hasChildKind(tsNode *tree_sitter.Node, kind string) bool {
+	for i := uint(0); i < tsNode.ChildCount(); i++ {
+		if tsNode.Child(i).Kind() == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// compactText collapses whitespace runs, so parameter lists spread over several
+// lines fit on one line
+func compactText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
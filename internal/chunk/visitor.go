@@ -26,6 +26,7 @@ type ChunkVisitor struct {
 	moduleName          string
 	minConditionalLines int
 	minLoopLines        int
+	signatures          SignatureExtractor
 }
 
 // NewChunkVisitor creates a new chunk visitor
@@ -38,6 +39,7 @@ func NewChunkVisitor(logger *zap.Logger, language, filePath string, sourceCode [
 		chunks:              make([]*model.CodeChunk, 0),
 		minConditionalLines: minConditionalLines,
 		minLoopLines:        minLoopLines,
+		signatures:          NewSignatureExtractor(language),
 	}
 }
 
@@ -167,6 +169,10 @@ func (cv *ChunkVisitor) traverseJavaScriptNode(ctx context.Context, tsNode *tree
 		return cv.handleJSFunction(ctx, tsNode)
 	case "method_definition":
 		return cv.handleJSMethod(ctx, tsNode)
+	case "variable_declarator", "public_field_definition", "field_definition":
+		if fn := cv.functionValue(tsNode); fn != nil {
+			return cv.handleJSFunctionValue(ctx, tsNode, fn)
+		}
 	case "if_statement":
 		return cv.handleConditional(ctx, tsNode, "if")
 	case "switch_statement":
@@ -216,7 +222,7 @@ func (cv *ChunkVisitor) handleFunctionDeclaration(ctx context.Context, tsNode *t
 
 	name := cv.getNodeText(nameNode)
 	content := cv.getNodeText(tsNode)
-	signature := cv.extractGoFunctionSignature(tsNode)
+	signature := cv.signatures.Extract(tsNode, cv.sourceCode)
 	docstring := cv.extractGoDocstring(tsNode)
 
 	chunkID := cv.generateChunkID(cv.filePath, name, tsNode.StartPosition().Row)
@@ -302,7 +308,7 @@ func (cv *ChunkVisitor) handlePythonFunction(ctx context.Context, tsNode *tree_s
 
 	name := cv.getNodeText(nameNode)
 	content := cv.getNodeText(tsNode)
-	signature := cv.extractPythonFunctionSignature(tsNode)
+	signature := cv.signatures.Extract(tsNode, cv.sourceCode)
 	docstring := cv.extractPythonDocstring(tsNode)
 
 	chunkID := cv.generateChunkID(cv.filePath, name, tsNode.StartPosition().Row)
@@ -386,7 +392,7 @@ func (cv *ChunkVisitor) handleJavaMethod(ctx context.Context, tsNode *tree_sitte
 
 	name := cv.getNodeText(nameNode)
 	content := cv.getNodeText(tsNode)
-	signature := cv.extractJavaMethodSignature(tsNode)
+	signature := cv.signatures.Extract(tsNode, cv.sourceCode)
 
 	chunkID := cv.generateChunkID(cv.filePath, name, tsNode.StartPosition().Row)
 
@@ -468,7 +474,7 @@ func (cv *ChunkVisitor) handleJSFunction(ctx context.Context, tsNode *tree_sitte
 
 	name := cv.getNodeText(nameNode)
 	content := cv.getNodeText(tsNode)
-	signature := cv.extractJSFunctionSignature(tsNode)
+	signature := cv.signatures.Extract(tsNode, cv.sourceCode)
 
 	chunkID := cv.generateChunkID(cv.filePath, name, tsNode.StartPosition().Row)
 
@@ -507,7 +513,7 @@ func (cv *ChunkVisitor) handleJSMethod(ctx context.Context, tsNode *tree_sitter.
 
 	name := cv.getNodeText(nameNode)
 	content := cv.getNodeText(tsNode)
-	signature := cv.extractJSFunctionSignature(tsNode)
+	signature := cv.signatures.Extract(tsNode, cv.sourceCode)
 
 	chunkID := cv.generateChunkID(cv.filePath, name, tsNode.StartPosition().Row)
 
@@ -541,6 +547,69 @@ func (cv *ChunkVisitor) handleJSMethod(ctx context.Context, tsNode *tree_sitter.
 	return chunk
 }
 
+// functionValue returns the arrow function or function expression assigned by
+// a JavaScript/TypeScript variable declarator or class field, or nil
+func (cv *ChunkVisitor) functionValue(tsNode *tree_sitter.Node) *tree_sitter.Node {
+	value := cv.getChildByFieldName(tsNode, "value")
+	if value == nil {
+		return nil
+	}
+
+	switch value.Kind() {
+	case "arrow_function", "function_expression", "function":
+		return value
+	default:
+		return nil
+	}
+}
+
+// handleJSFunctionValue handles functions assigned to variables and class fields,
+// e.g. const add = (a: number, b: number): number => a + b
+func (cv *ChunkVisitor) handleJSFunctionValue(ctx context.Context, tsNode, fnNode *tree_sitter.Node) any {
+	nameNode := cv.getChildByFieldName(tsNode, "name")
+	if nameNode == nil {
+		nameNode = cv.getChildByFieldName(tsNode, "property")
+	}
+	if nameNode == nil {
+		return nil
+	}
+
+	name := cv.getNodeText(nameNode)
+	content := cv.getNodeText(tsNode)
+	signature := cv.signatures.Extract(fnNode, cv.sourceCode)
+
+	chunkID := cv.generateChunkID(cv.filePath, name, tsNode.StartPosition().Row)
+
+	parentID := ""
+	className := ""
+	if cv.currentClass != nil && tsNode.Kind() != "variable_declarator" {
+		parentID = cv.currentClass.ID
+		className = cv.currentClass.Name
+	} else if cv.currentFile != nil {
+		parentID = cv.currentFile.ID
+	}
+
+	chunk := model.NewCodeChunk(
+		chunkID,
+		model.ChunkTypeFunction,
+		3,
+		content,
+		cv.language,
+		cv.filePath,
+		cv.toRange(tsNode),
+	).WithParent(parentID).
+		WithName(name).
+		WithSignature(signature).
+		WithContext(cv.moduleName, className)
+
+	cv.chunks = append(cv.chunks, chunk)
+
+	// Traverse body to find conditionals and loops
+	cv.traverseChildren(ctx, fnNode)
+
+	return chunk
+}
+
 // handleTypeDeclaration handles Go type declarations
 func (cv *ChunkVisitor) handleTypeDeclaration(ctx context.Context, tsNode *tree_sitter.Node) {
 	for i := uint(0); i < tsNode.ChildCount(); i++ {
@@ -656,89 +725,6 @@ func (cv *ChunkVisitor) extractJavaPackageName(tsNode *tree_sitter.Node) {
 	}
 }
 
-func (cv *ChunkVisitor) extractGoFunctionSignature(tsNode *tree_sitter.Node) string {
-	nameNode := cv.getChildByFieldName(tsNode, "name")
-	paramsNode := cv.getChildByFieldName(tsNode, "parameters")
-	resultNode := cv.getChildByFieldName(tsNode, "result")
-
-	sig := ""
-	if nameNode != nil {
-		sig = cv.getNodeText(nameNode)
-	}
-	if paramsNode != nil {
-		sig += cv.getNodeText(paramsNode)
-	}
-	if resultNode != nil {
-		sig += " " + cv.getNodeText(resultNode)
-	}
-
-	return sig
-}
-
-func (cv *ChunkVisitor) extractPythonFunctionSignature(tsNode *tree_sitter.Node) string {
-	nameNode := cv.getChildByFieldName(tsNode, "name")
-	paramsNode := cv.getChildByFieldName(tsNode, "parameters")
-	returnNode := cv.getChildByFieldName(tsNode, "return_type")
-
-	sig := ""
-	if nameNode != nil {
-		sig = cv.getNodeText(nameNode)
-	}
-	if paramsNode != nil {
-		sig += cv.getNodeText(paramsNode)
-	}
-	if returnNode != nil {
-		sig += " -> " + cv.getNodeText(returnNode)
-	}
-
-	return sig
-}
-
-func (cv *ChunkVisitor) extractJavaMethodSignature(tsNode *tree_sitter.Node) string {
-	// Get method signature including modifiers, return type, name, and parameters
-	parts := []string{}
-
-	nameNode := cv.getChildByFieldName(tsNode, "name")
-	paramsNode := cv.getChildByFieldName(tsNode, "parameters")
-
-	for i := uint(0); i < tsNode.ChildCount(); i++ {
-		child := tsNode.Child(i)
-		kind := child.Kind()
-
-		if kind == "modifiers" || kind == "type_identifier" || kind == "void_type" {
-			parts = append(parts, cv.getNodeText(child))
-		}
-	}
-
-	if nameNode != nil {
-		parts = append(parts, cv.getNodeText(nameNode))
-	}
-	if paramsNode != nil {
-		parts = append(parts, cv.getNodeText(paramsNode))
-	}
-
-	return strings.Join(parts, " ")
-}
-
-func (cv *ChunkVisitor) extractJSFunctionSignature(tsNode *tree_sitter.Node) string {
-	nameNode := cv.getChildByFieldName(tsNode, "name")
-	paramsNode := cv.getChildByFieldName(tsNode, "parameters")
-	returnNode := cv.getChildByFieldName(tsNode, "return_type")
-
-	sig := ""
-	if nameNode != nil {
-		sig = cv.getNodeText(nameNode)
-	}
-	if paramsNode != nil {
-		sig += cv.getNodeText(paramsNode)
-	}
-	if returnNode != nil {
-		sig += ": " + cv.getNodeText(returnNode)
-	}
-
-	return sig
-}
-
 func (cv *ChunkVisitor) extractGoDocstring(tsNode *tree_sitter.Node) string {
 	// Go docstrings are comments immediately before the function
 	// This is a simplified implementation
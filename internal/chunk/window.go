@@ -0,0 +1,114 @@
+package chunk
+
+import (
+	"bot-go/internal/model"
+	"bot-go/pkg/lsp/base"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// SplitOversizedChunks splits every chunk longer than maxLines into
+// overlapping windows of at most maxLines lines. Consecutive windows share
+// overlapLines lines so that code spanning a window boundary is still
+// embedded together. Windows keep the ChunkType, Name, and other metadata of
+// the original chunk; the first window keeps the original ID so that
+// children referencing it as a parent remain valid.
+// A maxLines of 0 or less disables splitting.
+func SplitOversizedChunks(chunks []*model.CodeChunk, maxLines, overlapLines int) []*model.CodeChunk {
+	if maxLines <= 0 {
+		return chunks
+	}
+	if overlapLines < 0 || overlapLines >= maxLines {
+		overlapLines = 0
+	}
+
+	result := make([]*model.CodeChunk, 0, len(chunks))
+	for _, c := range chunks {
+		result = append(result, splitChunk(c, maxLines, overlapLines)...)
+	}
+	return result
+}
+
+// splitChunk splits a single chunk into windows, or returns it unchanged if it fits
+func splitChunk(c *model.CodeChunk, maxLines, overlapLines int) []*model.CodeChunk {
+	lines := strings.Split(c.Content, "\n")
+	if len(lines) <= maxLines {
+		return []*model.CodeChunk{c}
+	}
+
+	step := maxLines - overlapLines
+	windowCount := 1 + (len(lines)-maxLines+step-1)/step
+
+	windows := make([]*model.CodeChunk, 0, windowCount)
+	for index, start := 0, 0; index < windowCount; index, start = index+1, start+step {
+		end := start + maxLines
+		if end > len(lines) {
+			end = len(lines)
+		}
+
+		rng := base.Range{
+			Start: base.Position{Line: c.Range.Start.Line + start},
+			End:   base.Position{Line: c.Range.Start.Line + end - 1, Character: len(lines[end-1])},
+		}
+		if start == 0 {
+			rng.Start.Character = c.Range.Start.Character
+		}
+		if end == len(lines) {
+			rng.End = c.Range.End
+		}
+
+		id := c.ID
+		if index > 0 {
+			id = windowChunkID(c.ID, index)
+		}
+
+		window := model.NewCodeChunk(
+			id,
+			c.ChunkType,
+			c.Level,
+			strings.Join(lines[start:end], "\n"),
+			c.Language,
+			c.FilePath,
+			rng,
+		).WithFileID(c.FileID).
+			WithParent(c.ParentID).
+			WithName(c.Name).
+			WithSignature(c.Signature).
+			WithDocstring(c.Docstring).
+			WithContext(c.ModuleName, c.ClassName)
+
+		for k, v := range c.Metadata {
+			window.WithMetadata(k, v)
+		}
+		window.WithMetadata("window_index", index).
+			WithMetadata("window_count", windowCount).
+			WithMetadata("original_id", c.ID)
+
+		windows = append(windows, window)
+	}
+
+	return windows
+}
+
+// windowChunkID derives a stable UUID-formatted ID for the index-th window of a chunk
+func windowChunkID(originalID string, index int) string {
+	return derivedChunkID(originalID, "window", index)
+}
+
+// derivedChunkID derives a stable UUID-formatted ID for the index-th chunk of
+// the given kind cut from a chunk
+func derivedChunkID(originalID, kind string, index int) string {
+	input := fmt.Sprintf("%s:%s:%d", originalID, kind, index)
+	hash := sha256.Sum256([]byte(input))
+	hashStr := hex.EncodeToString(hash[:])
+
+	return fmt.Sprintf("%s-%s-%s-%s-%s",
+		hashStr[0:8],
+		hashStr[8:12],
+		hashStr[12:16],
+		hashStr[16:20],
+		hashStr[20:32],
+	)
+}
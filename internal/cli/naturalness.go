@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"bot-go/internal/service/ngram"
+	"bot-go/internal/util"
+)
+
+// Naturalness loads the n-gram model saved for a repository and prints how
+// natural a file is under it: the surprise of each line, then the file's
+// entropy, perplexity and z-score. An empty language is detected from the
+// file's path and content.
+func Naturalness(ctx context.Context, w io.Writer, ns *ngram.NGramService, repoName, filePath, language string) error {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	content = util.NormalizeSource(content)
+	if language == "" {
+		language = util.DetectFileLanguage(filePath)
+	}
+
+	if err := ns.LoadModel(repoName); err != nil {
+		return fmt.Errorf("failed to load n-gram model for %s: %w", repoName, err)
+	}
+
+	analysis, err := ns.AnalyzeCode(ctx, repoName, language, content)
+	if err != nil {
+		return err
+	}
+	zScore, err := ns.CalculateZScore(ctx, repoName, language, content)
+	if err != nil {
+		return err
+	}
+	surprises, err := ns.LineSurprises(ctx, repoName, language, content)
+	if err != nil {
+		return err
+	}
+
+	byLine := make(map[int]ngram.LineSurprise, len(surprises))
+	for _, surprise := range surprises {
+		byLine[surprise.Line] = surprise
+	}
+
+	fmt.Fprintf(w, "File: %s\nRepository: %s\nLanguage: %s\n\n", filePath, repoName, language)
+	fmt.Fprintf(w, "%5s  %8s  %s\n", "Line", "Surprise", "Source")
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	for i, line := range lines {
+		surprise := "-"
+		if scored, ok := byLine[i+1]; ok {
+			surprise = fmt.Sprintf("%.3f", scored.Surprise)
+		}
+		fmt.Fprintf(w, "%5d  %8s  %s\n", i+1, surprise, line)
+	}
+
+	fmt.Fprintf(w, "\nTokens: %d\n", analysis.TokenCount)
+	fmt.Fprintf(w, "Entropy: %.4f bits/token\n", zScore.Entropy)
+	fmt.Fprintf(w, "Perplexity: %.4f\n", analysis.Perplexity)
+	fmt.Fprintf(w, "Z-score: %.4f (%s: %s)\n", zScore.ZScore, zScore.Interpretation.Level, zScore.Interpretation.Description)
+	return nil
+}
@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/service/ngram"
+
+	"go.uber.org/zap"
+)
+
+func TestNaturalnessMatchesService(t *testing.T) {
+	repoPath := t.TempDir()
+	files := map[string]string{
+		"sum.go":   "package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n",
+		"count.go": "package a\n\nfunc Count(xs []int) int {\n\tn := 0\n\tfor range xs {\n\t\tn++\n\t}\n\treturn n\n}\n",
+		"max.go":   "package a\n\nfunc Max(xs []int) int {\n\tbest := xs[0]\n\tfor _, x := range xs {\n\t\tif x > best {\n\t\t\tbest = x\n\t\t}\n\t}\n\treturn best\n}\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(repoPath, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	ctx := context.Background()
+	modelDir := t.TempDir()
+	builder, err := ngram.NewNGramServiceWithOutputDir(modelDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	if err := builder.ProcessRepository(ctx, &config.Repository{Name: "corpus", Path: repoPath}, 3, 0, true, ""); err != nil {
+		t.Fatalf("ProcessRepository: %v", err)
+	}
+
+	sample := filepath.Join(t.TempDir(), "sample.go")
+	source := "package b\n\nfunc Min(xs []int) int {\n\tworst := xs[0]\n\n\treturn worst\n}\n"
+	if err := os.WriteFile(sample, []byte(source), 0o644); err != nil {
+		t.Fatalf("write sample.go: %v", err)
+	}
+
+	// A fresh service has no model in memory, as when run from the command line
+	ns, err := ngram.NewNGramServiceWithOutputDir(modelDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	var out bytes.Buffer
+	if err := Naturalness(ctx, &out, ns, "corpus", sample, ""); err != nil {
+		t.Fatalf("Naturalness: %v", err)
+	}
+
+	// The saved model scores the file as the one it was saved from
+	want, err := builder.CalculateZScore(ctx, "corpus", "go", []byte(source))
+	if err != nil {
+		t.Fatalf("CalculateZScore: %v", err)
+	}
+	report := out.String()
+	for _, line := range []string{
+		"Language: go",
+		fmt.Sprintf("Entropy: %.4f bits/token", want.Entropy),
+		fmt.Sprintf("Z-score: %.4f (%s: ", want.ZScore, want.Interpretation.Level),
+	} {
+		if !strings.Contains(report, line) {
+			t.Errorf("report is missing %q:\n%s", line, report)
+		}
+	}
+
+	// Every source line is listed; the blank one has no tokens to score
+	surprises, err := builder.LineSurprises(ctx, "corpus", "go", []byte(source))
+	if err != nil {
+		t.Fatalf("LineSurprises: %v", err)
+	}
+	for _, surprise := range surprises {
+		if surprise.Line == 5 {
+			t.Errorf("blank line 5 has surprise %+v", surprise)
+		}
+		if !strings.Contains(report, fmt.Sprintf("%5d  %8.3f  ", surprise.Line, surprise.Surprise)) {
+			t.Errorf("report is missing the surprise of line %d:\n%s", surprise.Line, report)
+		}
+	}
+	if !strings.Contains(report, fmt.Sprintf("%5d  %8s  \n", 5, "-")) {
+		t.Errorf("report does not list blank line 5 without a surprise:\n%s", report)
+	}
+
+	if err := Naturalness(ctx, &out, ns, "missing", sample, ""); err == nil {
+		t.Error("Naturalness with no saved model succeeded, want an error")
+	}
+}
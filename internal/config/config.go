@@ -3,8 +3,13 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"math"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"strings"
 
 	"gopkg.in/yaml.v2"
 )
@@ -20,6 +25,52 @@ type Repository struct {
 	Language           string `yaml:"language"`
 	Disabled           bool   `yaml:"disabled,omitempty"`
 	SkipOtherLanguages bool   `yaml:"skip_other_languages,omitempty"`
+	IncludeDocs        bool   `yaml:"include_docs,omitempty"` // Model Markdown and plain text files as "text" in n-gram naturalness
+	Ref                string `yaml:"ref,omitempty"`          // Git ref analysis compares against (defaults to git_analysis.ref, then HEAD)
+
+	// Language detection overrides for nonstandard file names, consulted before
+	// the built-in extension mapping. Path rules are checked first, in order.
+	ExtensionOverrides map[string]string  `yaml:"extension_overrides,omitempty"` // Extension (e.g. ".tmpl" or ".go.tmpl") -> language
+	PathLanguageRules  []PathLanguageRule `yaml:"path_language_rules,omitempty"`
+}
+
+// PathLanguageRule assigns a language to files matching a glob. The glob is
+// matched against the file name and the repo-relative slash-separated path.
+type PathLanguageRule struct {
+	Glob     string `yaml:"glob"`
+	Language string `yaml:"language"`
+}
+
+// LanguageOverride returns the language configured for a file by the
+// repository's path rules or extension overrides, or "" to use the defaults
+func (r *Repository) LanguageOverride(path string) string {
+	if len(r.PathLanguageRules) > 0 {
+		relPath := path
+		if rel, err := filepath.Rel(r.Path, path); err == nil && !strings.HasPrefix(rel, "..") {
+			relPath = rel
+		}
+		name := filepath.Base(path)
+		slashPath := filepath.ToSlash(relPath)
+		for _, rule := range r.PathLanguageRules {
+			if ok, _ := filepath.Match(rule.Glob, name); ok {
+				return rule.Language
+			}
+			if ok, _ := filepath.Match(rule.Glob, slashPath); ok {
+				return rule.Language
+			}
+		}
+	}
+
+	// Multi-part extensions such as ".go.tmpl" are allowed; the longest match wins
+	name := strings.ToLower(filepath.Base(path))
+	match, language := "", ""
+	for ext, lang := range r.ExtensionOverrides {
+		suffix := "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+		if len(suffix) > len(match) && strings.HasSuffix(name, suffix) {
+			match, language = suffix, lang
+		}
+	}
+	return language
 }
 
 type App struct {
@@ -31,6 +82,11 @@ type App struct {
 	GCThreshold                 int64  `yaml:"gc_threshold,omitempty"`
 	NumFileThreads              int    `yaml:"num_file_threads,omitempty"`
 	MaxConcurrentFileProcessing int    `yaml:"max_concurrent_file_processing,omitempty"`
+	MaxConcurrentHeavyJobs      int    `yaml:"max_concurrent_heavy_jobs,omitempty"` // Max processNGram/processDirectory jobs running at once (default 2)
+	AbsolutePaths               bool   `yaml:"absolute_paths,omitempty"`            // Report absolute file paths instead of repo-relative ones
+	RefreshInterval             int    `yaml:"refresh_interval,omitempty"`          // Seconds between checks for new commits to reprocess (0 disables)
+	MaxFileSizeBytes            int64  `yaml:"max_file_size_bytes,omitempty"`       // Files larger than this are not tokenized or chunked (0 = no limit)
+	VectorSelfTest              bool   `yaml:"vector_self_test,omitempty"`          // Embed and upsert/delete a throwaway point at startup, failing fast on misconfiguration
 }
 
 type McpConfig struct {
@@ -42,24 +98,100 @@ type Neo4jConfig struct {
 	URI      string `yaml:"uri"`
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+	// Connection tuning; zero values keep the driver defaults
+	MaxPoolSize       int  `yaml:"max_pool_size"`      // Maximum connections per host
+	ConnectionTimeout int  `yaml:"connection_timeout"` // Seconds to wait when opening a connection
+	Encrypted         bool `yaml:"encrypted"`          // Use TLS (bolt+s/neo4j+s) for plain bolt/neo4j URIs
 }
 
 type QdrantConfig struct {
-	Host   string `yaml:"host"`
-	Port   int    `yaml:"port"`
-	APIKey string `yaml:"apikey"`
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	APIKey   string `yaml:"apikey"`
+	Distance string `yaml:"distance"` // Collection similarity metric: cosine (default), dot or euclid
 }
 
 type OllamaConfig struct {
-	URL       string `yaml:"url"`
-	APIKey    string `yaml:"apikey"`
+	URL             string              `yaml:"url"`
+	APIKey          string              `yaml:"apikey"`
+	Model           string              `yaml:"model"`
+	Dimension       int                 `yaml:"dimension"`
+	StrictDimension bool                `yaml:"strict_dimension"`        // Fail startup if the model's vector length differs from Dimension
+	SearchModels    []OllamaModelConfig `yaml:"search_models,omitempty"` // Further models available for searching collections built with them
+	DocModel        *OllamaModelConfig  `yaml:"doc_model,omitempty"`     // Embeds chunk descriptions into a "doc" vector stored beside the "code" one
+}
+
+// OllamaModelConfig names an additional embedding model served by the same Ollama instance
+type OllamaModelConfig struct {
 	Model     string `yaml:"model"`
 	Dimension int    `yaml:"dimension"`
 }
 
 type ChunkingConfig struct {
-	MinConditionalLines int `yaml:"min_conditional_lines"`
-	MinLoopLines        int `yaml:"min_loop_lines"`
+	MinConditionalLines  int      `yaml:"min_conditional_lines"`
+	MinLoopLines         int      `yaml:"min_loop_lines"`
+	MaxChunkLines        int      `yaml:"max_chunk_lines,omitempty"`        // Split chunks longer than this into windows (0 disables)
+	OverlapLines         int      `yaml:"overlap_lines,omitempty"`          // Lines shared between consecutive windows
+	EmbeddingCachePath   string   `yaml:"embedding_cache_path,omitempty"`   // On-disk embedding cache (empty disables caching)
+	SkipGlobs            []string `yaml:"skip_globs,omitempty"`             // File patterns never chunked (defaults to minified/bundled/generated files)
+	MaxAvgLineLength     int      `yaml:"max_avg_line_length,omitempty"`    // Skip files with longer average lines as minified (negative disables)
+	GeneratedMarkerLines int      `yaml:"generated_marker_lines,omitempty"` // Leading lines searched for a "generated by" marker (negative disables)
+	MaxContentChars      int      `yaml:"max_content_chars,omitempty"`      // Shorten chunk content longer than this before embedding (0 disables)
+	ContentStrategy      string   `yaml:"content_strategy,omitempty"`       // truncate-tail, truncate-middle (default) or split
+}
+
+type NGramConfig struct {
+	OutputDir          string `yaml:"output_dir,omitempty"`           // Directory saved models are written to (default <app.workdir>/ngram_models)
+	PythonIndentTokens bool   `yaml:"python_indent_tokens,omitempty"` // Model Python block structure with INDENT/DEDENT tokens
+	MaxVocabulary      int    `yaml:"max_vocabulary,omitempty"`       // Distinct tokens per model before new ones count as <OOV> (0 = unlimited)
+	N                  int    `yaml:"n,omitempty"`                    // N-gram order the indexing pipeline builds and processNGram defaults to (default 3)
+	MinTokens          int    `yaml:"min_tokens,omitempty"`           // Files with fewer tokens are kept out of models the indexing pipeline builds (default: no minimum)
+	MinN               int    `yaml:"min_n,omitempty"`                // Smallest n-gram order processNGram accepts (default 1)
+	MaxN               int    `yaml:"max_n,omitempty"`                // Largest n-gram order processNGram accepts (default 7)
+
+	// GenericTokenizer models files in languages without a dedicated tokenizer
+	// as "generic", lexed without a grammar, when their extension is listed in
+	// GenericExtensions (default: Rust, Ruby, C#, C/C++, Kotlin, Swift, Scala and PHP)
+	GenericTokenizer  bool     `yaml:"generic_tokenizer,omitempty"`
+	GenericExtensions []string `yaml:"generic_extensions,omitempty"`
+
+	ZScoreScale *ZScoreScaleConfig `yaml:"zscore_scale,omitempty"` // Cutoffs and wording for z-score interpretation (default: ±1 and ±2)
+
+	// FileWeights scale how much matching files count towards a repository's
+	// model; the first matching rule applies and other files weigh 1.0
+	FileWeights []FileWeightRule `yaml:"file_weights,omitempty"`
+}
+
+// FileWeightRule weighs the files matching Glob, a path.Match pattern tried
+// against both the repository-relative slash path and the file name. Weight
+// 0.5 counts a file as half an occurrence and 0 keeps it out of the model.
+type FileWeightRule struct {
+	Glob   string  `yaml:"glob"`
+	Weight float64 `yaml:"weight"`
+}
+
+// ZScoreScaleConfig overrides the scale n-gram z-scores are interpreted on.
+// Scores below VeryLowBelow are very low, below LowBelow low, above
+// VeryHighAbove very high, above HighAbove high, and normal otherwise. Unset
+// cutoffs keep their defaults of -2, -1, 1 and 2.
+type ZScoreScaleConfig struct {
+	Name          string   `yaml:"name,omitempty"` // Reported with each interpretation (default "custom")
+	VeryLowBelow  *float64 `yaml:"very_low_below,omitempty"`
+	LowBelow      *float64 `yaml:"low_below,omitempty"`
+	HighAbove     *float64 `yaml:"high_above,omitempty"`
+	VeryHighAbove *float64 `yaml:"very_high_above,omitempty"`
+
+	// Levels overrides the wording of each level, keyed by very_low, low,
+	// normal, high or very_high
+	Levels map[string]ZScoreLevelConfig `yaml:"levels,omitempty"`
+}
+
+// ZScoreLevelConfig overrides how one z-score level is reported; empty fields
+// keep their defaults
+type ZScoreLevelConfig struct {
+	Label       string   `yaml:"label,omitempty"` // Reported as the level (default: the level's key)
+	Description string   `yaml:"description,omitempty"`
+	Percentile  *float64 `yaml:"percentile,omitempty"`
 }
 
 type BloomFilterConfig struct {
@@ -84,9 +216,15 @@ type MySQLConfig struct {
 }
 
 type CodeGraphConfig struct {
-	EnableBatchWrites bool `yaml:"enable_batch_writes"`
-	BatchSize         int  `yaml:"batch_size"` // Number of nodes/relations to batch before writing
-	PrintParseTree    bool `yaml:"print_parse_tree"`
+	EnableBatchWrites    bool     `yaml:"enable_batch_writes"`
+	BatchSize            int      `yaml:"batch_size"` // Number of nodes/relations to batch before writing
+	PrintParseTree       bool     `yaml:"print_parse_tree"`
+	VectorCallResolution bool     `yaml:"vector_call_resolution"`           // Resolve calls LSP could not resolve via vector search
+	VectorCallThreshold  float32  `yaml:"vector_call_threshold,omitempty"`  // Minimum similarity score for a vector-resolved call (default 0.85)
+	FirstClassMetadata   []string `yaml:"first_class_metadata,omitempty"`   // Extra metadata keys stored as top-level node properties instead of md_ prefixed
+	ComplexityLogicalOps bool     `yaml:"complexity_logical_ops,omitempty"` // Count && and || as decision points in cyclomatic complexity
+	SkipNodeTypes        []string `yaml:"skip_node_types,omitempty"`        // Node labels (e.g. Expression, Variable) not written; containment is re-pointed to the nearest written ancestor
+	ExternalPackages     []string `yaml:"external_packages,omitempty"`      // Package or path prefixes (stdlib, vendor roots) whose classes are not foreign data in ATFD
 }
 
 // GitAnalysisMode defines how git analysis is performed
@@ -101,6 +239,7 @@ type GitAnalysisConfig struct {
 	Enabled         bool            `yaml:"enabled"`
 	Mode            GitAnalysisMode `yaml:"mode"`              // "ondemand" or "precompute"
 	LookbackCommits int             `yaml:"lookback_commits"`  // How many commits to analyze (default: 1000)
+	Ref             string          `yaml:"ref,omitempty"`     // Branch, tag or commit to analyze (default: HEAD)
 }
 
 func (c *McpConfig) GetAddress() string {
@@ -115,14 +254,39 @@ type Config struct {
 	Qdrant        QdrantConfig        `yaml:"qdrant"`
 	Chunking      ChunkingConfig      `yaml:"chunking"`
 	Ollama        OllamaConfig        `yaml:"ollama"`
+	NGram         NGramConfig         `yaml:"ngram"`
 	BloomFilter   BloomFilterConfig   `yaml:"bloom_filter"`
 	IndexBuilding IndexBuildingConfig `yaml:"index_building"`
 	MySQL         MySQLConfig         `yaml:"mysql"`
 	CodeGraph     CodeGraphConfig     `yaml:"code_graph"`
 	GitAnalysis   GitAnalysisConfig   `yaml:"git_analysis"`
 	App           App                 `yaml:"app"`
+	Logging       LoggingConfig       `yaml:"logging"`
+
+	// Values changed by Validate, reported through Adjustments
+	adjustments []string
 }
 
+// Defaults Validate applies to unset App settings
+const (
+	DefaultGCThreshold    = 100
+	DefaultNumFileThreads = 2
+)
+
+// DefaultQdrantDistance is the collection similarity metric used when
+// qdrant.distance is unset
+const DefaultQdrantDistance = "cosine"
+
+// DefaultNGramN is the n-gram order used when ngram.n is unset (trigrams)
+const DefaultNGramN = 3
+
+// DefaultContentStrategy is how over-long chunk content is shortened when
+// chunking.content_strategy is unset
+const DefaultContentStrategy = "truncate-middle"
+
+// maxFileThreadsPerCPU bounds app.num_file_threads relative to the CPU count
+const maxFileThreadsPerCPU = 4
+
 // expandEnvVars expands environment variables in the given string
 // Supports formats: ${VAR}, $VAR, ${VAR:-default}
 func expandEnvVars(s string) string {
@@ -196,11 +360,6 @@ func LoadConfig(appConfigPath string, sourceConfigPath string) (*Config, error)
 	// Merge SourceConfig into configApp
 	configApp.Source = configSource.Source
 
-	// Validate repository configurations
-	if err := validateRepositories(&configApp); err != nil {
-		return nil, fmt.Errorf("invalid repository configuration: %w", err)
-	}
-
 	if configSource.Mcp.Host != "" {
 		configApp.Mcp = configSource.Mcp
 	}
@@ -217,9 +376,127 @@ func LoadConfig(appConfigPath string, sourceConfigPath string) (*Config, error)
 		configApp.Ollama = configSource.Ollama
 	}
 
+	if err := configApp.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &configApp, nil
 }
 
+// Validate rejects clearly invalid settings, fills in defaults for unset worker
+// settings and clamps app.num_file_threads to [1, NumCPU*maxFileThreadsPerCPU].
+// Clamped values are reported by Adjustments.
+func (c *Config) Validate() error {
+	if err := validateRepositories(c); err != nil {
+		return fmt.Errorf("invalid repository configuration: %w", err)
+	}
+
+	// Repositories without a ref of their own analyze git_analysis.ref
+	for i := range c.Source.Repositories {
+		if c.Source.Repositories[i].Ref == "" {
+			c.Source.Repositories[i].Ref = c.GitAnalysis.Ref
+		}
+	}
+
+	if c.App.Port < 0 || c.App.Port > 65535 {
+		return fmt.Errorf("invalid app.port %d: must be between 0 and 65535", c.App.Port)
+	}
+
+	if c.App.RefreshInterval < 0 {
+		return fmt.Errorf("invalid app.refresh_interval %d: must not be negative (0 disables refreshing)", c.App.RefreshInterval)
+	}
+
+	if c.App.MaxFileSizeBytes < 0 {
+		return fmt.Errorf("invalid app.max_file_size_bytes %d: must not be negative (0 means no limit)", c.App.MaxFileSizeBytes)
+	}
+
+	if c.NGram.MinN < 0 || c.NGram.MaxN < 0 {
+		return fmt.Errorf("invalid ngram.min_n %d or ngram.max_n %d: must not be negative (0 uses the default)", c.NGram.MinN, c.NGram.MaxN)
+	}
+	if c.NGram.MinN > 0 && c.NGram.MaxN > 0 && c.NGram.MinN > c.NGram.MaxN {
+		return fmt.Errorf("invalid ngram.min_n %d: must not exceed ngram.max_n %d", c.NGram.MinN, c.NGram.MaxN)
+	}
+	if c.NGram.N < 0 {
+		return fmt.Errorf("invalid ngram.n %d: must not be negative (0 uses the default)", c.NGram.N)
+	}
+	if c.NGram.N == 0 {
+		c.NGram.N = DefaultNGramN
+	}
+	if c.NGram.MinTokens < 0 {
+		return fmt.Errorf("invalid ngram.min_tokens %d: must not be negative (0 means no minimum)", c.NGram.MinTokens)
+	}
+	for i, rule := range c.NGram.FileWeights {
+		if _, err := path.Match(rule.Glob, ""); err != nil || rule.Glob == "" {
+			return fmt.Errorf("invalid ngram.file_weights[%d].glob %q: must be a non-empty path pattern", i, rule.Glob)
+		}
+		if rule.Weight < 0 || math.IsNaN(rule.Weight) || math.IsInf(rule.Weight, 0) {
+			return fmt.Errorf("invalid ngram.file_weights[%d].weight %v: must be a finite number of at least 0", i, rule.Weight)
+		}
+	}
+
+	if c.App.GCThreshold < 0 {
+		return fmt.Errorf("invalid app.gc_threshold %d: must not be negative (0 uses the default of %d)", c.App.GCThreshold, DefaultGCThreshold)
+	}
+	if c.App.GCThreshold == 0 {
+		c.App.GCThreshold = DefaultGCThreshold
+	}
+
+	if c.Chunking.MaxContentChars < 0 {
+		return fmt.Errorf("invalid chunking.max_content_chars %d: must not be negative (0 disables the limit)", c.Chunking.MaxContentChars)
+	}
+	switch c.Chunking.ContentStrategy {
+	case "":
+		c.Chunking.ContentStrategy = DefaultContentStrategy
+	case "truncate-tail", "truncate-middle", "split":
+	default:
+		return fmt.Errorf("invalid chunking.content_strategy %q: must be truncate-tail, truncate-middle or split", c.Chunking.ContentStrategy)
+	}
+
+	switch c.Qdrant.Distance {
+	case "":
+		c.Qdrant.Distance = DefaultQdrantDistance
+	case "cosine", "dot", "euclid":
+	default:
+		return fmt.Errorf("invalid qdrant.distance %q: must be cosine, dot or euclid", c.Qdrant.Distance)
+	}
+
+	if err := c.Logging.validate(); err != nil {
+		return err
+	}
+
+	maxThreads := runtime.NumCPU() * maxFileThreadsPerCPU
+	switch threads := c.App.NumFileThreads; {
+	case threads == 0:
+		c.App.NumFileThreads = DefaultNumFileThreads
+	case threads < 1:
+		c.App.NumFileThreads = 1
+		c.adjust("app.num_file_threads %d raised to 1", threads)
+	case threads > maxThreads:
+		c.App.NumFileThreads = maxThreads
+		c.adjust("app.num_file_threads %d lowered to %d (%d per CPU)", threads, maxThreads, maxFileThreadsPerCPU)
+	}
+
+	return nil
+}
+
+// Adjustments describes the settings Validate changed, for logging at startup
+func (c *Config) Adjustments() []string {
+	return c.adjustments
+}
+
+func (c *Config) adjust(format string, args ...any) {
+	c.adjustments = append(c.adjustments, fmt.Sprintf(format, args...))
+}
+
+// NGramOutputDir returns the directory n-gram models are saved to. It is
+// resolved on use, since the work directory can be overridden after loading.
+func (c *Config) NGramOutputDir() string {
+	if c.NGram.OutputDir != "" {
+		return c.NGram.OutputDir
+	}
+	return filepath.Join(c.App.WorkDir, "ngram_models")
+}
+
 func (c *Config) GetRepository(name string) (*Repository, error) {
 	for _, repo := range c.Source.Repositories {
 		if repo.Name == name {
@@ -236,6 +513,16 @@ func validateRepositories(config *Config) error {
 		if repo.SkipOtherLanguages && repo.Language == "" {
 			return fmt.Errorf("repository '%s': skip_other_languages is true but language is not specified", repo.Name)
 		}
+		for _, rule := range repo.PathLanguageRules {
+			if _, err := filepath.Match(rule.Glob, ""); err != nil || rule.Language == "" {
+				return fmt.Errorf("repository '%s': invalid path language rule %q -> %q", repo.Name, rule.Glob, rule.Language)
+			}
+		}
+		for ext, language := range repo.ExtensionOverrides {
+			if strings.TrimPrefix(ext, ".") == "" || language == "" {
+				return fmt.Errorf("repository '%s': invalid extension override %q -> %q", repo.Name, ext, language)
+			}
+		}
 	}
 	return nil
 }
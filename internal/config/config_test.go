@@ -2,6 +2,9 @@ package config
 
 import (
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -98,3 +101,232 @@ func TestExpandEnvVars(t *testing.T) {
 		})
 	}
 }
+
+func TestRepositoryLanguageOverride(t *testing.T) {
+	repo := &Repository{
+		Name: "web",
+		Path: "/src/web",
+		ExtensionOverrides: map[string]string{
+			".tmpl":    "go",
+			"go.tmpl":  "go",
+			".py.tmpl": "python",
+		},
+		PathLanguageRules: []PathLanguageRule{
+			{Glob: "scripts/*.tsx", Language: "javascript"},
+			{Glob: "Jenkinsfile", Language: "java"},
+		},
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/src/web/handler.tmpl", "go"},
+		{"/src/web/views/page.GO.TMPL", "go"},
+		{"/src/web/gen/model.py.tmpl", "python"},
+		{"/src/web/scripts/build.tsx", "javascript"},
+		{"/src/web/app/build.tsx", ""},
+		{"/src/web/ci/Jenkinsfile", "java"},
+		{"/src/web/main.go", ""},
+		{"/src/web/Makefile", ""},
+	}
+
+	for _, tt := range tests {
+		if got := repo.LanguageOverride(tt.path); got != tt.want {
+			t.Errorf("LanguageOverride(%s) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	maxThreads := runtime.NumCPU() * maxFileThreadsPerCPU
+
+	tests := []struct {
+		name            string
+		app             App
+		wantErr         string
+		wantGCThreshold int64
+		wantThreads     int
+		wantAdjusted    bool
+	}{
+		{
+			name:            "defaults for unset values",
+			app:             App{},
+			wantGCThreshold: DefaultGCThreshold,
+			wantThreads:     DefaultNumFileThreads,
+		},
+		{
+			name:            "explicit values kept",
+			app:             App{GCThreshold: 500, NumFileThreads: 1},
+			wantGCThreshold: 500,
+			wantThreads:     1,
+		},
+		{
+			name:            "too many threads clamped",
+			app:             App{NumFileThreads: 10000},
+			wantGCThreshold: DefaultGCThreshold,
+			wantThreads:     maxThreads,
+			wantAdjusted:    true,
+		},
+		{
+			name:            "negative threads raised",
+			app:             App{NumFileThreads: -3},
+			wantGCThreshold: DefaultGCThreshold,
+			wantThreads:     1,
+			wantAdjusted:    true,
+		},
+		{
+			name:    "negative gc threshold rejected",
+			app:     App{GCThreshold: -1},
+			wantErr: "gc_threshold",
+		},
+		{
+			name:    "negative refresh interval rejected",
+			app:     App{RefreshInterval: -1},
+			wantErr: "refresh_interval",
+		},
+		{
+			name:    "port out of range rejected",
+			app:     App{Port: 70000},
+			wantErr: "app.port",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{App: tt.app}
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() error = %v, want error mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if cfg.App.GCThreshold != tt.wantGCThreshold {
+				t.Errorf("gc threshold = %d, want %d", cfg.App.GCThreshold, tt.wantGCThreshold)
+			}
+			if cfg.App.NumFileThreads != tt.wantThreads {
+				t.Errorf("num file threads = %d, want %d", cfg.App.NumFileThreads, tt.wantThreads)
+			}
+			if adjusted := len(cfg.Adjustments()) > 0; adjusted != tt.wantAdjusted {
+				t.Errorf("adjustments = %v, want adjusted %v", cfg.Adjustments(), tt.wantAdjusted)
+			}
+		})
+	}
+}
+
+func TestNGramFileWeightsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []FileWeightRule
+		wantErr string
+	}{
+		{name: "valid rules", rules: []FileWeightRule{{Glob: "*_test.go", Weight: 0.5}, {Glob: "gen/*", Weight: 0}}},
+		{name: "empty glob rejected", rules: []FileWeightRule{{Weight: 1}}, wantErr: "file_weights[0].glob"},
+		{name: "malformed glob rejected", rules: []FileWeightRule{{Glob: "[", Weight: 1}}, wantErr: "file_weights[0].glob"},
+		{name: "negative weight rejected", rules: []FileWeightRule{{Glob: "*.go", Weight: 1}, {Glob: "*.py", Weight: -1}}, wantErr: "file_weights[1].weight"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{NGram: NGramConfig{FileWeights: tt.rules}}
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() error = %v, want error mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNGramOrderValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		n         int
+		minTokens int
+		want      int
+		wantErr   string
+	}{
+		{name: "unset uses trigrams", want: DefaultNGramN},
+		{name: "configured order kept", n: 5, minTokens: 20, want: 5},
+		{name: "negative order rejected", n: -1, wantErr: "ngram.n"},
+		{name: "negative minimum rejected", minTokens: -1, wantErr: "ngram.min_tokens"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{NGram: NGramConfig{N: tt.n, MinTokens: tt.minTokens}}
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() error = %v, want error mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if cfg.NGram.N != tt.want {
+				t.Errorf("ngram.n = %d, want %d", cfg.NGram.N, tt.want)
+			}
+		})
+	}
+}
+
+func TestQdrantDistanceValidate(t *testing.T) {
+	tests := []struct {
+		distance string
+		want     string
+		wantErr  bool
+	}{
+		{distance: "", want: DefaultQdrantDistance},
+		{distance: "dot", want: "dot"},
+		{distance: "euclid", want: "euclid"},
+		{distance: "manhattan", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		cfg := &Config{Qdrant: QdrantConfig{Distance: tt.distance}}
+		err := cfg.Validate()
+		if tt.wantErr {
+			if err == nil || !strings.Contains(err.Error(), "qdrant.distance") {
+				t.Errorf("distance %q: Validate() error = %v, want qdrant.distance error", tt.distance, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("distance %q: Validate() error = %v", tt.distance, err)
+		}
+		if cfg.Qdrant.Distance != tt.want {
+			t.Errorf("distance %q: got %q, want %q", tt.distance, cfg.Qdrant.Distance, tt.want)
+		}
+	}
+}
+
+func TestNGramOutputDir(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"relative to working directory", Config{}, "ngram_models"},
+		{"under workdir", Config{App: App{WorkDir: "/var/lib/bot"}}, filepath.Join("/var/lib/bot", "ngram_models")},
+		{"explicit directory", Config{App: App{WorkDir: "/var/lib/bot"}, NGram: NGramConfig{OutputDir: "/models/staging"}}, "/models/staging"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.NGramOutputDir(); got != tt.want {
+				t.Errorf("NGramOutputDir() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
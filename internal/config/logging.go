@@ -0,0 +1,90 @@
+package config
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Defaults Validate applies to unset logging settings
+const (
+	DefaultLogLevel    = "info"
+	DefaultLogEncoding = "json"
+)
+
+// DefaultLogOutputPaths is where logs are written when logging.output_paths is unset
+var DefaultLogOutputPaths = []string{"stderr"}
+
+// LoggingConfig configures the application logger
+type LoggingConfig struct {
+	Level       string                 `yaml:"level"`                  // debug, info, warn, error, dpanic, panic or fatal (default info)
+	Encoding    string                 `yaml:"encoding"`               // json or console (default json)
+	OutputPaths []string               `yaml:"output_paths,omitempty"` // Files, or stdout/stderr (default stderr)
+	Sampling    *LoggingSamplingConfig `yaml:"sampling,omitempty"`     // Unset keeps zap's production sampling
+}
+
+// LoggingSamplingConfig caps repeated log messages: per second, the first
+// Initial entries with the same level and message are logged, then every
+// Thereafter-th. Zero for both disables sampling.
+type LoggingSamplingConfig struct {
+	Initial    int `yaml:"initial"`
+	Thereafter int `yaml:"thereafter"`
+}
+
+// validate fills in defaults for unset logging settings and rejects unknown
+// levels and encodings
+func (l *LoggingConfig) validate() error {
+	if l.Level == "" {
+		l.Level = DefaultLogLevel
+	}
+	if _, err := zapcore.ParseLevel(l.Level); err != nil {
+		return fmt.Errorf("invalid logging.level %q: must be debug, info, warn, error, dpanic, panic or fatal", l.Level)
+	}
+
+	switch l.Encoding {
+	case "":
+		l.Encoding = DefaultLogEncoding
+	case "json", "console":
+	default:
+		return fmt.Errorf("invalid logging.encoding %q: must be json or console", l.Encoding)
+	}
+
+	if len(l.OutputPaths) == 0 {
+		l.OutputPaths = DefaultLogOutputPaths
+	}
+
+	if l.Sampling != nil && (l.Sampling.Initial < 0 || l.Sampling.Thereafter < 0) {
+		return fmt.Errorf("invalid logging.sampling %+v: values must not be negative", *l.Sampling)
+	}
+	return nil
+}
+
+// NewLogger builds a zap logger from the logging configuration, starting from
+// zap's production settings. Unset fields take the defaults Validate applies.
+func NewLogger(l LoggingConfig) (*zap.Logger, error) {
+	if err := l.validate(); err != nil {
+		return nil, err
+	}
+	level, _ := zapcore.ParseLevel(l.Level)
+
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(level)
+	cfg.Encoding = l.Encoding
+	cfg.OutputPaths = l.OutputPaths
+	if l.Encoding == "console" {
+		cfg.EncoderConfig = zap.NewDevelopmentEncoderConfig()
+	}
+	if l.Sampling != nil {
+		cfg.Sampling = &zap.SamplingConfig{Initial: l.Sampling.Initial, Thereafter: l.Sampling.Thereafter}
+		if l.Sampling.Initial == 0 && l.Sampling.Thereafter == 0 {
+			cfg.Sampling = nil
+		}
+	}
+
+	logger, err := cfg.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build logger: %w", err)
+	}
+	return logger, nil
+}
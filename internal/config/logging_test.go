@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggerSuppressesBelowLevel(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "app.log")
+	logger, err := NewLogger(LoggingConfig{Level: "warn", OutputPaths: []string{logPath}})
+	if err != nil {
+		t.Fatalf("NewLogger: %v", err)
+	}
+
+	logger.Debug("debug message")
+	logger.Info("info message")
+	logger.Warn("warn message")
+	logger.Error("error message")
+	_ = logger.Sync()
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	log := string(content)
+	for _, suppressed := range []string{"debug message", "info message"} {
+		if strings.Contains(log, suppressed) {
+			t.Errorf("log contains %q below the warn level:\n%s", suppressed, log)
+		}
+	}
+	for _, logged := range []string{"warn message", "error message"} {
+		if !strings.Contains(log, logged) {
+			t.Errorf("log is missing %q:\n%s", logged, log)
+		}
+	}
+	if !strings.HasPrefix(log, "{") {
+		t.Errorf("log is not JSON encoded by default:\n%s", log)
+	}
+}
+
+func TestLoggingValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		logging LoggingConfig
+		wantErr string
+	}{
+		{name: "defaults for unset values", logging: LoggingConfig{}},
+		{name: "console encoding", logging: LoggingConfig{Level: "debug", Encoding: "console"}},
+		{name: "unknown level rejected", logging: LoggingConfig{Level: "verbose"}, wantErr: "logging.level"},
+		{name: "unknown encoding rejected", logging: LoggingConfig{Encoding: "xml"}, wantErr: "logging.encoding"},
+		{name: "negative sampling rejected", logging: LoggingConfig{Sampling: &LoggingSamplingConfig{Initial: -1}}, wantErr: "logging.sampling"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{Logging: tt.logging}
+			err := cfg.Validate()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Validate() error = %v, want error mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if tt.logging.Level == "" && cfg.Logging.Level != DefaultLogLevel {
+				t.Errorf("level = %q, want %q", cfg.Logging.Level, DefaultLogLevel)
+			}
+			if tt.logging.Encoding == "" && cfg.Logging.Encoding != DefaultLogEncoding {
+				t.Errorf("encoding = %q, want %q", cfg.Logging.Encoding, DefaultLogEncoding)
+			}
+			if len(cfg.Logging.OutputPaths) == 0 {
+				t.Error("output paths left empty")
+			}
+		})
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"bot-go/internal/parse"
 	"bot-go/internal/service"
 	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/vector"
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"time"
 
@@ -14,10 +17,11 @@ import (
 
 // CodeGraphProcessor implements FileProcessor for code graph building
 type CodeGraphProcessor struct {
-	config      *config.Config
-	codeGraph   *codegraph.CodeGraph
-	repoService *service.RepoService
-	logger      *zap.Logger
+	config       *config.Config
+	codeGraph    *codegraph.CodeGraph
+	repoService  *service.RepoService
+	chunkService *vector.CodeChunkService // Optional; enables vector-based call resolution
+	logger       *zap.Logger
 }
 
 // NewCodeGraphProcessor creates a new code graph processor
@@ -35,6 +39,11 @@ func NewCodeGraphProcessor(
 	}
 }
 
+// SetChunkService provides the vector search used to resolve calls LSP could not resolve
+func (cgp *CodeGraphProcessor) SetChunkService(chunkService *vector.CodeChunkService) {
+	cgp.chunkService = chunkService
+}
+
 // Name returns the processor name
 func (cgp *CodeGraphProcessor) Name() string {
 	return "CodeGraph"
@@ -66,14 +75,25 @@ func (cgp *CodeGraphProcessor) ProcessFile(ctx context.Context, repo *config.Rep
 	version := int32(1) // Default version
 
 	err := fileParser.ParseAndTraverseWithContent(ctx, repo, info, fileCtx.FilePath, fileCtx.FileID, version, fileCtx.Content)
+	var syntaxErr *parse.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		// The graph was built from the parts of the file that parsed
+		cgp.logger.Warn("File parsed with syntax errors",
+			zap.String("path", fileCtx.FilePath),
+			zap.Int32("file_id", fileCtx.FileID),
+			zap.Error(err))
+		fileCtx.AddWarning(cgp.Name(), syntaxErr.Line, syntaxErr.Column, parse.ErrSyntax.Error())
+		err = nil
+	}
 	if err != nil {
 		cgp.logger.Error("Failed to parse file for code graph",
 			zap.String("path", fileCtx.FilePath),
 			zap.Int32("file_id", fileCtx.FileID),
 			zap.Error(err))
-		// Still cleanup buffers even on error
+		// Still cleanup buffers even on error; the IndexBuilder records the
+		// failure and continues with other files
 		cgp.codeGraph.CleanupFileBuffers(ctx, fileCtx.FileID)
-		return nil // Continue processing other files
+		return fmt.Errorf("failed to parse file: %w", err)
 	}
 
 	// Cleanup: flush remaining data and remove buffers for this file
@@ -106,6 +126,11 @@ func (cgp *CodeGraphProcessor) PostProcess(ctx context.Context, repo *config.Rep
 	}
 
 	postProcessor := NewPostProcessor(cgp.codeGraph, cgp.repoService.GetLspService(), cgp.logger)
+	postProcessor.SetComplexityLogicalOps(cgp.config.CodeGraph.ComplexityLogicalOps)
+	if cgp.config.CodeGraph.VectorCallResolution && cgp.chunkService != nil {
+		postProcessor.SetVectorCallResolver(NewVectorCallResolver(
+			cgp.codeGraph, cgp.chunkService, cgp.config.CodeGraph.VectorCallThreshold, cgp.logger))
+	}
 	err := postProcessor.PostProcessRepository(ctx, repo)
 	if err != nil {
 		cgp.logger.Error("Code graph post-processing failed",
@@ -14,15 +14,13 @@ type EmbeddingProcessor struct {
 	chunkService         *vector.CodeChunkService
 	logger               *zap.Logger
 	chunkCount           atomic.Int64
-	collectionInitialized map[string]bool // Track which collections have been created
 }
 
 // NewEmbeddingProcessor creates a new embedding processor
 func NewEmbeddingProcessor(chunkService *vector.CodeChunkService, logger *zap.Logger) *EmbeddingProcessor {
 	return &EmbeddingProcessor{
-		chunkService:          chunkService,
-		logger:                logger,
-		collectionInitialized: make(map[string]bool),
+		chunkService: chunkService,
+		logger:       logger,
 	}
 }
 
@@ -31,35 +29,6 @@ func (ep *EmbeddingProcessor) Name() string {
 	return "Embedding"
 }
 
-// ensureCollection ensures the Qdrant collection exists for the repository
-func (ep *EmbeddingProcessor) ensureCollection(ctx context.Context, collectionName string) error {
-	// Check if we've already initialized this collection
-	if ep.collectionInitialized[collectionName] {
-		return nil
-	}
-
-	// Check if collection exists in Qdrant
-	exists, err := ep.chunkService.GetVectorDB().CollectionExists(ctx, collectionName)
-	if err != nil {
-		return err
-	}
-
-	if !exists {
-		ep.logger.Info("Creating Qdrant collection", zap.String("collection", collectionName))
-		// Get embedding dimension from the embedding model
-		vectorDim := ep.chunkService.GetEmbeddingModel().GetDimension()
-		err = ep.chunkService.GetVectorDB().CreateCollection(ctx, collectionName, vectorDim, vector.DistanceMetricCosine)
-		if err != nil {
-			return err
-		}
-		ep.logger.Info("Qdrant collection created successfully", zap.String("collection", collectionName))
-	}
-
-	// Mark collection as initialized
-	ep.collectionInitialized[collectionName] = true
-	return nil
-}
-
 // ProcessFile processes a single file for embedding generation
 func (ep *EmbeddingProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
 	ep.logger.Debug("Processing file for embeddings",
@@ -69,7 +38,7 @@ func (ep *EmbeddingProcessor) ProcessFile(ctx context.Context, repo *config.Repo
 	collectionName := repo.Name
 
 	// Ensure collection exists before processing
-	if err := ep.ensureCollection(ctx, collectionName); err != nil {
+	if err := ep.chunkService.EnsureCollection(ctx, collectionName); err != nil {
 		ep.logger.Error("Failed to ensure collection exists",
 			zap.String("collection", collectionName),
 			zap.Error(err))
@@ -78,7 +47,7 @@ func (ep *EmbeddingProcessor) ProcessFile(ctx context.Context, repo *config.Repo
 
 	chunks, err := ep.chunkService.ProcessFileWithContentAndFileID(
 		ctx,
-		fileCtx.FilePath,
+		ep.chunkService.PathNormalizer(repo.Path).Normalize(fileCtx.FilePath),
 		repo.Language,
 		collectionName,
 		fileCtx.Content,
@@ -109,6 +78,12 @@ func (ep *EmbeddingProcessor) PostProcess(ctx context.Context, repo *config.Repo
 		zap.String("repo_name", repo.Name),
 		zap.Int64("total_chunks", totalChunks))
 
+	if err := ep.chunkService.SaveEmbeddingCache(); err != nil {
+		ep.logger.Warn("Failed to save embedding cache",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+	}
+
 	// Reset counter for next repository
 	ep.chunkCount.Store(0)
 	return nil
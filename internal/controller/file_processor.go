@@ -2,6 +2,7 @@ package controller
 
 import (
 	"bot-go/internal/config"
+	"bot-go/internal/model"
 	"context"
 )
 
@@ -27,6 +28,21 @@ type FileContext struct {
 
 	// Ephemeral indicates if this is an uncommitted/working directory version
 	Ephemeral bool
+
+	// Warnings collects problems processors found in a file they still processed
+	Warnings []model.FileWarning
+}
+
+// AddWarning records a problem processor found at line and column (1-based, 0
+// when unknown) of a file it still processed
+func (fc *FileContext) AddWarning(processor string, line, column int, message string) {
+	fc.Warnings = append(fc.Warnings, model.FileWarning{
+		Path:      fc.RelativePath,
+		Processor: processor,
+		Line:      line,
+		Column:    column,
+		Message:   message,
+	})
 }
 
 // FileProcessor defines the interface for processing individual files
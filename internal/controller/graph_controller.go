@@ -0,0 +1,436 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/pkg/lsp/base"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// maxExportDepth bounds the traversal depth accepted by ExportGraph
+const maxExportDepth = 5
+
+// defaultSearchLimit is the number of matches SearchGraphNodes and
+// FindFunctionsBySignature return when no limit is given
+const defaultSearchLimit = 20
+
+// searchNodeTypes are the node types SearchGraphNodes accepts in its type parameter
+var searchNodeTypes = map[string]ast.NodeType{
+	"function": ast.NodeTypeFunction,
+	"class":    ast.NodeTypeClass,
+}
+
+// GraphController exposes read-only endpoints for inspecting code graph nodes
+type GraphController struct {
+	graph  *codegraph.CodeGraph
+	logger *zap.Logger
+}
+
+// NewGraphController creates a new GraphController
+func NewGraphController(graph *codegraph.CodeGraph, logger *zap.Logger) *GraphController {
+	return &GraphController{
+		graph:  graph,
+		logger: logger,
+	}
+}
+
+// GraphNodeResponse describes a single code graph node
+type GraphNodeResponse struct {
+	ID       ast.NodeID     `json:"id"`
+	NodeType ast.NodeType   `json:"node_type"`
+	Type     string         `json:"type"`
+	Name     string         `json:"name"`
+	Range    base.Range     `json:"range"`
+	FileID   int32          `json:"file_id"`
+	FilePath string         `json:"file_path,omitempty"`
+	MetaData map[string]any `json:"metadata,omitempty"`
+}
+
+// GraphNodeChildrenResponse lists the nodes contained by a code graph node
+type GraphNodeChildrenResponse struct {
+	NodeID   ast.NodeID          `json:"node_id"`
+	Children []GraphNodeResponse `json:"children"`
+}
+
+// GraphSearchResponse lists the nodes whose names matched a search
+type GraphSearchResponse struct {
+	Query   string              `json:"query"`
+	Type    string              `json:"type"`
+	Results []GraphNodeResponse `json:"results"` // Ordered by name
+}
+
+// FunctionSignatureResponse lists the functions whose signatures matched a filter
+type FunctionSignatureResponse struct {
+	RepoName   string              `json:"repo_name"`
+	Name       string              `json:"name,omitempty"`
+	Params     string              `json:"params,omitempty"`
+	ReturnType string              `json:"return_type,omitempty"`
+	Language   string              `json:"language,omitempty"`
+	Results    []GraphNodeResponse `json:"results"` // Ordered by name
+}
+
+// DataFlowGraphResponse is the DATA_FLOW subgraph of a function
+type DataFlowGraphResponse struct {
+	FunctionID ast.NodeID               `json:"function_id"`
+	Nodes      []GraphNodeResponse      `json:"nodes"`
+	Edges      []codegraph.DataFlowEdge `json:"edges"` // Directed: the value of from flows into to
+}
+
+// PackageMetricsResponse lists coupling metrics for the packages of a repository
+type PackageMetricsResponse struct {
+	RepoName string                     `json:"repo_name"`
+	Packages []codegraph.PackageMetrics `json:"packages"`
+}
+
+// GetGraphNode returns a single node by ID along with its resolved file path
+func (gc *GraphController) GetGraphNode(c *gin.Context) {
+	node, ok := gc.lookupNode(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gc.toNodeResponse(c.Request.Context(), node))
+}
+
+// GetGraphNodeChildren returns the nodes directly contained by a node via CONTAINS
+func (gc *GraphController) GetGraphNodeChildren(c *gin.Context) {
+	node, ok := gc.lookupNode(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	children, err := gc.graph.GetContainedNodes(ctx, node.ID)
+	if err != nil {
+		gc.logger.Error("Failed to get contained nodes",
+			zap.Int64("node_id", int64(node.ID)),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get node children",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response := GraphNodeChildrenResponse{
+		NodeID:   node.ID,
+		Children: make([]GraphNodeResponse, 0, len(children)),
+	}
+	for _, child := range children {
+		response.Children = append(response.Children, gc.toNodeResponse(ctx, child))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetDataFlowGraph returns the variables and expressions of a function
+// connected by DATA_FLOW relations, for debugging how values propagate
+func (gc *GraphController) GetDataFlowGraph(c *gin.Context) {
+	node, ok := gc.lookupNode(c)
+	if !ok {
+		return
+	}
+	if node.NodeType != ast.NodeTypeFunction {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Node is not a function",
+			"node_id": node.ID,
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	graph, err := gc.graph.GetDataFlowGraph(ctx, node.ID)
+	if err != nil {
+		gc.logger.Error("Failed to get data flow graph",
+			zap.Int64("node_id", int64(node.ID)),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get data flow graph",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response := DataFlowGraphResponse{
+		FunctionID: graph.FunctionID,
+		Nodes:      make([]GraphNodeResponse, 0, len(graph.Nodes)),
+		Edges:      graph.Edges,
+	}
+	for _, n := range graph.Nodes {
+		response.Nodes = append(response.Nodes, gc.toNodeResponse(ctx, n))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// ExportGraph streams the subgraph around a node as DOT or GraphML. Query
+// parameters: depth (default 1, at most maxExportDepth) and format (dot or graphml).
+func (gc *GraphController) ExportGraph(c *gin.Context) {
+	format, err := codegraph.ParseExportFormat(c.Query("format"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid format",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	depth := 1
+	if value := c.Query("depth"); value != "" {
+		depth, err = strconv.Atoi(value)
+		if err != nil || depth < 0 || depth > maxExportDepth {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("depth must be an integer between 0 and %d", maxExportDepth),
+			})
+			return
+		}
+	}
+
+	node, ok := gc.lookupNode(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	if err := gc.graph.ExportSubgraph(c.Request.Context(), node.ID, depth, format, c.Writer); err != nil {
+		gc.logger.Error("Failed to export subgraph",
+			zap.Int64("node_id", int64(node.ID)),
+			zap.Int("depth", depth),
+			zap.Error(err))
+		if !c.Writer.Written() {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to export subgraph",
+				"details": err.Error(),
+			})
+		}
+	}
+}
+
+// searchLimit reads the limit query parameter of a search, defaulting to
+// defaultSearchLimit. It responds with 400 and reports false when the limit is
+// not an integer in [1, codegraph.MaxNameSearchResults].
+func searchLimit(c *gin.Context) (int, bool) {
+	value := c.Query("limit")
+	if value == "" {
+		return defaultSearchLimit, true
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 || limit > codegraph.MaxNameSearchResults {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("limit must be an integer between 1 and %d", codegraph.MaxNameSearchResults),
+		})
+		return 0, false
+	}
+	return limit, true
+}
+
+// SearchGraphNodes finds functions or classes in a repository by partial name.
+// Query parameters: repo_name, q (case-insensitive substring), type (function
+// or class, default function), language (optional, e.g. python) and limit
+// (default defaultSearchLimit, at most codegraph.MaxNameSearchResults).
+func (gc *GraphController) SearchGraphNodes(c *gin.Context) {
+	repoName, pattern := c.Query("repo_name"), c.Query("q")
+	if repoName == "" || pattern == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "repo_name and q are required",
+		})
+		return
+	}
+
+	typeName := c.DefaultQuery("type", "function")
+	nodeType, ok := searchNodeTypes[typeName]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("unsupported type %q: must be function or class", typeName),
+		})
+		return
+	}
+
+	limit, ok := searchLimit(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	nodes, err := gc.graph.SearchNodesByName(ctx, repoName, pattern, c.Query("language"), nodeType, limit)
+	if err != nil {
+		gc.logger.Error("Failed to search graph nodes",
+			zap.String("repo_name", repoName),
+			zap.String("query", pattern),
+			zap.String("type", typeName),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to search graph nodes",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response := GraphSearchResponse{
+		Query:   pattern,
+		Type:    typeName,
+		Results: make([]GraphNodeResponse, 0, len(nodes)),
+	}
+	for _, node := range nodes {
+		response.Results = append(response.Results, gc.toNodeResponse(ctx, node))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// FindFunctionsBySignature finds the functions in a repository whose
+// signature matches the given patterns. Query parameters: repo_name, and at
+// least one of name, params and return_type (case-insensitive substrings);
+// language and limit (default defaultSearchLimit, at most
+// codegraph.MaxNameSearchResults) are optional.
+func (gc *GraphController) FindFunctionsBySignature(c *gin.Context) {
+	repoName := c.Query("repo_name")
+	filter := codegraph.FunctionSignatureFilter{
+		Name:       c.Query("name"),
+		Params:     c.Query("params"),
+		ReturnType: c.Query("return_type"),
+		Language:   c.Query("language"),
+	}
+	if repoName == "" || (filter.Name == "" && filter.Params == "" && filter.ReturnType == "") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "repo_name and at least one of name, params or return_type are required",
+		})
+		return
+	}
+	limit, ok := searchLimit(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	nodes, err := gc.graph.FindFunctionsBySignature(ctx, repoName, filter, limit)
+	if err != nil {
+		gc.logger.Error("Failed to find functions by signature",
+			zap.String("repo_name", repoName),
+			zap.Any("filter", filter),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to find functions by signature",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response := FunctionSignatureResponse{
+		RepoName:   repoName,
+		Name:       filter.Name,
+		Params:     filter.Params,
+		ReturnType: filter.ReturnType,
+		Language:   filter.Language,
+		Results:    make([]GraphNodeResponse, 0, len(nodes)),
+	}
+	for _, node := range nodes {
+		response.Results = append(response.Results, gc.toNodeResponse(ctx, node))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPackageMetrics returns instability and abstractness for every package of
+// the repository given by the repo_name query parameter
+func (gc *GraphController) GetPackageMetrics(c *gin.Context) {
+	repoName := c.Query("repo_name")
+	if repoName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "repo_name is required",
+		})
+		return
+	}
+
+	packages, err := gc.graph.PackageMetrics(c.Request.Context(), repoName)
+	if err != nil {
+		gc.logger.Error("Failed to compute package metrics",
+			zap.String("repo_name", repoName),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compute package metrics",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, PackageMetricsResponse{
+		RepoName: repoName,
+		Packages: packages,
+	})
+}
+
+// lookupNode parses the node_id path parameter and repo_name query parameter
+// and loads the node. It writes the error response and returns false if the
+// node cannot be served.
+func (gc *GraphController) lookupNode(c *gin.Context) (*ast.Node, bool) {
+	repoName := c.Query("repo_name")
+	if repoName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "repo_name is required",
+		})
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid node id",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	ctx := c.Request.Context()
+	node, err := gc.graph.FindNodeByID(ctx, ast.NodeID(id))
+	if err != nil {
+		gc.logger.Error("Failed to read graph node",
+			zap.Int64("node_id", id),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read graph node",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	if node == nil || !gc.belongsToRepo(ctx, node, repoName) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Node not found",
+			"node_id": id,
+		})
+		return nil, false
+	}
+
+	return node, true
+}
+
+// belongsToRepo checks the repo recorded on the node's file scope. Nodes whose
+// file scope is missing are still served so that orphaned nodes can be debugged.
+func (gc *GraphController) belongsToRepo(ctx context.Context, node *ast.Node, repoName string) bool {
+	fileScope, err := gc.graph.ReadFileScope(ctx, ast.NodeID(node.FileID))
+	if err != nil {
+		return true
+	}
+	repo, ok := fileScope.MetaData["repo"].(string)
+	return !ok || repo == repoName
+}
+
+func (gc *GraphController) toNodeResponse(ctx context.Context, node *ast.Node) GraphNodeResponse {
+	return GraphNodeResponse{
+		ID:       node.ID,
+		NodeType: node.NodeType,
+		Type:     gc.graph.NodeLabel(node.NodeType),
+		Name:     node.Name,
+		Range:    node.Range,
+		FileID:   node.FileID,
+		FilePath: gc.graph.GetFilePath(ctx, node.FileID),
+		MetaData: node.MetaData,
+	}
+}
@@ -0,0 +1,623 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/pkg/lsp/base"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var (
+	mergeNodeRe     = regexp.MustCompile(`MERGE \(n:(\w+) \{id: \$id\}\)`)
+	mergeRelationRe = regexp.MustCompile(`MERGE \(parent\)-\[r:(\w+)\]->\(child\)`)
+	matchNodeRe     = regexp.MustCompile(`MATCH \(n:(\w+)\)`)
+	matchNodeIDRe   = regexp.MustCompile(`MATCH \(n \{id: \$id\}\)`)
+	matchContainsRe = regexp.MustCompile(`MATCH \(parent \{id: \$parentId\}\)-\[:CONTAINS\]->\(child\)`)
+	searchNameRe    = regexp.MustCompile(`MATCH \(f:FileScope \{repo: \$repo\}\)\s+(?:WHERE f\.language = \$language\s+)?(?:MATCH \(n:(\w+) \{fileId: f\.id\}\)|WITH f AS n)\s+WHERE toLower\(n\.name\) CONTAINS toLower\(\$pattern\)`)
+	containsPropRe  = regexp.MustCompile(`toLower\(coalesce\(n\.(\w+), ''\)\) CONTAINS toLower\(\$(\w+)\)`)
+)
+
+type memoryGraphNode struct {
+	label string
+	props map[string]any
+}
+
+type memoryGraphRelation struct {
+	label    string
+	parentID int64
+	childID  int64
+}
+
+// memoryGraphDB is an in-memory GraphDatabase that understands the handful of
+// Cypher shapes CodeGraph issues for node writes, CONTAINS relations, reads by
+// property or ID, name searches and signature searches. Reads fail with readErr
+// when it is set.
+type memoryGraphDB struct {
+	nodes     map[int64]*memoryGraphNode
+	relations []memoryGraphRelation
+	readErr   error
+}
+
+func newMemoryGraphDB() *memoryGraphDB {
+	return &memoryGraphDB{nodes: make(map[int64]*memoryGraphNode)}
+}
+
+func (m *memoryGraphDB) ExecuteWrite(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if match := mergeNodeRe.FindStringSubmatch(query); match != nil {
+		props := make(map[string]any, len(params))
+		for k, v := range params {
+			props[k] = v
+		}
+		m.nodes[params["id"].(int64)] = &memoryGraphNode{label: match[1], props: props}
+		return []map[string]any{{"n": props}}, nil
+	}
+	if match := mergeRelationRe.FindStringSubmatch(query); match != nil {
+		m.relations = append(m.relations, memoryGraphRelation{
+			label:    match[1],
+			parentID: params["parentId"].(int64),
+			childID:  params["childId"].(int64),
+		})
+	}
+	return nil, nil
+}
+
+func (m *memoryGraphDB) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	if m.readErr != nil {
+		return nil, m.readErr
+	}
+	if matchNodeIDRe.MatchString(query) {
+		// Label-less read by ID
+		if node, ok := m.nodes[params["id"].(int64)]; ok {
+			return []map[string]any{{"n": node.props}}, nil
+		}
+		return nil, nil
+	}
+	if matchContainsRe.MatchString(query) {
+		var records []map[string]any
+		for _, rel := range m.relations {
+			if rel.label == "CONTAINS" && rel.parentID == params["parentId"].(int64) {
+				if child, ok := m.nodes[rel.childID]; ok {
+					records = append(records, map[string]any{"child": child.props})
+				}
+			}
+		}
+		return records, nil
+	}
+	if match := searchNameRe.FindStringSubmatch(query); match != nil {
+		// Name search: nodes of the repository's file scopes, optionally of
+		// one language
+		label := match[1]
+		if label == "" {
+			label = "FileScope"
+		}
+		files := make(map[any]bool)
+		for _, node := range m.nodes {
+			if node.label != "FileScope" || node.props["repo"] != params["repo"] {
+				continue
+			}
+			if language, ok := params["language"]; ok && node.props["language"] != language {
+				continue
+			}
+			files[node.props["id"]] = true
+		}
+		pattern := strings.ToLower(params["pattern"].(string))
+		var names []string
+		byName := make(map[string]map[string]any)
+		for _, node := range m.nodes {
+			name, _ := node.props["name"].(string)
+			if node.label == label && files[node.props["fileId"]] && strings.Contains(strings.ToLower(name), pattern) {
+				names = append(names, name)
+				byName[name] = node.props
+			}
+		}
+		sort.Strings(names)
+		if limit := int(params["limit"].(int64)); len(names) > limit {
+			names = names[:limit]
+		}
+		records := make([]map[string]any, 0, len(names))
+		for _, name := range names {
+			records = append(records, map[string]any{"n": byName[name]})
+		}
+		return records, nil
+	}
+	if predicates := containsPropRe.FindAllStringSubmatch(query, -1); predicates != nil {
+		// Signature search: substring predicates plus equality on repo and
+		// language, ordered by name and limited
+		label := matchNodeRe.FindStringSubmatch(query)[1]
+		var matched []map[string]any
+		for _, node := range m.nodes {
+			matches := node.label == label && node.props["repo"] == params["repo"]
+			if language, ok := params["language"]; ok {
+				matches = matches && node.props["language"] == language
+			}
+			for _, predicate := range predicates {
+				value, _ := node.props[predicate[1]].(string)
+				pattern := strings.ToLower(params[predicate[2]].(string))
+				matches = matches && strings.Contains(strings.ToLower(value), pattern)
+			}
+			if matches {
+				matched = append(matched, node.props)
+			}
+		}
+		sort.Slice(matched, func(i, j int) bool {
+			return matched[i]["name"].(string) < matched[j]["name"].(string)
+		})
+		if limit := int(params["limit"].(int64)); len(matched) > limit {
+			matched = matched[:limit]
+		}
+		records := make([]map[string]any, 0, len(matched))
+		for _, props := range matched {
+			records = append(records, map[string]any{"n": props})
+		}
+		return records, nil
+	}
+	if match := matchNodeRe.FindStringSubmatch(query); match != nil {
+		// readNodes: every parameter is an equality filter
+		var ids []int64
+		for id, node := range m.nodes {
+			matches := node.label == match[1]
+			for key, value := range params {
+				matches = matches && node.props[key] == value
+			}
+			if matches {
+				ids = append(ids, id)
+			}
+		}
+		slices.Sort(ids)
+		records := make([]map[string]any, 0, len(ids))
+		for _, id := range ids {
+			records = append(records, map[string]any{"n": m.nodes[id].props})
+		}
+		return records, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryGraphDB) ExecuteReadSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	records, err := m.ExecuteRead(ctx, query, params)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return records[0], nil
+}
+
+func (m *memoryGraphDB) ExecuteWriteSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	records, err := m.ExecuteWrite(ctx, query, params)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return records[0], nil
+}
+
+func (m *memoryGraphDB) Close(ctx context.Context) error { return nil }
+
+func (m *memoryGraphDB) VerifyConnectivity(ctx context.Context) error { return nil }
+
+func newTestGraphRouter(t *testing.T) (*gin.Engine, *memoryGraphDB) {
+	t.Helper()
+	ctx := context.Background()
+	logger := zap.NewNop()
+	db := newMemoryGraphDB()
+	graph := codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, logger)
+
+	fileScope := ast.NewNode(1, ast.NodeTypeFileScope, 1, "service.go", base.Range{}, 0, 0)
+	fileScope.MetaData = map[string]any{"repo": "demo", "path": "pkg/service.go"}
+	class := ast.NewNode(10, ast.NodeTypeClass, 1, "Service",
+		base.Range{Start: base.Position{Line: 3}, End: base.Position{Line: 20, Character: 1}}, 0, 1)
+	class.MetaData = map[string]any{"complexity": int64(4)}
+	method := ast.NewNode(11, ast.NodeTypeFunction, 1, "Run",
+		base.Range{Start: base.Position{Line: 5}, End: base.Position{Line: 9, Character: 1}}, 0, 10)
+
+	if err := graph.CreateFileScope(ctx, fileScope); err != nil {
+		t.Fatalf("CreateFileScope: %v", err)
+	}
+	if err := graph.CreateClass(ctx, class); err != nil {
+		t.Fatalf("CreateClass: %v", err)
+	}
+	if err := graph.CreateFunction(ctx, method); err != nil {
+		t.Fatalf("CreateFunction: %v", err)
+	}
+	if err := graph.CreateContainsRelation(ctx, class.ID, method.ID, 1); err != nil {
+		t.Fatalf("CreateContainsRelation: %v", err)
+	}
+	call := ast.NewNode(12, ast.NodeTypeFunctionCall, 1, "Validate",
+		base.Range{Start: base.Position{Line: 6}, End: base.Position{Line: 6, Character: 12}}, 0, 11)
+	if err := graph.CreateFunctionCall(ctx, call); err != nil {
+		t.Fatalf("CreateFunctionCall: %v", err)
+	}
+
+	gc := NewGraphController(graph, logger)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/graph/node/:id", gc.GetGraphNode)
+	router.GET("/api/v1/graph/node/:id/children", gc.GetGraphNodeChildren)
+	return router, db
+}
+
+func TestGetGraphNode(t *testing.T) {
+	router, _ := newTestGraphRouter(t)
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+	}{
+		{"existing node", "/api/v1/graph/node/10?repo_name=demo", http.StatusOK},
+		{"function call", "/api/v1/graph/node/12?repo_name=demo", http.StatusOK},
+		{"missing node", "/api/v1/graph/node/999?repo_name=demo", http.StatusNotFound},
+		{"other repo", "/api/v1/graph/node/10?repo_name=other", http.StatusNotFound},
+		{"missing repo name", "/api/v1/graph/node/10", http.StatusBadRequest},
+		{"invalid id", "/api/v1/graph/node/abc?repo_name=demo", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/graph/node/10?repo_name=demo", nil))
+
+	var resp GraphNodeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Type != "Class" || resp.Name != "Service" {
+		t.Errorf("got type %q name %q, want Class Service", resp.Type, resp.Name)
+	}
+	if resp.FileID != 1 || resp.FilePath != "pkg/service.go" {
+		t.Errorf("got file %d %q, want 1 pkg/service.go", resp.FileID, resp.FilePath)
+	}
+	if resp.Range.Start.Line != 3 || resp.Range.End.Line != 20 {
+		t.Errorf("got range %+v, want lines 3-20", resp.Range)
+	}
+	if resp.MetaData["complexity"] != float64(4) {
+		t.Errorf("got metadata %v, want complexity 4", resp.MetaData)
+	}
+}
+
+func TestGetGraphNodeReadError(t *testing.T) {
+	router, db := newTestGraphRouter(t)
+	db.readErr = errors.New("connection refused")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/graph/node/10?repo_name=demo", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500 when the graph cannot be read", w.Code)
+	}
+}
+
+func TestGetGraphNodeChildren(t *testing.T) {
+	router, _ := newTestGraphRouter(t)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/graph/node/10/children?repo_name=demo", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %s)", w.Code, w.Body.String())
+	}
+
+	var resp GraphNodeChildrenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Children) != 1 {
+		t.Fatalf("got %d children, want 1", len(resp.Children))
+	}
+	if child := resp.Children[0]; child.ID != 11 || child.Type != "Function" || child.Name != "Run" {
+		t.Errorf("got child %+v, want Function Run with id 11", child)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/graph/node/999/children?repo_name=demo", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404 for missing node", w.Code)
+	}
+}
+
+func TestSearchGraphNodes(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+	graph := codegraph.NewCodeGraphWithDatabase(newMemoryGraphDB(), &config.Config{}, logger)
+
+	fileScope := ast.NewNode(1, ast.NodeTypeFileScope, 1, "service.go", base.Range{}, 0, 0)
+	fileScope.MetaData = map[string]any{"repo": "demo", "path": "pkg/service.go"}
+	if err := graph.CreateFileScope(ctx, fileScope); err != nil {
+		t.Fatalf("CreateFileScope: %v", err)
+	}
+
+	otherScope := ast.NewNode(2, ast.NodeTypeFileScope, 2, "elsewhere.go", base.Range{}, 0, 0)
+	otherScope.MetaData = map[string]any{"repo": "other", "path": "pkg/elsewhere.go"}
+	if err := graph.CreateFileScope(ctx, otherScope); err != nil {
+		t.Fatalf("CreateFileScope: %v", err)
+	}
+
+	functions := []struct {
+		id     ast.NodeID
+		name   string
+		fileID int32
+	}{
+		{20, "runner", 1},
+		{21, "Stop", 1},
+		{22, "RunAll", 1},
+		{23, "prune", 1},
+		{24, "Run", 1},
+		{25, "RunElsewhere", 2},
+	}
+	for _, fn := range functions {
+		node := ast.NewNode(fn.id, ast.NodeTypeFunction, fn.fileID, fn.name,
+			base.Range{Start: base.Position{Line: int(fn.id)}, End: base.Position{Line: int(fn.id) + 2}}, 0, ast.NodeID(fn.fileID))
+		if err := graph.CreateFunction(ctx, node); err != nil {
+			t.Fatalf("CreateFunction(%s): %v", fn.name, err)
+		}
+	}
+	class := ast.NewNode(30, ast.NodeTypeClass, 1, "Runtime", base.Range{}, 0, 1)
+	class.MetaData = map[string]any{"repo": "demo"}
+	if err := graph.CreateClass(ctx, class); err != nil {
+		t.Fatalf("CreateClass: %v", err)
+	}
+
+	gc := NewGraphController(graph, logger)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/graph/search", gc.SearchGraphNodes)
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		want       []string
+	}{
+		{"substring ignoring case", "/api/v1/graph/search?repo_name=demo&q=RUN", http.StatusOK, []string{"Run", "RunAll", "prune", "runner"}},
+		{"limit", "/api/v1/graph/search?repo_name=demo&q=run&limit=2", http.StatusOK, []string{"Run", "RunAll"}},
+		{"classes", "/api/v1/graph/search?repo_name=demo&q=run&type=class", http.StatusOK, []string{"Runtime"}},
+		{"no match", "/api/v1/graph/search?repo_name=demo&q=xyz", http.StatusOK, []string{}},
+		{"missing query", "/api/v1/graph/search?repo_name=demo", http.StatusBadRequest, nil},
+		{"unsupported type", "/api/v1/graph/search?repo_name=demo&q=run&type=loop", http.StatusBadRequest, nil},
+		{"limit too large", "/api/v1/graph/search?repo_name=demo&q=run&limit=1000", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.want == nil {
+				return
+			}
+
+			var resp GraphSearchResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			names := make([]string, 0, len(resp.Results))
+			for _, result := range resp.Results {
+				names = append(names, result.Name)
+				if result.FilePath != "pkg/service.go" {
+					t.Errorf("%s file path = %q, want pkg/service.go", result.Name, result.FilePath)
+				}
+			}
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("results = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchGraphNodesByLanguage(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+	graph := codegraph.NewCodeGraphWithDatabase(newMemoryGraphDB(), &config.Config{}, logger)
+
+	// Nodes take their repository and language from their file scope
+	files := []struct {
+		id       int32
+		path     string
+		language string
+		classes  []string
+	}{
+		{1, "pkg/service.go", "go", []string{"Service", "ServiceConfig"}},
+		{2, "scripts/service.py", "python", []string{"ServiceClient"}},
+	}
+	nextID := ast.NodeID(100)
+	for _, file := range files {
+		fileScope := ast.NewNode(ast.NodeID(file.id), ast.NodeTypeFileScope, file.id, file.path, base.Range{}, 0, 0)
+		fileScope.MetaData = map[string]any{"repo": "demo", "path": file.path, "language": file.language}
+		if err := graph.CreateFileScope(ctx, fileScope); err != nil {
+			t.Fatalf("CreateFileScope(%s): %v", file.path, err)
+		}
+		for _, name := range file.classes {
+			nextID++
+			class := ast.NewNode(nextID, ast.NodeTypeClass, file.id, name, base.Range{}, 0, ast.NodeID(file.id))
+			if err := graph.CreateClass(ctx, class); err != nil {
+				t.Fatalf("CreateClass(%s): %v", name, err)
+			}
+		}
+	}
+
+	names := func(nodes []*ast.Node) string {
+		var result []string
+		for _, node := range nodes {
+			result = append(result, node.Name)
+		}
+		return strings.Join(result, ",")
+	}
+
+	python, err := graph.FindNodesByLanguage(ctx, "demo", "python", ast.NodeTypeClass)
+	if err != nil {
+		t.Fatalf("FindNodesByLanguage: %v", err)
+	}
+	if got := names(python); got != "ServiceClient" {
+		t.Errorf("python classes = %s, want ServiceClient", got)
+	}
+	for _, node := range python {
+		if node.MetaData["language"] != "python" {
+			t.Errorf("%s language = %v, want python", node.Name, node.MetaData["language"])
+		}
+	}
+
+	gc := NewGraphController(graph, logger)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/graph/search", gc.SearchGraphNodes)
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"/api/v1/graph/search?repo_name=demo&q=service&type=class", "Service,ServiceClient,ServiceConfig"},
+		{"/api/v1/graph/search?repo_name=demo&q=service&type=class&language=go", "Service,ServiceConfig"},
+		{"/api/v1/graph/search?repo_name=demo&q=service&type=class&language=python", "ServiceClient"},
+		{"/api/v1/graph/search?repo_name=demo&q=service&type=class&language=java", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200 (body %s)", tt.url, w.Code, w.Body.String())
+		}
+		var resp GraphSearchResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: failed to decode response: %v", tt.url, err)
+		}
+		var got []string
+		for _, result := range resp.Results {
+			got = append(got, result.Name)
+		}
+		if strings.Join(got, ",") != tt.want {
+			t.Errorf("%s: results = %v, want %s", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestFindFunctionsBySignature(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+	graph := codegraph.NewCodeGraphWithDatabase(newMemoryGraphDB(), &config.Config{}, logger)
+
+	files := []struct {
+		id       int32
+		path     string
+		language string
+	}{
+		{1, "pkg/service.go", "go"},
+		{2, "web/client.ts", "typescript"},
+	}
+	for _, file := range files {
+		fileScope := ast.NewNode(ast.NodeID(file.id), ast.NodeTypeFileScope, file.id, file.path, base.Range{}, 0, 0)
+		fileScope.MetaData = map[string]any{"repo": "demo", "path": file.path, "language": file.language}
+		if err := graph.CreateFileScope(ctx, fileScope); err != nil {
+			t.Fatalf("CreateFileScope(%s): %v", file.path, err)
+		}
+	}
+
+	functions := []struct {
+		id         ast.NodeID
+		fileID     int32
+		name       string
+		params     string
+		returnType string
+	}{
+		{20, 1, "Run", "(ctx context.Context)", "error"},
+		{21, 1, "Load", "(ctx context.Context, key string)", "([]byte, error)"},
+		{22, 1, "Close", "()", "error"},
+		{23, 1, "String", "()", "string"},
+		{24, 1, "reset", "()", ""},
+		{25, 2, "fetchUser", "(id: string)", "Promise<User>"},
+		{26, 2, "fetchAll", "()", "Promise<User[]>"},
+		{27, 2, "render", "(user: User)", "void"},
+	}
+	for _, fn := range functions {
+		node := ast.NewNode(fn.id, ast.NodeTypeFunction, fn.fileID, fn.name, base.Range{}, 0, ast.NodeID(fn.fileID))
+		node.MetaData = map[string]any{"params": fn.params}
+		if fn.returnType != "" {
+			node.MetaData["return_type"] = fn.returnType
+		}
+		if err := graph.CreateFunction(ctx, node); err != nil {
+			t.Fatalf("CreateFunction(%s): %v", fn.name, err)
+		}
+	}
+
+	errorFuncs, err := graph.FindFunctionsByReturnType(ctx, "demo", "error", 0)
+	if err != nil {
+		t.Fatalf("FindFunctionsByReturnType: %v", err)
+	}
+	var names []string
+	for _, node := range errorFuncs {
+		names = append(names, node.Name)
+		if node.MetaData["return_type"] == nil {
+			t.Errorf("%s has no return_type metadata", node.Name)
+		}
+	}
+	if got := strings.Join(names, ","); got != "Close,Load,Run" {
+		t.Errorf("functions returning error = %s, want Close,Load,Run", got)
+	}
+
+	gc := NewGraphController(graph, logger)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/graph/functions", gc.FindFunctionsBySignature)
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		want       string
+	}{
+		{"return type ignoring case", "/api/v1/graph/functions?repo_name=demo&return_type=PROMISE", http.StatusOK, "fetchAll,fetchUser"},
+		{"partial return type", "/api/v1/graph/functions?repo_name=demo&return_type=User%5B%5D", http.StatusOK, "fetchAll"},
+		{"params", "/api/v1/graph/functions?repo_name=demo&params=context.Context", http.StatusOK, "Load,Run"},
+		{"all patterns", "/api/v1/graph/functions?repo_name=demo&name=l&params=ctx&return_type=error", http.StatusOK, "Load"},
+		{"language", "/api/v1/graph/functions?repo_name=demo&params=()&language=typescript", http.StatusOK, "fetchAll"},
+		{"other repository", "/api/v1/graph/functions?repo_name=other&return_type=error", http.StatusOK, ""},
+		{"limit", "/api/v1/graph/functions?repo_name=demo&return_type=error&limit=2", http.StatusOK, "Close,Load"},
+		{"limit too large", "/api/v1/graph/functions?repo_name=demo&return_type=error&limit=1000", http.StatusBadRequest, ""},
+		{"invalid limit", "/api/v1/graph/functions?repo_name=demo&return_type=error&limit=all", http.StatusBadRequest, ""},
+		{"no pattern", "/api/v1/graph/functions?repo_name=demo&language=go", http.StatusBadRequest, ""},
+		{"missing repository", "/api/v1/graph/functions?return_type=error", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var resp FunctionSignatureResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			names := make([]string, 0, len(resp.Results))
+			for _, result := range resp.Results {
+				names = append(names, result.Name)
+			}
+			if got := strings.Join(names, ","); got != tt.want {
+				t.Errorf("results = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// idempotencyKeyHeader is the request header naming a retryable request
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// defaultIdempotencyTTL is how long a completed request's response is replayed
+const defaultIdempotencyTTL = 10 * time.Minute
+
+// idempotencyState is what a store knows about a key being claimed
+type idempotencyState int
+
+const (
+	idempotencyClaimed    idempotencyState = iota // New key, reserved for the caller
+	idempotencyReplay                             // Completed; replay the stored response
+	idempotencyInProgress                         // Another request with the key is running
+	idempotencyMismatch                           // The key was used for a different request
+)
+
+// idempotentResponse is a stored response to replay
+type idempotentResponse struct {
+	status int
+	body   any
+}
+
+type idempotencyEntry struct {
+	fingerprint string
+	response    *idempotentResponse // nil while the request is running
+	expires     time.Time
+}
+
+// idempotencyStore remembers the responses of recently completed requests by
+// key, so a client retrying a request gets the original response instead of
+// having it processed twice. Entries live in memory for the store's TTL.
+type idempotencyStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[string]*idempotencyEntry
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// claim reserves key for a request identified by fingerprint, which must
+// describe everything that determines the response. When the key already
+// completed for the same fingerprint, its response is returned to replay.
+func (s *idempotencyStore) claim(key, fingerprint string) (idempotencyState, *idempotentResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for k, entry := range s.entries {
+		if entry.response != nil && now.After(entry.expires) {
+			delete(s.entries, k)
+		}
+	}
+
+	entry, ok := s.entries[key]
+	switch {
+	case !ok:
+		s.entries[key] = &idempotencyEntry{fingerprint: fingerprint}
+		return idempotencyClaimed, nil
+	case entry.fingerprint != fingerprint:
+		return idempotencyMismatch, nil
+	case entry.response == nil:
+		return idempotencyInProgress, nil
+	}
+	return idempotencyReplay, entry.response
+}
+
+// complete stores the response of a claimed key for replay
+func (s *idempotencyStore) complete(key string, status int, body any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok {
+		entry.response = &idempotentResponse{status: status, body: body}
+		entry.expires = s.now().Add(s.ttl)
+	}
+}
+
+// release forgets a claimed key whose request did not complete, so it can be
+// retried. Completed keys are kept.
+func (s *idempotencyStore) release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.entries[key]; ok && entry.response == nil {
+		delete(s.entries, key)
+	}
+}
+
+// claimIdempotencyKey claims the request's Idempotency-Key, if it has one, for
+// an endpoint. It returns the store key to complete and release, or "" when
+// the request carries no key. It returns false after responding itself: with
+// the stored response for a repeated key, 409 Conflict while the first request
+// is still running, or 422 when the key was used for a different request.
+func (rc *RepoController) claimIdempotencyKey(c *gin.Context, endpoint, fingerprint string) (string, bool) {
+	header := c.GetHeader(idempotencyKeyHeader)
+	if header == "" {
+		return "", true
+	}
+	key := endpoint + ":" + header
+
+	state, response := rc.idempotency.claim(key, fingerprint)
+	switch state {
+	case idempotencyReplay:
+		rc.logger.Info("Replaying response for repeated idempotency key",
+			zap.String("endpoint", endpoint),
+			zap.String("idempotency_key", header))
+		c.JSON(response.status, response.body)
+		return "", false
+	case idempotencyInProgress:
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Request with this idempotency key is still being processed",
+			"details": header,
+		})
+		return "", false
+	case idempotencyMismatch:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":   "Idempotency key was already used for a different request",
+			"details": header,
+		})
+		return "", false
+	}
+	return key, true
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newIdempotencyStore(time.Minute)
+	store.now = func() time.Time { return now }
+
+	if state, _ := store.claim("k", "a"); state != idempotencyClaimed {
+		t.Fatalf("first claim = %v, want claimed", state)
+	}
+	if state, _ := store.claim("k", "a"); state != idempotencyInProgress {
+		t.Errorf("claim while running = %v, want in progress", state)
+	}
+
+	// A request that did not complete can be retried
+	store.release("k")
+	if state, _ := store.claim("k", "a"); state != idempotencyClaimed {
+		t.Fatalf("claim after release = %v, want claimed", state)
+	}
+	store.complete("k", 200, "done")
+	store.release("k")
+
+	state, response := store.claim("k", "a")
+	if state != idempotencyReplay || response.status != 200 || response.body != "done" {
+		t.Errorf("claim after completion = %v %+v, want the stored response", state, response)
+	}
+	if state, _ := store.claim("k", "b"); state != idempotencyMismatch {
+		t.Errorf("claim for another request = %v, want mismatch", state)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if state, _ := store.claim("k", "b"); state != idempotencyClaimed {
+		t.Errorf("claim after the TTL = %v, want claimed", state)
+	}
+}
@@ -3,22 +3,34 @@ package controller
 import (
 	"bot-go/internal/config"
 	"bot-go/internal/db"
+	"bot-go/internal/model"
 	"bot-go/internal/util"
 	"context"
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 
 	"go.uber.org/zap"
 )
 
+// fileTracker is the part of db.FileVersionRepository the IndexBuilder uses
+// to assign file IDs, track per-file processing status and record the commit
+// a completed build processed
+type fileTracker interface {
+	GetOrCreateFileID(fileSHA, relativePath string, ephemeral bool, commitID *string) (int32, error)
+	GetFileByID(fileID int32) (*db.FileVersion, error)
+	UpdateStatus(fileID int32, status string) error
+	SetProcessedCommit(commitSHA string) error
+}
+
 // IndexBuilder orchestrates the building of various indexes (code graph, embeddings, n-gram)
 // for a repository using a parallel file processing approach
 type IndexBuilder struct {
 	config          *config.Config
 	processors      []FileProcessor
 	logger          *zap.Logger
-	fileVersionRepo *db.FileVersionRepository
+	fileVersionRepo fileTracker
 }
 
 // NewIndexBuilder creates a new index builder with the specified processors
@@ -38,10 +50,22 @@ func (ib *IndexBuilder) BuildIndex(ctx context.Context, repo *config.Repository)
 
 // BuildIndexWithGitInfo processes a repository with optional git HEAD optimization
 func (ib *IndexBuilder) BuildIndexWithGitInfo(ctx context.Context, repo *config.Repository, useHead bool, gitInfo *util.GitInfo) error {
+	_, err := ib.BuildIndexWithSummary(ctx, repo, useHead, gitInfo)
+	return err
+}
+
+// BuildIndexWithSummary processes a repository like BuildIndexWithGitInfo and
+// reports which files were processed, why any of them failed and what
+// problems were found in files processed anyway. Files that fail are logged
+// and skipped, so they never fail the build as a whole. The summary is
+// returned even when post-processing fails. Once post-processing succeeds the
+// commit the repository's ref pointed to when the build started is recorded
+// as processed, which is what RepoRefresher compares HEAD against.
+func (ib *IndexBuilder) BuildIndexWithSummary(ctx context.Context, repo *config.Repository, useHead bool, gitInfo *util.GitInfo) (*model.ProcessingSummary, error) {
 	if len(ib.processors) == 0 {
 		ib.logger.Warn("No processors registered, skipping index building",
 			zap.String("repo_name", repo.Name))
-		return nil
+		return &model.ProcessingSummary{Failures: []model.FileFailure{}, Warnings: []model.FileWarning{}}, nil
 	}
 
 	ib.logger.Info("Starting index building for repository",
@@ -56,6 +80,19 @@ func (ib *IndexBuilder) BuildIndexWithGitInfo(ctx context.Context, repo *config.
 	}
 	ib.logger.Info("Active processors", zap.Strings("processors", processorNames))
 
+	// Resolve the commit before processing so that commits made during the
+	// build are picked up by the next refresh
+	headSHA := ""
+	if gitInfo != nil {
+		headSHA = gitInfo.HeadCommitSHA
+	} else if sha, err := gitHeadSHA(repo.Path, repo.Ref); err != nil {
+		ib.logger.Warn("Failed to read repository HEAD, the build will not be recorded as processed",
+			zap.String("repo_name", repo.Name),
+			zap.Error(err))
+	} else {
+		headSHA = sha
+	}
+
 	// Log git info if using HEAD
 	if useHead && gitInfo != nil && gitInfo.IsGitRepo {
 		ib.logger.Info("Using git HEAD for index building",
@@ -65,24 +102,35 @@ func (ib *IndexBuilder) BuildIndexWithGitInfo(ctx context.Context, repo *config.
 	}
 
 	// Phase 1: Process all files in parallel
-	err := ib.processFiles(ctx, repo, useHead, gitInfo)
+	summary, err := ib.processFiles(ctx, repo, useHead, gitInfo)
 	if err != nil {
-		return fmt.Errorf("failed to process files for repository %s: %w", repo.Name, err)
+		return nil, fmt.Errorf("failed to process files for repository %s: %w", repo.Name, err)
 	}
 
 	// Phase 2: Run post-processing steps in parallel
 	err = ib.postProcessRepository(ctx, repo)
 	if err != nil {
-		return fmt.Errorf("failed to post-process repository %s: %w", repo.Name, err)
+		return summary, fmt.Errorf("failed to post-process repository %s: %w", repo.Name, err)
+	}
+
+	if headSHA != "" {
+		if err := ib.fileVersionRepo.SetProcessedCommit(headSHA); err != nil {
+			ib.logger.Warn("Failed to record processed commit",
+				zap.String("repo_name", repo.Name),
+				zap.String("commit_sha", headSHA),
+				zap.Error(err))
+		}
 	}
 
 	ib.logger.Info("Completed index building for repository",
-		zap.String("repo_name", repo.Name))
-	return nil
+		zap.String("repo_name", repo.Name),
+		zap.Int("files_attempted", summary.FilesAttempted),
+		zap.Int("files_failed", summary.FilesFailed))
+	return summary, nil
 }
 
 // processFiles walks the repository directory and processes each file through all processors in parallel
-func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repository, useHead bool, gitInfo *util.GitInfo) error {
+func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repository, useHead bool, gitInfo *util.GitInfo) (*model.ProcessingSummary, error) {
 	ib.logger.Info("Processing files",
 		zap.String("repo_name", repo.Name),
 		zap.String("path", repo.Path))
@@ -90,17 +138,27 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 	fileCount := 0
 	filesFromGit := 0
 	filesFromDisk := 0
+	summary := &model.ProcessingSummary{Failures: []model.FileFailure{}, Warnings: []model.FileWarning{}}
 	var mu sync.Mutex
 
+	// recordFailures counts a file as attempted and failed with the given reasons
+	recordFailures := func(failures ...model.FileFailure) {
+		mu.Lock()
+		defer mu.Unlock()
+		summary.FilesAttempted++
+		summary.FilesFailed++
+		summary.Failures = append(summary.Failures, failures...)
+	}
+
 	// Get configuration for WalkDirTree
 	gcThreshold := ib.config.App.GCThreshold
 	if gcThreshold == 0 {
-		gcThreshold = 100 // default
+		gcThreshold = config.DefaultGCThreshold
 	}
 
 	numThreads := ib.config.App.NumFileThreads
 	if numThreads == 0 {
-		numThreads = 2 // default
+		numThreads = config.DefaultNumFileThreads
 	}
 
 	// Define the skip function for WalkDirTree
@@ -148,6 +206,8 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 				return nil // Continue processing other files
 			}
 			ib.logger.Error("Failed to read file", zap.String("path", filePath), zap.Error(err))
+			relPath, _ := util.GetRelativePath(repo.Path, filePath)
+			recordFailures(model.FileFailure{Path: relPath, Reason: err.Error()})
 			return nil // Continue processing other files
 		}
 
@@ -166,6 +226,8 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 		fileCtx, err := ib.createFileContext(repo.Path, filePath, content, useHead, gitInfo)
 		if err != nil {
 			ib.logger.Error("Failed to create file context", zap.String("path", filePath), zap.Error(err))
+			relPath, _ := util.GetRelativePath(repo.Path, filePath)
+			recordFailures(model.FileFailure{Path: relPath, Reason: err.Error()})
 			return nil // Continue processing other files
 		}
 
@@ -200,6 +262,7 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 			wg.Wait()
 		*/
 
+		var failures []model.FileFailure
 		for _, processor := range ib.processors {
 			err := processor.ProcessFile(ctx, repo, fileCtx)
 			if err != nil {
@@ -207,6 +270,11 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 					zap.String("processor", processor.Name()),
 					zap.String("path", filePath),
 					zap.Error(err))
+				failures = append(failures, model.FileFailure{
+					Path:      fileCtx.RelativePath,
+					Processor: processor.Name(),
+					Reason:    err.Error(),
+				})
 				// Continue processing other processors
 			} else {
 				// Update status to indicate this processor completed
@@ -230,6 +298,16 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 		// Increment file count
 		mu.Lock()
 		fileCount++
+		summary.Warnings = append(summary.Warnings, fileCtx.Warnings...)
+		mu.Unlock()
+
+		if len(failures) > 0 {
+			recordFailures(failures...)
+			return nil
+		}
+		mu.Lock()
+		summary.FilesAttempted++
+		summary.FilesSucceeded++
 		mu.Unlock()
 
 		return nil
@@ -238,9 +316,23 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 	// Walk the directory tree using the utility function
 	err := util.WalkDirTree(repo.Path, walkFunc, skipFunc, ib.logger, gcThreshold, numThreads)
 	if err != nil {
-		return fmt.Errorf("failed to walk directory tree: %w", err)
+		return nil, fmt.Errorf("failed to walk directory tree: %w", err)
 	}
 
+	sort.SliceStable(summary.Failures, func(i, j int) bool {
+		return summary.Failures[i].Path < summary.Failures[j].Path
+	})
+	sort.SliceStable(summary.Warnings, func(i, j int) bool {
+		a, b := summary.Warnings[i], summary.Warnings[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
 	if useHead && gitInfo != nil && gitInfo.IsGitRepo {
 		ib.logger.Info("Completed file processing",
 			zap.String("repo_name", repo.Name),
@@ -252,8 +344,18 @@ func (ib *IndexBuilder) processFiles(ctx context.Context, repo *config.Repositor
 			zap.String("repo_name", repo.Name),
 			zap.Int("files_processed", fileCount))
 	}
+	if summary.FilesFailed > 0 {
+		ib.logger.Warn("Some files failed to process",
+			zap.String("repo_name", repo.Name),
+			zap.Int("files_failed", summary.FilesFailed))
+	}
+	if len(summary.Warnings) > 0 {
+		ib.logger.Warn("Some files were processed with warnings",
+			zap.String("repo_name", repo.Name),
+			zap.Int("warnings", len(summary.Warnings)))
+	}
 
-	return nil
+	return summary, nil
 }
 
 // postProcessRepository runs post-processing steps for all processors in parallel
@@ -0,0 +1,314 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/db"
+	"bot-go/internal/service/codegraph"
+
+	"go.uber.org/zap"
+)
+
+// memoryFileTracker assigns file IDs by path and keeps statuses and the
+// processed commit in memory
+type memoryFileTracker struct {
+	mu              sync.Mutex
+	ids             map[string]int32
+	statuses        map[int32]string
+	processedCommit string
+}
+
+func newMemoryFileTracker() *memoryFileTracker {
+	return &memoryFileTracker{ids: make(map[string]int32), statuses: make(map[int32]string)}
+}
+
+func (m *memoryFileTracker) GetOrCreateFileID(fileSHA, relativePath string, ephemeral bool, commitID *string) (int32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id, ok := m.ids[relativePath]; ok {
+		return id, nil
+	}
+	id := int32(len(m.ids) + 1)
+	m.ids[relativePath] = id
+	return id, nil
+}
+
+func (m *memoryFileTracker) GetFileByID(fileID int32) (*db.FileVersion, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status, ok := m.statuses[fileID]
+	if !ok {
+		return nil, fmt.Errorf("file version not found: %d", fileID)
+	}
+	return &db.FileVersion{FileID: fileID, Status: status}, nil
+}
+
+func (m *memoryFileTracker) UpdateStatus(fileID int32, status string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.statuses[fileID] = status
+	return nil
+}
+
+func (m *memoryFileTracker) SetProcessedCommit(commitSHA string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.processedCommit = commitSHA
+	return nil
+}
+
+// parseOnlyProcessor builds the code graph for each file but skips the
+// LSP-backed post-processing
+type parseOnlyProcessor struct {
+	*CodeGraphProcessor
+}
+
+func (p parseOnlyProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	return nil
+}
+
+// rejectingProcessor fails every file at path
+type rejectingProcessor struct {
+	path string
+}
+
+func (p rejectingProcessor) Name() string { return "Reject" }
+
+func (p rejectingProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	if fileCtx.RelativePath == p.path {
+		return fmt.Errorf("rejected %s", p.path)
+	}
+	return nil
+}
+
+func (p rejectingProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	return nil
+}
+
+// postProcessProcessor accepts every file and fails post-processing with err
+type postProcessProcessor struct {
+	err error
+}
+
+func (p postProcessProcessor) Name() string { return "PostProcess" }
+
+func (p postProcessProcessor) ProcessFile(ctx context.Context, repo *config.Repository, fileCtx *FileContext) error {
+	return nil
+}
+
+func (p postProcessProcessor) PostProcess(ctx context.Context, repo *config.Repository) error {
+	return p.err
+}
+
+func TestBuildIndexRecordsProcessedCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	repoPath := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repoPath
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	if err := os.WriteFile(filepath.Join(repoPath, "run.go"), []byte("package demo\n\nfunc Run() {}\n"), 0o644); err != nil {
+		t.Fatalf("write run.go: %v", err)
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	head := git("rev-parse", "HEAD")
+
+	tests := []struct {
+		name    string
+		postErr error
+		want    string
+	}{
+		{"completed build", nil, head},
+		{"failed post-processing", errors.New("lsp unavailable"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{App: config.App{NumFileThreads: 1}}
+			builder := NewIndexBuilder(cfg, []FileProcessor{postProcessProcessor{err: tt.postErr}}, nil, zap.NewNop())
+			tracker := newMemoryFileTracker()
+			builder.fileVersionRepo = tracker
+
+			repo := &config.Repository{Name: "demo", Path: repoPath, Language: "go"}
+			_, err := builder.BuildIndexWithSummary(context.Background(), repo, false, nil)
+			if (err != nil) != (tt.postErr != nil) {
+				t.Fatalf("BuildIndexWithSummary error = %v, want error %v", err, tt.postErr != nil)
+			}
+			if tracker.processedCommit != tt.want {
+				t.Errorf("processed commit = %q, want %q", tracker.processedCommit, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildIndexWithSummaryReportsSyntaxErrorsAsWarnings(t *testing.T) {
+	repoPath := t.TempDir()
+	files := map[string]string{
+		"good.go":       "package demo\n\nfunc Run() int {\n\treturn 1\n}\n",
+		"pkg/broken.go": "package demo\n\nfunc Broken( {\n\treturn\n",
+		"pkg/other.go":  "package demo\n\nfunc Other() {}\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(repoPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	logger := zap.NewNop()
+	// One thread keeps the in-memory graph free of concurrent writes
+	cfg := &config.Config{App: config.App{NumFileThreads: 1}}
+	graph := codegraph.NewCodeGraphWithDatabase(newMemoryGraphDB(), cfg, logger)
+	processor := parseOnlyProcessor{NewCodeGraphProcessor(cfg, graph, nil, logger)}
+	builder := NewIndexBuilder(cfg, []FileProcessor{processor, rejectingProcessor{path: "pkg/other.go"}}, nil, logger)
+	builder.fileVersionRepo = newMemoryFileTracker()
+
+	repo := &config.Repository{Name: "demo", Path: repoPath, Language: "go"}
+	summary, err := builder.BuildIndexWithSummary(context.Background(), repo, false, nil)
+	if err != nil {
+		t.Fatalf("BuildIndexWithSummary: %v", err)
+	}
+
+	if summary.FilesAttempted != 3 || summary.FilesSucceeded != 2 || summary.FilesFailed != 1 {
+		t.Errorf("counts = %d attempted, %d succeeded, %d failed; want 3, 2, 1",
+			summary.FilesAttempted, summary.FilesSucceeded, summary.FilesFailed)
+	}
+	if len(summary.Failures) != 1 {
+		t.Fatalf("failures = %+v, want one", summary.Failures)
+	}
+	if failure := summary.Failures[0]; failure.Path != "pkg/other.go" || failure.Processor != "Reject" {
+		t.Errorf("failure = %+v, want pkg/other.go from Reject", failure)
+	}
+
+	// The file with a syntax error is still indexed, with a located warning
+	if len(summary.Warnings) != 1 {
+		t.Fatalf("warnings = %+v, want one", summary.Warnings)
+	}
+	warning := summary.Warnings[0]
+	if warning.Path != "pkg/broken.go" || warning.Processor != "CodeGraph" || warning.Line != 3 || warning.Column == 0 {
+		t.Errorf("warning = %+v, want pkg/broken.go from CodeGraph at line 3", warning)
+	}
+	if !strings.Contains(warning.Message, "syntax error") {
+		t.Errorf("message = %q, want a syntax error", warning.Message)
+	}
+}
+
+func TestBuildIndexSkipsNodeTypes(t *testing.T) {
+	repoPath := t.TempDir()
+	source := `package demo
+
+func Run(items []int) int {
+	total := 0
+	for _, item := range items {
+		if item > 0 {
+			total += helper(item)
+		}
+	}
+	return total
+}
+
+func helper(x int) int {
+	return x * 2
+}
+`
+	if err := os.WriteFile(filepath.Join(repoPath, "run.go"), []byte(source), 0o644); err != nil {
+		t.Fatalf("write run.go: %v", err)
+	}
+
+	build := func(skip []string) *memoryGraphDB {
+		logger := zap.NewNop()
+		cfg := &config.Config{
+			App:       config.App{NumFileThreads: 1},
+			CodeGraph: config.CodeGraphConfig{SkipNodeTypes: skip},
+		}
+		db := newMemoryGraphDB()
+		graph := codegraph.NewCodeGraphWithDatabase(db, cfg, logger)
+		processor := parseOnlyProcessor{NewCodeGraphProcessor(cfg, graph, nil, logger)}
+		builder := NewIndexBuilder(cfg, []FileProcessor{processor}, nil, logger)
+		builder.fileVersionRepo = newMemoryFileTracker()
+		repo := &config.Repository{Name: "demo", Path: repoPath, Language: "go"}
+		if _, err := builder.BuildIndexWithSummary(context.Background(), repo, false, nil); err != nil {
+			t.Fatalf("BuildIndexWithSummary: %v", err)
+		}
+		return db
+	}
+	containers := func(db *memoryGraphDB) map[int64]int64 {
+		parents := make(map[int64]int64)
+		for _, rel := range db.relations {
+			if rel.label == "CONTAINS" {
+				parents[rel.childID] = rel.parentID
+			}
+		}
+		return parents
+	}
+
+	full := build(nil)
+	coarse := build([]string{"Expression", "Variable", "Block", "Conditional", "Loop", "NoSuchType"})
+	kept := map[string]bool{"FileScope": true, "ModuleScope": true, "Function": true, "Class": true, "FunctionCall": true}
+
+	for _, rel := range coarse.relations {
+		if coarse.nodes[rel.parentID] == nil || coarse.nodes[rel.childID] == nil {
+			t.Errorf("%s relation %d -> %d points at a node that was not written", rel.label, rel.parentID, rel.childID)
+		}
+	}
+
+	fullParents, coarseParents := containers(full), containers(coarse)
+	written := 0
+	for id, node := range full.nodes {
+		if !kept[node.label] {
+			if coarse.nodes[id] != nil {
+				t.Errorf("%s node %d was written", node.label, id)
+			}
+			continue
+		}
+		if coarse.nodes[id] == nil {
+			t.Errorf("%s %v is missing from the coarse graph", node.label, node.props["name"])
+			continue
+		}
+		written++
+		if node.label == "FileScope" {
+			continue
+		}
+
+		// The coarse container is the nearest written ancestor in the full graph
+		want := fullParents[id]
+		for full.nodes[want] != nil && !kept[full.nodes[want].label] {
+			want = fullParents[want]
+		}
+		if got := coarseParents[id]; got != want {
+			t.Errorf("%s %v is contained by %d, want %d", node.label, node.props["name"], got, want)
+		}
+	}
+
+	functionCalls := 0
+	for _, node := range coarse.nodes {
+		if node.label == "FunctionCall" {
+			functionCalls++
+		}
+	}
+	if functionCalls == 0 || written != len(coarse.nodes) {
+		t.Errorf("coarse graph has %d nodes and %d function calls, want %d nodes including calls",
+			len(coarse.nodes), functionCalls, written)
+	}
+}
@@ -14,16 +14,18 @@ type NGramProcessor struct {
 	ngramService *ngram.NGramService
 	logger       *zap.Logger
 	n            int  // N-gram size (e.g., 3 for trigrams)
+	minTokens    int  // Files with fewer tokens are kept out of the model
 	override     bool // Whether to override existing models
 	fileCount    atomic.Int64
 }
 
 // NewNGramProcessor creates a new n-gram processor
-func NewNGramProcessor(ngramService *ngram.NGramService, n int, override bool, logger *zap.Logger) *NGramProcessor {
+func NewNGramProcessor(ngramService *ngram.NGramService, n int, minTokens int, override bool, logger *zap.Logger) *NGramProcessor {
 	return &NGramProcessor{
 		ngramService: ngramService,
 		logger:       logger,
 		n:            n,
+		minTokens:    minTokens,
 		override:     override,
 	}
 }
@@ -57,7 +59,7 @@ func (np *NGramProcessor) PostProcess(ctx context.Context, repo *config.Reposito
 		zap.String("repo_name", repo.Name),
 		zap.Int("n", np.n))
 
-	err := np.ngramService.ProcessRepository(ctx, repo, np.n, np.override)
+	err := np.ngramService.ProcessRepository(ctx, repo, np.n, np.minTokens, np.override, "")
 	if err != nil {
 		np.logger.Error("Failed to build n-gram model",
 			zap.String("repo_name", repo.Name),
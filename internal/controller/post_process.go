@@ -6,6 +6,7 @@ import (
 	"bot-go/internal/model/ast"
 	"bot-go/internal/parse"
 	"bot-go/internal/service/codegraph"
+	"bot-go/internal/signals/complexity"
 	"bot-go/internal/util"
 	"bot-go/pkg/lsp"
 	"bot-go/pkg/lsp/base"
@@ -17,9 +18,11 @@ import (
 )
 
 type PostProcessor struct {
-	codeGraph  *codegraph.CodeGraph
-	lspService *lsp.LspService
-	logger     *zap.Logger
+	codeGraph    *codegraph.CodeGraph
+	lspService   *lsp.LspService
+	callResolver *VectorCallResolver // Optional; resolves calls LSP left dangling
+	logicalOps   bool                // Count && and || operators in cached complexity
+	logger       *zap.Logger
 }
 
 func NewPostProcessor(codeGraph *codegraph.CodeGraph, lspService *lsp.LspService, logger *zap.Logger) *PostProcessor {
@@ -30,6 +33,17 @@ func NewPostProcessor(codeGraph *codegraph.CodeGraph, lspService *lsp.LspService
 	}
 }
 
+// SetVectorCallResolver enables vector-based resolution of calls LSP could not resolve
+func (pp *PostProcessor) SetVectorCallResolver(resolver *VectorCallResolver) {
+	pp.callResolver = resolver
+}
+
+// SetComplexityLogicalOps selects whether cached complexity counts && and ||
+// operators as decision points
+func (pp *PostProcessor) SetComplexityLogicalOps(enabled bool) {
+	pp.logicalOps = enabled
+}
+
 func (pp *PostProcessor) ProcessFakeClasses(ctx context.Context, fileScope *ast.Node) error {
 	return pp.codeGraph.UpdateFakeClasses(ctx, fileScope.FileID)
 }
@@ -73,9 +87,60 @@ func (pp *PostProcessor) processOneFile(ctx context.Context, repo *config.Reposi
 		return fmt.Errorf("failed to process function calls: %w", err)
 	}
 
+	if pp.callResolver != nil {
+		if _, err := pp.callResolver.ResolveFile(ctx, repo, fileScope); err != nil {
+			pp.logger.Error("Failed to resolve function calls via vector search", zap.Error(err))
+		}
+	}
+
+	if err := pp.processComplexity(ctx, fileScope); err != nil {
+		pp.logger.Error("Failed to compute function complexity", zap.Error(err))
+	}
+
 	return nil
 }
 
+// processComplexity computes the cyclomatic complexity of every function in the
+// file and caches it on the function node so signal computation can skip the query
+func (pp *PostProcessor) processComplexity(ctx context.Context, fileScope *ast.Node) error {
+	functions, err := pp.codeGraph.GetFunctionsInFile(ctx, fileScope.FileID)
+	if err != nil {
+		return fmt.Errorf("failed to find functions: %w", err)
+	}
+	if len(functions) == 0 {
+		return nil
+	}
+
+	calculator := complexity.NewComplexityCalculator(pp.codeGraph)
+	updates := make(map[ast.NodeID]map[string]any, len(functions))
+	for _, function := range functions {
+		// Always recompute: a cached value may be stale after the file was re-indexed
+		var value int
+		if pp.logicalOps {
+			value, err = calculator.ComputeWithLogicalOps(ctx, function)
+		} else {
+			value, err = calculator.Compute(ctx, function.ID)
+		}
+		if err != nil {
+			pp.logger.Warn("Failed to compute complexity",
+				zap.Int64("functionId", int64(function.ID)),
+				zap.String("name", function.Name),
+				zap.Error(err))
+			continue
+		}
+		updates[function.ID] = map[string]any{complexity.ComplexityMetadataKey: value}
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	pp.logger.Debug("Caching function complexity",
+		zap.Int32("fileId", fileScope.FileID),
+		zap.Int("functions", len(updates)))
+	return pp.codeGraph.BatchUpdateNodeMetaData(ctx, updates)
+}
+
 func (pp *PostProcessor) processFunctionCalls(ctx context.Context, repo *config.Repository, fileScope *ast.Node) error {
 	functionCallsInFunction, err := pp.codeGraph.FindFunctionCalls(ctx, fileScope.ID)
 	if err != nil {
@@ -3,19 +3,29 @@ package controller
 import (
 	"bot-go/internal/config"
 	"bot-go/internal/db"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
 	"bot-go/internal/service/ngram"
 	"bot-go/internal/service/vector"
 	"bot-go/internal/util"
+	"bot-go/pkg/lsp/base"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"bot-go/internal/model"
 	"bot-go/internal/service"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
@@ -27,10 +37,47 @@ type RepoController struct {
 	mysqlConn    *db.MySQLConnection
 	config       *config.Config
 	logger       *zap.Logger
+	codeGraph    *codegraph.CodeGraph // Optional; nil disables code graph queries
+
+	// indexRepository runs the processor pipeline for a repository and
+	// summarizes the files it processed. It is nil when file tracking is
+	// unavailable, which disables processRepo.
+	indexRepository func(ctx context.Context, repo *config.Repository, useHead bool) (*model.ProcessingSummary, error)
+
+	// chunkDirectory chunks and embeds a repository into a collection,
+	// returning the number of chunks. It is nil without a chunk service,
+	// which disables processDirectory.
+	chunkDirectory func(ctx context.Context, repo *config.Repository, collectionName string, incremental bool) (int, error)
+
+	// Responses of processDirectory requests by Idempotency-Key, so retries
+	// are not reprocessed
+	idempotency *idempotencyStore
+
+	// Heavy jobs (processNGram, processDirectory, processRepo) are limited to
+	// one per repo and to cap(jobSlots) across all repos
+	jobsMu   sync.Mutex
+	inFlight map[string]string                     // repo name -> running job kind
+	jobs     map[string]*model.ProcessRepoResponse // processRepo jobs by ID
+	jobSlots chan struct{}
 }
 
+// processRepo job states
+const (
+	jobStatusRunning   = "running"
+	jobStatusCompleted = "completed"
+	jobStatusFailed    = "failed"
+)
+
+// defaultMaxConcurrentHeavyJobs is used when app.max_concurrent_heavy_jobs is not set
+const defaultMaxConcurrentHeavyJobs = 2
+
 func NewRepoController(repoService *service.RepoService, chunkService *vector.CodeChunkService, ngramService *ngram.NGramService, processors []FileProcessor, mysqlConn *db.MySQLConnection, config *config.Config, logger *zap.Logger) *RepoController {
-	return &RepoController{
+	maxHeavyJobs := config.App.MaxConcurrentHeavyJobs
+	if maxHeavyJobs <= 0 {
+		maxHeavyJobs = defaultMaxConcurrentHeavyJobs
+	}
+
+	rc := &RepoController{
 		repoService:  repoService,
 		chunkService: chunkService,
 		ngramService: ngramService,
@@ -38,7 +85,86 @@ func NewRepoController(repoService *service.RepoService, chunkService *vector.Co
 		mysqlConn:    mysqlConn,
 		config:       config,
 		logger:       logger,
+		inFlight:     make(map[string]string),
+		jobs:         make(map[string]*model.ProcessRepoResponse),
+		jobSlots:     make(chan struct{}, maxHeavyJobs),
+		idempotency:  newIdempotencyStore(defaultIdempotencyTTL),
+	}
+	if mysqlConn != nil {
+		rc.indexRepository = rc.buildRepositoryIndex
+	}
+	if chunkService != nil {
+		rc.chunkDirectory = rc.chunkRepository
+	}
+	return rc
+}
+
+// SetCodeGraph enables endpoints that query the code graph
+func (rc *RepoController) SetCodeGraph(codeGraph *codegraph.CodeGraph) {
+	rc.codeGraph = codeGraph
+}
+
+// beginHeavyJob reserves the repository for a heavy job and waits for a global
+// job slot. If the repository already has a job running it responds with 409
+// Conflict and returns false. The returned release func must be called when
+// the job is done.
+func (rc *RepoController) beginHeavyJob(c *gin.Context, repoName, kind string) (func(), bool) {
+	finish, ok := rc.reserveRepository(c, repoName, kind)
+	if !ok {
+		return nil, false
+	}
+
+	select {
+	case rc.jobSlots <- struct{}{}:
+	case <-c.Request.Context().Done():
+		finish()
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Request cancelled while waiting for a job slot",
+			"details": c.Request.Context().Err().Error(),
+		})
+		return nil, false
 	}
+
+	return func() {
+		<-rc.jobSlots
+		finish()
+	}, true
+}
+
+// reserveRepository marks the repository as running a job of the given kind,
+// responding with 409 Conflict and returning false if it already has one. The
+// returned func releases the reservation.
+func (rc *RepoController) reserveRepository(c *gin.Context, repoName, kind string) (func(), bool) {
+	finish, running, ok := rc.tryReserveRepository(repoName, kind)
+	if !ok {
+		rc.logger.Warn("Rejecting job for repository with a job in progress",
+			zap.String("repo_name", repoName),
+			zap.String("job", kind),
+			zap.String("running_job", running))
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "A job is already in progress for this repository",
+			"details": fmt.Sprintf("%s is running for repository %s", running, repoName),
+		})
+		return nil, false
+	}
+	return finish, true
+}
+
+// tryReserveRepository marks the repository as running a job of the given
+// kind. If it already has one, it returns that job's kind and false.
+func (rc *RepoController) tryReserveRepository(repoName, kind string) (func(), string, bool) {
+	rc.jobsMu.Lock()
+	defer rc.jobsMu.Unlock()
+	if running, exists := rc.inFlight[repoName]; exists {
+		return nil, running, false
+	}
+	rc.inFlight[repoName] = kind
+
+	return func() {
+		rc.jobsMu.Lock()
+		delete(rc.inFlight, repoName)
+		rc.jobsMu.Unlock()
+	}, "", true
 }
 
 type BuildIndexRequest struct {
@@ -110,7 +236,7 @@ func (rc *RepoController) BuildIndex(c *gin.Context) {
 	// Get git info if using HEAD mode
 	var gitInfo *util.GitInfo
 	if request.UseHead {
-		gitInfo, err = util.GetGitInfo(repo.Path)
+		gitInfo, err = util.GetGitInfo(repo.Path, repo.Ref)
 		if err != nil {
 			rc.logger.Error("Failed to get git info",
 				zap.String("repo_name", repo.Name),
@@ -155,6 +281,143 @@ func (rc *RepoController) BuildIndex(c *gin.Context) {
 	})
 }
 
+// ProcessRepo starts indexing a repository in the background and responds
+// with a job ID that can be polled with GetProcessRepoJob
+func (rc *RepoController) ProcessRepo(c *gin.Context) {
+	var request model.ProcessRepoRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		rc.logger.Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request payload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if rc.indexRepository == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error":   "Repository processing is not enabled",
+			"details": "MySQL file tracking is required to process repositories",
+		})
+		return
+	}
+
+	repo, err := rc.config.GetRepository(request.RepoName)
+	if err != nil {
+		rc.logger.Error("Repository not found in configuration",
+			zap.String("repo_name", request.RepoName),
+			zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Repository not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	finish, ok := rc.reserveRepository(c, repo.Name, "processRepo")
+	if !ok {
+		return
+	}
+
+	job := &model.ProcessRepoResponse{
+		JobID:     uuid.NewString(),
+		RepoName:  repo.Name,
+		Status:    jobStatusRunning,
+		StartedAt: time.Now(),
+	}
+	rc.jobsMu.Lock()
+	rc.jobs[job.JobID] = job
+	response := *job
+	rc.jobsMu.Unlock()
+
+	rc.logger.Info("Started repository processing job",
+		zap.String("repo_name", repo.Name),
+		zap.String("job_id", job.JobID),
+		zap.Bool("use_head", request.UseHead))
+
+	go rc.runProcessRepoJob(job, repo, request.UseHead, finish)
+
+	c.JSON(http.StatusAccepted, response)
+}
+
+// GetProcessRepoJob reports the status of a job started by ProcessRepo
+func (rc *RepoController) GetProcessRepoJob(c *gin.Context) {
+	jobID := c.Param("jobId")
+
+	rc.jobsMu.Lock()
+	job, exists := rc.jobs[jobID]
+	var response model.ProcessRepoResponse
+	if exists {
+		response = *job
+	}
+	rc.jobsMu.Unlock()
+
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Job not found",
+			"details": fmt.Sprintf("no processRepo job with ID %s", jobID),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// runProcessRepoJob waits for a job slot, indexes the repository and records the outcome
+func (rc *RepoController) runProcessRepoJob(job *model.ProcessRepoResponse, repo *config.Repository, useHead bool, finish func()) {
+	defer finish()
+	rc.jobSlots <- struct{}{}
+	defer func() { <-rc.jobSlots }()
+
+	summary, err := rc.indexRepository(context.Background(), repo, useHead)
+
+	finishedAt := time.Now()
+	rc.jobsMu.Lock()
+	job.FinishedAt = &finishedAt
+	job.Summary = summary
+	if err != nil {
+		job.Status = jobStatusFailed
+		job.Error = err.Error()
+	} else {
+		job.Status = jobStatusCompleted
+	}
+	rc.jobsMu.Unlock()
+
+	if err != nil {
+		rc.logger.Error("Repository processing job failed",
+			zap.String("repo_name", repo.Name),
+			zap.String("job_id", job.JobID),
+			zap.Error(err))
+		return
+	}
+	rc.logger.Info("Repository processing job completed",
+		zap.String("repo_name", repo.Name),
+		zap.String("job_id", job.JobID),
+		zap.Duration("duration", finishedAt.Sub(job.StartedAt)))
+}
+
+// buildRepositoryIndex runs all processors over a repository with MySQL file tracking
+func (rc *RepoController) buildRepositoryIndex(ctx context.Context, repo *config.Repository, useHead bool) (*model.ProcessingSummary, error) {
+	fileVersionRepo, err := db.NewFileVersionRepository(rc.mysqlConn.GetDB(), repo.Name, rc.logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize file tracking: %w", err)
+	}
+
+	var gitInfo *util.GitInfo
+	if useHead {
+		gitInfo, err = util.GetGitInfo(repo.Path, repo.Ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get git information: %w", err)
+		}
+		if !gitInfo.IsGitRepo {
+			return nil, fmt.Errorf("repository %s is not a git repository, cannot use use_head", repo.Name)
+		}
+	}
+
+	indexBuilder := NewIndexBuilder(rc.config, rc.processors, fileVersionRepo, rc.logger)
+	return indexBuilder.BuildIndexWithSummary(ctx, repo, useHead, gitInfo)
+}
+
+// GetFunctionsInFile lists the functions and methods the code graph holds for a file
 func (rc *RepoController) GetFunctionsInFile(c *gin.Context) {
 	var request model.GetFunctionsInFileRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
@@ -170,11 +433,50 @@ func (rc *RepoController) GetFunctionsInFile(c *gin.Context) {
 		zap.String("repo_name", request.RepoName),
 		zap.String("relative_path", request.RelativePath))
 
-	/*response, err := rc.repoService.GetFunctionsInFile(request.RepoName, request.RelativePath)
+	if rc.codeGraph == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error":   "Code graph is not enabled",
+			"details": "enable codegraph in the configuration to query functions in a file",
+		})
+		return
+	}
+
+	repo, err := rc.config.GetRepository(request.RepoName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Repository not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	filePath := util.NewPathNormalizer(repo.Path, false).Normalize(request.RelativePath)
+	fileScopes, err := rc.codeGraph.FindFileScopes(ctx, repo.Name, filePath)
+	if err != nil {
+		rc.logger.Error("Failed to find file in code graph",
+			zap.String("repo_name", repo.Name),
+			zap.String("relative_path", filePath),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get functions in file",
+			"details": err.Error(),
+		})
+		return
+	}
+	if len(fileScopes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "File not found in code graph",
+			"details": fmt.Sprintf("%s has not been indexed for repository %s", filePath, repo.Name),
+		})
+		return
+	}
+
+	functions, err := rc.describeFunctions(ctx, filePath, fileScopes)
 	if err != nil {
 		rc.logger.Error("Failed to get functions in file",
-			zap.String("repo_name", request.RepoName),
-			zap.String("relative_path", request.RelativePath),
+			zap.String("repo_name", repo.Name),
+			zap.String("relative_path", filePath),
 			zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to get functions in file",
@@ -184,15 +486,230 @@ func (rc *RepoController) GetFunctionsInFile(c *gin.Context) {
 	}
 
 	rc.logger.Info("Successfully got functions in file",
-		zap.String("repo_name", request.RepoName),
-		zap.String("relative_path", request.RelativePath),
-		zap.Int("function_count", len(response.Functions)))
+		zap.String("repo_name", repo.Name),
+		zap.String("relative_path", filePath),
+		zap.Int("function_count", len(functions)))
+
+	c.JSON(http.StatusOK, model.GetFunctionsInFileResponse{
+		RepoName:  repo.Name,
+		FilePath:  filePath,
+		Functions: functions,
+	})
+}
+
+// describeFunctions collects the functions contained in the file scopes, ordered by position
+func (rc *RepoController) describeFunctions(ctx context.Context, filePath string, fileScopes []*ast.Node) ([]model.FunctionDefinition, error) {
+	functions := []model.FunctionDefinition{}
+	for _, fileScope := range fileScopes {
+		nodes, err := rc.codeGraph.GetFunctionsInFileScope(ctx, fileScope.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			args, err := rc.codeGraph.ReadFunctionArgs(ctx, node.ID)
+			if err != nil {
+				return nil, err
+			}
+			argNames := make([]string, 0, len(args))
+			for _, arg := range args {
+				argNames = append(argNames, arg.Name)
+			}
+			params := strings.Join(argNames, ", ")
+
+			functions = append(functions, model.FunctionDefinition{
+				Name:      node.Name,
+				Location:  base.Location{URI: filePath, Range: node.Range},
+				Params:    params,
+				Signature: fmt.Sprintf("%s(%s)", node.Name, params),
+			})
+		}
+	}
+
+	sort.SliceStable(functions, func(i, j int) bool {
+		a, b := functions[i].Location.Range.Start, functions[j].Location.Range.Start
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Character < b.Character
+	})
+	return functions, nil
+}
+
+// GetFileCallEdges lists the calls made by every function in a file as
+// caller/callee edges, together with the calls that have no resolved callee
+func (rc *RepoController) GetFileCallEdges(c *gin.Context) {
+	var request model.GetFileCallEdgesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		rc.logger.Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request payload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if rc.codeGraph == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error":   "Code graph is not enabled",
+			"details": "enable codegraph in the configuration to query calls in a file",
+		})
+		return
+	}
+
+	repo, err := rc.config.GetRepository(request.RepoName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Repository not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	filePath := util.NewPathNormalizer(repo.Path, false).Normalize(request.FilePath)
+	fileScopes, err := rc.codeGraph.FindFileScopes(ctx, repo.Name, filePath)
+	if err != nil {
+		rc.logger.Error("Failed to find file in code graph",
+			zap.String("repo_name", repo.Name),
+			zap.String("file_path", filePath),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get call edges in file",
+			"details": err.Error(),
+		})
+		return
+	}
+	if len(fileScopes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "File not found in code graph",
+			"details": fmt.Sprintf("%s has not been indexed for repository %s", filePath, repo.Name),
+		})
+		return
+	}
+
+	response := model.GetFileCallEdgesResponse{
+		RepoName:   repo.Name,
+		FilePath:   filePath,
+		Edges:      []model.FileCallEdge{},
+		Unresolved: []model.UnresolvedCall{},
+	}
+	for _, fileScope := range fileScopes {
+		if err := rc.collectFileCallEdges(ctx, filePath, fileScope, &response); err != nil {
+			rc.logger.Error("Failed to get call edges in file",
+				zap.String("repo_name", repo.Name),
+				zap.String("file_path", filePath),
+				zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to get call edges in file",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	rc.logger.Info("Successfully got call edges in file",
+		zap.String("repo_name", repo.Name),
+		zap.String("file_path", filePath),
+		zap.Int("edge_count", len(response.Edges)),
+		zap.Int("unresolved_count", len(response.Unresolved)))
 
-	rc.logger.Debug("About to send JSON response")
 	c.JSON(http.StatusOK, response)
-	*/
-	c.JSON(http.StatusOK, nil)
-	rc.logger.Debug("JSON response sent successfully")
+}
+
+// collectFileCallEdges appends the resolved and unresolved calls made by the
+// functions of one file scope to the response, ordered by call position
+func (rc *RepoController) collectFileCallEdges(ctx context.Context, filePath string, fileScope *ast.Node, response *model.GetFileCallEdgesResponse) error {
+	functions, err := rc.codeGraph.GetFunctionsInFileScope(ctx, fileScope.ID)
+	if err != nil {
+		return err
+	}
+	functionsByID := make(map[ast.NodeID]*ast.Node, len(functions))
+	for _, function := range functions {
+		functionsByID[function.ID] = function
+	}
+
+	callsByFunction, err := rc.codeGraph.FindFunctionCalls(ctx, fileScope.ID)
+	if err != nil {
+		return err
+	}
+
+	// A call inside a nested function is contained by every enclosing
+	// function too; it belongs to the innermost one, which starts last
+	calls := make(map[ast.NodeID]*ast.Node)
+	callers := make(map[ast.NodeID]*ast.Node)
+	for functionID, functionCalls := range callsByFunction {
+		function, ok := functionsByID[functionID]
+		if !ok {
+			continue
+		}
+		for _, call := range functionCalls {
+			calls[call.ID] = call
+			if current, ok := callers[call.ID]; !ok || positionBefore(current.Range.Start, function.Range.Start) {
+				callers[call.ID] = function
+			}
+		}
+	}
+
+	ordered := make([]*ast.Node, 0, len(calls))
+	for _, call := range calls {
+		ordered = append(ordered, call)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		a, b := ordered[i].Range.Start, ordered[j].Range.Start
+		if a != b {
+			return positionBefore(a, b)
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+
+	for _, call := range ordered {
+		caller := callers[call.ID]
+		callerDef := model.FunctionDefinition{
+			Name:     caller.Name,
+			Location: base.Location{URI: filePath, Range: caller.Range},
+		}
+
+		relations, err := rc.codeGraph.GetOutgoingRelations(ctx, call.ID, "CALLS_FUNCTION")
+		if err != nil {
+			return err
+		}
+		if len(relations) == 0 {
+			response.Unresolved = append(response.Unresolved, model.UnresolvedCall{
+				Caller:    callerDef,
+				Name:      call.Name,
+				CallRange: call.Range,
+			})
+			continue
+		}
+
+		for _, relation := range relations {
+			callee, err := rc.codeGraph.GetNodeByID(ctx, relation.ToNodeID)
+			if err != nil {
+				return err
+			}
+			calleePath := filePath
+			if callee.FileID != fileScope.FileID {
+				calleePath = rc.codeGraph.GetFilePath(ctx, callee.FileID)
+			}
+			response.Edges = append(response.Edges, model.FileCallEdge{
+				Caller: callerDef,
+				Callee: model.FunctionDefinition{
+					Name:     callee.Name,
+					Location: base.Location{URI: calleePath, Range: callee.Range},
+				},
+				CallRange: call.Range,
+			})
+		}
+	}
+	return nil
+}
+
+// positionBefore reports whether a comes before b in a file
+func positionBefore(a, b base.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Character < b.Character
 }
 
 func (rc *RepoController) GetFunctionDetails(c *gin.Context) {
@@ -211,7 +728,7 @@ func (rc *RepoController) GetFunctionDetails(c *gin.Context) {
 		zap.String("relative_path", request.RelativePath),
 		zap.String("function_name", request.FunctionName))
 
-	response, err := rc.repoService.GetFunctionDetails(request.RepoName, request.RelativePath, request.FunctionName)
+	response, err := rc.repoService.GetFunctionDetails(c.Request.Context(), request.RepoName, request.RelativePath, request.FunctionName)
 	if err != nil {
 		rc.logger.Error("Failed to get function details",
 			zap.String("repo_name", request.RepoName),
@@ -228,7 +745,8 @@ func (rc *RepoController) GetFunctionDetails(c *gin.Context) {
 	rc.logger.Info("Successfully got function details",
 		zap.String("repo_name", request.RepoName),
 		zap.String("relative_path", request.RelativePath),
-		zap.String("function_name", request.FunctionName))
+		zap.String("function_name", request.FunctionName),
+		zap.String("source", string(response.Source)))
 
 	rc.logger.Debug("About to send JSON response")
 	c.JSON(http.StatusOK, response)
@@ -290,7 +808,7 @@ func (rc *RepoController) ProcessDirectory(c *gin.Context) {
 	}
 
 	// Check if chunk service is available
-	if rc.chunkService == nil {
+	if rc.chunkDirectory == nil {
 		rc.logger.Error("Code chunk service not available")
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"error": "Code chunk service not available",
@@ -299,7 +817,7 @@ func (rc *RepoController) ProcessDirectory(c *gin.Context) {
 	}
 
 	// Get repository configuration
-	repo, err := rc.repoService.GetConfig().GetRepository(request.RepoName)
+	repo, err := rc.config.GetRepository(request.RepoName)
 	if err != nil {
 		rc.logger.Error("Repository not found",
 			zap.String("repo_name", request.RepoName),
@@ -317,25 +835,28 @@ func (rc *RepoController) ProcessDirectory(c *gin.Context) {
 		collectionName = request.RepoName
 	}
 
+	// A retried request replays the first one's response
+	fingerprint := fmt.Sprintf("%s|%s|%t", request.RepoName, collectionName, request.IncrementalSinceHead)
+	idempotencyKey, ok := rc.claimIdempotencyKey(c, "processDirectory", fingerprint)
+	if !ok {
+		return
+	}
+	if idempotencyKey != "" {
+		defer rc.idempotency.release(idempotencyKey)
+	}
+
+	release, ok := rc.beginHeavyJob(c, request.RepoName, "processDirectory")
+	if !ok {
+		return
+	}
+	defer release()
+
 	rc.logger.Info("Processing directory for code chunking",
 		zap.String("repo_name", request.RepoName),
 		zap.String("path", repo.Path),
 		zap.String("collection", collectionName))
 
-	// Create collection if it doesn't exist
-	if err := rc.chunkService.CreateCollection(c.Request.Context(), collectionName); err != nil {
-		rc.logger.Error("Failed to create collection",
-			zap.String("collection", collectionName),
-			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to create collection",
-			"details": err.Error(),
-		})
-		return
-	}
-
-	// Process directory with repository configuration
-	totalChunks, err := rc.chunkService.ProcessDirectory(c.Request.Context(), repo.Path, collectionName, repo)
+	totalChunks, err := rc.chunkDirectory(c.Request.Context(), repo, collectionName, request.IncrementalSinceHead)
 	if err != nil {
 		rc.logger.Error("Failed to process directory",
 			zap.String("repo_name", request.RepoName),
@@ -364,13 +885,35 @@ func (rc *RepoController) ProcessDirectory(c *gin.Context) {
 		Message:        "Directory processed successfully",
 	}
 
+	if idempotencyKey != "" {
+		rc.idempotency.complete(idempotencyKey, http.StatusOK, response)
+	}
 	c.JSON(http.StatusOK, response)
 }
 
-// SearchSimilarCode handles searching for similar code using a code snippet
-func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
-	var request model.SearchSimilarCodeRequest
-
+// chunkRepository processes a repository with the chunk service, which
+// creates the collection if it doesn't exist. Incremental runs only process
+// files modified relative to the repository's git ref and new untracked files.
+func (rc *RepoController) chunkRepository(ctx context.Context, repo *config.Repository, collectionName string, incremental bool) (int, error) {
+	if incremental {
+		return rc.chunkService.ProcessChangedFiles(ctx, repo, collectionName)
+	}
+	return rc.chunkService.ProcessDirectory(ctx, repo.Path, collectionName, repo)
+}
+
+// similarCodeLanguages are the snippet languages similar code search can chunk
+var similarCodeLanguages = map[string]bool{
+	"go":         true,
+	"python":     true,
+	"java":       true,
+	"javascript": true,
+	"typescript": true,
+}
+
+// SearchSimilarCode handles searching for similar code using a code snippet
+func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
+	var request model.SearchSimilarCodeRequest
+
 	if err := c.ShouldBindJSON(&request); err != nil {
 		rc.logger.Error("Invalid request", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -390,14 +933,7 @@ func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
 	}
 
 	// Validate language
-	validLanguages := map[string]bool{
-		"go":         true,
-		"python":     true,
-		"java":       true,
-		"javascript": true,
-		"typescript": true,
-	}
-	if !validLanguages[request.Language] {
+	if !similarCodeLanguages[request.Language] {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Unsupported language. Supported: go, python, java, javascript, typescript",
 		})
@@ -435,7 +971,12 @@ func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
 		rc.logger.Error("Failed to search for similar code",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.SearchSimilarCodeResponse{
+		status := http.StatusInternalServerError
+		if errors.Is(err, vector.ErrEmbeddingModelUnavailable) {
+			// The collection needs an embedding model this server is not configured with
+			status = http.StatusConflict
+		}
+		c.JSON(status, model.SearchSimilarCodeResponse{
 			RepoName:       request.RepoName,
 			CollectionName: collectionName,
 			Query: model.QueryInfo{
@@ -450,18 +991,31 @@ func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
 		return
 	}
 
+	// Chunk paths may be repo-relative; resolve them against the repo root when reading code
+	repoRoot := ""
+	if repo, err := rc.config.GetRepository(request.RepoName); err == nil {
+		repoRoot = repo.Path
+	}
+	paths := rc.chunkService.PathNormalizer(repoRoot)
+
 	// Build results
 	results := make([]model.SimilarCodeResult, len(resultChunks))
 	for i, chunk := range resultChunks {
-		result := model.SimilarCodeResult{
+		results[i] = model.SimilarCodeResult{
 			Chunk:           chunk,
 			Score:           scores[i],
 			QueryChunkIndex: queryChunkIndices[i],
+			QueryChunk:      matchedQueryChunk(queryChunks, queryChunkIndices[i]),
 		}
+	}
+	results = filterSimilarResults(results, request.MinScore, request.Dedup)
 
-		// Fetch code from file if requested
-		if request.IncludeCode {
-			code, err := rc.chunkService.ReadCodeFromFile(chunk.FilePath, chunk.StartLine, chunk.EndLine)
+	// Fetch code from file if requested
+	if request.IncludeCode {
+		files := rc.chunkService.NewFileLineCache()
+		for i := range results {
+			chunk := results[i].Chunk
+			code, err := rc.chunkService.ReadCodeFromFile(files, paths.Resolve(chunk.FilePath), chunk.StartLine, chunk.EndLine)
 			if err != nil {
 				rc.logger.Warn("Failed to read code from file",
 					zap.String("file", chunk.FilePath),
@@ -469,12 +1023,10 @@ func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
 					zap.Int("end_line", chunk.EndLine),
 					zap.Error(err))
 				// Continue without code rather than failing the entire request
-			} else {
-				result.Code = code
+				continue
 			}
+			results[i].Code = code
 		}
-
-		results[i] = result
 	}
 
 	rc.logger.Info("Successfully found similar code",
@@ -501,8 +1053,334 @@ func (rc *RepoController) SearchSimilarCode(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// SearchSimilarCodeAcrossRepos searches the collections of several repositories
+// for code similar to a snippet and ranks the results across all of them. Each
+// result is labeled with the collection, i.e. the repository, it came from.
+func (rc *RepoController) SearchSimilarCodeAcrossRepos(c *gin.Context) {
+	var request model.SearchAcrossReposRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		rc.logger.Error("Invalid request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if rc.chunkService == nil {
+		rc.logger.Error("Code chunk service not available")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Code chunk service not available",
+		})
+		return
+	}
+
+	if !similarCodeLanguages[request.Language] {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Unsupported language. Supported: go, python, java, javascript, typescript",
+		})
+		return
+	}
+
+	// Chunk paths may be repo-relative; keep each repository's normalizer to read code
+	paths := make(map[string]*util.PathNormalizer, len(request.RepoNames))
+	for _, repoName := range request.RepoNames {
+		repo, err := rc.config.GetRepository(repoName)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Repository not found",
+				"details": err.Error(),
+			})
+			return
+		}
+		paths[repoName] = rc.chunkService.PathNormalizer(repo.Path)
+	}
+
+	limit := request.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rc.logger.Info("Searching for similar code across repositories",
+		zap.Strings("repo_names", request.RepoNames),
+		zap.String("language", request.Language),
+		zap.Int("limit", limit))
+
+	queryChunks, found, err := rc.chunkService.SearchAcrossCollections(
+		c.Request.Context(),
+		request.RepoNames,
+		request.CodeSnippet,
+		request.Language,
+		limit,
+	)
+	if err != nil {
+		rc.logger.Error("Failed to search for similar code across repositories",
+			zap.Strings("repo_names", request.RepoNames),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, model.SearchAcrossReposResponse{
+			RepoNames: request.RepoNames,
+			Query: model.QueryInfo{
+				CodeSnippet: request.CodeSnippet,
+				Language:    request.Language,
+			},
+			Results: []model.SimilarCodeResult{},
+			Success: false,
+			Message: fmt.Sprintf("Failed to search: %v", err),
+		})
+		return
+	}
+
+	results := make([]model.SimilarCodeResult, len(found))
+	for i, result := range found {
+		results[i] = model.SimilarCodeResult{
+			Chunk:           result.Chunk,
+			Score:           result.Score,
+			QueryChunkIndex: result.QueryChunkIndex,
+			Collection:      result.Collection,
+			QueryChunk:      matchedQueryChunk(queryChunks, result.QueryChunkIndex),
+		}
+	}
+	results = filterSimilarResults(results, request.MinScore, request.Dedup)
+
+	if request.IncludeCode {
+		files := rc.chunkService.NewFileLineCache()
+		for i := range results {
+			chunk := results[i].Chunk
+			code, err := rc.chunkService.ReadCodeFromFile(files, paths[results[i].Collection].Resolve(chunk.FilePath), chunk.StartLine, chunk.EndLine)
+			if err != nil {
+				rc.logger.Warn("Failed to read code from file",
+					zap.String("collection", results[i].Collection),
+					zap.String("file", chunk.FilePath),
+					zap.Error(err))
+				continue
+			}
+			results[i].Code = code
+		}
+	}
+
+	rc.logger.Info("Successfully found similar code across repositories",
+		zap.Strings("repo_names", request.RepoNames),
+		zap.Int("query_chunks", len(queryChunks)),
+		zap.Int("results", len(results)))
+
+	c.JSON(http.StatusOK, model.SearchAcrossReposResponse{
+		RepoNames: request.RepoNames,
+		Query: model.QueryInfo{
+			CodeSnippet: request.CodeSnippet,
+			Language:    request.Language,
+			ChunksFound: len(queryChunks),
+			Chunks:      queryChunks,
+		},
+		Results: results,
+		Success: true,
+		Message: "Search completed successfully",
+	})
+}
+
+// defaultMinDuplicateSimilarity is the score FindDuplicates groups functions at
+// when the request does not set one
+const defaultMinDuplicateSimilarity = 0.95
+
+// FindDuplicates finds near-duplicate functions, a copy-paste smell, among the
+// function chunks of a repository's collection and returns them in clusters
+func (rc *RepoController) FindDuplicates(c *gin.Context) {
+	var request model.FindDuplicatesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		rc.logger.Error("Invalid request", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if rc.chunkService == nil {
+		rc.logger.Error("Code chunk service not available")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "Code chunk service not available",
+		})
+		return
+	}
+
+	// Function code is read from the repository to embed each query
+	repo, err := rc.config.GetRepository(request.RepoName)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Repository not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	collectionName := request.CollectionName
+	if collectionName == "" {
+		collectionName = request.RepoName
+	}
+	minSimilarity := float32(defaultMinDuplicateSimilarity)
+	if request.MinSimilarity != nil {
+		minSimilarity = *request.MinSimilarity
+	}
+
+	rc.logger.Info("Finding duplicate functions",
+		zap.String("repo_name", request.RepoName),
+		zap.String("collection", collectionName),
+		zap.Float32("min_similarity", minSimilarity))
+
+	found, err := rc.chunkService.FindDuplicates(c.Request.Context(), collectionName, repo.Path, minSimilarity)
+	if err != nil {
+		rc.logger.Error("Failed to find duplicate functions",
+			zap.String("repo_name", request.RepoName),
+			zap.Error(err))
+		status := http.StatusInternalServerError
+		if errors.Is(err, vector.ErrEmbeddingModelUnavailable) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, model.FindDuplicatesResponse{
+			RepoName:       request.RepoName,
+			CollectionName: collectionName,
+			MinSimilarity:  minSimilarity,
+			Clusters:       []model.DuplicateCluster{},
+			Success:        false,
+			Message:        fmt.Sprintf("Failed to find duplicates: %v", err),
+		})
+		return
+	}
+
+	clusters := make([]model.DuplicateCluster, len(found))
+	for i, cluster := range found {
+		clusters[i] = model.DuplicateCluster{Chunks: cluster.Chunks, MaxSimilarity: cluster.MaxSimilarity}
+	}
+
+	c.JSON(http.StatusOK, model.FindDuplicatesResponse{
+		RepoName:       request.RepoName,
+		CollectionName: collectionName,
+		MinSimilarity:  minSimilarity,
+		Clusters:       clusters,
+		Success:        true,
+		Message:        fmt.Sprintf("Found %d clusters of duplicate functions", len(clusters)),
+	})
+}
+
+// matchedQueryChunk returns the snippet chunk at index for highlighting the
+// part of the snippet a result matched, or nil when index is out of range
+func matchedQueryChunk(queryChunks []*model.CodeChunk, index int) *model.MatchedQueryChunk {
+	if index < 0 || index >= len(queryChunks) {
+		return nil
+	}
+	chunk := queryChunks[index]
+	return &model.MatchedQueryChunk{
+		Content:   chunk.Content,
+		StartLine: chunk.StartLine,
+		EndLine:   chunk.EndLine,
+	}
+}
+
+// filterSimilarResults drops results scoring below minScore and, when dedup is
+// set, collapses results whose line ranges overlap in the same file of the
+// same collection into the highest scoring one. Results are returned in
+// descending score order.
+func filterSimilarResults(results []model.SimilarCodeResult, minScore float32, dedup bool) []model.SimilarCodeResult {
+	sorted := make([]model.SimilarCodeResult, 0, len(results))
+	for _, result := range results {
+		if result.Score >= minScore {
+			sorted = append(sorted, result)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	if !dedup {
+		return sorted
+	}
+
+	kept := make([]model.SimilarCodeResult, 0, len(sorted))
+	for _, result := range sorted {
+		overlaps := false
+		for _, existing := range kept {
+			if existing.Collection == result.Collection &&
+				existing.Chunk.FilePath == result.Chunk.FilePath &&
+				existing.Chunk.StartLine <= result.Chunk.EndLine &&
+				result.Chunk.StartLine <= existing.Chunk.EndLine {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
 // ProcessNGram processes a repository and builds n-gram models
 func (rc *RepoController) ProcessNGram(c *gin.Context) {
+	request, repo, n, release, ok := rc.beginNGramJob(c)
+	if !ok {
+		return
+	}
+	defer release()
+
+	response, status := rc.buildNGramModel(c.Request.Context(), request, repo, n, nil)
+	c.JSON(status, response)
+}
+
+// ProcessNGramStream is ProcessNGram streaming its progress as server-sent
+// events: "progress" events carrying a model.NGramProgressEvent while the
+// repository is walked, then a final "complete" (or "error") event carrying
+// the model.ProcessNGramResponse. Progress events are dropped rather than
+// stalling the walk when the client reads slowly.
+func (rc *RepoController) ProcessNGramStream(c *gin.Context) {
+	request, repo, n, release, ok := rc.beginNGramJob(c)
+	if !ok {
+		return
+	}
+
+	// The build is cancelled with the request when the client disconnects
+	ctx := c.Request.Context()
+	progress := make(chan model.NGramProgressEvent, 16)
+	done := make(chan model.ProcessNGramResponse, 1)
+	go func() {
+		// Released here rather than by the handler, which returns as soon as
+		// the client disconnects
+		defer release()
+		response, _ := rc.buildNGramModel(ctx, request, repo, n, func(p ngram.ProcessProgress) {
+			select {
+			case progress <- model.NGramProgressEvent{
+				FilesProcessed: p.FilesProcessed,
+				TotalFiles:     p.TotalFiles,
+				CurrentFile:    p.CurrentFile,
+				Percent:        p.Percent(),
+			}:
+			default:
+			}
+		})
+		done <- response
+	}()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event := <-progress:
+			c.SSEvent("progress", event)
+			return true
+		case response := <-done:
+			// Progress reported before the build finished goes out first
+			for len(progress) > 0 {
+				c.SSEvent("progress", <-progress)
+			}
+			if response.Success {
+				c.SSEvent("complete", response)
+			} else {
+				c.SSEvent("error", response)
+			}
+			return false
+		}
+	})
+}
+
+// beginNGramJob validates a ProcessNGram request and reserves its repository,
+// responding with the error and returning false when the job cannot start.
+// The returned func releases the reservation.
+func (rc *RepoController) beginNGramJob(c *gin.Context) (model.ProcessNGramRequest, *config.Repository, int, func(), bool) {
 	var request model.ProcessNGramRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
 		rc.logger.Error("Invalid request payload", zap.Error(err))
@@ -510,7 +1388,7 @@ func (rc *RepoController) ProcessNGram(c *gin.Context) {
 			"error":   "Invalid request payload",
 			"details": err.Error(),
 		})
-		return
+		return request, nil, 0, nil, false
 	}
 
 	// Check if n-gram service is available
@@ -519,7 +1397,7 @@ func (rc *RepoController) ProcessNGram(c *gin.Context) {
 		c.JSON(http.StatusServiceUnavailable, gin.H{
 			"error": "N-gram service not available",
 		})
-		return
+		return request, nil, 0, nil, false
 	}
 
 	// Get repository configuration
@@ -532,47 +1410,68 @@ func (rc *RepoController) ProcessNGram(c *gin.Context) {
 			"error":   "Repository not found",
 			"details": err.Error(),
 		})
-		return
+		return request, nil, 0, nil, false
 	}
 
-	// Default n to 3 (trigrams) if not specified
+	// Default n to the configured order (trigrams unless ngram.n is set)
 	n := request.N
 	if n <= 0 {
-		n = 3
+		n = rc.config.NGram.N
+	}
+	if n <= 0 {
+		n = config.DefaultNGramN
+	}
+	if err := rc.ngramService.ValidateN(n); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid n-gram order",
+			"details": err.Error(),
+		})
+		return request, nil, 0, nil, false
 	}
 
+	release, ok := rc.beginHeavyJob(c, request.RepoName, "processNGram")
+	if !ok {
+		return request, nil, 0, nil, false
+	}
+	return request, repo, n, release, true
+}
+
+// buildNGramModel processes a repository into its n-gram model, reporting
+// progress to progress if not nil, and returns the response with its HTTP
+// status
+func (rc *RepoController) buildNGramModel(ctx context.Context, request model.ProcessNGramRequest, repo *config.Repository, n int, progress ngram.ProgressFunc) (model.ProcessNGramResponse, int) {
 	rc.logger.Info("Processing repository for n-gram model",
 		zap.String("repo_name", request.RepoName),
 		zap.String("path", repo.Path),
-		zap.Int("n", n))
+		zap.Int("n", n),
+		zap.Int("min_tokens", request.MinTokens),
+		zap.String("group", request.Group))
 
 	// Process repository
-	if err := rc.ngramService.ProcessRepository(c.Request.Context(), repo, n, request.Override); err != nil {
+	if err := rc.ngramService.ProcessRepositoryWithProgress(ctx, repo, n, request.MinTokens, request.Override, request.Group, progress); err != nil {
 		rc.logger.Error("Failed to process repository for n-gram",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.ProcessNGramResponse{
+		return model.ProcessNGramResponse{
 			RepoName: request.RepoName,
 			N:        n,
 			Success:  false,
 			Message:  fmt.Sprintf("Failed to process repository: %v", err),
-		})
-		return
+		}, http.StatusInternalServerError
 	}
 
 	// Get statistics
-	stats, err := rc.ngramService.GetRepositoryStats(c.Request.Context(), request.RepoName)
+	stats, err := rc.ngramService.GetRepositoryStats(ctx, request.RepoName)
 	if err != nil {
 		rc.logger.Error("Failed to get repository stats",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
-		c.JSON(http.StatusInternalServerError, model.ProcessNGramResponse{
+		return model.ProcessNGramResponse{
 			RepoName: request.RepoName,
 			N:        n,
 			Success:  false,
 			Message:  fmt.Sprintf("Failed to get stats: %v", err),
-		})
-		return
+		}, http.StatusInternalServerError
 	}
 
 	rc.logger.Info("Successfully processed repository for n-gram",
@@ -583,6 +1482,7 @@ func (rc *RepoController) ProcessNGram(c *gin.Context) {
 
 	response := model.ProcessNGramResponse{
 		RepoName:       request.RepoName,
+		Group:          request.Group,
 		N:              n,
 		TotalFiles:     stats.TotalFiles,
 		TotalTokens:    stats.TotalTokens,
@@ -592,7 +1492,7 @@ func (rc *RepoController) ProcessNGram(c *gin.Context) {
 		Message:        "Repository processed successfully",
 	}
 
-	c.JSON(http.StatusOK, response)
+	return response, http.StatusOK
 }
 
 // GetNGramStats returns statistics for a repository's n-gram model
@@ -622,8 +1522,12 @@ func (rc *RepoController) GetNGramStats(c *gin.Context) {
 		rc.logger.Error("Failed to get repository stats",
 			zap.String("repo_name", request.RepoName),
 			zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Repository not found or not processed",
+		if errors.Is(err, ngram.ErrModelNotLoaded) {
+			respondModelNotLoaded(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get repository stats",
 			"details": err.Error(),
 		})
 		return
@@ -633,6 +1537,7 @@ func (rc *RepoController) GetNGramStats(c *gin.Context) {
 		RepoName:       request.RepoName,
 		N:              stats.GlobalModel.N,
 		TotalFiles:     stats.TotalFiles,
+		SmallFiles:     stats.SmallFiles,
 		TotalTokens:    stats.TotalTokens,
 		VocabularySize: stats.GlobalModel.VocabularySize,
 		NGramCount:     stats.GlobalModel.NGramCount,
@@ -643,6 +1548,175 @@ func (rc *RepoController) GetNGramStats(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// TopSurprisingFiles returns the repository's highest-entropy files. Unusual
+// code is where bugs tend to concentrate, so this is a triage starting point.
+func (rc *RepoController) TopSurprisingFiles(c *gin.Context) {
+	var request model.TopSurprisingFilesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		rc.logger.Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request payload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Check if n-gram service is available
+	if rc.ngramService == nil {
+		rc.logger.Error("N-gram service not available")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "N-gram service not available",
+		})
+		return
+	}
+
+	n := request.N
+	if n <= 0 {
+		n = 10
+	}
+
+	files, err := rc.ngramService.TopSurprisingFiles(c.Request.Context(), request.RepoName, n)
+	if err != nil {
+		rc.logger.Error("Failed to rank files by entropy",
+			zap.String("repo_name", request.RepoName),
+			zap.Error(err))
+		if errors.Is(err, ngram.ErrModelNotLoaded) {
+			respondModelNotLoaded(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to rank files by entropy",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response := model.TopSurprisingFilesResponse{
+		RepoName: request.RepoName,
+		Files:    make([]model.SurprisingFile, len(files)),
+	}
+	for i, file := range files {
+		response.Files[i] = model.SurprisingFile{
+			FilePath:   file.FilePath,
+			Entropy:    file.Entropy,
+			TokenCount: file.TokenCount,
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetDebugStats reports loaded n-gram models with their estimated memory use,
+// the code graph's file ID cache size and running heavy jobs
+func (rc *RepoController) GetDebugStats(c *gin.Context) {
+	rc.jobsMu.Lock()
+	inFlight := make(map[string]string, len(rc.inFlight))
+	activeJobs := make(map[string]int)
+	for repoName, kind := range rc.inFlight {
+		inFlight[repoName] = kind
+		activeJobs[kind]++
+	}
+	rc.jobsMu.Unlock()
+
+	response := model.DebugStatsResponse{
+		Repos:         []model.RepoDebugStats{},
+		ActiveJobs:    activeJobs,
+		JobSlotsInUse: len(rc.jobSlots),
+		JobSlots:      cap(rc.jobSlots),
+	}
+	if rc.codeGraph != nil {
+		response.FileIDCacheSize = rc.codeGraph.FileIDCacheSize()
+	}
+
+	if rc.ngramService != nil {
+		for _, repoName := range rc.ngramService.LoadedRepositories() {
+			cm, err := rc.ngramService.GetCorpusManager(repoName, "")
+			if err != nil {
+				continue // Unloaded since it was listed
+			}
+			stats := cm.GetStats(c.Request.Context())
+			memory := cm.GetMemoryStats()
+
+			response.Repos = append(response.Repos, model.RepoDebugStats{
+				RepoName:       repoName,
+				ActiveJob:      inFlight[repoName],
+				TotalFiles:     stats.TotalFiles,
+				SmallFiles:     stats.SmallFiles,
+				TotalTokens:    stats.TotalTokens,
+				VocabularySize: stats.GlobalModel.VocabularySize,
+				NGramCount:     stats.GlobalModel.NGramCount,
+				AverageEntropy: stats.AverageEntropy,
+				Memory: model.NGramMemoryStats{
+					VocabularyNodes: memory.VocabularyStats.TotalNodes,
+					NGramNodes:      memory.NGramStats.TotalNodes,
+					ContextNodes:    memory.ContextStats.TotalNodes,
+					TotalBytes:      memory.TotalMemoryBytes(),
+				},
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// RecomputeNGramEntropy refreshes cached per-file entropies against the current
+// global model, optionally pruning it first, and returns the updated statistics
+func (rc *RepoController) RecomputeNGramEntropy(c *gin.Context) {
+	var request model.RecomputeNGramEntropyRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		rc.logger.Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request payload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Check if n-gram service is available
+	if rc.ngramService == nil {
+		rc.logger.Error("N-gram service not available")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "N-gram service not available",
+		})
+		return
+	}
+
+	release, ok := rc.beginHeavyJob(c, request.RepoName, "recomputeNGramEntropy")
+	if !ok {
+		return
+	}
+	defer release()
+
+	stats, err := rc.ngramService.RecomputeEntropies(c.Request.Context(), request.RepoName, request.PruneMinCount)
+	if err != nil {
+		rc.logger.Error("Failed to recompute n-gram entropies",
+			zap.String("repo_name", request.RepoName),
+			zap.Error(err))
+		if errors.Is(err, ngram.ErrModelNotLoaded) {
+			respondModelNotLoaded(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to recompute entropies",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, model.RecomputeNGramEntropyResponse{
+		RepoName:       request.RepoName,
+		TotalFiles:     stats.TotalFiles,
+		TotalTokens:    stats.TotalTokens,
+		VocabularySize: stats.GlobalModel.VocabularySize,
+		NGramCount:     stats.GlobalModel.NGramCount,
+		AverageEntropy: stats.AverageEntropy,
+		EntropyStdDev:  stats.EntropyStdDev,
+		EntropyMin:     stats.EntropyMin,
+		EntropyMax:     stats.EntropyMax,
+		LanguageCounts: stats.LanguageCounts,
+	})
+}
+
 // GetFileEntropy returns the entropy for a specific file
 func (rc *RepoController) GetFileEntropy(c *gin.Context) {
 	var request model.GetFileEntropyRequest
@@ -671,6 +1745,10 @@ func (rc *RepoController) GetFileEntropy(c *gin.Context) {
 			zap.String("repo_name", request.RepoName),
 			zap.String("file_path", request.FilePath),
 			zap.Error(err))
+		if errors.Is(err, ngram.ErrModelNotLoaded) {
+			respondModelNotLoaded(c, err)
+			return
+		}
 		c.JSON(http.StatusNotFound, gin.H{
 			"error":   "File not found or not processed",
 			"details": err.Error(),
@@ -715,10 +1793,11 @@ func (rc *RepoController) AnalyzeCode(c *gin.Context) {
 		"java":       true,
 		"javascript": true,
 		"typescript": true,
+		"text":       true,
 	}
 	if !validLanguages[request.Language] {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Unsupported language. Supported: go, python, java, javascript, typescript",
+			"error": "Unsupported language. Supported: go, python, java, javascript, typescript, text",
 		})
 		return
 	}
@@ -735,6 +1814,10 @@ func (rc *RepoController) AnalyzeCode(c *gin.Context) {
 			zap.String("repo_name", request.RepoName),
 			zap.String("language", request.Language),
 			zap.Error(err))
+		if errors.Is(err, ngram.ErrModelNotLoaded) {
+			respondModelNotLoaded(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to analyze code",
 			"details": err.Error(),
@@ -781,10 +1864,11 @@ func (rc *RepoController) CalculateZScore(c *gin.Context) {
 		"java":       true,
 		"javascript": true,
 		"typescript": true,
+		"text":       true,
 	}
 	if !validLanguages[request.Language] {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Unsupported language. Supported: go, python, java, javascript, typescript",
+			"error": "Unsupported language. Supported: go, python, java, javascript, typescript, text",
 		})
 		return
 	}
@@ -801,6 +1885,10 @@ func (rc *RepoController) CalculateZScore(c *gin.Context) {
 			zap.String("repo_name", request.RepoName),
 			zap.String("language", request.Language),
 			zap.Error(err))
+		if errors.Is(err, ngram.ErrModelNotLoaded) {
+			respondModelNotLoaded(c, err)
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to calculate z-score",
 			"details": err.Error(),
@@ -837,12 +1925,258 @@ func (rc *RepoController) CalculateZScore(c *gin.Context) {
 			Level:       analysis.Interpretation.Level,
 			Description: analysis.Interpretation.Description,
 			Percentile:  analysis.Interpretation.Percentile,
+			Scale:       analysis.Interpretation.Scale,
 		},
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// AnalyzeDiff scores the lines added in a repository's working tree compared to
+// HEAD, so newly introduced unusual code can be flagged before it is committed
+func (rc *RepoController) AnalyzeDiff(c *gin.Context) {
+	var request model.AnalyzeDiffRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		rc.logger.Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request payload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Check if n-gram service is available
+	if rc.ngramService == nil {
+		rc.logger.Error("N-gram service not available")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "N-gram service not available",
+		})
+		return
+	}
+
+	repo, err := rc.config.GetRepository(request.RepoName)
+	if err != nil {
+		rc.logger.Error("Repository not found", zap.String("repo_name", request.RepoName), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Repository not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	files, err := rc.ngramService.AnalyzeDiff(c.Request.Context(), repo)
+	if err != nil {
+		rc.logger.Error("Failed to analyze diff",
+			zap.String("repo_name", request.RepoName),
+			zap.Error(err))
+		switch {
+		case errors.Is(err, ngram.ErrModelNotLoaded):
+			respondModelNotLoaded(c, err)
+		case errors.Is(err, ngram.ErrNotGitRepository):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Repository is not a git repository",
+				"details": err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to analyze diff",
+				"details": err.Error(),
+			})
+		}
+		return
+	}
+
+	response := model.AnalyzeDiffResponse{
+		RepoName: request.RepoName,
+		Files:    make([]model.DiffFileAnalysis, len(files)),
+	}
+	for i, file := range files {
+		hunks := make([]model.DiffHunkAnalysis, len(file.Hunks))
+		for j, hunk := range file.Hunks {
+			hunks[j] = model.DiffHunkAnalysis{
+				StartLine:  hunk.StartLine,
+				LineCount:  hunk.LineCount,
+				TokenCount: hunk.TokenCount,
+				Entropy:    hunk.Entropy,
+				ZScore:     hunk.ZScore,
+			}
+		}
+		response.Files[i] = model.DiffFileAnalysis{
+			FilePath:   file.FilePath,
+			Language:   file.Language,
+			TokenCount: file.TokenCount,
+			Entropy:    file.Entropy,
+			ZScore:     file.ZScore,
+			Hunks:      hunks,
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CompareRepositories reports how surprising each repository's code is under the
+// other repository's n-gram model, to detect style drift between forks
+func (rc *RepoController) CompareRepositories(c *gin.Context) {
+	var request model.CompareNGramRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		rc.logger.Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request payload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Check if n-gram service is available
+	if rc.ngramService == nil {
+		rc.logger.Error("N-gram service not available")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "N-gram service not available",
+		})
+		return
+	}
+
+	comparison, err := rc.ngramService.CompareRepositories(c.Request.Context(), request.RepoA, request.RepoB)
+	if err != nil {
+		rc.logger.Error("Failed to compare repositories",
+			zap.String("repo_a", request.RepoA),
+			zap.String("repo_b", request.RepoB),
+			zap.Error(err))
+		if errors.Is(err, ngram.ErrModelNotLoaded) {
+			respondModelNotLoaded(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to compare repositories",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response := model.CompareNGramResponse{
+		RepoA:               comparison.RepoA,
+		RepoB:               comparison.RepoB,
+		AUnderB:             toNGramDivergence(comparison.AUnderB),
+		BUnderA:             toNGramDivergence(comparison.BUnderA),
+		SymmetricDivergence: comparison.SymmetricDivergence,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// defaultAnomalyZScore is the z-score NGramAnomalies reports functions from
+// when the request does not set one
+const defaultAnomalyZScore = 2.0
+
+// NGramAnomalies scans every function the code graph holds for a repository
+// and returns those whose code is unusually surprising under the repository's
+// n-gram model, the most bug-prone candidates first
+func (rc *RepoController) NGramAnomalies(c *gin.Context) {
+	var request model.NGramAnomaliesRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		rc.logger.Error("Invalid request payload", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request payload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// Check if n-gram service is available
+	if rc.ngramService == nil {
+		rc.logger.Error("N-gram service not available")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": "N-gram service not available",
+		})
+		return
+	}
+	if rc.codeGraph == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{
+			"error":   "Code graph is not enabled",
+			"details": "enable codegraph in the configuration to scan functions for anomalies",
+		})
+		return
+	}
+
+	repo, err := rc.config.GetRepository(request.RepoName)
+	if err != nil {
+		rc.logger.Error("Repository not found", zap.String("repo_name", request.RepoName), zap.Error(err))
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Repository not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	minZScore := defaultAnomalyZScore
+	if request.MinZScore != nil {
+		minZScore = *request.MinZScore
+	}
+
+	anomalies, err := rc.ngramService.FindAnomalousFunctions(c.Request.Context(), rc.codeGraph, repo, minZScore)
+	if err != nil {
+		rc.logger.Error("Failed to scan functions for anomalies",
+			zap.String("repo_name", request.RepoName),
+			zap.Error(err))
+		if errors.Is(err, ngram.ErrModelNotLoaded) {
+			respondModelNotLoaded(c, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to scan functions for anomalies",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response := model.NGramAnomaliesResponse{
+		RepoName:  request.RepoName,
+		MinZScore: minZScore,
+		Functions: make([]model.NGramAnomaly, len(anomalies)),
+	}
+	for i, anomaly := range anomalies {
+		response.Functions[i] = model.NGramAnomaly{
+			NodeID:     int64(anomaly.NodeID),
+			Name:       anomaly.Name,
+			FilePath:   anomaly.FilePath,
+			Language:   anomaly.Language,
+			StartLine:  anomaly.StartLine,
+			EndLine:    anomaly.EndLine,
+			TokenCount: anomaly.TokenCount,
+			Entropy:    anomaly.Entropy,
+			ZScore:     anomaly.ZScore,
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// errCodeModelNotLoaded is the stable error code of responses for repositories
+// without an n-gram model, so clients need not match on the message
+const errCodeModelNotLoaded = "ngram_model_not_loaded"
+
+// respondModelNotLoaded responds with 404 for an error wrapping
+// ngram.ErrModelNotLoaded
+func respondModelNotLoaded(c *gin.Context, err error) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error":   "N-gram model not loaded; process the repository with /processNGram first",
+		"code":    errCodeModelNotLoaded,
+		"details": err.Error(),
+	})
+}
+
+func toNGramDivergence(summary ngram.DivergenceSummary) model.NGramDivergence {
+	return model.NGramDivergence{
+		SourceRepo:     summary.SourceRepo,
+		ModelRepo:      summary.ModelRepo,
+		FilesCompared:  summary.FilesCompared,
+		TokensCompared: summary.TokensCompared,
+		SelfEntropy:    summary.SelfEntropy,
+		CrossEntropy:   summary.CrossEntropy,
+		Divergence:     summary.Divergence,
+	}
+}
+
 // IndexFileRequest represents the request to index a single file
 type IndexFileRequest struct {
 	RepoName      string   `json:"repo_name" binding:"required"`
@@ -0,0 +1,839 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/ngram"
+	"bot-go/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Two corpora with distinct styles: loops and arithmetic vs. switches and channels
+var (
+	loopCorpus = map[string]string{
+		"sum.go":   "package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\ttotal += xs[i] * 2\n\t}\n\treturn total\n}\n",
+		"count.go": "package a\n\nfunc Count(xs []int) int {\n\tn := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\tn += 1\n\t}\n\treturn n\n}\n",
+		"scale.go": "package a\n\nfunc Scale(xs []float64, f float64) []float64 {\n\tout := make([]float64, len(xs))\n\tfor i := 0; i < len(xs); i++ {\n\t\tout[i] = xs[i] * f / 2.5\n\t}\n\treturn out\n}\n",
+	}
+	switchCorpus = map[string]string{
+		"kind.go": "package b\n\nfunc Kind(v interface{}) string {\n\tswitch v.(type) {\n\tcase string:\n\t\treturn \"s\"\n\tcase int:\n\t\treturn \"i\"\n\tdefault:\n\t\treturn \"?\"\n\t}\n}\n",
+		"wait.go": "package b\n\nfunc Wait(done chan struct{}, errs chan error) error {\n\tselect {\n\tcase <-done:\n\t\treturn nil\n\tcase err := <-errs:\n\t\treturn err\n\t}\n}\n",
+		"name.go": "package b\n\nfunc Name(v interface{}) string {\n\tswitch v.(type) {\n\tcase bool:\n\t\treturn \"b\"\n\tcase error:\n\t\treturn \"e\"\n\tdefault:\n\t\treturn \"?\"\n\t}\n}\n",
+	}
+)
+
+func writeCorpus(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func newTestNGramRouter(t *testing.T, repos ...*config.Repository) *gin.Engine {
+	t.Helper()
+	logger := zap.NewNop()
+	ngramService, err := ngram.NewNGramServiceWithOutputDir(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	for _, repo := range repos {
+		if err := ngramService.ProcessRepository(context.Background(), repo, 3, 0, true, ""); err != nil {
+			t.Fatalf("ProcessRepository(%s): %v", repo.Name, err)
+		}
+	}
+
+	rc := NewRepoController(nil, nil, ngramService, nil, nil, &config.Config{}, logger)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/getNGramStats", rc.GetNGramStats)
+	router.POST("/api/v1/getFileEntropy", rc.GetFileEntropy)
+	router.POST("/api/v1/recomputeNGramEntropy", rc.RecomputeNGramEntropy)
+	router.POST("/api/v1/analyzeCode", rc.AnalyzeCode)
+	router.POST("/api/v1/calculateZScore", rc.CalculateZScore)
+	router.POST("/api/v1/ngram/compare", rc.CompareRepositories)
+	router.POST("/api/v1/topSurprisingFiles", rc.TopSurprisingFiles)
+	router.GET("/api/v1/debug/stats", rc.GetDebugStats)
+	return router
+}
+
+func postJSON(router *gin.Engine, url, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCompareRepositories(t *testing.T) {
+	router := newTestNGramRouter(t,
+		&config.Repository{Name: "loops", Path: writeCorpus(t, loopCorpus)},
+		&config.Repository{Name: "switches", Path: writeCorpus(t, switchCorpus)},
+	)
+
+	w := postJSON(router, "/api/v1/ngram/compare", `{"repo_a":"loops","repo_b":"switches"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var resp model.CompareNGramResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	for _, summary := range []model.NGramDivergence{resp.AUnderB, resp.BUnderA} {
+		if summary.FilesCompared != 3 || summary.TokensCompared == 0 {
+			t.Errorf("%s under %s compared %d files / %d tokens, want 3 files",
+				summary.SourceRepo, summary.ModelRepo, summary.FilesCompared, summary.TokensCompared)
+		}
+		if summary.CrossEntropy <= summary.SelfEntropy || summary.Divergence <= 0 {
+			t.Errorf("%s under %s: cross-entropy %.3f should exceed self-entropy %.3f",
+				summary.SourceRepo, summary.ModelRepo, summary.CrossEntropy, summary.SelfEntropy)
+		}
+	}
+	if resp.AUnderB.SourceRepo != "loops" || resp.AUnderB.ModelRepo != "switches" {
+		t.Errorf("a_under_b = %s under %s, want loops under switches", resp.AUnderB.SourceRepo, resp.AUnderB.ModelRepo)
+	}
+	if resp.SymmetricDivergence <= 0 {
+		t.Errorf("symmetric divergence = %f, want > 0", resp.SymmetricDivergence)
+	}
+}
+
+func TestCompareRepositoriesModelNotLoaded(t *testing.T) {
+	router := newTestNGramRouter(t, &config.Repository{Name: "loops", Path: writeCorpus(t, loopCorpus)})
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"unloaded second repo", `{"repo_a":"loops","repo_b":"missing"}`, http.StatusNotFound},
+		{"unloaded first repo", `{"repo_a":"missing","repo_b":"loops"}`, http.StatusNotFound},
+		{"missing repo name", `{"repo_a":"loops"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(router, "/api/v1/ngram/compare", tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want == http.StatusNotFound && !strings.Contains(w.Body.String(), "missing") {
+				t.Errorf("error does not name the unloaded repository: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestNGramEndpointsModelNotLoaded(t *testing.T) {
+	router := newTestNGramRouter(t, &config.Repository{Name: "loops", Path: writeCorpus(t, loopCorpus)})
+
+	code := `"code":"package a\n\nfunc F() {}\n"`
+	tests := []struct {
+		url  string
+		body string
+	}{
+		{"/api/v1/getNGramStats", `{"repo_name":"missing"}`},
+		{"/api/v1/getFileEntropy", `{"repo_name":"missing","file_path":"sum.go"}`},
+		{"/api/v1/recomputeNGramEntropy", `{"repo_name":"missing"}`},
+		{"/api/v1/analyzeCode", `{"repo_name":"missing","language":"go",` + code + `}`},
+		{"/api/v1/calculateZScore", `{"repo_name":"missing","language":"go",` + code + `}`},
+		{"/api/v1/topSurprisingFiles", `{"repo_name":"missing"}`},
+		{"/api/v1/ngram/compare", `{"repo_a":"loops","repo_b":"missing"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			w := postJSON(router, tt.url, tt.body)
+			if w.Code != http.StatusNotFound {
+				t.Fatalf("status = %d, want %d, body = %s", w.Code, http.StatusNotFound, w.Body.String())
+			}
+			var resp map[string]string
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp["code"] != errCodeModelNotLoaded {
+				t.Errorf("code = %q, want %q", resp["code"], errCodeModelNotLoaded)
+			}
+		})
+	}
+
+	// A loaded repository keeps its own 404 for files outside the corpus
+	w := postJSON(router, "/api/v1/getFileEntropy", `{"repo_name":"loops","file_path":"absent.go"}`)
+	if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), errCodeModelNotLoaded) {
+		t.Errorf("unknown file: status = %d, body = %s", w.Code, w.Body.String())
+	}
+}
+
+func TestProcessNGramValidatesN(t *testing.T) {
+	logger := zap.NewNop()
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{{Name: "loops", Path: writeCorpus(t, loopCorpus)}}}}
+	ngramService, err := ngram.NewNGramServiceWithOutputDir(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	rc := NewRepoController(service.NewRepoService(cfg, logger), nil, ngramService, nil, nil, cfg, logger)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/processNGram", rc.ProcessNGram)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		wantN  int
+	}{
+		{"unset defaults to trigrams", `{"repo_name":"loops","override":true}`, http.StatusOK, 3},
+		{"within range", `{"repo_name":"loops","n":5,"override":true}`, http.StatusOK, 5},
+		{"too large", `{"repo_name":"loops","n":100,"override":true}`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postJSON(router, "/api/v1/processNGram", tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				if !strings.Contains(w.Body.String(), "must be between 1 and 7") {
+					t.Errorf("error does not state the valid range: %s", w.Body.String())
+				}
+				return
+			}
+			var response model.ProcessNGramResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if response.N != tt.wantN {
+				t.Errorf("n = %d, want %d", response.N, tt.wantN)
+			}
+		})
+	}
+}
+
+func TestProcessNGramStreamReportsProgress(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 250; i++ {
+		files[fmt.Sprintf("f%03d.go", i)] = fmt.Sprintf("package a\n\nfunc F%d(x int) int {\n\treturn x + %d\n}\n", i, i)
+	}
+	dir := writeCorpus(t, files)
+
+	logger := zap.NewNop()
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{{Name: "many", Path: dir}}}}
+	ngramService, err := ngram.NewNGramServiceWithOutputDir(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	rc := NewRepoController(service.NewRepoService(cfg, logger), nil, ngramService, nil, nil, cfg, logger)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/processNGram/stream", rc.ProcessNGramStream)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/api/v1/processNGram/stream", "application/json", strings.NewReader(`{"repo_name":"many","override":true}`))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		t.Fatalf("Content-Type = %q, want text/event-stream", contentType)
+	}
+
+	type event struct{ name, data string }
+	var events []event
+	var current event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			current.name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			current.data = strings.TrimPrefix(line, "data:")
+		case line == "" && current.name != "":
+			events = append(events, current)
+			current = event{}
+		}
+	}
+	if len(events) < 2 {
+		t.Fatalf("received events %v, want progress then complete", events)
+	}
+
+	last := events[len(events)-1]
+	if last.name != "complete" {
+		t.Fatalf("last event = %q (%s), want complete", last.name, last.data)
+	}
+	var final model.ProcessNGramResponse
+	if err := json.Unmarshal([]byte(last.data), &final); err != nil {
+		t.Fatalf("decode complete event %q: %v", last.data, err)
+	}
+	if !final.Success || final.TotalFiles != 250 {
+		t.Errorf("final stats = %+v, want 250 files processed successfully", final)
+	}
+
+	walked := false
+	for _, e := range events[:len(events)-1] {
+		if e.name != "progress" {
+			t.Fatalf("event %q before the final event, want only progress", e.name)
+		}
+		var progress model.NGramProgressEvent
+		if err := json.Unmarshal([]byte(e.data), &progress); err != nil {
+			t.Fatalf("decode progress event %q: %v", e.data, err)
+		}
+		if progress.TotalFiles != 250 {
+			t.Errorf("progress total = %d, want 250", progress.TotalFiles)
+		}
+		if progress.FilesProcessed > 0 {
+			walked = true
+			if progress.CurrentFile == "" || progress.Percent <= 0 {
+				t.Errorf("progress = %+v, want the current file and a percentage", progress)
+			}
+		}
+	}
+	if !walked {
+		t.Errorf("no progress event reported processed files: %v", events)
+	}
+}
+
+func TestProcessRepo(t *testing.T) {
+	logger := zap.NewNop()
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{{Name: "demo", Path: t.TempDir()}}}}
+
+	t.Run("disabled without file tracking", func(t *testing.T) {
+		rc := NewRepoController(nil, nil, nil, nil, nil, cfg, logger)
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/api/v1/processRepo", rc.ProcessRepo)
+
+		w := postJSON(router, "/api/v1/processRepo", `{"repo_name":"demo"}`)
+		if w.Code != http.StatusNotImplemented {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusNotImplemented, w.Body.String())
+		}
+	})
+
+	t.Run("runs in the background", func(t *testing.T) {
+		rc := NewRepoController(nil, nil, nil, nil, nil, cfg, logger)
+		release := make(chan struct{})
+		rc.indexRepository = func(ctx context.Context, repo *config.Repository, useHead bool) (*model.ProcessingSummary, error) {
+			<-release
+			return &model.ProcessingSummary{
+				FilesAttempted: 2,
+				FilesSucceeded: 1,
+				FilesFailed:    1,
+				Failures:       []model.FileFailure{{Path: "bad.go", Reason: "permission denied"}},
+			}, nil
+		}
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/api/v1/processRepo", rc.ProcessRepo)
+		router.GET("/api/v1/processRepo/:jobId", rc.GetProcessRepoJob)
+
+		w := postJSON(router, "/api/v1/processRepo", `{"repo_name":"demo"}`)
+		if w.Code != http.StatusAccepted {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusAccepted, w.Body.String())
+		}
+		var started model.ProcessRepoResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &started); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if started.JobID == "" || started.Status != jobStatusRunning {
+			t.Fatalf("unexpected job: %+v", started)
+		}
+
+		if w := postJSON(router, "/api/v1/processRepo", `{"repo_name":"demo"}`); w.Code != http.StatusConflict {
+			t.Errorf("second run status = %d, want %d", w.Code, http.StatusConflict)
+		}
+
+		close(release)
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/processRepo/"+started.JobID, nil))
+			var job model.ProcessRepoResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+				t.Fatalf("decode job: %v", err)
+			}
+			if job.Status == jobStatusCompleted {
+				if job.FinishedAt == nil {
+					t.Error("completed job has no finish time")
+				}
+				if job.Summary == nil || job.Summary.FilesFailed != 1 || len(job.Summary.Failures) != 1 || job.Summary.Failures[0].Path != "bad.go" {
+					t.Errorf("unexpected summary: %+v", job.Summary)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job did not complete: %+v", job)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestProcessDirectoryIdempotencyKey(t *testing.T) {
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{{Name: "demo", Path: t.TempDir()}}}}
+	rc := NewRepoController(nil, nil, nil, nil, nil, cfg, zap.NewNop())
+	runs := 0
+	rc.chunkDirectory = func(ctx context.Context, repo *config.Repository, collectionName string, incremental bool) (int, error) {
+		runs++
+		if runs > 1 {
+			return 0, fmt.Errorf("processed %d times", runs)
+		}
+		return 42, nil
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/processDirectory", rc.ProcessDirectory)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/processDirectory", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", key)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := post("retry-1", `{"repo_name":"demo"}`)
+	if first.Code != http.StatusOK {
+		t.Fatalf("first status = %d: %s", first.Code, first.Body.String())
+	}
+	second := post("retry-1", `{"repo_name":"demo"}`)
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("retried response = %d %s, want %d %s", second.Code, second.Body.String(), first.Code, first.Body.String())
+	}
+	if runs != 1 {
+		t.Errorf("directory processed %d times, want 1", runs)
+	}
+
+	if w := post("retry-1", `{"repo_name":"demo","collection_name":"other"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key for a different request: status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if w := post("retry-2", `{"repo_name":"demo"}`); w.Code != http.StatusInternalServerError || runs != 2 {
+		t.Errorf("new key: status = %d after %d runs, want it processed again", w.Code, runs)
+	}
+}
+
+func TestGetFunctionsInFile(t *testing.T) {
+	logger := zap.NewNop()
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{{Name: "demo", Path: "/repo"}}}}
+
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		switch {
+		case strings.Contains(query, "MATCH (n:FileScope)"):
+			if params["path"] != "pkg/service.go" {
+				return nil, nil
+			}
+			return []map[string]any{{"n": map[string]any{
+				"id": int64(1), "nodeType": int64(ast.NodeTypeFileScope), "fileId": int64(1), "name": "service.go",
+			}}}, nil
+		case strings.Contains(query, "(f:Function)"):
+			return []map[string]any{
+				{"f": map[string]any{"id": int64(12), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(1), "name": "Stop", "range": "(12,0)-(14,1)"}},
+				{"f": map[string]any{"id": int64(11), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(1), "name": "Run", "range": "(5,0)-(9,1)"}},
+			}, nil
+		case strings.Contains(query, "FUNCTION_ARG") && params["functionId"] == int64(11):
+			return []map[string]any{
+				{"arg": map[string]any{"id": int64(20), "nodeType": int64(ast.NodeTypeVariable), "fileId": int64(1), "name": "ctx"}},
+				{"arg": map[string]any{"id": int64(21), "nodeType": int64(ast.NodeTypeVariable), "fileId": int64(1), "name": "opts"}},
+			}, nil
+		}
+		return nil, nil
+	}
+
+	newRouter := func(graph *codegraph.CodeGraph) *gin.Engine {
+		rc := NewRepoController(nil, nil, nil, nil, nil, cfg, logger)
+		if graph != nil {
+			rc.SetCodeGraph(graph)
+		}
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.POST("/api/v1/getFunctionsInFile", rc.GetFunctionsInFile)
+		return router
+	}
+
+	t.Run("disabled without code graph", func(t *testing.T) {
+		w := postJSON(newRouter(nil), "/api/v1/getFunctionsInFile", `{"repo_name":"demo","relative_path":"pkg/service.go"}`)
+		if w.Code != http.StatusNotImplemented {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusNotImplemented, w.Body.String())
+		}
+	})
+
+	router := newRouter(codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, logger))
+
+	t.Run("lists functions in order", func(t *testing.T) {
+		w := postJSON(router, "/api/v1/getFunctionsInFile", `{"repo_name":"demo","relative_path":"/repo/pkg/service.go"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+		var response model.GetFunctionsInFileResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if response.FilePath != "pkg/service.go" || len(response.Functions) != 2 {
+			t.Fatalf("unexpected response: %+v", response)
+		}
+		run := response.Functions[0]
+		if run.Name != "Run" || run.Signature != "Run(ctx, opts)" || run.Location.Range.Start.Line != 5 {
+			t.Errorf("first function = %+v, want Run(ctx, opts) at line 5", run)
+		}
+		if stop := response.Functions[1]; stop.Signature != "Stop()" {
+			t.Errorf("second function signature = %q, want Stop()", stop.Signature)
+		}
+	})
+
+	t.Run("unknown file", func(t *testing.T) {
+		w := postJSON(router, "/api/v1/getFunctionsInFile", `{"repo_name":"demo","relative_path":"pkg/missing.go"}`)
+		if w.Code != http.StatusNotFound {
+			t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusNotFound, w.Body.String())
+		}
+	})
+}
+
+func TestGetFileCallEdges(t *testing.T) {
+	logger := zap.NewNop()
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{{Name: "demo", Path: "/repo"}}}}
+
+	function := func(id int64, fileID int64, name, rng string) map[string]any {
+		return map[string]any{"id": id, "nodeType": int64(ast.NodeTypeFunction), "fileId": fileID, "name": name, "range": rng}
+	}
+	call := func(id int64, name, rng string) map[string]any {
+		return map[string]any{"id": id, "nodeType": int64(ast.NodeTypeFunctionCall), "fileId": int64(1), "name": name, "range": rng}
+	}
+	// Run (11) calls helper and holds a closure (13) that calls helper again;
+	// helper (12) calls Run, an unresolved fmt.Println and Load from another file
+	functions := map[int64]map[string]any{
+		11: function(11, 1, "Run", "(5,0)-(15,1)"),
+		12: function(12, 1, "helper", "(17,0)-(22,1)"),
+		13: function(13, 1, "func1", "(8,1)-(10,2)"),
+		31: function(31, 2, "Load", "(3,0)-(6,1)"),
+	}
+	callees := map[int64]int64{21: 12, 22: 12, 24: 11, 25: 31}
+
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		switch {
+		case strings.Contains(query, "MATCH (n:FileScope)"):
+			switch {
+			case params["path"] == "pkg/service.go":
+				return []map[string]any{{"n": map[string]any{
+					"id": int64(1), "nodeType": int64(ast.NodeTypeFileScope), "fileId": int64(1), "name": "service.go",
+				}}}, nil
+			case params["id"] == int64(2):
+				return []map[string]any{{"n": map[string]any{
+					"id": int64(2), "nodeType": int64(ast.NodeTypeFileScope), "fileId": int64(2), "name": "store.go",
+					"md_repo": "demo", "md_path": "pkg/store.go",
+				}}}, nil
+			}
+			return nil, nil
+		case strings.Contains(query, "MATCH (fc:FunctionCall)"):
+			return []map[string]any{
+				{"fc": call(23, "fmt.Println", "(18,1)-(18,20)"), "functionId": int64(12)},
+				{"fc": call(21, "helper", "(6,1)-(6,9)"), "functionId": int64(11)},
+				{"fc": call(22, "helper", "(9,2)-(9,10)"), "functionId": int64(11)},
+				{"fc": call(22, "helper", "(9,2)-(9,10)"), "functionId": int64(13)},
+				{"fc": call(24, "Run", "(19,1)-(19,6)"), "functionId": int64(12)},
+				{"fc": call(25, "Load", "(20,1)-(20,7)"), "functionId": int64(12)},
+			}, nil
+		case strings.Contains(query, "(fs:FileScope {id: $fileScopeId})"):
+			return []map[string]any{{"f": functions[11]}, {"f": functions[12]}, {"f": functions[13]}}, nil
+		case strings.Contains(query, "CALLS_FUNCTION"):
+			if callee, ok := callees[params["fromId"].(int64)]; ok {
+				return []map[string]any{{"toId": callee}}, nil
+			}
+			return nil, nil
+		case strings.Contains(query, "MATCH (n {id: $id})"):
+			if fn, ok := functions[params["id"].(int64)]; ok {
+				return []map[string]any{{"n": fn}}, nil
+			}
+		}
+		return nil, nil
+	}
+
+	rc := NewRepoController(nil, nil, nil, nil, nil, cfg, logger)
+	rc.SetCodeGraph(codegraph.NewCodeGraphWithDatabase(db, cfg, logger))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/getFileCallEdges", rc.GetFileCallEdges)
+
+	w := postJSON(router, "/api/v1/getFileCallEdges", `{"repo_name":"demo","file_path":"pkg/service.go"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var response model.GetFileCallEdgesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	var got []string
+	for _, edge := range response.Edges {
+		got = append(got, fmt.Sprintf("%s->%s@%d (%s)", edge.Caller.Name, edge.Callee.Name, edge.CallRange.Start.Line, edge.Callee.Location.URI))
+	}
+	want := []string{
+		"Run->helper@6 (pkg/service.go)",
+		"func1->helper@9 (pkg/service.go)",
+		"helper->Run@19 (pkg/service.go)",
+		"helper->Load@20 (pkg/store.go)",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("edges = %v, want %v", got, want)
+	}
+	if len(response.Unresolved) != 1 || response.Unresolved[0].Name != "fmt.Println" || response.Unresolved[0].Caller.Name != "helper" {
+		t.Errorf("unresolved = %+v, want fmt.Println called by helper", response.Unresolved)
+	}
+
+	if w := postJSON(router, "/api/v1/getFileCallEdges", `{"repo_name":"demo","file_path":"pkg/missing.go"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown file status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestSearchSimilarCodeDedupAndMinScore(t *testing.T) {
+	vectorDB := &stubVectorDB{
+		chunks: []*model.CodeChunk{
+			{ID: "a1", FilePath: "pkg/a.go", StartLine: 10, EndLine: 20},
+			{ID: "a2", FilePath: "pkg/a.go", StartLine: 15, EndLine: 25},
+			{ID: "a3", FilePath: "pkg/a.go", StartLine: 18, EndLine: 30},
+			{ID: "a4", FilePath: "pkg/a.go", StartLine: 40, EndLine: 50},
+			{ID: "b1", FilePath: "pkg/b.go", StartLine: 1, EndLine: 5},
+		},
+		scores: []float32{0.7, 0.9, 0.8, 0.6, 0.4},
+	}
+	rc := NewRepoController(nil, newResolverChunkService(vectorDB), nil, nil, nil, &config.Config{}, zap.NewNop())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/searchSimilarCode", rc.SearchSimilarCode)
+
+	tests := []struct {
+		name      string
+		options   string
+		wantIDs   []string
+		wantFirst float32
+	}{
+		{"no filtering", ``, []string{"a2", "a3", "a1", "a4", "b1"}, 0.9},
+		{"min score", `,"min_score":0.65`, []string{"a2", "a3", "a1"}, 0.9},
+		{"dedup overlapping ranges", `,"dedup":true,"min_score":0.5`, []string{"a2", "a4"}, 0.9},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"repo_name":"demo","language":"go","code_snippet":"package p\n\nfunc f() int {\n\treturn 1\n}\n"` + tt.options + `}`
+			w := postJSON(router, "/api/v1/searchSimilarCode", body)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+			}
+			var response model.SearchSimilarCodeResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+
+			var gotIDs []string
+			for _, result := range response.Results {
+				gotIDs = append(gotIDs, result.Chunk.ID)
+			}
+			if strings.Join(gotIDs, ",") != strings.Join(tt.wantIDs, ",") {
+				t.Fatalf("results = %v, want %v", gotIDs, tt.wantIDs)
+			}
+			if response.Results[0].Score != tt.wantFirst {
+				t.Errorf("top score = %v, want %v", response.Results[0].Score, tt.wantFirst)
+			}
+		})
+	}
+}
+
+// perQueryVectorDB is a stubVectorDB answering the n-th search with the n-th
+// response, so results can be told apart by the query chunk that found them
+type perQueryVectorDB struct {
+	*stubVectorDB
+	responses [][]*model.CodeChunk
+	calls     int
+}
+
+func (p *perQueryVectorDB) SearchSimilar(ctx context.Context, collectionName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	call := p.calls
+	p.calls++
+	if call >= len(p.responses) {
+		return nil, nil, nil
+	}
+	scores := make([]float32, len(p.responses[call]))
+	for i := range scores {
+		scores[i] = 0.9 - 0.1*float32(call)
+	}
+	return p.responses[call], scores, nil
+}
+
+func (p *perQueryVectorDB) SearchSimilarNamed(ctx context.Context, collectionName, vectorName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	return p.SearchSimilar(ctx, collectionName, queryVector, limit, filter)
+}
+
+func TestSearchSimilarCodeReturnsMatchedQueryChunk(t *testing.T) {
+	vectorDB := &perQueryVectorDB{stubVectorDB: &stubVectorDB{}}
+	for i := 0; i < 4; i++ {
+		vectorDB.responses = append(vectorDB.responses, []*model.CodeChunk{
+			{ID: fmt.Sprintf("r%d", i), FilePath: fmt.Sprintf("pkg/r%d.go", i), StartLine: 1, EndLine: 5},
+		})
+	}
+	rc := NewRepoController(nil, newResolverChunkService(vectorDB), nil, nil, nil, &config.Config{}, zap.NewNop())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/searchSimilarCode", rc.SearchSimilarCode)
+
+	body := `{"repo_name":"demo","language":"go","code_snippet":"package p\n\nfunc f() int {\n\treturn 1\n}\n\nfunc g() string {\n\treturn \"g\"\n}\n"}`
+	w := postJSON(router, "/api/v1/searchSimilarCode", body)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var response model.SearchSimilarCodeResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	indices := make(map[int]bool)
+	for _, result := range response.Results {
+		index := result.QueryChunkIndex
+		indices[index] = true
+		if index < 0 || index >= len(response.Query.Chunks) {
+			t.Fatalf("%s: query chunk index %d out of range of %d chunks", result.Chunk.ID, index, len(response.Query.Chunks))
+		}
+		queryChunk := response.Query.Chunks[index]
+		want := &model.MatchedQueryChunk{Content: queryChunk.Content, StartLine: queryChunk.StartLine, EndLine: queryChunk.EndLine}
+		if !reflect.DeepEqual(result.QueryChunk, want) {
+			t.Errorf("%s: query chunk = %+v, want chunk %d %+v", result.Chunk.ID, result.QueryChunk, index, want)
+		}
+	}
+	if len(indices) < 2 {
+		t.Errorf("results matched query chunks %v, want several", indices)
+	}
+}
+
+func TestSearchSimilarCodeAcrossRepos(t *testing.T) {
+	// Every collection returns the same chunks, so the same path appears in both repositories
+	vectorDB := &stubVectorDB{
+		chunks: []*model.CodeChunk{
+			{ID: "a1", FilePath: "pkg/a.go", StartLine: 10, EndLine: 20},
+			{ID: "b1", FilePath: "pkg/b.go", StartLine: 1, EndLine: 5},
+		},
+		scores: []float32{0.9, 0.5},
+	}
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{
+		{Name: "demo", Path: "/repos/demo"},
+		{Name: "other", Path: "/repos/other"},
+	}}}
+	rc := NewRepoController(nil, newResolverChunkService(vectorDB), nil, nil, nil, cfg, zap.NewNop())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/searchSimilarCodeAcrossRepos", rc.SearchSimilarCodeAcrossRepos)
+
+	snippet := `"code_snippet":"package p\n\nfunc f() int {\n\treturn 1\n}\n","language":"go"`
+	w := postJSON(router, "/api/v1/searchSimilarCodeAcrossRepos", `{"repo_names":["demo","other"],"dedup":true,`+snippet+`}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var response model.SearchAcrossReposResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	// Dedup only collapses overlapping ranges within one repository
+	var got []string
+	for _, result := range response.Results {
+		got = append(got, result.Collection+"/"+result.Chunk.ID)
+	}
+	want := "demo/a1,other/a1,demo/b1,other/b1"
+	if strings.Join(got, ",") != want {
+		t.Errorf("results = %v, want %s", got, want)
+	}
+
+	w = postJSON(router, "/api/v1/searchSimilarCodeAcrossRepos", `{"repo_names":["demo","missing"],`+snippet+`}`)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown repository status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestTopSurprisingFiles(t *testing.T) {
+	// Dense, irregular code next to the repetitive loop corpus
+	files := map[string]string{
+		"odd.go": "package a\n\nfunc Odd(p *[4]uint8, m map[rune]chan<- func() error) (r interface{}, ok bool) {\n\tdefer recover()\n\tgoto L\nL:\n\tif v, k := m['x']; k && p != nil || !ok {\n\t\tgo func() { v <- nil }()\n\t}\n\tr, ok = <-make(chan []byte, 0x1F), len(p[1:3:4]) >= 2 && ^p[0]&^3 == 0\n\treturn\n}\n",
+	}
+	for name, content := range loopCorpus {
+		files[name] = content
+	}
+	router := newTestNGramRouter(t, &config.Repository{Name: "mixed", Path: writeCorpus(t, files)})
+
+	w := postJSON(router, "/api/v1/topSurprisingFiles", `{"repo_name":"mixed","n":2}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var resp model.TopSurprisingFilesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Files) != 2 {
+		t.Fatalf("got %d files, want 2", len(resp.Files))
+	}
+	if filepath.Base(resp.Files[0].FilePath) != "odd.go" {
+		t.Errorf("most surprising file = %s, want odd.go", resp.Files[0].FilePath)
+	}
+	if resp.Files[0].Entropy < resp.Files[1].Entropy {
+		t.Errorf("files not sorted by descending entropy: %+v", resp.Files)
+	}
+	if resp.Files[0].TokenCount == 0 {
+		t.Error("token count missing from ranked file")
+	}
+
+	if w := postJSON(router, "/api/v1/topSurprisingFiles", `{"repo_name":"missing"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unloaded repo status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestGetDebugStats(t *testing.T) {
+	router := newTestNGramRouter(t, &config.Repository{Name: "loops", Path: writeCorpus(t, loopCorpus)})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/debug/stats", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var resp model.DebugStatsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Repos) != 1 || resp.Repos[0].RepoName != "loops" {
+		t.Fatalf("repos = %+v, want only loops", resp.Repos)
+	}
+
+	repo := resp.Repos[0]
+	if repo.TotalFiles != len(loopCorpus) || repo.TotalTokens == 0 {
+		t.Errorf("corpus stats = %d files / %d tokens, want %d files", repo.TotalFiles, repo.TotalTokens, len(loopCorpus))
+	}
+	if repo.Memory.NGramNodes == 0 || repo.Memory.TotalBytes == 0 {
+		t.Errorf("memory stats not reported: %+v", repo.Memory)
+	}
+	if len(resp.ActiveJobs) != 0 || resp.JobSlotsInUse != 0 {
+		t.Errorf("active jobs = %v (%d slots in use), want none", resp.ActiveJobs, resp.JobSlotsInUse)
+	}
+}
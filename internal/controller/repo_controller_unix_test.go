@@ -0,0 +1,80 @@
+//go:build unix
+
+package controller
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"bot-go/internal/config"
+	"bot-go/internal/service"
+	"bot-go/internal/service/ngram"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+func TestProcessNGramRejectsConcurrentRunForSameRepo(t *testing.T) {
+	// The walk blocks reading the FIFO, holding the first job open until the
+	// test writes to it
+	dir := writeCorpus(t, loopCorpus)
+	fifo := filepath.Join(dir, "blocked.go")
+	if err := syscall.Mkfifo(fifo, 0644); err != nil {
+		t.Skipf("mkfifo not supported: %v", err)
+	}
+
+	logger := zap.NewNop()
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{{Name: "loops", Path: dir}}}}
+	ngramService, err := ngram.NewNGramServiceWithOutputDir(t.TempDir(), logger)
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	rc := NewRepoController(service.NewRepoService(cfg, logger), nil, ngramService, nil, nil, cfg, logger)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/v1/processNGram", rc.ProcessNGram)
+
+	results := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			results <- postJSON(router, "/api/v1/processNGram", `{"repo_name":"loops","override":true}`).Code
+		}()
+	}
+
+	// Whichever request lost the race is rejected while the winner is blocked
+	select {
+	case code := <-results:
+		if code != http.StatusConflict {
+			t.Fatalf("first response = %d, want %d", code, http.StatusConflict)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request was rejected while a job was in progress")
+	}
+
+	writer, err := os.OpenFile(fifo, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open fifo: %v", err)
+	}
+	writer.WriteString("package a\n\nfunc Blocked() {}\n")
+	writer.Close()
+
+	select {
+	case code := <-results:
+		if code != http.StatusOK {
+			t.Fatalf("running job response = %d, want %d", code, http.StatusOK)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("running job did not finish")
+	}
+
+	rc.jobsMu.Lock()
+	defer rc.jobsMu.Unlock()
+	if len(rc.inFlight) != 0 || len(rc.jobSlots) != 0 {
+		t.Errorf("job was not released: in flight %v, slots used %d", rc.inFlight, len(rc.jobSlots))
+	}
+}
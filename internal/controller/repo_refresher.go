@@ -0,0 +1,179 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/db"
+	"bot-go/internal/util"
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// errRepositoryBusy is returned by reprocess when the repository already has a
+// job in progress
+var errRepositoryBusy = errors.New("repository has a job in progress")
+
+// RepoRefresher keeps the index of enabled repositories current by
+// reprocessing a repository whenever its git HEAD (or configured ref) differs
+// from the commit its last completed build processed, as recorded in MySQL by
+// the IndexBuilder. Reprocessing takes the same per-repository reservation and
+// global job slot as processRepo, so it never overlaps an API-triggered job.
+type RepoRefresher struct {
+	rc       *RepoController
+	interval time.Duration
+	logger   *zap.Logger
+
+	// Test hooks: newTicker returns the tick channel and its stop func,
+	// headSHA reads the commit a repository's ref points to and
+	// processedCommit reads the commit its index was last built from
+	newTicker       func(time.Duration) (<-chan time.Time, func())
+	headSHA         func(repoPath, ref string) (string, error)
+	processedCommit func(repo *config.Repository) (string, error)
+
+	fileVersionRepos map[string]*db.FileVersionRepository // By repo name, opened on first check
+}
+
+// NewRepoRefresher creates a refresher that checks repositories every interval
+func NewRepoRefresher(rc *RepoController, interval time.Duration, logger *zap.Logger) *RepoRefresher {
+	r := &RepoRefresher{
+		rc:               rc,
+		interval:         interval,
+		logger:           logger,
+		newTicker:        newTimeTicker,
+		headSHA:          gitHeadSHA,
+		fileVersionRepos: make(map[string]*db.FileVersionRepository),
+	}
+	r.processedCommit = r.readProcessedCommit
+	return r
+}
+
+// readProcessedCommit reads a repository's processed commit from MySQL
+func (r *RepoRefresher) readProcessedCommit(repo *config.Repository) (string, error) {
+	fileVersionRepo, ok := r.fileVersionRepos[repo.Name]
+	if !ok {
+		var err error
+		fileVersionRepo, err = db.NewFileVersionRepository(r.rc.mysqlConn.GetDB(), repo.Name, r.logger)
+		if err != nil {
+			return "", err
+		}
+		r.fileVersionRepos[repo.Name] = fileVersionRepo
+	}
+	return fileVersionRepo.GetProcessedCommit()
+}
+
+func newTimeTicker(interval time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+func gitHeadSHA(repoPath, ref string) (string, error) {
+	gitInfo, err := util.GetGitInfo(repoPath, ref)
+	if err != nil {
+		return "", err
+	}
+	if !gitInfo.IsGitRepo {
+		return "", nil
+	}
+	return gitInfo.HeadCommitSHA, nil
+}
+
+// Run checks the repositories on every tick until ctx is cancelled, starting
+// with an immediate check. Repositories with no recorded build are processed
+// on that first check.
+func (r *RepoRefresher) Run(ctx context.Context) {
+	if r.rc.indexRepository == nil {
+		r.logger.Warn("Repository refresh disabled: MySQL file tracking is required to process repositories")
+		return
+	}
+
+	r.logger.Info("Starting repository refresh", zap.Duration("interval", r.interval))
+	r.checkRepositories(ctx)
+
+	ticks, stop := r.newTicker(r.interval)
+	defer stop()
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info("Stopped repository refresh")
+			return
+		case <-ticks:
+			r.checkRepositories(ctx)
+		}
+	}
+}
+
+// checkRepositories reprocesses each enabled repository whose HEAD is not the
+// commit it was last processed at
+func (r *RepoRefresher) checkRepositories(ctx context.Context) {
+	for i := range r.rc.config.Source.Repositories {
+		repo := &r.rc.config.Source.Repositories[i]
+		if repo.Disabled {
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		sha, err := r.headSHA(repo.Path, repo.Ref)
+		if err != nil {
+			r.logger.Warn("Failed to read repository HEAD",
+				zap.String("repo_name", repo.Name),
+				zap.Error(err))
+			continue
+		}
+		if sha == "" {
+			continue // Not a git repository
+		}
+
+		last, err := r.processedCommit(repo)
+		if err != nil {
+			r.logger.Warn("Failed to read processed commit",
+				zap.String("repo_name", repo.Name),
+				zap.Error(err))
+			continue
+		}
+		if sha == last {
+			continue
+		}
+
+		if err := r.reprocess(ctx, repo); err != nil {
+			if errors.Is(err, errRepositoryBusy) {
+				r.logger.Info("Repository busy, deferring refresh to the next check",
+					zap.String("repo_name", repo.Name))
+				continue
+			}
+			r.logger.Error("Failed to reprocess repository",
+				zap.String("repo_name", repo.Name),
+				zap.String("head", sha),
+				zap.Error(err))
+			continue
+		}
+		r.logger.Info("Reprocessed repository after HEAD changed",
+			zap.String("repo_name", repo.Name),
+			zap.String("previous_head", last),
+			zap.String("head", sha))
+	}
+}
+
+// reprocess runs the incremental index build for a repository once it is free
+// and a job slot is available. A repository with a job in progress is left
+// for the next tick.
+func (r *RepoRefresher) reprocess(ctx context.Context, repo *config.Repository) error {
+	finish, _, ok := r.rc.tryReserveRepository(repo.Name, "refresh")
+	if !ok {
+		return errRepositoryBusy
+	}
+	defer finish()
+
+	select {
+	case r.rc.jobSlots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-r.rc.jobSlots }()
+
+	_, err := r.rc.indexRepository(ctx, repo, false)
+	return err
+}
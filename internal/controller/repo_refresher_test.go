@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRepoRefresherReprocessesOncePerHeadChange(t *testing.T) {
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{
+		{Name: "demo", Path: "/repos/demo"},
+		{Name: "off", Path: "/repos/off", Disabled: true},
+	}}}
+	rc := NewRepoController(nil, nil, nil, nil, nil, cfg, zap.NewNop())
+
+	var mu sync.Mutex
+	head := "aaa"
+	recorded := map[string]string{"demo": "aaa"} // Processed before the server started
+	var processed []string                       // HEAD at each reprocess
+	rc.indexRepository = func(ctx context.Context, repo *config.Repository, useHead bool) (*model.ProcessingSummary, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if repo.Name != "demo" {
+			t.Errorf("reprocessed %s, want only demo", repo.Name)
+		}
+		if len(rc.jobSlots) != 1 {
+			t.Errorf("job slots in use = %d, want 1", len(rc.jobSlots))
+		}
+		processed = append(processed, head)
+		recorded[repo.Name] = head // As the IndexBuilder does
+		return &model.ProcessingSummary{}, nil
+	}
+
+	refresher := NewRepoRefresher(rc, time.Minute, zap.NewNop())
+	ticks := make(chan time.Time)
+	refresher.newTicker = func(time.Duration) (<-chan time.Time, func()) { return ticks, func() {} }
+	refresher.headSHA = func(repoPath, ref string) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return head, nil
+	}
+	refresher.processedCommit = func(repo *config.Repository) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return recorded[repo.Name], nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		refresher.Run(ctx)
+		close(done)
+	}()
+
+	// Ticks are unbuffered and checks run on the Run goroutine, so each send
+	// returns only after the previous check finished
+	tick := func() { ticks <- time.Now() }
+	setHead := func(sha string) {
+		mu.Lock()
+		head = sha
+		mu.Unlock()
+	}
+
+	tick() // HEAD unchanged since the recorded build
+	setHead("bbb")
+	tick()
+	tick() // Already processed
+
+	// A repository with a job in progress is retried on the next tick
+	setHead("ccc")
+	finish, _, _ := rc.tryReserveRepository("demo", "processRepo")
+	tick()
+	finish()
+	tick()
+	tick()
+
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"bbb", "ccc"}
+	if len(processed) != len(want) {
+		t.Fatalf("reprocessed at heads %v, want %v", processed, want)
+	}
+	for i := range want {
+		if processed[i] != want[i] {
+			t.Errorf("reprocess %d at head %s, want %s", i, processed[i], want[i])
+		}
+	}
+	if len(rc.inFlight) != 0 || len(rc.jobSlots) != 0 {
+		t.Errorf("refresh was not released: in flight %v, slots used %d", rc.inFlight, len(rc.jobSlots))
+	}
+}
+
+func TestRepoRefresherProcessesRepositoryWithoutRecordedCommit(t *testing.T) {
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{
+		{Name: "demo", Path: "/repos/demo"},
+		{Name: "broken", Path: "/repos/broken"},
+	}}}
+	rc := NewRepoController(nil, nil, nil, nil, nil, cfg, zap.NewNop())
+
+	var mu sync.Mutex
+	recorded := make(map[string]string)
+	var processed []string
+	rc.indexRepository = func(ctx context.Context, repo *config.Repository, useHead bool) (*model.ProcessingSummary, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		processed = append(processed, repo.Name)
+		recorded[repo.Name] = "aaa"
+		return &model.ProcessingSummary{}, nil
+	}
+
+	refresher := NewRepoRefresher(rc, time.Minute, zap.NewNop())
+	ticks := make(chan time.Time)
+	refresher.newTicker = func(time.Duration) (<-chan time.Time, func()) { return ticks, func() {} }
+	refresher.headSHA = func(repoPath, ref string) (string, error) { return "aaa", nil }
+	refresher.processedCommit = func(repo *config.Repository) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if repo.Name == "broken" {
+			return "", errors.New("connection refused")
+		}
+		return recorded[repo.Name], nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		refresher.Run(ctx)
+		close(done)
+	}()
+
+	// The first check runs before the first tick is received; the second
+	// finds the commit recorded by the first
+	ticks <- time.Now()
+	ticks <- time.Now()
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(processed) != 1 || processed[0] != "demo" {
+		t.Errorf("processed %v, want demo once and nothing whose processed commit cannot be read", processed)
+	}
+}
@@ -0,0 +1,274 @@
+package controller
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/vector"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// DefaultVectorCallThreshold is the minimum similarity score used when none is configured
+const DefaultVectorCallThreshold = 0.85
+
+// vectorCallCandidates is how many similar functions are considered per call
+const vectorCallCandidates = 5
+
+// VectorCallResolver links FunctionCall nodes that LSP could not resolve to the
+// most similar function definition in the vector index. It is a heuristic for
+// dynamically typed languages where LSP often cannot find the callee.
+type VectorCallResolver struct {
+	codeGraph    *codegraph.CodeGraph
+	chunkService *vector.CodeChunkService
+	threshold    float32
+	logger       *zap.Logger
+}
+
+// NewVectorCallResolver creates a new VectorCallResolver
+func NewVectorCallResolver(codeGraph *codegraph.CodeGraph, chunkService *vector.CodeChunkService, threshold float32, logger *zap.Logger) *VectorCallResolver {
+	if threshold <= 0 {
+		threshold = DefaultVectorCallThreshold
+	}
+	return &VectorCallResolver{
+		codeGraph:    codeGraph,
+		chunkService: chunkService,
+		threshold:    threshold,
+		logger:       logger,
+	}
+}
+
+// ResolveFile resolves the dangling function calls of a file and returns how many were linked
+func (r *VectorCallResolver) ResolveFile(ctx context.Context, repo *config.Repository, fileScope *ast.Node) (int, error) {
+	calls, err := r.codeGraph.FindUnresolvedFunctionCalls(ctx, fileScope.FileID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to find unresolved function calls: %w", err)
+	}
+
+	resolved := 0
+	for _, call := range calls {
+		ok, err := r.resolveCall(ctx, repo, call)
+		if err != nil {
+			r.logger.Warn("Failed to resolve function call via vector search",
+				zap.Int64("callNodeId", int64(call.ID)),
+				zap.String("callName", call.Name),
+				zap.Error(err))
+			continue
+		}
+		if ok {
+			resolved++
+		}
+	}
+
+	if len(calls) > 0 {
+		r.logger.Info("Vector call resolution completed for file",
+			zap.Int32("fileId", fileScope.FileID),
+			zap.Int("unresolved", len(calls)),
+			zap.Int("resolved", resolved))
+	}
+	return resolved, nil
+}
+
+// resolveCall searches for functions named like the callee whose parameters
+// accept the call's arguments, compares the call rendered as a signature with
+// each one's signature text and links the call to the most similar one scoring
+// at or above the threshold
+func (r *VectorCallResolver) resolveCall(ctx context.Context, repo *config.Repository, call *ast.Node) (bool, error) {
+	callee := calleeName(call.Name)
+	if callee == "" {
+		return false, nil
+	}
+
+	arity, err := r.codeGraph.CountFunctionCallArgs(ctx, call.ID)
+	if err != nil {
+		return false, err
+	}
+	query := callQuery(callee, arity)
+
+	found, _, err := r.chunkService.SearchSimilarCode(ctx, repo.Name, query, vectorCallCandidates, map[string]interface{}{
+		"chunk_type": string(model.ChunkTypeFunction),
+		"name":       callee,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	var chunks []*model.CodeChunk
+	var signatures []string
+	for _, chunk := range found {
+		if chunk.Name != callee || !acceptsArity(chunk.Signature, arity) {
+			continue
+		}
+		signature := chunk.Signature
+		if signature == "" {
+			signature = chunk.Name
+		}
+		chunks = append(chunks, chunk)
+		signatures = append(signatures, signature)
+	}
+	if len(chunks) == 0 {
+		return false, nil
+	}
+
+	scores, err := r.chunkService.TextSimilarities(ctx, repo.Name, query, signatures)
+	if err != nil {
+		return false, err
+	}
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	for _, i := range order {
+		chunk := chunks[i]
+		if scores[i] < r.threshold {
+			break
+		}
+
+		target, err := r.findFunctionForChunk(ctx, repo, chunk)
+		if err != nil {
+			return false, err
+		}
+		if target == nil {
+			continue
+		}
+
+		err = r.codeGraph.CreateRelation(ctx, call.ID, target.ID, "CALLS_FUNCTION", map[string]any{
+			"resolved_by": "vector",
+			"confidence":  float64(scores[i]),
+		}, call.FileID)
+		if err != nil {
+			return false, err
+		}
+
+		r.logger.Debug("Created CALLS_FUNCTION relation from vector match",
+			zap.Int64("callNodeId", int64(call.ID)),
+			zap.String("callName", call.Name),
+			zap.Int64("targetFunctionId", int64(target.ID)),
+			zap.Float32("confidence", scores[i]))
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// findFunctionForChunk returns the Function node a function chunk was built from
+func (r *VectorCallResolver) findFunctionForChunk(ctx context.Context, repo *config.Repository, chunk *model.CodeChunk) (*ast.Node, error) {
+	fileID := chunk.FileID
+	if fileID == 0 {
+		fileScopes, err := r.codeGraph.FindFileScopes(ctx, repo.Name, chunk.FilePath)
+		if err != nil || len(fileScopes) == 0 {
+			return nil, err
+		}
+		fileID = fileScopes[0].FileID
+	}
+
+	functions, err := r.codeGraph.FindFunctionsByName(ctx, int(fileID), chunk.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, fn := range functions {
+		if fn.Range.Start.Line <= chunk.StartLine && chunk.StartLine <= fn.Range.End.Line {
+			return fn, nil
+		}
+	}
+	if len(functions) == 1 {
+		return functions[0], nil
+	}
+	return nil, nil
+}
+
+// callQuery renders a call in the "name(params)" form of function chunk
+// signatures, with one placeholder per argument (e.g. save with two
+// arguments -> "save(_, _)")
+func callQuery(callee string, arity int) string {
+	args := make([]string, arity)
+	for i := range args {
+		args[i] = "_"
+	}
+	return callee + "(" + strings.Join(args, ", ") + ")"
+}
+
+// receiverParams are parameters bound by the call's receiver rather than its arguments
+var receiverParams = map[string]bool{"self": true, "cls": true}
+
+// acceptsArity reports whether a function with the given signature text can be
+// called with arity arguments. Parameters with defaults are optional and
+// variadic parameters (*args, **kwargs, ...rest, Go's ...T) accept any number.
+// A signature without a parameter list accepts any call.
+func acceptsArity(signature string, arity int) bool {
+	params, ok := signatureParams(signature)
+	if !ok {
+		return true
+	}
+
+	required, optional := 0, 0
+	for _, param := range params {
+		// The name precedes a type annotation; "=>" belongs to function types
+		name := strings.TrimSpace(strings.SplitN(param, ":", 2)[0])
+		switch {
+		case name == "" || name == "*" || name == "/" || receiverParams[name]:
+		case strings.HasPrefix(name, "*") || strings.Contains(param, "..."):
+			return arity >= required
+		case strings.HasSuffix(name, "?") || strings.Contains(strings.ReplaceAll(param, "=>", ""), "="):
+			optional++
+		default:
+			required++
+		}
+	}
+	return required <= arity && arity <= required+optional
+}
+
+// signatureParams splits the parameter list of a signature at its top-level
+// commas. ok is false if the signature has no parameter list.
+func signatureParams(signature string) (params []string, ok bool) {
+	start := strings.Index(signature, "(")
+	if start < 0 {
+		return nil, false
+	}
+
+	depth, from := 0, start+1
+	for i := start + 1; i < len(signature); i++ {
+		switch signature[i] {
+		case '(', '[', '{', '<':
+			depth++
+		case '>':
+			// Part of "=>" or "->" rather than a closing type argument list
+			if prev := signature[i-1]; prev != '=' && prev != '-' {
+				depth--
+			}
+		case ']', '}':
+			depth--
+		case ')':
+			if depth == 0 {
+				if param := strings.TrimSpace(signature[from:i]); param != "" {
+					params = append(params, param)
+				}
+				return params, true
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				params = append(params, strings.TrimSpace(signature[from:i]))
+				from = i + 1
+			}
+		}
+	}
+	return nil, false
+}
+
+// calleeName strips receivers and qualifiers from a call name (e.g. "self.repo.save" -> "save")
+func calleeName(callName string) string {
+	name := strings.TrimSuffix(strings.TrimSpace(callName), "()")
+	if idx := strings.LastIndexAny(name, ".:"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return name
+}
@@ -0,0 +1,343 @@
+package controller
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"unicode"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/service/vector"
+	"bot-go/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+// stubVectorDB is a VectorDatabase whose search returns fixed chunks and
+// scores. Without scores it ranks the chunks matching the filter by the cosine
+// similarity of their searchable text, embedded with embedding, to the query.
+type stubVectorDB struct {
+	chunks    []*model.CodeChunk
+	scores    []float32
+	embedding vector.EmbeddingModel
+	filters   []map[string]interface{}
+}
+
+func (s *stubVectorDB) CreateCollection(ctx context.Context, collectionName string, vectorDim int, distance vector.DistanceMetric) error {
+	return nil
+}
+func (s *stubVectorDB) CreateCollectionWithNamedVectors(ctx context.Context, collectionName string, vectors []vector.NamedVector, distance vector.DistanceMetric) error {
+	return nil
+}
+func (s *stubVectorDB) DeleteCollection(ctx context.Context, collectionName string) error { return nil }
+func (s *stubVectorDB) CollectionExists(ctx context.Context, collectionName string) (bool, error) {
+	return true, nil
+}
+func (s *stubVectorDB) CollectionDistance(ctx context.Context, collectionName string) (vector.DistanceMetric, error) {
+	return vector.DistanceMetricCosine, nil
+}
+func (s *stubVectorDB) CollectionVectorNames(ctx context.Context, collectionName string) ([]string, error) {
+	return nil, nil
+}
+func (s *stubVectorDB) SetCollectionEmbedding(ctx context.Context, collectionName string, embedding vector.CollectionEmbedding) error {
+	return nil
+}
+func (s *stubVectorDB) CollectionEmbedding(ctx context.Context, collectionName string) (*vector.CollectionEmbedding, error) {
+	return nil, nil
+}
+func (s *stubVectorDB) UpsertChunks(ctx context.Context, collectionName string, chunks []*model.CodeChunk) error {
+	return nil
+}
+func (s *stubVectorDB) SearchSimilar(ctx context.Context, collectionName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	s.filters = append(s.filters, filter)
+	if s.scores != nil {
+		return s.chunks, s.scores, nil
+	}
+
+	var chunks []*model.CodeChunk
+	var scores []float32
+	for _, chunk := range s.chunks {
+		if chunk.Name != filter["name"] || string(chunk.ChunkType) != filter["chunk_type"] {
+			continue
+		}
+		chunkVector, err := s.embedding.GenerateEmbedding(ctx, chunk.GetSearchableText(true, 0))
+		if err != nil {
+			return nil, nil, err
+		}
+		chunks = append(chunks, chunk)
+		scores = append(scores, cosine(queryVector, chunkVector))
+	}
+	order := make([]int, len(chunks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+
+	ranked := make([]*model.CodeChunk, 0, limit)
+	rankedScores := make([]float32, 0, limit)
+	for _, i := range order {
+		if len(ranked) == limit {
+			break
+		}
+		ranked = append(ranked, chunks[i])
+		rankedScores = append(rankedScores, scores[i])
+	}
+	return ranked, rankedScores, nil
+}
+
+func cosine(a, b []float32) float32 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dot / math.Sqrt(normA*normB))
+}
+func (s *stubVectorDB) SearchSimilarNamed(ctx context.Context, collectionName, vectorName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	return s.SearchSimilar(ctx, collectionName, queryVector, limit, filter)
+}
+func (s *stubVectorDB) GetChunkByID(ctx context.Context, collectionName string, chunkID string) (*model.CodeChunk, error) {
+	return nil, nil
+}
+func (s *stubVectorDB) DeleteChunk(ctx context.Context, collectionName string, chunkID string) error {
+	return nil
+}
+func (s *stubVectorDB) GetChunksByFilePath(ctx context.Context, collectionName string, filePath string) ([]*model.CodeChunk, error) {
+	return nil, nil
+}
+func (s *stubVectorDB) GetChunksByType(ctx context.Context, collectionName string, chunkType model.ChunkType) ([]*model.CodeChunk, error) {
+	return nil, nil
+}
+func (s *stubVectorDB) Close() error                     { return nil }
+func (s *stubVectorDB) Health(ctx context.Context) error { return nil }
+
+// stubEmbedding returns a constant vector for every text
+type stubEmbedding struct{}
+
+func (stubEmbedding) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return []float32{1, 0}, nil
+}
+func (stubEmbedding) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i := range texts {
+		vecs[i] = []float32{1, 0}
+	}
+	return vecs, nil
+}
+func (stubEmbedding) GetDimension() int    { return 2 }
+func (stubEmbedding) GetModelName() string { return "stub" }
+
+// tokenEmbedding embeds a text as the counts of its lower-cased alphanumeric
+// tokens hashed into a fixed number of buckets, and records the texts it embeds
+type tokenEmbedding struct {
+	texts []string
+}
+
+func (e *tokenEmbedding) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	e.texts = append(e.texts, text)
+	vec := make([]float32, e.GetDimension())
+	isSeparator := func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }
+	for _, token := range strings.FieldsFunc(strings.ToLower(text), isSeparator) {
+		h := fnv.New32a()
+		h.Write([]byte(token))
+		vec[h.Sum32()%uint32(len(vec))]++
+	}
+	return vec, nil
+}
+func (e *tokenEmbedding) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i, text := range texts {
+		vecs[i], _ = e.GenerateEmbedding(ctx, text)
+	}
+	return vecs, nil
+}
+func (e *tokenEmbedding) GetDimension() int    { return 256 }
+func (e *tokenEmbedding) GetModelName() string { return "tokens" }
+
+// newResolverGraph returns a graph with one dangling call passing args
+// arguments in file 1, a save function defined in file 2 at lines 10-20 and
+// another in file 3 at lines 4-6
+func newResolverGraph(callName string, args int) (*testutil.MockGraphDatabase, *codegraph.CodeGraph) {
+	functions := map[int]map[string]any{
+		2: {"id": int64(200), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(2),
+			"name": "save", "range": "(10,0)-(20,0)", "version": int64(0), "scopeId": int64(2)},
+		3: {"id": int64(300), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(3),
+			"name": "save", "range": "(4,0)-(6,0)", "version": int64(0), "scopeId": int64(3)},
+	}
+
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		switch {
+		case strings.Contains(query, "NOT (fc)-[:CALLS_FUNCTION]->()"):
+			return []map[string]any{{"fc": map[string]any{
+				"id": int64(100), "nodeType": int64(ast.NodeTypeFunctionCall), "fileId": int64(1),
+				"name": callName, "version": int64(0), "scopeId": int64(50),
+			}}}, nil
+		case strings.Contains(query, "[:FUNCTION_CALL_ARG]") && params["callId"] == int64(100):
+			return []map[string]any{{"args": int64(args)}}, nil
+		case strings.Contains(query, "MATCH (n:Function)") && params["name"] == "save":
+			if fn, ok := functions[params["fileId"].(int)]; ok {
+				return []map[string]any{{"n": fn}}, nil
+			}
+		}
+		return nil, nil
+	}
+	return db, codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, zap.NewNop())
+}
+
+func newResolverChunkService(vectorDB vector.VectorDatabase) *vector.CodeChunkService {
+	return vector.NewCodeChunkService(vectorDB, stubEmbedding{}, 5, 5, 0, 0, 0, 1, zap.NewNop())
+}
+
+// resolverChunks are the function chunks of two save methods: the repository's
+// takes an order, the cache's a key and a value with an optional ttl
+func resolverChunks() []*model.CodeChunk {
+	return []*model.CodeChunk{
+		{
+			ID: "repo-save", ChunkType: model.ChunkTypeFunction, Name: "save", Language: "python",
+			FilePath: "models/repo.py", FileID: 2, StartLine: 10, EndLine: 20,
+			ClassName: "OrderRepository", Signature: "save(self, order)",
+			Content: "def save(self, order):\n" +
+				"    self.session.add(order)\n" +
+				"    self.session.commit()\n" +
+				"    return order.id\n",
+		},
+		{
+			ID: "cache-save", ChunkType: model.ChunkTypeFunction, Name: "save", Language: "python",
+			FilePath: "cache/store.py", FileID: 3, StartLine: 4, EndLine: 6,
+			ClassName: "Cache", Signature: "save(self, key, value, ttl=None)",
+			Content: "def save(self, key, value, ttl=None):\n" +
+				"    self.entries[key] = (value, ttl)\n",
+		},
+	}
+}
+
+func TestVectorCallResolverMatchesCallToSignature(t *testing.T) {
+	tests := []struct {
+		name       string
+		call       string
+		args       int
+		wantQuery  string
+		wantTarget int64
+	}{
+		{"one argument", "self.repo.save", 1, "save(_)", 200},
+		{"two arguments", "self.cache.save", 2, "save(_, _)", 300},
+		{"three arguments", "self.cache.save", 3, "save(_, _, _)", 300},
+		{"too many arguments", "self.cache.save", 4, "save(_, _, _, _)", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, graph := newResolverGraph(tt.call, tt.args)
+			embedding := &tokenEmbedding{}
+			vectorDB := &stubVectorDB{chunks: resolverChunks(), embedding: embedding}
+			chunkService := vector.NewCodeChunkService(vectorDB, embedding, 5, 5, 0, 0, 0, 1, zap.NewNop())
+			resolver := NewVectorCallResolver(graph, chunkService, 0.4, zap.NewNop())
+
+			resolved, err := resolver.ResolveFile(context.Background(), &config.Repository{Name: "demo"},
+				&ast.Node{ID: 1, NodeType: ast.NodeTypeFileScope, FileID: 1})
+			if err != nil {
+				t.Fatalf("ResolveFile failed: %v", err)
+			}
+			if len(embedding.texts) == 0 || embedding.texts[0] != tt.wantQuery {
+				t.Errorf("query texts = %q, want %q first", embedding.texts, tt.wantQuery)
+			}
+			if len(vectorDB.filters) != 1 || vectorDB.filters[0]["name"] != "save" || vectorDB.filters[0]["chunk_type"] != "function" {
+				t.Errorf("unexpected search filters: %v", vectorDB.filters)
+			}
+
+			writes := db.Writes()
+			if tt.wantTarget == 0 {
+				if resolved != 0 || len(writes) != 0 {
+					t.Errorf("resolved %d calls with %d writes, want none", resolved, len(writes))
+				}
+				return
+			}
+			if resolved != 1 || len(writes) != 1 {
+				t.Fatalf("resolved %d calls with %d writes, want 1", resolved, len(writes))
+			}
+			write := writes[0]
+			if !strings.Contains(write.Query, "[r:CALLS_FUNCTION]") {
+				t.Errorf("write does not create a CALLS_FUNCTION relation: %s", write.Query)
+			}
+			if write.Params["parentId"] != int64(100) || write.Params["childId"] != tt.wantTarget {
+				t.Errorf("relation links %v -> %v, want 100 -> %d", write.Params["parentId"], write.Params["childId"], tt.wantTarget)
+			}
+			if write.Params["md_resolved_by"] != "vector" {
+				t.Errorf("md_resolved_by = %v, want vector", write.Params["md_resolved_by"])
+			}
+			if confidence, ok := write.Params["md_confidence"].(float64); !ok || confidence < 0.4 || confidence > 1 {
+				t.Errorf("md_confidence = %v, want a similarity at or above the threshold", write.Params["md_confidence"])
+			}
+		})
+	}
+}
+
+func TestVectorCallResolverIgnoresLowSimilarity(t *testing.T) {
+	// "save(_)" shares only the name with "save(self, order)"
+	db, graph := newResolverGraph("self.repo.save", 1)
+	embedding := &tokenEmbedding{}
+	vectorDB := &stubVectorDB{chunks: resolverChunks(), embedding: embedding}
+	chunkService := vector.NewCodeChunkService(vectorDB, embedding, 5, 5, 0, 0, 0, 1, zap.NewNop())
+	resolver := NewVectorCallResolver(graph, chunkService, 0.8, zap.NewNop())
+
+	resolved, err := resolver.ResolveFile(context.Background(), &config.Repository{Name: "demo"}, &ast.Node{ID: 1, FileID: 1})
+	if err != nil {
+		t.Fatalf("ResolveFile failed: %v", err)
+	}
+	if resolved != 0 || len(db.Writes()) != 0 {
+		t.Errorf("resolved %d calls with %d writes, want none below threshold", resolved, len(db.Writes()))
+	}
+}
+
+func TestCalleeName(t *testing.T) {
+	tests := map[string]string{
+		"save":           "save",
+		"self.repo.save": "save",
+		"Repo::save":     "save",
+		"save()":         "save",
+		"":               "",
+	}
+	for input, want := range tests {
+		if got := calleeName(input); got != want {
+			t.Errorf("calleeName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestAcceptsArity(t *testing.T) {
+	tests := []struct {
+		signature string
+		arity     int
+		want      bool
+	}{
+		{"save(self, order)", 1, true},
+		{"save(self, order)", 2, false},
+		{"save(self, key, value, ttl=None)", 2, true},
+		{"save(self, key, value, ttl=None)", 4, false},
+		{"log(self, fmt, *args, **kwargs)", 5, true},
+		{"log(self, fmt, *args, **kwargs)", 0, false},
+		{"fetch(self, url: str, *, timeout: float = 1.0) -> Response", 2, true},
+		{"Printf(format string, a ...any)", 3, true},
+		{"Copy(dst, src []byte) int", 2, true},
+		{"merge(a: Map<string, number>, b?: Map<string, number>)", 1, true},
+		{"on(event: string, cb: (e: Event) => void)", 2, true},
+		{"on(event: string, cb: (e: Event) => void)", 1, false},
+		{"save", 3, true},
+	}
+	for _, tt := range tests {
+		if got := acceptsArity(tt.signature, tt.arity); got != tt.want {
+			t.Errorf("acceptsArity(%q, %d) = %v, want %v", tt.signature, tt.arity, got, tt.want)
+		}
+	}
+}
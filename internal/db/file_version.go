@@ -34,6 +34,10 @@ var (
 	invalidTableNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
 )
 
+// processedCommitsTable records, per repository, the commit its index was
+// last built from. It is shared by all repositories.
+const processedCommitsTable = "`processed_commits`"
+
 // sanitizeTableName converts a repository name to a valid SQL table name
 // Replaces all special characters (hyphens, spaces, etc.) with underscores
 func sanitizeTableName(repoName string) string {
@@ -72,7 +76,8 @@ func (r *FileVersionRepository) tableName() string {
 }
 
 // EnsureTable creates the file_versions table if it doesn't exist
-// and ensures all required columns are present (handles schema migrations)
+// and ensures all required columns are present (handles schema migrations).
+// The shared processed_commits table is created along with it.
 func (r *FileVersionRepository) EnsureTable() error {
 	tableName := r.tableName()
 	r.logger.Info("Ensuring file_versions table exists", zap.String("table", tableName))
@@ -131,6 +136,18 @@ func (r *FileVersionRepository) EnsureTable() error {
 		r.logger.Info("Status column added successfully", zap.String("table", tableName))
 	}
 
+	processedCommitsQuery := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			repo_name VARCHAR(255) NOT NULL PRIMARY KEY,
+			commit_sha VARCHAR(40) NOT NULL,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+	`, processedCommitsTable)
+
+	if _, err := r.db.Exec(processedCommitsQuery); err != nil {
+		return fmt.Errorf("failed to create processed commits table: %w", err)
+	}
+
 	r.logger.Info("Table ready", zap.String("table", tableName))
 	return nil
 }
@@ -372,6 +389,45 @@ func (r *FileVersionRepository) UpdateStatus(fileID int32, status string) error
 	return nil
 }
 
+// GetProcessedCommit returns the commit the repository's index was last built
+// from, or "" if no build has been recorded
+func (r *FileVersionRepository) GetProcessedCommit() (string, error) {
+	query := fmt.Sprintf(`
+		SELECT commit_sha
+		FROM %s
+		WHERE repo_name = ?
+	`, processedCommitsTable)
+
+	var commitSHA string
+	err := r.db.QueryRow(query, r.repoName).Scan(&commitSHA)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read processed commit: %w", err)
+	}
+	return commitSHA, nil
+}
+
+// SetProcessedCommit records the commit the repository's index was built from
+func (r *FileVersionRepository) SetProcessedCommit(commitSHA string) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (repo_name, commit_sha)
+		VALUES (?, ?)
+		ON DUPLICATE KEY UPDATE commit_sha = VALUES(commit_sha)
+	`, processedCommitsTable)
+
+	if _, err := r.db.Exec(query, r.repoName, commitSHA); err != nil {
+		return fmt.Errorf("failed to record processed commit: %w", err)
+	}
+
+	r.logger.Debug("Recorded processed commit",
+		zap.String("repo_name", r.repoName),
+		zap.String("commit_sha", commitSHA))
+
+	return nil
+}
+
 // GetStats returns statistics about the file versions
 func (r *FileVersionRepository) GetStats() (total int64, ephemeral int64, committed int64, err error) {
 	tableName := r.tableName()
@@ -389,7 +445,8 @@ func (r *FileVersionRepository) GetStats() (total int64, ephemeral int64, commit
 }
 
 // DropTable drops the file_versions table for this repository.
-// This permanently deletes all file version tracking data for the repository.
+// This permanently deletes all file version tracking data for the repository,
+// including its processed commit.
 func (r *FileVersionRepository) DropTable() error {
 	tableName := r.tableName()
 
@@ -402,6 +459,11 @@ func (r *FileVersionRepository) DropTable() error {
 		return fmt.Errorf("failed to drop table %s: %w", tableName, err)
 	}
 
+	query = fmt.Sprintf(`DELETE FROM %s WHERE repo_name = ?`, processedCommitsTable)
+	if _, err := r.db.Exec(query, r.repoName); err != nil {
+		return fmt.Errorf("failed to delete processed commit: %w", err)
+	}
+
 	r.logger.Info("File versions table dropped successfully", zap.String("table", tableName))
 	return nil
 }
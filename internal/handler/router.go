@@ -11,7 +11,7 @@ import (
 	"go.uber.org/zap"
 )
 
-func SetupRouter(repoController *controller.RepoController, mcpServer *mcp.CodeGraphServer, codeAPIController *controller.CodeAPIController, logger *zap.Logger) *gin.Engine {
+func SetupRouter(repoController *controller.RepoController, mcpServer *mcp.CodeGraphServer, codeAPIController *controller.CodeAPIController, graphController *controller.GraphController, logger *zap.Logger) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 
 	router := gin.New()
@@ -21,21 +21,45 @@ func SetupRouter(repoController *controller.RepoController, mcpServer *mcp.CodeG
 	v1 := router.Group("/api/v1")
 	{
 		v1.POST("/buildIndex", repoController.BuildIndex)
-		//v1.POST("/getFunctionsInFile", repoController.GetFunctionsInFile)
-		//v1.POST("/getFunctionDetails", repoController.GetFunctionDetails)
+		v1.POST("/processRepo", repoController.ProcessRepo)
+		v1.GET("/processRepo/:jobId", repoController.GetProcessRepoJob)
+		v1.POST("/getFunctionsInFile", repoController.GetFunctionsInFile)
+		v1.POST("/getFileCallEdges", repoController.GetFileCallEdges)
+		v1.POST("/getFunctionDetails", repoController.GetFunctionDetails)
 		v1.POST("/functionDependencies", repoController.GetFunctionDependencies)
 		v1.POST("/processDirectory", repoController.ProcessDirectory)
 		v1.POST("/searchSimilarCode", repoController.SearchSimilarCode)
+		v1.POST("/searchSimilarCodeAcrossRepos", repoController.SearchSimilarCodeAcrossRepos)
+		v1.POST("/findDuplicates", repoController.FindDuplicates)
 
 		// Index building endpoints
 		v1.POST("/indexFile", repoController.IndexFile)
 
 		// N-gram endpoints
 		v1.POST("/processNGram", repoController.ProcessNGram)
+		v1.POST("/processNGram/stream", repoController.ProcessNGramStream)
 		v1.POST("/getNGramStats", repoController.GetNGramStats)
 		v1.POST("/getFileEntropy", repoController.GetFileEntropy)
+		v1.POST("/recomputeNGramEntropy", repoController.RecomputeNGramEntropy)
+		v1.POST("/topSurprisingFiles", repoController.TopSurprisingFiles)
 		v1.POST("/analyzeCode", repoController.AnalyzeCode)
 		v1.POST("/calculateZScore", repoController.CalculateZScore)
+		v1.POST("/analyzeDiff", repoController.AnalyzeDiff)
+		v1.POST("/ngram/compare", repoController.CompareRepositories)
+		v1.POST("/ngram/anomalies", repoController.NGramAnomalies)
+
+		// Code graph debugging endpoints
+		if graphController != nil {
+			v1.GET("/graph/node/:id", graphController.GetGraphNode)
+			v1.GET("/graph/node/:id/children", graphController.GetGraphNodeChildren)
+			v1.GET("/graph/node/:id/export", graphController.ExportGraph)
+			v1.GET("/graph/node/:id/dataflow", graphController.GetDataFlowGraph)
+			v1.GET("/graph/search", graphController.SearchGraphNodes)
+			v1.GET("/graph/functions", graphController.FindFunctionsBySignature)
+			v1.GET("/graph/packageMetrics", graphController.GetPackageMetrics)
+		}
+
+		v1.GET("/debug/stats", repoController.GetDebugStats)
 
 		v1.GET("/health", func(c *gin.Context) {
 			c.JSON(200, gin.H{
@@ -1,6 +1,7 @@
 package init
 
 import (
+	"bot-go/internal/chunk"
 	"bot-go/internal/config"
 	"bot-go/internal/controller"
 	"bot-go/internal/db"
@@ -10,6 +11,7 @@ import (
 	"bot-go/internal/service/vector"
 	"context"
 	"fmt"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -92,7 +94,7 @@ func NewServiceContainer(cfg *config.Config, opts ServiceInitOptions, logger *za
 
 	// Initialize N-gram service if enabled
 	if opts.EnableNgram {
-		container.NgramService, err = initNgramService(logger)
+		container.NgramService, err = initNgramService(cfg, logger)
 		if err != nil {
 			return nil, fmt.Errorf("N-gram service initialization failed: %w", err)
 		}
@@ -112,6 +114,9 @@ func (sc *ServiceContainer) InitProcessors(cfg *config.Config) error {
 			return fmt.Errorf("CodeGraph processor requires RepoService but it's not initialized")
 		}
 		codeGraphProcessor := controller.NewCodeGraphProcessor(cfg, sc.CodeGraph, sc.RepoService, sc.logger)
+		if sc.ChunkService != nil {
+			codeGraphProcessor.SetChunkService(sc.ChunkService)
+		}
 		processors = append(processors, codeGraphProcessor)
 		sc.logger.Info("CodeGraph processor added to pipeline")
 	}
@@ -125,9 +130,8 @@ func (sc *ServiceContainer) InitProcessors(cfg *config.Config) error {
 
 	// Add N-gram processor if available
 	if sc.NgramService != nil {
-		n := 3 // trigrams
 		override := false
-		ngramProcessor := controller.NewNGramProcessor(sc.NgramService, n, override, sc.logger)
+		ngramProcessor := controller.NewNGramProcessor(sc.NgramService, cfg.NGram.N, cfg.NGram.MinTokens, override, sc.logger)
 		processors = append(processors, ngramProcessor)
 		sc.logger.Info("N-gram processor added to pipeline")
 	}
@@ -148,7 +152,11 @@ func (sc *ServiceContainer) Close(ctx context.Context) {
 		sc.logger.Info("CodeGraph closed")
 	}
 
-	if sc.VectorDB != nil {
+	if sc.ChunkService != nil {
+		// Closes the vector DB and flushes the embedding cache
+		sc.ChunkService.Close()
+		sc.logger.Info("Vector DB closed")
+	} else if sc.VectorDB != nil {
 		sc.VectorDB.Close()
 		sc.logger.Info("Vector DB closed")
 	}
@@ -195,6 +203,9 @@ func initCodeGraph(cfg *config.Config, logger *zap.Logger) (*codegraph.CodeGraph
 	return codeGraph, nil
 }
 
+// vectorSelfTestTimeout bounds the startup self-test of the vector services
+const vectorSelfTestTimeout = 30 * time.Second
+
 // initVectorServices initializes Vector DB, Embedding model, and CodeChunkService
 func initVectorServices(cfg *config.Config, logger *zap.Logger) (vector.VectorDatabase, vector.EmbeddingModel, *vector.CodeChunkService, error) {
 	// Validate configuration
@@ -210,10 +221,11 @@ func initVectorServices(cfg *config.Config, logger *zap.Logger) (vector.VectorDa
 
 	// Initialize Ollama embedding model
 	embeddingModel, err := vector.NewOllamaEmbedding(vector.OllamaEmbeddingConfig{
-		APIURL:    cfg.Ollama.URL,
-		APIKey:    cfg.Ollama.APIKey,
-		Model:     cfg.Ollama.Model,
-		Dimension: cfg.Ollama.Dimension,
+		APIURL:          cfg.Ollama.URL,
+		APIKey:          cfg.Ollama.APIKey,
+		Model:           cfg.Ollama.Model,
+		Dimension:       cfg.Ollama.Dimension,
+		StrictDimension: cfg.Ollama.StrictDimension,
 	}, logger)
 	if err != nil {
 		vectorDB.Close()
@@ -230,14 +242,23 @@ func initVectorServices(cfg *config.Config, logger *zap.Logger) (vector.VectorDa
 		minLoopLines = 5
 	}
 
+	maxChunkLines := cfg.Chunking.MaxChunkLines
+	overlapLines := cfg.Chunking.OverlapLines
+	if maxChunkLines > 0 && (overlapLines < 0 || overlapLines >= maxChunkLines) {
+		logger.Warn("Chunk overlap must be smaller than max chunk lines, disabling overlap",
+			zap.Int("max_chunk_lines", maxChunkLines),
+			zap.Int("overlap_lines", overlapLines))
+		overlapLines = 0
+	}
+
 	gcThreshold := cfg.App.GCThreshold
 	if gcThreshold == 0 {
-		gcThreshold = 100
+		gcThreshold = config.DefaultGCThreshold
 	}
 
 	numFileThreads := cfg.App.NumFileThreads
 	if numFileThreads == 0 {
-		numFileThreads = 2
+		numFileThreads = config.DefaultNumFileThreads
 	}
 
 	// Create CodeChunkService
@@ -246,28 +267,147 @@ func initVectorServices(cfg *config.Config, logger *zap.Logger) (vector.VectorDa
 		embeddingModel,
 		minConditionalLines,
 		minLoopLines,
+		maxChunkLines,
+		overlapLines,
 		gcThreshold,
 		numFileThreads,
 		logger,
 	)
 
+	distance, err := vector.ParseDistanceMetric(cfg.Qdrant.Distance)
+	if err != nil {
+		vectorDB.Close()
+		return nil, nil, nil, fmt.Errorf("invalid qdrant.distance: %w", err)
+	}
+	chunkService.SetDistanceMetric(distance)
+
+	// Collections built with another model stay searchable if it is configured
+	for _, searchModel := range cfg.Ollama.SearchModels {
+		embedding, err := vector.NewOllamaEmbedding(vector.OllamaEmbeddingConfig{
+			APIURL:    cfg.Ollama.URL,
+			APIKey:    cfg.Ollama.APIKey,
+			Model:     searchModel.Model,
+			Dimension: searchModel.Dimension,
+		}, logger)
+		if err != nil {
+			logger.Warn("Failed to initialize search embedding model, skipping it",
+				zap.String("model", searchModel.Model),
+				zap.Error(err))
+			continue
+		}
+		chunkService.RegisterEmbeddingModel(embedding)
+	}
+
+	// Store a description embedding per chunk beside the code embedding
+	if docModel := cfg.Ollama.DocModel; docModel != nil {
+		docEmbedding, err := vector.NewOllamaEmbedding(vector.OllamaEmbeddingConfig{
+			APIURL:    cfg.Ollama.URL,
+			APIKey:    cfg.Ollama.APIKey,
+			Model:     docModel.Model,
+			Dimension: docModel.Dimension,
+		}, logger)
+		if err != nil {
+			vectorDB.Close()
+			return nil, nil, nil, fmt.Errorf("failed to initialize doc embedding model: %w", err)
+		}
+		chunkService.SetDocEmbeddingModel(docEmbedding)
+	}
+
+	// Reuse embeddings for unchanged chunk content across runs
+	if cfg.Chunking.EmbeddingCachePath != "" {
+		embeddingCache, err := vector.NewEmbeddingCache(cfg.Chunking.EmbeddingCachePath, logger)
+		if err != nil {
+			logger.Warn("Failed to initialize embedding cache, continuing without it",
+				zap.String("path", cfg.Chunking.EmbeddingCachePath),
+				zap.Error(err))
+		} else {
+			chunkService.SetEmbeddingCache(embeddingCache)
+		}
+	}
+
+	// Skip minified and generated files; negative values disable a heuristic
+	skipRules := vector.FileSkipRules{
+		Globs:                cfg.Chunking.SkipGlobs,
+		MaxAvgLineLength:     cfg.Chunking.MaxAvgLineLength,
+		GeneratedMarkerLines: cfg.Chunking.GeneratedMarkerLines,
+	}
+	if skipRules.Globs == nil {
+		skipRules.Globs = vector.DefaultSkipGlobs
+	}
+	if skipRules.MaxAvgLineLength == 0 {
+		skipRules.MaxAvgLineLength = 300
+	}
+	if skipRules.GeneratedMarkerLines == 0 {
+		skipRules.GeneratedMarkerLines = 5
+	}
+	chunkService.SetFileSkipRules(skipRules)
+	chunkService.SetAbsolutePaths(cfg.App.AbsolutePaths)
+	chunkService.SetMaxFileSize(cfg.App.MaxFileSizeBytes)
+	chunkService.SetMaxContentLength(cfg.Chunking.MaxContentChars, chunk.ContentLimitStrategy(cfg.Chunking.ContentStrategy))
+
+	// Exercise Ollama and Qdrant end to end before serving traffic
+	if cfg.App.VectorSelfTest {
+		ctx, cancel := context.WithTimeout(context.Background(), vectorSelfTestTimeout)
+		err := chunkService.SelfTest(ctx)
+		cancel()
+		if err != nil {
+			logger.Error("Vector services self-test failed",
+				zap.String("qdrant_host", cfg.Qdrant.Host),
+				zap.String("ollama_url", cfg.Ollama.URL),
+				zap.String("model", embeddingModel.GetModelName()),
+				zap.Error(err))
+			vectorDB.Close()
+			return nil, nil, nil, fmt.Errorf("vector services self-test failed: %w", err)
+		}
+	}
+
 	logger.Info("Vector services initialized",
 		zap.String("qdrant_host", cfg.Qdrant.Host),
 		zap.Int("qdrant_port", cfg.Qdrant.Port),
 		zap.String("ollama_url", cfg.Ollama.URL),
 		zap.Int("min_conditional_lines", minConditionalLines),
 		zap.Int("min_loop_lines", minLoopLines),
+		zap.Int("max_chunk_lines", maxChunkLines),
+		zap.Int("overlap_lines", overlapLines),
+		zap.Strings("skip_globs", skipRules.Globs),
 		zap.Int64("gc_threshold", gcThreshold))
 
 	return vectorDB, embeddingModel, chunkService, nil
 }
 
 // initNgramService initializes the N-gram service
-func initNgramService(logger *zap.Logger) (*ngram.NGramService, error) {
-	ngramService, err := ngram.NewNGramService(logger)
+func initNgramService(cfg *config.Config, logger *zap.Logger) (*ngram.NGramService, error) {
+	outputDir := cfg.NGramOutputDir()
+	ngramService, err := ngram.NewNGramServiceWithOutputDir(outputDir, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize N-gram service: %w", err)
 	}
+	ngramService.SetPythonIndentTokens(cfg.NGram.PythonIndentTokens)
+	ngramService.SetMaxVocabulary(cfg.NGram.MaxVocabulary)
+	if err := ngramService.SetNRange(cfg.NGram.MinN, cfg.NGram.MaxN); err != nil {
+		return nil, fmt.Errorf("invalid ngram.min_n/max_n: %w", err)
+	}
+	if err := ngramService.ValidateN(cfg.NGram.N); err != nil {
+		return nil, fmt.Errorf("invalid ngram.n: %w", err)
+	}
+	if cfg.NGram.GenericTokenizer {
+		extensions := cfg.NGram.GenericExtensions
+		if len(extensions) == 0 {
+			extensions = ngram.DefaultGenericExtensions
+		}
+		ngramService.SetGenericExtensions(extensions)
+	}
+	if cfg.NGram.ZScoreScale != nil {
+		scale, err := ngram.NewZScoreScale(*cfg.NGram.ZScoreScale)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ngram.zscore_scale: %w", err)
+		}
+		ngramService.SetZScoreScale(scale)
+	}
+	ngramService.SetMaxFileSize(cfg.App.MaxFileSizeBytes)
+	ngramService.SetFileWeights(cfg.NGram.FileWeights)
+
+	logger.Info("N-gram models directory", zap.String("output_dir", outputDir))
 
 	return ngramService, nil
 }
@@ -284,6 +424,14 @@ func GetIndexBuildingOptions(cfg *config.Config) ServiceInitOptions {
 	}
 }
 
+// GetNaturalnessOptions returns ServiceInitOptions for scoring files against
+// saved n-gram models from the command line
+func GetNaturalnessOptions() ServiceInitOptions {
+	return ServiceInitOptions{
+		EnableNgram: true,
+	}
+}
+
 // GetServerModeOptions returns ServiceInitOptions configured for server mode
 func GetServerModeOptions(cfg *config.Config) ServiceInitOptions {
 	return ServiceInitOptions{
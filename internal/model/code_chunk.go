@@ -2,6 +2,7 @@ package model
 
 import (
 	"bot-go/pkg/lsp/base"
+	"unicode/utf8"
 )
 
 // ChunkType represents the hierarchical level of a code chunk
@@ -49,6 +50,10 @@ type CodeChunk struct {
 	// Vector embedding (generated by embedding model)
 	Embedding []float32 `json:"embedding,omitempty"`
 
+	// Vectors by name, for collections storing several embeddings per chunk
+	// (e.g. "code" and "doc"); when set they are stored instead of Embedding
+	NamedEmbeddings map[string][]float32 `json:"named_embeddings,omitempty"`
+
 	// Additional metadata
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
@@ -121,11 +126,21 @@ func (c *CodeChunk) WithMetadata(key string, value interface{}) *CodeChunk {
 	return c
 }
 
+// DefaultMaxSearchableChars is the content limit GetSearchableText applies
+// when none is configured, conservative for most embedding models (~2000 tokens)
+const DefaultMaxSearchableChars = 8000
+
+// searchableTruncationMarker ends content GetSearchableText truncated
+const searchableTruncationMarker = "\n// ... (truncated)"
+
 // GetSearchableText returns the text representation for embedding generation
-// Truncates content to avoid exceeding embedding model context limits
+// Truncates content longer than maxChars characters to avoid exceeding
+// embedding model context limits; maxChars of 0 or less uses DefaultMaxSearchableChars
 // includeContext: if true, includes module/class context; if false, only includes the code content
-func (c *CodeChunk) GetSearchableText(includeContext bool) string {
-	const maxChars = 8000 // Conservative limit for most embedding models (~2000 tokens)
+func (c *CodeChunk) GetSearchableText(includeContext bool, maxChars int) string {
+	if maxChars <= 0 {
+		maxChars = DefaultMaxSearchableChars
+	}
 
 	text := ""
 
@@ -148,15 +163,24 @@ func (c *CodeChunk) GetSearchableText(includeContext bool) string {
 	}
 
 	// Add the actual code content (may be truncated)
-	remainingChars := maxChars - len(text)
-	if remainingChars > 0 {
-		if len(c.Content) > remainingChars {
-			// Truncate content and add indicator
-			text += c.Content[:remainingChars-20] + "\n// ... (truncated)"
-		} else {
-			text += c.Content
-		}
+	if utf8.RuneCountInString(c.Content) <= maxChars {
+		return text + c.Content
 	}
+	// Truncate content and add indicator
+	keep := maxChars - utf8.RuneCountInString(searchableTruncationMarker)
+	if keep <= 0 {
+		return text + firstChars(c.Content, maxChars)
+	}
+	return text + firstChars(c.Content, keep) + searchableTruncationMarker
+}
 
-	return text
+// firstChars returns the first n characters of s
+func firstChars(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
 }
@@ -1,11 +1,25 @@
 package ngram
 
+// TokenCategory is the lexical category of a token, independent of language
+type TokenCategory string
+
+const (
+	CategoryKeyword     TokenCategory = "keyword"
+	CategoryIdentifier  TokenCategory = "identifier"
+	CategoryString      TokenCategory = "string"
+	CategoryNumber      TokenCategory = "number"
+	CategoryOperator    TokenCategory = "operator"
+	CategoryPunctuation TokenCategory = "punctuation"
+	CategoryOther       TokenCategory = "other"
+)
+
 // Token represents a single lexical token in source code
 type Token struct {
-	Type   string // Token type (e.g., "IDENTIFIER", "KEYWORD", "LITERAL", etc.)
-	Value  string // Original token value
-	Line   int    // Line number in source
-	Column int    // Column number in source
+	Type     string        // Parser node kind (e.g., "identifier", "int_literal", "func")
+	Category TokenCategory // Lexical category used for normalization
+	Value    string        // Original token value
+	Line     int           // Line number in source
+	Column   int           // Column number in source
 }
 
 // TokenSequence is a slice of tokens
@@ -1,13 +1,53 @@
 package model
 
 import (
+	"time"
+
 	"bot-go/pkg/lsp/base"
 )
 
+type ProcessRepoRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+	UseHead  bool   `json:"use_head"` // Use git HEAD version instead of working directory
+}
+
+// ProcessRepoResponse describes an asynchronous repository processing job
 type ProcessRepoResponse struct {
-	RepoName  string               `json:"repo_name"`
-	Files     []FileInfo           `json:"files"`
-	Functions []FunctionDefinition `json:"functions"`
+	JobID      string     `json:"job_id"`
+	RepoName   string     `json:"repo_name"`
+	Status     string     `json:"status"` // running, completed or failed
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	// Summary is set once the files have been processed
+	Summary *ProcessingSummary `json:"summary,omitempty"`
+}
+
+// ProcessingSummary counts the files a repository processing run attempted.
+// A file fails when it cannot be read or any processor returns an error for it.
+// A file indexed despite problems, such as syntax errors, succeeds with warnings.
+type ProcessingSummary struct {
+	FilesAttempted int           `json:"files_attempted"`
+	FilesSucceeded int           `json:"files_succeeded"`
+	FilesFailed    int           `json:"files_failed"`
+	Failures       []FileFailure `json:"failures"` // Ordered by path
+	Warnings       []FileWarning `json:"warnings"` // Ordered by path and location
+}
+
+// FileFailure records why a file could not be processed
+type FileFailure struct {
+	Path      string `json:"path"`                // Relative to the repository path
+	Processor string `json:"processor,omitempty"` // Empty when the file failed before reaching the processors
+	Reason    string `json:"reason"`
+}
+
+// FileWarning records a problem in a file that was still processed
+type FileWarning struct {
+	Path      string `json:"path"` // Relative to the repository path
+	Processor string `json:"processor"`
+	Line      int    `json:"line,omitempty"`   // 1-based; 0 when the warning has no location
+	Column    int    `json:"column,omitempty"` // 1-based
+	Message   string `json:"message"`
 }
 
 type GetFunctionsInFileRequest struct {
@@ -21,27 +61,66 @@ type GetFunctionsInFileResponse struct {
 	Functions []FunctionDefinition `json:"functions"`
 }
 
+type GetFileCallEdgesRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+	FilePath string `json:"file_path" binding:"required"`
+}
+
+// GetFileCallEdgesResponse lists every call made by the functions of a file.
+// Edges and unresolved calls are ordered by call position.
+type GetFileCallEdgesResponse struct {
+	RepoName   string           `json:"repo_name"`
+	FilePath   string           `json:"file_path"`
+	Edges      []FileCallEdge   `json:"edges"`
+	Unresolved []UnresolvedCall `json:"unresolved"`
+}
+
+// FileCallEdge is a call from a function in the file to a resolved callee,
+// which may be defined in another file
+type FileCallEdge struct {
+	Caller    FunctionDefinition `json:"caller"`
+	Callee    FunctionDefinition `json:"callee"`
+	CallRange base.Range         `json:"call_range"`
+}
+
+// UnresolvedCall is a call the code graph has no callee for
+type UnresolvedCall struct {
+	Caller    FunctionDefinition `json:"caller"`
+	Name      string             `json:"name"`
+	CallRange base.Range         `json:"call_range"`
+}
+
 type GetFunctionDetailsRequest struct {
 	RepoName     string `json:"repo_name" binding:"required"`
 	RelativePath string `json:"relative_path" binding:"required"`
 	FunctionName string `json:"function_name" binding:"required"`
 }
 
+// FunctionDetailsSource names where function details were read from
+type FunctionDetailsSource string
+
+const (
+	FunctionDetailsSourceLSP   FunctionDetailsSource = "lsp"   // The repository's language server
+	FunctionDetailsSourceGraph FunctionDetailsSource = "graph" // The code graph, when no language server answered
+)
+
 type GetFunctionDetailsResponse struct {
-	RepoName     string          `json:"repo_name"`
-	FilePath     string          `json:"file_path"`
-	FunctionName string          `json:"function_name"`
-	Details      FunctionDetails `json:"details"`
+	RepoName     string                `json:"repo_name"`
+	FilePath     string                `json:"file_path"`
+	FunctionName string                `json:"function_name"`
+	Source       FunctionDetailsSource `json:"source"`
+	Details      FunctionDetails       `json:"details"`
 }
 
 type FunctionDetails struct {
-	Name          string        `json:"name"`
-	Signature     string        `json:"signature"`
-	Parameters    []Parameter   `json:"parameters"`
-	ReturnType    string        `json:"return_type"`
-	IsAsync       bool          `json:"is_async"`
-	Documentation string        `json:"documentation"`
-	Location      base.Location `json:"location"`
+	Name            string        `json:"name"`
+	Signature       string        `json:"signature"`
+	Parameters      []Parameter   `json:"parameters"`
+	ReturnType      string        `json:"return_type"`
+	IsAsync         bool          `json:"is_async"`
+	Documentation   string        `json:"documentation"`
+	Location        base.Location `json:"location"`
+	ContainingClass string        `json:"containing_class,omitempty"`
 }
 
 type Parameter struct {
@@ -78,6 +157,7 @@ type FunctionDefinition struct {
 	Module     string        `json:"module,omitempty"`
 	Params     string        `json:"params"`
 	Returns    string        `json:"returns"`
+	Signature  string        `json:"signature,omitempty"`
 }
 
 type CallGraph struct {
@@ -261,6 +341,8 @@ func FunctionDefinitionToKey(def FunctionDefinition) string {
 type ProcessDirectoryRequest struct {
 	RepoName       string `json:"repo_name" binding:"required"`
 	CollectionName string `json:"collection_name"`
+	// IncrementalSinceHead processes only files modified since HEAD plus new untracked files
+	IncrementalSinceHead bool `json:"incremental_since_head"`
 }
 
 type ProcessDirectoryResponse struct {
@@ -278,6 +360,11 @@ type SearchSimilarCodeRequest struct {
 	Language       string `json:"language" binding:"required"`
 	Limit          int    `json:"limit"`
 	IncludeCode    bool   `json:"include_code"`
+	// MinScore drops results scoring below it (0 keeps all)
+	MinScore float32 `json:"min_score"`
+	// Dedup collapses results with overlapping line ranges in the same file,
+	// keeping the highest scoring one
+	Dedup bool `json:"dedup"`
 }
 
 type SearchSimilarCodeResponse struct {
@@ -297,22 +384,80 @@ type QueryInfo struct {
 }
 
 type SimilarCodeResult struct {
-	Chunk           *CodeChunk `json:"chunk"`
-	Score           float32    `json:"score"`
-	QueryChunkIndex int        `json:"query_chunk_index"` // Index of the input chunk that matched this result (0-based)
-	Code            string     `json:"code,omitempty"`    // Actual code content from file (if include_code is true)
+	Chunk           *CodeChunk         `json:"chunk"`
+	Score           float32            `json:"score"`
+	QueryChunkIndex int                `json:"query_chunk_index"`     // Index of the input chunk that matched this result (0-based)
+	Code            string             `json:"code,omitempty"`        // Actual code content from file (if include_code is true)
+	Collection      string             `json:"collection,omitempty"`  // Collection the chunk was found in (cross-repo search only)
+	QueryChunk      *MatchedQueryChunk `json:"query_chunk,omitempty"` // The part of the submitted snippet that matched
+}
+
+// MatchedQueryChunk is the chunk of a submitted snippet a similar code result
+// matched, located within the snippet so it can be highlighted
+type MatchedQueryChunk struct {
+	Content   string `json:"content"`
+	StartLine int    `json:"start_line"` // First line within the snippet (0-based)
+	EndLine   int    `json:"end_line"`   // Last line within the snippet (0-based, inclusive)
+}
+
+// SearchAcrossReposRequest searches the collections of several repositories at
+// once; each repository's collection is named after it
+type SearchAcrossReposRequest struct {
+	RepoNames   []string `json:"repo_names" binding:"required,min=1"`
+	CodeSnippet string   `json:"code_snippet" binding:"required"`
+	Language    string   `json:"language" binding:"required"`
+	Limit       int      `json:"limit"` // Results across all repositories (default 10)
+	IncludeCode bool     `json:"include_code"`
+	MinScore    float32  `json:"min_score"`
+	Dedup       bool     `json:"dedup"` // Collapse overlapping ranges of the same file in the same repository
+}
+
+type SearchAcrossReposResponse struct {
+	RepoNames []string            `json:"repo_names"`
+	Query     QueryInfo           `json:"query"`
+	Results   []SimilarCodeResult `json:"results"` // Ranked by score across all repositories
+	Success   bool                `json:"success"`
+	Message   string              `json:"message,omitempty"`
+}
+
+// FindDuplicatesRequest finds near-duplicate functions in a repository's
+// collection
+type FindDuplicatesRequest struct {
+	RepoName       string `json:"repo_name" binding:"required"`
+	CollectionName string `json:"collection_name"` // Defaults to repo_name
+	// MinSimilarity is the score two functions must reach to be grouped
+	// (default 0.95)
+	MinSimilarity *float32 `json:"min_similarity"`
+}
+
+type FindDuplicatesResponse struct {
+	RepoName       string             `json:"repo_name"`
+	CollectionName string             `json:"collection_name"`
+	MinSimilarity  float32            `json:"min_similarity"`
+	Clusters       []DuplicateCluster `json:"clusters"`
+	Success        bool               `json:"success"`
+	Message        string             `json:"message,omitempty"`
+}
+
+// DuplicateCluster is a group of near-identical functions
+type DuplicateCluster struct {
+	Chunks        []*CodeChunk `json:"chunks"`
+	MaxSimilarity float32      `json:"max_similarity"` // Highest score between two functions of the cluster
 }
 
 // N-gram API models
 
 type ProcessNGramRequest struct {
-	RepoName string `json:"repo_name" binding:"required"`
-	N        int    `json:"n"`        // N-gram size (default: 3)
-	Override bool   `json:"override"` // Force rebuild even if saved model exists
+	RepoName  string `json:"repo_name" binding:"required"`
+	N         int    `json:"n"`          // N-gram size (default: 3)
+	MinTokens int    `json:"min_tokens"` // Files with fewer tokens are kept out of the model (default: no minimum)
+	Override  bool   `json:"override"`   // Force rebuild even if saved model exists
+	Group     string `json:"group"`      // Append to this shared multi-repo corpus instead of a per-repo model
 }
 
 type ProcessNGramResponse struct {
 	RepoName       string  `json:"repo_name"`
+	Group          string  `json:"group,omitempty"` // Stats below are for the whole group
 	N              int     `json:"n"`
 	TotalFiles     int     `json:"total_files"`
 	TotalTokens    int     `json:"total_tokens"`
@@ -322,6 +467,15 @@ type ProcessNGramResponse struct {
 	Message        string  `json:"message,omitempty"`
 }
 
+// NGramProgressEvent is the data of a "progress" event streamed by
+// processNGram/stream while the repository is walked
+type NGramProgressEvent struct {
+	FilesProcessed int     `json:"files_processed"`
+	TotalFiles     int     `json:"total_files"`            // Files the walk may add, counted before it starts
+	CurrentFile    string  `json:"current_file,omitempty"` // File most recently added
+	Percent        float64 `json:"percent"`
+}
+
 type GetNGramStatsRequest struct {
 	RepoName string `json:"repo_name" binding:"required"`
 }
@@ -330,6 +484,7 @@ type GetNGramStatsResponse struct {
 	RepoName       string         `json:"repo_name"`
 	N              int            `json:"n"`
 	TotalFiles     int            `json:"total_files"`
+	SmallFiles     int            `json:"small_files"` // Files below min_tokens, excluded from the model and stats
 	TotalTokens    int            `json:"total_tokens"`
 	VocabularySize int            `json:"vocabulary_size"`
 	NGramCount     int            `json:"ngram_count"`
@@ -348,6 +503,69 @@ type GetFileEntropyResponse struct {
 	Entropy  float64 `json:"entropy"`
 }
 
+type RecomputeNGramEntropyRequest struct {
+	RepoName      string `json:"repo_name" binding:"required"`
+	PruneMinCount int64  `json:"prune_min_count"` // Prune n-grams seen fewer times first (0 = no pruning)
+}
+
+type RecomputeNGramEntropyResponse struct {
+	RepoName       string         `json:"repo_name"`
+	TotalFiles     int            `json:"total_files"`
+	TotalTokens    int            `json:"total_tokens"`
+	VocabularySize int            `json:"vocabulary_size"`
+	NGramCount     int            `json:"ngram_count"`
+	AverageEntropy float64        `json:"average_entropy"`
+	EntropyStdDev  float64        `json:"entropy_std_dev"`
+	EntropyMin     float64        `json:"entropy_min"`
+	EntropyMax     float64        `json:"entropy_max"`
+	LanguageCounts map[string]int `json:"language_counts"`
+}
+
+type TopSurprisingFilesRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+	N        int    `json:"n"` // Number of files to return (default: 10)
+}
+
+type TopSurprisingFilesResponse struct {
+	RepoName string           `json:"repo_name"`
+	Files    []SurprisingFile `json:"files"` // Highest entropy first
+}
+
+type SurprisingFile struct {
+	FilePath   string  `json:"file_path"`
+	Entropy    float64 `json:"entropy"`
+	TokenCount int     `json:"token_count"`
+}
+
+// DebugStatsResponse reports runtime state of the services held by the controller
+type DebugStatsResponse struct {
+	Repos           []RepoDebugStats `json:"repos"`              // Repositories with an n-gram model loaded
+	FileIDCacheSize int              `json:"file_id_cache_size"` // Cached code graph file paths (0 when the graph is disabled)
+	ActiveJobs      map[string]int   `json:"active_jobs"`        // Running heavy jobs by kind
+	JobSlotsInUse   int              `json:"job_slots_in_use"`
+	JobSlots        int              `json:"job_slots"`
+}
+
+type RepoDebugStats struct {
+	RepoName       string           `json:"repo_name"`
+	ActiveJob      string           `json:"active_job,omitempty"` // Heavy job currently running for the repo
+	TotalFiles     int              `json:"total_files"`
+	SmallFiles     int              `json:"small_files"`
+	TotalTokens    int              `json:"total_tokens"`
+	VocabularySize int              `json:"vocabulary_size"`
+	NGramCount     int              `json:"ngram_count"`
+	AverageEntropy float64          `json:"average_entropy"`
+	Memory         NGramMemoryStats `json:"memory"`
+}
+
+// NGramMemoryStats is the estimated footprint of a repository's global n-gram model
+type NGramMemoryStats struct {
+	VocabularyNodes int64 `json:"vocabulary_nodes"`
+	NGramNodes      int64 `json:"ngram_nodes"`
+	ContextNodes    int64 `json:"context_nodes"`
+	TotalBytes      int64 `json:"total_bytes"`
+}
+
 type AnalyzeCodeRequest struct {
 	RepoName string `json:"repo_name" binding:"required"`
 	Language string `json:"language" binding:"required"`
@@ -362,6 +580,33 @@ type AnalyzeCodeResponse struct {
 	Perplexity float64 `json:"perplexity"`
 }
 
+type AnalyzeDiffRequest struct {
+	RepoName string `json:"repo_name" binding:"required"`
+}
+
+type AnalyzeDiffResponse struct {
+	RepoName string             `json:"repo_name"`
+	Files    []DiffFileAnalysis `json:"files"`
+}
+
+// DiffFileAnalysis scores the lines added to one file in the working tree
+type DiffFileAnalysis struct {
+	FilePath   string             `json:"file_path"`
+	Language   string             `json:"language"`
+	TokenCount int                `json:"token_count"`
+	Entropy    float64            `json:"entropy"`
+	ZScore     float64            `json:"z_score"`
+	Hunks      []DiffHunkAnalysis `json:"hunks"`
+}
+
+type DiffHunkAnalysis struct {
+	StartLine  int     `json:"start_line"`
+	LineCount  int     `json:"line_count"`
+	TokenCount int     `json:"token_count"`
+	Entropy    float64 `json:"entropy"`
+	ZScore     float64 `json:"z_score"`
+}
+
 type CalculateZScoreRequest struct {
 	RepoName string `json:"repo_name" binding:"required"`
 	Language string `json:"language" binding:"required"`
@@ -398,6 +643,54 @@ type ZScoreInterpretation struct {
 	Level       string  `json:"level"` // "very_low", "low", "normal", "high", "very_high"
 	Description string  `json:"description"`
 	Percentile  float64 `json:"percentile"` // Approximate percentile in corpus
+	Scale       string  `json:"scale"`      // Name of the scale the z-score was interpreted on
+}
+
+type CompareNGramRequest struct {
+	RepoA string `json:"repo_a" binding:"required"`
+	RepoB string `json:"repo_b" binding:"required"`
+}
+
+type CompareNGramResponse struct {
+	RepoA               string          `json:"repo_a"`
+	RepoB               string          `json:"repo_b"`
+	AUnderB             NGramDivergence `json:"a_under_b"`
+	BUnderA             NGramDivergence `json:"b_under_a"`
+	SymmetricDivergence float64         `json:"symmetric_divergence"`
+}
+
+type NGramDivergence struct {
+	SourceRepo     string  `json:"source_repo"`
+	ModelRepo      string  `json:"model_repo"`
+	FilesCompared  int     `json:"files_compared"`
+	TokensCompared int     `json:"tokens_compared"`
+	SelfEntropy    float64 `json:"self_entropy"`
+	CrossEntropy   float64 `json:"cross_entropy"`
+	Divergence     float64 `json:"divergence"` // cross_entropy - self_entropy
+}
+
+type NGramAnomaliesRequest struct {
+	RepoName  string   `json:"repo_name" binding:"required"`
+	MinZScore *float64 `json:"min_zscore"` // Report functions at or above this z-score (default: 2.0)
+}
+
+type NGramAnomaliesResponse struct {
+	RepoName  string         `json:"repo_name"`
+	MinZScore float64        `json:"min_zscore"`
+	Functions []NGramAnomaly `json:"functions"` // Highest z-score first
+}
+
+// NGramAnomaly is a function whose code is unusually surprising under the repository's model
+type NGramAnomaly struct {
+	NodeID     int64   `json:"node_id"`
+	Name       string  `json:"name"`
+	FilePath   string  `json:"file_path"`
+	Language   string  `json:"language"`
+	StartLine  int     `json:"start_line"`
+	EndLine    int     `json:"end_line"`
+	TokenCount int     `json:"token_count"`
+	Entropy    float64 `json:"entropy"`
+	ZScore     float64 `json:"z_score"`
 }
 
 func (fd *FunctionDependency) IsIn(rng *base.Range) bool {
@@ -290,7 +290,7 @@ func (gv *GoVisitor) handleInterfaceType(ctx context.Context, tsNode *tree_sitte
 		clsName = gv.translate.GetTreeNodeName(typeId)
 	}
 
-	return gv.translate.HandleClass(ctx, scopeID, tsNode, clsName, methods, nil)
+	return gv.translate.HandleInterface(ctx, scopeID, tsNode, clsName, methods)
 }
 
 func (gv *GoVisitor) handleReturnStatement(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
@@ -5,6 +5,7 @@ import (
 	"bot-go/internal/model/ast"
 	"bot-go/internal/service/codegraph"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -20,6 +21,27 @@ import (
 	"go.uber.org/zap"
 )
 
+// ErrSyntax matches the *SyntaxError returned when a file parses with syntax
+// errors
+var ErrSyntax = errors.New("syntax error")
+
+// SyntaxError reports the location of the first ERROR or MISSING node of a file
+// that otherwise parsed. The graph is still built from the parts of the tree
+// that did parse, so the file is indexed; callers should treat it as a warning.
+type SyntaxError struct {
+	Line   int // 1-based
+	Column int // 1-based
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at line %d, column %d", ErrSyntax, e.Line, e.Column)
+}
+
+// Is makes errors.Is(err, ErrSyntax) match a *SyntaxError
+func (e *SyntaxError) Is(target error) bool {
+	return target == ErrSyntax
+}
+
 type LanguageType int
 
 const (
@@ -251,9 +273,31 @@ func (fp *FileParser) ParseAndTraverseWithContent(ctx context.Context, repo *con
 		content := PrintSyntaxTree(ctx, rootNode, translator.FileContent)
 		fp.logger.Info("Syntax Tree: " + filePath + "\n" + content)
 	}
+
+	if errNode := firstSyntaxError(rootNode); errNode != nil {
+		pos := errNode.StartPosition()
+		return &SyntaxError{Line: int(pos.Row) + 1, Column: int(pos.Column) + 1}
+	}
 	return nil
 }
 
+// firstSyntaxError returns the first ERROR or MISSING node below node, or nil
+// if the subtree parsed cleanly
+func firstSyntaxError(node *tree_sitter.Node) *tree_sitter.Node {
+	if !node.HasError() {
+		return nil
+	}
+	if node.IsError() || node.IsMissing() {
+		return node
+	}
+	for i := uint(0); i < node.ChildCount(); i++ {
+		if errNode := firstSyntaxError(node.Child(i)); errNode != nil {
+			return errNode
+		}
+	}
+	return node
+}
+
 func (fp *FileParser) ShouldSkipFile(ctx context.Context, repo *config.Repository, info os.FileInfo, filePath string) bool {
 	// Skip common directories and files that shouldn't be parsed
 	skipPaths := []string{
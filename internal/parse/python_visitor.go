@@ -3,6 +3,7 @@ package parse
 import (
 	"bot-go/internal/model/ast"
 	"context"
+	"strings"
 
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
 	"go.uber.org/zap"
@@ -34,6 +35,8 @@ func (pv *PythonVisitor) TraverseNode(ctx context.Context, tsNode *tree_sitter.N
 		return pv.translate.HandleBlock(ctx, tsNode, scopeID)
 	case "class_definition":
 		return pv.handleClassDefinition(ctx, tsNode, scopeID)
+	case "decorated_definition":
+		return pv.handleDecoratedDefinition(ctx, tsNode, scopeID)
 	case "return_statement":
 		return pv.handleReturnStatement(ctx, tsNode, scopeID)
 	case "call":
@@ -50,6 +53,10 @@ func (pv *PythonVisitor) TraverseNode(ctx context.Context, tsNode *tree_sitter.N
 		return pv.handleWhileStatement(ctx, tsNode, scopeID)
 	case "assignment":
 		return pv.handleAssignment(ctx, tsNode, scopeID)
+	case "import_statement":
+		return pv.handleImportStatement(ctx, tsNode, scopeID)
+	case "import_from_statement":
+		return pv.handleImportFromStatement(ctx, tsNode, scopeID)
 	/*
 
 		case "expression_statement":
@@ -98,10 +105,165 @@ func (pv *PythonVisitor) handleClassDefinition(ctx context.Context, tsNode *tree
 	var methods []*tree_sitter.Node
 	if body != nil {
 		methods = pv.translate.TreeChildrenByKind(body, "function_definition")
+		// Decorated methods such as properties are wrapped in a decorated_definition
+		for _, decorated := range pv.translate.TreeChildrenByKind(body, "decorated_definition") {
+			if definition := pv.translate.TreeChildByFieldName(decorated, "definition"); definition != nil && definition.Kind() == "function_definition" {
+				methods = append(methods, decorated)
+			}
+		}
+	}
+	if pv.isAbstractClass(tsNode, body) {
+		return pv.translate.HandleAbstractClass(ctx, scopeID, tsNode, "", methods, nil)
 	}
 	return pv.translate.HandleClass(ctx, scopeID, tsNode, "", methods, nil)
 }
 
+// isAbstractClass reports whether a class derives from abc.ABC, uses the
+// ABCMeta metaclass or declares an abstract method
+func (pv *PythonVisitor) isAbstractClass(tsNode *tree_sitter.Node, body *tree_sitter.Node) bool {
+	if superclasses := pv.translate.TreeChildByFieldName(tsNode, "superclasses"); superclasses != nil {
+		for _, base := range pv.translate.NamedChildren(superclasses) {
+			switch base.Kind() {
+			case "identifier", "attribute":
+				if strings.TrimPrefix(pv.translate.String(base), "abc.") == "ABC" {
+					return true
+				}
+			case "keyword_argument":
+				name := pv.translate.TreeChildByFieldName(base, "name")
+				value := pv.translate.TreeChildByFieldName(base, "value")
+				if name != nil && value != nil && pv.translate.String(name) == "metaclass" &&
+					strings.TrimPrefix(pv.translate.String(value), "abc.") == "ABCMeta" {
+					return true
+				}
+			}
+		}
+	}
+	if body == nil {
+		return false
+	}
+	for _, decorated := range pv.translate.TreeChildrenByKind(body, "decorated_definition") {
+		for _, decorator := range pv.translate.TreeChildrenByKind(decorated, "decorator") {
+			text := strings.TrimSpace(strings.TrimPrefix(pv.translate.String(decorator), "@"))
+			if strings.HasPrefix(strings.TrimPrefix(text, "abc."), "abstract") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleImportStatement processes "import a.b" and "import a.b as c"
+// Handles:
+//   - Plain imports: import os.path -> symbol name is "os"
+//   - Aliased imports: import numpy as np -> symbol name is "np"
+func (pv *PythonVisitor) handleImportStatement(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	for _, nameNode := range pv.translate.TreeChildrenByFieldName(tsNode, "name") {
+		switch nameNode.Kind() {
+		case "dotted_name":
+			importPath := pv.translate.String(nameNode)
+			symbolName, _, _ := strings.Cut(importPath, ".")
+			pv.createImport(ctx, nameNode, symbolName, importPath, scopeID)
+		case "aliased_import":
+			module := pv.translate.TreeChildByFieldName(nameNode, "name")
+			alias := pv.translate.TreeChildByFieldName(nameNode, "alias")
+			if module != nil && alias != nil {
+				pv.createImport(ctx, nameNode, pv.translate.String(alias), pv.translate.String(module), scopeID)
+			}
+		}
+	}
+	return ast.InvalidNodeID
+}
+
+// handleImportFromStatement processes "from m import x"
+// Handles:
+//   - Named imports: from pkg.mod import x -> symbol "x", import path "pkg.mod"
+//   - Aliased imports: from pkg import x as y -> symbol "y", import path "pkg"
+//   - Relative imports: from ..base import x -> import path "..base"
+//   - Bare relative imports: from . import mod -> import path ".mod"
+//   - Wildcard imports: from pkg import * -> no symbol, import path "pkg"
+func (pv *PythonVisitor) handleImportFromStatement(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	moduleNode := pv.translate.TreeChildByFieldName(tsNode, "module_name")
+	if moduleNode == nil {
+		return ast.InvalidNodeID
+	}
+	module := pv.translate.String(moduleNode)
+
+	if wildcard := pv.translate.TreeChildByKind(tsNode, "wildcard_import"); wildcard != nil {
+		pv.createImport(ctx, tsNode, "", module, scopeID)
+		return ast.InvalidNodeID
+	}
+
+	for _, nameNode := range pv.translate.TreeChildrenByFieldName(tsNode, "name") {
+		var name, symbolName string
+		switch nameNode.Kind() {
+		case "dotted_name":
+			name = pv.translate.String(nameNode)
+			symbolName = name
+		case "aliased_import":
+			original := pv.translate.TreeChildByFieldName(nameNode, "name")
+			alias := pv.translate.TreeChildByFieldName(nameNode, "alias")
+			if original == nil || alias == nil {
+				continue
+			}
+			name = pv.translate.String(original)
+			symbolName = pv.translate.String(alias)
+		default:
+			continue
+		}
+
+		importPath := module
+		// "from . import mod" imports the module itself
+		if strings.Trim(module, ".") == "" {
+			importPath = module + name
+		}
+		pv.createImport(ctx, nameNode, symbolName, importPath, scopeID)
+	}
+	return ast.InvalidNodeID
+}
+
+// createImport writes an Import node for importPath and, when symbolName is
+// set, binds it in the current scope so uses of the name resolve to it
+func (pv *PythonVisitor) createImport(ctx context.Context, tsNode *tree_sitter.Node, symbolName, importPath string, scopeID ast.NodeID) ast.NodeID {
+	if importPath == "" {
+		return ast.InvalidNodeID
+	}
+
+	name := symbolName
+	if name == "" {
+		name = importPath
+	}
+	importNode := ast.NewNode(
+		pv.translate.NextNodeID(),
+		ast.NodeTypeImport,
+		pv.translate.FileID,
+		name,
+		pv.translate.ToRange(tsNode),
+		pv.translate.Version,
+		scopeID,
+	)
+	importNode.MetaData = map[string]any{
+		"importPath": importPath,
+	}
+	pv.translate.CodeGraph.CreateImport(ctx, importNode)
+
+	if symbolName != "" {
+		pv.translate.CurrentScope.AddSymbol(NewSymbol(importNode))
+	}
+	pv.translate.Nodes[importNode.ID] = importNode
+
+	return importNode.ID
+}
+
+// handleDecoratedDefinition traverses the decorators of a function or class
+// and returns the node of the definition they decorate. The definition reads
+// its decorators back from its parent.
+func (pv *PythonVisitor) handleDecoratedDefinition(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
+	for _, decorator := range pv.translate.TreeChildrenByKind(tsNode, "decorator") {
+		pv.translate.TraverseChildren(ctx, decorator, scopeID)
+	}
+	return pv.TraverseNode(ctx, pv.translate.TreeChildByFieldName(tsNode, "definition"), scopeID)
+}
+
 func (pv *PythonVisitor) handleReturnStatement(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
 	if tsNode.ChildCount() < 2 {
 		return ast.InvalidNodeID
@@ -122,20 +284,29 @@ func (pv *PythonVisitor) handleCall(ctx context.Context, tsNode *tree_sitter.Nod
 }
 
 func (pv *PythonVisitor) handleAttribute(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
-	var names []*tree_sitter.Node
+	names := pv.attributeNames(tsNode, nil)
+	resolvedNodeId := pv.translate.ResolveNameChain(ctx, names, scopeID)
+	if pv.translate.CurrentScope.IsRhs() && resolvedNodeId != ast.InvalidNodeID {
+		pv.translate.CurrentScope.AddRhsVar(resolvedNodeId)
+	}
+	return resolvedNodeId
+}
 
+// attributeNames appends the names of an attribute chain to names, flattening
+// nested attributes so that self.order.total yields self, order and total
+func (pv *PythonVisitor) attributeNames(tsNode *tree_sitter.Node, names []*tree_sitter.Node) []*tree_sitter.Node {
 	for i := uint(0); i < tsNode.ChildCount(); i++ {
 		child := tsNode.Child(i)
-		if child.Kind() == "." {
+		switch child.Kind() {
+		case ".":
 			continue
+		case "attribute":
+			names = pv.attributeNames(child, names)
+		default:
+			names = append(names, child)
 		}
-		names = append(names, child)
-	}
-	resolvedNodeId := pv.translate.ResolveNameChain(ctx, names, scopeID)
-	if pv.translate.CurrentScope.IsRhs() && resolvedNodeId != ast.InvalidNodeID {
-		pv.translate.CurrentScope.AddRhsVar(resolvedNodeId)
 	}
-	return resolvedNodeId
+	return names
 }
 
 func (pv *PythonVisitor) handleIfStatement(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
@@ -247,6 +247,18 @@ func (t *TranslateFromSyntaxTree) TreeChildByFieldName(node *tree_sitter.Node, f
 	return nil
 }
 
+// TreeChildrenByFieldName returns every child of node stored under fieldName,
+// for fields such as Python's import names that repeat
+func (t *TranslateFromSyntaxTree) TreeChildrenByFieldName(node *tree_sitter.Node, fieldName string) []*tree_sitter.Node {
+	var children []*tree_sitter.Node
+	for i := uint(0); i < node.ChildCount(); i++ {
+		if node.FieldNameForChild(uint32(i)) == fieldName {
+			children = append(children, node.Child(i))
+		}
+	}
+	return children
+}
+
 func (t *TranslateFromSyntaxTree) SubtreeNodeByKind(node *tree_sitter.Node, kind string) *tree_sitter.Node {
 	if node == nil {
 		return nil
@@ -427,6 +439,11 @@ func (t *TranslateFromSyntaxTree) CreateFunction(ctx context.Context,
 	funcNode := t.NewNode(
 		ast.NodeTypeFunction, funcName, t.ToRange(fn), scopeID,
 	)
+	funcNode.MetaData = t.functionSignature(fn)
+	// Short-circuit operators are not graph nodes; record them for complexity
+	if logicalOps := countLogicalOperators(body); logicalOps > 0 {
+		funcNode.MetaData["logical_operators"] = logicalOps
+	}
 	t.CodeGraph.CreateFunction(ctx, funcNode)
 
 	t.PushScope(false)
@@ -450,6 +467,73 @@ func (t *TranslateFromSyntaxTree) CreateFunction(ctx context.Context,
 	return funcNode.ID
 }
 
+// returnTypeFields are the fields holding a function's declared return type:
+// Go's result and the return_type of Python and TypeScript
+var returnTypeFields = []string{"result", "return_type"}
+
+// functionSignature returns the metadata describing fn's signature as written
+// in the source: its parameter list, its decorators and, when declared, its
+// return type. Whitespace is collapsed so signatures can be matched as
+// single-line text.
+func (t *TranslateFromSyntaxTree) functionSignature(fn *tree_sitter.Node) map[string]any {
+	metadata := make(map[string]any)
+	if decorators := t.decorators(fn); len(decorators) > 0 {
+		metadata["decorators"] = decorators
+	}
+	if params := t.TreeChildByFieldName(fn, "parameters"); params != nil {
+		metadata["params"] = strings.Join(strings.Fields(t.String(params)), " ")
+	}
+	for _, field := range returnTypeFields {
+		if result := t.TreeChildByFieldName(fn, field); result != nil {
+			// A TypeScript type annotation includes its leading colon
+			returnType := strings.TrimSpace(strings.TrimPrefix(t.String(result), ":"))
+			metadata["return_type"] = strings.Join(strings.Fields(returnType), " ")
+			break
+		}
+	}
+	return metadata
+}
+
+// decorators returns the decorators applied to fn by an enclosing Python
+// decorated_definition, without their "@"
+func (t *TranslateFromSyntaxTree) decorators(fn *tree_sitter.Node) []string {
+	parent := fn.Parent()
+	if parent == nil || parent.Kind() != "decorated_definition" {
+		return nil
+	}
+	var decorators []string
+	for _, decorator := range t.TreeChildrenByKind(parent, "decorator") {
+		text := strings.TrimSpace(strings.TrimPrefix(t.String(decorator), "@"))
+		decorators = append(decorators, strings.Join(strings.Fields(text), " "))
+	}
+	return decorators
+}
+
+// logicalOperatorKinds are the short-circuit boolean operators of the
+// supported grammars
+var logicalOperatorKinds = map[string]bool{"&&": true, "||": true, "and": true, "or": true}
+
+// countLogicalOperators counts the short-circuit boolean operators under node,
+// including those of nested functions, matching the CONTAINS* scope used for
+// decision points
+func countLogicalOperators(node *tree_sitter.Node) int {
+	if node == nil {
+		return 0
+	}
+	if node.ChildCount() == 0 {
+		if !node.IsNamed() && logicalOperatorKinds[node.Kind()] {
+			return 1
+		}
+		return 0
+	}
+
+	count := 0
+	for i := uint(0); i < node.ChildCount(); i++ {
+		count += countLogicalOperators(node.Child(i))
+	}
+	return count
+}
+
 func (t *TranslateFromSyntaxTree) HandleBlock(ctx context.Context, tsNode *tree_sitter.Node, scopeID ast.NodeID) ast.NodeID {
 	blockNode := t.NewNode(
 		ast.NodeTypeBlock, "", t.ToRange(tsNode), scopeID,
@@ -557,6 +641,36 @@ func (t *TranslateFromSyntaxTree) HandleClass(ctx context.Context,
 	name string,
 	methods []*tree_sitter.Node,
 	fields []*tree_sitter.Node) ast.NodeID {
+	return t.handleClass(ctx, scopeID, cls, name, methods, fields, nil)
+}
+
+// HandleInterface is HandleClass for interfaces, which are marked abstract
+func (t *TranslateFromSyntaxTree) HandleInterface(ctx context.Context,
+	scopeID ast.NodeID,
+	cls *tree_sitter.Node,
+	name string,
+	methods []*tree_sitter.Node) ast.NodeID {
+	return t.handleClass(ctx, scopeID, cls, name, methods, nil, map[string]any{"abstract": true})
+}
+
+// HandleAbstractClass is HandleClass for classes the language marks abstract
+// through other means than an interface declaration
+func (t *TranslateFromSyntaxTree) HandleAbstractClass(ctx context.Context,
+	scopeID ast.NodeID,
+	cls *tree_sitter.Node,
+	name string,
+	methods []*tree_sitter.Node,
+	fields []*tree_sitter.Node) ast.NodeID {
+	return t.handleClass(ctx, scopeID, cls, name, methods, fields, map[string]any{"abstract": true})
+}
+
+func (t *TranslateFromSyntaxTree) handleClass(ctx context.Context,
+	scopeID ast.NodeID,
+	cls *tree_sitter.Node,
+	name string,
+	methods []*tree_sitter.Node,
+	fields []*tree_sitter.Node,
+	metaData map[string]any) ast.NodeID {
 	className := name
 	if className == "" {
 		className = t.GetTreeNodeName(cls)
@@ -568,6 +682,7 @@ func (t *TranslateFromSyntaxTree) HandleClass(ctx context.Context,
 	classNode := t.NewNode(
 		ast.NodeTypeClass, className, t.ToRange(cls), scopeID,
 	)
+	classNode.MetaData = metaData
 	t.CodeGraph.CreateClass(ctx, classNode)
 
 	t.PushScope(false)
@@ -0,0 +1,123 @@
+package codegraph_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/signals"
+	"bot-go/internal/signals/cohesion"
+	"bot-go/internal/signals/size"
+	"bot-go/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+// classNode returns the node of the class with the given name
+func classNode(t *testing.T, graph *testutil.GraphRecorder, name string) *ast.Node {
+	t.Helper()
+	for _, class := range graph.Nodes("Class") {
+		if class["name"] == name {
+			return &ast.Node{ID: ast.NodeID(class["id"].(int64)), NodeType: ast.NodeTypeClass,
+				FileID: int32(class["fileId"].(int64)), Name: name}
+		}
+	}
+	t.Fatalf("class %s not indexed", name)
+	return nil
+}
+
+func TestNOMAndNOFFromIndexedRepository(t *testing.T) {
+	files := map[string]string{
+		"io/reader.py": `class Reader:
+    def __init__(self, path):
+        self.path = path
+        self.buffer = None
+
+    def open(self):
+        self.buffer = open(self.path).read()
+        self.validate()
+
+    def validate(self):
+        return self.buffer is not None
+
+    def close(self):
+        self.buffer = None
+        self.parser.reset()
+`,
+	}
+	cg, graph := indexRepository(t, "demo", files)
+	graph.ReadFunc = answerClassReads(graph)
+	sctx := &signals.SignalContext{CodeGraph: cg, Logger: zap.NewNop()}
+
+	classInfo, err := signals.ExtractClass(context.Background(), classNode(t, graph, "Reader"), "io/reader.py", sctx)
+	if err != nil {
+		t.Fatalf("ExtractClass: %v", err)
+	}
+
+	// Every access to a field is its own Field node; fields are told apart by
+	// name, and self.validate() is a call rather than a field
+	var fields []string
+	for _, field := range classInfo.Fields {
+		fields = append(fields, field.Name)
+	}
+	if want := []string{"path", "buffer", "parser"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %v, want %v", fields, want)
+	}
+
+	for _, tt := range []struct {
+		signal signals.ClassSignal
+		want   float64
+	}{
+		{size.NewNOMSignal(), 4},
+		{size.NewNOFSignal(), 3},
+	} {
+		name := tt.signal.Metadata().Name
+		result, err := tt.signal.ComputeClass(context.Background(), classInfo, sctx)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if result.Value != tt.want {
+			t.Errorf("%s = %v, want %v", name, result.Value, tt.want)
+		}
+	}
+}
+
+func TestLCOM4FromIndexedRepository(t *testing.T) {
+	// deposit and statement share history, rename and greeting share owner,
+	// and nothing links the two groups
+	files := map[string]string{
+		"bank/account.py": `class Account:
+    def deposit(self, amount):
+        self.balance += amount
+        self.history.append(amount)
+
+    def statement(self):
+        return list(self.history)
+
+    def rename(self, name):
+        self.owner = name
+
+    def greeting(self):
+        return "Hello " + self.owner
+`,
+	}
+	cg, graph := indexRepository(t, "demo", files)
+	graph.ReadFunc = answerClassReads(graph)
+	sctx := &signals.SignalContext{CodeGraph: cg, Logger: zap.NewNop()}
+
+	classInfo, err := signals.ExtractClass(context.Background(), classNode(t, graph, "Account"), "bank/account.py", sctx)
+	if err != nil {
+		t.Fatalf("ExtractClass: %v", err)
+	}
+	result, err := cohesion.NewLCOM4Signal().ComputeClass(context.Background(), classInfo, sctx)
+	if err != nil || result.Error != nil {
+		t.Fatalf("ComputeClass: %v, %v", err, result.Error)
+	}
+	if result.Value != 2 {
+		t.Errorf("LCOM4 = %v, want 2 (metadata %v)", result.Value, result.Metadata)
+	}
+	if fields := result.Metadata["fields"]; fields != 3 {
+		t.Errorf("fields = %v, want 3", fields)
+	}
+}
@@ -0,0 +1,125 @@
+package codegraph_test
+
+import (
+	"context"
+	"strings"
+
+	"bot-go/internal/testutil"
+)
+
+// answerClassReads answers the class queries of signal extraction and of
+// GetForeignAccesses and GetReceiverFieldAccesses from the recorded graph, the
+// way Neo4j would
+func answerClassReads(graph *testutil.GraphRecorder) testutil.GraphQueryFunc {
+	labels := make(map[int64]string)
+	for _, label := range []string{"FileScope", "ModuleScope", "Class", "Function", "Field", "FunctionCall", "Variable"} {
+		for _, n := range graph.Nodes(label) {
+			labels[n["id"].(int64)] = label
+		}
+	}
+	targets := func(label string, from int64) []int64 {
+		var ids []int64
+		for _, rel := range graph.Relations(label) {
+			if rel.ParentID == from {
+				ids = append(ids, rel.ChildID)
+			}
+		}
+		return ids
+	}
+	children := func(label string, from int64, childLabel string) []int64 {
+		var ids []int64
+		for _, id := range targets(label, from) {
+			if labels[id] == childLabel {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	owners := make(map[int64]int64)
+	for _, rel := range graph.Relations("CONTAINS") {
+		if labels[rel.ParentID] == "Class" {
+			owners[rel.ChildID] = rel.ParentID
+		}
+	}
+
+	return func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		var records []map[string]any
+		switch {
+		case strings.Contains(query, "CALLS_FUNCTION"):
+			classID := params["classId"].(int64)
+			var calls []int64
+			var walk func(id int64)
+			walk = func(id int64) {
+				for _, child := range targets("CONTAINS", id) {
+					if labels[child] == "FunctionCall" {
+						calls = append(calls, child)
+					}
+					walk(child)
+				}
+			}
+			for _, method := range children("CONTAINS", classID, "Function") {
+				walk(method)
+			}
+
+			seen := make(map[int64]bool)
+			for _, call := range calls {
+				for _, member := range children("CALLS_FUNCTION", call, "Function") {
+					owner, ok := owners[member]
+					if !ok || owner == classID || seen[member] {
+						continue
+					}
+					seen[member] = true
+					file := graph.Node(graph.Node(owner)["fileId"].(int64))
+					module := ""
+					for _, child := range children("CONTAINS", file["id"].(int64), "ModuleScope") {
+						module, _ = graph.Node(child)["name"].(string)
+					}
+					records = append(records, map[string]any{
+						"memberId": member, "memberName": graph.Node(member)["name"],
+						"ownerId": owner, "module": module, "path": file["path"],
+					})
+				}
+			}
+		case strings.Contains(query, "(r:Variable)-[:HAS_FIELD]->(f:Field)"):
+			classID := params["classId"].(int64)
+			methods := children("CONTAINS", classID, "Function")
+			methodNames := make(map[any]bool)
+			for _, method := range methods {
+				methodNames[graph.Node(method)["name"]] = true
+			}
+			receivers := make(map[any]bool)
+			for _, name := range params["receivers"].([]string) {
+				receivers[name] = true
+			}
+			for _, method := range methods {
+				for _, receiver := range children("CONTAINS", method, "Variable") {
+					if !receivers[graph.Node(receiver)["name"]] && len(targets("THIS", receiver)) == 0 {
+						continue
+					}
+					for _, field := range children("HAS_FIELD", receiver, "Field") {
+						if node := graph.Node(field); !methodNames[node["name"]] {
+							records = append(records, map[string]any{"methodId": method, "field": node})
+						}
+					}
+				}
+			}
+		case strings.Contains(query, "(m:Function)"):
+			for _, method := range children("CONTAINS", params["classId"].(int64), "Function") {
+				records = append(records, map[string]any{"m": graph.Node(method)})
+			}
+		case strings.Contains(query, "[:HAS_FIELD]->(child:Variable)"):
+			for _, field := range children("HAS_FIELD", params["parentId"].(int64), "Variable") {
+				records = append(records, map[string]any{"child": graph.Node(field)})
+			}
+		case strings.Contains(query, "[r:FUNCTION_ARG]->(to)"):
+			for _, arg := range targets("FUNCTION_ARG", params["fromId"].(int64)) {
+				records = append(records, map[string]any{"toId": arg})
+			}
+		case strings.Contains(query, "[r:FUNCTION_ARG]->(arg)"):
+			for _, arg := range targets("FUNCTION_ARG", params["functionId"].(int64)) {
+				records = append(records, map[string]any{"arg": graph.Node(arg)})
+			}
+		}
+		return records, nil
+	}
+}
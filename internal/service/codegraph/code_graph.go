@@ -3,8 +3,10 @@ package codegraph
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"sort"
 	"strings"
 	"sync"
@@ -12,6 +14,7 @@ import (
 
 	"bot-go/internal/config"
 	"bot-go/internal/model/ast"
+	"bot-go/internal/util"
 	"bot-go/pkg/lsp/base"
 
 	"go.uber.org/zap"
@@ -27,15 +30,28 @@ type CodeGraph struct {
 	config      *config.Config
 	logger      *zap.Logger
 	fileIDCache map[int32]string
+	// File scope properties copied onto every node of the file (fileScopeKeys)
+	fileProperties map[int32]map[string]any
+	cacheMutex     sync.RWMutex // Protects fileIDCache and fileProperties
 	// Batch writing support - file-level buffers for parallel processing
 	enableBatchWrites bool
 	batchSize         int
 	buffers           map[int32]*Buffer // Map: fileID -> buffer
 	bufferMutex       sync.Mutex        // Protects buffer maps
+	// Metadata keys written as top-level properties: FirstClassMetadata plus configured keys
+	firstClassMetadata map[string]bool
+	// Node types not written to the graph (CodeGraphConfig.SkipNodeTypes)
+	skippedNodeTypes map[ast.NodeType]bool
+	skippedNodes     map[ast.NodeID]*skippedNode
+	skippedByFile    map[int32][]ast.NodeID
+	skipMutex        sync.Mutex // Protects skippedNodes and skippedByFile
 }
 
 func NewCodeGraph(uri, username, password string, config *config.Config, logger *zap.Logger) (*CodeGraph, error) {
-	db, err := NewNeo4jDatabase(uri, username, password, logger)
+	neo4jConfig := config.Neo4j
+	neo4jConfig.URI, neo4jConfig.Username, neo4jConfig.Password = uri, username, password
+
+	db, err := NewNeo4jDatabase(neo4jConfig, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Neo4j database: %w", err)
 	}
@@ -46,6 +62,11 @@ func NewCodeGraph(uri, username, password string, config *config.Config, logger
 		return nil, fmt.Errorf("failed to verify database connectivity: %w", err)
 	}
 
+	return NewCodeGraphWithDatabase(db, config, logger), nil
+}
+
+// NewCodeGraphWithDatabase creates a CodeGraph on top of an already connected GraphDatabase
+func NewCodeGraphWithDatabase(db GraphDatabase, config *config.Config, logger *zap.Logger) *CodeGraph {
 	// Initialize batch writing configuration
 	enableBatch := config.CodeGraph.EnableBatchWrites
 	batchSize := config.CodeGraph.BatchSize
@@ -53,15 +74,40 @@ func NewCodeGraph(uri, username, password string, config *config.Config, logger
 		batchSize = 100 // default
 	}
 
-	return &CodeGraph{
-		db:                db,
-		config:            config,
-		logger:            logger,
-		fileIDCache:       make(map[int32]string),
-		enableBatchWrites: enableBatch,
-		batchSize:         batchSize,
-		buffers:           make(map[int32]*Buffer),
-	}, nil
+	cg := &CodeGraph{
+		db:                 db,
+		config:             config,
+		logger:             logger,
+		fileIDCache:        make(map[int32]string),
+		fileProperties:     make(map[int32]map[string]any),
+		enableBatchWrites:  enableBatch,
+		batchSize:          batchSize,
+		buffers:            make(map[int32]*Buffer),
+		firstClassMetadata: firstClassMetadataKeys(config.CodeGraph.FirstClassMetadata, logger),
+		skippedNodes:       make(map[ast.NodeID]*skippedNode),
+		skippedByFile:      make(map[int32][]ast.NodeID),
+	}
+	cg.skippedNodeTypes = cg.skipNodeTypes(config.CodeGraph.SkipNodeTypes)
+	return cg
+}
+
+// firstClassMetadataKeys merges the configured first-class metadata keys with
+// the built-in FirstClassMetadata. Keys become property names in Cypher
+// queries, so keys that are not plain identifiers are ignored, as are keys
+// that would overwrite the core node properties.
+func firstClassMetadataKeys(configured []string, logger *zap.Logger) map[string]bool {
+	keys := make(map[string]bool, len(FirstClassMetadata)+len(configured))
+	for key := range FirstClassMetadata {
+		keys[key] = true
+	}
+	for _, key := range configured {
+		if !propertyNamePattern.MatchString(key) || strings.HasPrefix(key, "md_") || coreNodeProperties[key] {
+			logger.Warn("Ignoring invalid first-class metadata key", zap.String("key", key))
+			continue
+		}
+		keys[key] = true
+	}
+	return keys
 }
 
 func (cg *CodeGraph) Close(ctx context.Context) error {
@@ -92,6 +138,7 @@ func (cg *CodeGraph) InitializeFileBuffers(fileID int32) {
 // CleanupFileBuffers flushes and removes buffers for a file after processing completes
 // This frees memory and ensures data is written to database
 func (cg *CodeGraph) CleanupFileBuffers(ctx context.Context, fileID int32) error {
+	cg.releaseSkippedNodes(fileID)
 	if !cg.enableBatchWrites {
 		return nil
 	}
@@ -312,17 +359,26 @@ func (cg *CodeGraph) recordToNode(record map[string]any) (*ast.Node, error) {
 		}
 	}
 
+	nodeName, ok := name.(string)
+	if !ok && name != nil {
+		cg.logger.Warn("Unexpected type for node name", zap.Any("value", name), zap.String("type", fmt.Sprintf("%T", name)))
+	}
+
 	node := &ast.Node{
 		ID:       ast.NodeID(cg.convertToInt64(id)),
 		NodeType: ast.NodeType(cg.convertToInt64(nodeType)),
 		FileID:   cg.convertToInt32(fileID),
-		Name:     name.(string),
+		Name:     nodeName,
 		Version:  cg.convertToInt32(version),
 		ScopeID:  ast.NodeID(cg.convertToInt64(scopeID)),
 	}
 
 	if rangeStr != nil {
-		node.Range = strToRange(rangeStr.(string))
+		if s, ok := rangeStr.(string); ok {
+			node.Range = strToRange(s)
+		} else {
+			cg.logger.Warn("Unexpected type for node range", zap.Any("value", rangeStr), zap.String("type", fmt.Sprintf("%T", rangeStr)))
+		}
 	}
 
 	if len(newMetadata) > 0 {
@@ -391,6 +447,11 @@ func (cg *CodeGraph) getNodeLabel(nodeType ast.NodeType) string {
 	}
 }
 
+// NodeLabel returns the graph label used for the given node type
+func (cg *CodeGraph) NodeLabel(nodeType ast.NodeType) string {
+	return cg.getNodeLabel(nodeType)
+}
+
 func (cg *CodeGraph) CreateFunction(ctx context.Context, node *ast.Node) error {
 	if node.NodeType != ast.NodeTypeFunction {
 		return fmt.Errorf("invalid node type: expected %d, got %d", ast.NodeTypeFunction, node.NodeType)
@@ -407,51 +468,15 @@ func (cg *CodeGraph) ReadFunction(ctx context.Context, nodeID ast.NodeID) (*ast.
 	return cg.readNodeByType(ctx, nodeID, ast.NodeTypeFunction)
 }
 
-/*
+// ReadFunctionArgs returns the parameters of a function in declaration order
 func (cg *CodeGraph) ReadFunctionArgs(ctx context.Context, functionNodeID ast.NodeID) ([]*ast.Node, error) {
-	session := cg.driver.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
-	defer session.Close(ctx)
-
-	nodesResult, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-		query := `
-			MATCH (f:Function {id: $functionId})-[:FUNCTION_ARG]->(arg)
-			RETURN arg
-			ORDER BY arg.position
-		`
-		parameters := map[string]any{
-			"functionId": int64(functionNodeID),
-		}
-
-		result, err := tx.Run(ctx, query, parameters)
-		if err != nil {
-			return nil, err
-		}
-
-		var nodes []*ast.Node
-		for result.Next(ctx) {
-			record := result.Record()
-			node, err := cg.recordToNode(record)
-			if err != nil {
-				return nil, err
-			}
-			nodes = append(nodes, node)
-		}
-
-		if err = result.Err(); err != nil {
-			return nil, err
-		}
-
-		return nodes, nil
-	})
-
-	if err != nil {
-		cg.logger.Error("Failed to read function arguments", zap.Int64("functionId", int64(functionNodeID)), zap.Error(err))
-		return nil, fmt.Errorf("failed to read function arguments: %w", err)
-	}
-
-	return nodesResult.([]*ast.Node), nil
+	query := `
+		MATCH (f:Function {id: $functionId})-[r:FUNCTION_ARG]->(arg)
+		RETURN arg
+		ORDER BY r.md_position
+	`
+	return cg.readNodesByQuery(ctx, "arg", query, map[string]any{"functionId": int64(functionNodeID)})
 }
-*/
 
 func (cg *CodeGraph) CreateFileScope(ctx context.Context, node *ast.Node) error {
 	if node.NodeType != ast.NodeTypeFileScope {
@@ -465,7 +490,10 @@ func (cg *CodeGraph) ReadFileScope(ctx context.Context, nodeID ast.NodeID) (*ast
 }
 
 func (cg *CodeGraph) GetFilePath(ctx context.Context, fileID int32) string {
-	if path, ok := cg.fileIDCache[fileID]; ok {
+	cg.cacheMutex.RLock()
+	path, ok := cg.fileIDCache[fileID]
+	cg.cacheMutex.RUnlock()
+	if ok {
 		return path
 	}
 
@@ -473,14 +501,33 @@ func (cg *CodeGraph) GetFilePath(ctx context.Context, fileID int32) string {
 	if err != nil {
 		return ""
 	}
-	path, ok := fs.MetaData["path"].(string)
+	path, ok = fs.MetaData["path"].(string)
 	if !ok {
 		return ""
 	}
+
+	// File scopes store repo-relative paths; report them in the configured form
+	repoRoot := ""
+	if repoName, ok := fs.MetaData["repo"].(string); ok {
+		if repo, err := cg.config.GetRepository(repoName); err == nil {
+			repoRoot = repo.Path
+		}
+	}
+	path = util.NewPathNormalizer(repoRoot, cg.config.App.AbsolutePaths).Normalize(path)
+
+	cg.cacheMutex.Lock()
 	cg.fileIDCache[fileID] = path
+	cg.cacheMutex.Unlock()
 	return path
 }
 
+// FileIDCacheSize returns the number of file IDs whose paths are cached
+func (cg *CodeGraph) FileIDCacheSize() int {
+	cg.cacheMutex.RLock()
+	defer cg.cacheMutex.RUnlock()
+	return len(cg.fileIDCache)
+}
+
 func (cg *CodeGraph) FindFileScopes(ctx context.Context, repoName, filePath string) ([]*ast.Node, error) {
 	params := map[string]any{
 		"repo": repoName,
@@ -617,17 +664,34 @@ func strToRange(s string) base.Range {
 
 var (
 	FirstClassMetadata = map[string]bool{
-		"fake":     true,
-		"nameID":   true,
-		"return":   true,
-		"repo":     true,
-		"path":     true,
-		"language": true,
+		"fake":        true,
+		"nameID":      true,
+		"return":      true,
+		"repo":        true,
+		"path":        true,
+		"language":    true,
+		"params":      true, // Function parameter list as written
+		"return_type": true, // Function return type as written
 	}
 )
 
+// propertyNamePattern matches metadata keys that are safe to use as unquoted property names
+var propertyNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// coreNodeProperties are the properties every node is written with, which
+// first-class metadata must not overwrite
+var coreNodeProperties = map[string]bool{
+	"id":       true,
+	"nodeType": true,
+	"fileId":   true,
+	"name":     true,
+	"range":    true,
+	"version":  true,
+	"scopeId":  true,
+}
+
 func (cg *CodeGraph) isFirstClassMetadata(key string) bool {
-	return FirstClassMetadata[key]
+	return cg.firstClassMetadata[key]
 }
 
 func (cg *CodeGraph) populateFirstClassMetadata(metadata map[string]any,
@@ -703,7 +767,51 @@ func (cg *CodeGraph) writeNodeReal(ctx context.Context, node *ast.Node) error {
 	return nil
 }
 
+// fileScopeKeys are the file scope metadata keys that writeNode copies onto
+// the other nodes of the file, so any node can be filtered by repository and
+// language without joining back to its file scope
+var fileScopeKeys = []string{"repo", "language"}
+
+// inheritFileProperties remembers the fileScopeKeys of a file scope and sets
+// them on later nodes of the same file that don't have their own value
+func (cg *CodeGraph) inheritFileProperties(node *ast.Node) {
+	if node.NodeType == ast.NodeTypeFileScope {
+		properties := make(map[string]any)
+		for _, key := range fileScopeKeys {
+			if value, ok := node.MetaData[key]; ok {
+				properties[key] = value
+			}
+		}
+		cg.cacheMutex.Lock()
+		cg.fileProperties[node.FileID] = properties
+		cg.cacheMutex.Unlock()
+		return
+	}
+
+	cg.cacheMutex.RLock()
+	properties := cg.fileProperties[node.FileID]
+	cg.cacheMutex.RUnlock()
+	if len(properties) == 0 {
+		return
+	}
+
+	if node.MetaData == nil {
+		node.MetaData = make(map[string]any, len(properties))
+	}
+	for key, value := range properties {
+		if _, ok := node.MetaData[key]; !ok {
+			node.MetaData[key] = value
+		}
+	}
+}
+
 func (cg *CodeGraph) writeNode(ctx context.Context, node *ast.Node) error {
+	if cg.skippedNodeTypes[node.NodeType] {
+		cg.skipNode(node)
+		return nil
+	}
+	cg.inheritFileProperties(node)
+
 	// If batch writes are enabled, buffer the node instead of writing immediately
 	if cg.enableBatchWrites {
 		fileID := node.FileID
@@ -1016,6 +1124,121 @@ func (cg *CodeGraph) readNodeByType(ctx context.Context, nodeID ast.NodeID, node
 	return nodes[0], nil
 }
 
+// MaxNameSearchResults caps the number of nodes returned by SearchNodesByName
+// and FindFunctionsBySignature
+const MaxNameSearchResults = 100
+
+// SearchNodesByName returns up to limit nodes of a type in a repository whose
+// name contains pattern, ignoring case, ordered by name. A non-empty language
+// keeps only nodes of files in that language. A limit outside
+// (0, MaxNameSearchResults] is treated as MaxNameSearchResults.
+func (cg *CodeGraph) SearchNodesByName(ctx context.Context, repoName, pattern, language string, nodeType ast.NodeType, limit int) ([]*ast.Node, error) {
+	if limit <= 0 || limit > MaxNameSearchResults {
+		limit = MaxNameSearchResults
+	}
+
+	params := map[string]any{
+		"repo":    repoName,
+		"pattern": pattern,
+		"limit":   int64(limit),
+	}
+	languageFilter := ""
+	if language != "" {
+		languageFilter = "WHERE f.language = $language"
+		params["language"] = language
+	}
+
+	// Repository and language are read from the file scope, since nodes
+	// indexed before they inherited these properties lack them
+	fileMatch := fmt.Sprintf("MATCH (n:%s {fileId: f.id})", cg.getNodeLabel(nodeType))
+	if nodeType == ast.NodeTypeFileScope {
+		fileMatch = "WITH f AS n"
+	}
+	query := fmt.Sprintf(`
+		MATCH (f:FileScope {repo: $repo})
+		%s
+		%s
+		WHERE toLower(n.name) CONTAINS toLower($pattern)
+		RETURN n
+		ORDER BY n.name
+		LIMIT $limit
+	`, languageFilter, fileMatch)
+	return cg.readNodesByQuery(ctx, "n", query, params)
+}
+
+// FunctionSignatureFilter selects functions by their signature as recorded
+// at parse time. Each non-empty pattern must be a case-insensitive substring of
+// the matching property; Language, when set, must match exactly.
+type FunctionSignatureFilter struct {
+	Name       string
+	Params     string // Matched against the parameter list, e.g. "ctx context.Context"
+	ReturnType string // Matched against the declared return type, e.g. "error"
+	Language   string
+}
+
+// signaturePatterns pairs the node properties with their filter patterns
+func (f FunctionSignatureFilter) signaturePatterns() []struct{ property, pattern string } {
+	return []struct{ property, pattern string }{
+		{"name", f.Name},
+		{"params", f.Params},
+		{"return_type", f.ReturnType},
+	}
+}
+
+// FindFunctionsBySignature returns up to limit functions in a repository
+// matching all patterns of the filter, ordered by name. Functions without a
+// declared return type only match an empty ReturnType pattern. A limit outside
+// (0, MaxNameSearchResults] is treated as MaxNameSearchResults.
+func (cg *CodeGraph) FindFunctionsBySignature(ctx context.Context, repoName string, filter FunctionSignatureFilter, limit int) ([]*ast.Node, error) {
+	if limit <= 0 || limit > MaxNameSearchResults {
+		limit = MaxNameSearchResults
+	}
+
+	params := map[string]any{"repo": repoName, "limit": int64(limit)}
+	conditions := []string{"n.repo = $repo"}
+	for _, p := range filter.signaturePatterns() {
+		if p.pattern == "" {
+			continue
+		}
+		conditions = append(conditions,
+			fmt.Sprintf("toLower(coalesce(n.%s, '')) CONTAINS toLower($%s)", p.property, p.property))
+		params[p.property] = p.pattern
+	}
+	if filter.Language != "" {
+		conditions = append(conditions, "n.language = $language")
+		params["language"] = filter.Language
+	}
+
+	query := fmt.Sprintf(`
+		MATCH (n:%s)
+		WHERE %s
+		RETURN n
+		ORDER BY n.name
+		LIMIT $limit
+	`, cg.getNodeLabel(ast.NodeTypeFunction), strings.Join(conditions, " AND "))
+	return cg.readNodesByQuery(ctx, "n", query, params)
+}
+
+// FindFunctionsByReturnType returns up to limit functions in a repository
+// whose declared return type contains returnPattern, ignoring case
+func (cg *CodeGraph) FindFunctionsByReturnType(ctx context.Context, repoName, returnPattern string, limit int) ([]*ast.Node, error) {
+	return cg.FindFunctionsBySignature(ctx, repoName, FunctionSignatureFilter{ReturnType: returnPattern}, limit)
+}
+
+// FindNodesByLanguage returns all nodes of a type in a repository that belong
+// to files in the given language
+func (cg *CodeGraph) FindNodesByLanguage(ctx context.Context, repoName, language string, nodeType ast.NodeType) ([]*ast.Node, error) {
+	return cg.readNodes(ctx, nodeType, map[string]any{
+		"repo":     repoName,
+		"language": language,
+	})
+}
+
+// FindFunctionsInRepo returns every function node of a repository
+func (cg *CodeGraph) FindFunctionsInRepo(ctx context.Context, repoName string) ([]*ast.Node, error) {
+	return cg.readNodes(ctx, ast.NodeTypeFunction, map[string]any{"repo": repoName})
+}
+
 func (cg *CodeGraph) FindNodesByNameAndTypeInFile(ctx context.Context, name string, nodeType ast.NodeType, fileID int32) ([]*ast.Node, error) {
 	return cg.readNodes(ctx, nodeType, map[string]any{
 		"name":   name,
@@ -1023,8 +1246,35 @@ func (cg *CodeGraph) FindNodesByNameAndTypeInFile(ctx context.Context, name stri
 	})
 }
 
+// ErrSelfContainment is returned when a containment relation would link a node to itself
+var ErrSelfContainment = errors.New("node cannot contain itself")
+
+// containmentLabels are the relations that nest one node inside another. A
+// self-loop in them is always a bug, whereas CALLS and similar relations may
+// legitimately point back at their source, e.g. for recursion.
+var containmentLabels = map[string]bool{
+	"CONTAINS":  true,
+	"HAS_FIELD": true,
+	"BODY":      true,
+}
+
+// checkRelation rejects relations that would corrupt the graph's structure
+func checkRelation(parentNodeID, childNodeID ast.NodeID, relationLabel string) error {
+	if parentNodeID == childNodeID && containmentLabels[relationLabel] {
+		return fmt.Errorf("%w: %s relation from node %d to itself", ErrSelfContainment, relationLabel, parentNodeID)
+	}
+	return nil
+}
+
+// CreateRelationReal writes a relation immediately. Relations are merged on
+// their endpoints and label, so repeating a relation updates its metadata
+// (such as a FUNCTION_ARG position) instead of adding a second edge.
 func (cg *CodeGraph) CreateRelationReal(ctx context.Context, parentNodeID, childNodeID ast.NodeID,
 	relationLabel string, metaData map[string]any, fileID int32) error {
+	if err := checkRelation(parentNodeID, childNodeID, relationLabel); err != nil {
+		return err
+	}
+
 	parameters := map[string]any{
 		"parentId": int64(parentNodeID),
 		"childId":  int64(childNodeID),
@@ -1066,9 +1316,30 @@ func (cg *CodeGraph) CreateRelationReal(ctx context.Context, parentNodeID, child
 	return nil
 }
 
+// CreateRelation creates a relation, buffering it when batch writes are enabled.
+// Self-loops are rejected for containment relations with ErrSelfContainment.
+// Relations touching skipped node types are re-pointed or dropped.
 func (cg *CodeGraph) CreateRelation(ctx context.Context, parentNodeID, childNodeID ast.NodeID,
 	relationLabel string, metaData map[string]any, fileID int32) error {
+	if err := checkRelation(parentNodeID, childNodeID, relationLabel); err != nil {
+		return err
+	}
+
+	if len(cg.skippedNodeTypes) == 0 {
+		return cg.writeRelation(ctx, parentNodeID, childNodeID, relationLabel, metaData, fileID)
+	}
+	parentNodeID, children := cg.resolveSkippedRelation(parentNodeID, childNodeID, relationLabel)
+	for _, child := range children {
+		if err := cg.writeRelation(ctx, parentNodeID, child, relationLabel, metaData, fileID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// writeRelation buffers or immediately writes a relation between persisted nodes
+func (cg *CodeGraph) writeRelation(ctx context.Context, parentNodeID, childNodeID ast.NodeID,
+	relationLabel string, metaData map[string]any, fileID int32) error {
 	// If batch writes are enabled, buffer the relation instead of writing immediately
 	if cg.enableBatchWrites {
 		// Only lock for map access - Go maps are not safe for concurrent reads/writes
@@ -1142,24 +1413,47 @@ func (cg *CodeGraph) GetNodesByType(ctx context.Context, nodeType ast.NodeType)
 
 // GetNodeByID returns a node by its ID
 func (cg *CodeGraph) GetNodeByID(ctx context.Context, nodeID ast.NodeID) (*ast.Node, error) {
-	// Try each node type until we find the node
-	nodeTypes := []ast.NodeType{
-		ast.NodeTypeClass,
-		ast.NodeTypeFunction,
-		ast.NodeTypeField,
-		ast.NodeTypeVariable,
-		ast.NodeTypeBlock,
-		ast.NodeTypeFileScope,
+	node, err := cg.FindNodeByID(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, fmt.Errorf("node with id %d not found", nodeID)
 	}
+	return node, nil
+}
 
-	for _, nodeType := range nodeTypes {
-		nodes, err := cg.readNodes(ctx, nodeType, map[string]any{"id": int64(nodeID)})
-		if err == nil && len(nodes) > 0 {
-			return nodes[0], nil
-		}
+// FindNodeByID returns the node with the given ID regardless of its type, or
+// nil if there is none. An error is returned only if the read fails.
+func (cg *CodeGraph) FindNodeByID(ctx context.Context, nodeID ast.NodeID) (*ast.Node, error) {
+	query := `
+		MATCH (n {id: $id})
+		RETURN n
+		LIMIT 1
+	`
+
+	nodes, err := cg.readNodesByQuery(ctx, "n", query, map[string]any{"id": int64(nodeID)})
+	if err != nil {
+		return nil, err
 	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	return nodes[0], nil
+}
 
-	return nil, fmt.Errorf("node with id %d not found", nodeID)
+// GetContainedNodes returns all nodes directly contained by a parent via CONTAINS, regardless of type
+func (cg *CodeGraph) GetContainedNodes(ctx context.Context, parentID ast.NodeID) ([]*ast.Node, error) {
+	query := `
+		MATCH (parent {id: $parentId})-[:CONTAINS]->(child)
+		RETURN child
+	`
+
+	nodes, err := cg.readNodesByQuery(ctx, "child", query, map[string]any{"parentId": int64(parentID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contained nodes: %w", err)
+	}
+	return nodes, nil
 }
 
 // RelationInfo represents a relationship between nodes
@@ -1167,6 +1461,7 @@ type RelationInfo struct {
 	FromNodeID ast.NodeID
 	ToNodeID   ast.NodeID
 	Label      string
+	Metadata   map[string]any // Relation metadata with the md_ prefix removed (e.g. position, condition)
 }
 
 // GetChildNodes returns all child nodes of a given parent via a relationship
@@ -1210,7 +1505,7 @@ func (cg *CodeGraph) GetChildNodes(ctx context.Context, parentID ast.NodeID, rel
 func (cg *CodeGraph) GetOutgoingRelations(ctx context.Context, fromNodeID ast.NodeID, relationLabel string) ([]RelationInfo, error) {
 	query := fmt.Sprintf(`
 		MATCH (from {id: $fromId})-[r:%s]->(to)
-		RETURN to.id as toId
+		RETURN to.id as toId, properties(r) as props
 	`, relationLabel)
 
 	records, err := cg.db.ExecuteRead(ctx, query, map[string]any{"fromId": int64(fromNodeID)})
@@ -1230,6 +1525,7 @@ func (cg *CodeGraph) GetOutgoingRelations(ctx context.Context, fromNodeID ast.No
 			FromNodeID: fromNodeID,
 			ToNodeID:   ast.NodeID(toNodeID),
 			Label:      relationLabel,
+			Metadata:   cg.relationMetadata(record["props"]),
 		})
 	}
 
@@ -1240,7 +1536,7 @@ func (cg *CodeGraph) GetOutgoingRelations(ctx context.Context, fromNodeID ast.No
 func (cg *CodeGraph) GetIncomingRelations(ctx context.Context, toNodeID ast.NodeID, relationLabel string) ([]RelationInfo, error) {
 	query := fmt.Sprintf(`
 		MATCH (from)-[r:%s]->(to {id: $toId})
-		RETURN from.id as fromId
+		RETURN from.id as fromId, properties(r) as props
 	`, relationLabel)
 
 	records, err := cg.db.ExecuteRead(ctx, query, map[string]any{"toId": int64(toNodeID)})
@@ -1260,12 +1556,33 @@ func (cg *CodeGraph) GetIncomingRelations(ctx context.Context, toNodeID ast.Node
 			FromNodeID: ast.NodeID(fromNodeID),
 			ToNodeID:   toNodeID,
 			Label:      relationLabel,
+			Metadata:   cg.relationMetadata(record["props"]),
 		})
 	}
 
 	return results, nil
 }
 
+// relationMetadata un-flattens the md_ prefixed properties of a relation, mirroring recordToNode
+func (cg *CodeGraph) relationMetadata(props any) map[string]any {
+	propMap, ok := props.(map[string]any)
+	if !ok {
+		return nil
+	}
+
+	metadata := make(map[string]any)
+	for key, value := range propMap {
+		if strings.HasPrefix(key, "md_") {
+			metadata[key[3:]] = value
+		}
+	}
+
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
+
 func (cg *CodeGraph) CreateUsesVariableRelation(ctx context.Context, userNodeID, variableNodeID ast.NodeID, fileID int32) error {
 	return cg.CreateRelation(ctx, userNodeID, variableNodeID, "USES_VARIABLE", nil, fileID)
 }
@@ -1399,6 +1716,36 @@ func (cg *CodeGraph) FindFunctionCalls(ctx context.Context, fileID ast.NodeID) (
 	return functionCalls, nil
 }
 
+// FindUnresolvedFunctionCalls returns FunctionCall nodes in a file that have no
+// CALLS_FUNCTION edge and were not marked external during call resolution
+func (cg *CodeGraph) FindUnresolvedFunctionCalls(ctx context.Context, fileID int32) ([]*ast.Node, error) {
+	query := `
+		MATCH (fc:FunctionCall)
+		WHERE fc.fileId = $fileId
+		  AND NOT (fc)-[:CALLS_FUNCTION]->()
+		  AND coalesce(fc.md_external, false) = false
+		RETURN fc
+	`
+	return cg.readNodesByQuery(ctx, "fc", query, map[string]any{"fileId": int64(fileID)})
+}
+
+// CountFunctionCallArgs returns the number of arguments passed at a call site
+func (cg *CodeGraph) CountFunctionCallArgs(ctx context.Context, callID ast.NodeID) (int, error) {
+	query := `
+		MATCH (fc:FunctionCall {id: $callId})-[:FUNCTION_CALL_ARG]->(arg)
+		RETURN count(arg) AS args
+	`
+
+	record, err := cg.db.ExecuteReadSingle(ctx, query, map[string]any{"callId": int64(callID)})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count function call arguments: %w", err)
+	}
+	if record == nil {
+		return 0, nil
+	}
+	return int(cg.convertToInt64(record["args"])), nil
+}
+
 func (cg *CodeGraph) FindFunctionsByName(ctx context.Context, fileID int, name string) ([]*ast.Node, error) {
 	return cg.readNodes(ctx, ast.NodeTypeFunction, map[string]any{
 		"name":   name,
@@ -1685,6 +2032,55 @@ func (cg *CodeGraph) GetMethodsOfClass(ctx context.Context, classID ast.NodeID)
 	return cg.readNodesByQuery(ctx, "m", query, map[string]any{"classId": int64(classID)})
 }
 
+// receiverNames are the names of the variables through which methods access
+// their own instance (or, for cls, class) attributes
+var receiverNames = []string{"self", "cls", "this"}
+
+// ReceiverFieldAccess is an attribute a method accesses on its receiver
+type ReceiverFieldAccess struct {
+	MethodID ast.NodeID
+	Field    *ast.Node
+}
+
+// GetReceiverFieldAccesses returns the attributes the methods of a class
+// access on their receiver (self, cls or this, or a variable marked THIS), one
+// per access. The parser records every access as its own Field node hanging
+// off the receiver through HAS_FIELD, so accesses to one field share a name
+// but not an ID. Attributes named like a method of the class are calls, not
+// fields, and are left out.
+func (cg *CodeGraph) GetReceiverFieldAccesses(ctx context.Context, classID ast.NodeID) ([]ReceiverFieldAccess, error) {
+	query := `
+		MATCH (c:Class {id: $classId})-[:CONTAINS]->(m:Function)-[:CONTAINS]->(r:Variable)-[:HAS_FIELD]->(f:Field)
+		WHERE (r.name IN $receivers OR (r)-[:THIS]->())
+			AND NOT (c)-[:CONTAINS]->(:Function {name: f.name})
+		RETURN m.id AS methodId, f AS field
+	`
+	records, err := cg.db.ExecuteRead(ctx, query, map[string]any{
+		"classId":   int64(classID),
+		"receivers": receiverNames,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receiver field accesses of class %d: %w", classID, err)
+	}
+
+	accesses := make([]ReceiverFieldAccess, 0, len(records))
+	for _, record := range records {
+		fieldMap, ok := record["field"].(map[string]any)
+		if !ok {
+			continue
+		}
+		field, err := cg.recordToNode(fieldMap)
+		if err != nil {
+			return nil, err
+		}
+		accesses = append(accesses, ReceiverFieldAccess{
+			MethodID: ast.NodeID(cg.convertToInt64(record["methodId"])),
+			Field:    field,
+		})
+	}
+	return accesses, nil
+}
+
 // GetFieldsOfClass returns all fields contained by a class
 func (cg *CodeGraph) GetFieldsOfClass(ctx context.Context, classID ast.NodeID) ([]*ast.Node, error) {
 	query := `
@@ -1765,6 +2161,49 @@ func (cg *CodeGraph) GetFieldOwnerClass(ctx context.Context, fieldID ast.NodeID)
 	return nodes[0], nil
 }
 
+// ForeignAccess is a method of another class called by a class's methods
+type ForeignAccess struct {
+	MemberID     ast.NodeID
+	MemberName   string
+	OwnerClassID ast.NodeID
+	OwnerModule  string // ModuleScope name of the owner's file; "" when unknown
+	OwnerPath    string // Repo-relative path of the owner's file; "" when unknown
+}
+
+// GetForeignAccesses returns the methods of other classes that the methods
+// of a class call, as resolved through CALLS_FUNCTION. Each method is
+// returned once; callers decide which of them read or write data (ATFD keeps
+// only accessors). Direct reads of another class's fields are not returned:
+// the graph records such a read as a Field node inside the reading method,
+// with nothing linking it to the class that declares the field.
+func (cg *CodeGraph) GetForeignAccesses(ctx context.Context, classID ast.NodeID) ([]ForeignAccess, error) {
+	query := `
+		MATCH (c:Class {id: $classId})-[:CONTAINS]->(:Function)-[:CONTAINS*]->(:FunctionCall)-[:CALLS_FUNCTION]->(member:Function)
+		MATCH (owner:Class)-[:CONTAINS]->(member)
+		WHERE owner.id <> c.id
+		OPTIONAL MATCH (fs:FileScope {id: owner.fileId})-[:CONTAINS]->(mod:ModuleScope)
+		RETURN DISTINCT member.id AS memberId, member.name AS memberName,
+			owner.id AS ownerId, mod.name AS module, fs.path AS path
+	`
+	records, err := cg.db.ExecuteRead(ctx, query, map[string]any{"classId": int64(classID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get foreign accesses of class %d: %w", classID, err)
+	}
+
+	accesses := make([]ForeignAccess, 0, len(records))
+	for _, record := range records {
+		access := ForeignAccess{
+			MemberID:     ast.NodeID(cg.convertToInt64(record["memberId"])),
+			OwnerClassID: ast.NodeID(cg.convertToInt64(record["ownerId"])),
+		}
+		access.MemberName, _ = record["memberName"].(string)
+		access.OwnerModule, _ = record["module"].(string)
+		access.OwnerPath, _ = record["path"].(string)
+		accesses = append(accesses, access)
+	}
+	return accesses, nil
+}
+
 func (cg *CodeGraph) GetModuleName(ctx context.Context, fileId int32) (string, error) {
 	// Query the database (either batch mode disabled, or module not in buffer)
 	query := `
@@ -1914,6 +2353,46 @@ func (cg *CodeGraph) GetFieldsWrittenByMethod(ctx context.Context, methodID ast.
 	return cg.readNodesByQuery(ctx, "f", query, map[string]any{"methodId": int64(methodID)})
 }
 
+// GetFunctionsInFileScope returns the Function nodes reachable from a file
+// scope through CONTAINS relations, including methods nested in classes
+func (cg *CodeGraph) GetFunctionsInFileScope(ctx context.Context, fileScopeID ast.NodeID) ([]*ast.Node, error) {
+	query := `
+		MATCH (fs:FileScope {id: $fileScopeId})-[:CONTAINS*]->(f:Function)
+		RETURN DISTINCT f
+	`
+	return cg.readNodesByQuery(ctx, "f", query, map[string]any{"fileScopeId": int64(fileScopeID)})
+}
+
+// GetFunctionsInFile returns all Function nodes belonging to a file
+func (cg *CodeGraph) GetFunctionsInFile(ctx context.Context, fileID int32) ([]*ast.Node, error) {
+	return cg.readNodes(ctx, ast.NodeTypeFunction, map[string]any{"fileId": int64(fileID)})
+}
+
+// CountDecisionPoints returns the number of decision points nested in a function:
+// one per loop and one per conditional branch guarded by a condition
+func (cg *CodeGraph) CountDecisionPoints(ctx context.Context, functionID ast.NodeID) (int, error) {
+	query := `
+		MATCH (f:Function {id: $functionId})-[:CONTAINS*]->(d)
+		WHERE d:Loop OR d:Conditional
+		OPTIONAL MATCH (d)-[b:BRANCH]->()
+		WHERE b.md_condition <> $invalidId
+		WITH d, count(b) AS guardedBranches
+		RETURN sum(CASE WHEN d:Loop THEN 1 ELSE guardedBranches END) AS decisionPoints
+	`
+
+	record, err := cg.db.ExecuteReadSingle(ctx, query, map[string]any{
+		"functionId": int64(functionID),
+		"invalidId":  int64(ast.InvalidNodeID),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count decision points: %w", err)
+	}
+	if record == nil {
+		return 0, nil
+	}
+	return int(cg.convertToInt64(record["decisionPoints"])), nil
+}
+
 // DumpToFile dumps the code graph for the specified repositories to a file.
 // FileScopes are output in alphabetical order by their path.
 // For each FileScope, all nodes and relations within that file are dumped.
@@ -2118,56 +2597,86 @@ func (cg *CodeGraph) getAllRelationsInFile(ctx context.Context, fileID int32) ([
 	return relations, nil
 }
 
-// CleanRepository deletes all nodes and relationships for a specific repository from Neo4j.
-// This includes all FileScopes and their descendant nodes (functions, classes, variables, etc.)
+// CleanRepository deletes all nodes and relationships for a specific repository.
+// It is DeleteRepository, kept for existing callers.
 func (cg *CodeGraph) CleanRepository(ctx context.Context, repoName string) error {
-	cg.logger.Info("Starting Neo4j cleanup for repository", zap.String("repo", repoName))
+	return cg.DeleteRepository(ctx, repoName)
+}
 
-	// First, get count of nodes to be deleted for logging
-	countQuery := `
+// DeleteRepository deletes every node of a repository together with its
+// relationships, so a reprocess starts clean instead of leaving nodes of
+// removed or renamed files behind. Nodes are matched by the file they belong
+// to and by their repo property, which writeNode copies from the file scope.
+func (cg *CodeGraph) DeleteRepository(ctx context.Context, repoName string) error {
+	fileScopes, err := cg.FindFileScopes(ctx, repoName, "")
+	if err != nil {
+		return fmt.Errorf("failed to find file scopes: %w", err)
+	}
+	cg.logger.Info("Deleting repository from code graph",
+		zap.String("repo", repoName),
+		zap.Int("file_scopes", len(fileScopes)))
+
+	// Nodes of the repository's files, including any written without a repo
+	deleteFileNodesQuery := `
 		MATCH (fs:FileScope {repo: $repo})
-		OPTIONAL MATCH (fs)-[:CONTAINS*]->(descendant)
-		RETURN count(DISTINCT fs) as fileScopeCount, count(DISTINCT descendant) as descendantCount
+		MATCH (n) WHERE n.fileId = fs.fileId
+		DETACH DELETE n
 	`
-	countResult, err := cg.db.ExecuteReadSingle(ctx, countQuery, map[string]any{"repo": repoName})
-	if err != nil {
-		cg.logger.Warn("Failed to count nodes for deletion", zap.Error(err))
-	} else {
-		fsCount := cg.convertToInt64(countResult["fileScopeCount"])
-		descCount := cg.convertToInt64(countResult["descendantCount"])
-		cg.logger.Info("Nodes to be deleted",
-			zap.String("repo", repoName),
-			zap.Int64("file_scopes", fsCount),
-			zap.Int64("descendants", descCount))
-	}
-
-	// Delete all descendant nodes first (nodes connected via CONTAINS relationships)
-	// Using DETACH DELETE to also remove all relationships
-	deleteDescendantsQuery := `
-		MATCH (fs:FileScope {repo: $repo})-[:CONTAINS*]->(descendant)
-		DETACH DELETE descendant
+	if _, err := cg.db.ExecuteWrite(ctx, deleteFileNodesQuery, map[string]any{"repo": repoName}); err != nil {
+		return fmt.Errorf("failed to delete file nodes: %w", err)
+	}
+
+	deleteRepoNodesQuery := `
+		MATCH (n) WHERE n.repo = $repo
+		DETACH DELETE n
 	`
-	_, err = cg.db.ExecuteWrite(ctx, deleteDescendantsQuery, map[string]any{"repo": repoName})
+	if _, err := cg.db.ExecuteWrite(ctx, deleteRepoNodesQuery, map[string]any{"repo": repoName}); err != nil {
+		return fmt.Errorf("failed to delete repository nodes: %w", err)
+	}
+
+	cg.forgetFiles(fileScopes)
+	cg.logger.Info("Deleted repository from code graph", zap.String("repo", repoName))
+	return nil
+}
+
+// DeleteFile deletes the file scope of a file in a repository and every node
+// belonging to the file, together with their relationships, e.g. after the
+// file was removed or renamed. Deleting a file that is not in the graph is
+// not an error.
+func (cg *CodeGraph) DeleteFile(ctx context.Context, repoName, filePath string) error {
+	fileScopes, err := cg.FindFileScopes(ctx, repoName, filePath)
 	if err != nil {
-		return fmt.Errorf("failed to delete descendant nodes: %w", err)
+		return fmt.Errorf("failed to find file scope: %w", err)
 	}
-	cg.logger.Debug("Deleted descendant nodes", zap.String("repo", repoName))
 
-	// Now delete the FileScope nodes themselves
-	deleteFileScopesQuery := `
-		MATCH (fs:FileScope {repo: $repo})
-		DETACH DELETE fs
+	query := `
+		MATCH (n) WHERE n.fileId = $fileId
+		DETACH DELETE n
 	`
-	_, err = cg.db.ExecuteWrite(ctx, deleteFileScopesQuery, map[string]any{"repo": repoName})
-	if err != nil {
-		return fmt.Errorf("failed to delete FileScope nodes: %w", err)
+	for _, fs := range fileScopes {
+		if _, err := cg.db.ExecuteWrite(ctx, query, map[string]any{"fileId": int64(fs.FileID)}); err != nil {
+			return fmt.Errorf("failed to delete nodes of %s: %w", filePath, err)
+		}
 	}
-	cg.logger.Debug("Deleted FileScope nodes", zap.String("repo", repoName))
 
-	cg.logger.Info("Neo4j cleanup completed for repository", zap.String("repo", repoName))
+	cg.forgetFiles(fileScopes)
+	cg.logger.Debug("Deleted file from code graph",
+		zap.String("repo", repoName),
+		zap.String("path", filePath),
+		zap.Int("file_scopes", len(fileScopes)))
 	return nil
 }
 
+// forgetFiles drops the cached paths and properties of deleted files
+func (cg *CodeGraph) forgetFiles(fileScopes []*ast.Node) {
+	cg.cacheMutex.Lock()
+	defer cg.cacheMutex.Unlock()
+	for _, fs := range fileScopes {
+		delete(cg.fileIDCache, fs.FileID)
+		delete(cg.fileProperties, fs.FileID)
+	}
+}
+
 // ExecuteRead executes a read-only Cypher query and returns the raw records.
 // This is exposed for use by higher-level query APIs (e.g., codeapi package).
 func (cg *CodeGraph) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
@@ -0,0 +1,615 @@
+package codegraph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/testutil"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestCodeGraph(db GraphDatabase) (*CodeGraph, *observer.ObservedLogs) {
+	core, logs := observer.New(zap.WarnLevel)
+	return NewCodeGraphWithDatabase(db, &config.Config{}, zap.New(core)), logs
+}
+
+func TestRecordToNode(t *testing.T) {
+	tests := []struct {
+		name     string
+		record   map[string]any
+		want     *ast.Node
+		warnings int
+	}{
+		{
+			name: "complete record",
+			record: map[string]any{
+				"id": int64(7), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(3),
+				"name": "Run", "range": "(1,2)-(5,1)", "version": int64(1), "scopeId": int64(2),
+				"repo": "demo", "md_complexity": int64(4),
+			},
+			want: &ast.Node{
+				ID: 7, NodeType: ast.NodeTypeFunction, FileID: 3, Name: "Run",
+				Range:   base.Range{Start: base.Position{Line: 1, Character: 2}, End: base.Position{Line: 5, Character: 1}},
+				Version: 1, ScopeID: 2,
+				MetaData: map[string]any{"repo": "demo", "complexity": int64(4)},
+			},
+		},
+		{
+			name: "narrower integer types",
+			record: map[string]any{
+				"id": 7, "nodeType": int32(ast.NodeTypeClass), "fileId": int32(3),
+				"name": "Service", "version": uint32(0), "scopeId": uint64(1),
+			},
+			want: &ast.Node{ID: 7, NodeType: ast.NodeTypeClass, FileID: 3, Name: "Service", ScopeID: 1},
+		},
+		{
+			name: "nil range and missing name",
+			record: map[string]any{
+				"id": int64(8), "nodeType": int64(ast.NodeTypeBlock), "fileId": int64(3),
+				"range": nil, "version": int64(0), "scopeId": int64(7),
+			},
+			want: &ast.Node{ID: 8, NodeType: ast.NodeTypeBlock, FileID: 3, ScopeID: 7},
+		},
+		{
+			name: "unexpected types are logged",
+			record: map[string]any{
+				"id": "9", "nodeType": int64(ast.NodeTypeVariable), "fileId": 3.5,
+				"name": 42, "range": 17, "version": int64(0), "scopeId": int64(0),
+			},
+			want:     &ast.Node{NodeType: ast.NodeTypeVariable},
+			warnings: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cg, logs := newTestCodeGraph(testutil.NewMockGraphDatabase())
+
+			got, err := cg.recordToNode(tt.record)
+			if err != nil {
+				t.Fatalf("recordToNode returned error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recordToNode() = %+v, want %+v", got, tt.want)
+			}
+			if logs.Len() != tt.warnings {
+				t.Errorf("logged %d warnings, want %d: %v", logs.Len(), tt.warnings, logs.All())
+			}
+		})
+	}
+}
+
+func TestWriteNodeFlattensMetadata(t *testing.T) {
+	db := testutil.NewMockGraphDatabase()
+	cg, _ := newTestCodeGraph(db)
+
+	node := ast.NewNode(10, ast.NodeTypeClass, 3, "Service",
+		base.Range{Start: base.Position{Line: 2}, End: base.Position{Line: 9}}, 1, 1)
+	node.MetaData = map[string]any{
+		"repo":       "demo",
+		"fake":       true,
+		"complexity": 4,
+		"module":     "pkg",
+	}
+
+	if err := cg.CreateClass(context.Background(), node); err != nil {
+		t.Fatalf("CreateClass failed: %v", err)
+	}
+
+	writes := db.Writes()
+	if len(writes) != 1 {
+		t.Fatalf("got %d writes, want 1", len(writes))
+	}
+	write := writes[0]
+
+	if !strings.Contains(write.Query, "MERGE (n:Class {id: $id})") {
+		t.Errorf("query does not merge a Class node: %s", write.Query)
+	}
+
+	wantParams := map[string]any{
+		"id": int64(10), "nodeType": int64(ast.NodeTypeClass), "fileId": int64(3),
+		"name": "Service", "range": "(2,0)-(9,0)", "version": int64(1), "scopeId": int64(1),
+		"repo": "demo", "fake": true, "md_complexity": 4, "md_module": "pkg",
+	}
+	if !reflect.DeepEqual(write.Params, wantParams) {
+		t.Errorf("params = %v, want %v", write.Params, wantParams)
+	}
+
+	for key := range wantParams {
+		if !strings.Contains(write.Query, "n."+key+" = $"+key) {
+			t.Errorf("query does not set %s: %s", key, write.Query)
+		}
+	}
+}
+
+func TestWriteNodeConfiguredFirstClassMetadata(t *testing.T) {
+	db := testutil.NewMockGraphDatabase()
+	cfg := &config.Config{CodeGraph: config.CodeGraphConfig{
+		FirstClassMetadata: []string{"decorator", "bad key", "md_position", "name", "fileId"},
+	}}
+	core, logs := observer.New(zap.WarnLevel)
+	cg := NewCodeGraphWithDatabase(db, cfg, zap.New(core))
+
+	node := ast.NewNode(11, ast.NodeTypeFunction, 3, "handler",
+		base.Range{Start: base.Position{Line: 4}, End: base.Position{Line: 8}}, 1, 1)
+	node.MetaData = map[string]any{
+		"decorator":  "route",
+		"repo":       "demo",
+		"complexity": 2,
+		"name":       "decorated",
+	}
+
+	if err := cg.CreateFunction(context.Background(), node); err != nil {
+		t.Fatalf("CreateFunction failed: %v", err)
+	}
+
+	writes := db.Writes()
+	if len(writes) != 1 {
+		t.Fatalf("got %d writes, want 1", len(writes))
+	}
+	params := writes[0].Params
+	if params["decorator"] != "route" {
+		t.Errorf("decorator not written as a top-level property: %v", params)
+	}
+	if _, ok := params["md_decorator"]; ok {
+		t.Errorf("decorator also written with md_ prefix: %v", params)
+	}
+	if params["repo"] != "demo" || params["md_complexity"] != 2 {
+		t.Errorf("built-in and unlisted keys not handled as before: %v", params)
+	}
+	if !strings.Contains(writes[0].Query, "n.decorator = $decorator") {
+		t.Errorf("query does not set decorator: %s", writes[0].Query)
+	}
+	if params["name"] != "handler" || params["md_name"] != "decorated" || params["fileId"] != int64(3) {
+		t.Errorf("metadata overwrote core properties: %v", params)
+	}
+
+	got, err := cg.recordToNode(map[string]any{
+		"id": int64(11), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(3),
+		"decorator": "route", "md_complexity": int64(2),
+	})
+	if err != nil {
+		t.Fatalf("recordToNode returned error: %v", err)
+	}
+	if got.MetaData["decorator"] != "route" || got.MetaData["complexity"] != int64(2) {
+		t.Errorf("metadata read back as %v", got.MetaData)
+	}
+
+	if n := logs.FilterMessage("Ignoring invalid first-class metadata key").Len(); n != 4 {
+		t.Errorf("got %d warnings for invalid keys, want 4", n)
+	}
+}
+
+func TestWriteNodePropagatesDatabaseError(t *testing.T) {
+	db := testutil.NewMockGraphDatabase()
+	db.StubWrite(nil, errors.New("connection reset"))
+	cg, logs := newTestCodeGraph(db)
+
+	node := ast.NewNode(10, ast.NodeTypeClass, 3, "Service", base.Range{}, 0, 0)
+	err := cg.CreateClass(context.Background(), node)
+	if err == nil || !strings.Contains(err.Error(), "connection reset") {
+		t.Fatalf("CreateClass error = %v, want wrapped connection reset", err)
+	}
+	if logs.FilterMessage("Failed to write node").Len() != 1 {
+		t.Errorf("write failure was not logged: %v", logs.All())
+	}
+}
+
+func TestReadNodesSkipsMalformedRecords(t *testing.T) {
+	db := testutil.NewMockGraphDatabase()
+	db.StubRead([]map[string]any{
+		{"child": "not a node"},
+		{"other": map[string]any{"id": int64(1)}},
+		{"child": map[string]any{
+			"id": int64(11), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(3),
+			"name": "Run", "version": int64(0), "scopeId": int64(10),
+		}},
+	}, nil)
+	cg, _ := newTestCodeGraph(db)
+
+	nodes, err := cg.GetContainedNodes(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("GetContainedNodes failed: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].ID != 11 {
+		t.Fatalf("got %+v, want only node 11", nodes)
+	}
+
+	reads := db.Reads()
+	if len(reads) != 1 || reads[0].Params["parentId"] != int64(10) {
+		t.Errorf("unexpected reads: %+v", reads)
+	}
+
+	db.StubRead(nil, errors.New("database unavailable"))
+	if _, err := cg.GetContainedNodes(context.Background(), 10); err == nil {
+		t.Error("GetContainedNodes should propagate read errors")
+	}
+}
+
+func TestRelationMetadataReadback(t *testing.T) {
+	db := testutil.NewMockGraphDatabase()
+
+	// Serve relation properties back exactly as CreateRelation wrote them
+	var written map[string]any
+	db.WriteFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		written = make(map[string]any)
+		for key, value := range params {
+			if strings.HasPrefix(key, "md_") {
+				written[key] = value
+			}
+		}
+		return nil, nil
+	}
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		if _, ok := params["fromId"]; ok {
+			return []map[string]any{{"toId": int64(21), "props": written}}, nil
+		}
+		return []map[string]any{{"fromId": int64(20), "props": written}}, nil
+	}
+	cg, _ := newTestCodeGraph(db)
+	ctx := context.Background()
+
+	if err := cg.CreateFunctionArgRelation(ctx, 20, 21, 2, 3); err != nil {
+		t.Fatalf("CreateFunctionArgRelation failed: %v", err)
+	}
+
+	outgoing, err := cg.GetOutgoingRelations(ctx, 20, "FUNCTION_ARG")
+	if err != nil {
+		t.Fatalf("GetOutgoingRelations failed: %v", err)
+	}
+	want := []RelationInfo{{FromNodeID: 20, ToNodeID: 21, Label: "FUNCTION_ARG", Metadata: map[string]any{"position": 2}}}
+	if !reflect.DeepEqual(outgoing, want) {
+		t.Errorf("GetOutgoingRelations = %+v, want %+v", outgoing, want)
+	}
+
+	incoming, err := cg.GetIncomingRelations(ctx, 21, "FUNCTION_ARG")
+	if err != nil {
+		t.Fatalf("GetIncomingRelations failed: %v", err)
+	}
+	if !reflect.DeepEqual(incoming, want) {
+		t.Errorf("GetIncomingRelations = %+v, want %+v", incoming, want)
+	}
+
+	reads := db.Reads()
+	if len(reads) == 0 || !strings.Contains(reads[0].Query, "properties(r)") {
+		t.Errorf("relation query does not return relation properties: %+v", reads)
+	}
+}
+
+func TestCreateRelationRejectsSelfContainment(t *testing.T) {
+	// Emulate MERGE: one edge per endpoints and label, whose properties SET overwrites
+	type edgeKey struct {
+		parent, child any
+		label         string
+	}
+	edges := make(map[edgeKey]map[string]any)
+	labelRe := regexp.MustCompile(`\[r:(\w+)\]`)
+	db := testutil.NewMockGraphDatabase()
+	db.WriteFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		key := edgeKey{params["parentId"], params["childId"], labelRe.FindStringSubmatch(query)[1]}
+		if edges[key] == nil {
+			edges[key] = make(map[string]any)
+		}
+		for name, value := range params {
+			if strings.HasPrefix(name, "md_") && strings.Contains(query, "r."+name+" = $"+name) {
+				edges[key][name] = value
+			}
+		}
+		return nil, nil
+	}
+	cg, _ := newTestCodeGraph(db)
+	ctx := context.Background()
+
+	for _, label := range []string{"CONTAINS", "HAS_FIELD", "BODY"} {
+		if err := cg.CreateRelation(ctx, 5, 5, label, nil, 1); !errors.Is(err, ErrSelfContainment) {
+			t.Errorf("self %s relation: err = %v, want ErrSelfContainment", label, err)
+		}
+	}
+	if len(db.Writes()) != 0 {
+		t.Errorf("rejected relations were written: %+v", db.Writes())
+	}
+
+	// Recursion is a genuine self-loop
+	if err := cg.CreateCallsRelation(ctx, 5, 5, 1); err != nil {
+		t.Errorf("self CALLS relation: %v", err)
+	}
+
+	if err := cg.CreateFunctionArgRelation(ctx, 20, 21, 1, 1); err != nil {
+		t.Fatalf("CreateFunctionArgRelation: %v", err)
+	}
+	if err := cg.CreateFunctionArgRelation(ctx, 20, 21, 2, 1); err != nil {
+		t.Fatalf("CreateFunctionArgRelation again: %v", err)
+	}
+	var args []map[string]any
+	for key, props := range edges {
+		if key.label == "FUNCTION_ARG" {
+			args = append(args, props)
+		}
+	}
+	if len(args) != 1 || args[0]["md_position"] != 2 {
+		t.Errorf("FUNCTION_ARG edges = %+v, want one edge at position 2", args)
+	}
+}
+
+func TestGetFilePathConcurrent(t *testing.T) {
+	const files = 8
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		id, _ := params["id"].(int64)
+		if id < 1 || id > files {
+			return nil, nil
+		}
+		return []map[string]any{{"n": map[string]any{
+			"id": id, "nodeType": int64(ast.NodeTypeFileScope), "fileId": id,
+			"name": fmt.Sprintf("file%d.go", id), "version": int64(0), "scopeId": int64(0),
+			"md_path": fmt.Sprintf("pkg/file%d.go", id), "md_repo": "demo",
+		}}}, nil
+	}
+	cfg := &config.Config{Source: config.SourceConfig{Repositories: []config.Repository{{Name: "demo", Path: "/src/demo"}}}}
+	cg := NewCodeGraphWithDatabase(db, cfg, zap.NewNop())
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 64)
+	for g := 0; g < 32; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				fileID := int32((g+i)%files + 1)
+				if got, want := cg.GetFilePath(ctx, fileID), fmt.Sprintf("pkg/file%d.go", fileID); got != want {
+					select {
+					case errs <- fmt.Sprintf("GetFilePath(%d) = %q, want %q", fileID, got, want):
+					default:
+					}
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if got := cg.FileIDCacheSize(); got != files {
+		t.Errorf("cached %d file paths, want %d", got, files)
+	}
+	if got := cg.GetFilePath(ctx, files+1); got != "" {
+		t.Errorf("GetFilePath of a missing file scope = %q, want \"\"", got)
+	}
+}
+
+// memoryGraph stores the nodes written through a MockGraphDatabase and
+// answers the file scope reads and the deletes issued by DeleteRepository and
+// DeleteFile
+type memoryGraph struct {
+	mu    sync.Mutex
+	nodes map[int64]map[string]any // id -> properties, with "label" added
+}
+
+var mergeLabelPattern = regexp.MustCompile(`MERGE \(n:(\w+)`)
+
+func newMemoryGraph(db *testutil.MockGraphDatabase) *memoryGraph {
+	g := &memoryGraph{nodes: make(map[int64]map[string]any)}
+	db.WriteFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		switch {
+		case strings.Contains(query, "MERGE (n:"):
+			node := map[string]any{"label": mergeLabelPattern.FindStringSubmatch(query)[1]}
+			for key, value := range params {
+				node[key] = value
+			}
+			g.nodes[params["id"].(int64)] = node
+		case strings.Contains(query, "n.fileId = fs.fileId") && strings.Contains(query, "DETACH DELETE n"):
+			fileIDs := make(map[any]bool)
+			for _, node := range g.nodes {
+				if node["label"] == "FileScope" && node["repo"] == params["repo"] {
+					fileIDs[node["fileId"]] = true
+				}
+			}
+			g.deleteWhere(func(node map[string]any) bool { return fileIDs[node["fileId"]] })
+		case strings.Contains(query, "n.repo = $repo") && strings.Contains(query, "DETACH DELETE n"):
+			g.deleteWhere(func(node map[string]any) bool { return node["repo"] == params["repo"] })
+		case strings.Contains(query, "n.fileId = $fileId") && strings.Contains(query, "DETACH DELETE n"):
+			g.deleteWhere(func(node map[string]any) bool { return node["fileId"] == params["fileId"] })
+		default:
+			return nil, fmt.Errorf("unexpected write: %s", query)
+		}
+		return nil, nil
+	}
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		label := regexp.MustCompile(`MATCH \(n:(\w+)\)`).FindStringSubmatch(query)[1]
+		var records []map[string]any
+		for _, node := range g.nodes {
+			matches := node["label"] == label
+			for key, value := range params {
+				matches = matches && node[key] == value
+			}
+			if matches {
+				records = append(records, map[string]any{"n": node})
+			}
+		}
+		return records, nil
+	}
+	return g
+}
+
+func (g *memoryGraph) deleteWhere(match func(node map[string]any) bool) {
+	for id, node := range g.nodes {
+		if match(node) {
+			delete(g.nodes, id)
+		}
+	}
+}
+
+func (g *memoryGraph) repos() map[any]int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	repos := make(map[any]int)
+	for _, node := range g.nodes {
+		repos[node["repo"]]++
+	}
+	return repos
+}
+
+func TestDeleteRepositoryAndFile(t *testing.T) {
+	db := testutil.NewMockGraphDatabase()
+	graph := newMemoryGraph(db)
+	cg, _ := newTestCodeGraph(db)
+	ctx := context.Background()
+
+	// Two files in each of two repositories, each with a function
+	nextID := ast.NodeID(1)
+	for _, repo := range []string{"billing", "shipping"} {
+		for _, path := range []string{"a.go", "b.go"} {
+			fileID := int32(nextID)
+			fs := ast.NewNode(nextID, ast.NodeTypeFileScope, fileID, path, base.Range{}, 1, 0)
+			fs.MetaData = map[string]any{"repo": repo, "path": path, "language": "go"}
+			if err := cg.CreateFileScope(ctx, fs); err != nil {
+				t.Fatalf("CreateFileScope: %v", err)
+			}
+			fn := ast.NewNode(nextID+1, ast.NodeTypeFunction, fileID, "Run", base.Range{}, 1, nextID)
+			if err := cg.CreateFunction(ctx, fn); err != nil {
+				t.Fatalf("CreateFunction: %v", err)
+			}
+			nextID += 2
+		}
+	}
+	if got := graph.repos(); got["billing"] != 4 || got["shipping"] != 4 {
+		t.Fatalf("nodes per repository = %v, want 4 each", got)
+	}
+
+	if err := cg.DeleteFile(ctx, "shipping", "a.go"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if got := graph.repos(); got["billing"] != 4 || got["shipping"] != 2 {
+		t.Errorf("after DeleteFile nodes per repository = %v, want billing 4 and shipping 2", got)
+	}
+	if scopes, err := cg.FindFileScopes(ctx, "shipping", ""); err != nil || len(scopes) != 1 || scopes[0].MetaData["path"] != "b.go" {
+		t.Errorf("shipping file scopes after DeleteFile = %v, %v; want only b.go", scopes, err)
+	}
+
+	if err := cg.DeleteRepository(ctx, "billing"); err != nil {
+		t.Fatalf("DeleteRepository: %v", err)
+	}
+	if got := graph.repos(); len(got) != 1 || got["shipping"] != 2 {
+		t.Errorf("after DeleteRepository nodes per repository = %v, want only shipping's 2", got)
+	}
+	if scopes, err := cg.FindFileScopes(ctx, "billing", ""); err != nil || len(scopes) != 0 {
+		t.Errorf("billing file scopes after DeleteRepository = %v, %v; want none", scopes, err)
+	}
+}
+
+func TestSearchNodesByNameWithoutInheritedProperties(t *testing.T) {
+	graph := testutil.NewGraphRecorder()
+	cg, _ := newTestCodeGraph(graph)
+	ctx := context.Background()
+
+	// Functions written before their file scope, like nodes indexed before
+	// they inherited repo and language, carry neither
+	functions := []struct {
+		id     ast.NodeID
+		fileID int32
+		name   string
+	}{
+		{100, 1, "HandleOrder"},
+		{101, 1, "handleRefund"},
+		{102, 2, "HandleLegacy"},
+		{103, 3, "HandleOther"},
+	}
+	for _, fn := range functions {
+		if err := cg.CreateFunction(ctx, ast.NewNode(fn.id, ast.NodeTypeFunction, fn.fileID, fn.name, base.Range{}, 1, ast.NodeID(fn.fileID))); err != nil {
+			t.Fatalf("CreateFunction(%s): %v", fn.name, err)
+		}
+	}
+	files := []struct {
+		id             int32
+		repo, language string
+	}{
+		{1, "shop", "go"},
+		{2, "shop", "python"},
+		{3, "other", "go"},
+	}
+	for _, f := range files {
+		node := ast.NewNode(ast.NodeID(f.id), ast.NodeTypeFileScope, f.id, fmt.Sprintf("file%d", f.id), base.Range{}, 1, 0)
+		node.MetaData = map[string]any{"repo": f.repo, "language": f.language}
+		if err := cg.CreateFileScope(ctx, node); err != nil {
+			t.Fatalf("CreateFileScope(%d): %v", f.id, err)
+		}
+	}
+	for _, node := range graph.Nodes("Function") {
+		if _, ok := node["repo"]; ok {
+			t.Fatalf("function %v inherited repo, want it missing", node["name"])
+		}
+	}
+
+	// Answers the search the way Neo4j would: file scopes by their own
+	// properties, other nodes by the properties they were written with
+	graph.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		label := regexp.MustCompile(`MATCH \(n:(\w+)`).FindStringSubmatch(query)
+		if !strings.Contains(query, "MATCH (f:FileScope {repo: $repo})") || label == nil {
+			return nil, fmt.Errorf("unexpected query: %s", query)
+		}
+		repoFiles := make(map[any]bool)
+		for _, f := range graph.Nodes("FileScope") {
+			if f["repo"] == params["repo"] && (!strings.Contains(query, "f.language = $language") || f["language"] == params["language"]) {
+				repoFiles[f["id"]] = true
+			}
+		}
+		var records []map[string]any
+		for _, n := range graph.Nodes(label[1]) {
+			if !repoFiles[n["fileId"]] || !strings.Contains(strings.ToLower(n["name"].(string)), strings.ToLower(params["pattern"].(string))) {
+				continue
+			}
+			if strings.Contains(query, "n.repo = $repo") && n["repo"] != params["repo"] {
+				continue
+			}
+			if strings.Contains(query, "n.language = $language") && n["language"] != params["language"] {
+				continue
+			}
+			records = append(records, map[string]any{"n": n})
+		}
+		return records, nil
+	}
+
+	tests := []struct {
+		name     string
+		language string
+		want     []string
+	}{
+		{name: "whole repository", want: []string{"HandleLegacy", "HandleOrder", "handleRefund"}},
+		{name: "one language", language: "go", want: []string{"HandleOrder", "handleRefund"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nodes, err := cg.SearchNodesByName(ctx, "shop", "handle", tt.language, ast.NodeTypeFunction, 10)
+			if err != nil {
+				t.Fatalf("SearchNodesByName failed: %v", err)
+			}
+			var got []string
+			for _, node := range nodes {
+				got = append(got, node.Name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchNodesByName = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"bot-go/internal/model/ast"
+)
+
+// DataFlowEdge is a DATA_FLOW relation: the value of From flows into To
+type DataFlowEdge struct {
+	From ast.NodeID `json:"from"`
+	To   ast.NodeID `json:"to"`
+}
+
+// DataFlowGraph is the subgraph of a function's variables and expressions
+// connected by DATA_FLOW relations
+type DataFlowGraph struct {
+	FunctionID ast.NodeID
+	Nodes      []*ast.Node    // Nodes with at least one edge, ordered by ID
+	Edges      []DataFlowEdge // Ordered by source, then target
+}
+
+// GetDataFlowGraph returns the DATA_FLOW subgraph of a function. Traversal is
+// bounded to the function's scope: only relations between nodes the function
+// contains, directly or through nested blocks, are returned, so flows into
+// fields or other functions are left out.
+func (cg *CodeGraph) GetDataFlowGraph(ctx context.Context, functionID ast.NodeID) (*DataFlowGraph, error) {
+	scope, err := cg.readNodesByQuery(ctx, "n", `
+		MATCH (f:Function {id: $functionId})-[:CONTAINS*]->(n)
+		RETURN DISTINCT n
+	`, map[string]any{"functionId": int64(functionID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scope of function %d: %w", functionID, err)
+	}
+
+	graph := &DataFlowGraph{FunctionID: functionID, Nodes: []*ast.Node{}, Edges: []DataFlowEdge{}}
+	if len(scope) == 0 {
+		return graph, nil
+	}
+
+	inScope := make(map[ast.NodeID]*ast.Node, len(scope))
+	ids := make([]int64, 0, len(scope))
+	for _, node := range scope {
+		inScope[node.ID] = node
+		ids = append(ids, int64(node.ID))
+	}
+
+	records, err := cg.db.ExecuteRead(ctx, `
+		UNWIND $ids AS id
+		MATCH (a {id: id})-[:DATA_FLOW]->(b)
+		WHERE b.id IN $ids
+		RETURN DISTINCT a.id AS fromId, b.id AS toId
+	`, map[string]any{"ids": ids})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data flow of function %d: %w", functionID, err)
+	}
+
+	connected := make(map[ast.NodeID]bool)
+	for _, record := range records {
+		edge := DataFlowEdge{
+			From: ast.NodeID(cg.convertToInt64(record["fromId"])),
+			To:   ast.NodeID(cg.convertToInt64(record["toId"])),
+		}
+		// Checked again here: the scope is authoritative, not the query
+		if inScope[edge.From] == nil || inScope[edge.To] == nil {
+			continue
+		}
+		graph.Edges = append(graph.Edges, edge)
+		connected[edge.From] = true
+		connected[edge.To] = true
+	}
+
+	for id := range connected {
+		graph.Nodes = append(graph.Nodes, inScope[id])
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].ID < graph.Nodes[j].ID })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+	return graph, nil
+}
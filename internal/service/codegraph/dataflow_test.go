@@ -0,0 +1,83 @@
+package codegraph
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/testutil"
+)
+
+func TestGetDataFlowGraph(t *testing.T) {
+	// Function 10 takes x (11) and assigns y := x (13) and z := y (14) in its
+	// body block (12), then stores z into field 20. Function 30 passes its
+	// variable 31 into y. Only the x -> y -> z chain is within function 10.
+	node := func(id int64, nodeType ast.NodeType, name string) map[string]any {
+		return map[string]any{"id": id, "nodeType": int64(nodeType), "fileId": int64(1), "name": name, "version": int64(0), "scopeId": int64(0)}
+	}
+	nodes := map[int64]map[string]any{
+		11: node(11, ast.NodeTypeVariable, "x"),
+		12: node(12, ast.NodeTypeBlock, ""),
+		13: node(13, ast.NodeTypeVariable, "y"),
+		14: node(14, ast.NodeTypeVariable, "z"),
+		20: node(20, ast.NodeTypeField, "total"),
+		31: node(31, ast.NodeTypeVariable, "other"),
+	}
+	contains := map[int64][]int64{10: {11, 12}, 12: {13, 14}, 30: {31}}
+	dataFlow := [][2]int64{{11, 13}, {13, 14}, {14, 20}, {31, 13}}
+
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		switch {
+		case strings.Contains(query, "CONTAINS*"):
+			var records []map[string]any
+			var walk func(id int64)
+			walk = func(id int64) {
+				for _, child := range contains[id] {
+					records = append(records, map[string]any{"n": nodes[child]})
+					walk(child)
+				}
+			}
+			walk(params["functionId"].(int64))
+			return records, nil
+		case strings.Contains(query, "DATA_FLOW"):
+			ids := make(map[int64]bool)
+			for _, id := range params["ids"].([]int64) {
+				ids[id] = true
+			}
+			var records []map[string]any
+			for _, edge := range dataFlow {
+				if ids[edge[0]] && ids[edge[1]] {
+					records = append(records, map[string]any{"fromId": edge[0], "toId": edge[1]})
+				}
+			}
+			return records, nil
+		}
+		return nil, nil
+	}
+	cg, _ := newTestCodeGraph(db)
+
+	graph, err := cg.GetDataFlowGraph(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("GetDataFlowGraph: %v", err)
+	}
+
+	var names []string
+	for _, n := range graph.Nodes {
+		names = append(names, n.Name)
+	}
+	if want := []string{"x", "y", "z"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("nodes = %v, want %v", names, want)
+	}
+	if want := []DataFlowEdge{{From: 11, To: 13}, {From: 13, To: 14}}; !reflect.DeepEqual(graph.Edges, want) {
+		t.Errorf("edges = %v, want %v", graph.Edges, want)
+	}
+
+	// A function without contained nodes has an empty graph
+	empty, err := cg.GetDataFlowGraph(context.Background(), 99)
+	if err != nil || len(empty.Nodes) != 0 || len(empty.Edges) != 0 {
+		t.Errorf("GetDataFlowGraph(99) = %+v, %v; want an empty graph", empty, err)
+	}
+}
@@ -0,0 +1,204 @@
+package codegraph
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"bot-go/internal/model/ast"
+)
+
+// ExportFormat is a serialization format for ExportSubgraph
+type ExportFormat string
+
+const (
+	ExportFormatDOT     ExportFormat = "dot"
+	ExportFormatGraphML ExportFormat = "graphml"
+)
+
+// MaxExportNodes bounds the size of an exported subgraph
+const MaxExportNodes = 5000
+
+// ContentType returns the HTTP content type of the format
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatGraphML:
+		return "application/graphml+xml"
+	default:
+		return "text/vnd.graphviz"
+	}
+}
+
+// ParseExportFormat validates a format name, defaulting to DOT when empty
+func ParseExportFormat(name string) (ExportFormat, error) {
+	switch ExportFormat(strings.ToLower(name)) {
+	case "", ExportFormatDOT:
+		return ExportFormatDOT, nil
+	case ExportFormatGraphML:
+		return ExportFormatGraphML, nil
+	}
+	return "", fmt.Errorf("unsupported export format: %s", name)
+}
+
+// subgraphEdge is a relation collected during export
+type subgraphEdge struct {
+	from  ast.NodeID
+	to    ast.NodeID
+	label string
+}
+
+// ExportSubgraph traverses relations of any type in either direction from the
+// root node up to depth hops and writes the reached nodes and the relations
+// between them to w. Nothing is written if the traversal fails.
+func (cg *CodeGraph) ExportSubgraph(ctx context.Context, rootNodeID ast.NodeID, depth int, format ExportFormat, w io.Writer) error {
+	root, err := cg.GetNodeByID(ctx, rootNodeID)
+	if err != nil {
+		return fmt.Errorf("failed to read root node: %w", err)
+	}
+
+	nodes := map[ast.NodeID]*ast.Node{root.ID: root}
+	edges := make(map[subgraphEdge]bool)
+	frontier := []ast.NodeID{root.ID}
+
+	for hop := 0; hop < depth && len(frontier) > 0; hop++ {
+		var next []ast.NodeID
+		for _, nodeID := range frontier {
+			neighbors, nodeEdges, err := cg.readNeighborhood(ctx, nodeID)
+			if err != nil {
+				return err
+			}
+			for _, edge := range nodeEdges {
+				edges[edge] = true
+			}
+			for _, neighbor := range neighbors {
+				if _, seen := nodes[neighbor.ID]; seen {
+					continue
+				}
+				if len(nodes) >= MaxExportNodes {
+					return fmt.Errorf("subgraph exceeds %d nodes; reduce the depth", MaxExportNodes)
+				}
+				nodes[neighbor.ID] = neighbor
+				next = append(next, neighbor.ID)
+			}
+		}
+		frontier = next
+	}
+
+	// Relations to nodes beyond the depth limit are dropped
+	var kept []subgraphEdge
+	for edge := range edges {
+		if nodes[edge.from] != nil && nodes[edge.to] != nil {
+			kept = append(kept, edge)
+		}
+	}
+
+	sortedNodes := make([]*ast.Node, 0, len(nodes))
+	for _, node := range nodes {
+		sortedNodes = append(sortedNodes, node)
+	}
+	sort.Slice(sortedNodes, func(i, j int) bool { return sortedNodes[i].ID < sortedNodes[j].ID })
+	sort.Slice(kept, func(i, j int) bool {
+		if kept[i].from != kept[j].from {
+			return kept[i].from < kept[j].from
+		}
+		if kept[i].to != kept[j].to {
+			return kept[i].to < kept[j].to
+		}
+		return kept[i].label < kept[j].label
+	})
+
+	if format == ExportFormatGraphML {
+		return cg.writeGraphML(w, sortedNodes, kept)
+	}
+	return cg.writeDOT(w, sortedNodes, kept)
+}
+
+// readNeighborhood returns the nodes adjacent to a node and the relations connecting them
+func (cg *CodeGraph) readNeighborhood(ctx context.Context, nodeID ast.NodeID) ([]*ast.Node, []subgraphEdge, error) {
+	query := `
+		MATCH (n {id: $nodeId})-[r]-(m)
+		RETURN startNode(r).id as fromId, endNode(r).id as toId, type(r) as label, m
+	`
+	records, err := cg.db.ExecuteRead(ctx, query, map[string]any{"nodeId": int64(nodeID)})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read neighbors of node %d: %w", nodeID, err)
+	}
+
+	var neighbors []*ast.Node
+	var edges []subgraphEdge
+	for _, record := range records {
+		nodeMap, ok := record["m"].(map[string]any)
+		if !ok {
+			continue
+		}
+		neighbor, err := cg.recordToNode(nodeMap)
+		if err != nil {
+			return nil, nil, err
+		}
+		label, _ := record["label"].(string)
+
+		neighbors = append(neighbors, neighbor)
+		edges = append(edges, subgraphEdge{
+			from:  ast.NodeID(cg.convertToInt64(record["fromId"])),
+			to:    ast.NodeID(cg.convertToInt64(record["toId"])),
+			label: label,
+		})
+	}
+	return neighbors, edges, nil
+}
+
+func (cg *CodeGraph) writeDOT(w io.Writer, nodes []*ast.Node, edges []subgraphEdge) error {
+	var b strings.Builder
+	b.WriteString("digraph codegraph {\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, node := range nodes {
+		label := cg.getNodeLabel(node.NodeType)
+		if node.Name != "" {
+			label += "\n" + node.Name
+		}
+		fmt.Fprintf(&b, "  n%d [label=\"%s\"];\n", node.ID, dotEscape(label))
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "  n%d -> n%d [label=\"%s\"];\n", edge.from, edge.to, dotEscape(edge.label))
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (cg *CodeGraph) writeGraphML(w io.Writer, nodes []*ast.Node, edges []subgraphEdge) error {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString("<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+	b.WriteString("  <key id=\"label\" for=\"node\" attr.name=\"label\" attr.type=\"string\"/>\n")
+	b.WriteString("  <key id=\"name\" for=\"node\" attr.name=\"name\" attr.type=\"string\"/>\n")
+	b.WriteString("  <key id=\"relation\" for=\"edge\" attr.name=\"relation\" attr.type=\"string\"/>\n")
+	b.WriteString("  <graph id=\"codegraph\" edgedefault=\"directed\">\n")
+	for _, node := range nodes {
+		fmt.Fprintf(&b, "    <node id=\"n%d\"><data key=\"label\">%s</data><data key=\"name\">%s</data></node>\n",
+			node.ID, xmlEscape(cg.getNodeLabel(node.NodeType)), xmlEscape(node.Name))
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(&b, "    <edge source=\"n%d\" target=\"n%d\"><data key=\"relation\">%s</data></edge>\n",
+			edge.from, edge.to, xmlEscape(edge.label))
+	}
+	b.WriteString("  </graph>\n")
+	b.WriteString("</graphml>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
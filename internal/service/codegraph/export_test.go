@@ -0,0 +1,132 @@
+package codegraph
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/testutil"
+)
+
+// newExportTestGraph serves a synthetic graph: file 1 contains class 10, which
+// contains method 11, which calls function 12
+func newExportTestGraph() *CodeGraph {
+	nodes := map[int64]map[string]any{
+		1:  {"id": int64(1), "nodeType": int64(ast.NodeTypeFileScope), "fileId": int64(1), "name": "service.go", "version": int64(0), "scopeId": int64(0)},
+		10: {"id": int64(10), "nodeType": int64(ast.NodeTypeClass), "fileId": int64(1), "name": "Service", "version": int64(0), "scopeId": int64(1)},
+		11: {"id": int64(11), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(1), "name": "Run", "version": int64(0), "scopeId": int64(10)},
+		12: {"id": int64(12), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(1), "name": `say "hi"`, "version": int64(0), "scopeId": int64(1)},
+	}
+	relations := []struct {
+		from, to int64
+		label    string
+	}{
+		{1, 10, "CONTAINS"},
+		{10, 11, "CONTAINS"},
+		{11, 12, "CALLS_FUNCTION"},
+	}
+
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		if nodeID, ok := params["nodeId"].(int64); ok {
+			var records []map[string]any
+			for _, rel := range relations {
+				other := int64(-1)
+				if rel.from == nodeID {
+					other = rel.to
+				} else if rel.to == nodeID {
+					other = rel.from
+				}
+				if other >= 0 {
+					records = append(records, map[string]any{
+						"fromId": rel.from, "toId": rel.to, "label": rel.label, "m": nodes[other],
+					})
+				}
+			}
+			return records, nil
+		}
+		if id, ok := params["id"].(int64); ok && strings.Contains(query, "MATCH (n {id: $id})") && nodes[id] != nil {
+			return []map[string]any{{"n": nodes[id]}}, nil
+		}
+		return nil, nil
+	}
+
+	cg, _ := newTestCodeGraph(db)
+	return cg
+}
+
+func TestExportSubgraph(t *testing.T) {
+	tests := []struct {
+		name    string
+		depth   int
+		format  ExportFormat
+		want    []string
+		notWant []string
+	}{
+		{
+			name:   "dot depth 1",
+			depth:  1,
+			format: ExportFormatDOT,
+			want: []string{
+				"digraph codegraph {",
+				`n1 [label="FileScope\nservice.go"];`,
+				`n10 [label="Class\nService"];`,
+				`n11 [label="Function\nRun"];`,
+				`n1 -> n10 [label="CONTAINS"];`,
+				`n10 -> n11 [label="CONTAINS"];`,
+			},
+			notWant: []string{"n12", "CALLS_FUNCTION"},
+		},
+		{
+			name:   "dot depth 2 escapes names",
+			depth:  2,
+			format: ExportFormatDOT,
+			want: []string{
+				`n12 [label="Function\nsay \"hi\""];`,
+				`n11 -> n12 [label="CALLS_FUNCTION"];`,
+			},
+		},
+		{
+			name:   "graphml",
+			depth:  2,
+			format: ExportFormatGraphML,
+			want: []string{
+				`<graph id="codegraph" edgedefault="directed">`,
+				`<node id="n10"><data key="label">Class</data><data key="name">Service</data></node>`,
+				`<data key="name">say &#34;hi&#34;</data>`,
+				`<edge source="n11" target="n12"><data key="relation">CALLS_FUNCTION</data></edge>`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := newExportTestGraph().ExportSubgraph(context.Background(), 10, tt.depth, tt.format, &out); err != nil {
+				t.Fatalf("ExportSubgraph failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out.String(), notWant) {
+					t.Errorf("output should not contain %q:\n%s", notWant, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestExportSubgraphMissingRoot(t *testing.T) {
+	var out bytes.Buffer
+	if err := newExportTestGraph().ExportSubgraph(context.Background(), 99, 1, ExportFormatDOT, &out); err == nil {
+		t.Fatal("expected an error for a missing root node")
+	}
+	if out.Len() != 0 {
+		t.Errorf("nothing should be written on failure, got %q", out.String())
+	}
+}
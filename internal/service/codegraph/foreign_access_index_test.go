@@ -0,0 +1,99 @@
+package codegraph_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/signals"
+	"bot-go/internal/signals/coupling"
+	"bot-go/internal/testutil"
+)
+
+// resolveCallsByName links each function call to the function of that name in
+// another file, standing in for the call resolution of post-processing
+func resolveCallsByName(t *testing.T, cg *codegraph.CodeGraph, graph *testutil.GraphRecorder) {
+	t.Helper()
+	for _, call := range graph.Nodes("FunctionCall") {
+		for _, function := range graph.Nodes("Function") {
+			if function["name"] != call["name"] || function["fileId"] == call["fileId"] {
+				continue
+			}
+			err := cg.CreateCallsFunctionRelation(context.Background(),
+				ast.NodeID(call["id"].(int64)), ast.NodeID(function["id"].(int64)), int32(call["fileId"].(int64)))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestATFDFromIndexedRepository(t *testing.T) {
+	// Invoice.render reads the total of the user's Order through its getter
+	// and the width of the standard library's TextWrapper through its own
+	files := map[string]string{
+		"shop/order.py": `class Order:
+    def get_total(self):
+        return self.total
+
+    def recalculate(self):
+        for item in self.items:
+            self.total += item
+`,
+		"lib/python3/textwrap.py": `class TextWrapper:
+    def get_width(self):
+        return self.width
+`,
+		"shop/invoice.py": `class Invoice:
+    def render(self):
+        self.order.recalculate()
+        return self.order.get_total() + self.wrapper.get_width()
+`,
+	}
+	cg, graph := indexRepository(t, "demo", files)
+	resolveCallsByName(t, cg, graph)
+	graph.ReadFunc = answerClassReads(graph)
+	invoice := classNode(t, graph, "Invoice").ID
+
+	ctx := context.Background()
+	accesses, err := cg.GetForeignAccesses(ctx, invoice)
+	if err != nil {
+		t.Fatalf("GetForeignAccesses failed: %v", err)
+	}
+	var names []string
+	for _, access := range accesses {
+		names = append(names, access.OwnerPath+":"+access.MemberName)
+	}
+	sort.Strings(names)
+	want := []string{"lib/python3/textwrap.py:get_width", "shop/order.py:get_total", "shop/order.py:recalculate"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("foreign methods = %v, want %v", names, want)
+	}
+
+	// recalculate is behavior rather than data, and the standard library's
+	// getter only counts until its package is configured as external
+	tests := []struct {
+		name     string
+		external []string
+		want     float64
+	}{
+		{"no external packages", nil, 2},
+		{"stdlib excluded", []string{"lib/python3/"}, 1},
+	}
+	classInfo := signals.NewClassInfo(invoice, "Invoice", "shop/invoice.py", 3)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sctx := &signals.SignalContext{CodeGraph: cg, ExternalPackages: tt.external}
+			result, err := coupling.NewATFDSignal().ComputeClass(ctx, classInfo, sctx)
+			if err != nil || result.Error != nil {
+				t.Fatalf("ComputeClass: %v %v", err, result.Error)
+			}
+			if result.Value != tt.want {
+				t.Errorf("ATFD = %v, want %v (metadata %v)", result.Value, tt.want, result.Metadata)
+			}
+		})
+	}
+}
@@ -3,6 +3,10 @@ package codegraph
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
+
+	"bot-go/internal/config"
 
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	"go.uber.org/zap"
@@ -14,9 +18,25 @@ type Neo4jDatabase struct {
 	logger *zap.Logger
 }
 
+// newNeo4jDriver creates the driver; replaced in tests to inspect the driver configuration
+var newNeo4jDriver = neo4j.NewDriverWithContext
+
 // NewNeo4jDatabase creates a new Neo4j database instance
-func NewNeo4jDatabase(uri, username, password string, logger *zap.Logger) (*Neo4jDatabase, error) {
-	driver, err := neo4j.NewDriverWithContext(uri, neo4j.BasicAuth(username, password, ""))
+func NewNeo4jDatabase(neo4jConfig config.Neo4jConfig, logger *zap.Logger) (*Neo4jDatabase, error) {
+	uri := neo4jConfig.URI
+	if neo4jConfig.Encrypted {
+		uri = encryptedURI(uri)
+	}
+
+	driver, err := newNeo4jDriver(uri, neo4j.BasicAuth(neo4jConfig.Username, neo4jConfig.Password, ""),
+		func(driverConfig *neo4j.Config) {
+			if neo4jConfig.MaxPoolSize > 0 {
+				driverConfig.MaxConnectionPoolSize = neo4jConfig.MaxPoolSize
+			}
+			if neo4jConfig.ConnectionTimeout > 0 {
+				driverConfig.SocketConnectTimeout = time.Duration(neo4jConfig.ConnectionTimeout) * time.Second
+			}
+		})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Neo4j driver: %w", err)
 	}
@@ -29,6 +49,17 @@ func NewNeo4jDatabase(uri, username, password string, logger *zap.Logger) (*Neo4
 	return db, nil
 }
 
+// encryptedURI switches plain bolt:// and neo4j:// URIs to their TLS schemes.
+// Other schemes already state their encryption and are returned unchanged.
+func encryptedURI(uri string) string {
+	for _, scheme := range []string{"bolt", "neo4j"} {
+		if strings.HasPrefix(uri, scheme+"://") {
+			return scheme + "+s://" + strings.TrimPrefix(uri, scheme+"://")
+		}
+	}
+	return uri
+}
+
 // VerifyConnectivity checks if the database connection is working
 func (db *Neo4jDatabase) VerifyConnectivity(ctx context.Context) error {
 	return db.driver.VerifyConnectivity(ctx)
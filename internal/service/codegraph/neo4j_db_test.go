@@ -0,0 +1,84 @@
+package codegraph
+
+import (
+	"testing"
+	"time"
+
+	"bot-go/internal/config"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j/auth"
+	"go.uber.org/zap"
+)
+
+func TestNewNeo4jDatabaseDriverConfig(t *testing.T) {
+	defaults := &neo4j.Config{MaxConnectionPoolSize: 100, SocketConnectTimeout: 5 * time.Second}
+
+	tests := []struct {
+		name        string
+		config      config.Neo4jConfig
+		wantURI     string
+		wantPool    int
+		wantTimeout time.Duration
+	}{
+		{
+			name:        "defaults when unset",
+			config:      config.Neo4jConfig{URI: "bolt://localhost:7687"},
+			wantURI:     "bolt://localhost:7687",
+			wantPool:    100,
+			wantTimeout: 5 * time.Second,
+		},
+		{
+			name:        "pool size and timeout",
+			config:      config.Neo4jConfig{URI: "neo4j://db:7687", MaxPoolSize: 25, ConnectionTimeout: 30},
+			wantURI:     "neo4j://db:7687",
+			wantPool:    25,
+			wantTimeout: 30 * time.Second,
+		},
+		{
+			name:        "encrypted upgrades scheme",
+			config:      config.Neo4jConfig{URI: "neo4j://xyz.databases.neo4j.io", Encrypted: true},
+			wantURI:     "neo4j+s://xyz.databases.neo4j.io",
+			wantPool:    100,
+			wantTimeout: 5 * time.Second,
+		},
+		{
+			name:        "encrypted keeps explicit scheme",
+			config:      config.Neo4jConfig{URI: "bolt+ssc://db:7687", Encrypted: true},
+			wantURI:     "bolt+ssc://db:7687",
+			wantPool:    100,
+			wantTimeout: 5 * time.Second,
+		},
+	}
+
+	original := newNeo4jDriver
+	defer func() { newNeo4jDriver = original }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotURI string
+			var got neo4j.Config
+			newNeo4jDriver = func(target string, tokens auth.TokenManager, configurers ...func(*neo4j.Config)) (neo4j.DriverWithContext, error) {
+				gotURI = target
+				got = *defaults
+				for _, configure := range configurers {
+					configure(&got)
+				}
+				return nil, nil
+			}
+
+			if _, err := NewNeo4jDatabase(tt.config, zap.NewNop()); err != nil {
+				t.Fatalf("NewNeo4jDatabase failed: %v", err)
+			}
+			if gotURI != tt.wantURI {
+				t.Errorf("uri = %q, want %q", gotURI, tt.wantURI)
+			}
+			if got.MaxConnectionPoolSize != tt.wantPool {
+				t.Errorf("MaxConnectionPoolSize = %d, want %d", got.MaxConnectionPoolSize, tt.wantPool)
+			}
+			if got.SocketConnectTimeout != tt.wantTimeout {
+				t.Errorf("SocketConnectTimeout = %v, want %v", got.SocketConnectTimeout, tt.wantTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package codegraph
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+)
+
+// PackageMetrics holds Robert Martin's package-level coupling metrics. A
+// package is the directory of its files; nodes belong to the package of the
+// file scope they were parsed from.
+type PackageMetrics struct {
+	Package          string  `json:"package"` // Repo-relative directory ("." for the root)
+	Files            int     `json:"files"`
+	Classes          int     `json:"classes"`
+	AbstractClasses  int     `json:"abstract_classes"`
+	AfferentCoupling int     `json:"afferent_coupling"` // Ca: packages depending on this one
+	EfferentCoupling int     `json:"efferent_coupling"` // Ce: packages this one depends on
+	Instability      float64 `json:"instability"`       // Ce / (Ca + Ce), 0 for an uncoupled package
+	Abstractness     float64 `json:"abstractness"`      // Abstract classes / classes, 0 without classes
+	Distance         float64 `json:"distance"`          // |A + I - 1|, distance from the main sequence
+}
+
+// PackageMetrics computes coupling metrics for every package of a repository.
+// A package depends on another when one of its files imports it (Import nodes
+// resolved against the repository's packages), calls one of its functions
+// (CALLS_FUNCTION) or has a class inheriting from one of its classes;
+// dependencies on code outside the repository are ignored. Classes are
+// abstract when marked with the abstract metadata flag (Go interfaces and
+// Python ABCs). Packages are ordered by name.
+func (cg *CodeGraph) PackageMetrics(ctx context.Context, repoName string) ([]PackageMetrics, error) {
+	params := map[string]any{"repo": repoName}
+
+	fileRecords, err := cg.db.ExecuteRead(ctx, `
+		MATCH (f:FileScope {repo: $repo})
+		RETURN f.id AS fileId, f.path AS path, f.language AS language
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file scopes: %w", err)
+	}
+
+	metrics := make(map[string]*PackageMetrics)
+	filePackages := make(map[int64]string)
+	fileLanguages := make(map[int64]string)
+	for _, record := range fileRecords {
+		fileID, ok := record["fileId"].(int64)
+		filePath, _ := record["path"].(string)
+		if !ok || filePath == "" {
+			continue
+		}
+		pkg := path.Dir(filePath)
+		filePackages[fileID] = pkg
+		fileLanguages[fileID], _ = record["language"].(string)
+		if metrics[pkg] == nil {
+			metrics[pkg] = &PackageMetrics{Package: pkg}
+		}
+		metrics[pkg].Files++
+	}
+
+	classRecords, err := cg.db.ExecuteRead(ctx, `
+		MATCH (f:FileScope {repo: $repo})
+		MATCH (c:Class {fileId: f.id})
+		RETURN c.fileId AS fileId, c.md_abstract AS abstract
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read classes: %w", err)
+	}
+	for _, record := range classRecords {
+		pkg, ok := recordPackage(record, "fileId", filePackages)
+		if !ok {
+			continue
+		}
+		metrics[pkg].Classes++
+		if abstract, _ := record["abstract"].(bool); abstract {
+			metrics[pkg].AbstractClasses++
+		}
+	}
+
+	efferent := make(map[string]map[string]bool)
+	afferent := make(map[string]map[string]bool)
+	addDependency := func(from, to string) {
+		if from == to {
+			return
+		}
+		addPackageEdge(efferent, from, to)
+		addPackageEdge(afferent, to, from)
+	}
+
+	importRecords, err := cg.db.ExecuteRead(ctx, `
+		MATCH (f:FileScope {repo: $repo})
+		MATCH (i:Import {fileId: f.id})
+		RETURN i.fileId AS fileId, i.md_importPath AS importPath
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read imports: %w", err)
+	}
+	for _, record := range importRecords {
+		from, ok := recordPackage(record, "fileId", filePackages)
+		importPath, _ := record["importPath"].(string)
+		if !ok || importPath == "" {
+			continue
+		}
+		language := fileLanguages[record["fileId"].(int64)]
+		if to, ok := resolveImportPackage(language, from, importPath, metrics); ok {
+			addDependency(from, to)
+		}
+	}
+
+	// INHERITS points from the parent class to the child, so the child's
+	// package is the one that depends on the parent's
+	dependencyRecords, err := cg.db.ExecuteRead(ctx, `
+		MATCH (f:FileScope {repo: $repo})
+		MATCH (a {fileId: f.id})-[r:IMPORTS|CALLS_FUNCTION|INHERITS]->(b)
+		RETURN DISTINCT a.fileId AS fromFileId, b.fileId AS toFileId, type(r) AS relation
+	`, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package dependencies: %w", err)
+	}
+	for _, record := range dependencyRecords {
+		from, ok := recordPackage(record, "fromFileId", filePackages)
+		if !ok {
+			continue
+		}
+		to, ok := recordPackage(record, "toFileId", filePackages)
+		if !ok {
+			continue
+		}
+		if relation, _ := record["relation"].(string); relation == "INHERITS" {
+			from, to = to, from
+		}
+		addDependency(from, to)
+	}
+
+	result := make([]PackageMetrics, 0, len(metrics))
+	for pkg, m := range metrics {
+		m.EfferentCoupling = len(efferent[pkg])
+		m.AfferentCoupling = len(afferent[pkg])
+		if coupling := m.AfferentCoupling + m.EfferentCoupling; coupling > 0 {
+			m.Instability = float64(m.EfferentCoupling) / float64(coupling)
+		}
+		if m.Classes > 0 {
+			m.Abstractness = float64(m.AbstractClasses) / float64(m.Classes)
+		}
+		m.Distance = math.Abs(m.Abstractness + m.Instability - 1)
+		result = append(result, *m)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Package < result[j].Package
+	})
+	return result, nil
+}
+
+// recordPackage resolves the package of the file ID stored under key
+func recordPackage(record map[string]any, key string, filePackages map[int64]string) (string, bool) {
+	fileID, ok := record[key].(int64)
+	if !ok {
+		return "", false
+	}
+	pkg, ok := filePackages[fileID]
+	return pkg, ok
+}
+
+// resolveImportPackage maps an import path to the repository package it
+// names. Python imports are dotted module paths, possibly relative to the
+// importing package; other imports (e.g. Go) end with the package directory,
+// and the longest matching package wins. Imports of code outside the
+// repository don't resolve.
+func resolveImportPackage(language, importerPkg, importPath string, packages map[string]*PackageMetrics) (string, bool) {
+	if language == "python" {
+		dots := len(importPath) - len(strings.TrimLeft(importPath, "."))
+		modulePath := strings.ReplaceAll(importPath[dots:], ".", "/")
+		if dots > 0 {
+			base := importerPkg
+			for i := 1; i < dots; i++ {
+				base = path.Dir(base)
+			}
+			modulePath = path.Join(base, modulePath)
+		}
+		// The module is either a package directory or a file in one
+		for _, candidate := range []string{modulePath, path.Dir(modulePath)} {
+			if packages[candidate] != nil {
+				return candidate, true
+			}
+		}
+		return "", false
+	}
+
+	if strings.HasPrefix(importPath, ".") {
+		candidate := path.Join(importerPkg, importPath)
+		for _, pkg := range []string{candidate, path.Dir(candidate)} {
+			if packages[pkg] != nil {
+				return pkg, true
+			}
+		}
+		return "", false
+	}
+	if packages[importPath] != nil {
+		return importPath, true
+	}
+	best := ""
+	for pkg := range packages {
+		if pkg != "." && strings.HasSuffix(importPath, "/"+pkg) && len(pkg) > len(best) {
+			best = pkg
+		}
+	}
+	return best, best != ""
+}
+
+func addPackageEdge(edges map[string]map[string]bool, from, to string) {
+	if edges[from] == nil {
+		edges[from] = make(map[string]bool)
+	}
+	edges[from][to] = true
+}
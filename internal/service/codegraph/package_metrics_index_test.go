@@ -0,0 +1,167 @@
+package codegraph_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/parse"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+// indexRepository parses files (repo-relative path to source) with the real
+// parser into a graph recorder and returns the code graph reading from it
+func indexRepository(t *testing.T, repoName string, files map[string]string) (*codegraph.CodeGraph, *testutil.GraphRecorder) {
+	t.Helper()
+
+	repo := &config.Repository{Name: repoName, Path: t.TempDir()}
+	graph := testutil.NewGraphRecorder()
+	cfg := &config.Config{}
+	cg := codegraph.NewCodeGraphWithDatabase(graph, cfg, zap.NewNop())
+	parser := parse.NewFileParser(zap.NewNop(), cg, cfg)
+
+	fileID := int32(1)
+	for relPath, source := range files {
+		fullPath := filepath.Join(repo.Path, relPath)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := parser.ParseAndTraverseWithContent(context.Background(), repo, info, fullPath, fileID, 1, []byte(source)); err != nil {
+			t.Fatalf("failed to index %s: %v", relPath, err)
+		}
+		fileID++
+	}
+	return cg, graph
+}
+
+// answerPackageMetricsReads answers PackageMetrics' queries from the recorded
+// graph, the way Neo4j would
+func answerPackageMetricsReads(graph *testutil.GraphRecorder) testutil.GraphQueryFunc {
+	return func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		repoFiles := make(map[int64]bool)
+		var files []map[string]any
+		for _, f := range graph.Nodes("FileScope") {
+			if f["repo"] == params["repo"] {
+				repoFiles[f["id"].(int64)] = true
+				files = append(files, map[string]any{"fileId": f["id"], "path": f["path"], "language": f["language"]})
+			}
+		}
+
+		var records []map[string]any
+		switch {
+		case strings.Contains(query, "CALLS_FUNCTION"):
+			seen := make(map[[2]any]bool)
+			for _, label := range []string{"IMPORTS", "CALLS_FUNCTION", "INHERITS"} {
+				for _, rel := range graph.Relations(label) {
+					from, to := graph.Node(rel.ParentID)["fileId"], graph.Node(rel.ChildID)["fileId"]
+					if !repoFiles[from.(int64)] || seen[[2]any{from, to}] {
+						continue
+					}
+					seen[[2]any{from, to}] = true
+					records = append(records, map[string]any{"fromFileId": from, "toFileId": to, "relation": label})
+				}
+			}
+		case strings.Contains(query, "MATCH (i:Import"):
+			for _, i := range graph.Nodes("Import") {
+				if repoFiles[i["fileId"].(int64)] {
+					records = append(records, map[string]any{"fileId": i["fileId"], "importPath": i["md_importPath"]})
+				}
+			}
+		case strings.Contains(query, "MATCH (c:Class"):
+			for _, c := range graph.Nodes("Class") {
+				if repoFiles[c["fileId"].(int64)] {
+					records = append(records, map[string]any{"fileId": c["fileId"], "abstract": c["md_abstract"]})
+				}
+			}
+		default:
+			records = files
+		}
+		return records, nil
+	}
+}
+
+func TestPackageMetricsFromIndexedRepository(t *testing.T) {
+	files := map[string]string{
+		"store/store.go": `package store
+
+type Store interface {
+	Get(key string) string
+}
+`,
+		"store/mem.go": `package store
+
+type Mem struct {
+	data map[string]string
+}
+`,
+		"api/api.go": `package api
+
+import (
+	"fmt"
+
+	"example.com/demo/store"
+)
+
+func Describe(s store.Store) string {
+	return fmt.Sprint(s.Get("name"))
+}
+`,
+		"cmd/main.go": `package main
+
+import "example.com/demo/api"
+
+func main() {
+	api.Describe(nil)
+}
+`,
+		"py/base.py": `from abc import ABC, abstractmethod
+
+
+class Base(ABC):
+    @abstractmethod
+    def run(self):
+        pass
+`,
+		"py/jobs/worker.py": `import os
+
+from ..base import Base
+
+
+class Worker(Base):
+    def run(self):
+        return os.getcwd()
+`,
+	}
+	cg, graph := indexRepository(t, "demo", files)
+	graph.ReadFunc = answerPackageMetricsReads(graph)
+
+	got, err := cg.PackageMetrics(context.Background(), "demo")
+	if err != nil {
+		t.Fatalf("PackageMetrics failed: %v", err)
+	}
+
+	want := []codegraph.PackageMetrics{
+		{Package: "api", Files: 1, AfferentCoupling: 1, EfferentCoupling: 1, Instability: 0.5, Distance: 0.5},
+		{Package: "cmd", Files: 1, EfferentCoupling: 1, Instability: 1},
+		{Package: "py", Files: 1, Classes: 1, AbstractClasses: 1, AfferentCoupling: 1, Abstractness: 1},
+		{Package: "py/jobs", Files: 1, Classes: 1, EfferentCoupling: 1, Instability: 1},
+		{Package: "store", Files: 2, Classes: 2, AbstractClasses: 1, AfferentCoupling: 1, Abstractness: 0.5, Distance: 0.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PackageMetrics =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,69 @@
+package codegraph
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot-go/internal/testutil"
+)
+
+func TestPackageMetrics(t *testing.T) {
+	// cmd imports api; api imports store, calls into it and extends one of its
+	// classes; store depends only on itself
+	files := []map[string]any{
+		{"fileId": int64(1), "path": "api/handler.go", "language": "go"},
+		{"fileId": int64(2), "path": "store/db.go", "language": "go"},
+		{"fileId": int64(3), "path": "store/iface.go", "language": "go"},
+		{"fileId": int64(4), "path": "cmd/main.go", "language": "go"},
+	}
+	classes := []map[string]any{
+		{"fileId": int64(1), "abstract": nil},
+		{"fileId": int64(2), "abstract": nil},
+		{"fileId": int64(3), "abstract": true},
+	}
+	imports := []map[string]any{
+		{"fileId": int64(1), "importPath": "example.com/demo/store"},
+		{"fileId": int64(4), "importPath": "example.com/demo/api"},
+		{"fileId": int64(4), "importPath": "fmt"}, // Outside the repository
+	}
+	dependencies := []map[string]any{
+		{"fromFileId": int64(1), "toFileId": int64(2), "relation": "CALLS_FUNCTION"},
+		{"fromFileId": int64(3), "toFileId": int64(1), "relation": "INHERITS"},        // Parent to child
+		{"fromFileId": int64(2), "toFileId": int64(3), "relation": "CALLS_FUNCTION"},  // Within store
+		{"fromFileId": int64(1), "toFileId": int64(99), "relation": "CALLS_FUNCTION"}, // Outside the repository
+	}
+
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		if params["repo"] != "demo" {
+			return nil, nil
+		}
+		switch {
+		case strings.Contains(query, "CALLS_FUNCTION"):
+			return dependencies, nil
+		case strings.Contains(query, "MATCH (i:Import"):
+			return imports, nil
+		case strings.Contains(query, "MATCH (c:Class"):
+			return classes, nil
+		default:
+			return files, nil
+		}
+	}
+	cg, _ := newTestCodeGraph(db)
+
+	got, err := cg.PackageMetrics(context.Background(), "demo")
+	if err != nil {
+		t.Fatalf("PackageMetrics failed: %v", err)
+	}
+
+	want := []PackageMetrics{
+		{Package: "api", Files: 1, Classes: 1, AfferentCoupling: 1, EfferentCoupling: 1, Instability: 0.5, Distance: 0.5},
+		{Package: "cmd", Files: 1, EfferentCoupling: 1, Instability: 1},
+		{Package: "store", Files: 2, Classes: 2, AbstractClasses: 1, AfferentCoupling: 1, Abstractness: 0.5, Distance: 0.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("PackageMetrics =\n%+v\nwant\n%+v", got, want)
+	}
+}
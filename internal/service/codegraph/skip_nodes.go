@@ -0,0 +1,104 @@
+package codegraph
+
+import (
+	"bot-go/internal/model/ast"
+
+	"go.uber.org/zap"
+)
+
+// skippedNode tracks a node whose type is not persisted so containment can be
+// routed around it
+type skippedNode struct {
+	fileID int32
+	// Node containing this one, once its CONTAINS relation has been seen
+	parent ast.NodeID
+	// Persisted descendants waiting for parent to be known
+	pending []ast.NodeID
+}
+
+// skipNodeTypes resolves the configured node type labels to skip. Unknown
+// labels are ignored, as is FileScope, which every file needs as its root.
+func (cg *CodeGraph) skipNodeTypes(labels []string) map[ast.NodeType]bool {
+	if len(labels) == 0 {
+		return nil
+	}
+
+	byLabel := make(map[string]ast.NodeType)
+	for nodeType := ast.NodeTypeModuleScope; nodeType <= ast.NodeTypeImport; nodeType++ {
+		byLabel[cg.getNodeLabel(nodeType)] = nodeType
+	}
+
+	skip := make(map[ast.NodeType]bool, len(labels))
+	for _, label := range labels {
+		nodeType, ok := byLabel[label]
+		if !ok || nodeType == ast.NodeTypeFileScope {
+			cg.logger.Warn("Ignoring node type that cannot be skipped", zap.String("node_type", label))
+			continue
+		}
+		skip[nodeType] = true
+	}
+	return skip
+}
+
+// skipNode records a node of a skipped type instead of writing it
+func (cg *CodeGraph) skipNode(node *ast.Node) {
+	cg.skipMutex.Lock()
+	defer cg.skipMutex.Unlock()
+	cg.skippedNodes[node.ID] = &skippedNode{fileID: node.FileID}
+	cg.skippedByFile[node.FileID] = append(cg.skippedByFile[node.FileID], node.ID)
+}
+
+// releaseSkippedNodes forgets the skipped nodes of a file once it is processed
+func (cg *CodeGraph) releaseSkippedNodes(fileID int32) {
+	cg.skipMutex.Lock()
+	defer cg.skipMutex.Unlock()
+	for _, nodeID := range cg.skippedByFile[fileID] {
+		if skipped := cg.skippedNodes[nodeID]; len(skipped.pending) > 0 {
+			cg.logger.Debug("Dropping containment of nodes under an unattached skipped node",
+				zap.Int64("node_id", int64(nodeID)),
+				zap.Int("count", len(skipped.pending)))
+		}
+		delete(cg.skippedNodes, nodeID)
+	}
+	delete(cg.skippedByFile, fileID)
+}
+
+// resolveSkippedRelation maps a relation onto the persisted nodes. A CONTAINS
+// relation is re-pointed from skipped nodes to their nearest persisted
+// ancestor; since the translator relates children before their parents are
+// contained, descendants of a skipped node wait until its own parent is seen.
+// Any other relation touching a skipped node is dropped. It returns the
+// parent and children to relate, with no children when nothing is written.
+func (cg *CodeGraph) resolveSkippedRelation(parentNodeID, childNodeID ast.NodeID, relationLabel string) (ast.NodeID, []ast.NodeID) {
+	cg.skipMutex.Lock()
+	defer cg.skipMutex.Unlock()
+
+	if relationLabel != "CONTAINS" {
+		if cg.skippedNodes[parentNodeID] != nil || cg.skippedNodes[childNodeID] != nil {
+			return parentNodeID, nil
+		}
+		return parentNodeID, []ast.NodeID{childNodeID}
+	}
+
+	children := []ast.NodeID{childNodeID}
+	if skipped := cg.skippedNodes[childNodeID]; skipped != nil {
+		skipped.parent = parentNodeID
+		children, skipped.pending = skipped.pending, nil
+	}
+
+	// A chain longer than the skipped nodes can only be a containment cycle
+	for steps := 0; cg.skippedNodes[parentNodeID] != nil; steps++ {
+		skipped := cg.skippedNodes[parentNodeID]
+		if skipped.parent == ast.InvalidNodeID {
+			skipped.pending = append(skipped.pending, children...)
+			return parentNodeID, nil
+		}
+		if steps > len(cg.skippedNodes) {
+			cg.logger.Warn("Dropping containment through a cycle of skipped nodes",
+				zap.Int64("node_id", int64(parentNodeID)))
+			return parentNodeID, nil
+		}
+		parentNodeID = skipped.parent
+	}
+	return parentNodeID, children
+}
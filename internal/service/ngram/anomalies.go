@@ -0,0 +1,138 @@
+package ngram
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// FunctionAnomaly is a function whose code is unusually surprising under its
+// repository's model, a candidate for bug-prone code
+type FunctionAnomaly struct {
+	NodeID     ast.NodeID `json:"node_id"`
+	Name       string     `json:"name"`
+	FilePath   string     `json:"file_path"`
+	Language   string     `json:"language"`
+	StartLine  int        `json:"start_line"` // 1-based, inclusive
+	EndLine    int        `json:"end_line"`
+	TokenCount int        `json:"token_count"`
+	Entropy    float64    `json:"entropy"`
+	ZScore     float64    `json:"z_score"`
+}
+
+// FindAnomalousFunctions scores the source of every function the code graph
+// holds for a repository against the repository's model, and returns those
+// whose z-score is at least minZScore, highest first. A function's source is
+// read from its file using the node's range; functions whose file cannot be
+// read or tokenized, that have fewer tokens than the model's order, or whose
+// language's file entropies have no spread to compare against, are skipped.
+func (ns *NGramService) FindAnomalousFunctions(ctx context.Context, cg *codegraph.CodeGraph, repo *config.Repository, minZScore float64) ([]FunctionAnomaly, error) {
+	cm, err := ns.GetCorpusManager(repo.Name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	functions, err := cg.FindFunctionsInRepo(ctx, repo.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read functions of %s: %w", repo.Name, err)
+	}
+
+	// Each file's path, language and lines are looked up once, however many
+	// functions it holds
+	type functionFile struct {
+		path     string // Empty when the file is unknown or has no tokenizer
+		language string
+		lines    []string
+	}
+	files := make(map[int32]*functionFile)
+	var anomalies []FunctionAnomaly
+	for _, function := range functions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		file, ok := files[function.FileID]
+		if !ok {
+			file = &functionFile{}
+			files[function.FileID] = file
+			if path := cg.GetFilePath(ctx, function.FileID); path != "" {
+				language := ns.detectLanguage(path, repo)
+				if _, ok := ns.registry.GetTokenizer(language); ok {
+					file.path, file.language = path, language
+					file.lines = ns.readLines(repo, path)
+				}
+			}
+		}
+		if file.path == "" {
+			continue
+		}
+		filePath, language, lines := file.path, file.language, file.lines
+
+		start, end := function.Range.Start.Line, min(function.Range.End.Line, len(lines)-1)
+		if start < 0 || start > end {
+			continue
+		}
+
+		source := []byte(strings.Join(lines[start:end+1], "\n") + "\n")
+		tokenCount, entropy, err := cm.scoreSource(ctx, source, language)
+		if err != nil {
+			ns.logger.Warn("Failed to tokenize function",
+				zap.String("function", function.Name),
+				zap.String("file", filePath),
+				zap.Error(err))
+			continue
+		}
+		if tokenCount < cm.n {
+			continue
+		}
+
+		zScore, stats := cm.CalculateLanguageZScore(ctx, entropy, language)
+		if stats.StdDev == 0 {
+			// Without any spread in file entropies there is no baseline to
+			// call a function surprising against
+			ns.logger.Debug("No entropy baseline to score function against",
+				zap.String("function", function.Name),
+				zap.String("language", language),
+				zap.Int("files", stats.Count))
+			continue
+		}
+		if zScore < minZScore {
+			continue
+		}
+		anomalies = append(anomalies, FunctionAnomaly{
+			NodeID:     function.ID,
+			Name:       function.Name,
+			FilePath:   filePath,
+			Language:   language,
+			StartLine:  start + 1,
+			EndLine:    end + 1,
+			TokenCount: tokenCount,
+			Entropy:    entropy,
+			ZScore:     zScore,
+		})
+	}
+
+	sort.SliceStable(anomalies, func(i, j int) bool { return anomalies[i].ZScore > anomalies[j].ZScore })
+	return anomalies, nil
+}
+
+// readLines reads a file's lines, resolving a relative path against the
+// repository. It returns nil when the file cannot be read.
+func (ns *NGramService) readLines(repo *config.Repository, filePath string) []string {
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(repo.Path, filePath)
+	}
+	content, err := ns.readFile(filePath)
+	if err != nil {
+		ns.logger.Debug("Function source unavailable", zap.String("path", filePath), zap.Error(err))
+		return nil
+	}
+	return strings.Split(string(content), "\n")
+}
@@ -0,0 +1,135 @@
+package ngram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+func TestFindAnomalousFunctionsFlagsUnusualFunction(t *testing.T) {
+	files := make(map[string]string)
+	for i, name := range []string{"Sum", "Count", "Total", "Size", "Length", "Tally", "Score", "Weight"} {
+		files[fmt.Sprintf("f%d.go", i)] = fmt.Sprintf("package a\n\nfunc %s(xs []int) int {\n\tn := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\tn += xs[i]\n\t}\n\treturn n\n}\n", name)
+	}
+	files["odd.go"] = "package a\n\nfunc Odd(p *uint8, k uint) (r uint8) {\nloop:\n\tselect {\n\tcase <-make(chan struct{}):\n\t\tgoto loop\n\tdefault:\n\t}\n\tdefer func() { recover() }()\n\tr = ^*p &^ uint8(k>>3|k<<5) % 7\n\treturn\n}\n"
+	dir := writeFiles(t, files)
+
+	ctx := context.Background()
+	repo := &config.Repository{Name: "corpus", Path: dir}
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	if err := ns.ProcessRepository(ctx, repo, 3, 0, true, ""); err != nil {
+		t.Fatalf("ProcessRepository: %v", err)
+	}
+
+	// One function per file, spanning from its declaration to the file's end
+	var paths []string
+	for path := range files {
+		paths = append(paths, path)
+	}
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		switch {
+		case strings.Contains(query, "(n:Function)"):
+			var records []map[string]any
+			for i, path := range paths {
+				lines := strings.Count(files[path], "\n")
+				name := strings.TrimPrefix(strings.Split(files[path], "(")[0], "package a\n\nfunc ")
+				records = append(records, map[string]any{"n": map[string]any{
+					"id": int64(100 + i), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(i + 1),
+					"name": name, "range": fmt.Sprintf("(2,0)-(%d,1)", lines-1),
+				}})
+			}
+			return records, nil
+		case strings.Contains(query, "(n:FileScope)"):
+			fileID := params["id"].(int64)
+			return []map[string]any{{"n": map[string]any{
+				"id": fileID, "nodeType": int64(ast.NodeTypeFileScope), "fileId": fileID,
+				"md_path": paths[fileID-1], "md_repo": "corpus",
+			}}}, nil
+		}
+		return nil, nil
+	}
+	cg := codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, zap.NewNop())
+
+	anomalies, err := ns.FindAnomalousFunctions(ctx, cg, repo, 1.0)
+	if err != nil {
+		t.Fatalf("FindAnomalousFunctions: %v", err)
+	}
+	if len(anomalies) != 1 {
+		t.Fatalf("flagged %d functions (%+v), want only Odd", len(anomalies), anomalies)
+	}
+	odd := anomalies[0]
+	if odd.Name != "Odd" || odd.FilePath != "odd.go" || odd.StartLine != 3 || odd.EndLine != 13 {
+		t.Errorf("anomaly = %+v, want Odd in odd.go at lines 3-13", odd)
+	}
+	if odd.ZScore < 1.0 || odd.Language != "go" {
+		t.Errorf("anomaly z-score %.3f in %q, want at least 1.0 in go", odd.ZScore, odd.Language)
+	}
+
+	// Without a threshold every function is scored, most surprising first
+	all, err := ns.FindAnomalousFunctions(ctx, cg, repo, -100)
+	if err != nil {
+		t.Fatalf("FindAnomalousFunctions: %v", err)
+	}
+	if len(all) != len(files) || all[0].Name != "Odd" {
+		t.Fatalf("scored %d functions starting with %+v, want %d starting with Odd", len(all), all[0], len(files))
+	}
+	for i := 1; i < len(all); i++ {
+		if all[i].ZScore > all[i-1].ZScore {
+			t.Errorf("function %d z-score %.3f above previous %.3f", i, all[i].ZScore, all[i-1].ZScore)
+		}
+	}
+}
+
+func TestFindAnomalousFunctionsNeedsEntropyBaseline(t *testing.T) {
+	// A single file gives no spread of file entropies to compare against
+	source := "package a\n\nfunc Sum(xs []int) int {\n\tn := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\tn += xs[i]\n\t}\n\treturn n\n}\n"
+	dir := writeFiles(t, map[string]string{"sum.go": source})
+
+	ctx := context.Background()
+	repo := &config.Repository{Name: "single", Path: dir}
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	if err := ns.ProcessRepository(ctx, repo, 3, 0, true, ""); err != nil {
+		t.Fatalf("ProcessRepository: %v", err)
+	}
+
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		switch {
+		case strings.Contains(query, "(n:Function)"):
+			return []map[string]any{{"n": map[string]any{
+				"id": int64(100), "nodeType": int64(ast.NodeTypeFunction), "fileId": int64(1),
+				"name": "Sum", "range": "(2,0)-(8,1)",
+			}}}, nil
+		case strings.Contains(query, "(n:FileScope)"):
+			return []map[string]any{{"n": map[string]any{
+				"id": int64(1), "nodeType": int64(ast.NodeTypeFileScope), "fileId": int64(1),
+				"md_path": "sum.go", "md_repo": "single",
+			}}}, nil
+		}
+		return nil, nil
+	}
+	cg := codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, zap.NewNop())
+
+	anomalies, err := ns.FindAnomalousFunctions(ctx, cg, repo, -100)
+	if err != nil {
+		t.Fatalf("FindAnomalousFunctions: %v", err)
+	}
+	if len(anomalies) != 0 {
+		t.Errorf("flagged %+v without an entropy baseline, want none", anomalies)
+	}
+}
@@ -1,9 +1,12 @@
 package ngram
 
 import (
+	ngrammodel "bot-go/internal/model/ngram"
 	"bot-go/internal/service/tokenizer"
+	"bytes"
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -17,19 +20,40 @@ type FileModel struct {
 	TokenCount   int
 	LastModified time.Time
 	Model        *NGramModelTrie // Always trie-based with bloom filter
-	Entropy      float64         // Cached entropy value
+	Entropy      float64         // Cached cross-entropy of the file's tokens under the global model
+	Weight       float64         // Multiplier applied to the counts the file adds to the global model
+	TokenIDs     []uint32        // Normalized tokens added to the global model, as IDs in the corpus's token table
 }
 
+// weightCountScale is the count scale of global models, which keeps file
+// weights to two decimals (see NGramModelTrie.SetCountScale)
+const weightCountScale = 100
+
+// FileWeightFunc returns the weight of a file's contribution to the global
+// model, e.g. higher for recently changed or core files. See AddWeightedFile
+// for how weights are applied.
+type FileWeightFunc func(filePath string) float64
+
 // CorpusManager manages both file-level and global n-gram models
 // Always uses Trie+Bloom for optimal memory efficiency
 type CorpusManager struct {
 	globalModel *NGramModelTrie       // Global model (trie + bloom filter)
 	fileModels  map[string]*FileModel // file path -> file model
+	smallFiles  map[string]*FileModel // files below minTokens, kept out of the global model and stats
 	tokenizer   *tokenizer.TokenizerRegistry
 	n           int // N-gram size
+	minTokens   int // Files with fewer tokens don't contribute to the corpus (0 = no minimum)
 	smoother    Smoother
+	checkpoint  bool           // Loaded from a checkpoint of a build that did not finish
+	weightFunc  FileWeightFunc // Optional; nil weighs every file 1.0
 	logger      *zap.Logger
 	mu          sync.RWMutex // Protects fileModels map
+
+	// Token table of the files' TokenIDs, kept so a file's contribution can
+	// be taken out of the global model when it changes or is removed
+	tokenIDs   map[string]uint32
+	tokenTable []string
+	tokenMu    sync.RWMutex
 }
 
 // NewCorpusManager creates a new corpus manager with Trie+Bloom (recommended)
@@ -47,10 +71,13 @@ func NewCorpusManager(n int, smoother Smoother, tokenizerRegistry *tokenizer.Tok
 	// Always use Trie+Bloom for optimal memory efficiency
 	// Estimate: ~100K n-grams per 10K LOC, 1% false positive rate
 	globalModel := NewNGramModelTrieWithBloom(n, smoother, true, 100000, 0.01)
+	globalModel.SetCountScale(weightCountScale)
 
 	return &CorpusManager{
 		globalModel: globalModel,
 		fileModels:  make(map[string]*FileModel),
+		smallFiles:  make(map[string]*FileModel),
+		tokenIDs:    make(map[string]uint32),
 		tokenizer:   tokenizerRegistry,
 		n:           n,
 		smoother:    smoother,
@@ -68,45 +95,82 @@ func NewCorpusManagerWithTrieAndBloom(n int, smoother Smoother, tokenizerRegistr
 	return NewCorpusManager(n, smoother, tokenizerRegistry, logger)
 }
 
-// Deprecated: Use NewCorpusManager instead (always uses Trie+Bloom now)
-func NewCorpusManagerWithOptions(n int, smoother Smoother, tokenizerRegistry *tokenizer.TokenizerRegistry, useTrie bool, useBloom bool, logger *zap.Logger) *CorpusManager {
-	logger.Warn("NewCorpusManagerWithOptions is deprecated, always uses Trie+Bloom now")
-	return NewCorpusManager(n, smoother, tokenizerRegistry, logger)
+// NewCorpusManagerWithOptions creates a corpus manager that keeps files with fewer
+// than minTokens tokens out of the global model and statistics; their entropy is
+// too noisy to be meaningful. Such files are still recorded and their entropy can
+// be queried. useTrie and useBloom are ignored (always uses Trie+Bloom now).
+func NewCorpusManagerWithOptions(n int, smoother Smoother, tokenizerRegistry *tokenizer.TokenizerRegistry, useTrie bool, useBloom bool, minTokens int, logger *zap.Logger) *CorpusManager {
+	cm := NewCorpusManager(n, smoother, tokenizerRegistry, logger)
+	if minTokens > 0 {
+		cm.minTokens = minTokens
+	}
+	return cm
 }
 
-// AddFile adds a file to the corpus, updating both file-level and global models
-func (cm *CorpusManager) AddFile(ctx context.Context, filePath string, source []byte, language string) error {
-	// Get the appropriate tokenizer
-	tok, ok := cm.tokenizer.GetTokenizer(language)
-	if !ok {
-		return fmt.Errorf("no tokenizer found for language: %s", language)
+// SetFileWeightFunc sets the function weighing each file added by AddFile or
+// UpdateFile; nil weighs every file 1.0. Files already in the corpus keep the
+// weight they were added with.
+func (cm *CorpusManager) SetFileWeightFunc(weightFunc FileWeightFunc) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	cm.weightFunc = weightFunc
+}
+
+// SetMaxVocabulary caps the number of distinct tokens in the global model;
+// tokens first seen after the cap is reached are modeled as OOVToken. 0
+// removes the cap. File models are not capped.
+func (cm *CorpusManager) SetMaxVocabulary(maxVocab int) {
+	cm.globalModel.SetMaxVocabulary(maxVocab)
+}
+
+// fileWeight returns the weight of a file from the weighting function
+func (cm *CorpusManager) fileWeight(filePath string) float64 {
+	cm.mu.RLock()
+	weightFunc := cm.weightFunc
+	cm.mu.RUnlock()
+
+	if weightFunc == nil {
+		return 1.0
 	}
+	return weightFunc(filePath)
+}
 
-	// Tokenize the source
-	tokenSeq, err := tok.Tokenize(ctx, source)
-	if err != nil {
-		return fmt.Errorf("tokenization failed: %w", err)
+// AddFile adds a file to the corpus, updating both file-level and global models.
+// The file is weighed by the corpus's weighting function.
+func (cm *CorpusManager) AddFile(ctx context.Context, filePath string, source []byte, language string) error {
+	return cm.AddWeightedFile(ctx, filePath, source, language, cm.fileWeight(filePath))
+}
+
+// AddWeightedFile adds a file like AddFile, multiplying the counts it adds to
+// the global model by weight, so a weight of 0.5 counts each of its n-grams
+// as half an occurrence. A weight of 0 keeps the file out of the global model.
+// The file's own model is unweighted.
+func (cm *CorpusManager) AddWeightedFile(ctx context.Context, filePath string, source []byte, language string, weight float64) error {
+	// Check if file already exists and update
+	cm.mu.RLock()
+	_, exists := cm.fileModels[filePath]
+	cm.mu.RUnlock()
+	if exists {
+		return cm.UpdateWeightedFile(ctx, filePath, source, language, weight)
 	}
 
-	// Normalize tokens
-	normalizedTokens := make([]string, 0, len(tokenSeq))
-	for _, token := range tokenSeq {
-		normalized := tok.Normalize(token)
-		normalizedTokens = append(normalizedTokens, normalized)
+	normalizedTokens, err := cm.normalizedTokens(ctx, source, language)
+	if err != nil {
+		return err
 	}
 
-	// Check if file already exists and update
-	cm.mu.Lock()
-	if _, exists := cm.fileModels[filePath]; exists {
-		cm.mu.Unlock()
-		return cm.UpdateFile(ctx, filePath, source, language)
+	if cm.isSmallFile(normalizedTokens) {
+		cm.recordSmallFile(filePath, language, normalizedTokens)
+		return nil
 	}
-	cm.mu.Unlock()
 
 	// Create new file model (always Trie+Bloom)
 	fileModel := NewNGramModelTrieWithBloom(cm.n, cm.smoother, true, 10000, 0.01)
 	fileModel.Add(normalizedTokens)
-	entropy := fileModel.CrossEntropy(normalizedTokens)
+
+	// Update global model
+	cm.globalModel.AddWeighted(normalizedTokens, weight)
+	entropy := cm.globalModel.CrossEntropy(normalizedTokens)
 
 	fm := &FileModel{
 		FilePath:     filePath,
@@ -115,14 +179,14 @@ func (cm *CorpusManager) AddFile(ctx context.Context, filePath string, source []
 		LastModified: time.Now(),
 		Model:        fileModel,
 		Entropy:      entropy,
+		Weight:       weight,
+		TokenIDs:     cm.internTokens(normalizedTokens),
 	}
 
-	// Update global model
-	cm.globalModel.Add(normalizedTokens)
-
 	// Store file model
 	cm.mu.Lock()
 	cm.fileModels[filePath] = fm
+	delete(cm.smallFiles, filePath)
 	cm.mu.Unlock()
 
 	cm.logger.Debug("Added file to corpus",
@@ -130,44 +194,56 @@ func (cm *CorpusManager) AddFile(ctx context.Context, filePath string, source []
 		zap.String("language", language),
 		zap.Int("tokens", len(normalizedTokens)),
 		zap.Float64("entropy", entropy),
+		zap.Float64("weight", weight),
 	)
 
 	return nil
 }
 
-// UpdateFile updates an existing file in the corpus
+// UpdateFile updates an existing file in the corpus, weighing it by the
+// corpus's weighting function
 func (cm *CorpusManager) UpdateFile(ctx context.Context, filePath string, source []byte, language string) error {
+	return cm.UpdateWeightedFile(ctx, filePath, source, language, cm.fileWeight(filePath))
+}
+
+// UpdateWeightedFile updates an existing file like UpdateFile, multiplying the
+// counts it adds to the global model by weight
+func (cm *CorpusManager) UpdateWeightedFile(ctx context.Context, filePath string, source []byte, language string, weight float64) error {
 	cm.mu.RLock()
 	existingModel, exists := cm.fileModels[filePath]
 	cm.mu.RUnlock()
 
 	if !exists {
-		return cm.AddFile(ctx, filePath, source, language)
+		return cm.AddWeightedFile(ctx, filePath, source, language, weight)
 	}
 
-	// Get the appropriate tokenizer
-	tok, ok := cm.tokenizer.GetTokenizer(language)
-	if !ok {
-		return fmt.Errorf("no tokenizer found for language: %s", language)
+	normalizedTokens, err := cm.normalizedTokens(ctx, source, language)
+	if err != nil {
+		return err
 	}
 
-	// Tokenize the new source
-	tokenSeq, err := tok.Tokenize(ctx, source)
-	if err != nil {
-		return fmt.Errorf("tokenization failed: %w", err)
+	if cm.isSmallFile(normalizedTokens) {
+		if err := cm.removeFromGlobal(existingModel); err != nil {
+			return err
+		}
+		cm.mu.Lock()
+		delete(cm.fileModels, filePath)
+		cm.mu.Unlock()
+		cm.recordSmallFile(filePath, language, normalizedTokens)
+		return nil
 	}
 
-	// Normalize tokens
-	normalizedTokens := make([]string, 0, len(tokenSeq))
-	for _, token := range tokenSeq {
-		normalized := tok.Normalize(token)
-		normalizedTokens = append(normalizedTokens, normalized)
+	// Replace the file's previous contribution to the global model, so
+	// repeated updates do not inflate its counts
+	if err := cm.removeFromGlobal(existingModel); err != nil {
+		return err
 	}
 
 	// Create new file model (always Trie+Bloom)
 	newFileModel := NewNGramModelTrieWithBloom(cm.n, cm.smoother, true, 10000, 0.01)
 	newFileModel.Add(normalizedTokens)
-	entropy := newFileModel.CrossEntropy(normalizedTokens)
+	cm.globalModel.AddWeighted(normalizedTokens, weight)
+	entropy := cm.globalModel.CrossEntropy(normalizedTokens)
 
 	fm := &FileModel{
 		FilePath:     filePath,
@@ -176,11 +252,10 @@ func (cm *CorpusManager) UpdateFile(ctx context.Context, filePath string, source
 		LastModified: time.Now(),
 		Model:        newFileModel,
 		Entropy:      entropy,
+		Weight:       weight,
+		TokenIDs:     cm.internTokens(normalizedTokens),
 	}
 
-	// Update global model
-	cm.globalModel.Add(normalizedTokens)
-
 	// Update file model
 	cm.mu.Lock()
 	cm.fileModels[filePath] = fm
@@ -193,11 +268,81 @@ func (cm *CorpusManager) UpdateFile(ctx context.Context, filePath string, source
 		zap.Int("new_tokens", len(normalizedTokens)),
 		zap.Float64("old_entropy", existingModel.Entropy),
 		zap.Float64("new_entropy", entropy),
+		zap.Float64("weight", weight),
 	)
 
 	return nil
 }
 
+// removeFromGlobal takes a file's contribution out of the global model. It
+// fails, leaving the counts in place, when the file's tokens are not known.
+func (cm *CorpusManager) removeFromGlobal(fm *FileModel) error {
+	if len(fm.TokenIDs) != fm.TokenCount {
+		return fmt.Errorf("tokens of %s are not recorded, rebuild the model to update it", fm.FilePath)
+	}
+	cm.globalModel.RemoveWeighted(cm.fileTokens(fm), fm.Weight)
+	return nil
+}
+
+// internTokens returns the IDs of tokens in the corpus's token table, adding
+// the tokens not seen before
+func (cm *CorpusManager) internTokens(tokens []string) []uint32 {
+	cm.tokenMu.Lock()
+	defer cm.tokenMu.Unlock()
+
+	ids := make([]uint32, len(tokens))
+	for i, token := range tokens {
+		id, ok := cm.tokenIDs[token]
+		if !ok {
+			id = uint32(len(cm.tokenTable))
+			cm.tokenIDs[token] = id
+			cm.tokenTable = append(cm.tokenTable, token)
+		}
+		ids[i] = id
+	}
+	return ids
+}
+
+// fileTokens returns the normalized tokens a file added to the global model
+func (cm *CorpusManager) fileTokens(fm *FileModel) []string {
+	cm.tokenMu.RLock()
+	defer cm.tokenMu.RUnlock()
+
+	tokens := make([]string, len(fm.TokenIDs))
+	for i, id := range fm.TokenIDs {
+		tokens[i] = cm.tokenTable[id]
+	}
+	return tokens
+}
+
+// isSmallFile reports whether a token stream is too short to contribute to the corpus
+func (cm *CorpusManager) isSmallFile(tokens []string) bool {
+	return cm.minTokens > 0 && len(tokens) < cm.minTokens
+}
+
+// recordSmallFile records a file below minTokens without adding it to the
+// global model; its entropy is computed against its own tokens only
+func (cm *CorpusManager) recordSmallFile(filePath, language string, tokens []string) {
+	fileModel := NewNGramModelTrie(cm.n, cm.smoother)
+	fileModel.Add(tokens)
+
+	cm.mu.Lock()
+	cm.smallFiles[filePath] = &FileModel{
+		FilePath:     filePath,
+		Language:     language,
+		TokenCount:   len(tokens),
+		LastModified: time.Now(),
+		Entropy:      fileModel.CrossEntropy(tokens),
+	}
+	cm.mu.Unlock()
+
+	cm.logger.Debug("Recorded file below minimum token count",
+		zap.String("path", filePath),
+		zap.Int("tokens", len(tokens)),
+		zap.Int("min_tokens", cm.minTokens),
+	)
+}
+
 // RemoveFile removes a file from the corpus
 func (cm *CorpusManager) RemoveFile(ctx context.Context, filePath string) error {
 	cm.mu.Lock()
@@ -205,29 +350,84 @@ func (cm *CorpusManager) RemoveFile(ctx context.Context, filePath string) error
 
 	fileModel, exists := cm.fileModels[filePath]
 	if !exists {
+		if _, small := cm.smallFiles[filePath]; small {
+			delete(cm.smallFiles, filePath)
+			return nil
+		}
 		return fmt.Errorf("file not found in corpus: %s", filePath)
 	}
 
-	// Note: Removing from global model is complex without tracking
-	// In a production system, we'd need better bookkeeping
+	if err := cm.removeFromGlobal(fileModel); err != nil {
+		return err
+	}
 	delete(cm.fileModels, filePath)
 
 	cm.logger.Debug("Removed file from corpus",
 		zap.String("path", filePath),
 	)
 
-	// Suppress unused variable warning
-	_ = fileModel
-
 	return nil
 }
 
+// HasFile reports whether a file is in the corpus, including files below the
+// minimum token count
+func (cm *CorpusManager) HasFile(filePath string) bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	_, exists := cm.fileModels[filePath]
+	if !exists {
+		_, exists = cm.smallFiles[filePath]
+	}
+	return exists
+}
+
+// IsCheckpoint reports whether the corpus was loaded from a checkpoint of a
+// build that did not finish
+func (cm *CorpusManager) IsCheckpoint() bool {
+	return cm.checkpoint
+}
+
+// builtWith reports whether the corpus was built for n-grams of order n,
+// keeping files of fewer than minTokens tokens out
+func (cm *CorpusManager) builtWith(n, minTokens int) bool {
+	return cm.n == n && cm.minTokens == max(minTokens, 0)
+}
+
+// setModTime records the on-disk modification time of a file in the corpus
+func (cm *CorpusManager) setModTime(filePath string, modTime time.Time) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if fm, exists := cm.fileModels[filePath]; exists {
+		fm.LastModified = modTime
+	} else if fm, exists := cm.smallFiles[filePath]; exists {
+		fm.LastModified = modTime
+	}
+}
+
+// hasUnchangedFile reports whether a file is in the corpus with the given
+// on-disk modification time
+func (cm *CorpusManager) hasUnchangedFile(filePath string, modTime time.Time) bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	fm, exists := cm.fileModels[filePath]
+	if !exists {
+		fm, exists = cm.smallFiles[filePath]
+	}
+	return exists && fm.LastModified.Equal(modTime)
+}
+
 // GetFileEntropy returns the entropy for a specific file
 func (cm *CorpusManager) GetFileEntropy(ctx context.Context, filePath string) (float64, error) {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
 	fileModel, exists := cm.fileModels[filePath]
+	if !exists {
+		fileModel, exists = cm.smallFiles[filePath]
+	}
 	if !exists {
 		return 0, fmt.Errorf("file not found in corpus: %s", filePath)
 	}
@@ -289,11 +489,13 @@ func (cm *CorpusManager) GetStats(ctx context.Context) CorpusStats {
 	languageCounts := make(map[string]int)
 	totalTokens := 0
 	entropies := make([]float64, 0, len(cm.fileModels))
+	languageEntropies := make(map[string][]float64)
 
 	for _, fm := range cm.fileModels {
 		languageCounts[fm.Language]++
 		totalTokens += fm.TokenCount
 		entropies = append(entropies, fm.Entropy)
+		languageEntropies[fm.Language] = append(languageEntropies[fm.Language], fm.Entropy)
 	}
 
 	// Get global model stats (always Trie+Bloom)
@@ -301,16 +503,22 @@ func (cm *CorpusManager) GetStats(ctx context.Context) CorpusStats {
 
 	// Calculate entropy statistics
 	entropyStats := calculateEntropyStatistics(entropies)
+	languageEntropy := make(map[string]EntropyStats, len(languageEntropies))
+	for language, values := range languageEntropies {
+		languageEntropy[language] = calculateEntropyStatistics(values)
+	}
 
 	return CorpusStats{
-		TotalFiles:     len(cm.fileModels),
-		TotalTokens:    totalTokens,
-		LanguageCounts: languageCounts,
-		GlobalModel:    globalModelStats,
-		AverageEntropy: entropyStats.Mean,
-		EntropyStdDev:  entropyStats.StdDev,
-		EntropyMin:     entropyStats.Min,
-		EntropyMax:     entropyStats.Max,
+		TotalFiles:      len(cm.fileModels),
+		SmallFiles:      len(cm.smallFiles),
+		TotalTokens:     totalTokens,
+		LanguageCounts:  languageCounts,
+		GlobalModel:     globalModelStats,
+		AverageEntropy:  entropyStats.Mean,
+		EntropyStdDev:   entropyStats.StdDev,
+		EntropyMin:      entropyStats.Min,
+		EntropyMax:      entropyStats.Max,
+		LanguageEntropy: languageEntropy,
 	}
 }
 
@@ -325,6 +533,95 @@ func (cm *CorpusManager) PruneGlobalModel(minCount int64) (int64, int64) {
 	return cm.globalModel.Prune(minCount)
 }
 
+// RecomputeEntropies re-derives every file's cached entropy against the current
+// global model, e.g. after PruneGlobalModel has dropped low-frequency n-grams
+// or after later files were added. Files are scored from their recorded
+// tokens, so nothing is re-read from disk.
+func (cm *CorpusManager) RecomputeEntropies(ctx context.Context) (CorpusStats, error) {
+	cm.mu.RLock()
+	files := make([]*FileModel, 0, len(cm.fileModels))
+	for _, fm := range cm.fileModels {
+		files = append(files, fm)
+	}
+	cm.mu.RUnlock()
+
+	entropies := make(map[*FileModel]float64, len(files))
+	for _, fm := range files {
+		if err := ctx.Err(); err != nil {
+			return CorpusStats{}, err
+		}
+		if len(fm.TokenIDs) != fm.TokenCount {
+			return CorpusStats{}, fmt.Errorf("tokens of %s are not recorded, rebuild the model to recompute its entropies", fm.FilePath)
+		}
+		entropies[fm] = cm.globalModel.CrossEntropy(cm.fileTokens(fm))
+	}
+
+	// Swap in updated copies so readers holding the old FileModel are unaffected;
+	// files replaced or removed while recomputing are left as they are
+	cm.mu.Lock()
+	for path, fm := range cm.fileModels {
+		entropy, ok := entropies[fm]
+		if !ok {
+			continue
+		}
+		updated := *fm
+		updated.Entropy = entropy
+		cm.fileModels[path] = &updated
+	}
+	cm.mu.Unlock()
+
+	cm.logger.Debug("Recomputed file entropies against global model",
+		zap.Int("files", len(entropies)),
+	)
+
+	return cm.GetStats(ctx), nil
+}
+
+// normalizedTokens returns the normalized tokens of source. Adding a file
+// needs the whole sequence: it is recorded with the file and scored under the
+// global model after the models are updated.
+func (cm *CorpusManager) normalizedTokens(ctx context.Context, source []byte, language string) ([]string, error) {
+	var normalized []string
+	err := streamNormalizedTokens(ctx, cm.tokenizer, source, language, func(token string) {
+		normalized = append(normalized, token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// scoreSource returns the number of normalized tokens in source and their
+// cross-entropy under the global model, scoring tokens as they are produced
+// instead of collecting them
+func (cm *CorpusManager) scoreSource(ctx context.Context, source []byte, language string) (int, float64, error) {
+	scorer := cm.GetGlobalModel().newEntropyScorer()
+	if err := streamNormalizedTokens(ctx, cm.tokenizer, source, language, scorer.score); err != nil {
+		return 0, 0, err
+	}
+	return scorer.tokens, scorer.crossEntropy(), nil
+}
+
+// streamNormalizedTokens tokenizes source with the language's tokenizer and
+// passes each token to emit in its normalized form as soon as it is produced,
+// so neither the raw nor the normalized token sequence is built unless emit
+// keeps it
+func streamNormalizedTokens(ctx context.Context, registry *tokenizer.TokenizerRegistry, source []byte, language string, emit func(string)) error {
+	tok, ok := registry.GetTokenizer(language)
+	if !ok {
+		return fmt.Errorf("no tokenizer found for language: %s", language)
+	}
+
+	err := tok.TokenizeStream(ctx, bytes.NewReader(source), func(token ngrammodel.Token) error {
+		emit(tok.Normalize(token))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("tokenization failed: %w", err)
+	}
+	return nil
+}
+
 // ListFiles returns a list of all files in the corpus
 func (cm *CorpusManager) ListFiles(ctx context.Context) []string {
 	cm.mu.RLock()
@@ -338,9 +635,45 @@ func (cm *CorpusManager) ListFiles(ctx context.Context) []string {
 	return files
 }
 
+// TopEntropyFiles returns up to n files with the highest cached entropy, most
+// surprising first. Ties are broken by path so the ranking is stable.
+func (cm *CorpusManager) TopEntropyFiles(ctx context.Context, n int) []FileEntropy {
+	cm.mu.RLock()
+	files := make([]FileEntropy, 0, len(cm.fileModels))
+	for _, fm := range cm.fileModels {
+		files = append(files, FileEntropy{
+			FilePath:   fm.FilePath,
+			Entropy:    fm.Entropy,
+			TokenCount: fm.TokenCount,
+		})
+	}
+	cm.mu.RUnlock()
+
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Entropy != files[j].Entropy {
+			return files[i].Entropy > files[j].Entropy
+		}
+		return files[i].FilePath < files[j].FilePath
+	})
+
+	if n >= 0 && n < len(files) {
+		files = files[:n]
+	}
+	return files
+}
+
+// FileEntropy is a file's cached entropy; TokenCount helps recognize small,
+// noisy files among the most surprising ones
+type FileEntropy struct {
+	FilePath   string  `json:"file_path"`
+	Entropy    float64 `json:"entropy"`
+	TokenCount int     `json:"token_count"`
+}
+
 // CorpusStats contains statistics about the entire corpus
 type CorpusStats struct {
 	TotalFiles     int            `json:"total_files"`
+	SmallFiles     int            `json:"small_files"` // Files below the minimum token count, excluded from all other stats
 	TotalTokens    int            `json:"total_tokens"`
 	LanguageCounts map[string]int `json:"language_counts"`
 	GlobalModel    ModelStats     `json:"global_model"`
@@ -348,6 +681,10 @@ type CorpusStats struct {
 	EntropyStdDev  float64        `json:"entropy_std_dev"` // Standard deviation of file entropies
 	EntropyMin     float64        `json:"entropy_min"`     // Minimum file entropy
 	EntropyMax     float64        `json:"entropy_max"`     // Maximum file entropy
+
+	// Entropy statistics per language; mixed-language corpora have one
+	// distribution per language rather than a single one
+	LanguageEntropy map[string]EntropyStats `json:"language_entropy"`
 }
 
 // EntropyStats contains detailed entropy statistics for z-score calculation
@@ -372,12 +709,41 @@ func (cm *CorpusManager) GetEntropyStats(ctx context.Context) EntropyStats {
 	return calculateEntropyStatistics(entropies)
 }
 
+// GetEntropyStatsByLanguage returns entropy statistics over the files of one
+// language; Count is 0 if the corpus has none
+func (cm *CorpusManager) GetEntropyStatsByLanguage(ctx context.Context, language string) EntropyStats {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	var entropies []float64
+	for _, fm := range cm.fileModels {
+		if fm.Language == language {
+			entropies = append(entropies, fm.Entropy)
+		}
+	}
+
+	return calculateEntropyStatistics(entropies)
+}
+
 // CalculateZScore calculates the z-score for a given entropy value
 // Z-score = (entropy - mean) / stddev
 // Higher z-score indicates more unusual/buggy code
 func (cm *CorpusManager) CalculateZScore(ctx context.Context, entropy float64) float64 {
-	stats := cm.GetEntropyStats(ctx)
+	return zScore(entropy, cm.GetEntropyStats(ctx))
+}
+
+// CalculateLanguageZScore calculates the z-score of an entropy value against
+// the files of the given language, falling back to the whole corpus when it
+// has none. It returns the statistics the score was computed from.
+func (cm *CorpusManager) CalculateLanguageZScore(ctx context.Context, entropy float64, language string) (float64, EntropyStats) {
+	stats := cm.GetEntropyStatsByLanguage(ctx, language)
+	if stats.Count == 0 {
+		stats = cm.GetEntropyStats(ctx)
+	}
+	return zScore(entropy, stats), stats
+}
 
+func zScore(entropy float64, stats EntropyStats) float64 {
 	if stats.StdDev == 0 {
 		return 0 // Avoid division by zero
 	}
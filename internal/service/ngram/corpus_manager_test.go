@@ -0,0 +1,287 @@
+package ngram
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot-go/internal/service/tokenizer"
+
+	"go.uber.org/zap"
+)
+
+func TestCorpusManagerMinTokensExcludesSmallFiles(t *testing.T) {
+	goTokenizer, err := tokenizer.NewGoTokenizer()
+	if err != nil {
+		t.Fatalf("NewGoTokenizer: %v", err)
+	}
+	registry := tokenizer.NewTokenizerRegistry()
+	registry.Register("go", goTokenizer, []string{".go"})
+
+	ctx := context.Background()
+	cm := NewCorpusManagerWithOptions(3, nil, registry, true, true, 10, zap.NewNop())
+
+	tiny := []byte("package a")
+	normal := []byte("package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n")
+	if err := cm.AddFile(ctx, "tiny.go", tiny, "go"); err != nil {
+		t.Fatalf("AddFile(tiny.go): %v", err)
+	}
+	if err := cm.AddFile(ctx, "sum.go", normal, "go"); err != nil {
+		t.Fatalf("AddFile(sum.go): %v", err)
+	}
+
+	stats := cm.GetStats(ctx)
+	sum, err := cm.GetFileModel(ctx, "sum.go")
+	if err != nil {
+		t.Fatalf("GetFileModel(sum.go): %v", err)
+	}
+	if stats.TotalFiles != 1 || stats.SmallFiles != 1 {
+		t.Errorf("total files = %d, small files = %d, want 1 and 1", stats.TotalFiles, stats.SmallFiles)
+	}
+	if stats.TotalTokens != sum.TokenCount {
+		t.Errorf("total tokens = %d, want only sum.go's %d", stats.TotalTokens, sum.TokenCount)
+	}
+	if stats.AverageEntropy != sum.Entropy || stats.EntropyMin != sum.Entropy || stats.EntropyMax != sum.Entropy {
+		t.Errorf("entropy stats %+v include the small file, want all equal to %f", stats, sum.Entropy)
+	}
+	if files := cm.ListFiles(ctx); len(files) != 1 || files[0] != "sum.go" {
+		t.Errorf("ListFiles() = %v, want [sum.go]", files)
+	}
+	if int(stats.GlobalModel.TotalTokens) != sum.TokenCount {
+		t.Errorf("global model saw %d tokens, want %d", stats.GlobalModel.TotalTokens, sum.TokenCount)
+	}
+
+	// The small file is still recorded
+	if _, err := cm.GetFileEntropy(ctx, "tiny.go"); err != nil {
+		t.Errorf("GetFileEntropy(tiny.go): %v", err)
+	}
+
+	// Growing past the threshold moves it into the corpus
+	if err := cm.UpdateFile(ctx, "tiny.go", normal, "go"); err != nil {
+		t.Fatalf("UpdateFile(tiny.go): %v", err)
+	}
+	if stats := cm.GetStats(ctx); stats.TotalFiles != 2 || stats.SmallFiles != 0 {
+		t.Errorf("after growth total files = %d, small files = %d, want 2 and 0", stats.TotalFiles, stats.SmallFiles)
+	}
+}
+
+func TestCorpusManagerWeightedFileMultipliesCounts(t *testing.T) {
+	goTokenizer, err := tokenizer.NewGoTokenizer()
+	if err != nil {
+		t.Fatalf("NewGoTokenizer: %v", err)
+	}
+	registry := tokenizer.NewTokenizerRegistry()
+	registry.Register("go", goTokenizer, []string{".go"})
+
+	ctx := context.Background()
+	source := []byte("package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n")
+
+	build := func(weight float64) *CorpusManager {
+		cm := NewCorpusManager(3, nil, registry, zap.NewNop())
+		cm.SetFileWeightFunc(func(filePath string) float64 { return weight })
+		if err := cm.AddFile(ctx, "sum.go", source, "go"); err != nil {
+			t.Fatalf("AddFile(weight %v): %v", weight, err)
+		}
+		return cm
+	}
+	single := build(1)
+	tripled := build(3)
+
+	tokens, err := single.normalizedTokens(ctx, source, "go")
+	if err != nil {
+		t.Fatalf("normalizedTokens: %v", err)
+	}
+	singleStats := single.GetGlobalModel().Stats()
+	tripledStats := tripled.GetGlobalModel().Stats()
+	if tripledStats.TotalTokens != 3*singleStats.TotalTokens {
+		t.Errorf("total tokens = %d, want 3 x %d", tripledStats.TotalTokens, singleStats.TotalTokens)
+	}
+
+	// The global model keeps an n-gram from its second sighting, so compare
+	// unweighted and weighted adds to a model without that filter
+	plain := NewNGramModelTrie(3, nil)
+	plain.Add(tokens)
+	weighted := NewNGramModelTrie(3, nil)
+	weighted.AddWeighted(tokens, 3.0)
+	for _, ng := range plain.extractNGrams(tokens) {
+		if got, want := weighted.ngramTrie.GetCount(ng), 3*plain.ngramTrie.GetCount(ng); got != want {
+			t.Errorf("count of %v = %d, want %d", ng, got, want)
+		}
+	}
+	if got, want := weighted.vocabulary.GetCount(tokens[:1]), 3*plain.vocabulary.GetCount(tokens[:1]); got != want {
+		t.Errorf("count of %q = %d, want %d", tokens[0], got, want)
+	}
+
+	// The file's entropy is measured under the weighted global model
+	tripledFile, _ := tripled.GetFileModel(ctx, "sum.go")
+	if want := tripled.GetGlobalModel().CrossEntropy(tokens); tripledFile.Entropy != want || tripledFile.Weight != 3 {
+		t.Errorf("weighted file = entropy %f, weight %v; want entropy %f, weight 3", tripledFile.Entropy, tripledFile.Weight, want)
+	}
+}
+
+func TestCorpusManagerFractionalWeightDiscountsFile(t *testing.T) {
+	goTokenizer, err := tokenizer.NewGoTokenizer()
+	if err != nil {
+		t.Fatalf("NewGoTokenizer: %v", err)
+	}
+	registry := tokenizer.NewTokenizerRegistry()
+	registry.Register("go", goTokenizer, []string{".go"})
+
+	ctx := context.Background()
+	source := []byte("package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n")
+
+	build := func(weight float64) *CorpusManager {
+		cm := NewCorpusManager(3, nil, registry, zap.NewNop())
+		cm.SetFileWeightFunc(func(filePath string) float64 { return weight })
+		if err := cm.AddFile(ctx, "sum.go", source, "go"); err != nil {
+			t.Fatalf("AddFile(weight %v): %v", weight, err)
+		}
+		return cm
+	}
+	single := build(1).GetGlobalModel()
+	halved := build(0.5).GetGlobalModel()
+	excluded := build(0).GetGlobalModel()
+
+	if halved.totalTokens*2 != single.totalTokens {
+		t.Errorf("weight 0.5 stored %d token counts, want half of %d", halved.totalTokens, single.totalTokens)
+	}
+	if excluded.totalTokens != 0 {
+		t.Errorf("weight 0 stored %d token counts, want none", excluded.totalTokens)
+	}
+
+	// Half an occurrence is smoothed as such, not rounded up to a whole one
+	tokens := []string{"a", "b", "c"}
+	model := NewNGramModelTrie(2, nil)
+	model.SetCountScale(weightCountScale)
+	model.AddWeighted(tokens, 0.5)
+	if got := model.ngramTrie.GetCount([]string{"a", "b"}); got != weightCountScale/2 {
+		t.Errorf("stored count of a b = %d, want %d", got, weightCountScale/2)
+	}
+	want := NewAddKSmoother(1.0).Smooth(0.5, 0.5, 1, 1.0/3, 3)
+	if got := model.Probability("b", []string{"a"}); math.Abs(got-want) > 1e-9 {
+		t.Errorf("P(b|a) = %f, want %f", got, want)
+	}
+	model.RemoveWeighted(tokens, 0.5)
+	if got := model.ngramTrie.GetCount([]string{"a", "b"}); got != 0 {
+		t.Errorf("count of a b after removal = %d, want 0", got)
+	}
+}
+
+func TestCorpusManagerRecomputeEntropiesFromRecordedTokens(t *testing.T) {
+	goTokenizer, err := tokenizer.NewGoTokenizer()
+	if err != nil {
+		t.Fatalf("NewGoTokenizer: %v", err)
+	}
+	registry := tokenizer.NewTokenizerRegistry()
+	registry.Register("go", goTokenizer, []string{".go"})
+
+	// The paths do not exist, so the files can only be scored from their tokens
+	ctx := context.Background()
+	sources := map[string][]byte{
+		"missing/sum.go": []byte("package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n"),
+		"missing/max.go": []byte("package a\n\nfunc Max(a, b int) int {\n\tif a > b {\n\t\treturn a\n\t}\n\treturn b\n}\n"),
+	}
+	cm := NewCorpusManager(3, nil, registry, zap.NewNop())
+	for _, path := range []string{"missing/sum.go", "missing/max.go"} {
+		if err := cm.AddFile(ctx, path, sources[path], "go"); err != nil {
+			t.Fatalf("AddFile(%s): %v", path, err)
+		}
+	}
+	cm.PruneGlobalModel(2)
+
+	stats, err := cm.RecomputeEntropies(ctx)
+	if err != nil {
+		t.Fatalf("RecomputeEntropies: %v", err)
+	}
+	sum := 0.0
+	for path, source := range sources {
+		tokens, err := cm.normalizedTokens(ctx, source, "go")
+		if err != nil {
+			t.Fatalf("normalizedTokens(%s): %v", path, err)
+		}
+		fm, err := cm.GetFileModel(ctx, path)
+		if err != nil {
+			t.Fatalf("GetFileModel(%s): %v", path, err)
+		}
+		if want := cm.GetGlobalModel().CrossEntropy(tokens); fm.Entropy != want {
+			t.Errorf("%s entropy = %f, want %f under the pruned global model", path, fm.Entropy, want)
+		}
+		sum += fm.Entropy
+	}
+	if mean := sum / float64(len(sources)); math.Abs(stats.AverageEntropy-mean) > 1e-9 {
+		t.Errorf("average entropy = %f, want %f", stats.AverageEntropy, mean)
+	}
+}
+
+// trieCounts returns the non-zero counts of a trie's n-grams by their tokens
+func trieCounts(trie *NGramTrie) map[string]int64 {
+	counts := make(map[string]int64)
+	for _, ng := range trie.GetAllWithPrefix(nil) {
+		counts[strings.Join(ng.Tokens, " ")] = ng.Count
+	}
+	return counts
+}
+
+func TestCorpusManagerUpdateFileReplacesGlobalCounts(t *testing.T) {
+	goTokenizer, err := tokenizer.NewGoTokenizer()
+	if err != nil {
+		t.Fatalf("NewGoTokenizer: %v", err)
+	}
+	registry := tokenizer.NewTokenizerRegistry()
+	registry.Register("go", goTokenizer, []string{".go"})
+
+	ctx := context.Background()
+	other := []byte("package a\n\nfunc Max(a, b int) int {\n\tif a > b {\n\t\treturn a\n\t}\n\treturn b\n}\n")
+	versions := [][]byte{
+		[]byte("package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n"),
+		[]byte("package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\ttotal += xs[i]\n\t}\n\treturn total\n}\n"),
+		[]byte("package a\n\nfunc Sum(xs []int) (total int) {\n\tfor _, x := range xs {\n\t\tif x > 0 {\n\t\t\ttotal += x\n\t\t}\n\t}\n\treturn\n}\n"),
+	}
+
+	updated := NewCorpusManager(3, nil, registry, zap.NewNop())
+	if err := updated.AddFile(ctx, "max.go", other, "go"); err != nil {
+		t.Fatalf("AddFile(max.go): %v", err)
+	}
+	for i, source := range versions {
+		if err := updated.UpdateFile(ctx, "sum.go", source, "go"); err != nil {
+			t.Fatalf("UpdateFile(version %d): %v", i+1, err)
+		}
+	}
+
+	fresh := NewCorpusManager(3, nil, registry, zap.NewNop())
+	if err := fresh.AddFile(ctx, "max.go", other, "go"); err != nil {
+		t.Fatalf("AddFile(max.go): %v", err)
+	}
+	if err := fresh.AddFile(ctx, "sum.go", versions[len(versions)-1], "go"); err != nil {
+		t.Fatalf("AddFile(sum.go): %v", err)
+	}
+
+	got, want := updated.GetGlobalModel(), fresh.GetGlobalModel()
+	if got.Stats().TotalTokens != want.Stats().TotalTokens {
+		t.Errorf("total tokens = %d, want %d", got.Stats().TotalTokens, want.Stats().TotalTokens)
+	}
+	for _, trie := range []struct {
+		name      string
+		got, want *NGramTrie
+	}{
+		{"n-gram", got.ngramTrie, want.ngramTrie},
+		{"context", got.contextTrie, want.contextTrie},
+		{"vocabulary", got.vocabulary, want.vocabulary},
+	} {
+		if gotCounts, wantCounts := trieCounts(trie.got), trieCounts(trie.want); !reflect.DeepEqual(gotCounts, wantCounts) {
+			t.Errorf("%s counts after updates = %v, want %v", trie.name, gotCounts, wantCounts)
+		}
+	}
+
+	// Removing both files empties the global model
+	for _, path := range []string{"sum.go", "max.go"} {
+		if err := updated.RemoveFile(ctx, path); err != nil {
+			t.Fatalf("RemoveFile(%s): %v", path, err)
+		}
+	}
+	if counts := trieCounts(got.ngramTrie); len(counts) != 0 {
+		t.Errorf("n-gram counts after removing every file = %v, want none", counts)
+	}
+}
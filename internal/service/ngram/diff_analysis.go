@@ -0,0 +1,164 @@
+package ngram
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/util"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ErrNotGitRepository is returned when a diff is requested for a repository
+// that is not a git working tree
+var ErrNotGitRepository = errors.New("repository is not a git repository")
+
+// DiffHunkAnalysis scores the lines added by one hunk
+type DiffHunkAnalysis struct {
+	StartLine  int     `json:"start_line"` // First added line in the working tree file
+	LineCount  int     `json:"line_count"`
+	TokenCount int     `json:"token_count"`
+	Entropy    float64 `json:"entropy"`
+	ZScore     float64 `json:"z_score"`
+}
+
+// FileDiffAnalysis scores all lines added to a file. Entropy is the
+// token-weighted mean of the hunk entropies.
+type FileDiffAnalysis struct {
+	FilePath   string             `json:"file_path"` // Relative to the repository path
+	Language   string             `json:"language"`
+	TokenCount int                `json:"token_count"`
+	Entropy    float64            `json:"entropy"`
+	ZScore     float64            `json:"z_score"`
+	Hunks      []DiffHunkAnalysis `json:"hunks"`
+}
+
+// AnalyzeDiff scores the lines added in the repository's working tree compared
+// to its git ref (HEAD by default), staged or not, against the repository's
+// model. Untracked files count
+// as entirely added. Only added lines are tokenized, so each hunk is scored on
+// the new code alone; files without a tokenizer are skipped. Files are ordered
+// by path.
+func (ns *NGramService) AnalyzeDiff(ctx context.Context, repo *config.Repository) ([]FileDiffAnalysis, error) {
+	cm, err := ns.GetCorpusManager(repo.Name, "")
+	if err != nil {
+		return nil, err
+	}
+
+	gitInfo, err := util.GetGitInfo(repo.Path, repo.Ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git state: %w", err)
+	}
+	if !gitInfo.IsGitRepo {
+		return nil, fmt.Errorf("%w: %s", ErrNotGitRepository, repo.Path)
+	}
+
+	repoRoot, err := filepath.Abs(repo.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve repository path: %w", err)
+	}
+	repoRoot, err = filepath.EvalSymlinks(repoRoot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve repository path: %w", err)
+	}
+
+	// Git reports paths under its root, which may be above the repository path
+	changed := make([]string, 0, len(gitInfo.ModifiedFiles)+len(gitInfo.UntrackedFiles))
+	for path := range gitInfo.ModifiedFiles {
+		changed = append(changed, path)
+	}
+	for path := range gitInfo.UntrackedFiles {
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+
+	results := []FileDiffAnalysis{}
+	for _, path := range changed {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		relPath, err := filepath.Rel(repoRoot, path)
+		if err != nil || strings.HasPrefix(relPath, "..") {
+			continue
+		}
+
+		language := ns.detectLanguage(path, repo)
+		if _, ok := ns.registry.GetTokenizer(language); !ok {
+			continue
+		}
+
+		hunks, err := ns.addedHunks(gitInfo, path)
+		if err != nil {
+			ns.logger.Warn("Failed to read added lines",
+				zap.String("path", path),
+				zap.Error(err))
+			continue
+		}
+
+		if file, ok := ns.scoreHunks(ctx, cm, language, hunks); ok {
+			file.FilePath = filepath.ToSlash(relPath)
+			results = append(results, file)
+		}
+	}
+
+	return results, nil
+}
+
+// addedHunks returns the lines added to a changed file; an untracked file is
+// one hunk holding the whole file, and a deleted file has none
+func (ns *NGramService) addedHunks(gitInfo *util.GitInfo, path string) ([]util.DiffHunk, error) {
+	if !gitInfo.UntrackedFiles[path] {
+		return util.GetAddedHunks(gitInfo.GitRootPath, gitInfo.Ref, path)
+	}
+
+	content, err := ns.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	return []util.DiffHunk{{StartLine: 1, AddedLines: lines}}, nil
+}
+
+// scoreHunks tokenizes and scores each hunk on its own, returning false if no
+// hunk has any tokens
+func (ns *NGramService) scoreHunks(ctx context.Context, cm *CorpusManager, language string, hunks []util.DiffHunk) (FileDiffAnalysis, bool) {
+	file := FileDiffAnalysis{Language: language}
+	weightedEntropy := 0.0
+
+	for _, hunk := range hunks {
+		source := []byte(strings.Join(hunk.AddedLines, "\n") + "\n")
+		tokenCount, entropy, err := cm.scoreSource(ctx, source, language)
+		if err != nil {
+			ns.logger.Warn("Failed to tokenize added lines",
+				zap.Int("start_line", hunk.StartLine),
+				zap.Error(err))
+			continue
+		}
+		if tokenCount == 0 {
+			continue
+		}
+
+		zScore, _ := cm.CalculateLanguageZScore(ctx, entropy, language)
+		file.Hunks = append(file.Hunks, DiffHunkAnalysis{
+			StartLine:  hunk.StartLine,
+			LineCount:  len(hunk.AddedLines),
+			TokenCount: tokenCount,
+			Entropy:    entropy,
+			ZScore:     zScore,
+		})
+		file.TokenCount += tokenCount
+		weightedEntropy += entropy * float64(tokenCount)
+	}
+
+	if file.TokenCount == 0 {
+		return file, false
+	}
+	file.Entropy = weightedEntropy / float64(file.TokenCount)
+	file.ZScore, _ = cm.CalculateLanguageZScore(ctx, file.Entropy, language)
+	return file, true
+}
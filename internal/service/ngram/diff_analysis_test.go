@@ -0,0 +1,93 @@
+package ngram
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bot-go/internal/config"
+
+	"go.uber.org/zap"
+)
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+}
+
+func TestAnalyzeDiffScoresOnlyAddedLines(t *testing.T) {
+	calc := "package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\ttotal += xs[i]\n\t}\n\treturn total\n}\n"
+	dir := writeFiles(t, map[string]string{
+		"calc.go":  calc,
+		"count.go": "package a\n\nfunc Count(xs []int) int {\n\tn := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\tn += 1\n\t}\n\treturn n\n}\n",
+	})
+	runGit(t, dir, "init", "-q")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-qm", "initial")
+
+	ctx := context.Background()
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	repo := &config.Repository{Name: "diff", Path: dir}
+	if err := ns.ProcessRepository(ctx, repo, 3, 0, true, ""); err != nil {
+		t.Fatalf("ProcessRepository: %v", err)
+	}
+
+	// Stage a new function after the existing one; the README has no tokenizer
+	added := "\nfunc Max(xs []int) int {\n\tbest := xs[0]\n\tfor _, x := range xs {\n\t\tif x > best {\n\t\t\tbest = x\n\t\t}\n\t}\n\treturn best\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "calc.go"), []byte(calc+added), 0644); err != nil {
+		t.Fatalf("write calc.go: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README"), []byte("notes\n"), 0644); err != nil {
+		t.Fatalf("write README: %v", err)
+	}
+	runGit(t, dir, "add", "calc.go")
+
+	files, err := ns.AnalyzeDiff(ctx, repo)
+	if err != nil {
+		t.Fatalf("AnalyzeDiff: %v", err)
+	}
+	if len(files) != 1 || files[0].FilePath != "calc.go" {
+		t.Fatalf("analyzed files = %+v, want only calc.go", files)
+	}
+
+	file := files[0]
+	if len(file.Hunks) != 1 {
+		t.Fatalf("hunks = %+v, want 1", file.Hunks)
+	}
+	hunk := file.Hunks[0]
+	addedLines := strings.Split(strings.TrimSuffix(added, "\n"), "\n")
+	if hunk.StartLine != 10 || hunk.LineCount != len(addedLines) {
+		t.Errorf("hunk covers lines %d+%d, want 10+%d", hunk.StartLine, hunk.LineCount, len(addedLines))
+	}
+
+	cm, err := ns.GetCorpusManager("diff", "")
+	if err != nil {
+		t.Fatalf("GetCorpusManager: %v", err)
+	}
+	want, err := cm.normalizedTokens(ctx, []byte(strings.Join(addedLines, "\n")+"\n"), "go")
+	if err != nil {
+		t.Fatalf("normalizedTokens: %v", err)
+	}
+	if hunk.TokenCount != len(want) || file.TokenCount != len(want) {
+		t.Errorf("scored %d tokens (file %d), want the %d tokens of the added lines", hunk.TokenCount, file.TokenCount, len(want))
+	}
+	if entropy := cm.GetGlobalModel().CrossEntropy(want); math.Abs(hunk.Entropy-entropy) > 1e-9 || math.Abs(file.Entropy-entropy) > 1e-9 {
+		t.Errorf("entropy = %f (file %f), want %f", hunk.Entropy, file.Entropy, entropy)
+	}
+
+	if _, err := ns.AnalyzeDiff(ctx, &config.Repository{Name: "diff", Path: t.TempDir()}); !errors.Is(err, ErrNotGitRepository) {
+		t.Errorf("AnalyzeDiff outside a git repository: err = %v, want ErrNotGitRepository", err)
+	}
+}
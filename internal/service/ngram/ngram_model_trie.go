@@ -5,6 +5,10 @@ import (
 	"sync"
 )
 
+// OOVToken stands in for every token first seen after a model's vocabulary
+// reached its cap
+const OOVToken = "<OOV>"
+
 // ModelStats contains statistics about an n-gram model
 type ModelStats struct {
 	N              int    `json:"n"`
@@ -22,7 +26,9 @@ type NGramModelTrie struct {
 	vocabulary  *NGramTrie   // Trie for unigrams (vocabulary)
 	totalTokens int64        // Total number of tokens
 	smoother    Smoother     // Smoothing algorithm
-	mu          sync.RWMutex // Protects totalTokens
+	maxVocab    int          // Distinct tokens modeled before new ones become OOVToken (0 = unlimited)
+	countScale  int64        // Stored count of one unweighted occurrence, see SetCountScale
+	mu          sync.RWMutex // Protects totalTokens, maxVocab and countScale
 }
 
 // NewNGramModelTrie creates a new trie-based n-gram model without bloom filter
@@ -58,104 +64,228 @@ func NewNGramModelTrieWithBloom(n int, smoother Smoother, useBloom bool, expecte
 		vocabulary:  vocabulary,
 		totalTokens: 0,
 		smoother:    smoother,
+		countScale:  1,
+	}
+}
+
+// SetCountScale sets the count stored for one unweighted occurrence, so that
+// AddWeighted can record weights below 1 as a fraction of an occurrence: with
+// a scale of 100, weights are kept to two decimals. Probabilities and stats
+// divide the scale back out, so the scale doesn't change them for unweighted
+// tokens. Counts already in the model are rescaled when scale is a multiple of
+// the current scale; other scales are ignored.
+func (m *NGramModelTrie) SetCountScale(scale int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rescale(scale)
+}
+
+// rescale multiplies the model's counts up to scale, a multiple of the current
+// scale. Callers hold m.mu.
+func (m *NGramModelTrie) rescale(scale int64) {
+	if scale <= m.countScale || scale%m.countScale != 0 {
+		return
+	}
+	factor := scale / m.countScale
+	m.ngramTrie.scaleCounts(factor)
+	m.contextTrie.scaleCounts(factor)
+	m.vocabulary.scaleCounts(factor)
+	m.totalTokens *= factor
+	m.countScale = scale
+}
+
+// weightUnits converts a weight into the count each occurrence adds. Callers
+// hold m.mu.
+func (m *NGramModelTrie) weightUnits(weight float64) int64 {
+	return int64(math.Round(weight * float64(m.countScale)))
+}
+
+// SetMaxVocabulary caps the number of distinct tokens the model keeps; 0
+// removes the cap. Once the cap is reached, tokens not yet in the vocabulary
+// are counted as OOVToken, and Probability scores unseen tokens with the
+// OOVToken statistics. Tokens already modeled are kept.
+func (m *NGramModelTrie) SetMaxVocabulary(maxVocab int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxVocab = max(maxVocab, 0)
+}
+
+// mapTokens returns tokens with those outside the vocabulary replaced by
+// OOVToken. When adding, new tokens are admitted while the vocabulary is below
+// its cap. Without a cap tokens are returned as is. Callers hold m.mu.
+func (m *NGramModelTrie) mapTokens(tokens []string, adding bool) []string {
+	if m.maxVocab == 0 {
+		return tokens
+	}
+
+	size := m.vocabulary.VocabularySize()
+	if m.vocabulary.HasToken(OOVToken) {
+		size--
+	}
+	pending := make(map[string]bool) // Admitted by this call but not yet in the vocabulary
+
+	mapped := make([]string, len(tokens))
+	for i, token := range tokens {
+		switch {
+		case pending[token] || m.vocabulary.HasToken(token):
+			mapped[i] = token
+		case adding && size < m.maxVocab:
+			pending[token] = true
+			size++
+			mapped[i] = token
+		default:
+			mapped[i] = OOVToken
+		}
 	}
+	return mapped
 }
 
 // Add adds tokens to the model, updating all counts
 func (m *NGramModelTrie) Add(tokens []string) {
+	m.AddWeighted(tokens, 1)
+}
+
+// AddWeighted adds tokens to the model as if they occurred weight times, so
+// every count they contribute is multiplied by weight. Weights below 1 count
+// as a fraction of an occurrence, to the precision of the model's count scale;
+// a weight rounding to nothing adds nothing.
+func (m *NGramModelTrie) AddWeighted(tokens []string, weight float64) {
 	if len(tokens) == 0 {
 		return
 	}
 
+	// The vocabulary is updated under the lock so concurrent adds cannot
+	// admit tokens beyond the cap
 	m.mu.Lock()
-	m.totalTokens += int64(len(tokens))
-	m.mu.Unlock()
+	units := m.weightUnits(weight)
+	if units < 1 {
+		m.mu.Unlock()
+		return
+	}
+	m.totalTokens += int64(len(tokens)) * units
+	tokens = m.mapTokens(tokens, true)
 
 	// Update vocabulary (unigrams)
 	for _, token := range tokens {
-		m.vocabulary.Insert([]string{token})
+		m.vocabulary.InsertN([]string{token}, units)
 	}
+	m.mu.Unlock()
 
 	// Extract and count n-grams
 	ngrams := m.extractNGrams(tokens)
 	for _, ng := range ngrams {
 		// Add full n-gram
-		m.ngramTrie.Insert(ng)
+		m.ngramTrie.InsertN(ng, units)
 
 		// Add context (n-1 gram) if applicable
 		if len(ng) > 1 {
 			context := ng[:len(ng)-1]
-			m.contextTrie.Insert(context)
+			m.contextTrie.InsertN(context, units)
 		}
 	}
 }
 
 // Remove removes tokens from the model (for incremental updates)
 func (m *NGramModelTrie) Remove(tokens []string) {
+	m.RemoveWeighted(tokens, 1)
+}
+
+// RemoveWeighted undoes AddWeighted with the same tokens and weight
+func (m *NGramModelTrie) RemoveWeighted(tokens []string, weight float64) {
 	if len(tokens) == 0 {
 		return
 	}
 
 	m.mu.Lock()
-	m.totalTokens -= int64(len(tokens))
+	units := m.weightUnits(weight)
+	if units < 1 {
+		m.mu.Unlock()
+		return
+	}
+	m.totalTokens -= int64(len(tokens)) * units
 	if m.totalTokens < 0 {
 		m.totalTokens = 0
 	}
+	tokens = m.mapTokens(tokens, false)
 	m.mu.Unlock()
 
 	// Remove from vocabulary
 	for _, token := range tokens {
-		m.vocabulary.Remove([]string{token})
+		m.vocabulary.RemoveN([]string{token}, units)
 	}
 
 	// Remove n-grams
 	ngrams := m.extractNGrams(tokens)
 	for _, ng := range ngrams {
-		m.ngramTrie.Remove(ng)
+		m.ngramTrie.RemoveN(ng, units)
 
 		if len(ng) > 1 {
 			context := ng[:len(ng)-1]
-			m.contextTrie.Remove(context)
+			m.contextTrie.RemoveN(context, units)
 		}
 	}
 }
 
-// Merge combines another trie-based model into this one
+// Merge combines another trie-based model into this one by adding its n-gram,
+// context and vocabulary counts, without re-tokenizing any source. Models of a
+// different order are ignored. Counts are merged at the larger of the two
+// count scales when one is a multiple of the other; models whose scales don't
+// divide are ignored as well. The vocabulary cap is not enforced on merged
+// tokens.
 func (m *NGramModelTrie) Merge(other *NGramModelTrie) {
-	if other == nil || other.n != m.n {
+	if other == nil || other == m || other.n != m.n {
 		return
 	}
 
-	// Note: This is a simplified merge that re-adds all n-grams
-	// A more efficient implementation would merge the tries directly
-	// For now, we just update the total tokens
 	m.mu.Lock()
+	defer m.mu.Unlock()
 	other.mu.RLock()
-	m.totalTokens += other.totalTokens
-	other.mu.RUnlock()
-	m.mu.Unlock()
+	defer other.mu.RUnlock()
 
-	// TODO: Implement efficient trie merging
-	// For now, this is a placeholder - the tries are independent structures
+	m.rescale(other.countScale)
+	if m.countScale%other.countScale != 0 {
+		return
+	}
+	factor := m.countScale / other.countScale
+	if factor == 1 {
+		m.ngramTrie.Merge(other.ngramTrie)
+		m.contextTrie.Merge(other.contextTrie)
+		m.vocabulary.Merge(other.vocabulary)
+		m.totalTokens += other.totalTokens
+		return
+	}
+
+	// Merging scales up the other model's counts, so merge a scaled copy
+	scaled := NewNGramModelTrie(other.n, other.smoother)
+	for _, pair := range []struct{ dst, src *NGramTrie }{
+		{scaled.ngramTrie, other.ngramTrie},
+		{scaled.contextTrie, other.contextTrie},
+		{scaled.vocabulary, other.vocabulary},
+	} {
+		pair.dst.Merge(pair.src)
+		pair.dst.scaleCounts(factor)
+	}
+	m.ngramTrie.Merge(scaled.ngramTrie)
+	m.contextTrie.Merge(scaled.contextTrie)
+	m.vocabulary.Merge(scaled.vocabulary)
+	m.totalTokens += other.totalTokens * factor
 }
 
-// Probability calculates the probability of a token given its context
+// Probability calculates the probability of a token given its context. A
+// unigram model ignores the context and scores the token by its share of all
+// tokens seen.
 func (m *NGramModelTrie) Probability(token string, context []string) float64 {
 	// Build the n-gram
 	ng := append(context, token)
+	m.mu.RLock()
+	ng = m.mapTokens(ng, false)
+	scale := float64(m.countScale)
+	totalTokens := float64(m.totalTokens) / scale
+	m.mu.RUnlock()
 	if len(ng) > m.n {
 		ng = ng[len(ng)-m.n:]
 	}
 
-	ngramCount := m.ngramTrie.GetCount(ng)
-
-	// Get context count
-	contextCount := int64(0)
-	if len(ng) > 1 {
-		ctx := ng[:len(ng)-1]
-		contextCount = m.contextTrie.GetCount(ctx)
-	}
-
 	// Calculate backoff probability (uniform for now)
 	vocabSize := m.vocabulary.VocabularySize()
 	backoffProb := 1.0 / float64(vocabSize)
@@ -163,38 +293,76 @@ func (m *NGramModelTrie) Probability(token string, context []string) float64 {
 		backoffProb = 0.0
 	}
 
-	return m.smoother.Smooth(ngramCount, contextCount, backoffProb, vocabSize)
+	if m.n == 1 {
+		// Every token follows the empty context. The vocabulary keeps the
+		// first sighting of each token, which the bloom-filtered n-gram trie
+		// does not.
+		return m.smoother.Smooth(float64(m.vocabulary.GetCount(ng))/scale, totalTokens, int64(vocabSize), backoffProb, vocabSize)
+	}
+
+	ngramCount := float64(m.ngramTrie.GetCount(ng)) / scale
+
+	// Get context count and the number of distinct tokens seen after it
+	contextCount := 0.0
+	continuationTypes := int64(0)
+	if len(ng) > 1 {
+		ctx := ng[:len(ng)-1]
+		contextCount = float64(m.contextTrie.GetCount(ctx)) / scale
+		continuationTypes = int64(m.ngramTrie.ContinuationCount(ctx))
+	}
+
+	return m.smoother.Smooth(ngramCount, contextCount, continuationTypes, backoffProb, vocabSize)
 }
 
 // CrossEntropy calculates the cross-entropy of a token sequence
 func (m *NGramModelTrie) CrossEntropy(tokens []string) float64 {
-	if len(tokens) == 0 {
-		return 0.0
+	scorer := m.newEntropyScorer()
+	for _, token := range tokens {
+		scorer.score(token)
 	}
+	return scorer.crossEntropy()
+}
 
-	totalLogProb := 0.0
-	count := 0
+// entropyScorer computes the cross-entropy of a token stream under a model
+// one token at a time, keeping only the last n-1 tokens as context
+type entropyScorer struct {
+	model        *NGramModelTrie
+	context      []string
+	tokens       int // Tokens scored
+	totalLogProb float64
+	count        int // Tokens with a non-zero probability
+}
 
-	for i := 0; i < len(tokens); i++ {
-		// Build context
-		contextStart := 0
-		if i >= m.n-1 {
-			contextStart = i - m.n + 1
-		}
-		context := tokens[contextStart:i]
+func (m *NGramModelTrie) newEntropyScorer() *entropyScorer {
+	return &entropyScorer{model: m, context: make([]string, 0, max(m.n-1, 0))}
+}
 
-		prob := m.Probability(tokens[i], context)
-		if prob > 0 {
-			totalLogProb += math.Log2(prob)
-			count++
-		}
+// score adds the next token of the stream
+func (s *entropyScorer) score(token string) {
+	s.tokens++
+	// Probability appends to the context, so it gets a slice without spare capacity
+	prob := s.model.Probability(token, s.context[:len(s.context):len(s.context)])
+	if prob > 0 {
+		s.totalLogProb += math.Log2(prob)
+		s.count++
 	}
 
-	if count == 0 {
-		return 0.0
+	if cap(s.context) == 0 {
+		return
 	}
+	if len(s.context) == cap(s.context) {
+		copy(s.context, s.context[1:])
+		s.context = s.context[:len(s.context)-1]
+	}
+	s.context = append(s.context, token)
+}
 
-	return -totalLogProb / float64(count)
+// crossEntropy returns the cross-entropy of the tokens scored so far
+func (s *entropyScorer) crossEntropy() float64 {
+	if s.count == 0 {
+		return 0.0
+	}
+	return -s.totalLogProb / float64(s.count)
 }
 
 // Perplexity calculates the perplexity of a token sequence
@@ -203,9 +371,11 @@ func (m *NGramModelTrie) Perplexity(tokens []string) float64 {
 	return math.Pow(2, entropy)
 }
 
-// extractNGrams extracts all n-grams from a token sequence (returns as []string slices)
+// extractNGrams extracts all n-grams from a token sequence (returns as []string slices).
+// A sequence shorter than n has none; storing it as a shorter n-gram would
+// count it as a prefix of every n-gram starting with it.
 func (m *NGramModelTrie) extractNGrams(tokens []string) [][]string {
-	if len(tokens) == 0 {
+	if len(tokens) < m.n {
 		return nil
 	}
 
@@ -218,13 +388,6 @@ func (m *NGramModelTrie) extractNGrams(tokens []string) [][]string {
 		result = append(result, ng)
 	}
 
-	// Handle tail if sequence is shorter than n
-	if len(tokens) < m.n {
-		ng := make([]string, len(tokens))
-		copy(ng, tokens)
-		result = append(result, ng)
-	}
-
 	return result
 }
 
@@ -236,8 +399,8 @@ func (m *NGramModelTrie) Stats() ModelStats {
 	return ModelStats{
 		N:              m.n,
 		VocabularySize: m.vocabulary.VocabularySize(),
-		NGramCount:     int(m.ngramTrie.TotalNGrams()),
-		TotalTokens:    m.totalTokens,
+		NGramCount:     int(m.ngramTrie.TotalNGrams() / m.countScale),
+		TotalTokens:    m.totalTokens / m.countScale,
 		SmootherName:   m.smoother.Name(),
 	}
 }
@@ -249,23 +412,37 @@ func (m *NGramModelTrie) MemoryStats() TrieModelMemoryStats {
 
 	return TrieModelMemoryStats{
 		N:               m.n,
-		TotalTokens:     m.totalTokens,
+		TotalTokens:     m.totalTokens / m.countScale,
 		VocabularyStats: m.vocabulary.MemoryStats(),
 		NGramStats:      m.ngramTrie.MemoryStats(),
 		ContextStats:    m.contextTrie.MemoryStats(),
 	}
 }
 
-// Prune removes n-grams with count below threshold
+// Prune removes n-grams occurring fewer than minCount times, and returns how
+// many occurrences were pruned from the n-gram and context tries
 func (m *NGramModelTrie) Prune(minCount int64) (int64, int64) {
-	ngramPruned := m.ngramTrie.Prune(minCount)
-	contextPruned := m.contextTrie.Prune(minCount)
-	return ngramPruned, contextPruned
+	m.mu.RLock()
+	scale := m.countScale
+	m.mu.RUnlock()
+
+	ngramPruned := m.ngramTrie.Prune(minCount * scale)
+	contextPruned := m.contextTrie.Prune(minCount * scale)
+	return ngramPruned / scale, contextPruned / scale
 }
 
-// GetNGramsWithPrefix returns all n-grams starting with a given prefix
+// GetNGramsWithPrefix returns all n-grams starting with a given prefix, with
+// their counts in whole occurrences
 func (m *NGramModelTrie) GetNGramsWithPrefix(prefix []string) []NGramWithCount {
-	return m.ngramTrie.GetAllWithPrefix(prefix)
+	m.mu.RLock()
+	scale := m.countScale
+	m.mu.RUnlock()
+
+	ngrams := m.ngramTrie.GetAllWithPrefix(prefix)
+	for i := range ngrams {
+		ngrams[i].Count /= scale
+	}
+	return ngrams
 }
 
 // TrieModelMemoryStats contains detailed memory statistics for the trie model
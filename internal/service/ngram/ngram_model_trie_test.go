@@ -0,0 +1,153 @@
+package ngram
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestMaxVocabularyMapsNewTokensToOOV(t *testing.T) {
+	model := NewNGramModelTrie(2, NewAddKSmoother(1.0))
+	model.SetMaxVocabulary(5)
+	model.Add([]string{"a", "b", "c", "a", "d", "e", "f", "g", "a", "h", "b", "f"})
+
+	vocabulary := map[string]bool{}
+	for _, token := range model.vocabulary.GetVocabulary() {
+		vocabulary[token] = true
+	}
+	want := []string{"a", "b", "c", "d", "e", OOVToken}
+	if len(vocabulary) != len(want) {
+		t.Errorf("vocabulary = %v, want %v", model.vocabulary.GetVocabulary(), want)
+	}
+	for _, token := range want {
+		if !vocabulary[token] {
+			t.Errorf("vocabulary is missing %q", token)
+		}
+	}
+
+	// f, g, h and the second f all count as OOV
+	if got := model.vocabulary.GetCount([]string{OOVToken}); got != 4 {
+		t.Errorf("OOV count = %d, want 4", got)
+	}
+	if got := model.ngramTrie.GetCount([]string{"e", OOVToken}); got != 1 {
+		t.Errorf("count(e <OOV>) = %d, want 1", got)
+	}
+
+	// Unseen tokens take the OOV statistics, and each context still yields a
+	// distribution over the vocabulary
+	for _, token := range []string{"f", "never-seen"} {
+		got, want := model.Probability(token, []string{"a"}), model.Probability(OOVToken, []string{"a"})
+		if got != want || got <= 0 {
+			t.Errorf("P(%s|a) = %v, want the OOV probability %v", token, got, want)
+		}
+	}
+	for _, context := range []string{"a", OOVToken} {
+		sum := 0.0
+		for _, token := range want {
+			sum += model.Probability(token, []string{context})
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("probabilities after %q sum to %.6f, want 1", context, sum)
+		}
+	}
+	entropy := model.CrossEntropy([]string{"x", "y", "a", "z"})
+	if math.IsNaN(entropy) || math.IsInf(entropy, 0) || entropy <= 0 {
+		t.Errorf("cross-entropy of unseen tokens = %v, want a positive finite value", entropy)
+	}
+
+	uncapped := NewNGramModelTrie(2, NewAddKSmoother(1.0))
+	uncapped.Add([]string{"a", "b", "c", "a", "d", "e", "f", "g", "a", "h", "b", "f"})
+	if got := uncapped.vocabulary.VocabularySize(); got != 8 {
+		t.Errorf("uncapped vocabulary size = %d, want 8", got)
+	}
+}
+
+func TestUnigramProbability(t *testing.T) {
+	tokens := []string{"a", "b", "a", "c", "a", "b"}
+	tests := []struct {
+		name     string
+		useBloom bool
+	}{
+		{"plain", false},
+		{"bloom", true}, // Singletons never reach the bloom-filtered n-gram trie
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			model := NewNGramModelTrieWithBloom(1, NewAddKSmoother(1.0), tt.useBloom, 1000, 0.01)
+			model.Add(tokens)
+
+			// Add-one over 6 tokens and a vocabulary of 3: (count+1)/(6+3)
+			want := map[string]float64{"a": 4.0 / 9, "b": 3.0 / 9, "c": 2.0 / 9}
+			total := 0.0
+			for token, p := range want {
+				// The context is ignored
+				for _, context := range [][]string{nil, {"a"}, {"c", "b"}} {
+					if got := model.Probability(token, context); math.Abs(got-p) > 1e-12 {
+						t.Errorf("P(%s|%v) = %v, want %v", token, context, got, p)
+					}
+				}
+				total += model.Probability(token, nil)
+			}
+			if math.Abs(total-1) > 1e-12 {
+				t.Errorf("probabilities sum to %v, want 1", total)
+			}
+			if got := model.Probability("never-seen", nil); math.Abs(got-1.0/9) > 1e-12 {
+				t.Errorf("P(never-seen) = %v, want %v", got, 1.0/9)
+			}
+		})
+	}
+}
+
+func TestShortSequenceStoresNoNGrams(t *testing.T) {
+	model := NewNGramModelTrie(3, NewAddKSmoother(1.0))
+	model.Add([]string{"a", "b"})
+
+	if got := model.ngramTrie.TotalNGrams(); got != 0 {
+		t.Errorf("stored %d n-grams for 2 tokens with n=3, want 0", got)
+	}
+	if got := model.ngramTrie.GetAllWithPrefix(nil); len(got) != 0 {
+		t.Errorf("n-gram trie holds %v, want nothing", got)
+	}
+	if got := model.contextTrie.TotalNGrams(); got != 0 {
+		t.Errorf("stored %d contexts for 2 tokens with n=3, want 0", got)
+	}
+	// The tokens are still part of the vocabulary
+	if got := model.vocabulary.VocabularySize(); got != 2 {
+		t.Errorf("vocabulary size = %d, want 2", got)
+	}
+
+	// Full-length n-grams of a later sequence are not miscounted by the
+	// earlier short one
+	model.Add([]string{"a", "b", "c"})
+	if got := model.ngramTrie.GetCount([]string{"a", "b"}); got != 0 {
+		t.Errorf("count(a b) = %d, want 0: only trigrams are stored", got)
+	}
+	if got := model.ngramTrie.GetCount([]string{"a", "b", "c"}); got != 1 {
+		t.Errorf("count(a b c) = %d, want 1", got)
+	}
+}
+
+func TestEntropyScorerUsesLastTokensAsContext(t *testing.T) {
+	tokens := strings.Fields("for i := 0 ; i < n ; i ++ { sum += i }")
+	for _, n := range []int{1, 2, 3} {
+		model := NewNGramModelTrie(n, NewAddKSmoother(1.0))
+		model.Add(strings.Fields("for i := 0 ; i < n ; i ++ { total += i }"))
+
+		// Reference: every token scored with the n-1 tokens before it
+		totalLogProb := 0.0
+		for i, token := range tokens {
+			context := tokens[max(i-n+1, 0):i]
+			totalLogProb += math.Log2(model.Probability(token, context))
+		}
+		want := -totalLogProb / float64(len(tokens))
+
+		scorer := model.newEntropyScorer()
+		for _, token := range tokens {
+			scorer.score(token)
+		}
+		if got := scorer.crossEntropy(); math.Abs(got-want) > 1e-12 || scorer.tokens != len(tokens) {
+			t.Errorf("n=%d: scorer entropy = %v over %d tokens, want %v over %d", n, got, scorer.tokens, want, len(tokens))
+		}
+	}
+}
@@ -3,46 +3,70 @@ package ngram
 import (
 	"bot-go/internal/service/tokenizer"
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/bits-and-blooms/bloom/v3"
 	"go.uber.org/zap"
 )
 
+// modelFormatVersion is bumped whenever the serialized layout or the token
+// normalization changes, so models built with older vocabularies are rebuilt
+const modelFormatVersion = "5.0"
+
 // SerializableNGramModel is a serializable representation of the n-gram model (always Trie+Bloom)
 type SerializableNGramModel struct {
 	Version      string    // Format version
 	N            int       // N-gram size
-	TotalTokens  int64     // Total tokens processed
+	MinTokens    int       // Files with fewer tokens were kept out of the model (0 = no minimum)
+	TotalTokens  int64     // Total tokens processed, multiplied by CountScale
+	CountScale   int64     // Count of one unweighted token (0 for models saved before it was kept, meaning 1)
 	CreatedAt    time.Time // When the model was created
 	RepoName     string    // Repository name
 	SmootherName string    // Smoother type
+	SmootherK    float64   // AddK's k (0 for other smoothers and models saved before it was kept)
+	Checkpoint   bool      // Saved part way through a build that has not finished
 
 	// File-level metadata (for GetStats)
 	FileMetadata map[string]FileMetadata // path -> metadata
-
-	// Trie-based model data
-	TokenToID    map[string]uint32      // String interning map
-	IDToToken    []string               // Reverse lookup
-	TrieNodes    []SerializableTrieNode // Flattened trie structure
-	VocabNodes   []SerializableTrieNode // Vocabulary trie
-	ContextNodes []SerializableTrieNode // Context trie
+	TokenTable   []string                // Tokens of the files' TokenIDs, by ID
+
+	// Trie-based model data. Each trie interns tokens in its own order, so
+	// each has its own ID table.
+	TokenToID        map[string]uint32      // String interning map of the vocabulary trie
+	IDToToken        []string               // Reverse lookup
+	NGramIDToToken   []string               // Token IDs of the n-gram trie
+	ContextIDToToken []string               // Token IDs of the context trie
+	TrieNodes        []SerializableTrieNode // Flattened trie structure
+	VocabNodes       []SerializableTrieNode // Vocabulary trie
+	ContextNodes     []SerializableTrieNode // Context trie
 
 	// Trie counters
 	NGramTrieTotalNGrams   int64 // Total n-grams in ngramTrie
 	NGramTrieTotalTokens   int64 // Total tokens in ngramTrie
 	ContextTrieTotalNGrams int64 // Total n-grams in contextTrie
 	ContextTrieTotalTokens int64 // Total tokens in contextTrie
+
+	// Bloom filters holding the n-grams and contexts seen once, and the
+	// sightings removed from them, so files can still be updated after loading
+	NGramBloom      *bloom.BloomFilter
+	ContextBloom    *bloom.BloomFilter
+	NGramReleased   map[string]int
+	ContextReleased map[string]int
 }
 
 // FileMetadata stores minimal file information for statistics
 type FileMetadata struct {
-	Path       string  `json:"path"`
-	Language   string  `json:"language"`
-	TokenCount int     `json:"token_count"`
-	Entropy    float64 `json:"entropy"`
+	Path       string    `json:"path"`
+	Language   string    `json:"language"`
+	TokenCount int       `json:"token_count"`
+	Entropy    float64   `json:"entropy"`
+	ModTime    time.Time `json:"mod_time"` // On-disk modification time when the file was added
+	Weight     float64   `json:"weight"`   // Weight of the file in the global model
+	TokenIDs   []uint32  `json:"-"`        // Tokens the file added to the global model, in TokenTable
 }
 
 // SerializableTrieNode represents a serialized trie node
@@ -57,6 +81,7 @@ type SerializableTrieNode struct {
 // NGramPersistence handles saving and loading n-gram models
 type NGramPersistence struct {
 	outputDir string
+	smoothers *SmootherRegistry // Restores the smoother a model was saved with
 	logger    *zap.Logger
 }
 
@@ -69,6 +94,7 @@ func NewNGramPersistence(outputDir string, logger *zap.Logger) (*NGramPersistenc
 
 	return &NGramPersistence{
 		outputDir: outputDir,
+		smoothers: NewSmootherRegistry(),
 		logger:    logger,
 	}, nil
 }
@@ -80,11 +106,24 @@ func (p *NGramPersistence) GetModelPath(repoName string) string {
 
 // SaveCorpusManager saves a corpus manager to disk (always Trie+Bloom)
 func (p *NGramPersistence) SaveCorpusManager(cm *CorpusManager, repoName string) error {
+	return p.save(cm, repoName, false)
+}
+
+// SaveCheckpoint saves the corpus manager of a build in progress. It replaces
+// any saved model; loading it yields a corpus manager marked as a checkpoint,
+// so the build can be resumed instead of restarted.
+func (p *NGramPersistence) SaveCheckpoint(cm *CorpusManager, repoName string) error {
+	return p.save(cm, repoName, true)
+}
+
+func (p *NGramPersistence) save(cm *CorpusManager, repoName string, checkpoint bool) error {
 	model := &SerializableNGramModel{
-		Version:      "2.0",
+		Version:      modelFormatVersion,
 		N:            cm.n,
+		MinTokens:    cm.minTokens,
 		CreatedAt:    time.Now(),
 		RepoName:     repoName,
+		Checkpoint:   checkpoint,
 		FileMetadata: make(map[string]FileMetadata),
 	}
 
@@ -96,9 +135,15 @@ func (p *NGramPersistence) SaveCorpusManager(cm *CorpusManager, repoName string)
 			Language:   fm.Language,
 			TokenCount: fm.TokenCount,
 			Entropy:    fm.Entropy,
+			ModTime:    fm.LastModified,
+			Weight:     fm.Weight,
+			TokenIDs:   fm.TokenIDs,
 		}
 	}
 	cm.mu.RUnlock()
+	cm.tokenMu.RLock()
+	model.TokenTable = cm.tokenTable
+	cm.tokenMu.RUnlock()
 
 	// Serialize trie model
 	if err := p.serializeTrieModel(cm.globalModel, model); err != nil {
@@ -115,7 +160,8 @@ func (p *NGramPersistence) SaveCorpusManager(cm *CorpusManager, repoName string)
 		zap.String("repo", repoName),
 		zap.String("path", modelPath),
 		zap.Int("n", model.N),
-		zap.Int64("tokens", model.TotalTokens))
+		zap.Int64("tokens", model.TotalTokens/max(model.CountScale, 1)),
+		zap.Bool("checkpoint", checkpoint))
 
 	return nil
 }
@@ -135,27 +181,53 @@ func (p *NGramPersistence) LoadCorpusManager(repoName string, tokenizerRegistry
 		return nil, fmt.Errorf("failed to load from file: %w", err)
 	}
 
-	// Create smoother (default to AddK for now)
-	var smoother Smoother = NewAddKSmoother(1.0)
-	if model.SmootherName == "WittenBell" {
-		smoother = NewWittenBellSmoother()
+	if model.Version != modelFormatVersion {
+		return nil, fmt.Errorf("saved model for %s has format version %s, want %s", repoName, model.Version, modelFormatVersion)
+	}
+
+	smoother, err := p.smoothers.Create(model.SmootherName, SmootherParams{K: model.SmootherK})
+	if err != nil {
+		return nil, fmt.Errorf("saved model for %s: %w", repoName, err)
 	}
 
 	// Create corpus manager (always Trie+Bloom)
 	cm := NewCorpusManager(model.N, smoother, tokenizerRegistry, logger)
+	cm.checkpoint = model.Checkpoint
+	cm.minTokens = model.MinTokens
 
-	// Restore file metadata
+	// Restore file metadata. Without its tokens a file could not be updated
+	// or removed, so such models are rebuilt instead.
 	cm.mu.Lock()
 	for path, metadata := range model.FileMetadata {
+		if len(metadata.TokenIDs) != metadata.TokenCount {
+			cm.mu.Unlock()
+			return nil, fmt.Errorf("saved model for %s lacks the tokens of %s", repoName, path)
+		}
+		for _, id := range metadata.TokenIDs {
+			if int(id) >= len(model.TokenTable) {
+				cm.mu.Unlock()
+				return nil, fmt.Errorf("saved model for %s has token ID %d out of range for %s", repoName, id, path)
+			}
+		}
+		lastModified := metadata.ModTime
+		if lastModified.IsZero() {
+			lastModified = model.CreatedAt // Saved before modification times were kept
+		}
 		cm.fileModels[path] = &FileModel{
 			FilePath:     metadata.Path,
 			Language:     metadata.Language,
 			TokenCount:   metadata.TokenCount,
 			Entropy:      metadata.Entropy,
-			LastModified: model.CreatedAt,
+			LastModified: lastModified,
+			Weight:       metadata.Weight,
+			TokenIDs:     metadata.TokenIDs,
 		}
 	}
 	cm.mu.Unlock()
+	cm.tokenTable = model.TokenTable
+	for id, token := range model.TokenTable {
+		cm.tokenIDs[token] = uint32(id)
+	}
 
 	// Deserialize trie model
 	if err := p.deserializeTrieModel(model, cm); err != nil {
@@ -166,11 +238,93 @@ func (p *NGramPersistence) LoadCorpusManager(repoName string, tokenizerRegistry
 		zap.String("repo", repoName),
 		zap.String("path", modelPath),
 		zap.Int("n", model.N),
-		zap.Int64("tokens", model.TotalTokens))
+		zap.Int64("tokens", model.TotalTokens/max(model.CountScale, 1)))
 
 	return cm, nil
 }
 
+// MergeModels combines the saved models of two repositories into a model
+// saved under outputName, e.g. to build an organization-wide corpus without
+// re-tokenizing either repository. The models' n-gram, context and vocabulary
+// counts are added together; the merged model uses repoA's smoother. File
+// metadata is kept, with each path prefixed by its repository name so files
+// of the same name stay distinct. Models of different orders, and checkpoints
+// of unfinished builds, cannot be merged.
+func (p *NGramPersistence) MergeModels(repoA, repoB, outputName string, tokenizerRegistry *tokenizer.TokenizerRegistry, logger *zap.Logger) (*CorpusManager, error) {
+	cmA, err := p.LoadCorpusManager(repoA, tokenizerRegistry, logger)
+	if err != nil {
+		return nil, err
+	}
+	cmB, err := p.LoadCorpusManager(repoB, tokenizerRegistry, logger)
+	if err != nil {
+		return nil, err
+	}
+	if cmA.n != cmB.n {
+		return nil, fmt.Errorf("cannot merge %d-gram model of %s with %d-gram model of %s", cmA.n, repoA, cmB.n, repoB)
+	}
+	sources := []struct {
+		repoName string
+		cm       *CorpusManager
+	}{{repoA, cmA}, {repoB, cmB}}
+	for _, src := range sources {
+		if src.cm.IsCheckpoint() {
+			return nil, fmt.Errorf("saved model for %s is a checkpoint of an unfinished build", src.repoName)
+		}
+	}
+
+	merged := NewCorpusManager(cmA.n, cmA.smoother, tokenizerRegistry, logger)
+	for _, src := range sources {
+		merged.globalModel.Merge(src.cm.globalModel)
+		for path, fm := range src.cm.fileModels {
+			prefixed := src.repoName + "/" + path
+			fm.FilePath = prefixed
+			fm.TokenIDs = merged.internTokens(src.cm.fileTokens(fm))
+			merged.fileModels[prefixed] = fm
+		}
+	}
+
+	if err := p.SaveCorpusManager(merged, outputName); err != nil {
+		return nil, err
+	}
+
+	p.logger.Info("Merged n-gram models",
+		zap.String("repo_a", repoA),
+		zap.String("repo_b", repoB),
+		zap.String("output", outputName),
+		zap.Int("files", len(merged.fileModels)))
+
+	return merged, nil
+}
+
+// groupsFile is the file in the output directory mapping repositories to the
+// corpus groups their models were built into
+const groupsFile = "ngram_groups.json"
+
+// SaveGroups saves which corpus group each repository was processed into
+func (p *NGramPersistence) SaveGroups(groups map[string]string) error {
+	data, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(p.outputDir, groupsFile), data, 0644)
+}
+
+// LoadGroups loads the mapping saved by SaveGroups; it is empty if none was saved
+func (p *NGramPersistence) LoadGroups() (map[string]string, error) {
+	groups := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(p.outputDir, groupsFile))
+	if os.IsNotExist(err) {
+		return groups, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", groupsFile, err)
+	}
+	return groups, nil
+}
+
 // ModelExists checks if a saved model exists for a repository
 func (p *NGramPersistence) ModelExists(repoName string) bool {
 	modelPath := p.GetModelPath(repoName)
@@ -191,18 +345,34 @@ func (p *NGramPersistence) DeleteModel(repoName string) error {
 // serializeTrieModel serializes a trie-based model
 func (p *NGramPersistence) serializeTrieModel(trieModel *NGramModelTrie, target *SerializableNGramModel) error {
 	stats := trieModel.Stats()
-	target.TotalTokens = stats.TotalTokens
+	trieModel.mu.RLock()
+	target.TotalTokens = trieModel.totalTokens
+	target.CountScale = trieModel.countScale
+	trieModel.mu.RUnlock()
 	target.SmootherName = stats.SmootherName
+	if !p.smoothers.Registered(target.SmootherName) {
+		// The model could be saved but never loaded again
+		return fmt.Errorf("unknown smoother: %q", target.SmootherName)
+	}
+	if addK, ok := trieModel.smoother.(*AddKSmoother); ok {
+		target.SmootherK = addK.k
+	}
 
 	// Serialize string interning
 	target.TokenToID = trieModel.vocabulary.tokenToID
 	target.IDToToken = trieModel.vocabulary.idToToken
+	target.NGramIDToToken = trieModel.ngramTrie.idToToken
+	target.ContextIDToToken = trieModel.contextTrie.idToToken
 
 	// Serialize trie counters
 	target.NGramTrieTotalNGrams = trieModel.ngramTrie.totalNGrams
 	target.NGramTrieTotalTokens = trieModel.ngramTrie.totalTokens
 	target.ContextTrieTotalNGrams = trieModel.contextTrie.totalNGrams
 	target.ContextTrieTotalTokens = trieModel.contextTrie.totalTokens
+	target.NGramBloom = trieModel.ngramTrie.bloomFilter
+	target.ContextBloom = trieModel.contextTrie.bloomFilter
+	target.NGramReleased = trieModel.ngramTrie.released
+	target.ContextReleased = trieModel.contextTrie.released
 
 	// Serialize tries
 	target.TrieNodes = p.flattenTrie(trieModel.ngramTrie.root)
@@ -257,6 +427,8 @@ func (p *NGramPersistence) deserializeTrieModel(model *SerializableNGramModel, c
 	cm.globalModel.vocabulary.tokenToID = model.TokenToID
 	cm.globalModel.vocabulary.idToToken = model.IDToToken
 	cm.globalModel.vocabulary.nextID = uint32(len(model.IDToToken))
+	restoreInterning(cm.globalModel.ngramTrie, model.NGramIDToToken)
+	restoreInterning(cm.globalModel.contextTrie, model.ContextIDToToken)
 
 	// Restore tries
 	cm.globalModel.ngramTrie.root = p.reconstructTrie(model.TrieNodes)
@@ -268,13 +440,39 @@ func (p *NGramPersistence) deserializeTrieModel(model *SerializableNGramModel, c
 	cm.globalModel.ngramTrie.totalTokens = model.NGramTrieTotalTokens
 	cm.globalModel.contextTrie.totalNGrams = model.ContextTrieTotalNGrams
 	cm.globalModel.contextTrie.totalTokens = model.ContextTrieTotalTokens
+	restoreBloom(cm.globalModel.ngramTrie, model.NGramBloom, model.NGramReleased)
+	restoreBloom(cm.globalModel.contextTrie, model.ContextBloom, model.ContextReleased)
 
 	// Update total tokens
 	cm.globalModel.totalTokens = model.TotalTokens
+	cm.globalModel.countScale = max(model.CountScale, 1)
 
 	return nil
 }
 
+// restoreInterning sets a trie's token IDs from its saved reverse lookup
+func restoreInterning(trie *NGramTrie, idToToken []string) {
+	trie.tokenToID = make(map[string]uint32, len(idToToken))
+	for id, token := range idToToken {
+		if id > 0 { // ID 0 is the root sentinel
+			trie.tokenToID[token] = uint32(id)
+		}
+	}
+	trie.idToToken = idToToken
+	trie.nextID = uint32(len(idToToken))
+}
+
+// restoreBloom sets a trie's saved bloom filter and released sightings
+func restoreBloom(trie *NGramTrie, filter *bloom.BloomFilter, released map[string]int) {
+	if !trie.useBloom || filter == nil {
+		return
+	}
+	trie.bloomFilter = filter
+	if released != nil {
+		trie.released = released
+	}
+}
+
 // reconstructTrie rebuilds a trie from serialized nodes
 func (p *NGramPersistence) reconstructTrie(nodes []SerializableTrieNode) *TrieNode {
 	if len(nodes) == 0 {
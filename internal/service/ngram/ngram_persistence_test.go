@@ -0,0 +1,291 @@
+package ngram
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot-go/internal/service/tokenizer"
+
+	"go.uber.org/zap"
+)
+
+// kneserNeySmoother stands in for a smoother the persistence layer does not know
+type kneserNeySmoother struct{ AddKSmoother }
+
+func (s *kneserNeySmoother) Name() string { return "KneserNey" }
+
+func TestPersistenceRestoresSmoother(t *testing.T) {
+	registry := tokenizer.NewTokenizerRegistry()
+	goTokenizer, err := tokenizer.NewGoTokenizer()
+	if err != nil {
+		t.Fatalf("NewGoTokenizer: %v", err)
+	}
+	registry.Register("go", goTokenizer, []string{".go"})
+
+	p, err := NewNGramPersistence(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramPersistence: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		smoother Smoother
+		wantK    float64
+	}{
+		{"addk", NewAddKSmoother(0.5), 0.5},
+		{"wittenbell", NewWittenBellSmoother(), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := NewCorpusManager(3, tt.smoother, registry, zap.NewNop())
+			source := []byte("package a\n\nfunc Sum(a, b int) int {\n\treturn a + b\n}\n")
+			if err := cm.AddFile(context.Background(), "sum.go", source, "go"); err != nil {
+				t.Fatalf("AddFile: %v", err)
+			}
+			if err := p.SaveCorpusManager(cm, tt.name); err != nil {
+				t.Fatalf("SaveCorpusManager: %v", err)
+			}
+
+			loaded, err := p.LoadCorpusManager(tt.name, registry, zap.NewNop())
+			if err != nil {
+				t.Fatalf("LoadCorpusManager: %v", err)
+			}
+			if got := loaded.smoother.Name(); got != tt.smoother.Name() {
+				t.Errorf("smoother = %s, want %s", got, tt.smoother.Name())
+			}
+			if addK, ok := loaded.smoother.(*AddKSmoother); ok && addK.k != tt.wantK {
+				t.Errorf("k = %v, want %v", addK.k, tt.wantK)
+			}
+		})
+	}
+
+	// A smoother without a factory can neither be saved nor loaded
+	unknown := NewCorpusManager(3, &kneserNeySmoother{AddKSmoother{k: 1}}, registry, zap.NewNop())
+	if err := p.SaveCorpusManager(unknown, "unknown"); err == nil || !strings.Contains(err.Error(), "KneserNey") {
+		t.Errorf("SaveCorpusManager with unknown smoother: err = %v, want unknown smoother error", err)
+	}
+
+	model := &SerializableNGramModel{Version: modelFormatVersion, N: 3, SmootherName: "KneserNey"}
+	if err := p.saveToFile(model, p.GetModelPath("unknown")); err != nil {
+		t.Fatalf("saveToFile: %v", err)
+	}
+	if _, err := p.LoadCorpusManager("unknown", registry, zap.NewNop()); err == nil || !strings.Contains(err.Error(), "KneserNey") {
+		t.Errorf("LoadCorpusManager with unknown smoother: err = %v, want unknown smoother error", err)
+	}
+}
+
+func TestPersistenceKeepsProbabilities(t *testing.T) {
+	registry := tokenizer.NewTokenizerRegistry()
+	goTokenizer, err := tokenizer.NewGoTokenizer()
+	if err != nil {
+		t.Fatalf("NewGoTokenizer: %v", err)
+	}
+	registry.Register("go", goTokenizer, []string{".go"})
+
+	p, err := NewNGramPersistence(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramPersistence: %v", err)
+	}
+
+	ctx := context.Background()
+	cm := NewCorpusManager(3, NewAddKSmoother(1.0), registry, zap.NewNop())
+	sources := map[string]string{
+		"sum.go":  "package a\n\nfunc Sum(a, b int) int {\n\treturn a + b\n}\n",
+		"loop.go": "package a\n\nfunc Loop(xs []int) {\n\tfor _, x := range xs {\n\t\t_ = x\n\t}\n}\n",
+	}
+	for path, source := range sources {
+		if err := cm.AddFile(ctx, path, []byte(source), "go"); err != nil {
+			t.Fatalf("AddFile(%s): %v", path, err)
+		}
+	}
+	if err := p.SaveCorpusManager(cm, "corpus"); err != nil {
+		t.Fatalf("SaveCorpusManager: %v", err)
+	}
+	loaded, err := p.LoadCorpusManager("corpus", registry, zap.NewNop())
+	if err != nil {
+		t.Fatalf("LoadCorpusManager: %v", err)
+	}
+
+	// The tries intern tokens in different orders, so this only holds if each
+	// trie's token IDs are restored
+	tokens, err := cm.normalizedTokens(ctx, []byte(sources["loop.go"]), "go")
+	if err != nil {
+		t.Fatalf("normalizedTokens: %v", err)
+	}
+	for i := 2; i < len(tokens); i++ {
+		context := tokens[i-2 : i]
+		want := cm.GetGlobalModel().Probability(tokens[i], context)
+		if got := loaded.GetGlobalModel().Probability(tokens[i], context); got != want {
+			t.Errorf("P(%s|%v) = %v after loading, want %v", tokens[i], context, got, want)
+		}
+	}
+}
+
+func TestPersistenceUpdateAfterLoadReplacesCounts(t *testing.T) {
+	registry := tokenizer.NewTokenizerRegistry()
+	goTokenizer, err := tokenizer.NewGoTokenizer()
+	if err != nil {
+		t.Fatalf("NewGoTokenizer: %v", err)
+	}
+	registry.Register("go", goTokenizer, []string{".go"})
+
+	p, err := NewNGramPersistence(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramPersistence: %v", err)
+	}
+
+	ctx := context.Background()
+	other := []byte("package a\n\nfunc Max(a, b int) int {\n\tif a > b {\n\t\treturn a\n\t}\n\treturn b\n}\n")
+	before := []byte("package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n")
+	after := []byte("package a\n\nfunc Sum(xs []int) (total int) {\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn\n}\n")
+
+	cm := NewCorpusManager(3, nil, registry, zap.NewNop())
+	if err := cm.AddFile(ctx, "max.go", other, "go"); err != nil {
+		t.Fatalf("AddFile(max.go): %v", err)
+	}
+	if err := cm.AddWeightedFile(ctx, "sum.go", before, "go", 0.5); err != nil {
+		t.Fatalf("AddWeightedFile(sum.go): %v", err)
+	}
+	if err := cm.AddWeightedFile(ctx, "gen.go", before, "go", 0); err != nil {
+		t.Fatalf("AddWeightedFile(gen.go): %v", err)
+	}
+	if err := p.SaveCorpusManager(cm, "corpus"); err != nil {
+		t.Fatalf("SaveCorpusManager: %v", err)
+	}
+	loaded, err := p.LoadCorpusManager("corpus", registry, zap.NewNop())
+	if err != nil {
+		t.Fatalf("LoadCorpusManager: %v", err)
+	}
+
+	// The update takes out the counts the saved file added, at its saved weight
+	if err := loaded.UpdateWeightedFile(ctx, "sum.go", after, "go", 1); err != nil {
+		t.Fatalf("UpdateWeightedFile(sum.go): %v", err)
+	}
+	if err := loaded.RemoveFile(ctx, "gen.go"); err != nil {
+		t.Fatalf("RemoveFile(gen.go): %v", err)
+	}
+	fresh := NewCorpusManager(3, nil, registry, zap.NewNop())
+	for path, source := range map[string][]byte{"max.go": other, "sum.go": after} {
+		if err := fresh.AddFile(ctx, path, source, "go"); err != nil {
+			t.Fatalf("AddFile(%s): %v", path, err)
+		}
+	}
+	if got, want := trieCounts(loaded.GetGlobalModel().ngramTrie), trieCounts(fresh.GetGlobalModel().ngramTrie); !reflect.DeepEqual(got, want) {
+		t.Errorf("n-gram counts after updating the loaded model = %v, want %v", got, want)
+	}
+	if got, want := loaded.GetGlobalModel().totalTokens, fresh.GetGlobalModel().totalTokens; got != want {
+		t.Errorf("total token counts = %d, want %d", got, want)
+	}
+
+	// A model without the files' tokens cannot be updated, so it is not loaded
+	model, err := p.loadFromFile(p.GetModelPath("corpus"))
+	if err != nil {
+		t.Fatalf("loadFromFile: %v", err)
+	}
+	for path, metadata := range model.FileMetadata {
+		metadata.TokenIDs = nil
+		model.FileMetadata[path] = metadata
+	}
+	if err := p.saveToFile(model, p.GetModelPath("tokenless")); err != nil {
+		t.Fatalf("saveToFile: %v", err)
+	}
+	if _, err := p.LoadCorpusManager("tokenless", registry, zap.NewNop()); err == nil || !strings.Contains(err.Error(), "lacks the tokens") {
+		t.Errorf("LoadCorpusManager without file tokens: err = %v, want missing tokens error", err)
+	}
+}
+
+func TestPersistenceMergeModels(t *testing.T) {
+	registry := tokenizer.NewTokenizerRegistry()
+	goTokenizer, err := tokenizer.NewGoTokenizer()
+	if err != nil {
+		t.Fatalf("NewGoTokenizer: %v", err)
+	}
+	registry.Register("go", goTokenizer, []string{".go"})
+
+	p, err := NewNGramPersistence(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramPersistence: %v", err)
+	}
+
+	ctx := context.Background()
+	// Both repositories have a main.go; their bodies repeat so the n-grams
+	// get past the bloom filter's singletons
+	sources := map[string]string{
+		"a": "package a\n\nfunc Sum(a, b int) int {\n\treturn a + b\n}\n\nfunc Add(a, b int) int {\n\treturn a + b\n}\n",
+		"b": "package b\n\nfunc Loop(xs []string) {\n\tfor _, x := range xs {\n\t\tprintln(x)\n\t}\n\tfor _, x := range xs {\n\t\tprintln(x)\n\t}\n}\n",
+	}
+	models := make(map[string]*NGramModelTrie)
+	for repoName, source := range sources {
+		cm := NewCorpusManager(3, NewAddKSmoother(1.0), registry, zap.NewNop())
+		if err := cm.AddFile(ctx, "main.go", []byte(source), "go"); err != nil {
+			t.Fatalf("AddFile(%s): %v", repoName, err)
+		}
+		if err := p.SaveCorpusManager(cm, repoName); err != nil {
+			t.Fatalf("SaveCorpusManager(%s): %v", repoName, err)
+		}
+		models[repoName] = cm.GetGlobalModel()
+	}
+
+	if _, err := p.MergeModels("a", "b", "merged", registry, zap.NewNop()); err != nil {
+		t.Fatalf("MergeModels: %v", err)
+	}
+	merged, err := p.LoadCorpusManager("merged", registry, zap.NewNop())
+	if err != nil {
+		t.Fatalf("LoadCorpusManager: %v", err)
+	}
+	got := merged.GetGlobalModel()
+	a, b := models["a"], models["b"]
+
+	wantVocab := make(map[string]bool)
+	for _, model := range []*NGramModelTrie{a, b} {
+		for _, token := range model.vocabulary.GetVocabulary() {
+			wantVocab[token] = true
+		}
+	}
+	if size := got.vocabulary.VocabularySize(); size != len(wantVocab) {
+		t.Errorf("vocabulary size = %d, want %d", size, len(wantVocab))
+	}
+	for token := range wantVocab {
+		want := a.vocabulary.GetCount([]string{token}) + b.vocabulary.GetCount([]string{token})
+		if count := got.vocabulary.GetCount([]string{token}); count != want {
+			t.Errorf("count of %q = %d, want %d", token, count, want)
+		}
+	}
+	if total, want := got.ngramTrie.TotalNGrams(), a.ngramTrie.TotalNGrams()+b.ngramTrie.TotalNGrams(); total != want || want == 0 {
+		t.Errorf("total n-grams = %d, want %d", total, want)
+	}
+	for _, model := range []*NGramModelTrie{a, b} {
+		for _, ng := range model.ngramTrie.GetAllWithPrefix(nil) {
+			want := a.ngramTrie.GetCount(ng.Tokens) + b.ngramTrie.GetCount(ng.Tokens)
+			if count := got.ngramTrie.GetCount(ng.Tokens); count != want {
+				t.Errorf("count of %v = %d, want %d", ng.Tokens, count, want)
+			}
+		}
+	}
+	if got.totalTokens != a.totalTokens+b.totalTokens {
+		t.Errorf("total tokens = %d, want %d", got.totalTokens, a.totalTokens+b.totalTokens)
+	}
+	for _, path := range []string{"a/main.go", "b/main.go"} {
+		if _, exists := merged.fileModels[path]; !exists {
+			t.Errorf("merged model is missing file %s", path)
+		}
+	}
+
+	// Models of different orders cannot be merged
+	bigrams := NewCorpusManager(2, NewAddKSmoother(1.0), registry, zap.NewNop())
+	if err := bigrams.AddFile(ctx, "main.go", []byte(sources["a"]), "go"); err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	if err := p.SaveCorpusManager(bigrams, "bigrams"); err != nil {
+		t.Fatalf("SaveCorpusManager: %v", err)
+	}
+	if _, err := p.MergeModels("a", "bigrams", "mismatched", registry, zap.NewNop()); err == nil || !strings.Contains(err.Error(), "2-gram") {
+		t.Errorf("MergeModels with different orders: err = %v, want order mismatch error", err)
+	}
+	if p.ModelExists("mismatched") {
+		t.Error("a model was saved for a failed merge")
+	}
+}
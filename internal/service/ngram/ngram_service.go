@@ -2,26 +2,91 @@ package ngram
 
 import (
 	"bot-go/internal/config"
+	ngrammodel "bot-go/internal/model/ngram"
 	"bot-go/internal/service/tokenizer"
 	"bot-go/internal/util"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io"
+	"io/fs"
+	"math"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 
 	"go.uber.org/zap"
 )
 
+// docExtensions are the documentation files modeled as "text" for repositories
+// with IncludeDocs set
+var docExtensions = []string{".md", ".markdown", ".txt"}
+
+// DefaultGenericExtensions are the files modeled with the generic tokenizer
+// when it is enabled without an explicit extension list
+var DefaultGenericExtensions = []string{".rs", ".rb", ".cs", ".c", ".h", ".cpp", ".cc", ".hpp", ".kt", ".swift", ".scala", ".php"}
+
+// defaultCheckpointInterval is how many files ProcessRepository adds between
+// checkpoints of the model being built
+const defaultCheckpointInterval = 500
+
+// progressInterval is how many files ProcessRepository adds between progress
+// reports
+const progressInterval = 100
+
+// ProcessProgress reports how far a repository walk has got
+type ProcessProgress struct {
+	FilesProcessed int    // Files added to the model so far
+	TotalFiles     int    // Files the walk may add, counted before it starts
+	CurrentFile    string // File most recently added, empty before the first
+}
+
+// Percent returns the share of the counted files processed, from 0 to 100
+func (p ProcessProgress) Percent() float64 {
+	if p.TotalFiles <= 0 {
+		return 0
+	}
+	return min(100, 100*float64(p.FilesProcessed)/float64(p.TotalFiles))
+}
+
+// ProgressFunc receives progress reports from ProcessRepositoryWithProgress.
+// It is called from the walk's worker goroutines and should return quickly.
+type ProgressFunc func(ProcessProgress)
+
+// ErrModelNotLoaded is returned when a repository has no n-gram model in memory
+var ErrModelNotLoaded = errors.New("n-gram model not loaded for repository")
+
+// ErrInvalidN is returned for an n-gram order outside the service's range
+var ErrInvalidN = errors.New("invalid n-gram order")
+
+// Default range of n-gram orders ProcessRepository accepts. Beyond 7 almost
+// every n-gram is a singleton, so the model is degenerate and memory grows
+// with n for no gain in predictive power.
+const (
+	DefaultMinN = 1
+	DefaultMaxN = 7
+)
+
 // NGramService orchestrates n-gram model building for repositories
 type NGramService struct {
-	corpusManagers map[string]*CorpusManager // repo name -> corpus manager
-	registry       *tokenizer.TokenizerRegistry
-	persistence    *NGramPersistence // Model persistence
-	logger         *zap.Logger
-	mu             sync.RWMutex
+	corpusManagers  map[string]*CorpusManager // repo or corpus group name -> corpus manager
+	groups          map[string]string         // repo name -> corpus group it was processed into
+	registry        *tokenizer.TokenizerRegistry
+	persistence     *NGramPersistence       // Model persistence
+	checkpointEvery int                     // Files added between checkpoints of a build (0 = none)
+	weightFunc      FileWeightFunc          // Optional; weighs files added by ProcessRepository
+	fileWeights     []config.FileWeightRule // Used when weightFunc is nil
+	maxFileSize     int64                   // Files larger than this many bytes are skipped (0 = no limit)
+	maxVocab        int                     // Distinct tokens in each global model before new ones are OOV (0 = unlimited)
+	zScoreScale     ZScoreScale             // Interprets the z-scores of CalculateZScore
+	genericExts     map[string]bool         // Extensions modeled as "generic" when no specific tokenizer handles them
+	minN, maxN      int                     // Range of n-gram orders ProcessRepository accepts
+	logger          *zap.Logger
+	mu              sync.RWMutex
 }
 
 // NewNGramService creates a new n-gram service with default output directory
@@ -64,60 +129,283 @@ func NewNGramServiceWithOutputDir(outputDir string, logger *zap.Logger) (*NGramS
 	}
 	registry.Register("java", javaTokenizer, []string{".java"})
 
+	// Documentation is only walked for repositories with IncludeDocs set
+	registry.Register("text", tokenizer.NewTextTokenizer(), docExtensions)
+
 	// Initialize persistence
 	persistence, err := NewNGramPersistence(outputDir, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create persistence: %w", err)
 	}
+	groups, err := persistence.LoadGroups()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load corpus groups: %w", err)
+	}
 
 	return &NGramService{
-		corpusManagers: make(map[string]*CorpusManager),
-		registry:       registry,
-		persistence:    persistence,
-		logger:         logger,
+		corpusManagers:  make(map[string]*CorpusManager),
+		groups:          groups,
+		registry:        registry,
+		persistence:     persistence,
+		checkpointEvery: defaultCheckpointInterval,
+		zScoreScale:     DefaultZScoreScale(),
+		minN:            DefaultMinN,
+		maxN:            DefaultMaxN,
+		logger:          logger,
 	}, nil
 }
 
-// ProcessRepository processes all files in a repository and builds n-gram models
-func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repository, n int, override bool) error {
+// SetCheckpointInterval sets how many files ProcessRepository adds between
+// checkpoints of the model being built; 0 disables periodic checkpoints
+func (ns *NGramService) SetCheckpointInterval(files int) {
+	ns.checkpointEvery = max(files, 0)
+}
+
+// SetPythonIndentTokens selects whether Python is tokenized with INDENT and
+// DEDENT tokens around indented blocks. Other languages are unaffected; saved
+// Python models must be rebuilt after changing it.
+func (ns *NGramService) SetPythonIndentTokens(enabled bool) {
+	if tok, ok := ns.registry.GetTokenizer("python"); ok {
+		if pythonTokenizer, ok := tok.(*tokenizer.PythonTokenizer); ok {
+			pythonTokenizer.SetIndentTokens(enabled)
+		}
+	}
+}
+
+// SetGenericExtensions models files with the given extensions as "generic",
+// lexed without a grammar, when no specific tokenizer handles their language.
+// An empty list disables the generic tokenizer.
+func (ns *NGramService) SetGenericExtensions(extensions []string) {
+	if len(extensions) == 0 {
+		ns.genericExts = nil
+		return
+	}
+
+	ns.genericExts = make(map[string]bool, len(extensions))
+	lowered := make([]string, 0, len(extensions))
+	for _, ext := range extensions {
+		ext = strings.ToLower(ext)
+		ns.genericExts[ext] = true
+		lowered = append(lowered, ext)
+	}
+	ns.registry.Register("generic", tokenizer.NewGenericTokenizer(), lowered)
+}
+
+// SetMaxFileSize skips files larger than maxBytes when tokenizing; 0 disables the limit
+func (ns *NGramService) SetMaxFileSize(maxBytes int64) {
+	ns.maxFileSize = maxBytes
+}
+
+// SetMaxVocabulary caps the number of distinct tokens in the models
+// ProcessRepository builds or loads afterwards; tokens first seen beyond the
+// cap are modeled as a single OOV token. 0 removes the cap.
+func (ns *NGramService) SetMaxVocabulary(maxVocab int) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.maxVocab = max(maxVocab, 0)
+}
+
+// SetNRange sets the range of n-gram orders ProcessRepository accepts; 0
+// keeps the default bound (DefaultMinN or DefaultMaxN)
+func (ns *NGramService) SetNRange(minN, maxN int) error {
+	if minN == 0 {
+		minN = DefaultMinN
+	}
+	if maxN == 0 {
+		maxN = DefaultMaxN
+	}
+	if minN < 1 || maxN < minN {
+		return fmt.Errorf("%w range %d-%d: need 1 <= min <= max", ErrInvalidN, minN, maxN)
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.minN, ns.maxN = minN, maxN
+	return nil
+}
+
+// ValidateN returns an error wrapping ErrInvalidN if ProcessRepository would
+// reject n-grams of order n
+func (ns *NGramService) ValidateN(n int) error {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+	if n < ns.minN || n > ns.maxN {
+		return fmt.Errorf("%w %d: must be between %d and %d", ErrInvalidN, n, ns.minN, ns.maxN)
+	}
+	return nil
+}
+
+// SetZScoreScale sets the scale CalculateZScore interprets z-scores on
+func (ns *NGramService) SetZScoreScale(scale ZScoreScale) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.zScoreScale = scale
+}
+
+// SetFileWeightFunc sets the function weighing each file ProcessRepository
+// adds to a model, e.g. by how recently it changed; nil weighs every file 1.0
+func (ns *NGramService) SetFileWeightFunc(weightFunc FileWeightFunc) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.weightFunc = weightFunc
+}
+
+// SetFileWeights sets the rules weighing the files ProcessRepository adds,
+// matched relative to each repository's root. A function set with
+// SetFileWeightFunc takes precedence.
+func (ns *NGramService) SetFileWeights(rules []config.FileWeightRule) {
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	ns.fileWeights = rules
+}
+
+// NewFileWeightFunc weighs files under root by the first rule whose glob
+// matches their root-relative slash path or their name; other files weigh 1.0
+func NewFileWeightFunc(root string, rules []config.FileWeightRule) FileWeightFunc {
+	return func(filePath string) float64 {
+		relPath := filePath
+		if rel, err := filepath.Rel(root, filePath); err == nil {
+			relPath = rel
+		}
+		relPath = filepath.ToSlash(relPath)
+		name := path.Base(relPath)
+		for _, rule := range rules {
+			if matched, _ := path.Match(rule.Glob, relPath); matched {
+				return rule.Weight
+			}
+			if matched, _ := path.Match(rule.Glob, name); matched {
+				return rule.Weight
+			}
+		}
+		return 1.0
+	}
+}
+
+// ProcessRepository processes all files in a repository and builds n-gram models.
+// Files with fewer than minTokens tokens are kept out of the model (0 = no minimum).
+// n must be within the service's range (see SetNRange), else ErrInvalidN is returned.
+//
+// With a non-empty group the repository's files are appended to the group's
+// shared corpus instead, so several related repositories form one naturalness
+// baseline. The group's model is taken from memory, loaded from disk unless
+// override is set, or created. Files already in it are skipped while their
+// modification time is unchanged, and replaced otherwise or when override is
+// set. A group's model must have been built with the same n and minTokens.
+// Which group each repository was processed into is saved with the models.
+//
+// The model is checkpointed to disk every few files and when ctx is cancelled,
+// which stops the walk promptly. Unless override is set, a later run with the
+// same n and minTokens resumes from the checkpoint, skipping files whose
+// modification time is unchanged and replacing the counts of changed ones.
+// Saved models built with other options are rebuilt.
+func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repository, n int, minTokens int, override bool, group string) error {
+	return ns.ProcessRepositoryWithProgress(ctx, repo, n, minTokens, override, group, nil)
+}
+
+// ProcessRepositoryWithProgress is ProcessRepository reporting its progress to
+// progress, if not nil: once before the walk starts and then every
+// progressInterval files. Nothing is reported when a saved model is loaded
+// instead of walking the repository.
+func (ns *NGramService) ProcessRepositoryWithProgress(ctx context.Context, repo *config.Repository, n int, minTokens int, override bool, group string, progress ProgressFunc) error {
+	if err := ns.ValidateN(n); err != nil {
+		return err
+	}
+
 	ns.logger.Info("Processing repository for n-gram model",
 		zap.String("repo", repo.Name),
 		zap.String("path", repo.Path),
+		zap.String("group", group),
 		zap.Int("n", n),
+		zap.Int("min_tokens", minTokens),
 		zap.Bool("override", override),
 	)
 
+	modelName := repo.Name
+	if group != "" {
+		modelName = group
+	}
+
+	var corpusManager *CorpusManager
+	ns.mu.Lock()
+	if group != "" {
+		corpusManager = ns.corpusManagers[group]
+	}
+	if err := ns.setGroup(repo.Name, group); err != nil {
+		ns.mu.Unlock()
+		return err
+	}
+	ns.mu.Unlock()
+	if corpusManager != nil && !corpusManager.builtWith(n, minTokens) {
+		return fmt.Errorf("corpus group %s models %d-grams with min tokens %d, cannot add %s with n %d and min tokens %d",
+			group, corpusManager.n, corpusManager.minTokens, repo.Name, n, minTokens)
+	}
+
 	// Check if we should load from disk
-	if !override && ns.persistence.ModelExists(repo.Name) {
+	if corpusManager == nil && !override && ns.persistence.ModelExists(modelName) {
 		ns.logger.Info("Loading existing n-gram model from disk",
-			zap.String("repo", repo.Name))
+			zap.String("repo", repo.Name),
+			zap.String("model", modelName))
 
-		corpusManager, err := ns.persistence.LoadCorpusManager(repo.Name, ns.registry, ns.logger)
+		loaded, err := ns.persistence.LoadCorpusManager(modelName, ns.registry, ns.logger)
+		if err == nil && !loaded.builtWith(n, minTokens) {
+			if group != "" && !loaded.IsCheckpoint() {
+				return fmt.Errorf("saved model of corpus group %s models %d-grams with min tokens %d, cannot add %s with n %d and min tokens %d",
+					group, loaded.n, loaded.minTokens, repo.Name, n, minTokens)
+			}
+			// Built for other options, so it cannot be resumed or reused
+			err = fmt.Errorf("saved model has n %d and min tokens %d, want n %d and min tokens %d",
+				loaded.n, loaded.minTokens, n, minTokens)
+		}
 		if err == nil {
 			ns.mu.Lock()
-			ns.corpusManagers[repo.Name] = corpusManager
+			loaded.SetMaxVocabulary(ns.maxVocab)
+			ns.corpusManagers[modelName] = loaded
 			ns.mu.Unlock()
 
 			ns.logger.Info("Successfully loaded n-gram model from disk",
-				zap.String("repo", repo.Name))
-			return nil
+				zap.String("repo", repo.Name),
+				zap.String("model", modelName),
+				zap.Bool("checkpoint", loaded.IsCheckpoint()))
+			if group == "" && !loaded.IsCheckpoint() {
+				return nil
+			}
+			corpusManager = loaded
+		} else {
+			ns.logger.Warn("Failed to load existing model, will rebuild",
+				zap.String("repo", repo.Name),
+				zap.String("model", modelName),
+				zap.Error(err))
 		}
-
-		ns.logger.Warn("Failed to load existing model, will rebuild",
-			zap.String("repo", repo.Name),
-			zap.Error(err))
 	}
 
 	// Create new corpus manager (always Trie+Bloom)
-	ns.mu.Lock()
-	smoother := NewAddKSmoother(1.0)
-	corpusManager := NewCorpusManager(n, smoother, ns.registry, ns.logger)
-	ns.corpusManagers[repo.Name] = corpusManager
-	ns.mu.Unlock()
+	if corpusManager == nil {
+		ns.mu.Lock()
+		smoother := NewAddKSmoother(1.0)
+		corpusManager = NewCorpusManagerWithOptions(n, smoother, ns.registry, true, true, minTokens, ns.logger)
+		corpusManager.SetMaxVocabulary(ns.maxVocab)
+		ns.corpusManagers[modelName] = corpusManager
+		ns.mu.Unlock()
+	}
+
+	ns.mu.RLock()
+	weightFunc := ns.weightFunc
+	if weightFunc == nil && len(ns.fileWeights) > 0 {
+		weightFunc = NewFileWeightFunc(repo.Path, ns.fileWeights)
+	}
+	ns.mu.RUnlock()
+	corpusManager.SetFileWeightFunc(weightFunc)
+
+	totalFiles := 0
+	if progress != nil {
+		totalFiles = ns.countCandidateFiles(ctx, repo)
+		progress(ProcessProgress{TotalFiles: totalFiles})
+	}
 
 	// Walk the repository directory using concurrent walker
 	fileCount := 0
 	var mu sync.Mutex
+	var buildMu sync.RWMutex // Held exclusively while checkpointing, so no file is half added
 
 	err := util.WalkDirTree(repo.Path,
 		// Walk function - called for each file
@@ -126,17 +414,37 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 				return err
 			}
 
+			// Cancelled: leave the remaining files to a resumed run
+			if ctx.Err() != nil {
+				return nil
+			}
+
 			// Check if file should be processed
 			if !ns.shouldProcessFile(path, repo) {
 				return nil
 			}
 
 			// Detect language
-			language := ns.detectLanguage(path)
+			language := ns.detectLanguage(path, repo)
 			if language == "" {
 				return nil
 			}
 
+			info, err := os.Stat(path)
+			if err != nil {
+				ns.logger.Warn("Failed to stat file",
+					zap.String("path", path),
+					zap.Error(err),
+				)
+				return nil
+			}
+
+			// Unchanged since it was added to the group's corpus or to the
+			// checkpoint being resumed; override rebuilds every file
+			if !override && corpusManager.hasUnchangedFile(path, info.ModTime()) {
+				return nil
+			}
+
 			// Read file
 			source, err := ns.readFile(path)
 			if err != nil {
@@ -148,7 +456,12 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 			}
 
 			// Add file to corpus
+			buildMu.RLock()
 			err = corpusManager.AddFile(ctx, path, source, language)
+			if err == nil {
+				corpusManager.setModTime(path, info.ModTime())
+			}
+			buildMu.RUnlock()
 			if err != nil {
 				ns.logger.Warn("Failed to process file",
 					zap.String("path", path),
@@ -162,17 +475,29 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 			currentCount := fileCount
 			mu.Unlock()
 
-			if currentCount%100 == 0 {
+			if currentCount%progressInterval == 0 {
 				ns.logger.Info("Processing progress",
 					zap.String("repo", repo.Name),
 					zap.Int("files", currentCount),
 				)
+				if progress != nil {
+					progress(ProcessProgress{FilesProcessed: currentCount, TotalFiles: totalFiles, CurrentFile: path})
+				}
+			}
+
+			if ns.checkpointEvery > 0 && currentCount%ns.checkpointEvery == 0 {
+				buildMu.Lock()
+				ns.saveCheckpoint(corpusManager, modelName)
+				buildMu.Unlock()
 			}
 
 			return nil
 		},
 		// Skip function - called to determine if path should be skipped
 		func(path string, isDir bool) bool {
+			if ctx.Err() != nil {
+				return true
+			}
 			if isDir {
 				// Skip common ignored directories
 				dirName := filepath.Base(path)
@@ -185,22 +510,39 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 		2, // numThreads: use 2 workers
 	)
 
+	// Checked first: a cancelled walk may also report the skipped root
+	if err := ctx.Err(); err != nil {
+		ns.logger.Info("Repository processing cancelled",
+			zap.String("repo", repo.Name),
+			zap.String("model", modelName),
+			zap.Int("files_processed", fileCount),
+		)
+		ns.saveCheckpoint(corpusManager, modelName)
+		return fmt.Errorf("repository processing cancelled: %w", err)
+	}
+
 	if err != nil {
 		return fmt.Errorf("failed to walk repository: %w", err)
 	}
 
-	stats := corpusManager.GetStats(ctx)
+	// Files added early were scored against a partial model
+	stats, err := corpusManager.RecomputeEntropies(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to recompute file entropies: %w", err)
+	}
 	ns.logger.Info("Repository processing complete",
 		zap.String("repo", repo.Name),
+		zap.String("model", modelName),
 		zap.Int("files_processed", fileCount),
 		zap.Int("total_tokens", stats.TotalTokens),
 		zap.Float64("avg_entropy", stats.AverageEntropy),
 	)
 
 	// Save the model to disk
-	if err := ns.persistence.SaveCorpusManager(corpusManager, repo.Name); err != nil {
+	if err := ns.persistence.SaveCorpusManager(corpusManager, modelName); err != nil {
 		ns.logger.Error("Failed to save n-gram model",
 			zap.String("repo", repo.Name),
+			zap.String("model", modelName),
 			zap.Error(err))
 		return fmt.Errorf("failed to save model: %w", err)
 	}
@@ -208,22 +550,187 @@ func (ns *NGramService) ProcessRepository(ctx context.Context, repo *config.Repo
 	return nil
 }
 
-// GetCorpusManager returns the corpus manager for a repository
-func (ns *NGramService) GetCorpusManager(repoName string) (*CorpusManager, error) {
+// countCandidateFiles counts the files under a repository that
+// ProcessRepository would model, so progress can be reported as a percentage
+func (ns *NGramService) countCandidateFiles(ctx context.Context, repo *config.Repository) int {
+	count := 0
+	filepath.WalkDir(repo.Path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable entries are skipped by the walk as well
+		}
+		if ctx.Err() != nil {
+			return filepath.SkipAll
+		}
+		if entry.IsDir() {
+			if path != repo.Path && ns.shouldSkipDirectory(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if ns.shouldProcessFile(path, repo) {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+// saveCheckpoint saves a model being built so an interrupted build can be
+// resumed; failures are logged, since the build itself can still complete
+func (ns *NGramService) saveCheckpoint(corpusManager *CorpusManager, modelName string) {
+	if err := ns.persistence.SaveCheckpoint(corpusManager, modelName); err != nil {
+		ns.logger.Warn("Failed to checkpoint n-gram model",
+			zap.String("model", modelName),
+			zap.Error(err))
+	}
+}
+
+// LoadModel loads the model saved for a repository or corpus group into memory
+// without walking the repository, replacing any model already loaded under
+// that name. A repository processed into a group loads the group's model.
+func (ns *NGramService) LoadModel(repoName string) error {
+	ns.mu.RLock()
+	modelName := ns.modelName(repoName)
+	ns.mu.RUnlock()
+
+	loaded, err := ns.persistence.LoadCorpusManager(modelName, ns.registry, ns.logger)
+	if err != nil {
+		return err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	loaded.SetMaxVocabulary(ns.maxVocab)
+	ns.corpusManagers[modelName] = loaded
+	return nil
+}
+
+// MergeModels combines the saved models of two repositories into a model
+// saved and loaded under outputName, without re-tokenizing either repository.
+// See NGramPersistence.MergeModels.
+func (ns *NGramService) MergeModels(repoA, repoB, outputName string) (*CorpusManager, error) {
+	merged, err := ns.persistence.MergeModels(repoA, repoB, outputName, ns.registry, ns.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.Lock()
+	defer ns.mu.Unlock()
+	merged.SetMaxVocabulary(ns.maxVocab)
+	ns.corpusManagers[outputName] = merged
+	return merged, nil
+}
+
+// GetCorpusManager returns the shared corpus manager of group if it is set.
+// Otherwise it returns the repository's own corpus manager, or that of the
+// group the repository was last processed into. The error wraps
+// ErrModelNotLoaded when no model is in memory.
+func (ns *NGramService) GetCorpusManager(repoName, group string) (*CorpusManager, error) {
 	ns.mu.RLock()
 	defer ns.mu.RUnlock()
 
-	cm, exists := ns.corpusManagers[repoName]
+	if group != "" {
+		cm, exists := ns.corpusManagers[group]
+		if !exists {
+			return nil, fmt.Errorf("%w: group %s", ErrModelNotLoaded, group)
+		}
+		return cm, nil
+	}
+
+	cm, exists := ns.corpusManagers[ns.modelName(repoName)]
 	if !exists {
-		return nil, fmt.Errorf("no corpus manager found for repository: %s", repoName)
+		return nil, fmt.Errorf("%w: %s", ErrModelNotLoaded, repoName)
 	}
 
 	return cm, nil
 }
 
+// setGroup records the corpus group a repository is processed into, "" for
+// none, and saves the mapping if it changed. Callers hold ns.mu.
+func (ns *NGramService) setGroup(repoName, group string) error {
+	if ns.groups[repoName] == group {
+		return nil
+	}
+	previous, had := ns.groups[repoName]
+	if group != "" {
+		ns.groups[repoName] = group
+	} else {
+		delete(ns.groups, repoName)
+	}
+	if err := ns.persistence.SaveGroups(ns.groups); err != nil {
+		if had {
+			ns.groups[repoName] = previous
+		} else {
+			delete(ns.groups, repoName)
+		}
+		return fmt.Errorf("failed to save corpus groups: %w", err)
+	}
+	return nil
+}
+
+// modelName returns the name a repository's model is kept and saved under:
+// its corpus group if it has one, otherwise its own name. Callers hold ns.mu.
+func (ns *NGramService) modelName(repoName string) string {
+	if group, ok := ns.groups[repoName]; ok {
+		return group
+	}
+	return repoName
+}
+
+// LoadedRepositories returns the names of repositories and corpus groups with an
+// n-gram model in memory
+func (ns *NGramService) LoadedRepositories() []string {
+	ns.mu.RLock()
+	defer ns.mu.RUnlock()
+
+	names := make([]string, 0, len(ns.corpusManagers))
+	for name := range ns.corpusManagers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RecomputeEntropies optionally prunes n-grams seen fewer than pruneMinCount
+// times from the repository's global model, then refreshes every file's cached
+// entropy against the resulting model and saves it
+func (ns *NGramService) RecomputeEntropies(ctx context.Context, repoName string, pruneMinCount int64) (*CorpusStats, error) {
+	cm, err := ns.GetCorpusManager(repoName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if pruneMinCount > 0 {
+		ngramsPruned, contextsPruned := cm.PruneGlobalModel(pruneMinCount)
+		ns.logger.Info("Pruned n-gram model",
+			zap.String("repo", repoName),
+			zap.Int64("min_count", pruneMinCount),
+			zap.Int64("ngrams_pruned", ngramsPruned),
+			zap.Int64("contexts_pruned", contextsPruned))
+	}
+
+	stats, err := cm.RecomputeEntropies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ns.mu.RLock()
+	modelName := ns.modelName(repoName)
+	ns.mu.RUnlock()
+
+	if err := ns.persistence.SaveCorpusManager(cm, modelName); err != nil {
+		ns.logger.Error("Failed to save n-gram model",
+			zap.String("repo", repoName),
+			zap.Error(err))
+		return nil, fmt.Errorf("failed to save model: %w", err)
+	}
+
+	return &stats, nil
+}
+
 // GetFileEntropy returns the entropy for a specific file
 func (ns *NGramService) GetFileEntropy(ctx context.Context, repoName, filePath string) (float64, error) {
-	cm, err := ns.GetCorpusManager(repoName)
+	cm, err := ns.GetCorpusManager(repoName, "")
 	if err != nil {
 		return 0, err
 	}
@@ -231,9 +738,19 @@ func (ns *NGramService) GetFileEntropy(ctx context.Context, repoName, filePath s
 	return cm.GetFileEntropy(ctx, filePath)
 }
 
+// TopSurprisingFiles returns the n highest-entropy files of a repository
+func (ns *NGramService) TopSurprisingFiles(ctx context.Context, repoName string, n int) ([]FileEntropy, error) {
+	cm, err := ns.GetCorpusManager(repoName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return cm.TopEntropyFiles(ctx, n), nil
+}
+
 // GetRepositoryStats returns statistics for a repository
 func (ns *NGramService) GetRepositoryStats(ctx context.Context, repoName string) (*CorpusStats, error) {
-	cm, err := ns.GetCorpusManager(repoName)
+	cm, err := ns.GetCorpusManager(repoName, "")
 	if err != nil {
 		return nil, err
 	}
@@ -244,46 +761,28 @@ func (ns *NGramService) GetRepositoryStats(ctx context.Context, repoName string)
 
 // AnalyzeCode analyzes a code snippet and returns its entropy/naturalness
 func (ns *NGramService) AnalyzeCode(ctx context.Context, repoName, language string, code []byte) (*CodeAnalysis, error) {
-	cm, err := ns.GetCorpusManager(repoName)
+	cm, err := ns.GetCorpusManager(repoName, "")
 	if err != nil {
 		return nil, err
 	}
 
-	// Get tokenizer for language
-	tokenizer, ok := ns.registry.GetTokenizer(language)
-	if !ok {
-		return nil, fmt.Errorf("no tokenizer found for language: %s", language)
-	}
-
-	// Tokenize code
-	tokens, err := tokenizer.Tokenize(ctx, code)
+	// Calculate entropy and perplexity using global model
+	tokenCount, entropy, err := cm.scoreSource(ctx, code, language)
 	if err != nil {
-		return nil, fmt.Errorf("tokenization failed: %w", err)
-	}
-
-	// Normalize tokens
-	normalizedTokens := make([]string, 0, len(tokens))
-	for _, token := range tokens {
-		normalized := tokenizer.Normalize(token)
-		normalizedTokens = append(normalizedTokens, normalized)
+		return nil, err
 	}
 
-	// Calculate entropy and perplexity using global model
-	globalModel := cm.GetGlobalModel()
-	entropy := globalModel.CrossEntropy(normalizedTokens)
-	perplexity := globalModel.Perplexity(normalizedTokens)
-
 	return &CodeAnalysis{
-		TokenCount: len(normalizedTokens),
+		TokenCount: tokenCount,
 		Entropy:    entropy,
-		Perplexity: perplexity,
+		Perplexity: math.Pow(2, entropy),
 		Language:   language,
 	}, nil
 }
 
 // CalculateZScore analyzes code and calculates z-score with detailed n-gram information
 func (ns *NGramService) CalculateZScore(ctx context.Context, repoName, language string, code []byte) (*ZScoreAnalysis, error) {
-	cm, err := ns.GetCorpusManager(repoName)
+	cm, err := ns.GetCorpusManager(repoName, "")
 	if err != nil {
 		return nil, err
 	}
@@ -310,14 +809,14 @@ func (ns *NGramService) CalculateZScore(ctx context.Context, repoName, language
 	// Calculate entropy and scores (always Trie+Bloom)
 	entropy, ngramScores := ns.calculateEntropyWithScores(normalizedTokens, cm.globalModel, cm.n)
 
-	// Calculate z-score
-	zScore := cm.CalculateZScore(ctx, entropy)
-
-	// Get entropy statistics
-	entropyStats := cm.GetEntropyStats(ctx)
+	// Calculate z-score against files of the same language, since languages
+	// have different entropy distributions
+	zScore, entropyStats := cm.CalculateLanguageZScore(ctx, entropy, language)
 
 	// Interpret z-score
-	interpretation := interpretZScore(zScore)
+	ns.mu.RLock()
+	interpretation := ns.zScoreScale.Interpret(zScore)
+	ns.mu.RUnlock()
 
 	return &ZScoreAnalysis{
 		TokenCount:     len(normalizedTokens),
@@ -329,6 +828,143 @@ func (ns *NGramService) CalculateZScore(ctx context.Context, repoName, language
 	}, nil
 }
 
+// LineSurprises scores code against a repository's global model line by line.
+// As in CalculateZScore, each n-gram is scored on its last token, which
+// assigns it to that token's line. Lines without scored tokens are omitted.
+func (ns *NGramService) LineSurprises(ctx context.Context, repoName, language string, code []byte) ([]LineSurprise, error) {
+	cm, err := ns.GetCorpusManager(repoName, "")
+	if err != nil {
+		return nil, err
+	}
+
+	tokenizer, ok := ns.registry.GetTokenizer(language)
+	if !ok {
+		return nil, fmt.Errorf("no tokenizer found for language: %s", language)
+	}
+
+	var tokens []string
+	var lines []int
+	err = tokenizer.TokenizeStream(ctx, bytes.NewReader(code), func(token ngrammodel.Token) error {
+		tokens = append(tokens, tokenizer.Normalize(token))
+		lines = append(lines, token.Line)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("tokenization failed: %w", err)
+	}
+
+	var surprises []LineSurprise
+	for i := cm.n - 1; i < len(tokens); i++ {
+		logProb := surprisal(cm.globalModel.Probability(tokens[i], tokens[i-cm.n+1:i]))
+		if len(surprises) == 0 || surprises[len(surprises)-1].Line != lines[i] {
+			surprises = append(surprises, LineSurprise{Line: lines[i]})
+		}
+		line := &surprises[len(surprises)-1]
+		line.TokenCount++
+		line.Surprise += logProb
+	}
+	for i := range surprises {
+		surprises[i].Surprise /= float64(surprises[i].TokenCount)
+	}
+
+	return surprises, nil
+}
+
+// CompareRepositories measures style drift between two processed repositories.
+// Each repository's files are scored under both its own global model and the
+// other repository's global model; the gap between the two cross-entropies is
+// a KL-like divergence of the source repository from the other model.
+func (ns *NGramService) CompareRepositories(ctx context.Context, repoA, repoB string) (*RepositoryComparison, error) {
+	cmA, err := ns.GetCorpusManager(repoA, "")
+	if err != nil {
+		return nil, err
+	}
+	cmB, err := ns.GetCorpusManager(repoB, "")
+	if err != nil {
+		return nil, err
+	}
+
+	aUnderB, err := ns.scoreUnderModel(ctx, repoA, cmA, repoB, cmB)
+	if err != nil {
+		return nil, err
+	}
+	bUnderA, err := ns.scoreUnderModel(ctx, repoB, cmB, repoA, cmA)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RepositoryComparison{
+		RepoA:               repoA,
+		RepoB:               repoB,
+		AUnderB:             aUnderB,
+		BUnderA:             bUnderA,
+		SymmetricDivergence: (aUnderB.Divergence + bUnderA.Divergence) / 2,
+	}, nil
+}
+
+// scoreUnderModel computes the token-weighted entropy of the source corpus
+// files under their own global model and under the other corpus' global model
+func (ns *NGramService) scoreUnderModel(ctx context.Context, sourceRepo string, source *CorpusManager, modelRepo string, other *CorpusManager) (DivergenceSummary, error) {
+	summary := DivergenceSummary{
+		SourceRepo: sourceRepo,
+		ModelRepo:  modelRepo,
+	}
+
+	selfModel := source.GetGlobalModel()
+	otherModel := other.GetGlobalModel()
+	selfTotal, crossTotal := 0.0, 0.0
+
+	for _, path := range source.ListFiles(ctx) {
+		if err := ctx.Err(); err != nil {
+			return summary, err
+		}
+
+		fileModel, err := source.GetFileModel(ctx, path)
+		if err != nil {
+			continue
+		}
+
+		content, err := ns.readFile(path)
+		if err != nil {
+			ns.logger.Warn("Skipping file missing from disk during comparison",
+				zap.String("repo", sourceRepo),
+				zap.String("path", path),
+				zap.Error(err))
+			continue
+		}
+
+		self, cross := selfModel.newEntropyScorer(), otherModel.newEntropyScorer()
+		err = streamNormalizedTokens(ctx, ns.registry, content, fileModel.Language, func(token string) {
+			self.score(token)
+			cross.score(token)
+		})
+		if err != nil {
+			ns.logger.Warn("Failed to tokenize file during comparison",
+				zap.String("repo", sourceRepo),
+				zap.String("path", path),
+				zap.Error(err))
+			continue
+		}
+		if self.tokens == 0 {
+			continue
+		}
+
+		weight := float64(self.tokens)
+		selfTotal += self.crossEntropy() * weight
+		crossTotal += cross.crossEntropy() * weight
+		summary.FilesCompared++
+		summary.TokensCompared += self.tokens
+	}
+
+	if summary.TokensCompared > 0 {
+		summary.SelfEntropy = selfTotal / float64(summary.TokensCompared)
+		summary.CrossEntropy = crossTotal / float64(summary.TokensCompared)
+		summary.Divergence = summary.CrossEntropy - summary.SelfEntropy
+	}
+
+	return summary, nil
+}
+
 // calculateEntropyWithScores calculates entropy and returns individual n-gram scores (trie-based)
 func (ns *NGramService) calculateEntropyWithScores(tokens []string, model *NGramModelTrie, n int) (float64, []NGramScoreDetail) {
 	if len(tokens) < n {
@@ -344,12 +980,7 @@ func (ns *NGramService) calculateEntropyWithScores(tokens []string, model *NGram
 		context := ngram[:n-1]
 		token := ngram[n-1]
 		prob := model.Probability(token, context)
-		logProb := 0.0
-		if prob > 0 {
-			logProb = -1.0 * log2(prob)
-		} else {
-			logProb = 20.0 // High value for zero probability
-		}
+		logProb := surprisal(prob)
 
 		totalEntropy += logProb
 
@@ -365,6 +996,14 @@ func (ns *NGramService) calculateEntropyWithScores(tokens []string, model *NGram
 	return avgEntropy, ngramScores
 }
 
+// surprisal returns the bits of information in an event of probability prob
+func surprisal(prob float64) float64 {
+	if prob > 0 {
+		return -1.0 * log2(prob)
+	}
+	return 20.0 // High value for zero probability
+}
+
 // log2 calculates log base 2
 func log2(x float64) float64 {
 	if x <= 0 {
@@ -395,40 +1034,6 @@ func log2(x float64) float64 {
 	return lnX / ln2
 }
 
-// interpretZScore provides human-readable interpretation of z-score
-func interpretZScore(zScore float64) ZScoreInterpretation {
-	var level, description string
-	var percentile float64
-
-	if zScore < -2.0 {
-		level = "very_low"
-		description = "Extremely typical code - simpler than 97.5% of corpus"
-		percentile = 2.5
-	} else if zScore < -1.0 {
-		level = "low"
-		description = "More typical than average - simpler than 84% of corpus"
-		percentile = 16.0
-	} else if zScore <= 1.0 {
-		level = "normal"
-		description = "Normal entropy - within 1 standard deviation of mean"
-		percentile = 50.0
-	} else if zScore <= 2.0 {
-		level = "high"
-		description = "Unusual code - more complex than 84% of corpus"
-		percentile = 84.0
-	} else {
-		level = "very_high"
-		description = "Highly unusual code - more complex than 97.5% of corpus (potential bug indicator)"
-		percentile = 97.5
-	}
-
-	return ZScoreInterpretation{
-		Level:       level,
-		Description: description,
-		Percentile:  percentile,
-	}
-}
-
 // Helper functions
 
 func (ns *NGramService) shouldSkipDirectory(dirName string) bool {
@@ -448,49 +1053,40 @@ func (ns *NGramService) shouldSkipDirectory(dirName string) bool {
 }
 
 func (ns *NGramService) shouldProcessFile(filePath string, repo *config.Repository) bool {
-	// Check file extension
-	ext := strings.ToLower(filepath.Ext(filePath))
-	if ext == "" {
-		return false
-	}
-
-	// Check if we have a tokenizer for this extension
-	_, ok := ns.registry.GetTokenizerByExtension(ext)
+	// Check if we have a tokenizer for this file's language
+	_, ok := ns.registry.GetTokenizer(ns.detectLanguage(filePath, repo))
 	return ok
 }
 
-func (ns *NGramService) detectLanguage(filePath string) string {
+// detectLanguage returns the language of a file, preferring the repository's
+// language overrides and falling back to content sniffing for extensionless
+// and ambiguous files. Documentation files are "text" when the repository
+// includes docs, and files in a language without a tokenizer are "generic"
+// when their extension is configured for the generic tokenizer.
+func (ns *NGramService) detectLanguage(filePath string, repo *config.Repository) string {
+	language := repo.LanguageOverride(filePath)
 	ext := strings.ToLower(filepath.Ext(filePath))
-
-	switch ext {
-	case ".go":
-		return "go"
-	case ".py", ".pyw":
-		return "python"
-	case ".js", ".jsx", ".mjs":
-		return "javascript"
-	case ".ts", ".tsx":
-		return "typescript"
-	case ".java":
-		return "java"
+	switch {
+	case language != "":
+	case repo.IncludeDocs && slices.Contains(docExtensions, ext):
+		return "text"
 	default:
-		return ""
+		language = util.DetectFileLanguage(filePath)
 	}
-}
 
-func (ns *NGramService) readFile(filePath string) ([]byte, error) {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return nil, err
+	if _, ok := ns.registry.GetTokenizer(language); !ok && ns.genericExts[ext] {
+		return "generic"
 	}
-	defer file.Close()
+	return language
+}
 
-	content, err := io.ReadAll(file)
+// readFile reads a source file, normalizing its BOM and line endings
+func (ns *NGramService) readFile(filePath string) ([]byte, error) {
+	content, err := util.ReadFileWithLimit(filePath, ns.maxFileSize)
 	if err != nil {
 		return nil, err
 	}
-
-	return content, nil
+	return util.NormalizeSource(content), nil
 }
 
 // CodeAnalysis contains the analysis results for a code snippet
@@ -501,6 +1097,26 @@ type CodeAnalysis struct {
 	Language   string  `json:"language"`
 }
 
+// DivergenceSummary describes how surprising one repository's code is under another repository's model
+type DivergenceSummary struct {
+	SourceRepo     string  `json:"source_repo"`
+	ModelRepo      string  `json:"model_repo"`
+	FilesCompared  int     `json:"files_compared"`
+	TokensCompared int     `json:"tokens_compared"`
+	SelfEntropy    float64 `json:"self_entropy"`  // Mean bits per token under the source repo's own model
+	CrossEntropy   float64 `json:"cross_entropy"` // Mean bits per token under the other repo's model
+	Divergence     float64 `json:"divergence"`    // CrossEntropy - SelfEntropy
+}
+
+// RepositoryComparison contains divergence summaries in both directions
+type RepositoryComparison struct {
+	RepoA               string            `json:"repo_a"`
+	RepoB               string            `json:"repo_b"`
+	AUnderB             DivergenceSummary `json:"a_under_b"`
+	BUnderA             DivergenceSummary `json:"b_under_a"`
+	SymmetricDivergence float64           `json:"symmetric_divergence"`
+}
+
 // ZScoreAnalysis contains z-score analysis results
 type ZScoreAnalysis struct {
 	TokenCount     int                  `json:"token_count"`
@@ -511,6 +1127,13 @@ type ZScoreAnalysis struct {
 	Interpretation ZScoreInterpretation `json:"interpretation"`
 }
 
+// LineSurprise is the mean surprisal of the n-grams ending on one source line
+type LineSurprise struct {
+	Line       int     `json:"line"` // 1-based
+	TokenCount int     `json:"token_count"`
+	Surprise   float64 `json:"surprise"` // Mean bits per token
+}
+
 // NGramScoreDetail contains detailed information about a single n-gram
 type NGramScoreDetail struct {
 	NGram       []string `json:"ngram"`
@@ -524,4 +1147,5 @@ type ZScoreInterpretation struct {
 	Level       string  `json:"level"` // "very_low", "low", "normal", "high", "very_high"
 	Description string  `json:"description"`
 	Percentile  float64 `json:"percentile"` // Approximate percentile in corpus
+	Scale       string  `json:"scale"`      // Name of the ZScoreScale used
 }
@@ -0,0 +1,844 @@
+package ngram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"bot-go/internal/config"
+	ngrammodel "bot-go/internal/model/ngram"
+	"bot-go/internal/service/tokenizer"
+
+	"go.uber.org/zap"
+)
+
+func TestRecomputeEntropiesAfterPrune(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"sum.go":    "package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\ttotal += xs[i]\n\t}\n\treturn total\n}\n",
+		"count.go":  "package a\n\nfunc Count(xs []int) int {\n\tn := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\tn += 1\n\t}\n\treturn n\n}\n",
+		"wait.go":   "package a\n\nfunc Wait(done chan struct{}, errs chan error) error {\n\tselect {\n\tcase <-done:\n\t\treturn nil\n\tcase err := <-errs:\n\t\treturn err\n\t}\n}\n",
+		"labels.go": "package a\n\nvar labels = map[string]bool{\"x\": true, \"y\": false}\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatalf("mkdir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	ctx := context.Background()
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	if err := ns.ProcessRepository(ctx, &config.Repository{Name: "corpus", Path: dir}, 3, 0, true, ""); err != nil {
+		t.Fatalf("ProcessRepository: %v", err)
+	}
+
+	before, err := ns.RecomputeEntropies(ctx, "corpus", 0)
+	if err != nil {
+		t.Fatalf("RecomputeEntropies without pruning: %v", err)
+	}
+	after, err := ns.RecomputeEntropies(ctx, "corpus", 2)
+	if err != nil {
+		t.Fatalf("RecomputeEntropies with pruning: %v", err)
+	}
+
+	if after.GlobalModel.NGramCount >= before.GlobalModel.NGramCount {
+		t.Errorf("ngram count after prune = %d, want fewer than %d", after.GlobalModel.NGramCount, before.GlobalModel.NGramCount)
+	}
+	if after.AverageEntropy == before.AverageEntropy {
+		t.Errorf("average entropy unchanged by pruning: %f", after.AverageEntropy)
+	}
+
+	cm, err := ns.GetCorpusManager("corpus", "")
+	if err != nil {
+		t.Fatalf("GetCorpusManager: %v", err)
+	}
+	paths := cm.ListFiles(ctx)
+	if after.TotalFiles != len(files) || len(paths) != len(files) {
+		t.Fatalf("total files = %d (listed %d), want %d", after.TotalFiles, len(paths), len(files))
+	}
+
+	sum, tokens := 0.0, 0
+	for _, path := range paths {
+		fm, err := cm.GetFileModel(ctx, path)
+		if err != nil {
+			t.Fatalf("GetFileModel(%s): %v", path, err)
+		}
+		if want := cm.GetGlobalModel().CrossEntropy(mustTokens(t, ns, fm)); fm.Entropy != want {
+			t.Errorf("%s entropy = %f, want %f under pruned global model", path, fm.Entropy, want)
+		}
+		sum += fm.Entropy
+		tokens += fm.TokenCount
+	}
+
+	if mean := sum / float64(len(paths)); math.Abs(after.AverageEntropy-mean) > 1e-9 {
+		t.Errorf("average entropy = %f, want mean of file entropies %f", after.AverageEntropy, mean)
+	}
+	if after.TotalTokens != tokens {
+		t.Errorf("total tokens = %d, want sum of file tokens %d", after.TotalTokens, tokens)
+	}
+	if after.EntropyMin > after.AverageEntropy || after.AverageEntropy > after.EntropyMax {
+		t.Errorf("entropy range [%f, %f] does not contain average %f", after.EntropyMin, after.EntropyMax, after.AverageEntropy)
+	}
+
+	if _, err := ns.RecomputeEntropies(ctx, "missing", 0); err == nil {
+		t.Error("RecomputeEntropies for unknown repository succeeded, want error")
+	}
+}
+
+func mustTokens(t *testing.T, ns *NGramService, fm *FileModel) []string {
+	t.Helper()
+	content, err := os.ReadFile(fm.FilePath)
+	if err != nil {
+		t.Fatalf("read %s: %v", fm.FilePath, err)
+	}
+	var tokens []string
+	err = streamNormalizedTokens(context.Background(), ns.registry, content, fm.Language, func(token string) {
+		tokens = append(tokens, token)
+	})
+	if err != nil {
+		t.Fatalf("tokenize %s: %v", fm.FilePath, err)
+	}
+	return tokens
+}
+
+func TestProcessRepositoryIntoGroup(t *testing.T) {
+	repos := []*config.Repository{
+		{Name: "orders", Path: writeFiles(t, map[string]string{
+			"orders.go": "package orders\n\nfunc Total(xs []int) int {\n\ttotal := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\ttotal += xs[i]\n\t}\n\treturn total\n}\n",
+			"ids.go":    "package orders\n\nvar ids = map[string]int{\"a\": 1}\n",
+		})},
+		{Name: "billing", Path: writeFiles(t, map[string]string{
+			"billing.go": "package billing\n\nfunc Count(xs []int) int {\n\tn := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\tn += 1\n\t}\n\treturn n\n}\n",
+		})},
+	}
+
+	ctx := context.Background()
+	outputDir := t.TempDir()
+	ns, err := NewNGramServiceWithOutputDir(outputDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+
+	wantFiles, wantTokens := 0, 0
+	for _, repo := range repos {
+		if err := ns.ProcessRepository(ctx, repo, 3, 0, true, ""); err != nil {
+			t.Fatalf("ProcessRepository(%s): %v", repo.Name, err)
+		}
+		stats, err := ns.GetRepositoryStats(ctx, repo.Name)
+		if err != nil {
+			t.Fatalf("GetRepositoryStats(%s): %v", repo.Name, err)
+		}
+		wantFiles += stats.TotalFiles
+		wantTokens += stats.TotalTokens
+	}
+
+	for _, repo := range append(repos, repos[0]) { // The repeat must not double count
+		if err := ns.ProcessRepository(ctx, repo, 3, 0, true, "services"); err != nil {
+			t.Fatalf("ProcessRepository(%s) into group: %v", repo.Name, err)
+		}
+	}
+
+	group, err := ns.GetCorpusManager("", "services")
+	if err != nil {
+		t.Fatalf("GetCorpusManager for group: %v", err)
+	}
+	stats := group.GetStats(ctx)
+	if stats.TotalFiles != wantFiles || stats.TotalTokens != wantTokens {
+		t.Errorf("group stats = %d files / %d tokens, want %d / %d", stats.TotalFiles, stats.TotalTokens, wantFiles, wantTokens)
+	}
+
+	// Member repositories are scored against the combined baseline
+	for _, repo := range repos {
+		cm, err := ns.GetCorpusManager(repo.Name, "")
+		if err != nil {
+			t.Fatalf("GetCorpusManager(%s): %v", repo.Name, err)
+		}
+		if cm != group {
+			t.Errorf("%s resolves to its own corpus, want the group's", repo.Name)
+		}
+	}
+
+	// The group model is persisted and reloaded instead of rebuilt
+	reloaded, err := NewNGramServiceWithOutputDir(outputDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	if err := reloaded.ProcessRepository(ctx, repos[1], 3, 0, false, "services"); err != nil {
+		t.Fatalf("ProcessRepository from disk: %v", err)
+	}
+	reloadedStats, err := reloaded.GetRepositoryStats(ctx, repos[1].Name)
+	if err != nil {
+		t.Fatalf("GetRepositoryStats after reload: %v", err)
+	}
+	if reloadedStats.TotalFiles != wantFiles || reloadedStats.TotalTokens != wantTokens {
+		t.Errorf("reloaded group stats = %d files / %d tokens, want %d / %d", reloadedStats.TotalFiles, reloadedStats.TotalTokens, wantFiles, wantTokens)
+	}
+}
+
+func TestProcessRepositoryGroupTracksChanges(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"orders.go": "package orders\n\nfunc Total(xs []int) int {\n\ttotal := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\ttotal += xs[i]\n\t}\n\treturn total\n}\n",
+		"ids.go":    "package orders\n\nvar ids = map[string]int{\"a\": 1}\n",
+	})
+	repo := &config.Repository{Name: "orders", Path: dir}
+	ctx := context.Background()
+	outputDir := t.TempDir()
+	ns, counting := newCountingService(t, outputDir)
+	if err := ns.ProcessRepository(ctx, repo, 3, 0, false, "services"); err != nil {
+		t.Fatalf("ProcessRepository: %v", err)
+	}
+
+	// Unchanged files are skipped unless override is set
+	counting.calls.Store(0)
+	if err := ns.ProcessRepository(ctx, repo, 3, 0, false, "services"); err != nil {
+		t.Fatalf("ProcessRepository again: %v", err)
+	}
+	if got := counting.calls.Load(); got != 0 {
+		t.Errorf("repeat run tokenized %d unchanged files, want 0", got)
+	}
+	if err := ns.ProcessRepository(ctx, repo, 3, 0, true, "services"); err != nil {
+		t.Fatalf("ProcessRepository with override: %v", err)
+	}
+	if got := counting.calls.Load(); got != 2 {
+		t.Errorf("run with override tokenized %d files, want 2", got)
+	}
+
+	// A changed file replaces its counts in the group's model
+	changed := filepath.Join(dir, "ids.go")
+	if err := os.WriteFile(changed, []byte("package orders\n\nvar names = []string{\"a\", \"b\", \"c\"}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(changed, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := ns.ProcessRepository(ctx, repo, 3, 0, false, "services"); err != nil {
+		t.Fatalf("ProcessRepository after change: %v", err)
+	}
+	fresh, _ := newCountingService(t, t.TempDir())
+	if err := fresh.ProcessRepository(ctx, repo, 3, 0, true, ""); err != nil {
+		t.Fatalf("fresh ProcessRepository: %v", err)
+	}
+	got, err := ns.GetRepositoryStats(ctx, repo.Name)
+	if err != nil {
+		t.Fatalf("GetRepositoryStats: %v", err)
+	}
+	want, err := fresh.GetRepositoryStats(ctx, repo.Name)
+	if err != nil {
+		t.Fatalf("GetRepositoryStats: %v", err)
+	}
+	if got.TotalFiles != want.TotalFiles || got.TotalTokens != want.TotalTokens {
+		t.Errorf("group stats after change = %d files / %d tokens, want %d / %d", got.TotalFiles, got.TotalTokens, want.TotalFiles, want.TotalTokens)
+	}
+
+	// A restarted service still resolves the repository to its group
+	restarted, err := NewNGramServiceWithOutputDir(outputDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	if err := restarted.LoadModel(repo.Name); err != nil {
+		t.Fatalf("LoadModel: %v", err)
+	}
+	group, err := restarted.GetCorpusManager("", "services")
+	if err != nil {
+		t.Fatalf("GetCorpusManager for group: %v", err)
+	}
+	if cm, err := restarted.GetCorpusManager(repo.Name, ""); err != nil || cm != group {
+		t.Errorf("GetCorpusManager(%s) = %p, %v; want the group's model %p", repo.Name, cm, err, group)
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatalf("mkdir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	return dir
+}
+
+func TestProcessRepositoryExtensionOverride(t *testing.T) {
+	// No package clause, so content sniffing cannot tell this is Go
+	dir := writeFiles(t, map[string]string{
+		"render.tmpl": "func Render(w io.Writer, items []string) error {\n\tfor _, item := range items {\n\t\tfmt.Fprintln(w, item)\n\t}\n\treturn nil\n}\n",
+	})
+	path := filepath.Join(dir, "render.tmpl")
+
+	ctx := context.Background()
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+
+	if err := ns.ProcessRepository(ctx, &config.Repository{Name: "plain", Path: dir}, 3, 0, true, ""); err != nil {
+		t.Fatalf("ProcessRepository without override: %v", err)
+	}
+	if stats, _ := ns.GetRepositoryStats(ctx, "plain"); stats.TotalFiles != 0 {
+		t.Errorf("processed %d files without an override, want 0", stats.TotalFiles)
+	}
+
+	repo := &config.Repository{Name: "templates", Path: dir, ExtensionOverrides: map[string]string{".tmpl": "go"}}
+	if err := ns.ProcessRepository(ctx, repo, 3, 0, true, ""); err != nil {
+		t.Fatalf("ProcessRepository with override: %v", err)
+	}
+	cm, err := ns.GetCorpusManager("templates", "")
+	if err != nil {
+		t.Fatalf("GetCorpusManager: %v", err)
+	}
+	fm, err := cm.GetFileModel(ctx, path)
+	if err != nil {
+		t.Fatalf("GetFileModel: %v", err)
+	}
+	if fm.Language != "go" {
+		t.Errorf("language = %q, want go", fm.Language)
+	}
+
+	goTokenizer, err := tokenizer.NewGoTokenizer()
+	if err != nil {
+		t.Fatalf("NewGoTokenizer: %v", err)
+	}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	tokens, err := goTokenizer.Tokenize(ctx, source)
+	if err != nil {
+		t.Fatalf("Tokenize: %v", err)
+	}
+	if fm.TokenCount != len(tokens) {
+		t.Errorf("token count = %d, want %d from the Go tokenizer", fm.TokenCount, len(tokens))
+	}
+}
+
+func TestProcessRepositorySkipsFilesOverMaxSize(t *testing.T) {
+	source := "package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n"
+	dir := writeFiles(t, map[string]string{
+		"sum.go":    source,
+		"bundle.go": source + "\nvar data = \"" + strings.Repeat("x", 1000) + "\"\n",
+	})
+
+	ctx := context.Background()
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	ns.SetMaxFileSize(int64(len(source)))
+
+	if err := ns.ProcessRepository(ctx, &config.Repository{Name: "demo", Path: dir}, 3, 0, true, ""); err != nil {
+		t.Fatalf("ProcessRepository: %v", err)
+	}
+	cm, err := ns.GetCorpusManager("demo", "")
+	if err != nil {
+		t.Fatalf("GetCorpusManager: %v", err)
+	}
+	if !cm.HasFile(filepath.Join(dir, "sum.go")) {
+		t.Error("sum.go is within the limit but was not processed")
+	}
+	if cm.HasFile(filepath.Join(dir, "bundle.go")) {
+		t.Error("bundle.go is over the limit but was processed")
+	}
+}
+
+func TestProcessRepositoryFileWeights(t *testing.T) {
+	source := "package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor _, x := range xs {\n\t\ttotal += x\n\t}\n\treturn total\n}\n"
+	ctx := context.Background()
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	ns.SetFileWeights([]config.FileWeightRule{
+		{Glob: "*_gen.go", Weight: 0},
+		{Glob: "legacy/*", Weight: 0.5},
+	})
+
+	build := func(name string, files map[string]string) *NGramModelTrie {
+		if err := ns.ProcessRepository(ctx, &config.Repository{Name: name, Path: writeFiles(t, files)}, 3, 0, true, ""); err != nil {
+			t.Fatalf("ProcessRepository(%s): %v", name, err)
+		}
+		cm, err := ns.GetCorpusManager(name, "")
+		if err != nil {
+			t.Fatalf("GetCorpusManager(%s): %v", name, err)
+		}
+		return cm.GetGlobalModel()
+	}
+	single := build("single", map[string]string{"sum.go": source})
+	weighted := build("weighted", map[string]string{
+		"sum.go":           source,
+		"pkg/sum_gen.go":   source,
+		"legacy/sum.go":    source,
+		"legacy/x/deep.go": source, // legacy/* does not cross directories
+	})
+
+	// sum.go and legacy/x/deep.go count fully, legacy/sum.go by half and the
+	// generated file not at all
+	if want := single.totalTokens * 5 / 2; weighted.totalTokens != want {
+		t.Errorf("weighted model stored %d token counts, want %d", weighted.totalTokens, want)
+	}
+}
+
+func TestProcessRepositoryIncludeDocs(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.go":   "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n",
+		"README.md": "# Demo\n\nThe demo prints a greeting and exits.\n\n```sh\ngo run .\n```\n",
+		"notes.txt": "Remember to update the greeting before the release.\n",
+	})
+
+	ctx := context.Background()
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		includeDocs bool
+		want        map[string]int
+	}{
+		{"code only", false, map[string]int{"go": 1}},
+		{"with docs", true, map[string]int{"go": 1, "text": 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &config.Repository{Name: tt.name, Path: dir, IncludeDocs: tt.includeDocs}
+			if err := ns.ProcessRepository(ctx, repo, 3, 0, true, ""); err != nil {
+				t.Fatalf("ProcessRepository: %v", err)
+			}
+			stats, err := ns.GetRepositoryStats(ctx, tt.name)
+			if err != nil {
+				t.Fatalf("GetRepositoryStats: %v", err)
+			}
+			if !reflect.DeepEqual(stats.LanguageCounts, tt.want) {
+				t.Errorf("language counts = %v, want %v", stats.LanguageCounts, tt.want)
+			}
+		})
+	}
+
+	// Snippets can be scored as text against a model that includes docs
+	analysis, err := ns.AnalyzeCode(ctx, "with docs", "text", []byte("The demo prints a greeting."))
+	if err != nil {
+		t.Fatalf("AnalyzeCode: %v", err)
+	}
+	if analysis.TokenCount != 6 {
+		t.Errorf("token count = %d, want 6", analysis.TokenCount)
+	}
+}
+
+func TestProcessRepositoryGenericTokenizer(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.go":  "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n",
+		"lib.rs":   "fn add(a: i32, b: i32) -> i32 {\n    a + b\n}\n",
+		"app.rb":   "def add(a, b)\n  a + b\nend\n",
+		"build.sh": "#!/bin/sh\necho hi\n",
+	})
+
+	ctx := context.Background()
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		extensions []string
+		want       map[string]int
+	}{
+		{"disabled", nil, map[string]int{"go": 1}},
+		{"enabled", []string{".rs", ".RB", ".go"}, map[string]int{"go": 1, "generic": 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns.SetGenericExtensions(tt.extensions)
+			repo := &config.Repository{Name: tt.name, Path: dir}
+			if err := ns.ProcessRepository(ctx, repo, 3, 0, true, ""); err != nil {
+				t.Fatalf("ProcessRepository: %v", err)
+			}
+			stats, err := ns.GetRepositoryStats(ctx, tt.name)
+			if err != nil {
+				t.Fatalf("GetRepositoryStats: %v", err)
+			}
+			if !reflect.DeepEqual(stats.LanguageCounts, tt.want) {
+				t.Errorf("language counts = %v, want %v", stats.LanguageCounts, tt.want)
+			}
+		})
+	}
+}
+
+// sameEntropyStats compares statistics summed in map order, so up to rounding
+func sameEntropyStats(a, b EntropyStats) bool {
+	const eps = 1e-9
+	return a.Count == b.Count &&
+		math.Abs(a.Mean-b.Mean) < eps && math.Abs(a.StdDev-b.StdDev) < eps &&
+		math.Abs(a.Min-b.Min) < eps && math.Abs(a.Max-b.Max) < eps
+}
+
+func TestCalculateZScoreUsesLanguageStats(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"sum.go":    "package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\ttotal += xs[i]\n\t}\n\treturn total\n}\n",
+		"count.go":  "package a\n\nfunc Count(xs []int) int {\n\tn := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\tn += 1\n\t}\n\treturn n\n}\n",
+		"kind.go":   "package a\n\nfunc Kind(v interface{}) string {\n\tswitch v.(type) {\n\tcase string:\n\t\treturn \"s\"\n\tdefault:\n\t\treturn \"?\"\n\t}\n}\n",
+		"greet.py":  "def greet(names):\n    for name in names:\n        print('hello', name)\n",
+		"total.py":  "def total(xs):\n    result = 0\n    for x in xs:\n        result += x\n    return result\n",
+		"config.py": "class Config:\n    def __init__(self, path):\n        self.path = path\n        self.values = {}\n\n    def get(self, key):\n        return self.values.get(key)\n",
+	})
+
+	ctx := context.Background()
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	if err := ns.ProcessRepository(ctx, &config.Repository{Name: "mixed", Path: dir}, 3, 0, true, ""); err != nil {
+		t.Fatalf("ProcessRepository: %v", err)
+	}
+
+	stats, err := ns.GetRepositoryStats(ctx, "mixed")
+	if err != nil {
+		t.Fatalf("GetRepositoryStats: %v", err)
+	}
+	goStats, pythonStats := stats.LanguageEntropy["go"], stats.LanguageEntropy["python"]
+	if goStats.Count != 3 || pythonStats.Count != 3 {
+		t.Fatalf("per-language file counts = %d go, %d python, want 3 each", goStats.Count, pythonStats.Count)
+	}
+	if goStats.Mean == pythonStats.Mean {
+		t.Errorf("go and python mean entropy are both %.4f, want different distributions", goStats.Mean)
+	}
+
+	cm, err := ns.GetCorpusManager("mixed", "")
+	if err != nil {
+		t.Fatalf("GetCorpusManager: %v", err)
+	}
+	if got := cm.GetEntropyStatsByLanguage(ctx, "python"); !sameEntropyStats(got, pythonStats) {
+		t.Errorf("GetEntropyStatsByLanguage(python) = %+v, want %+v", got, pythonStats)
+	}
+
+	analysis, err := ns.CalculateZScore(ctx, "mixed", "python", []byte("def double(xs):\n    return [x * 2 for x in xs]\n"))
+	if err != nil {
+		t.Fatalf("CalculateZScore: %v", err)
+	}
+	if !sameEntropyStats(analysis.EntropyStats, pythonStats) {
+		t.Errorf("z-score stats = %+v, want python stats %+v", analysis.EntropyStats, pythonStats)
+	}
+	want := (analysis.Entropy - pythonStats.Mean) / pythonStats.StdDev
+	if math.Abs(analysis.ZScore-want) > 1e-9 {
+		t.Errorf("z-score = %.6f, want %.6f from the python distribution", analysis.ZScore, want)
+	}
+	if got := analysis.Interpretation; got != DefaultZScoreScale().Interpret(analysis.ZScore) {
+		t.Errorf("interpretation = %+v, want the default scale's", got)
+	}
+}
+
+func TestServicesWithSeparateOutputDirs(t *testing.T) {
+	ctx := context.Background()
+	corpora := []map[string]string{
+		{"sum.go": "package a\n\nfunc Sum(xs []int) int {\n\ttotal := 0\n\tfor i := 0; i < len(xs); i++ {\n\t\ttotal += xs[i]\n\t}\n\treturn total\n}\n"},
+		{
+			"kind.go": "package b\n\nfunc Kind(v interface{}) string {\n\tswitch v.(type) {\n\tcase string:\n\t\treturn \"s\"\n\tdefault:\n\t\treturn \"?\"\n\t}\n}\n",
+			"wait.go": "package b\n\nfunc Wait(done chan struct{}) {\n\t<-done\n}\n",
+		},
+	}
+
+	// Both services save a model under the same repository name
+	outputDirs := make([]string, len(corpora))
+	for i, files := range corpora {
+		outputDirs[i] = filepath.Join(t.TempDir(), "ngram_models")
+		ns, err := NewNGramServiceWithOutputDir(outputDirs[i], zap.NewNop())
+		if err != nil {
+			t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+		}
+		if err := ns.ProcessRepository(ctx, &config.Repository{Name: "shared", Path: writeFiles(t, files)}, 3, 0, true, ""); err != nil {
+			t.Fatalf("ProcessRepository: %v", err)
+		}
+		if _, err := os.Stat(filepath.Join(outputDirs[i], "shared_ngram.gob")); err != nil {
+			t.Errorf("model not written under %s: %v", outputDirs[i], err)
+		}
+	}
+
+	// Each directory reloads its own model
+	for i, outputDir := range outputDirs {
+		ns, err := NewNGramServiceWithOutputDir(outputDir, zap.NewNop())
+		if err != nil {
+			t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+		}
+		if err := ns.ProcessRepository(ctx, &config.Repository{Name: "shared", Path: t.TempDir()}, 3, 0, false, ""); err != nil {
+			t.Fatalf("ProcessRepository from disk: %v", err)
+		}
+		stats, err := ns.GetRepositoryStats(ctx, "shared")
+		if err != nil {
+			t.Fatalf("GetRepositoryStats: %v", err)
+		}
+		if stats.TotalFiles != len(corpora[i]) {
+			t.Errorf("model in %s has %d files, want %d", outputDir, stats.TotalFiles, len(corpora[i]))
+		}
+	}
+}
+
+// countingTokenizer counts the sources it tokenizes, calling onCall with the
+// running count before tokenizing each one
+type countingTokenizer struct {
+	tokenizer.Tokenizer
+	calls  atomic.Int32
+	onCall func(n int32)
+}
+
+func (t *countingTokenizer) TokenizeStream(ctx context.Context, source io.Reader, emit func(ngrammodel.Token) error) error {
+	n := t.calls.Add(1)
+	if t.onCall != nil {
+		t.onCall(n)
+	}
+	return t.Tokenizer.TokenizeStream(ctx, source, emit)
+}
+
+// newCountingService returns a service writing models to outputDir whose Go
+// tokenizer is wrapped in a countingTokenizer
+func newCountingService(t *testing.T, outputDir string) (*NGramService, *countingTokenizer) {
+	t.Helper()
+	ns, err := NewNGramServiceWithOutputDir(outputDir, zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	goTokenizer, _ := ns.registry.GetTokenizer("go")
+	counting := &countingTokenizer{Tokenizer: goTokenizer}
+	ns.registry.Register("go", counting, []string{".go"})
+	ns.SetCheckpointInterval(1)
+	return ns, counting
+}
+
+func TestProcessRepositoryResumesFromCheckpoint(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 8; i++ {
+		files[fmt.Sprintf("f%d.go", i)] = fmt.Sprintf("package a\n\nfunc F%d(xs []int) int {\n\treturn len(xs) + %d\n}\n", i, i)
+	}
+	repo := &config.Repository{Name: "resumable", Path: writeFiles(t, files)}
+	outputDir := t.TempDir()
+
+	// First run is cancelled part way through the tree
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ns, counting := newCountingService(t, outputDir)
+	counting.onCall = func(n int32) {
+		if n == 3 {
+			cancel()
+		}
+	}
+	err := ns.ProcessRepository(ctx, repo, 3, 0, false, "")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled ProcessRepository: err = %v, want context.Canceled", err)
+	}
+
+	checkpoint, err := ns.persistence.LoadCorpusManager(repo.Name, ns.registry, zap.NewNop())
+	if err != nil {
+		t.Fatalf("LoadCorpusManager: %v", err)
+	}
+	if !checkpoint.IsCheckpoint() {
+		t.Fatal("model saved on cancellation is not marked as a checkpoint")
+	}
+	done := len(checkpoint.ListFiles(context.Background()))
+	if done == 0 || done >= len(files) {
+		t.Fatalf("checkpoint has %d files, want some but not all of %d", done, len(files))
+	}
+
+	// A restarted service only tokenizes the files missing from the checkpoint
+	ns, counting = newCountingService(t, outputDir)
+	if err := ns.ProcessRepository(context.Background(), repo, 3, 0, false, ""); err != nil {
+		t.Fatalf("resumed ProcessRepository: %v", err)
+	}
+	if got, want := int(counting.calls.Load()), len(files)-done; got != want {
+		t.Errorf("resumed run tokenized %d files, want %d", got, want)
+	}
+	stats, err := ns.GetRepositoryStats(context.Background(), repo.Name)
+	if err != nil {
+		t.Fatalf("GetRepositoryStats: %v", err)
+	}
+	if stats.TotalFiles != len(files) {
+		t.Errorf("resumed model has %d files, want %d", stats.TotalFiles, len(files))
+	}
+
+	// The finished model is loaded as is
+	ns, counting = newCountingService(t, outputDir)
+	if err := ns.ProcessRepository(context.Background(), repo, 3, 0, false, ""); err != nil {
+		t.Fatalf("ProcessRepository after completion: %v", err)
+	}
+	if got := counting.calls.Load(); got != 0 {
+		t.Errorf("run after completion tokenized %d files, want 0", got)
+	}
+}
+
+// checkpointPartOf cancels a build of repo once a few files are added and
+// returns the paths in the checkpoint it leaves behind
+func checkpointPartOf(t *testing.T, repo *config.Repository, outputDir string, n int) []string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ns, counting := newCountingService(t, outputDir)
+	counting.onCall = func(calls int32) {
+		if calls == 3 {
+			cancel()
+		}
+	}
+	if err := ns.ProcessRepository(ctx, repo, n, 0, true, ""); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled ProcessRepository: err = %v, want context.Canceled", err)
+	}
+	checkpoint, err := ns.persistence.LoadCorpusManager(repo.Name, ns.registry, zap.NewNop())
+	if err != nil {
+		t.Fatalf("LoadCorpusManager: %v", err)
+	}
+	return checkpoint.ListFiles(context.Background())
+}
+
+func TestProcessRepositoryResumeHandlesChangesSinceCheckpoint(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 8; i++ {
+		files[fmt.Sprintf("f%d.go", i)] = fmt.Sprintf("package a\n\nfunc F%d(xs []int) int {\n\treturn len(xs) + %d\n}\n", i, i)
+	}
+	repo := &config.Repository{Name: "resumable", Path: writeFiles(t, files)}
+
+	// A file changed after it was checkpointed replaces its saved counts
+	outputDir := t.TempDir()
+	done := checkpointPartOf(t, repo, outputDir, 3)
+	if len(done) == 0 {
+		t.Fatal("checkpoint has no files")
+	}
+	changed := done[0]
+	if err := os.WriteFile(changed, []byte("package a\n\nfunc G(m map[string]int) {\n\tfor k := range m {\n\t\tdelete(m, k)\n\t}\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(changed, later, later); err != nil {
+		t.Fatal(err)
+	}
+	ns, _ := newCountingService(t, outputDir)
+	if err := ns.ProcessRepository(context.Background(), repo, 3, 0, false, ""); err != nil {
+		t.Fatalf("resumed ProcessRepository: %v", err)
+	}
+	resumed, err := ns.GetCorpusManager(repo.Name, "")
+	if err != nil {
+		t.Fatalf("GetCorpusManager: %v", err)
+	}
+	fresh, _ := newCountingService(t, t.TempDir())
+	if err := fresh.ProcessRepository(context.Background(), repo, 3, 0, true, ""); err != nil {
+		t.Fatalf("fresh ProcessRepository: %v", err)
+	}
+	want, err := fresh.GetCorpusManager(repo.Name, "")
+	if err != nil {
+		t.Fatalf("GetCorpusManager: %v", err)
+	}
+	if got, want := trieCounts(resumed.GetGlobalModel().ngramTrie), trieCounts(want.GetGlobalModel().ngramTrie); !reflect.DeepEqual(got, want) {
+		t.Errorf("resumed n-gram counts = %v, want %v like a fresh build", got, want)
+	}
+
+	// A checkpoint of a build for another n is discarded, not resumed
+	outputDir = t.TempDir()
+	checkpointPartOf(t, repo, outputDir, 3)
+	ns, counting := newCountingService(t, outputDir)
+	if err := ns.ProcessRepository(context.Background(), repo, 4, 0, false, ""); err != nil {
+		t.Fatalf("ProcessRepository with n 4: %v", err)
+	}
+	if got := int(counting.calls.Load()); got != len(files) {
+		t.Errorf("run with n 4 tokenized %d files, want all %d", got, len(files))
+	}
+	cm, err := ns.GetCorpusManager(repo.Name, "")
+	if err != nil {
+		t.Fatalf("GetCorpusManager: %v", err)
+	}
+	if cm.n != 4 || cm.IsCheckpoint() {
+		t.Errorf("model has n %d (checkpoint %v), want a finished 4-gram model", cm.n, cm.IsCheckpoint())
+	}
+}
+
+func TestProcessRepositoryNormalizesLineEndingsAndBOM(t *testing.T) {
+	sources := map[string]string{
+		"sum.go": "package a\n\n// Sum adds two numbers\nfunc Sum(a, b int) int {\n\n\treturn a + b\n}\n",
+		"sum.rb": "# Sum adds two numbers\ndef sum(a, b)\n\n  a + b\nend\n",
+	}
+	variants := map[string]func(string) string{
+		"lf":   func(s string) string { return s },
+		"crlf": func(s string) string { return strings.ReplaceAll(s, "\n", "\r\n") },
+		"bom":  func(s string) string { return "\xEF\xBB\xBF" + strings.ReplaceAll(s, "\n", "\r\n") },
+	}
+
+	ctx := context.Background()
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	ns.SetGenericExtensions(DefaultGenericExtensions)
+
+	// variant -> file -> model
+	fileModels := make(map[string]map[string]*FileModel)
+	for name, convert := range variants {
+		files := make(map[string]string, len(sources))
+		for file, source := range sources {
+			files[file] = convert(source)
+		}
+		dir := writeFiles(t, files)
+		if err := ns.ProcessRepository(ctx, &config.Repository{Name: name, Path: dir}, 3, 0, true, ""); err != nil {
+			t.Fatalf("ProcessRepository(%s): %v", name, err)
+		}
+		cm, err := ns.GetCorpusManager(name, "")
+		if err != nil {
+			t.Fatalf("GetCorpusManager(%s): %v", name, err)
+		}
+		fileModels[name] = make(map[string]*FileModel)
+		for file := range sources {
+			fm, err := cm.GetFileModel(ctx, filepath.Join(dir, file))
+			if err != nil {
+				t.Fatalf("GetFileModel(%s, %s): %v", name, file, err)
+			}
+			fileModels[name][file] = fm
+		}
+	}
+
+	for _, name := range []string{"crlf", "bom"} {
+		for file, want := range fileModels["lf"] {
+			got := fileModels[name][file]
+			if got.TokenCount != want.TokenCount || got.Entropy != want.Entropy {
+				t.Errorf("%s %s: %d tokens, entropy %v; want %d tokens, entropy %v like the LF file",
+					name, file, got.TokenCount, got.Entropy, want.TokenCount, want.Entropy)
+			}
+		}
+	}
+}
+
+func TestProcessRepositoryRejectsNOutOfRange(t *testing.T) {
+	ns, err := NewNGramServiceWithOutputDir(t.TempDir(), zap.NewNop())
+	if err != nil {
+		t.Fatalf("NewNGramServiceWithOutputDir: %v", err)
+	}
+	if err := ns.SetNRange(4, 2); !errors.Is(err, ErrInvalidN) {
+		t.Errorf("SetNRange(4, 2) = %v, want ErrInvalidN", err)
+	}
+	if err := ns.SetNRange(2, 0); err != nil {
+		t.Fatalf("SetNRange(2, 0): %v", err)
+	}
+
+	repo := &config.Repository{Name: "demo", Path: writeFiles(t, map[string]string{"a.go": "package a\n"})}
+	for _, n := range []int{1, DefaultMaxN + 1} {
+		if err := ns.ProcessRepository(context.Background(), repo, n, 0, true, ""); !errors.Is(err, ErrInvalidN) {
+			t.Errorf("ProcessRepository(n=%d) = %v, want ErrInvalidN", n, err)
+		}
+	}
+	if err := ns.ProcessRepository(context.Background(), repo, DefaultMaxN, 0, true, ""); err != nil {
+		t.Errorf("ProcessRepository(n=%d): %v", DefaultMaxN, err)
+	}
+}
@@ -33,6 +33,7 @@ type NGramTrie struct {
 	totalNGrams int64              // Total number of n-grams stored
 	bloomFilter *bloom.BloomFilter // Bloom filter for singleton detection
 	useBloom    bool               // Whether to use bloom filter for singletons
+	released    map[string]int     // Bloom-only first sightings undone by Remove, by bloom key
 	mu          sync.RWMutex       // Protects all data structures
 }
 
@@ -56,6 +57,7 @@ func NewNGramTrieWithBloom(useBloom bool, expectedItems uint, falsePositiveRate
 
 	if useBloom {
 		trie.bloomFilter = bloom.NewWithEstimates(expectedItems, falsePositiveRate)
+		trie.released = make(map[string]int)
 	}
 
 	return trie
@@ -86,7 +88,13 @@ func (t *NGramTrie) getToken(id uint32) string {
 // Insert adds an n-gram to the trie and increments its count
 // If bloom filter is enabled, only stores n-grams that appear more than once
 func (t *NGramTrie) Insert(tokens []string) {
-	if len(tokens) == 0 {
+	t.InsertN(tokens, 1)
+}
+
+// InsertN records one sighting of an n-gram that adds n to its count. With a
+// bloom filter the first sighting is only recorded in the filter, whatever its n.
+func (t *NGramTrie) InsertN(tokens []string, n int64) {
+	if len(tokens) == 0 || n < 1 {
 		return
 	}
 
@@ -103,6 +111,11 @@ func (t *NGramTrie) Insert(tokens []string) {
 			t.bloomFilter.AddString(ngramKey)
 			return
 		}
+		// Seen before, but every sighting was removed: this is the first again
+		if t.released[ngramKey] > 0 {
+			t.release(ngramKey, -1)
+			return
+		}
 		// Second time (or more) - add to trie
 	}
 
@@ -124,8 +137,63 @@ func (t *NGramTrie) Insert(tokens []string) {
 	}
 
 	// Increment count at the final node
-	current.count++
-	t.totalNGrams++
+	current.count += n
+	t.totalNGrams += n
+}
+
+// Merge adds the counts of another trie to this one. Token IDs are mapped
+// through their strings, since the two tries intern tokens independently.
+// With bloom filters, n-grams the other trie has seen only once are recorded
+// in neither trie and are lost unless the filters can be merged.
+func (t *NGramTrie) Merge(other *NGramTrie) {
+	if other == nil || other == t {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	other.mu.RLock()
+	defer other.mu.RUnlock()
+
+	t.mergeNode(t.root, other, other.root)
+	t.totalNGrams += other.totalNGrams
+	t.totalTokens += other.totalTokens
+	if t.useBloom && other.useBloom {
+		// Filters of different sizes cannot be merged; singletons are then dropped
+		_ = t.bloomFilter.Merge(other.bloomFilter)
+	}
+}
+
+// scaleCounts multiplies every count in the trie by factor
+func (t *NGramTrie) scaleCounts(factor int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var scale func(node *TrieNode)
+	scale = func(node *TrieNode) {
+		node.count *= factor
+		for _, child := range node.children {
+			scale(child)
+		}
+	}
+	scale(t.root)
+	t.totalNGrams *= factor
+	t.totalTokens *= factor
+}
+
+// mergeNode adds the counts below src, a node of other, to dst. Callers hold
+// both tries' locks.
+func (t *NGramTrie) mergeNode(dst *TrieNode, other *NGramTrie, src *TrieNode) {
+	for srcID, srcChild := range src.children {
+		tokenID := t.internToken(other.getToken(srcID))
+		child, exists := dst.children[tokenID]
+		if !exists {
+			child = NewTrieNode(tokenID)
+			dst.children[tokenID] = child
+		}
+		child.count += srcChild.count
+		t.mergeNode(child, other, srcChild)
+	}
 }
 
 // tokensToKey creates a unique string key for an n-gram (for bloom filter)
@@ -172,44 +240,97 @@ func (t *NGramTrie) GetCount(tokens []string) int64 {
 	return current.count
 }
 
+// ContinuationCount returns the number of distinct tokens that follow a prefix,
+// i.e. the fan-out of the prefix node counting only children with a non-zero count
+func (t *NGramTrie) ContinuationCount(prefix []string) int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	current := t.root
+	for _, token := range prefix {
+		id, exists := t.tokenToID[token]
+		if !exists {
+			return 0
+		}
+		child, exists := current.children[id]
+		if !exists {
+			return 0
+		}
+		current = child
+	}
+
+	count := 0
+	for _, child := range current.children {
+		if child.count > 0 {
+			count++
+		}
+	}
+	return count
+}
+
 // Remove decrements the count of an n-gram (for incremental updates)
 func (t *NGramTrie) Remove(tokens []string) {
-	if len(tokens) == 0 {
+	t.RemoveN(tokens, 1)
+}
+
+// RemoveN undoes InsertN with the same tokens and n. The count does not drop
+// below zero.
+func (t *NGramTrie) RemoveN(tokens []string, n int64) {
+	if len(tokens) == 0 || n < 1 {
 		return
 	}
 
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	// Convert tokens to IDs
-	tokenIDs := make([]uint32, len(tokens))
-	for i, token := range tokens {
-		id, exists := t.tokenToID[token]
-		if !exists {
-			return // Token never seen, nothing to remove
+	// Decrement count
+	if current := t.findNode(tokens); current != nil && current.count > 0 {
+		n = min(n, current.count)
+		current.count -= n
+		t.totalNGrams -= n
+		return
+	}
+
+	// With a bloom filter an n-gram's first sighting is only recorded there.
+	// The filter cannot forget it, so remember that it was given back and
+	// treat the next insert as the first sighting again.
+	if t.useBloom {
+		if ngramKey := t.tokensToKey(tokens); t.bloomFilter.TestString(ngramKey) {
+			t.release(ngramKey, 1)
 		}
-		tokenIDs[i] = id
 	}
 
-	// Traverse trie
+	// Note: We don't remove nodes even if count reaches 0
+	// This keeps the trie structure stable for concurrent access
+	// Optional: implement garbage collection separately
+}
+
+// findNode returns the node of an n-gram, or nil if it is not in the trie.
+// Callers hold t.mu.
+func (t *NGramTrie) findNode(tokens []string) *TrieNode {
 	current := t.root
-	for _, tokenID := range tokenIDs {
-		child, exists := current.children[tokenID]
+	for _, token := range tokens {
+		id, exists := t.tokenToID[token]
+		if !exists {
+			return nil // Token never seen
+		}
+		child, exists := current.children[id]
 		if !exists {
-			return // N-gram not found
+			return nil // N-gram not found
 		}
 		current = child
 	}
+	return current
+}
 
-	// Decrement count
-	if current.count > 0 {
-		current.count--
-		t.totalNGrams--
+// release adjusts the number of removed bloom-only sightings of an n-gram.
+// Callers hold t.mu.
+func (t *NGramTrie) release(ngramKey string, delta int) {
+	if count := t.released[ngramKey] + delta; count > 0 {
+		t.released[ngramKey] = count
+	} else {
+		delete(t.released, ngramKey)
 	}
-
-	// Note: We don't remove nodes even if count reaches 0
-	// This keeps the trie structure stable for concurrent access
-	// Optional: implement garbage collection separately
 }
 
 // GetAllWithPrefix returns all n-grams with a given prefix
@@ -265,6 +386,14 @@ func (t *NGramTrie) collectNGrams(node *TrieNode, path []uint32, results *[]NGra
 	}
 }
 
+// HasToken reports whether the token has been interned
+func (t *NGramTrie) HasToken(token string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	_, exists := t.tokenToID[token]
+	return exists
+}
+
 // VocabularySize returns the number of unique tokens
 func (t *NGramTrie) VocabularySize() int {
 	t.mu.RLock()
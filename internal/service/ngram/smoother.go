@@ -1,13 +1,16 @@
 package ngram
 
+import "fmt"
+
 // Smoother defines the interface for n-gram probability smoothing algorithms
 type Smoother interface {
 	// Smooth computes the smoothed probability for an n-gram
-	// ngramCount: count of the full n-gram
-	// contextCount: count of the context (n-1 gram)
+	// ngramCount: count of the full n-gram, fractional for weighted models
+	// contextCount: count of the context (n-1 gram), fractional for weighted models
+	// continuationTypes: number of distinct tokens seen following the context
 	// backoffProb: probability from lower-order model
 	// vocabularySize: size of the vocabulary
-	Smooth(ngramCount, contextCount int64, backoffProb float64, vocabularySize int) float64
+	Smooth(ngramCount, contextCount float64, continuationTypes int64, backoffProb float64, vocabularySize int) float64
 
 	// Name returns the name of the smoothing algorithm
 	Name() string
@@ -26,12 +29,12 @@ func NewAddKSmoother(k float64) *AddKSmoother {
 	return &AddKSmoother{k: k}
 }
 
-func (s *AddKSmoother) Smooth(ngramCount, contextCount int64, backoffProb float64, vocabularySize int) float64 {
+func (s *AddKSmoother) Smooth(ngramCount, contextCount float64, continuationTypes int64, backoffProb float64, vocabularySize int) float64 {
 	if contextCount == 0 {
 		return 1.0 / float64(vocabularySize)
 	}
-	numerator := float64(ngramCount) + s.k
-	denominator := float64(contextCount) + (s.k * float64(vocabularySize))
+	numerator := ngramCount + s.k
+	denominator := contextCount + (s.k * float64(vocabularySize))
 	return numerator / denominator
 }
 
@@ -47,24 +50,70 @@ func NewWittenBellSmoother() *WittenBellSmoother {
 	return &WittenBellSmoother{}
 }
 
-func (s *WittenBellSmoother) Smooth(ngramCount, contextCount int64, backoffProb float64, vocabularySize int) float64 {
+// Smooth interpolates the maximum-likelihood estimate with the backoff
+// distribution, reserving probability mass for unseen continuations in
+// proportion to the number of distinct continuation types T of the context:
+//
+//	P(w|h) = (c(h,w) + T(h) * P_backoff(w)) / (c(h) + T(h))
+func (s *WittenBellSmoother) Smooth(ngramCount, contextCount float64, continuationTypes int64, backoffProb float64, vocabularySize int) float64 {
 	if contextCount == 0 {
 		return 1.0 / float64(vocabularySize)
 	}
-
-	if ngramCount > 0 {
-		// Seen n-gram: use MLE with discounting
-		uniqueTypes := float64(vocabularySize) // Simplified: should be unique continuations
-		lambda := float64(contextCount) / (float64(contextCount) + uniqueTypes)
-		return lambda * (float64(ngramCount) / float64(contextCount))
+	if continuationTypes == 0 {
+		// No stored continuations (e.g. all pruned as singletons): nothing to interpolate with
+		return backoffProb
 	}
 
-	// Unseen n-gram: use backoff
-	uniqueTypes := float64(vocabularySize)
-	lambda := uniqueTypes / (float64(contextCount) + uniqueTypes)
-	return lambda * backoffProb
+	types := float64(continuationTypes)
+	return (ngramCount + types*backoffProb) / (contextCount + types)
 }
 
 func (s *WittenBellSmoother) Name() string {
 	return "WittenBell"
 }
+
+// SmootherParams holds the tunable parameters a smoother is persisted with
+type SmootherParams struct {
+	K float64 // Add-k constant (AddK only)
+}
+
+// SmootherFactory creates a smoother from its persisted parameters
+type SmootherFactory func(params SmootherParams) Smoother
+
+// SmootherRegistry maps smoother names, as returned by Smoother.Name, to
+// factories, so persisted models restore the smoother they were built with
+type SmootherRegistry struct {
+	factories map[string]SmootherFactory
+}
+
+// NewSmootherRegistry creates a registry with the built-in smoothers registered
+func NewSmootherRegistry() *SmootherRegistry {
+	registry := &SmootherRegistry{factories: make(map[string]SmootherFactory)}
+	registry.Register("AddK", func(params SmootherParams) Smoother {
+		return NewAddKSmoother(params.K) // Models saved before k was persisted get the 1.0 default
+	})
+	registry.Register("WittenBell", func(SmootherParams) Smoother {
+		return NewWittenBellSmoother()
+	})
+	return registry
+}
+
+// Register adds or replaces the factory for a smoother name
+func (r *SmootherRegistry) Register(name string, factory SmootherFactory) {
+	r.factories[name] = factory
+}
+
+// Registered reports whether a smoother name has a factory
+func (r *SmootherRegistry) Registered(name string) bool {
+	_, ok := r.factories[name]
+	return ok
+}
+
+// Create returns a smoother by name, or an error for an unregistered name
+func (r *SmootherRegistry) Create(name string, params SmootherParams) (Smoother, error) {
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown smoother: %q", name)
+	}
+	return factory(params), nil
+}
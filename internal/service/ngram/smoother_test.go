@@ -0,0 +1,75 @@
+package ngram
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWittenBellProbabilities(t *testing.T) {
+	// Bigrams of "a b a c a b": ab, ba, ac, ca, ab. Vocabulary {a, b, c} gives
+	// a uniform backoff of 1/3. Context "a" occurs 3 times with 2 distinct
+	// continuations, context "b" once with 1, so for example
+	// P(b|a) = (2 + 2*(1/3)) / (3 + 2) = 8/15.
+	model := NewNGramModelTrie(2, NewWittenBellSmoother())
+	model.Add([]string{"a", "b", "a", "c", "a", "b"})
+
+	tests := []struct {
+		context string
+		token   string
+		want    float64
+	}{
+		{"a", "b", 8.0 / 15},
+		{"a", "c", 5.0 / 15},
+		{"a", "a", 2.0 / 15},
+		{"b", "a", 2.0 / 3},
+		{"b", "b", 1.0 / 6},
+		{"b", "c", 1.0 / 6},
+	}
+
+	for _, tt := range tests {
+		got := model.Probability(tt.token, []string{tt.context})
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("P(%s|%s) = %.6f, want %.6f", tt.token, tt.context, got, tt.want)
+		}
+	}
+
+	for _, context := range []string{"a", "b"} {
+		sum := 0.0
+		for _, token := range []string{"a", "b", "c"} {
+			sum += model.Probability(token, []string{context})
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("probabilities after %q sum to %.6f, want 1", context, sum)
+		}
+	}
+}
+
+func TestSmoothers(t *testing.T) {
+	tests := []struct {
+		name              string
+		smoother          Smoother
+		ngramCount        float64
+		contextCount      float64
+		continuationTypes int64
+		backoffProb       float64
+		vocabularySize    int
+		want              float64
+	}{
+		{"add-k seen", NewAddKSmoother(1.0), 2, 3, 2, 0.25, 4, 3.0 / 7},
+		{"add-k ignores continuation types", NewAddKSmoother(1.0), 2, 3, 99, 0.25, 4, 3.0 / 7},
+		{"add-k fractional counts", NewAddKSmoother(1.0), 0.5, 1.5, 1, 0.25, 4, 1.5 / 5.5},
+		{"add-k unseen context", NewAddKSmoother(1.0), 0, 0, 0, 0.25, 4, 0.25},
+		{"witten-bell unseen ngram", NewWittenBellSmoother(), 0, 4, 2, 0.1, 10, 0.2 / 6},
+		{"witten-bell unseen context", NewWittenBellSmoother(), 0, 0, 0, 0.1, 10, 0.1},
+		{"witten-bell no stored continuations", NewWittenBellSmoother(), 0, 4, 0, 0.1, 10, 0.1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.smoother.Smooth(tt.ngramCount, tt.contextCount, tt.continuationTypes, tt.backoffProb, tt.vocabularySize)
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Smooth() = %.6f, want %.6f", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package ngram
+
+import (
+	"bot-go/internal/config"
+	"fmt"
+)
+
+// ZScoreLevel describes how z-scores in one band of a ZScoreScale are reported
+type ZScoreLevel struct {
+	Label       string
+	Description string
+	Percentile  float64
+}
+
+// ZScoreScale maps z-scores to interpretation levels. Scores below
+// VeryLowBelow are very low, below LowBelow low, above VeryHighAbove very
+// high, above HighAbove high, and normal otherwise.
+type ZScoreScale struct {
+	Name          string
+	VeryLowBelow  float64
+	LowBelow      float64
+	HighAbove     float64
+	VeryHighAbove float64
+
+	VeryLow  ZScoreLevel
+	Low      ZScoreLevel
+	Normal   ZScoreLevel
+	High     ZScoreLevel
+	VeryHigh ZScoreLevel
+}
+
+// DefaultZScoreScale returns the scale with cutoffs at one and two standard
+// deviations from the mean
+func DefaultZScoreScale() ZScoreScale {
+	return ZScoreScale{
+		Name:          "default",
+		VeryLowBelow:  -2.0,
+		LowBelow:      -1.0,
+		HighAbove:     1.0,
+		VeryHighAbove: 2.0,
+		VeryLow: ZScoreLevel{
+			Label:       "very_low",
+			Description: "Extremely typical code - simpler than 97.5% of corpus",
+			Percentile:  2.5,
+		},
+		Low: ZScoreLevel{
+			Label:       "low",
+			Description: "More typical than average - simpler than 84% of corpus",
+			Percentile:  16.0,
+		},
+		Normal: ZScoreLevel{
+			Label:       "normal",
+			Description: "Normal entropy - within 1 standard deviation of mean",
+			Percentile:  50.0,
+		},
+		High: ZScoreLevel{
+			Label:       "high",
+			Description: "Unusual code - more complex than 84% of corpus",
+			Percentile:  84.0,
+		},
+		VeryHigh: ZScoreLevel{
+			Label:       "very_high",
+			Description: "Highly unusual code - more complex than 97.5% of corpus (potential bug indicator)",
+			Percentile:  97.5,
+		},
+	}
+}
+
+// NewZScoreScale applies a configured scale over the defaults. It returns an
+// error if the cutoffs are out of order or a level key is unknown.
+func NewZScoreScale(cfg config.ZScoreScaleConfig) (ZScoreScale, error) {
+	scale := DefaultZScoreScale()
+	scale.Name = cfg.Name
+	if scale.Name == "" {
+		scale.Name = "custom"
+	}
+
+	for _, cutoff := range []struct {
+		value  *float64
+		target *float64
+	}{
+		{cfg.VeryLowBelow, &scale.VeryLowBelow},
+		{cfg.LowBelow, &scale.LowBelow},
+		{cfg.HighAbove, &scale.HighAbove},
+		{cfg.VeryHighAbove, &scale.VeryHighAbove},
+	} {
+		if cutoff.value != nil {
+			*cutoff.target = *cutoff.value
+		}
+	}
+	if scale.VeryLowBelow > scale.LowBelow || scale.LowBelow > scale.HighAbove || scale.HighAbove > scale.VeryHighAbove {
+		return ZScoreScale{}, fmt.Errorf("z-score cutoffs must be ordered very_low_below <= low_below <= high_above <= very_high_above, got %g, %g, %g, %g",
+			scale.VeryLowBelow, scale.LowBelow, scale.HighAbove, scale.VeryHighAbove)
+	}
+
+	levels := map[string]*ZScoreLevel{
+		"very_low":  &scale.VeryLow,
+		"low":       &scale.Low,
+		"normal":    &scale.Normal,
+		"high":      &scale.High,
+		"very_high": &scale.VeryHigh,
+	}
+	for key, override := range cfg.Levels {
+		level, ok := levels[key]
+		if !ok {
+			return ZScoreScale{}, fmt.Errorf("unknown z-score level %q", key)
+		}
+		if override.Label != "" {
+			level.Label = override.Label
+		}
+		if override.Description != "" {
+			level.Description = override.Description
+		}
+		if override.Percentile != nil {
+			level.Percentile = *override.Percentile
+		}
+	}
+
+	return scale, nil
+}
+
+// Interpret provides a human-readable interpretation of a z-score
+func (s ZScoreScale) Interpret(zScore float64) ZScoreInterpretation {
+	var level ZScoreLevel
+	switch {
+	case zScore < s.VeryLowBelow:
+		level = s.VeryLow
+	case zScore < s.LowBelow:
+		level = s.Low
+	case zScore <= s.HighAbove:
+		level = s.Normal
+	case zScore <= s.VeryHighAbove:
+		level = s.High
+	default:
+		level = s.VeryHigh
+	}
+
+	return ZScoreInterpretation{
+		Level:       level.Label,
+		Description: level.Description,
+		Percentile:  level.Percentile,
+		Scale:       s.Name,
+	}
+}
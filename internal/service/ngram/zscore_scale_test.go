@@ -0,0 +1,76 @@
+package ngram
+
+import (
+	"testing"
+
+	"bot-go/internal/config"
+)
+
+func TestZScoreScaleInterpret(t *testing.T) {
+	veryHighAbove := 1.5
+	percentile := 90.0
+	strict, err := NewZScoreScale(config.ZScoreScaleConfig{
+		Name:          "strict",
+		VeryHighAbove: &veryHighAbove,
+		Levels: map[string]config.ZScoreLevelConfig{
+			"very_high": {Label: "suspicious", Percentile: &percentile},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewZScoreScale: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		scale     ZScoreScale
+		zScore    float64
+		wantLevel string
+	}{
+		{"default very low", DefaultZScoreScale(), -2.5, "very_low"},
+		{"default low at cutoff", DefaultZScoreScale(), -2.0, "low"},
+		{"default normal at cutoff", DefaultZScoreScale(), 1.0, "normal"},
+		{"default high", DefaultZScoreScale(), 1.7, "high"},
+		{"default high at cutoff", DefaultZScoreScale(), 2.0, "high"},
+		{"default very high", DefaultZScoreScale(), 2.1, "very_high"},
+		{"strict very high", strict, 1.7, "suspicious"},
+		{"strict high", strict, 1.2, "high"},
+		{"strict keeps low cutoffs", strict, -1.5, "low"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.scale.Interpret(tt.zScore)
+			if got.Level != tt.wantLevel || got.Scale != tt.scale.Name {
+				t.Errorf("Interpret(%v) = %s on %q, want %s on %q", tt.zScore, got.Level, got.Scale, tt.wantLevel, tt.scale.Name)
+			}
+		})
+	}
+
+	got := strict.Interpret(1.7)
+	if got.Percentile != 90 || got.Description != DefaultZScoreScale().VeryHigh.Description {
+		t.Errorf("strict very high = %+v, want percentile 90 and the default description", got)
+	}
+}
+
+func TestNewZScoreScaleRejectsInvalidConfig(t *testing.T) {
+	lowBelow := 1.5
+	tests := []struct {
+		name string
+		cfg  config.ZScoreScaleConfig
+	}{
+		{"cutoffs out of order", config.ZScoreScaleConfig{LowBelow: &lowBelow}},
+		{"unknown level", config.ZScoreScaleConfig{Levels: map[string]config.ZScoreLevelConfig{"extreme": {Label: "x"}}}},
+	}
+	for _, tt := range tests {
+		if _, err := NewZScoreScale(tt.cfg); err == nil {
+			t.Errorf("%s: NewZScoreScale succeeded, want an error", tt.name)
+		}
+	}
+
+	scale, err := NewZScoreScale(config.ZScoreScaleConfig{})
+	if err != nil {
+		t.Fatalf("NewZScoreScale(empty): %v", err)
+	}
+	if scale.Name != "custom" || scale.VeryHighAbove != 2 || scale.High.Label != "high" {
+		t.Errorf("empty config gave %+v, want the default cutoffs named custom", scale)
+	}
+}
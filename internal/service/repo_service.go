@@ -2,10 +2,16 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"bot-go/internal/config"
 	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
 	"bot-go/pkg/lsp"
+	"bot-go/pkg/lsp/base"
 
 	"go.uber.org/zap"
 )
@@ -14,6 +20,7 @@ type RepoService struct {
 	config     *config.Config
 	logger     *zap.Logger
 	lspService *lsp.LspService
+	codeGraph  *codegraph.CodeGraph
 }
 
 func NewRepoService(config *config.Config, logger *zap.Logger) *RepoService {
@@ -32,15 +39,136 @@ func (rs *RepoService) GetConfig() *config.Config {
 	return rs.config
 }
 
-func (rs *RepoService) GetFunctionDetails(repoName, relativePath, functionName string) (*model.GetFunctionDetailsResponse, error) {
-	return nil, nil
+// SetCodeGraph lets GetFunctionDetails fall back to the code graph for
+// repositories without a working language server
+func (rs *RepoService) SetCodeGraph(codeGraph *codegraph.CodeGraph) {
+	rs.codeGraph = codeGraph
+}
+
+// GetFunctionDetails describes a function using the repository's language
+// server. When the language server cannot, for instance because none is
+// installed for the repository, the details are read from the code graph
+// instead, if one is set. The response's Source tells which one answered.
+func (rs *RepoService) GetFunctionDetails(ctx context.Context, repoName, relativePath, functionName string) (*model.GetFunctionDetailsResponse, error) {
+	response := &model.GetFunctionDetailsResponse{
+		RepoName:     repoName,
+		FilePath:     relativePath,
+		FunctionName: functionName,
+	}
+
+	details, lspErr := rs.lspService.GetFunctionDetails(ctx, repoName, relativePath, functionName)
+	if lspErr == nil {
+		response.Source = model.FunctionDetailsSourceLSP
+		response.Details = *details
+		return response, nil
+	}
+	if rs.codeGraph == nil {
+		return nil, lspErr
+	}
+
+	rs.logger.Info("Language server could not describe function, reading it from the code graph",
+		zap.String("repo_name", repoName),
+		zap.String("relative_path", relativePath),
+		zap.String("function_name", functionName),
+		zap.Error(lspErr))
+
+	details, err := rs.functionDetailsFromGraph(ctx, repoName, relativePath, functionName)
+	if err != nil {
+		return nil, fmt.Errorf("language server: %v; code graph: %w", lspErr, err)
+	}
+	response.Source = model.FunctionDetailsSourceGraph
+	response.Details = *details
+	return response, nil
+}
+
+// functionDetailsFromGraph describes a function from its code graph node: its
+// range, its signature as recorded at parse time and its containing class.
+// Parameter types are not recorded in the graph and are left empty.
+func (rs *RepoService) functionDetailsFromGraph(ctx context.Context, repoName, relativePath, functionName string) (*model.FunctionDetails, error) {
+	filePath := relativePath
+	if repo, err := rs.config.GetRepository(repoName); err == nil {
+		filePath = util.NewPathNormalizer(repo.Path, false).Normalize(relativePath)
+	}
+
+	node, class, err := rs.findGraphFunction(ctx, repoName, filePath, functionName)
+	if err != nil {
+		return nil, err
+	}
+
+	args, err := rs.codeGraph.ReadFunctionArgs(ctx, node.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read arguments of function '%s': %w", functionName, err)
+	}
+	parameters := make([]model.Parameter, 0, len(args))
+	argNames := make([]string, 0, len(args))
+	for _, arg := range args {
+		parameters = append(parameters, model.Parameter{Name: arg.Name})
+		argNames = append(argNames, arg.Name)
+	}
+
+	params, _ := node.MetaData["params"].(string)
+	if params == "" {
+		params = "(" + strings.Join(argNames, ", ") + ")"
+	}
+	returnType, _ := node.MetaData["return_type"].(string)
+	signature := node.Name + params
+	if returnType != "" {
+		signature += " " + returnType
+	}
+
+	details := &model.FunctionDetails{
+		Name:       node.Name,
+		Signature:  signature,
+		Parameters: parameters,
+		ReturnType: returnType,
+		Location:   base.Location{URI: filePath, Range: node.Range},
+	}
+	if class != nil {
+		details.ContainingClass = class.Name
+	}
+	return details, nil
+}
+
+// findGraphFunction finds a function of a file in the code graph, together
+// with its containing class if it has one. A name of the form Class.method
+// selects the method of that class.
+func (rs *RepoService) findGraphFunction(ctx context.Context, repoName, filePath, functionName string) (*ast.Node, *ast.Node, error) {
+	fileScopes, err := rs.codeGraph.FindFileScopes(ctx, repoName, filePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to find file in code graph: %w", err)
+	}
+	if len(fileScopes) == 0 {
+		return nil, nil, fmt.Errorf("%s has not been indexed for repository %s", filePath, repoName)
+	}
+
+	className, name := "", functionName
+	if dot := strings.LastIndex(functionName, "."); dot >= 0 {
+		className, name = functionName[:dot], functionName[dot+1:]
+	}
+
+	for _, fileScope := range fileScopes {
+		candidates, err := rs.codeGraph.FindFunctionsByName(ctx, int(fileScope.FileID), name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to find function '%s': %w", functionName, err)
+		}
+		for _, candidate := range candidates {
+			class, err := rs.codeGraph.GetContainingClass(ctx, candidate.ID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to read class of function '%s': %w", functionName, err)
+			}
+			if className == "" || (class != nil && class.Name == className) {
+				return candidate, class, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("function '%s' not found in file '%s'", functionName, filePath)
 }
 
 func (rs *RepoService) GetFunctionDependencies(ctx context.Context, repoName, relativePath, functionName string, depth int) (*model.CallGraph, error) {
 	return rs.lspService.GetFunctionDependencies(ctx, repoName, relativePath, functionName, depth)
 }
 
-func (rs *RepoService) GetFunctionHovers(ctx context.Context, repoName string, functions []model.FunctionDefinition) ([]string, error) {
+func (rs *RepoService) GetFunctionHovers(ctx context.Context, repoName string, functions []model.FunctionDefinition) ([]lsp.HoverResult, error) {
 	return rs.lspService.GetFunctionHovers(ctx, repoName, functions)
 }
 
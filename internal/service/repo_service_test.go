@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+func TestGetFunctionDetailsFallsBackToCodeGraph(t *testing.T) {
+	// File 3 (scope 1) holds class Service (2) with method Run (10) taking
+	// ctx (11), and a function Run (20) outside the class
+	node := func(id int64, nodeType ast.NodeType, name string, properties map[string]any) map[string]any {
+		record := map[string]any{"id": id, "nodeType": int64(nodeType), "fileId": int64(3), "name": name,
+			"range": "(4,0)-(9,1)", "version": int64(0), "scopeId": int64(1)}
+		for key, value := range properties {
+			record[key] = value
+		}
+		return record
+	}
+	method := node(10, ast.NodeTypeFunction, "Run", map[string]any{"params": "(self, ctx)", "return_type": "bool"})
+	function := node(20, ast.NodeTypeFunction, "Run", nil)
+
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		switch {
+		case strings.Contains(query, "MATCH (n:FileScope)"):
+			if params["path"] != "svc/service.py" {
+				return nil, nil
+			}
+			return []map[string]any{{"n": node(1, ast.NodeTypeFileScope, "", nil)}}, nil
+		case strings.Contains(query, "MATCH (n:Function)"):
+			return []map[string]any{{"n": function}, {"n": method}}, nil
+		case strings.Contains(query, "FUNCTION_ARG"):
+			if params["functionId"] != int64(10) {
+				return nil, nil
+			}
+			return []map[string]any{{"arg": node(11, ast.NodeTypeVariable, "ctx", nil)}}, nil
+		case strings.Contains(query, "(c:Class)-[:CONTAINS]->"):
+			if params["methodId"] != int64(10) {
+				return nil, nil
+			}
+			return []map[string]any{{"c": node(2, ast.NodeTypeClass, "Service", nil)}}, nil
+		}
+		return nil, nil
+	}
+
+	// No repository is configured, so no language server can be started
+	rs := NewRepoService(&config.Config{}, zap.NewNop())
+	if _, err := rs.GetFunctionDetails(context.Background(), "demo", "svc/service.py", "Run"); err == nil {
+		t.Fatal("GetFunctionDetails without language server or code graph succeeded")
+	}
+	rs.SetCodeGraph(codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, zap.NewNop()))
+
+	response, err := rs.GetFunctionDetails(context.Background(), "demo", "svc/service.py", "Service.Run")
+	if err != nil {
+		t.Fatalf("GetFunctionDetails: %v", err)
+	}
+	if response.Source != model.FunctionDetailsSourceGraph {
+		t.Errorf("source = %q, want %q", response.Source, model.FunctionDetailsSourceGraph)
+	}
+	details := response.Details
+	if details.Name != "Run" || details.ContainingClass != "Service" {
+		t.Errorf("details = %+v, want method Run of Service", details)
+	}
+	if details.Signature != "Run(self, ctx) bool" || details.ReturnType != "bool" {
+		t.Errorf("signature = %q, return type = %q", details.Signature, details.ReturnType)
+	}
+	if want := []model.Parameter{{Name: "ctx"}}; !reflect.DeepEqual(details.Parameters, want) {
+		t.Errorf("parameters = %+v, want %+v", details.Parameters, want)
+	}
+	if details.Location.URI != "svc/service.py" || details.Location.Range.Start.Line != 4 {
+		t.Errorf("location = %+v", details.Location)
+	}
+
+	// Without a class the first function of that name is described, with a
+	// signature built from its arguments
+	response, err = rs.GetFunctionDetails(context.Background(), "demo", "svc/service.py", "Run")
+	if err != nil {
+		t.Fatalf("GetFunctionDetails: %v", err)
+	}
+	if response.Details.ContainingClass != "" || response.Details.Signature != "Run()" {
+		t.Errorf("details = %+v, want the function outside the class", response.Details)
+	}
+
+	if _, err := rs.GetFunctionDetails(context.Background(), "demo", "other.py", "Run"); err == nil {
+		t.Error("GetFunctionDetails of an unindexed file succeeded")
+	}
+}
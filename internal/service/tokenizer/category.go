@@ -0,0 +1,151 @@
+package tokenizer
+
+import (
+	"bot-go/internal/model/ngram"
+	"strings"
+	"unicode"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// languageRules maps a grammar's node kinds to token categories. Anonymous
+// nodes (keywords, operators and punctuation spelled out in the grammar) are
+// categorized from their text, so only named kinds need to be listed.
+type languageRules struct {
+	identifiers map[string]bool // Named kinds for user-chosen names
+	strings     map[string]bool // String-like literals, emitted as one token even if they have children
+	numbers     map[string]bool // Numeric literals
+	keywords    map[string]bool // Named kinds that are reserved words (e.g. true, nil)
+	comments    map[string]bool // Kinds that are skipped
+	blocks      map[string]bool // Indented block kinds, wrapped in INDENT and DEDENT tokens
+}
+
+// Synthetic tokens marking where an indented block opens and closes
+const (
+	indentType = "indent"
+	dedentType = "dedent"
+)
+
+// punctuation lists the separators that are not operators in any supported language
+var punctuation = map[string]bool{
+	"(": true, ")": true, "[": true, "]": true, "{": true, "}": true,
+	",": true, ";": true, ".": true, ":": true,
+}
+
+func kindSet(kinds ...string) map[string]bool {
+	set := make(map[string]bool, len(kinds))
+	for _, kind := range kinds {
+		set[kind] = true
+	}
+	return set
+}
+
+// walkTokens emits the leaf tokens under node in source order, stopping at the
+// first error returned by emit
+func (r *languageRules) walkTokens(node *tree_sitter.Node, source []byte, emit func(ngram.Token) error) error {
+	if node == nil {
+		return nil
+	}
+
+	kind := node.Kind()
+	if r.comments[kind] {
+		return nil
+	}
+
+	// Leaves and whole string literals become tokens
+	if node.ChildCount() == 0 || r.strings[kind] {
+		content := node.Utf8Text(source)
+		if content == "" {
+			return nil
+		}
+
+		startPoint := node.StartPosition()
+		return emit(ngram.Token{
+			Type:     kind,
+			Category: r.categorize(kind, content, node.IsNamed()),
+			Value:    content,
+			Line:     int(startPoint.Row) + 1,
+			Column:   int(startPoint.Column) + 1,
+		})
+	}
+
+	indented := r.blocks[kind] && startsOnNewLine(node)
+	if indented {
+		if err := emit(blockToken(indentType, node.StartPosition())); err != nil {
+			return err
+		}
+	}
+
+	for i := uint(0); i < node.ChildCount(); i++ {
+		if err := r.walkTokens(node.Child(i), source, emit); err != nil {
+			return err
+		}
+	}
+
+	if indented {
+		return emit(blockToken(dedentType, node.EndPosition()))
+	}
+	return nil
+}
+
+// startsOnNewLine reports whether a block begins on a later line than the
+// token before it; a body on the same line as its header (if x: pass) is not
+// indented
+func startsOnNewLine(node *tree_sitter.Node) bool {
+	prev := node.PrevSibling()
+	return prev == nil || node.StartPosition().Row > prev.EndPosition().Row
+}
+
+// blockToken creates an INDENT or DEDENT token at a block boundary
+func blockToken(tokenType string, point tree_sitter.Point) ngram.Token {
+	return ngram.Token{
+		Type:     tokenType,
+		Category: ngram.CategoryOther,
+		Value:    strings.ToUpper(tokenType),
+		Line:     int(point.Row) + 1,
+		Column:   int(point.Column) + 1,
+	}
+}
+
+// categorize assigns a category to a leaf token
+func (r *languageRules) categorize(kind, content string, named bool) ngram.TokenCategory {
+	switch {
+	case r.identifiers[kind]:
+		return ngram.CategoryIdentifier
+	case r.strings[kind]:
+		return ngram.CategoryString
+	case r.numbers[kind]:
+		return ngram.CategoryNumber
+	case r.keywords[kind]:
+		return ngram.CategoryKeyword
+	case named:
+		return ngram.CategoryOther
+	case punctuation[content]:
+		return ngram.CategoryPunctuation
+	case isWord(content):
+		return ngram.CategoryKeyword
+	default:
+		return ngram.CategoryOperator
+	}
+}
+
+func isWord(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '_'
+	}) == -1
+}
+
+// normalizeToken abstracts identifiers and literals while keeping keywords,
+// operators and punctuation verbatim
+func normalizeToken(token ngram.Token) string {
+	switch token.Category {
+	case ngram.CategoryIdentifier:
+		return "ID"
+	case ngram.CategoryString:
+		return "STR"
+	case ngram.CategoryNumber:
+		return "NUM"
+	default:
+		return token.Value
+	}
+}
@@ -0,0 +1,237 @@
+package tokenizer
+
+import (
+	"bot-go/internal/model/ngram"
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token types produced by GenericTokenizer
+const (
+	genericIdentifierType  = "identifier"
+	genericKeywordType     = "keyword"
+	genericStringType      = "string"
+	genericNumberType      = "number"
+	genericOperatorType    = "operator"
+	genericPunctuationType = "punctuation"
+)
+
+// genericKeywords are reserved words shared by many C-like and scripting
+// languages. Without a grammar a keyword cannot be told from an identifier,
+// so these are kept verbatim to preserve some structure after normalization.
+var genericKeywords = kindSet(
+	"if", "else", "elif", "elsif", "unless", "then", "for", "foreach", "while", "loop", "do", "end",
+	"switch", "case", "when", "match", "default", "break", "continue", "return", "yield",
+	"try", "catch", "finally", "throw", "rescue", "ensure", "begin", "raise",
+	"fn", "func", "function", "def", "lambda", "class", "struct", "enum", "trait", "impl", "interface",
+	"module", "namespace", "package", "import", "use", "using", "require", "mod",
+	"let", "var", "val", "const", "mut", "static", "public", "private", "protected", "pub",
+	"new", "this", "self", "super", "nil", "null", "true", "false", "in", "as", "is",
+	"async", "await", "where", "void",
+)
+
+// genericOperators are the multi-character operators recognized before
+// falling back to single characters, longest first
+var genericOperators = []string{
+	"<<=", ">>=", "**=", "...", "..=", "===", "!==", "<=>",
+	"::", "->", "=>", "==", "!=", "<=", ">=", "&&", "||", "++", "--",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=", "<<", ">>", "..", "**", "?.", "??",
+}
+
+// GenericTokenizer lexes source code without a grammar, for languages that
+// have no dedicated tokenizer. It recognizes identifiers, numbers, quoted
+// strings, operators and punctuation, and skips //, /* */ and # comments.
+type GenericTokenizer struct{}
+
+// NewGenericTokenizer creates a new language-agnostic tokenizer
+func NewGenericTokenizer() *GenericTokenizer {
+	return &GenericTokenizer{}
+}
+
+func (t *GenericTokenizer) Tokenize(ctx context.Context, source []byte) (ngram.TokenSequence, error) {
+	var tokens ngram.TokenSequence
+	err := t.lex(ctx, source, func(token ngram.Token) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// TokenizeStream reads the whole source, since strings and comments may span lines
+func (t *GenericTokenizer) TokenizeStream(ctx context.Context, source io.Reader, emit func(ngram.Token) error) error {
+	content, err := readSource(source, t.Language())
+	if err != nil {
+		return err
+	}
+	return t.lex(ctx, content, emit)
+}
+
+// Normalize abstracts identifiers and literals while keeping keywords,
+// operators and punctuation verbatim
+func (t *GenericTokenizer) Normalize(token ngram.Token) string {
+	return normalizeToken(token)
+}
+
+func (t *GenericTokenizer) Language() string {
+	return "generic"
+}
+
+// lex emits the tokens of source in order, stopping at the first error
+// returned by emit
+func (t *GenericTokenizer) lex(ctx context.Context, source []byte, emit func(ngram.Token) error) error {
+	line, lineStart := 1, 0
+	// advance moves past source[start:end], tracking line starts
+	advance := func(start, end int) {
+		for i := start; i < end; i++ {
+			if source[i] == '\n' {
+				line, lineStart = line+1, i+1
+			}
+		}
+	}
+
+	for i := 0; i < len(source); {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		r, size := utf8.DecodeRune(source[i:])
+		if unicode.IsSpace(r) {
+			advance(i, i+size)
+			i += size
+			continue
+		}
+		if end := genericCommentEnd(source, i); end > i {
+			advance(i, end)
+			i = end
+			continue
+		}
+
+		token := ngram.Token{Line: line, Column: i - lineStart + 1}
+		end := i + size
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+			end = i + wordLength(source[i:])
+			token.Value = string(source[i:end])
+			if genericKeywords[token.Value] {
+				token.Type, token.Category = genericKeywordType, ngram.CategoryKeyword
+			} else {
+				token.Type, token.Category = genericIdentifierType, ngram.CategoryIdentifier
+			}
+		case unicode.IsDigit(r):
+			end = i + numberLength(source[i:])
+			token.Value = string(source[i:end])
+			token.Type, token.Category = genericNumberType, ngram.CategoryNumber
+		case r == '"' || r == '\'' || r == '`':
+			if stringEnd := genericStringEnd(source, i); stringEnd > i && !isLifetimeQuote(source, i) {
+				end = stringEnd
+				token.Value = string(source[i:end])
+				token.Type, token.Category = genericStringType, ngram.CategoryString
+				break
+			}
+			// An unterminated quote or a Rust lifetime is an operator
+			token.Value = string(r)
+			token.Type, token.Category = genericOperatorType, ngram.CategoryOperator
+		default:
+			token.Value = string(source[i:end])
+			for _, op := range genericOperators {
+				if bytes.HasPrefix(source[i:], []byte(op)) {
+					token.Value, end = op, i+len(op)
+					break
+				}
+			}
+			if punctuation[token.Value] {
+				token.Type, token.Category = genericPunctuationType, ngram.CategoryPunctuation
+			} else {
+				token.Type, token.Category = genericOperatorType, ngram.CategoryOperator
+			}
+		}
+
+		if err := emit(token); err != nil {
+			return err
+		}
+		advance(i, end)
+		i = end
+	}
+	return nil
+}
+
+// genericCommentEnd returns the end of a comment starting at i, or i if none
+// starts there. A # followed by [ or ! opens a Rust attribute, not a comment.
+func genericCommentEnd(source []byte, i int) int {
+	rest := source[i:]
+	switch {
+	case bytes.HasPrefix(rest, []byte("//")),
+		rest[0] == '#' && !bytes.HasPrefix(rest, []byte("#[")) && !bytes.HasPrefix(rest, []byte("#!")):
+		if end := bytes.IndexByte(rest, '\n'); end >= 0 {
+			return i + end
+		}
+		return len(source)
+	case bytes.HasPrefix(rest, []byte("/*")):
+		if end := bytes.Index(rest[2:], []byte("*/")); end >= 0 {
+			return i + 2 + end + 2
+		}
+		return len(source)
+	default:
+		return i
+	}
+}
+
+// genericStringEnd returns the end of the quoted string starting at i, or i
+// if it is unterminated. Single-quoted strings must close on the same line.
+func genericStringEnd(source []byte, i int) int {
+	quote := source[i]
+	for j := i + 1; j < len(source); j++ {
+		switch source[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n':
+			if quote == '\'' {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// isLifetimeQuote reports whether the quote at i opens a Rust lifetime such
+// as &'a or <'a rather than a string
+func isLifetimeQuote(source []byte, i int) bool {
+	return source[i] == '\'' && i > 0 && (source[i-1] == '&' || source[i-1] == '<')
+}
+
+// wordLength returns the length of the identifier at the start of source
+func wordLength(source []byte) int {
+	end := bytes.IndexFunc(source, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+	if end < 0 {
+		return len(source)
+	}
+	return end
+}
+
+// numberLength returns the length of the numeric literal at the start of
+// source, including radix prefixes, digit separators, a fraction and suffixes
+// such as 10u32
+func numberLength(source []byte) int {
+	end := 0
+	for end < len(source) {
+		c := source[end]
+		isFraction := c == '.' && end+1 < len(source) && source[end+1] >= '0' && source[end+1] <= '9'
+		if !isFraction && c != '_' && !strings.ContainsRune("0123456789abcdefABCDEFxXoOlLuUiIsSzZ", rune(c)) {
+			break
+		}
+		end++
+	}
+	return end
+}
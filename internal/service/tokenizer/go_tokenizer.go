@@ -4,6 +4,7 @@ import (
 	"bot-go/internal/model/ngram"
 	"context"
 	"fmt"
+	"io"
 	"sync"
 
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
@@ -17,6 +18,15 @@ type GoTokenizer struct {
 	mu       sync.Mutex // Protects parser (tree-sitter parsers are not thread-safe)
 }
 
+// goRules categorizes tree-sitter-go node kinds
+var goRules = &languageRules{
+	identifiers: kindSet("identifier", "field_identifier", "type_identifier", "package_identifier", "label_name"),
+	strings:     kindSet("interpreted_string_literal", "raw_string_literal", "rune_literal"),
+	numbers:     kindSet("int_literal", "float_literal", "imaginary_literal"),
+	keywords:    kindSet("true", "false", "nil", "iota"),
+	comments:    kindSet("comment"),
+}
+
 // NewGoTokenizer creates a new Go tokenizer
 func NewGoTokenizer() (*GoTokenizer, error) {
 	parser := tree_sitter.NewParser()
@@ -34,75 +44,31 @@ func NewGoTokenizer() (*GoTokenizer, error) {
 }
 
 func (t *GoTokenizer) Tokenize(ctx context.Context, source []byte) (ngram.TokenSequence, error) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
-	tree := t.parser.Parse(source, nil)
-	if tree == nil {
-		return nil, fmt.Errorf("failed to parse Go source")
-	}
-	defer tree.Close()
-
-	rootNode := tree.RootNode()
 	var tokens ngram.TokenSequence
-
-	t.traverseNode(rootNode, source, &tokens)
+	err := parseTokens(ctx, t.parser, &t.mu, goRules, "Go", source, func(token ngram.Token) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return tokens, nil
 }
 
-func (t *GoTokenizer) traverseNode(node *tree_sitter.Node, source []byte, tokens *ngram.TokenSequence) {
-	if node == nil {
-		return
-	}
-
-	// If this is a leaf node (no children), extract the token
-	if node.ChildCount() == 0 {
-		nodeType := node.Kind()
-		content := node.Utf8Text(source)
-
-		// Skip empty tokens and whitespace
-		if content == "" || nodeType == "comment" {
-			return
-		}
-
-		startPoint := node.StartPosition()
-		token := ngram.Token{
-			Type:   nodeType,
-			Value:  content,
-			Line:   int(startPoint.Row) + 1,
-			Column: int(startPoint.Column) + 1,
-		}
-		*tokens = append(*tokens, token)
-		return
+// TokenizeStream emits tokens while walking the parse tree instead of collecting them
+func (t *GoTokenizer) TokenizeStream(ctx context.Context, source io.Reader, emit func(ngram.Token) error) error {
+	content, err := readSource(source, "Go")
+	if err != nil {
+		return err
 	}
 
-	// Recursively traverse children
-	for i := uint(0); i < node.ChildCount(); i++ {
-		child := node.Child(i)
-		t.traverseNode(child, source, tokens)
-	}
+	return parseTokens(ctx, t.parser, &t.mu, goRules, "Go", content, emit)
 }
 
+// Normalize maps identifiers to ID and string and numeric literals to STR and NUM
 func (t *GoTokenizer) Normalize(token ngram.Token) string {
-	// Normalize based on token type
-	switch token.Type {
-	case "identifier":
-		return "ID"
-	case "int_literal", "float_literal", "imaginary_literal":
-		return "NUM"
-	case "raw_string_literal", "interpreted_string_literal":
-		return "STR"
-	case "rune_literal":
-		return "CHAR"
-	case "true", "false":
-		return "BOOL"
-	case "nil":
-		return "NIL"
-	default:
-		// Return the actual value for keywords, operators, and punctuation
-		return token.Value
-	}
+	return normalizeToken(token)
 }
 
 func (t *GoTokenizer) Language() string {
@@ -4,6 +4,7 @@ import (
 	"bot-go/internal/model/ngram"
 	"context"
 	"fmt"
+	"io"
 	"sync"
 
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
@@ -17,6 +18,16 @@ type JavaTokenizer struct {
 	mu       sync.Mutex // Protects parser (tree-sitter parsers are not thread-safe)
 }
 
+// javaRules categorizes tree-sitter-java node kinds
+var javaRules = &languageRules{
+	identifiers: kindSet("identifier", "type_identifier"),
+	strings:     kindSet("string_literal", "character_literal"),
+	numbers: kindSet("decimal_integer_literal", "hex_integer_literal", "octal_integer_literal",
+		"binary_integer_literal", "decimal_floating_point_literal", "hex_floating_point_literal"),
+	keywords: kindSet("true", "false", "null_literal", "this", "super"),
+	comments: kindSet("comment", "line_comment", "block_comment"),
+}
+
 // NewJavaTokenizer creates a new Java tokenizer
 func NewJavaTokenizer() (*JavaTokenizer, error) {
 	parser := tree_sitter.NewParser()
@@ -34,74 +45,31 @@ func NewJavaTokenizer() (*JavaTokenizer, error) {
 }
 
 func (t *JavaTokenizer) Tokenize(ctx context.Context, source []byte) (ngram.TokenSequence, error) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
-	tree := t.parser.Parse(source, nil)
-	if tree == nil {
-		return nil, fmt.Errorf("failed to parse Java source")
-	}
-	defer tree.Close()
-
-	rootNode := tree.RootNode()
 	var tokens ngram.TokenSequence
-
-	t.traverseNode(rootNode, source, &tokens)
+	err := parseTokens(ctx, t.parser, &t.mu, javaRules, "Java", source, func(token ngram.Token) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return tokens, nil
 }
 
-func (t *JavaTokenizer) traverseNode(node *tree_sitter.Node, source []byte, tokens *ngram.TokenSequence) {
-	if node == nil {
-		return
-	}
-
-	// If this is a leaf node (no children), extract the token
-	if node.ChildCount() == 0 {
-		nodeType := node.Kind()
-		content := node.Utf8Text(source)
-
-		// Skip empty tokens, whitespace, and comments
-		if content == "" || nodeType == "comment" || nodeType == "line_comment" || nodeType == "block_comment" {
-			return
-		}
-
-		startPoint := node.StartPosition()
-		token := ngram.Token{
-			Type:   nodeType,
-			Value:  content,
-			Line:   int(startPoint.Row) + 1,
-			Column: int(startPoint.Column) + 1,
-		}
-		*tokens = append(*tokens, token)
-		return
+// TokenizeStream emits tokens while walking the parse tree instead of collecting them
+func (t *JavaTokenizer) TokenizeStream(ctx context.Context, source io.Reader, emit func(ngram.Token) error) error {
+	content, err := readSource(source, "Java")
+	if err != nil {
+		return err
 	}
 
-	// Recursively traverse children
-	for i := uint(0); i < node.ChildCount(); i++ {
-		child := node.Child(i)
-		t.traverseNode(child, source, tokens)
-	}
+	return parseTokens(ctx, t.parser, &t.mu, javaRules, "Java", content, emit)
 }
 
+// Normalize maps identifiers to ID and string and numeric literals to STR and NUM
 func (t *JavaTokenizer) Normalize(token ngram.Token) string {
-	// Normalize based on token type
-	switch token.Type {
-	case "identifier", "type_identifier":
-		return "ID"
-	case "decimal_integer_literal", "hex_integer_literal", "octal_integer_literal",
-		"binary_integer_literal", "decimal_floating_point_literal", "hex_floating_point_literal":
-		return "NUM"
-	case "string_literal", "character_literal":
-		return "STR"
-	case "true", "false":
-		return "BOOL"
-	case "null":
-		return "NULL"
-	default:
-		// Return the actual value for keywords, operators, and punctuation
-		return token.Value
-	}
+	return normalizeToken(token)
 }
 
 func (t *JavaTokenizer) Language() string {
@@ -4,6 +4,7 @@ import (
 	"bot-go/internal/model/ngram"
 	"context"
 	"fmt"
+	"io"
 	"sync"
 
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
@@ -17,6 +18,16 @@ type JavaScriptTokenizer struct {
 	mu       sync.Mutex // Protects parser (tree-sitter parsers are not thread-safe)
 }
 
+// javascriptRules categorizes tree-sitter-javascript node kinds
+var javascriptRules = &languageRules{
+	identifiers: kindSet("identifier", "property_identifier", "shorthand_property_identifier",
+		"shorthand_property_identifier_pattern", "private_property_identifier", "statement_identifier"),
+	strings:  kindSet("string", "template_string", "regex"),
+	numbers:  kindSet("number"),
+	keywords: kindSet("true", "false", "null", "undefined", "this", "super"),
+	comments: kindSet("comment"),
+}
+
 // NewJavaScriptTokenizer creates a new JavaScript tokenizer
 func NewJavaScriptTokenizer() (*JavaScriptTokenizer, error) {
 	parser := tree_sitter.NewParser()
@@ -34,77 +45,31 @@ func NewJavaScriptTokenizer() (*JavaScriptTokenizer, error) {
 }
 
 func (t *JavaScriptTokenizer) Tokenize(ctx context.Context, source []byte) (ngram.TokenSequence, error) {
-	t.mu.Lock()
-	defer t.mu.Unlock()
-
-	tree := t.parser.Parse(source, nil)
-	if tree == nil {
-		return nil, fmt.Errorf("failed to parse JavaScript source")
-	}
-	defer tree.Close()
-
-	rootNode := tree.RootNode()
 	var tokens ngram.TokenSequence
-
-	t.traverseNode(rootNode, source, &tokens)
+	err := parseTokens(ctx, t.parser, &t.mu, javascriptRules, "JavaScript", source, func(token ngram.Token) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return tokens, nil
 }
 
-func (t *JavaScriptTokenizer) traverseNode(node *tree_sitter.Node, source []byte, tokens *ngram.TokenSequence) {
-	if node == nil {
-		return
-	}
-
-	// If this is a leaf node (no children), extract the token
-	if node.ChildCount() == 0 {
-		nodeType := node.Kind()
-		content := node.Utf8Text(source)
-
-		// Skip empty tokens, whitespace, and comments
-		if content == "" || nodeType == "comment" {
-			return
-		}
-
-		startPoint := node.StartPosition()
-		token := ngram.Token{
-			Type:   nodeType,
-			Value:  content,
-			Line:   int(startPoint.Row) + 1,
-			Column: int(startPoint.Column) + 1,
-		}
-		*tokens = append(*tokens, token)
-		return
+// TokenizeStream emits tokens while walking the parse tree instead of collecting them
+func (t *JavaScriptTokenizer) TokenizeStream(ctx context.Context, source io.Reader, emit func(ngram.Token) error) error {
+	content, err := readSource(source, "JavaScript")
+	if err != nil {
+		return err
 	}
 
-	// Recursively traverse children
-	for i := uint(0); i < node.ChildCount(); i++ {
-		child := node.Child(i)
-		t.traverseNode(child, source, tokens)
-	}
+	return parseTokens(ctx, t.parser, &t.mu, javascriptRules, "JavaScript", content, emit)
 }
 
+// Normalize maps identifiers to ID and string and numeric literals to STR and NUM
 func (t *JavaScriptTokenizer) Normalize(token ngram.Token) string {
-	// Normalize based on token type
-	switch token.Type {
-	case "identifier":
-		return "ID"
-	case "number":
-		return "NUM"
-	case "string", "template_string":
-		return "STR"
-	case "regex":
-		return "REGEX"
-	case "true", "false":
-		return "BOOL"
-	case "null":
-		return "NULL"
-	case "undefined":
-		return "UNDEF"
-	default:
-		// Return the actual value for keywords, operators, and punctuation
-		return token.Value
-	}
+	return normalizeToken(token)
 }
 
 func (t *JavaScriptTokenizer) Language() string {
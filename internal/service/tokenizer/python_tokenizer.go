@@ -4,6 +4,7 @@ import (
 	"bot-go/internal/model/ngram"
 	"context"
 	"fmt"
+	"io"
 	"sync"
 
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
@@ -12,11 +13,28 @@ import (
 
 // PythonTokenizer implements tokenization for Python source code
 type PythonTokenizer struct {
-	parser   *tree_sitter.Parser
-	language *tree_sitter.Language
-	mu       sync.Mutex // Protects parser (tree-sitter parsers are not thread-safe)
+	parser       *tree_sitter.Parser
+	language     *tree_sitter.Language
+	mu           sync.Mutex // Protects parser (tree-sitter parsers are not thread-safe)
+	indentTokens bool       // Emit INDENT and DEDENT tokens around indented blocks
 }
 
+// pythonRules categorizes tree-sitter-python node kinds
+var pythonRules = &languageRules{
+	identifiers: kindSet("identifier"),
+	strings:     kindSet("string"),
+	numbers:     kindSet("integer", "float"),
+	keywords:    kindSet("true", "false", "none"),
+	comments:    kindSet("comment"),
+}
+
+// pythonIndentRules also wraps indented blocks in INDENT and DEDENT tokens
+var pythonIndentRules = func() *languageRules {
+	rules := *pythonRules
+	rules.blocks = kindSet("block")
+	return &rules
+}()
+
 // NewPythonTokenizer creates a new Python tokenizer
 func NewPythonTokenizer() (*PythonTokenizer, error) {
 	parser := tree_sitter.NewParser()
@@ -33,74 +51,57 @@ func NewPythonTokenizer() (*PythonTokenizer, error) {
 	}, nil
 }
 
-func (t *PythonTokenizer) Tokenize(ctx context.Context, source []byte) (ngram.TokenSequence, error) {
+// SetIndentTokens selects whether indented blocks are wrapped in synthetic
+// INDENT and DEDENT tokens, so the model sees Python's block structure. Models
+// built with and without them are not comparable.
+func (t *PythonTokenizer) SetIndentTokens(enabled bool) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.indentTokens = enabled
+}
 
-	tree := t.parser.Parse(source, nil)
-	if tree == nil {
-		return nil, fmt.Errorf("failed to parse Python source")
+// rules returns the rules for the current indent token setting; callers hold t.mu
+func (t *PythonTokenizer) rules() *languageRules {
+	if t.indentTokens {
+		return pythonIndentRules
 	}
-	defer tree.Close()
+	return pythonRules
+}
 
-	rootNode := tree.RootNode()
+func (t *PythonTokenizer) Tokenize(ctx context.Context, source []byte) (ngram.TokenSequence, error) {
 	var tokens ngram.TokenSequence
-
-	t.traverseNode(rootNode, source, &tokens)
+	err := t.parse(ctx, source, func(token ngram.Token) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	return tokens, nil
 }
 
-func (t *PythonTokenizer) traverseNode(node *tree_sitter.Node, source []byte, tokens *ngram.TokenSequence) {
-	if node == nil {
-		return
+// TokenizeStream emits tokens while walking the parse tree instead of collecting them
+func (t *PythonTokenizer) TokenizeStream(ctx context.Context, source io.Reader, emit func(ngram.Token) error) error {
+	content, err := readSource(source, "Python")
+	if err != nil {
+		return err
 	}
 
-	// If this is a leaf node (no children), extract the token
-	if node.ChildCount() == 0 {
-		nodeType := node.Kind()
-		content := node.Utf8Text(source)
-
-		// Skip empty tokens, whitespace, and comments
-		if content == "" || nodeType == "comment" {
-			return
-		}
-
-		startPoint := node.StartPosition()
-		token := ngram.Token{
-			Type:   nodeType,
-			Value:  content,
-			Line:   int(startPoint.Row) + 1,
-			Column: int(startPoint.Column) + 1,
-		}
-		*tokens = append(*tokens, token)
-		return
-	}
+	return t.parse(ctx, content, emit)
+}
 
-	// Recursively traverse children
-	for i := uint(0); i < node.ChildCount(); i++ {
-		child := node.Child(i)
-		t.traverseNode(child, source, tokens)
-	}
+func (t *PythonTokenizer) parse(ctx context.Context, source []byte, emit func(ngram.Token) error) error {
+	t.mu.Lock()
+	rules := t.rules()
+	t.mu.Unlock()
+	return parseTokens(ctx, t.parser, &t.mu, rules, "Python", source, emit)
 }
 
+// Normalize maps identifiers to ID and string and numeric literals to STR and
+// NUM; INDENT and DEDENT are kept as is
 func (t *PythonTokenizer) Normalize(token ngram.Token) string {
-	// Normalize based on token type
-	switch token.Type {
-	case "identifier":
-		return "ID"
-	case "integer", "float":
-		return "NUM"
-	case "string":
-		return "STR"
-	case "true", "false", "True", "False":
-		return "BOOL"
-	case "none", "None":
-		return "NONE"
-	default:
-		// Return the actual value for keywords, operators, and punctuation
-		return token.Value
-	}
+	return normalizeToken(token)
 }
 
 func (t *PythonTokenizer) Language() string {
@@ -0,0 +1,159 @@
+package tokenizer
+
+import (
+	"bot-go/internal/model/ngram"
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Token types produced by TextTokenizer
+const (
+	textWordType        = "word"
+	textNumberType      = "number"
+	textPunctuationType = "punctuation"
+	textCodeBlockType   = "code_block"
+)
+
+// TextTokenizer implements tokenization for prose such as Markdown and plain
+// text documentation. Words, numbers and punctuation marks become tokens.
+// Fenced code blocks are collapsed into a single marker token, so code samples
+// do not skew the model of the surrounding prose.
+type TextTokenizer struct{}
+
+// NewTextTokenizer creates a new text tokenizer
+func NewTextTokenizer() *TextTokenizer {
+	return &TextTokenizer{}
+}
+
+func (t *TextTokenizer) Tokenize(ctx context.Context, source []byte) (ngram.TokenSequence, error) {
+	var tokens ngram.TokenSequence
+	err := t.TokenizeStream(ctx, bytes.NewReader(source), func(token ngram.Token) error {
+		tokens = append(tokens, token)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// TokenizeStream reads the source line by line, so only the current line is held in memory
+func (t *TextTokenizer) TokenizeStream(ctx context.Context, source io.Reader, emit func(ngram.Token) error) error {
+	reader := bufio.NewReader(source)
+	fence := "" // Opening marker of the fenced code block being skipped
+
+	for lineNum := 1; ; lineNum++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		line, readErr := reader.ReadString('\n')
+		if readErr != nil && !errors.Is(readErr, io.EOF) {
+			return fmt.Errorf("failed to read text source: %w", readErr)
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		trimmed := strings.TrimLeft(line, " \t")
+		switch {
+		case fence != "":
+			// A closing fence uses the same character at least as many times
+			if strings.HasPrefix(trimmed, fence) && strings.Trim(strings.TrimSpace(trimmed), fence[:1]) == "" {
+				fence = ""
+			}
+		case strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~"):
+			fence = fenceMarker(trimmed)
+			err := emit(ngram.Token{
+				Type:     textCodeBlockType,
+				Category: ngram.CategoryOther,
+				Value:    strings.TrimSpace(trimmed),
+				Line:     lineNum,
+				Column:   len(line) - len(trimmed) + 1,
+			})
+			if err != nil {
+				return err
+			}
+		default:
+			if err := emitTextLine(line, lineNum, emit); err != nil {
+				return err
+			}
+		}
+
+		if readErr != nil {
+			return nil
+		}
+	}
+}
+
+// Normalize lowercases words, maps numbers to NUM and code blocks to CODE
+func (t *TextTokenizer) Normalize(token ngram.Token) string {
+	switch token.Type {
+	case textWordType:
+		return strings.ToLower(token.Value)
+	case textNumberType:
+		return "NUM"
+	case textCodeBlockType:
+		return "CODE"
+	default:
+		return token.Value
+	}
+}
+
+func (t *TextTokenizer) Language() string {
+	return "text"
+}
+
+// fenceMarker returns the run of fence characters that opens a code block
+func fenceMarker(line string) string {
+	end := strings.IndexFunc(line, func(r rune) bool { return r != rune(line[0]) })
+	if end == -1 {
+		return line
+	}
+	return line[:end]
+}
+
+// emitTextLine emits the words, numbers and punctuation marks of one line
+func emitTextLine(line string, lineNum int, emit func(ngram.Token) error) error {
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		token := ngram.Token{Line: lineNum, Column: i + 1}
+		if isTextWordRune(r) {
+			end := i + strings.IndexFunc(line[i:], func(r rune) bool { return !isTextWordRune(r) })
+			if end < i {
+				end = len(line)
+			}
+			token.Value = line[i:end]
+			if strings.IndexFunc(token.Value, func(r rune) bool { return !unicode.IsDigit(r) }) == -1 {
+				token.Type, token.Category = textNumberType, ngram.CategoryNumber
+			} else {
+				token.Type, token.Category = textWordType, ngram.CategoryIdentifier
+			}
+			i = end
+		} else {
+			token.Value = line[i : i+size]
+			token.Type, token.Category = textPunctuationType, ngram.CategoryPunctuation
+			i += size
+		}
+
+		if err := emit(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isTextWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
@@ -3,10 +3,26 @@ package tokenizer
 import (
 	"bot-go/internal/model/ngram"
 	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
 )
 
 // Tokenizer defines the interface for language-specific tokenization
 type Tokenizer interface {
+	SliceTokenizer
+
+	// TokenizeStream feeds tokens to emit one at a time in source order, so
+	// callers never hold the full token sequence. It stops at the first error
+	// returned by emit and returns it.
+	TokenizeStream(ctx context.Context, source io.Reader, emit func(ngram.Token) error) error
+}
+
+// SliceTokenizer is a tokenizer that only produces whole token sequences; wrap
+// it with NewStreamAdapter to use it as a Tokenizer
+type SliceTokenizer interface {
 	// Tokenize converts source code into a sequence of tokens
 	Tokenize(ctx context.Context, source []byte) (ngram.TokenSequence, error)
 
@@ -17,6 +33,66 @@ type Tokenizer interface {
 	Language() string
 }
 
+// streamAdapter implements TokenizeStream on top of Tokenize
+type streamAdapter struct {
+	SliceTokenizer
+}
+
+// NewStreamAdapter turns a slice-based tokenizer into a Tokenizer. The whole
+// token sequence is still built internally and then emitted token by token.
+func NewStreamAdapter(tokenizer SliceTokenizer) Tokenizer {
+	return streamAdapter{SliceTokenizer: tokenizer}
+}
+
+func (a streamAdapter) TokenizeStream(ctx context.Context, source io.Reader, emit func(ngram.Token) error) error {
+	content, err := readSource(source, a.Language())
+	if err != nil {
+		return err
+	}
+
+	tokens, err := a.Tokenize(ctx, content)
+	if err != nil {
+		return err
+	}
+
+	for _, token := range tokens {
+		if err := emit(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTokens parses source with a tree-sitter parser and emits its tokens
+// without building a token slice. The tree and source stay in memory for the
+// duration of the walk, since token text is read from the source.
+func parseTokens(ctx context.Context, parser *tree_sitter.Parser, mu *sync.Mutex, rules *languageRules, languageName string, source []byte, emit func(ngram.Token) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	tree := parser.Parse(source, nil)
+	if tree == nil {
+		return fmt.Errorf("failed to parse %s source", languageName)
+	}
+	defer tree.Close()
+
+	return rules.walkTokens(tree.RootNode(), source, func(token ngram.Token) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return emit(token)
+	})
+}
+
+// readSource reads a streamed source file into memory for parsing
+func readSource(source io.Reader, languageName string) ([]byte, error) {
+	content, err := io.ReadAll(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s source: %w", languageName, err)
+	}
+	return content, nil
+}
+
 // TokenizerRegistry manages tokenizers for different languages
 type TokenizerRegistry struct {
 	tokenizers map[string]Tokenizer
@@ -0,0 +1,354 @@
+package tokenizer
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot-go/internal/model/ngram"
+)
+
+func TestNormalizeByCategory(t *testing.T) {
+	goTok, _ := NewGoTokenizer()
+	pythonTok, _ := NewPythonTokenizer()
+	jsTok, _ := NewJavaScriptTokenizer()
+	tsTok, _ := NewTypeScriptTokenizer()
+	javaTok, _ := NewJavaTokenizer()
+
+	tests := []struct {
+		name      string
+		tokenizer Tokenizer
+		source    string
+		// Normalized form expected for the first token with each value
+		want map[string]string
+	}{
+		{
+			name:      "go",
+			tokenizer: goTok,
+			source:    "package main\n\nfunc count(items []string) int {\n\tif items == nil {\n\t\treturn len(\"x\") + 42\n\t}\n\treturn obj.total\n}\n",
+			want: map[string]string{
+				"func": "func", "if": "if", "return": "return", "nil": "nil",
+				"count": "ID", "items": "ID", "total": "ID", "string": "ID",
+				`"x"`: "STR", "42": "NUM", "+": "+", "(": "(",
+			},
+		},
+		{
+			name:      "python",
+			tokenizer: pythonTok,
+			source:    "def count(items):\n    if items is None:\n        return len('x') + 42\n    return self.total\n",
+			want: map[string]string{
+				"def": "def", "if": "if", "return": "return", "is": "is", "None": "None",
+				"count": "ID", "items": "ID", "total": "ID",
+				"'x'": "STR", "42": "NUM", "+": "+",
+			},
+		},
+		{
+			name:      "javascript",
+			tokenizer: jsTok,
+			source:    "function count(items) {\n  if (items === null) { return 'x'.length + 42; }\n  return this.total;\n}\n",
+			want: map[string]string{
+				"function": "function", "if": "if", "return": "return", "null": "null", "this": "this",
+				"count": "ID", "items": "ID", "total": "ID",
+				"'x'": "STR", "42": "NUM", "===": "===", ";": ";",
+			},
+		},
+		{
+			name:      "typescript",
+			tokenizer: tsTok,
+			source:    "function count(items: Item[]): number {\n  if (items === null) { return 'x'.length + 42; }\n  return this.total;\n}\n",
+			want: map[string]string{
+				"function": "function", "if": "if", "return": "return", "null": "null",
+				"count": "ID", "items": "ID", "Item": "ID", "total": "ID",
+				"'x'": "STR", "42": "NUM", "===": "===",
+			},
+		},
+		{
+			name:      "java",
+			tokenizer: javaTok,
+			source:    "class Counter {\n  int count(List items) {\n    if (items == null) { return \"x\".length() + 42; }\n    return this.total;\n  }\n}\n",
+			want: map[string]string{
+				"class": "class", "int": "int", "if": "if", "return": "return", "null": "null", "this": "this",
+				"Counter": "ID", "count": "ID", "items": "ID", "List": "ID", "total": "ID",
+				`"x"`: "STR", "42": "NUM", "==": "==",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := tt.tokenizer.Tokenize(context.Background(), []byte(tt.source))
+			if err != nil {
+				t.Fatalf("Tokenize failed: %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, token := range tokens {
+				if _, seen := got[token.Value]; !seen {
+					got[token.Value] = tt.tokenizer.Normalize(token)
+				}
+			}
+			for value, want := range tt.want {
+				normalized, ok := got[value]
+				if !ok {
+					t.Errorf("no token %q in %v", value, tokens)
+					continue
+				}
+				if normalized != want {
+					t.Errorf("Normalize(%q) = %q, want %q", value, normalized, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStringLiteralsAreSingleTokens(t *testing.T) {
+	tok, _ := NewPythonTokenizer()
+	tokens, err := tok.Tokenize(context.Background(), []byte("x = \"a\\nb\"\n"))
+	if err != nil {
+		t.Fatalf("Tokenize failed: %v", err)
+	}
+
+	var categories []ngram.TokenCategory
+	for _, token := range tokens {
+		categories = append(categories, token.Category)
+	}
+	want := []ngram.TokenCategory{ngram.CategoryIdentifier, ngram.CategoryOperator, ngram.CategoryString}
+	if len(categories) != len(want) {
+		t.Fatalf("categories = %v, want %v", categories, want)
+	}
+	for i := range want {
+		if categories[i] != want[i] {
+			t.Errorf("token %d category = %q, want %q", i, categories[i], want[i])
+		}
+	}
+}
+
+func TestPythonIndentTokens(t *testing.T) {
+	source := "def outer(x):\n    def inner(y):\n        if y: return y\n        return 0\n    return inner(x)\n\nz = 1\n"
+
+	tok, _ := NewPythonTokenizer()
+	plain, err := tok.Tokenize(context.Background(), []byte(source))
+	if err != nil {
+		t.Fatalf("Tokenize failed: %v", err)
+	}
+	for _, token := range plain {
+		if token.Type == indentType || token.Type == dedentType {
+			t.Fatalf("got %s token at line %d with indent tokens disabled", token.Value, token.Line)
+		}
+	}
+
+	tok.SetIndentTokens(true)
+	tokens, err := tok.Tokenize(context.Background(), []byte(source))
+	if err != nil {
+		t.Fatalf("Tokenize failed: %v", err)
+	}
+	if len(tokens) != len(plain)+4 {
+		t.Errorf("got %d tokens, want %d plus two INDENT/DEDENT pairs", len(tokens), len(plain))
+	}
+
+	// Each marker follows the colon ending a header or precedes the first
+	// token after the block; the same-line if body is not indented
+	type marker struct {
+		value string
+		next  string // Token that follows the marker
+		line  int
+	}
+	want := []marker{
+		{"INDENT", "def", 2},
+		{"INDENT", "if", 3},
+		{"DEDENT", "return", 4},
+		{"DEDENT", "ID", 5},
+	}
+	var got []marker
+	depth := 0
+	for i, token := range tokens {
+		if token.Type != indentType && token.Type != dedentType {
+			continue
+		}
+		if tok.Normalize(token) != token.Value {
+			t.Errorf("Normalize(%s) = %q, want it unchanged", token.Value, tok.Normalize(token))
+		}
+		if token.Type == indentType {
+			depth++
+		} else {
+			depth--
+		}
+		if depth < 0 {
+			t.Fatalf("DEDENT at line %d without matching INDENT", token.Line)
+		}
+		next := ""
+		if i+1 < len(tokens) {
+			next = tok.Normalize(tokens[i+1])
+		}
+		got = append(got, marker{token.Value, next, token.Line})
+	}
+	if depth != 0 {
+		t.Errorf("INDENT/DEDENT unbalanced by %d", depth)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("markers = %v, want %v", got, want)
+	}
+}
+
+func TestTokenizeStreamMatchesTokenize(t *testing.T) {
+	goTok, _ := NewGoTokenizer()
+	source := "package main\n\nimport \"fmt\"\n\n// Greet prints a greeting\nfunc Greet(names []string) {\n\tfor i, name := range names {\n\t\tif i > 0 && name != \"\" {\n\t\t\tfmt.Printf(\"hi %s\\n\", name)\n\t\t}\n\t}\n}\n"
+
+	want, err := goTok.Tokenize(context.Background(), []byte(source))
+	if err != nil {
+		t.Fatalf("Tokenize failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		tokenizer Tokenizer
+	}{
+		{"tree-sitter", goTok},
+		{"adapter", NewStreamAdapter(goTok)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ngram.TokenSequence
+			err := tt.tokenizer.TokenizeStream(context.Background(), strings.NewReader(source), func(token ngram.Token) error {
+				got = append(got, token)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("TokenizeStream failed: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("streamed %d tokens %v, want %d tokens %v", len(got), got, len(want), want)
+			}
+
+			// An emit error stops the stream and is returned as is
+			stop := errors.New("stop")
+			emitted := 0
+			err = tt.tokenizer.TokenizeStream(context.Background(), strings.NewReader(source), func(ngram.Token) error {
+				emitted++
+				if emitted == 3 {
+					return stop
+				}
+				return nil
+			})
+			if !errors.Is(err, stop) || emitted != 3 {
+				t.Errorf("after emit error: err = %v, emitted = %d, want stop after 3", err, emitted)
+			}
+		})
+	}
+}
+
+func TestTextTokenizerMarkdown(t *testing.T) {
+	tok := NewTextTokenizer()
+	source := "# Setup Guide\n\nRun it 3 times, then stop.\n\n```go\nfunc main() {\n\tfmt.Println(\"skipped\")\n}\n```\n\n~~~~\nnot prose\n~~~ still inside\n~~~~\nDone!\n"
+
+	tokens, err := tok.Tokenize(context.Background(), []byte(source))
+	if err != nil {
+		t.Fatalf("Tokenize failed: %v", err)
+	}
+
+	var normalized []string
+	for _, token := range tokens {
+		normalized = append(normalized, tok.Normalize(token))
+	}
+	want := []string{
+		"#", "setup", "guide",
+		"run", "it", "NUM", "times", ",", "then", "stop", ".",
+		"CODE",
+		"CODE",
+		"done", "!",
+	}
+	if !reflect.DeepEqual(normalized, want) {
+		t.Errorf("normalized = %v, want %v", normalized, want)
+	}
+
+	// The code fence marker keeps its position and info string
+	for _, token := range tokens {
+		if token.Value == "```go" && (token.Line != 5 || token.Column != 1) {
+			t.Errorf("code fence at %d:%d, want 5:1", token.Line, token.Column)
+		}
+		if token.Value == "Done" && token.Line != 15 {
+			t.Errorf("Done on line %d, want 15", token.Line)
+		}
+	}
+
+	var streamed ngram.TokenSequence
+	err = tok.TokenizeStream(context.Background(), strings.NewReader(source), func(token ngram.Token) error {
+		streamed = append(streamed, token)
+		return nil
+	})
+	if err != nil || !reflect.DeepEqual(streamed, tokens) {
+		t.Errorf("TokenizeStream = %v, %v; want the Tokenize result", streamed, err)
+	}
+}
+
+func TestGenericTokenizer(t *testing.T) {
+	tok := NewGenericTokenizer()
+
+	tests := []struct {
+		name   string
+		source string
+		want   []string // Normalized tokens
+	}{
+		{
+			name:   "rust",
+			source: "#[derive(Debug)]\nfn longest<'a>(x: &'a str, n: u32) -> &'a str {\n    // pick one\n    if n >= 10u32 { return \"x\\\"y\"; }\n    x\n}\n",
+			want: []string{
+				"#", "[", "ID", "(", "ID", ")", "]",
+				"fn", "ID", "<", "'", "ID", ">", "(", "ID", ":", "&", "'", "ID", "ID", ",", "ID", ":", "ID", ")", "->", "&", "'", "ID", "ID", "{",
+				"if", "ID", ">=", "NUM", "{", "return", "STR", ";", "}",
+				"ID",
+				"}",
+			},
+		},
+		{
+			name:   "ruby",
+			source: "# Greets people\nclass Greeter\n  def greet(name)\n    puts 'Hello, ' + name unless name.nil?\n    @count += 1.5\n  end\nend\n",
+			want: []string{
+				"class", "ID",
+				"def", "ID", "(", "ID", ")",
+				"ID", "STR", "+", "ID", "unless", "ID", ".", "nil", "?",
+				"@", "ID", "+=", "NUM",
+				"end",
+				"end",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, err := tok.Tokenize(context.Background(), []byte(tt.source))
+			if err != nil {
+				t.Fatalf("Tokenize failed: %v", err)
+			}
+
+			var normalized []string
+			for _, token := range tokens {
+				normalized = append(normalized, tok.Normalize(token))
+			}
+			if !reflect.DeepEqual(normalized, tt.want) {
+				t.Errorf("normalized = %v, want %v", normalized, tt.want)
+			}
+
+			var streamed ngram.TokenSequence
+			err = tok.TokenizeStream(context.Background(), strings.NewReader(tt.source), func(token ngram.Token) error {
+				streamed = append(streamed, token)
+				return nil
+			})
+			if err != nil || !reflect.DeepEqual(streamed, tokens) {
+				t.Errorf("TokenizeStream = %v, %v; want the Tokenize result", streamed, err)
+			}
+		})
+	}
+
+	// Positions are 1-based and count lines across comments and strings
+	tokens, _ := tok.Tokenize(context.Background(), []byte("/* a\nb */ x = \"p\nq\"\ny"))
+	if last := tokens[len(tokens)-1]; last.Value != "y" || last.Line != 4 || last.Column != 1 {
+		t.Errorf("last token = %q at %d:%d, want y at 4:1", last.Value, last.Line, last.Column)
+	}
+	if tokens[0].Value != "x" || tokens[0].Line != 2 || tokens[0].Column != 6 {
+		t.Errorf("first token = %q at %d:%d, want x at 2:6", tokens[0].Value, tokens[0].Line, tokens[0].Column)
+	}
+}
@@ -8,10 +8,14 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 
@@ -28,17 +32,31 @@ import (
 type CodeChunkService struct {
 	vectorDB            VectorDatabase
 	embedding           EmbeddingModel
+	docEmbedding        EmbeddingModel            // Optional; embeds chunk descriptions into a "doc" named vector
+	searchModels        map[string]EmbeddingModel // Models collections may be searched with, by name
 	logger              *zap.Logger
 	parser              *tree_sitter.Parser
 	parserMutex         sync.Mutex // Protects parser access (tree-sitter is not thread-safe)
 	minConditionalLines int
 	minLoopLines        int
+	maxChunkLines       int                        // Chunks longer than this are split into windows (0 disables)
+	overlapLines        int                        // Lines shared between consecutive windows
+	maxContentChars     int                        // Chunk content longer than this is shortened before embedding (0 disables)
+	contentLimit        chunk.ContentLimitStrategy // How over-long chunk content is shortened
 	gcThreshold         int64
 	numFileThreads      int
+	embeddingCache      *EmbeddingCache        // Optional; nil disables embedding reuse across runs
+	skipRules           FileSkipRules          // Minified/generated file detection used by ProcessDirectory
+	absolutePaths       bool                   // Store absolute chunk file paths instead of repo-relative ones
+	distance            DistanceMetric         // Similarity metric for new collections
+	maxFileSize         int64                  // Files larger than this many bytes are skipped (0 = no limit)
+	collectionLocks     map[string]*sync.Mutex // Serializes creation of each collection
+	ensuredCollections  map[string]bool        // Collections EnsureCollection has created or validated
+	collectionsMutex    sync.Mutex             // Protects collectionLocks and ensuredCollections
 }
 
 // NewCodeChunkService creates a new code chunk service
-func NewCodeChunkService(vectorDB VectorDatabase, embedding EmbeddingModel, minConditionalLines, minLoopLines int, gcThreshold int64, numFileThreads int, logger *zap.Logger) *CodeChunkService {
+func NewCodeChunkService(vectorDB VectorDatabase, embedding EmbeddingModel, minConditionalLines, minLoopLines, maxChunkLines, overlapLines int, gcThreshold int64, numFileThreads int, logger *zap.Logger) *CodeChunkService {
 	return &CodeChunkService{
 		vectorDB:            vectorDB,
 		embedding:           embedding,
@@ -46,14 +64,130 @@ func NewCodeChunkService(vectorDB VectorDatabase, embedding EmbeddingModel, minC
 		parser:              tree_sitter.NewParser(),
 		minConditionalLines: minConditionalLines,
 		minLoopLines:        minLoopLines,
+		maxChunkLines:       maxChunkLines,
+		overlapLines:        overlapLines,
 		gcThreshold:         gcThreshold,
 		numFileThreads:      numFileThreads,
+		distance:            DistanceMetricCosine,
+		searchModels:        map[string]EmbeddingModel{embedding.GetModelName(): embedding},
+		collectionLocks:     make(map[string]*sync.Mutex),
+		ensuredCollections:  make(map[string]bool),
 	}
 }
 
+// Names of the vectors stored per point when a doc embedding model is set
+const (
+	VectorNameCode = "code" // Chunk code, embedded with the service's model
+	VectorNameDoc  = "doc"  // Chunk name, signature and docstring, embedded with the doc model
+)
+
+// SetDocEmbeddingModel stores a second vector per chunk: new collections are
+// created with named "code" and "doc" vectors, the doc vector embedding the
+// chunk's natural-language description with the given model. Collections
+// created before it was set keep their single unnamed vector.
+func (ccs *CodeChunkService) SetDocEmbeddingModel(docEmbedding EmbeddingModel) {
+	ccs.docEmbedding = docEmbedding
+}
+
+// collectionVectorName resolves the vector a search of a collection compares
+// against. An empty request selects the collection's default: its code vector
+// if it was created with named vectors, else its unnamed vector. Collections
+// created before a doc model was configured keep their unnamed vector, so the
+// decision is made per collection from its vectors config.
+func (ccs *CodeChunkService) collectionVectorName(ctx context.Context, collectionName, requested string) (string, error) {
+	names, err := ccs.vectorDB.CollectionVectorNames(ctx, collectionName)
+	if err != nil {
+		return "", fmt.Errorf("failed to read collection vectors: %w", err)
+	}
+	if requested == "" {
+		if len(names) == 0 {
+			return "", nil
+		}
+		requested = VectorNameCode
+	}
+	if !slices.Contains(names, requested) {
+		if len(names) == 0 {
+			return "", fmt.Errorf("unknown vector %q: collection %s has a single unnamed vector", requested, collectionName)
+		}
+		return "", fmt.Errorf("unknown vector %q: collection %s has vectors %s", requested, collectionName, strings.Join(names, ", "))
+	}
+	if requested == VectorNameDoc && ccs.docEmbedding == nil {
+		return "", fmt.Errorf("vector %q of collection %s needs a doc embedding model, which is not configured", requested, collectionName)
+	}
+	return requested, nil
+}
+
+// RegisterEmbeddingModel makes a model available for searching collections
+// that were built with it. The service's own model is always registered.
+func (ccs *CodeChunkService) RegisterEmbeddingModel(embedding EmbeddingModel) {
+	ccs.searchModels[embedding.GetModelName()] = embedding
+}
+
+// SetDistanceMetric selects the similarity metric collections are created with;
+// it should match the metric the embedding model was trained for
+func (ccs *CodeChunkService) SetDistanceMetric(distance DistanceMetric) {
+	ccs.distance = distance
+}
+
+// DistanceMetric returns the similarity metric collections are created with
+func (ccs *CodeChunkService) DistanceMetric() DistanceMetric {
+	return ccs.distance
+}
+
+// SetEmbeddingCache enables reuse of embeddings for unchanged chunk content
+func (ccs *CodeChunkService) SetEmbeddingCache(cache *EmbeddingCache) {
+	ccs.embeddingCache = cache
+}
+
+// SetFileSkipRules configures which files ProcessDirectory skips as minified or generated
+func (ccs *CodeChunkService) SetFileSkipRules(rules FileSkipRules) {
+	ccs.skipRules = rules
+}
+
+// SetMaxFileSize skips files larger than maxBytes when chunking; 0 disables the limit
+func (ccs *CodeChunkService) SetMaxFileSize(maxBytes int64) {
+	ccs.maxFileSize = maxBytes
+}
+
+// SetMaxContentLength shortens chunk content longer than maxChars characters
+// with strategy before it is embedded; 0 disables the limit
+func (ccs *CodeChunkService) SetMaxContentLength(maxChars int, strategy chunk.ContentLimitStrategy) {
+	ccs.maxContentChars = maxChars
+	ccs.contentLimit = strategy
+}
+
+// NewFileLineCache creates a line cache for one request that skips files over
+// the service's maximum file size, like chunking does
+func (ccs *CodeChunkService) NewFileLineCache() *FileLineCache {
+	return NewFileLineCacheWithLimit(ccs.maxFileSize)
+}
+
+// SetAbsolutePaths selects whether chunk file paths are stored absolute or repo-relative
+func (ccs *CodeChunkService) SetAbsolutePaths(absolute bool) {
+	ccs.absolutePaths = absolute
+}
+
+// PathNormalizer returns the normalizer used for chunk file paths of the repository at root
+func (ccs *CodeChunkService) PathNormalizer(root string) *util.PathNormalizer {
+	return util.NewPathNormalizer(root, ccs.absolutePaths)
+}
+
+// SaveEmbeddingCache flushes the embedding cache to disk, if one is configured
+func (ccs *CodeChunkService) SaveEmbeddingCache() error {
+	if ccs.embeddingCache == nil {
+		return nil
+	}
+	return ccs.embeddingCache.Save()
+}
+
 // ProcessFile processes a single source file and stores chunks in vector DB
 // Returns (chunks, error) - if error is non-nil, processing failed but can be retried
 func (ccs *CodeChunkService) ProcessFile(ctx context.Context, filePath, language, collectionName string) ([]*model.CodeChunk, error) {
+	return ccs.processFileAs(ctx, filePath, filePath, language, collectionName)
+}
+
+// processFileAs reads the file at filePath and stores its chunks under storedPath
+func (ccs *CodeChunkService) processFileAs(ctx context.Context, filePath, storedPath, language, collectionName string) ([]*model.CodeChunk, error) {
 	// Read file content
 	sourceCode, err := ccs.readFile(filePath)
 	if err != nil {
@@ -64,12 +198,28 @@ func (ccs *CodeChunkService) ProcessFile(ctx context.Context, filePath, language
 		return nil, nil // Return nil error to continue processing other files
 	}
 
-	return ccs.ProcessFileWithContent(ctx, filePath, language, collectionName, sourceCode)
+	if reason := ccs.skipRules.SkipReason(sourceCode); reason != "" {
+		ccs.logger.Info("Skipping file",
+			zap.String("file", filePath),
+			zap.String("reason", reason))
+		return []*model.CodeChunk{}, nil
+	}
+
+	return ccs.ProcessFileWithContent(ctx, storedPath, language, collectionName, sourceCode)
 }
 
 // ProcessFileWithContent processes a single source file with provided content and stores chunks in vector DB
 // Returns (chunks, error) - if error is non-nil, processing failed but can be retried
 func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePath, language, collectionName string, sourceCode []byte) ([]*model.CodeChunk, error) {
+	chunks, _ := ccs.storeFileContent(ctx, filePath, language, collectionName, sourceCode)
+	return chunks, nil
+}
+
+// storeFileContent chunks a file's content, embeds the chunks not already
+// stored and upserts them all. stored is false if parsing, embedding or
+// storing failed, in which case the file is skipped and the vector DB holds
+// whatever it held before.
+func (ccs *CodeChunkService) storeFileContent(ctx context.Context, filePath, language, collectionName string, sourceCode []byte) (chunks []*model.CodeChunk, stored bool) {
 	// Check for existing chunks in the database
 	existingChunks, err := ccs.vectorDB.GetChunksByFilePath(ctx, collectionName, filePath)
 	if err != nil {
@@ -80,19 +230,19 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 	}
 
 	// Parse file and generate chunks
-	chunks, err := ccs.parseAndChunk(ctx, filePath, language, sourceCode)
+	chunks, err = ccs.parseAndChunk(ctx, filePath, language, sourceCode)
 	if err != nil {
 		// Parse errors might indicate corrupted files or unsupported syntax - log and skip
 		ccs.logger.Warn("Failed to parse file, skipping",
 			zap.String("file", filePath),
 			zap.String("language", language),
 			zap.Error(err))
-		return nil, nil // Return nil error to continue processing other files
+		return nil, false
 	}
 
 	if len(chunks) == 0 {
 		ccs.logger.Debug("No chunks generated for file", zap.String("file", filePath))
-		return nil, nil
+		return nil, true
 	}
 
 	// Build a map of existing chunk IDs for quick lookup
@@ -111,6 +261,7 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 		if existingChunk, exists := existingChunkMap[chunk.ID]; exists {
 			// Chunk already exists, reuse its embedding
 			chunk.Embedding = existingChunk.Embedding
+			chunk.NamedEmbeddings = existingChunk.NamedEmbeddings
 			existingMatchedChunks = append(existingMatchedChunks, chunk)
 		} else {
 			// New chunk, needs embedding
@@ -133,7 +284,7 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 			ccs.logger.Warn("Failed to generate embeddings, skipping file",
 				zap.String("file", filePath),
 				zap.Error(err))
-			return nil, nil // Return nil error to continue processing other files
+			return nil, false
 		}
 		chunksToStore = append(chunksToStore, newChunksWithEmbeddings...)
 	}
@@ -143,12 +294,12 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 
 	// Store all chunks in vector database (upsert will update existing ones)
 	if len(chunksToStore) > 0 {
-		if err := ccs.vectorDB.UpsertChunks(ctx, collectionName, chunksToStore); err != nil {
+		if err := ccs.upsertChunks(ctx, collectionName, chunksToStore); err != nil {
 			// Vector DB errors might be transient - log and skip
 			ccs.logger.Warn("Failed to store chunks, skipping file",
 				zap.String("file", filePath),
 				zap.Error(err))
-			return nil, nil // Return nil error to continue processing other files
+			return nil, false
 		}
 	}
 
@@ -158,7 +309,7 @@ func (ccs *CodeChunkService) ProcessFileWithContent(ctx context.Context, filePat
 		zap.Int("new_embeddings_generated", len(newChunks)),
 		zap.Int("stored_chunks", len(chunksToStore)))
 
-	return chunks, nil
+	return chunks, true
 }
 
 // ProcessFileWithContentAndFileID processes a single source file with provided content and FileID
@@ -215,6 +366,7 @@ func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context
 		if existingChunk, exists := existingChunkMap[chunk.ID]; exists {
 			// Chunk already exists, reuse its embedding
 			chunk.Embedding = existingChunk.Embedding
+			chunk.NamedEmbeddings = existingChunk.NamedEmbeddings
 			existingMatchedChunks = append(existingMatchedChunks, chunk)
 		} else {
 			// New chunk, needs embedding
@@ -249,7 +401,7 @@ func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context
 
 	// Store all chunks in vector database (upsert will update existing ones)
 	if len(chunksToStore) > 0 {
-		if err := ccs.vectorDB.UpsertChunks(ctx, collectionName, chunksToStore); err != nil {
+		if err := ccs.upsertChunks(ctx, collectionName, chunksToStore); err != nil {
 			// Vector DB errors might be transient - log and skip
 			ccs.logger.Warn("Failed to store chunks, skipping file",
 				zap.String("file", filePath),
@@ -269,16 +421,27 @@ func (ccs *CodeChunkService) ProcessFileWithContentAndFileID(ctx context.Context
 	return chunks, nil
 }
 
-// ProcessDirectory processes all supported files in a directory recursively
-// Gracefully skips files that fail to read or process
+// ProcessDirectory processes all supported files in a directory recursively.
+// Files are chunked and embedded concurrently by numFileThreads workers.
+// Files that fail to read or process are logged and skipped; cancellation of
+// ctx is fatal and stops the walk, returning the chunks stored so far.
 func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, collectionName string, repoConfig interface{}) (int, error) {
+	if err := ccs.EnsureCollection(ctx, collectionName); err != nil {
+		return 0, err
+	}
+
 	totalChunks := 0
 	filesFailed := 0
+	var fatalErr error
+	var mu sync.Mutex // Protects the counters and fatalErr across workers
 
 	// Extract repository configuration if provided
 	var skipOtherLanguages bool
 	var repoLanguage string
-	if repo, ok := repoConfig.(*config.Repository); ok && repo != nil {
+	repoRoot := dirPath
+	repo, _ := repoConfig.(*config.Repository)
+	if repo != nil {
+		repoRoot = repo.Path
 		skipOtherLanguages = repo.SkipOtherLanguages
 		repoLanguage = repo.Language
 		if skipOtherLanguages {
@@ -288,26 +451,45 @@ func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, coll
 		}
 	}
 
+	paths := ccs.PathNormalizer(repoRoot)
+
+	// stopped records the first fatal error and reports whether one occurred
+	stopped := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if fatalErr == nil && ctx.Err() != nil {
+			fatalErr = ctx.Err()
+		}
+		return fatalErr != nil
+	}
+
 	err := util.WalkDirTree(dirPath, func(path string, err error) error {
 		if err != nil {
 			return err
 		}
+		if stopped() {
+			return nil
+		}
 
-		language := ccs.detectLanguage(path)
+		language := ccs.detectLanguage(path, repo)
 		if language == "" {
 			ccs.logger.Info("WalkDirTree - Skipping unsupported file", zap.String("path", path))
 			return nil
 		}
 		// Process file
-		chunks, err := ccs.ProcessFile(ctx, path, language, collectionName)
+		chunks, err := ccs.processFileAs(ctx, path, paths.Normalize(path), language, collectionName)
 		if err != nil {
 			// This shouldn't happen as ProcessFile now handles errors internally
 			// But keep this as a safeguard
 			ccs.logger.Error("WalkDirTree - Unexpected error processing file", zap.String("path", path), zap.Error(err))
+			mu.Lock()
 			filesFailed++
+			mu.Unlock()
 			return nil // Continue processing other files
 		}
 
+		mu.Lock()
+		defer mu.Unlock()
 		// Count successful processing (chunks might be nil if file was skipped internally)
 		if chunks != nil {
 			totalChunks += len(chunks)
@@ -319,6 +501,11 @@ func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, coll
 		return nil
 	},
 		func(path string, isDir bool) bool {
+			// Stop walking once processing has failed fatally
+			if stopped() {
+				return true
+			}
+
 			// Skip excluded directories
 			if isDir {
 				if ccs.shouldSkipDirectory(path, filepath.Base(path)) {
@@ -328,7 +515,12 @@ func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, coll
 				return false
 			}
 
-			language := ccs.detectLanguage(path)
+			if ccs.skipRules.MatchesGlob(path) {
+				ccs.logger.Info("WalkDirTree - Skipping file matching skip glob", zap.String("path", path))
+				return true
+			}
+
+			language := ccs.detectLanguage(path, repo)
 			if language == "" {
 				ccs.logger.Info("WalkDirTree - Skipping unsupported file", zap.String("path", path))
 				return true
@@ -346,8 +538,16 @@ func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, coll
 		},
 		ccs.logger,
 		ccs.gcThreshold,
-		ccs.numFileThreads)
+		max(ccs.numFileThreads, 1))
 
+	if err := ccs.SaveEmbeddingCache(); err != nil {
+		ccs.logger.Warn("WalkDirTree - Failed to save embedding cache", zap.Error(err))
+	}
+
+	// Checked first: a stopped walk may also report the skipped root
+	if fatalErr != nil {
+		return totalChunks, fmt.Errorf("WalkDirTree - stopped processing directory: %w", fatalErr)
+	}
 	if err != nil {
 		return totalChunks, fmt.Errorf("WalkDirTree - failed to process directory: %w", err)
 	}
@@ -359,21 +559,171 @@ func (ccs *CodeChunkService) ProcessDirectory(ctx context.Context, dirPath, coll
 		zap.String("dir", dirPath),
 		//zap.Int("files_processed", filesProcessed),
 		//zap.Int("files_skipped", filesSkipped),
+		zap.Int("files_failed", filesFailed),
 		zap.Int("total_chunks", totalChunks))
 
 	return totalChunks, nil
 }
 
+// ProcessChangedFiles re-chunks only the files under the repository that are
+// modified relative to the repository's git ref (HEAD by default) or new and
+// untracked. The current content of each changed file is chunked, embedded
+// and stored before the file's chunks that no longer exist are deleted, so a
+// file whose processing fails keeps its previous chunks. Deleted files and
+// files now excluded by the skip rules lose their chunks.
+func (ccs *CodeChunkService) ProcessChangedFiles(ctx context.Context, repo *config.Repository, collectionName string) (int, error) {
+	if err := ccs.EnsureCollection(ctx, collectionName); err != nil {
+		return 0, err
+	}
+
+	gitInfo, err := util.GetGitInfo(repo.Path, repo.Ref)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get git info: %w", err)
+	}
+	if !gitInfo.IsGitRepo {
+		return 0, fmt.Errorf("incremental processing requires a git repository: %s", repo.Path)
+	}
+
+	// Git reports paths under the resolved root; compare against the same form
+	repoRoot := repo.Path
+	if resolved, err := filepath.EvalSymlinks(repoRoot); err == nil {
+		repoRoot = resolved
+	}
+	if absRoot, err := filepath.Abs(repoRoot); err == nil {
+		repoRoot = absRoot
+	}
+	paths := ccs.PathNormalizer(repoRoot)
+
+	changed := make(map[string]bool, len(gitInfo.ModifiedFiles)+len(gitInfo.UntrackedFiles))
+	for path := range gitInfo.ModifiedFiles {
+		changed[path] = true
+	}
+	for path := range gitInfo.UntrackedFiles {
+		changed[path] = true
+	}
+
+	totalChunks := 0
+	filesProcessed := 0
+	for path := range changed {
+		if !ccs.isIncrementalCandidate(repoRoot, path, repo) {
+			continue
+		}
+		storedPath := paths.Normalize(path)
+
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			if err := ccs.deleteFileChunks(ctx, collectionName, storedPath, nil); err != nil {
+				return totalChunks, err
+			}
+			ccs.logger.Info("Removed chunks of deleted file", zap.String("path", storedPath))
+			continue
+		}
+
+		sourceCode, err := ccs.readFile(path)
+		if err != nil {
+			ccs.logger.Warn("Failed to read file, keeping its previous chunks",
+				zap.String("file", path),
+				zap.Error(err))
+			continue
+		}
+		if reason := ccs.skipRules.SkipReason(sourceCode); reason != "" {
+			if err := ccs.deleteFileChunks(ctx, collectionName, storedPath, nil); err != nil {
+				return totalChunks, err
+			}
+			ccs.logger.Info("Skipping file",
+				zap.String("file", path),
+				zap.String("reason", reason))
+			continue
+		}
+
+		chunks, stored := ccs.storeFileContent(ctx, storedPath, ccs.detectLanguage(path, repo), collectionName, sourceCode)
+		if !stored {
+			continue
+		}
+		if err := ccs.deleteFileChunks(ctx, collectionName, storedPath, chunks); err != nil {
+			return totalChunks, err
+		}
+		totalChunks += len(chunks)
+		filesProcessed++
+	}
+
+	if err := ccs.SaveEmbeddingCache(); err != nil {
+		ccs.logger.Warn("Failed to save embedding cache", zap.Error(err))
+	}
+
+	ccs.logger.Info("Processed changed files",
+		zap.String("repo", repo.Name),
+		zap.Int("files_changed", len(changed)),
+		zap.Int("files_processed", filesProcessed),
+		zap.Int("total_chunks", totalChunks))
+
+	return totalChunks, nil
+}
+
+// isIncrementalCandidate applies the ProcessDirectory filters to a single changed file
+func (ccs *CodeChunkService) isIncrementalCandidate(repoRoot, path string, repo *config.Repository) bool {
+	relPath, err := filepath.Rel(repoRoot, path)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return false
+	}
+	for dir := filepath.Dir(path); dir != repoRoot && len(dir) > len(repoRoot); dir = filepath.Dir(dir) {
+		if ccs.shouldSkipDirectory(dir, filepath.Base(dir)) {
+			return false
+		}
+	}
+	if ccs.skipRules.MatchesGlob(path) {
+		return false
+	}
+	language := ccs.detectLanguage(path, repo)
+	if language == "" {
+		return false
+	}
+	return !repo.SkipOtherLanguages || language == repo.Language
+}
+
+// deleteFileChunks removes the stored chunks of a file other than keep
+func (ccs *CodeChunkService) deleteFileChunks(ctx context.Context, collectionName, filePath string, keep []*model.CodeChunk) error {
+	existing, err := ccs.vectorDB.GetChunksByFilePath(ctx, collectionName, filePath)
+	if err != nil {
+		return fmt.Errorf("failed to get chunks of %s: %w", filePath, err)
+	}
+	kept := make(map[string]bool, len(keep))
+	for _, chunk := range keep {
+		kept[chunk.ID] = true
+	}
+	for _, existingChunk := range existing {
+		if kept[existingChunk.ID] {
+			continue
+		}
+		if err := ccs.vectorDB.DeleteChunk(ctx, collectionName, existingChunk.ID); err != nil {
+			return fmt.Errorf("failed to delete chunk %s: %w", existingChunk.ID, err)
+		}
+	}
+	return nil
+}
+
 // SearchSimilarCode searches for code chunks similar to the given query text
 func (ccs *CodeChunkService) SearchSimilarCode(ctx context.Context, collectionName, queryText string, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	if err := ccs.EnsureCollection(ctx, collectionName); err != nil {
+		return nil, nil, err
+	}
+
+	embedding, err := ccs.collectionEmbeddingModel(ctx, collectionName)
+	if err != nil {
+		return nil, nil, err
+	}
+	vectorName, err := ccs.collectionVectorName(ctx, collectionName, "")
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Generate embedding for query text
-	queryVector, err := ccs.embedding.GenerateEmbedding(ctx, queryText)
+	queryVector, err := embedding.GenerateEmbedding(ctx, queryText)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to generate query embedding: %w", err)
 	}
 
 	// Search in vector database
-	chunks, scores, err := ccs.vectorDB.SearchSimilar(ctx, collectionName, queryVector, limit, filter)
+	chunks, scores, err := ccs.searchVector(ctx, collectionName, vectorName, queryVector, limit, filter)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to search: %w", err)
 	}
@@ -381,38 +731,285 @@ func (ccs *CodeChunkService) SearchSimilarCode(ctx context.Context, collectionNa
 	return chunks, scores, nil
 }
 
+// TextSimilarities embeds text and others with the model a collection was
+// built with and returns the cosine similarity of text to each of the others
+func (ccs *CodeChunkService) TextSimilarities(ctx context.Context, collectionName, text string, others []string) ([]float32, error) {
+	if err := ccs.EnsureCollection(ctx, collectionName); err != nil {
+		return nil, err
+	}
+
+	embedding, err := ccs.collectionEmbeddingModel(ctx, collectionName)
+	if err != nil {
+		return nil, err
+	}
+
+	vectors, err := embedding.GenerateEmbeddings(ctx, append([]string{text}, others...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate embeddings: %w", err)
+	}
+	if len(vectors) != len(others)+1 {
+		return nil, fmt.Errorf("embedding model returned %d vectors for %d texts", len(vectors), len(others)+1)
+	}
+
+	similarities := make([]float32, len(others))
+	for i := range others {
+		similarities[i] = cosineSimilarity(vectors[0], vectors[i+1])
+	}
+	return similarities, nil
+}
+
+// cosineSimilarity returns the cosine of the angle between two vectors, or 0
+// if their lengths differ or either is zero
+func cosineSimilarity(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+
+	var dotProduct, normA, normB float64
+	for i := range a {
+		dotProduct += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return float32(dotProduct / (math.Sqrt(normA) * math.Sqrt(normB)))
+}
+
 // SearchSimilarCodeBySnippet chunks a code snippet and searches for similar code in the database
 func (ccs *CodeChunkService) SearchSimilarCodeBySnippet(ctx context.Context, collectionName, codeSnippet, language string, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []*model.CodeChunk, []float32, []int, error) {
-	// Parse and chunk the code snippet
+	return ccs.SearchSimilarCodeBySnippetInVector(ctx, collectionName, "", codeSnippet, language, limit, filter)
+}
+
+// SearchSimilarCodeBySnippetInVector is SearchSimilarCodeBySnippet comparing
+// against one named vector: VectorNameCode embeds the query chunks' code with
+// the collection's model, VectorNameDoc their descriptions with the doc model.
+// An empty vectorName searches the collection's default vector.
+func (ccs *CodeChunkService) SearchSimilarCodeBySnippetInVector(ctx context.Context, collectionName, vectorName, codeSnippet, language string, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []*model.CodeChunk, []float32, []int, error) {
+	if err := ccs.EnsureCollection(ctx, collectionName); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	vectorName, err := ccs.collectionVectorName(ctx, collectionName, vectorName)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	embedding, queryText := ccs.docEmbedding, ccs.chunkDocText
+	if vectorName != VectorNameDoc {
+		embedding, err = ccs.collectionEmbeddingModel(ctx, collectionName)
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		queryText = ccs.chunkSearchableText
+	}
+
+	queryChunks, err := ccs.chunkSnippet(ctx, codeSnippet, language)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	queries := ccs.embedSnippet(ctx, embedding, queryChunks, queryText)
+
+	results := ccs.searchSnippet(ctx, collectionName, vectorName, queries, limit, filter)
+
+	chunks := make([]*model.CodeChunk, len(results))
+	scores := make([]float32, len(results))
+	queryChunkIndices := make([]int, len(results))
+	for i, result := range results {
+		chunks[i] = result.chunk
+		scores[i] = result.score
+		queryChunkIndices[i] = result.queryChunkIndex
+	}
+
+	return queryChunks, chunks, scores, queryChunkIndices, nil
+}
+
+// CollectionSearchResult is a chunk found by SearchAcrossCollections, labeled
+// with the collection it came from
+type CollectionSearchResult struct {
+	Collection      string
+	Chunk           *model.CodeChunk
+	Score           float32
+	QueryChunkIndex int // Index of the query chunk that matched
+}
+
+// SearchAcrossCollections searches several collections for code similar to a
+// snippet. The snippet is chunked once and embedded once per embedding model
+// the collections were built with, the collections are searched concurrently,
+// and the merged results are ranked by score across all collections, keeping
+// the best limit. A collection that cannot be searched, including one whose
+// embedding model is unavailable, contributes no results. Returns the query
+// chunks and the results.
+func (ccs *CodeChunkService) SearchAcrossCollections(ctx context.Context, collections []string, codeSnippet, language string, limit int) ([]*model.CodeChunk, []CollectionSearchResult, error) {
+	queryChunks, err := ccs.chunkSnippet(ctx, codeSnippet, language)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Scores are only comparable within one model, so a query is embedded
+	// with each collection's own model
+	queriesByModel := make(map[string][]snippetQuery)
+	collectionQueries := make([][]snippetQuery, len(collections))
+	collectionVectors := make([]string, len(collections))
+	for i, collection := range collections {
+		embedding, err := ccs.collectionEmbeddingModel(ctx, collection)
+		if err == nil {
+			collectionVectors[i], err = ccs.collectionVectorName(ctx, collection, "")
+		}
+		if err != nil {
+			ccs.logger.Warn("Skipping collection that cannot be searched",
+				zap.String("collection", collection),
+				zap.Error(err))
+			continue
+		}
+		queries, ok := queriesByModel[embedding.GetModelName()]
+		if !ok {
+			queries = ccs.embedSnippet(ctx, embedding, queryChunks, ccs.chunkSearchableText)
+			queriesByModel[embedding.GetModelName()] = queries
+		}
+		collectionQueries[i] = queries
+	}
+
+	perCollection := make([][]*resultWithScore, len(collections))
+	var wg sync.WaitGroup
+	for i, collection := range collections {
+		if len(collectionQueries[i]) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, collection string) {
+			defer wg.Done()
+			perCollection[i] = ccs.searchSnippet(ctx, collection, collectionVectors[i], collectionQueries[i], limit, nil)
+		}(i, collection)
+	}
+	wg.Wait()
+
+	var merged []CollectionSearchResult
+	for i, results := range perCollection {
+		for _, result := range results {
+			merged = append(merged, CollectionSearchResult{
+				Collection:      collections[i],
+				Chunk:           result.chunk,
+				Score:           result.score,
+				QueryChunkIndex: result.queryChunkIndex,
+			})
+		}
+	}
+
+	// Stable, so equal scores keep the order collections were given in
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Score > merged[j].Score })
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+
+	return queryChunks, merged, nil
+}
+
+// snippetQuery is the embedding of one chunk of a query snippet
+type snippetQuery struct {
+	chunkIndex int
+	vector     []float32
+}
+
+// chunkSnippet parses a code snippet into the query chunks it is searched by
+func (ccs *CodeChunkService) chunkSnippet(ctx context.Context, codeSnippet, language string) ([]*model.CodeChunk, error) {
 	queryChunks, err := ccs.parseAndChunk(ctx, "query.snippet", language, []byte(codeSnippet))
 	if err != nil {
-		return nil, nil, nil, nil, fmt.Errorf("failed to parse code snippet: %w", err)
+		return nil, fmt.Errorf("failed to parse code snippet: %w", err)
 	}
 
 	if len(queryChunks) == 0 {
-		return nil, nil, nil, nil, fmt.Errorf("no chunks generated from code snippet")
+		return nil, fmt.Errorf("no chunks generated from code snippet")
 	}
+	return queryChunks, nil
+}
 
-	// For each query chunk, generate embeddings and search
-	// We'll aggregate results from all query chunks
-	allResults := make(map[string]*resultWithScore)
+// chunkSearchableText is the text a chunk's code vector embeds: its content with context
+func (ccs *CodeChunkService) chunkSearchableText(chunk *model.CodeChunk) string {
+	return chunk.GetSearchableText(true, ccs.maxContentChars)
+}
+
+// chunkDocText is the text a chunk's doc vector embeds: its name, signature
+// and docstring, or its content when it has none of them
+func (ccs *CodeChunkService) chunkDocText(chunk *model.CodeChunk) string {
+	var parts []string
+	for _, part := range []string{chunk.Name, chunk.Signature, chunk.Docstring} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return chunk.GetSearchableText(false, ccs.maxContentChars)
+	}
+	return strings.Join(parts, "\n")
+}
 
+// embedSnippet embeds the text of each query chunk using the given model.
+// Chunks whose embedding fails are left out of the queries.
+func (ccs *CodeChunkService) embedSnippet(ctx context.Context, embedding EmbeddingModel, queryChunks []*model.CodeChunk, queryText func(*model.CodeChunk) string) []snippetQuery {
+	queries := make([]snippetQuery, 0, len(queryChunks))
 	for queryChunkIndex, queryChunk := range queryChunks {
-		// Generate embedding for the query chunk (with context)
-		searchableText := queryChunk.GetSearchableText(true)
-		queryVector, err := ccs.embedding.GenerateEmbedding(ctx, searchableText)
+		queryVector, err := embedding.GenerateEmbedding(ctx, queryText(queryChunk))
 		if err != nil {
 			ccs.logger.Warn("Failed to generate embedding for query chunk",
 				zap.String("chunk_type", string(queryChunk.ChunkType)),
 				zap.Error(err))
 			continue
 		}
+		queries = append(queries, snippetQuery{chunkIndex: queryChunkIndex, vector: queryVector})
+	}
+
+	return queries
+}
+
+// collectionEmbeddingModel returns the registered model a collection was built
+// with. Collections that predate embedding metadata are searched with the
+// service's own model. ErrEmbeddingModelUnavailable is returned when the
+// recorded model is not registered or produces a different dimension.
+func (ccs *CodeChunkService) collectionEmbeddingModel(ctx context.Context, collectionName string) (EmbeddingModel, error) {
+	recorded, err := ccs.vectorDB.CollectionEmbedding(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection embedding model: %w", err)
+	}
+	if recorded == nil {
+		return ccs.embedding, nil
+	}
+
+	embedding, ok := ccs.searchModels[recorded.Model]
+	if !ok {
+		return nil, fmt.Errorf("%w: collection %s was built with %s, which is not configured",
+			ErrEmbeddingModelUnavailable, collectionName, recorded.Model)
+	}
+	if embedding.GetDimension() != recorded.Dimension {
+		return nil, fmt.Errorf("%w: collection %s was built with %s at dimension %d, configured model produces %d",
+			ErrEmbeddingModelUnavailable, collectionName, recorded.Model, recorded.Dimension, embedding.GetDimension())
+	}
+	return embedding, nil
+}
 
+// searchVector searches the named vector of a collection, or its unnamed
+// vector when vectorName is empty
+func (ccs *CodeChunkService) searchVector(ctx context.Context, collectionName, vectorName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	if vectorName == "" {
+		return ccs.vectorDB.SearchSimilar(ctx, collectionName, queryVector, limit, filter)
+	}
+	return ccs.vectorDB.SearchSimilarNamed(ctx, collectionName, vectorName, queryVector, limit, filter)
+}
+
+// searchSnippet searches one vector of a collection with every query chunk,
+// keeping each result chunk once with its highest score. Results are returned
+// in descending score order, at most limit of them.
+func (ccs *CodeChunkService) searchSnippet(ctx context.Context, collectionName, vectorName string, queries []snippetQuery, limit int, filter map[string]interface{}) []*resultWithScore {
+	// Aggregate results from all query chunks
+	allResults := make(map[string]*resultWithScore)
+
+	for _, query := range queries {
 		// Search in vector database
-		resultChunks, scores, err := ccs.vectorDB.SearchSimilar(ctx, collectionName, queryVector, limit, filter)
+		resultChunks, scores, err := ccs.searchVector(ctx, collectionName, vectorName, query.vector, limit, filter)
 		if err != nil {
 			ccs.logger.Warn("Failed to search for query chunk",
-				zap.String("chunk_type", string(queryChunk.ChunkType)),
+				zap.String("collection", collectionName),
+				zap.Int("query_chunk_index", query.chunkIndex),
 				zap.Error(err))
 			continue
 		}
@@ -423,48 +1020,31 @@ func (ccs *CodeChunkService) SearchSimilarCodeBySnippet(ctx context.Context, col
 				// Keep the higher score and update query chunk index
 				if scores[i] > existing.score {
 					existing.score = scores[i]
-					existing.queryChunkIndex = queryChunkIndex
+					existing.queryChunkIndex = query.chunkIndex
 				}
 			} else {
 				allResults[chunk.ID] = &resultWithScore{
 					chunk:           chunk,
 					score:           scores[i],
-					queryChunkIndex: queryChunkIndex,
+					queryChunkIndex: query.chunkIndex,
 				}
 			}
 		}
 	}
 
-	// Convert map to slices and sort by score
-	chunks := make([]*model.CodeChunk, 0, len(allResults))
-	scores := make([]float32, 0, len(allResults))
-	queryChunkIndices := make([]int, 0, len(allResults))
-
+	results := make([]*resultWithScore, 0, len(allResults))
 	for _, result := range allResults {
-		chunks = append(chunks, result.chunk)
-		scores = append(scores, result.score)
-		queryChunkIndices = append(queryChunkIndices, result.queryChunkIndex)
-	}
-
-	// Sort by score descending (keep indices aligned)
-	for i := 0; i < len(scores)-1; i++ {
-		for j := i + 1; j < len(scores); j++ {
-			if scores[j] > scores[i] {
-				scores[i], scores[j] = scores[j], scores[i]
-				chunks[i], chunks[j] = chunks[j], chunks[i]
-				queryChunkIndices[i], queryChunkIndices[j] = queryChunkIndices[j], queryChunkIndices[i]
-			}
-		}
+		results = append(results, result)
 	}
 
+	// Sort by score descending
+	sort.Slice(results, func(i, j int) bool { return results[i].score > results[j].score })
+
 	// Limit results
-	if len(chunks) > limit {
-		chunks = chunks[:limit]
-		scores = scores[:limit]
-		queryChunkIndices = queryChunkIndices[:limit]
+	if len(results) > limit {
+		results = results[:limit]
 	}
-
-	return queryChunks, chunks, scores, queryChunkIndices, nil
+	return results
 }
 
 type resultWithScore struct {
@@ -473,8 +1053,48 @@ type resultWithScore struct {
 	queryChunkIndex int
 }
 
-// CreateCollection creates a new collection in the vector database
+// EnsureCollection creates a collection with the configured embedding
+// dimension and distance metric unless it already exists. It is safe to call
+// concurrently and repeatedly: a collection is created at most once, and one
+// this service has already ensured is not checked again.
+func (ccs *CodeChunkService) EnsureCollection(ctx context.Context, collectionName string) error {
+	ccs.collectionsMutex.Lock()
+	ensured := ccs.ensuredCollections[collectionName]
+	ccs.collectionsMutex.Unlock()
+	if ensured {
+		return nil
+	}
+
+	if err := ccs.CreateCollection(ctx, collectionName); err != nil {
+		return err
+	}
+
+	ccs.collectionsMutex.Lock()
+	ccs.ensuredCollections[collectionName] = true
+	ccs.collectionsMutex.Unlock()
+	return nil
+}
+
+// collectionLock returns the mutex serializing creation of a collection
+func (ccs *CodeChunkService) collectionLock(collectionName string) *sync.Mutex {
+	ccs.collectionsMutex.Lock()
+	defer ccs.collectionsMutex.Unlock()
+
+	lock, ok := ccs.collectionLocks[collectionName]
+	if !ok {
+		lock = &sync.Mutex{}
+		ccs.collectionLocks[collectionName] = lock
+	}
+	return lock
+}
+
+// CreateCollection creates a new collection in the vector database, or
+// validates its distance metric if it exists
 func (ccs *CodeChunkService) CreateCollection(ctx context.Context, collectionName string) error {
+	lock := ccs.collectionLock(collectionName)
+	lock.Lock()
+	defer lock.Unlock()
+
 	exists, err := ccs.vectorDB.CollectionExists(ctx, collectionName)
 	if err != nil {
 		return fmt.Errorf("failed to check collection existence: %w", err)
@@ -482,24 +1102,72 @@ func (ccs *CodeChunkService) CreateCollection(ctx context.Context, collectionNam
 
 	if exists {
 		ccs.logger.Info("Collection already exists", zap.String("collection", collectionName))
+		if err := ccs.ValidateCollection(ctx, collectionName); err != nil && !errors.Is(err, ErrDistanceMismatch) {
+			return err
+		}
 		return nil
 	}
 
 	dimension := ccs.embedding.GetDimension()
-	if err := ccs.vectorDB.CreateCollection(ctx, collectionName, dimension, DistanceMetricCosine); err != nil {
+	if ccs.docEmbedding != nil {
+		vectors := []NamedVector{
+			{Name: VectorNameCode, Dimension: dimension},
+			{Name: VectorNameDoc, Dimension: ccs.docEmbedding.GetDimension()},
+		}
+		if err := ccs.vectorDB.CreateCollectionWithNamedVectors(ctx, collectionName, vectors, ccs.distance); err != nil {
+			return fmt.Errorf("failed to create collection: %w", err)
+		}
+	} else if err := ccs.vectorDB.CreateCollection(ctx, collectionName, dimension, ccs.distance); err != nil {
 		return fmt.Errorf("failed to create collection: %w", err)
 	}
 
-	ccs.logger.Info("Created collection", zap.String("collection", collectionName), zap.Int("dimension", dimension))
+	// Searches embed their query with the model recorded here
+	modelName := ccs.embedding.GetModelName()
+	if err := ccs.vectorDB.SetCollectionEmbedding(ctx, collectionName, CollectionEmbedding{Model: modelName, Dimension: dimension}); err != nil {
+		return fmt.Errorf("failed to record collection embedding model: %w", err)
+	}
+
+	ccs.logger.Info("Created collection",
+		zap.String("collection", collectionName),
+		zap.String("embedding_model", modelName),
+		zap.Int("dimension", dimension),
+		zap.String("distance", string(ccs.distance)))
+	return nil
+}
+
+// ValidateCollection checks that an existing collection uses the configured
+// distance metric. A mismatch is logged as a warning and returned as
+// ErrDistanceMismatch, since searches against it silently lose quality.
+func (ccs *CodeChunkService) ValidateCollection(ctx context.Context, collectionName string) error {
+	distance, err := ccs.vectorDB.CollectionDistance(ctx, collectionName)
+	if err != nil {
+		return fmt.Errorf("failed to read collection distance: %w", err)
+	}
+
+	if distance != ccs.distance {
+		ccs.logger.Warn("Collection distance metric differs from configuration; recreate the collection to apply it",
+			zap.String("collection", collectionName),
+			zap.String("collection_distance", string(distance)),
+			zap.String("configured_distance", string(ccs.distance)))
+		return fmt.Errorf("%w: collection %s uses %s, configured %s", ErrDistanceMismatch, collectionName, distance, ccs.distance)
+	}
 	return nil
 }
 
 // DeleteCollection deletes a collection from the vector database
 func (ccs *CodeChunkService) DeleteCollection(ctx context.Context, collectionName string) error {
+	lock := ccs.collectionLock(collectionName)
+	lock.Lock()
+	defer lock.Unlock()
+
 	if err := ccs.vectorDB.DeleteCollection(ctx, collectionName); err != nil {
 		return fmt.Errorf("failed to delete collection: %w", err)
 	}
 
+	ccs.collectionsMutex.Lock()
+	delete(ccs.ensuredCollections, collectionName)
+	ccs.collectionsMutex.Unlock()
+
 	ccs.logger.Info("Deleted collection", zap.String("collection", collectionName))
 	return nil
 }
@@ -536,7 +1204,10 @@ func (ccs *CodeChunkService) parseAndChunk(ctx context.Context, filePath, langua
 	rootNode := tree.RootNode()
 	visitor.TraverseNode(ctx, rootNode, nil)
 
-	return visitor.GetChunks(), nil
+	// Split oversized chunks into overlapping windows so they embed well, then
+	// shorten any content still over the embedding model's input limit
+	chunks := chunk.SplitOversizedChunks(visitor.GetChunks(), ccs.maxChunkLines, ccs.overlapLines)
+	return chunk.LimitContentLength(chunks, ccs.maxContentChars, ccs.contentLimit), nil
 }
 
 func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, chunks []*model.CodeChunk) ([]*model.CodeChunk, error) {
@@ -562,7 +1233,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 		validChunks := make([]*model.CodeChunk, 0, len(needsOneEmbedding))
 
 		for _, chunk := range needsOneEmbedding {
-			text := chunk.GetSearchableText(true) // with context
+			text := chunk.GetSearchableText(true, ccs.maxContentChars) // with context
 			if text != "" {
 				texts = append(texts, text)
 				validChunks = append(validChunks, chunk)
@@ -577,7 +1248,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 		if len(texts) == 0 {
 			ccs.logger.Warn("No valid texts for embedding generation in needsOneEmbedding")
 		} else {
-			embeddings, err := ccs.embedding.GenerateEmbeddings(ctx, texts)
+			embeddings, err := ccs.generateEmbeddings(ctx, texts)
 			if err != nil {
 				return nil, fmt.Errorf("failed to generate embeddings for standard chunks: %w", err)
 			}
@@ -609,7 +1280,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 		validTwoEmbeddingChunks := make([]*model.CodeChunk, 0, len(needsTwoEmbeddings))
 
 		for _, chunk := range needsTwoEmbeddings {
-			text := chunk.GetSearchableText(true)
+			text := chunk.GetSearchableText(true, ccs.maxContentChars)
 			if text != "" {
 				textsWithContext = append(textsWithContext, text)
 				validTwoEmbeddingChunks = append(validTwoEmbeddingChunks, chunk)
@@ -624,7 +1295,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 		if len(textsWithContext) == 0 {
 			ccs.logger.Warn("No valid texts for embedding generation in needsTwoEmbeddings")
 		} else {
-			embeddingsWithContext, err := ccs.embedding.GenerateEmbeddings(ctx, textsWithContext)
+			embeddingsWithContext, err := ccs.generateEmbeddings(ctx, textsWithContext)
 			if err != nil {
 				return nil, fmt.Errorf("failed to generate embeddings with context: %w", err)
 			}
@@ -632,7 +1303,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 			// Second: without context
 			textsWithoutContext := make([]string, 0, len(validTwoEmbeddingChunks))
 			for _, chunk := range validTwoEmbeddingChunks {
-				text := chunk.GetSearchableText(false)
+				text := chunk.GetSearchableText(false, ccs.maxContentChars)
 				if text != "" {
 					textsWithoutContext = append(textsWithoutContext, text)
 				} else {
@@ -641,7 +1312,7 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 				}
 			}
 
-			embeddingsWithoutContext, err = ccs.embedding.GenerateEmbeddings(ctx, textsWithoutContext)
+			embeddingsWithoutContext, err = ccs.generateEmbeddings(ctx, textsWithoutContext)
 			if err != nil {
 				return nil, fmt.Errorf("failed to generate embeddings without context: %w", err)
 			}
@@ -722,22 +1393,130 @@ func (ccs *CodeChunkService) generateAndPrepareEmbeddings(ctx context.Context, c
 		result = append(result, chunkNoContext)
 	}
 
+	if ccs.docEmbedding != nil {
+		if err := ccs.addDocEmbeddings(ctx, result); err != nil {
+			return nil, err
+		}
+	}
+
 	return result, nil
 }
 
-func (ccs *CodeChunkService) detectLanguage(filePath string) string {
-	ext := filepath.Ext(filePath)
-	switch ext {
-	case ".go":
-		return "go"
-	case ".py", ".pyw":
-		return "python"
-	case ".java":
-		return "java"
-	case ".js", ".jsx", ".mjs":
-		return "javascript"
-	case ".ts", ".tsx":
-		return "typescript"
+// upsertChunks stores chunks in a collection. Chunks bound for a collection
+// created with a single unnamed vector, before a doc model was configured,
+// are stored with their code embedding only.
+func (ccs *CodeChunkService) upsertChunks(ctx context.Context, collectionName string, chunks []*model.CodeChunk) error {
+	if ccs.docEmbedding != nil {
+		names, err := ccs.vectorDB.CollectionVectorNames(ctx, collectionName)
+		if err != nil {
+			return fmt.Errorf("failed to read collection vectors: %w", err)
+		}
+		if len(names) == 0 {
+			for _, chunk := range chunks {
+				chunk.NamedEmbeddings = nil
+			}
+		}
+	}
+	return ccs.vectorDB.UpsertChunks(ctx, collectionName, chunks)
+}
+
+// addDocEmbeddings embeds the description of each embedded chunk with the doc
+// model and sets the chunk's named vectors to its code and doc embeddings
+func (ccs *CodeChunkService) addDocEmbeddings(ctx context.Context, chunks []*model.CodeChunk) error {
+	embedded := make([]*model.CodeChunk, 0, len(chunks))
+	texts := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		if len(chunk.Embedding) > 0 {
+			embedded = append(embedded, chunk)
+			texts = append(texts, ccs.chunkDocText(chunk))
+		}
+	}
+	if len(texts) == 0 {
+		return nil
+	}
+
+	docEmbeddings, err := ccs.docEmbedding.GenerateEmbeddings(ctx, texts)
+	if err != nil {
+		return fmt.Errorf("failed to generate doc embeddings: %w", err)
+	}
+	if len(docEmbeddings) != len(texts) {
+		return fmt.Errorf("doc embedding model returned %d vectors for %d texts", len(docEmbeddings), len(texts))
+	}
+
+	for i, chunk := range embedded {
+		chunk.NamedEmbeddings = map[string][]float32{
+			VectorNameCode: chunk.Embedding,
+			VectorNameDoc:  docEmbeddings[i],
+		}
+	}
+	return nil
+}
+
+// generateEmbeddings embeds texts in one batch, serving cache hits from the
+// embedding cache and only sending misses to the embedding model
+func (ccs *CodeChunkService) generateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	if ccs.embeddingCache == nil {
+		return ccs.embedding.GenerateEmbeddings(ctx, texts)
+	}
+
+	modelName := ccs.embedding.GetModelName()
+	dimension := ccs.embedding.GetDimension()
+
+	results := make([][]float32, len(texts))
+	keys := make([]string, len(texts))
+	var missTexts []string
+	var missIndices []int
+
+	for i, text := range texts {
+		keys[i] = ccs.embeddingCache.Key(modelName, dimension, text)
+		if vec, ok := ccs.embeddingCache.Get(keys[i]); ok {
+			results[i] = vec
+		} else {
+			missTexts = append(missTexts, text)
+			missIndices = append(missIndices, i)
+		}
+	}
+
+	ccs.logger.Debug("Embedding cache lookup",
+		zap.Int("texts", len(texts)),
+		zap.Int("hits", len(texts)-len(missTexts)),
+		zap.Int("misses", len(missTexts)))
+
+	if len(missTexts) == 0 {
+		return results, nil
+	}
+
+	embeddings, err := ccs.embedding.GenerateEmbeddings(ctx, missTexts)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(missTexts) {
+		return nil, fmt.Errorf("embedding model returned %d vectors for %d texts", len(embeddings), len(missTexts))
+	}
+
+	for j, vec := range embeddings {
+		i := missIndices[j]
+		results[i] = vec
+		ccs.embeddingCache.Put(keys[i], vec)
+	}
+
+	return results, nil
+}
+
+// detectLanguage returns the language of a file if it is one we can chunk,
+// preferring the repository's language overrides (repo may be nil) and falling
+// back to content sniffing for extensionless files
+func (ccs *CodeChunkService) detectLanguage(filePath string, repo *config.Repository) string {
+	language := ""
+	if repo != nil {
+		language = repo.LanguageOverride(filePath)
+	}
+	if language == "" {
+		language = util.DetectFileLanguage(filePath)
+	}
+	switch language {
+	case "go", "python", "java", "javascript", "typescript":
+		return language
 	default:
 		return ""
 	}
@@ -761,42 +1540,52 @@ func (ccs *CodeChunkService) getTreeSitterLanguage(language string) (*tree_sitte
 }
 
 func (ccs *CodeChunkService) readFile(filePath string) ([]byte, error) {
-	// Use os.ReadFile which opens, reads, and closes in one operation
-	// This is more efficient and ensures file descriptors are released immediately
-	content, err := os.ReadFile(filePath)
+	// Reads in one operation, so file descriptors are released immediately;
+	// files over the size limit are rejected before reading
+	content, err := util.ReadFileWithLimit(filePath, ccs.maxFileSize)
 	if err != nil {
 		return nil, err
 	}
 	return content, nil
 }
 
-// ReadCodeFromFile reads specific lines from a file
-func (ccs *CodeChunkService) ReadCodeFromFile(filePath string, startLine, endLine int) (string, error) {
-	content, err := ccs.readFile(filePath)
+// ReadCodeFromFile reads specific lines (0-indexed, inclusive) from a file.
+// Files are read through cache, so callers serving several ranges of the same
+// file should share one; a nil cache reads the file afresh. Line numbers past
+// either end of the file are clamped to it.
+func (ccs *CodeChunkService) ReadCodeFromFile(cache *FileLineCache, filePath string, startLine, endLine int) (string, error) {
+	if cache == nil {
+		cache = ccs.NewFileLineCache()
+	}
+	lines, err := cache.Lines(filePath)
 	if err != nil {
 		return "", fmt.Errorf("failed to read file: %w", err)
 	}
 
-	lines := strings.Split(string(content), "\n")
-
-	// Validate line numbers (0-indexed internally)
-	if startLine < 0 || startLine >= len(lines) {
-		return "", fmt.Errorf("invalid start line: %d", startLine)
-	}
-	if endLine < 0 || endLine >= len(lines) {
-		endLine = len(lines) - 1
-	}
 	if startLine > endLine {
 		return "", fmt.Errorf("start line (%d) greater than end line (%d)", startLine, endLine)
 	}
 
+	lastLine := len(lines) - 1
+	clampedStart := min(max(startLine, 0), lastLine)
+	clampedEnd := min(max(endLine, clampedStart), lastLine)
+	if clampedStart != startLine || clampedEnd != endLine {
+		ccs.logger.Warn("Clamped line range to file length",
+			zap.String("file", filePath),
+			zap.Int("start_line", startLine),
+			zap.Int("end_line", endLine),
+			zap.Int("file_lines", len(lines)))
+	}
+
 	// Extract lines (inclusive)
-	codeLines := lines[startLine : endLine+1]
-	return strings.Join(codeLines, "\n"), nil
+	return strings.Join(lines[clampedStart:clampedEnd+1], "\n"), nil
 }
 
 // Close closes all resources
 func (ccs *CodeChunkService) Close() error {
+	if err := ccs.SaveEmbeddingCache(); err != nil {
+		ccs.logger.Warn("Failed to save embedding cache", zap.Error(err))
+	}
 	if ccs.vectorDB != nil {
 		return ccs.vectorDB.Close()
 	}
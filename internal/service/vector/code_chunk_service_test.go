@@ -0,0 +1,761 @@
+package vector
+
+import (
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/internal/util"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+// buildLongGoFunction returns a Go file containing a single function whose
+// declaration spans exactly bodyLines+2 lines, starting on line index 2
+func buildLongGoFunction(bodyLines int) string {
+	var sb strings.Builder
+	sb.WriteString("package sample\n\n")
+	sb.WriteString("func Long() int {\n")
+	sb.WriteString("\tx := 0\n")
+	for i := 0; i < bodyLines-2; i++ {
+		sb.WriteString(fmt.Sprintf("\tx += %d\n", i))
+	}
+	sb.WriteString("\treturn x\n")
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func TestParseAndChunkSplitsOversizedFunction(t *testing.T) {
+	const maxChunkLines = 100
+	const overlapLines = 10
+
+	ccs := NewCodeChunkService(newMockVectorDB(), newMockEmbedding("test-model", 4), 1000, 1000, maxChunkLines, overlapLines, 0, 1, zap.NewNop())
+
+	source := buildLongGoFunction(498)
+	chunks, err := ccs.parseAndChunk(context.Background(), "long.go", "go", []byte(source))
+	if err != nil {
+		t.Fatalf("parseAndChunk failed: %v", err)
+	}
+
+	var windows []*model.CodeChunk
+	for _, c := range chunks {
+		if c.ChunkType == model.ChunkTypeFunction {
+			windows = append(windows, c)
+		}
+	}
+
+	if len(windows) < 2 {
+		t.Fatalf("expected function to be split into multiple windows, got %d", len(windows))
+	}
+
+	const funcStart, funcEnd = 2, 501
+	if windows[0].StartLine != funcStart {
+		t.Errorf("first window starts at %d, want %d", windows[0].StartLine, funcStart)
+	}
+	if last := windows[len(windows)-1]; last.EndLine != funcEnd {
+		t.Errorf("last window ends at %d, want %d", last.EndLine, funcEnd)
+	}
+
+	ids := make(map[string]bool)
+	for i, w := range windows {
+		if w.Name != "Long" {
+			t.Errorf("window %d has name %q, want %q", i, w.Name, "Long")
+		}
+		if lines := w.EndLine - w.StartLine + 1; lines > maxChunkLines {
+			t.Errorf("window %d spans %d lines, exceeds max %d", i, lines, maxChunkLines)
+		}
+		if got := strings.Count(w.Content, "\n") + 1; got != w.EndLine-w.StartLine+1 {
+			t.Errorf("window %d content has %d lines but range spans %d", i, got, w.EndLine-w.StartLine+1)
+		}
+		if ids[w.ID] {
+			t.Errorf("window %d has duplicate ID %s", i, w.ID)
+		}
+		ids[w.ID] = true
+
+		if i == 0 {
+			continue
+		}
+		prev := windows[i-1]
+		if w.StartLine > prev.EndLine+1 {
+			t.Errorf("gap between window %d (ends %d) and window %d (starts %d)", i-1, prev.EndLine, i, w.StartLine)
+		}
+		if overlap := prev.EndLine - w.StartLine + 1; overlap != overlapLines {
+			t.Errorf("windows %d and %d overlap by %d lines, want %d", i-1, i, overlap, overlapLines)
+		}
+	}
+}
+
+func TestParseAndChunkKeepsSmallFunctionWhole(t *testing.T) {
+	ccs := NewCodeChunkService(newMockVectorDB(), newMockEmbedding("test-model", 4), 1000, 1000, 100, 10, 0, 1, zap.NewNop())
+
+	chunks, err := ccs.parseAndChunk(context.Background(), "short.go", "go", []byte(buildLongGoFunction(20)))
+	if err != nil {
+		t.Fatalf("parseAndChunk failed: %v", err)
+	}
+
+	functions := 0
+	for _, c := range chunks {
+		if c.ChunkType == model.ChunkTypeFunction {
+			functions++
+		}
+	}
+	if functions != 1 {
+		t.Errorf("expected a single function chunk, got %d", functions)
+	}
+}
+
+func TestParseAndChunkSignatures(t *testing.T) {
+	ccs := NewCodeChunkService(newMockVectorDB(), newMockEmbedding("test-model", 4), 1000, 1000, 0, 0, 0, 1, zap.NewNop())
+
+	tests := []struct {
+		name     string
+		language string
+		path     string
+		source   string
+		want     map[string]string // function chunk name -> signature
+	}{
+		{
+			name:     "java method",
+			language: "java",
+			path:     "Totals.java",
+			source:   "class Totals {\n  @Override\n  public static <T extends Number> List<T> sum(int[] xs,\n      final Map<String, T> weights) throws IOException {\n    return null;\n  }\n\n  int size() { return 0; }\n}\n",
+			want: map[string]string{
+				"sum":  "public static <T extends Number> List<T> sum(int[] xs, final Map<String, T> weights) throws IOException",
+				"size": "int size()",
+			},
+		},
+		{
+			name:     "typescript functions",
+			language: "typescript",
+			path:     "math.ts",
+			source:   "export const add = async <T>(a: number, b: T): Promise<number> => a + 1;\nconst id = x => x;\nfunction scale(v: number, by = 2): number { return v * by; }\nclass Counter {\n  onTick = (e: Event): void => {};\n  total(limit: number): number { return limit; }\n}\n",
+			want: map[string]string{
+				"add":    "async add<T>(a: number, b: T): Promise<number>",
+				"id":     "id(x)",
+				"scale":  "scale(v: number, by = 2): number",
+				"onTick": "onTick(e: Event): void",
+				"total":  "total(limit: number): number",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := ccs.parseAndChunk(context.Background(), tt.path, tt.language, []byte(tt.source))
+			if err != nil {
+				t.Fatalf("parseAndChunk failed: %v", err)
+			}
+
+			got := make(map[string]string)
+			for _, c := range chunks {
+				if c.ChunkType == model.ChunkTypeFunction {
+					got[c.Name] = c.Signature
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("function chunks = %v, want %d", got, len(tt.want))
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("signature of %s = %q, want %q", name, got[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadCodeFromFileSharesCacheAndClampsRanges(t *testing.T) {
+	ccs := NewCodeChunkService(newMockVectorDB(), newMockEmbedding("test-model", 4), 1000, 1000, 0, 0, 0, 1, zap.NewNop())
+
+	reads := 0
+	cache := NewFileLineCache()
+	cache.readFile = func(path string) ([]byte, error) {
+		reads++
+		return []byte("l0\nl1\nl2\nl3\nl4"), nil
+	}
+
+	tests := []struct {
+		name       string
+		start, end int
+		want       string
+		wantErr    bool
+	}{
+		{name: "in range", start: 1, end: 2, want: "l1\nl2"},
+		{name: "end past file", start: 3, end: 100, want: "l3\nl4"},
+		{name: "negative start", start: -2, end: 1, want: "l0\nl1"},
+		{name: "start past file", start: 50, end: 60, want: "l4"},
+		{name: "inverted range", start: 3, end: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ccs.ReadCodeFromFile(cache, "big.go", tt.start, tt.end)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ReadCodeFromFile(%d, %d) succeeded, want error", tt.start, tt.end)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ReadCodeFromFile(%d, %d) failed: %v", tt.start, tt.end, err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadCodeFromFile(%d, %d) = %q, want %q", tt.start, tt.end, got, tt.want)
+			}
+		})
+	}
+
+	if reads != 1 {
+		t.Errorf("file read %d times, want once", reads)
+	}
+}
+
+func TestReadCodeFromFileSkipsFilesOverMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	smallPath := filepath.Join(dir, "small.go")
+	largePath := filepath.Join(dir, "large.go")
+	if err := os.WriteFile(smallPath, []byte("package a\n\nfunc A() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(largePath, []byte("package a\n\n// "+strings.Repeat("x", 200)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ccs := NewCodeChunkService(newMockVectorDB(), newMockEmbedding("test-model", 4), 1000, 1000, 0, 0, 0, 1, zap.NewNop())
+	ccs.SetMaxFileSize(100)
+	cache := ccs.NewFileLineCache()
+
+	if code, err := ccs.ReadCodeFromFile(cache, smallPath, 2, 2); err != nil || code != "func A() {}" {
+		t.Errorf("ReadCodeFromFile(small.go) = %q, %v; want its function", code, err)
+	}
+	if _, err := ccs.ReadCodeFromFile(cache, largePath, 0, 0); !errors.Is(err, util.ErrFileTooLarge) {
+		t.Errorf("ReadCodeFromFile(large.go) error = %v, want ErrFileTooLarge", err)
+	}
+	if _, err := ccs.ReadCodeFromFile(nil, largePath, 0, 0); !errors.Is(err, util.ErrFileTooLarge) {
+		t.Errorf("ReadCodeFromFile(large.go) without a cache error = %v, want ErrFileTooLarge", err)
+	}
+}
+
+func TestProcessChangedFilesOnlyReembedsChangedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	writeFile := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	writeFile("stable.js", normalJSSource)
+	writeFile("changed.js", normalJSSource)
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+	writeFile("changed.js", strings.Replace(normalJSSource, "a + b", "b + a", 1))
+	writeFile("added.js", normalJSSource)
+
+	vectorDB := newMockVectorDB()
+	embedding := newMockEmbedding("test-model", 4)
+	ccs := NewCodeChunkService(vectorDB, embedding, 5, 5, 0, 0, 0, 1, zap.NewNop())
+
+	repo := &config.Repository{Name: "test", Path: dir}
+	if _, err := ccs.ProcessChangedFiles(context.Background(), repo, "test"); err != nil {
+		t.Fatalf("ProcessChangedFiles failed: %v", err)
+	}
+
+	chunked := vectorDB.filePaths("test")
+	if len(chunked) != 2 || !chunked["changed.js"] || !chunked["added.js"] {
+		t.Errorf("only changed.js and added.js should be chunked, got %v", chunked)
+	}
+	if embedding.calls == 0 {
+		t.Error("expected the modified file to be embedded")
+	}
+}
+
+// pathIndexedVectorDB is a mockVectorDB that reports the chunks stored for a
+// file, so that stale chunks can be found and embeddings reused
+type pathIndexedVectorDB struct {
+	*mockVectorDB
+}
+
+func (p pathIndexedVectorDB) GetChunksByFilePath(ctx context.Context, collectionName string, filePath string) ([]*model.CodeChunk, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var chunks []*model.CodeChunk
+	for _, c := range p.chunks[collectionName] {
+		if c.FilePath == filePath {
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks, nil
+}
+
+// failingEmbedding is a mockEmbedding whose calls fail while fail is set
+type failingEmbedding struct {
+	*mockEmbedding
+	fail bool
+}
+
+func (f *failingEmbedding) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := f.GenerateEmbeddings(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+func (f *failingEmbedding) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	if f.fail {
+		return nil, errors.New("embedding service unavailable")
+	}
+	return f.mockEmbedding.GenerateEmbeddings(ctx, texts)
+}
+
+func TestProcessChangedFilesKeepsChunksWhenEmbeddingFails(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not available")
+	}
+
+	dir := t.TempDir()
+	git := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+	writeFile := func(content string) {
+		if err := os.WriteFile(filepath.Join(dir, "changed.js"), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write changed.js: %v", err)
+		}
+	}
+
+	writeFile(normalJSSource)
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-q", "-m", "initial")
+
+	vectorDB := pathIndexedVectorDB{newMockVectorDB()}
+	embedding := &failingEmbedding{mockEmbedding: newMockEmbedding("test-model", 4)}
+	ccs := NewCodeChunkService(vectorDB, embedding, 5, 5, 0, 0, 0, 1, zap.NewNop())
+	repo := &config.Repository{Name: "test", Path: dir}
+
+	names := func() []string {
+		chunks, _ := vectorDB.GetChunksByFilePath(context.Background(), "test", "changed.js")
+		var names []string
+		for _, c := range chunks {
+			if c.ChunkType == model.ChunkTypeFunction {
+				names = append(names, c.Name)
+			}
+		}
+		slices.Sort(names)
+		return names
+	}
+	process := func() {
+		t.Helper()
+		if _, err := ccs.ProcessChangedFiles(context.Background(), repo, "test"); err != nil {
+			t.Fatalf("ProcessChangedFiles failed: %v", err)
+		}
+	}
+
+	writeFile(strings.Replace(normalJSSource, "a + b", "b + a", 1))
+	process()
+	if got, want := names(), []string{"add", "greet"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("functions after the first edit = %v, want %v", got, want)
+	}
+
+	// Renaming greet needs a new embedding, which fails: the previous chunks stay
+	renamed := strings.Replace(normalJSSource, "function greet", "function welcome", 1)
+	writeFile(renamed)
+	embedding.fail = true
+	process()
+	if got, want := names(), []string{"add", "greet"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("functions after a failed embedding = %v, want the previous %v", got, want)
+	}
+
+	// Once embedding works again the renamed function replaces the stale one
+	embedding.fail = false
+	process()
+	if got, want := names(), []string{"add", "welcome"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("functions after the retry = %v, want %v", got, want)
+	}
+}
+
+func TestProcessDirectoryUsesBoundedWorkers(t *testing.T) {
+	const numFiles, numThreads = 7, 3
+
+	dir := t.TempDir()
+	for i := 0; i < numFiles; i++ {
+		name := fmt.Sprintf("file%d.js", i)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(normalJSSource), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	vectorDB := newMockVectorDB()
+	embedding := newConcurrencyEmbedding(numThreads)
+	ccs := NewCodeChunkService(vectorDB, embedding, 5, 5, 0, 0, 0, numThreads, zap.NewNop())
+
+	chunks, err := ccs.parseAndChunk(context.Background(), "file0.js", "javascript", []byte(normalJSSource))
+	if err != nil {
+		t.Fatalf("parseAndChunk failed: %v", err)
+	}
+
+	total, err := ccs.ProcessDirectory(context.Background(), dir, "test", nil)
+	if err != nil {
+		t.Fatalf("ProcessDirectory failed: %v", err)
+	}
+	if want := numFiles * len(chunks); total != want {
+		t.Errorf("total chunks = %d, want %d", total, want)
+	}
+	if chunked := vectorDB.filePaths("test"); len(chunked) != numFiles {
+		t.Errorf("chunked %d files, want %d: %v", len(chunked), numFiles, chunked)
+	}
+	if got := embedding.MaxInFlight(); got != numThreads {
+		t.Errorf("max concurrent embedding calls = %d, want %d", got, numThreads)
+	}
+}
+
+func TestProcessDirectoryStopsWhenCancelled(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte(normalJSSource), 0644); err != nil {
+		t.Fatalf("failed to write app.js: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ccs := NewCodeChunkService(newMockVectorDB(), newMockEmbedding("test-model", 4), 5, 5, 0, 0, 0, 2, zap.NewNop())
+
+	if _, err := ccs.ProcessDirectory(ctx, dir, "test", nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("ProcessDirectory with cancelled context: err = %v, want context.Canceled", err)
+	}
+}
+
+func TestProcessDirectoryCreatesCollectionOnce(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.js"), []byte(normalJSSource), 0644); err != nil {
+		t.Fatalf("failed to write app.js: %v", err)
+	}
+
+	vectorDB := newMockVectorDB()
+	ccs := NewCodeChunkService(vectorDB, newMockEmbedding("test-model", 4), 5, 5, 0, 0, 0, 2, zap.NewNop())
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = ccs.ProcessDirectory(context.Background(), dir, "fresh", nil)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("ProcessDirectory %d: %v", i, err)
+		}
+	}
+	if got := vectorDB.creates["fresh"]; got != 1 {
+		t.Errorf("collection created %d times, want 1", got)
+	}
+	if got := vectorDB.models["fresh"]; got.Model != "test-model" || got.Dimension != 4 {
+		t.Errorf("recorded embedding = %+v, want test-model with dimension 4", got)
+	}
+
+	// Searching a collection that was never indexed creates it too
+	if _, _, err := ccs.SearchSimilarCode(context.Background(), "unindexed", "add numbers", 5, nil); err != nil {
+		t.Fatalf("SearchSimilarCode: %v", err)
+	}
+	if got := vectorDB.creates["unindexed"]; got != 1 {
+		t.Errorf("searched collection created %d times, want 1", got)
+	}
+}
+
+func TestCreateCollectionUsesConfiguredDistance(t *testing.T) {
+	ctx := context.Background()
+	vectorDB := newMockVectorDB()
+	ccs := NewCodeChunkService(vectorDB, newMockEmbedding("test-model", 4), 5, 5, 0, 0, 0, 1, zap.NewNop())
+
+	if err := ccs.CreateCollection(ctx, "default"); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	if got := vectorDB.distances["default"]; got != DistanceMetricCosine {
+		t.Errorf("default collection distance = %q, want %q", got, DistanceMetricCosine)
+	}
+
+	distance, err := ParseDistanceMetric("dot")
+	if err != nil {
+		t.Fatalf("ParseDistanceMetric: %v", err)
+	}
+	ccs.SetDistanceMetric(distance)
+	if err := ccs.CreateCollection(ctx, "dot"); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	if got := vectorDB.distances["dot"]; got != DistanceMetricDot {
+		t.Errorf("collection distance = %q, want %q", got, DistanceMetricDot)
+	}
+	if err := ccs.ValidateCollection(ctx, "dot"); err != nil {
+		t.Errorf("ValidateCollection on matching collection: %v", err)
+	}
+
+	// The cosine collection exists, so it is kept and the mismatch reported
+	if err := ccs.ValidateCollection(ctx, "default"); !errors.Is(err, ErrDistanceMismatch) {
+		t.Errorf("ValidateCollection = %v, want ErrDistanceMismatch", err)
+	}
+	if err := ccs.CreateCollection(ctx, "default"); err != nil {
+		t.Errorf("CreateCollection on mismatched collection: %v", err)
+	}
+	if got := vectorDB.distances["default"]; got != DistanceMetricCosine {
+		t.Errorf("existing collection distance = %q, want it unchanged", got)
+	}
+}
+
+func TestSearchAcrossCollectionsMergesByScore(t *testing.T) {
+	vectorDB := &scoredVectorDB{
+		mockVectorDB: newMockVectorDB(),
+		results: map[string][]scoredChunk{
+			"api": {
+				{&model.CodeChunk{ID: "api-1", FilePath: "handler.go"}, 0.91},
+				{&model.CodeChunk{ID: "api-2", FilePath: "router.go"}, 0.62},
+			},
+			"worker": {
+				{&model.CodeChunk{ID: "worker-1", FilePath: "job.go"}, 0.85},
+				{&model.CodeChunk{ID: "worker-2", FilePath: "queue.go"}, 0.7},
+				{&model.CodeChunk{ID: "worker-3", FilePath: "retry.go"}, 0.4},
+			},
+		},
+	}
+	ccs := NewCodeChunkService(vectorDB, newMockEmbedding("test-model", 4), 5, 5, 0, 0, 0, 1, zap.NewNop())
+
+	snippet := "package p\n\nfunc f() int {\n\treturn 1\n}\n"
+	queryChunks, results, err := ccs.SearchAcrossCollections(context.Background(), []string{"api", "worker", "missing"}, snippet, "go", 4)
+	if err != nil {
+		t.Fatalf("SearchAcrossCollections: %v", err)
+	}
+	if len(queryChunks) == 0 {
+		t.Error("no query chunks returned")
+	}
+
+	var got []string
+	for i, result := range results {
+		got = append(got, result.Collection+"/"+result.Chunk.ID)
+		if i > 0 && result.Score > results[i-1].Score {
+			t.Errorf("result %d score %v above previous %v", i, result.Score, results[i-1].Score)
+		}
+	}
+	want := "api/api-1,worker/worker-1,worker/worker-2,api/api-2"
+	if strings.Join(got, ",") != want {
+		t.Errorf("results = %v, want %s", got, want)
+	}
+}
+
+func TestSearchUsesCollectionEmbeddingModel(t *testing.T) {
+	ctx := context.Background()
+	vectorDB := newMockVectorDB()
+	modelA := newMockEmbedding("model-a", 4)
+	builder := NewCodeChunkService(vectorDB, modelA, 5, 5, 0, 0, 0, 1, zap.NewNop())
+	if err := builder.CreateCollection(ctx, "repo"); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	if got := vectorDB.models["repo"]; got != (CollectionEmbedding{Model: "model-a", Dimension: 4}) {
+		t.Errorf("recorded embedding = %+v, want model-a at dimension 4", got)
+	}
+
+	modelB := newMockEmbedding("model-b", 4)
+	searcher := NewCodeChunkService(vectorDB, modelB, 5, 5, 0, 0, 0, 1, zap.NewNop())
+	snippet := "package p\n\nfunc f() int {\n\treturn 1\n}\n"
+
+	if _, _, err := searcher.SearchSimilarCode(ctx, "repo", "query", 5, nil); !errors.Is(err, ErrEmbeddingModelUnavailable) {
+		t.Errorf("SearchSimilarCode with model-b = %v, want ErrEmbeddingModelUnavailable", err)
+	}
+	if _, _, _, _, err := searcher.SearchSimilarCodeBySnippet(ctx, "repo", snippet, "go", 5, nil); !errors.Is(err, ErrEmbeddingModelUnavailable) {
+		t.Errorf("SearchSimilarCodeBySnippet with model-b = %v, want ErrEmbeddingModelUnavailable", err)
+	}
+	if modelB.calls != 0 {
+		t.Errorf("model-b embedded %d times, want it never used", modelB.calls)
+	}
+
+	// A registered model with the wrong dimension is refused as well
+	searcher.RegisterEmbeddingModel(newMockEmbedding("model-a", 8))
+	if _, _, err := searcher.SearchSimilarCode(ctx, "repo", "query", 5, nil); !errors.Is(err, ErrEmbeddingModelUnavailable) {
+		t.Errorf("SearchSimilarCode with 8-dimension model-a = %v, want ErrEmbeddingModelUnavailable", err)
+	}
+
+	searcher.RegisterEmbeddingModel(modelA)
+	if _, _, err := searcher.SearchSimilarCode(ctx, "repo", "query", 5, nil); err != nil {
+		t.Fatalf("SearchSimilarCode with model-a registered: %v", err)
+	}
+	if modelA.calls != 1 || modelB.calls != 0 {
+		t.Errorf("embedding calls = model-a %d, model-b %d; want the query embedded once with model-a", modelA.calls, modelB.calls)
+	}
+}
+
+func TestNamedVectorsStoredAndSearched(t *testing.T) {
+	ctx := context.Background()
+	vectorDB := newMockVectorDB()
+	docModel := newMockEmbedding("doc-model", 3)
+	ccs := NewCodeChunkService(vectorDB, newMockEmbedding("code-model", 4), 5, 5, 0, 0, 0, 1, zap.NewNop())
+	ccs.SetDocEmbeddingModel(docModel)
+
+	if err := ccs.CreateCollection(ctx, "repo"); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	want := []NamedVector{{Name: VectorNameCode, Dimension: 4}, {Name: VectorNameDoc, Dimension: 3}}
+	if got := vectorDB.named["repo"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("named vectors = %+v, want %+v", got, want)
+	}
+
+	source := "package p\n\n// Add returns the sum of a and b\nfunc Add(a, b int) int {\n\treturn a + b\n}\n"
+	chunks, err := ccs.ProcessFileWithContent(ctx, "add.go", "go", "repo", []byte(source))
+	if err != nil || len(chunks) == 0 {
+		t.Fatalf("ProcessFileWithContent = %d chunks, %v", len(chunks), err)
+	}
+	for id, stored := range vectorDB.chunks["repo"] {
+		if len(stored.NamedEmbeddings[VectorNameCode]) != 4 || len(stored.NamedEmbeddings[VectorNameDoc]) != 3 {
+			t.Errorf("chunk %s stored with named vectors %v, want code of 4 and doc of 3 dimensions", id, stored.NamedEmbeddings)
+		}
+	}
+
+	snippet := "package p\n\nfunc Sum(x, y int) int {\n\treturn x + y\n}\n"
+	docCalls, _ := docModel.Calls()
+	if _, _, _, _, err := ccs.SearchSimilarCodeBySnippetInVector(ctx, "repo", VectorNameDoc, snippet, "go", 5, nil); err != nil {
+		t.Fatalf("search doc vector: %v", err)
+	}
+	if calls, _ := docModel.Calls(); calls == docCalls {
+		t.Error("doc vector query was not embedded with the doc model")
+	}
+	if _, _, _, _, err := ccs.SearchSimilarCodeBySnippet(ctx, "repo", snippet, "go", 5, nil); err != nil {
+		t.Fatalf("SearchSimilarCodeBySnippet: %v", err)
+	}
+	if _, _, _, _, err := ccs.SearchSimilarCodeBySnippetInVector(ctx, "repo", "tests", snippet, "go", 5, nil); err == nil {
+		t.Error("search of an undeclared vector succeeded")
+	}
+
+	searched := strings.Join(vectorDB.searched, ",")
+	if !strings.HasPrefix(searched, VectorNameDoc) || !strings.HasSuffix(searched, VectorNameCode) || strings.Contains(searched, "tests") {
+		t.Errorf("searched vectors = %q, want doc queries then code queries", searched)
+	}
+}
+
+func TestUnnamedCollectionSearchedAfterDocModelConfigured(t *testing.T) {
+	ctx := context.Background()
+	vectorDB := newMockVectorDB()
+	codeModel := newMockEmbedding("code-model", 4)
+
+	// "legacy" predates the doc model and keeps its single unnamed vector
+	legacy := NewCodeChunkService(vectorDB, codeModel, 5, 5, 0, 0, 0, 1, zap.NewNop())
+	if err := legacy.CreateCollection(ctx, "legacy"); err != nil {
+		t.Fatalf("CreateCollection legacy: %v", err)
+	}
+	ccs := NewCodeChunkService(vectorDB, codeModel, 5, 5, 0, 0, 0, 1, zap.NewNop())
+	ccs.SetDocEmbeddingModel(newMockEmbedding("doc-model", 3))
+	if err := ccs.CreateCollection(ctx, "named"); err != nil {
+		t.Fatalf("CreateCollection named: %v", err)
+	}
+
+	source := "package p\n\n// Add returns the sum of a and b\nfunc Add(a, b int) int {\n\treturn a + b\n}\n"
+	if _, err := ccs.ProcessFileWithContent(ctx, "add.go", "go", "legacy", []byte(source)); err != nil {
+		t.Fatalf("ProcessFileWithContent: %v", err)
+	}
+	if len(vectorDB.chunks["legacy"]) == 0 {
+		t.Fatal("no chunks stored in the unnamed collection")
+	}
+	for id, stored := range vectorDB.chunks["legacy"] {
+		if stored.NamedEmbeddings != nil || len(stored.Embedding) != 4 {
+			t.Errorf("chunk %s stored with named vectors %v, want only its unnamed code embedding", id, stored.NamedEmbeddings)
+		}
+	}
+
+	snippet := "package p\n\nfunc Sum(x, y int) int {\n\treturn x + y\n}\n"
+	if _, _, err := ccs.SearchSimilarCode(ctx, "legacy", "sum", 5, nil); err != nil {
+		t.Fatalf("SearchSimilarCode: %v", err)
+	}
+	if _, _, _, _, err := ccs.SearchSimilarCodeBySnippet(ctx, "legacy", snippet, "go", 5, nil); err != nil {
+		t.Fatalf("SearchSimilarCodeBySnippet: %v", err)
+	}
+	if _, _, _, _, err := ccs.SearchSimilarCodeBySnippetInVector(ctx, "legacy", VectorNameDoc, snippet, "go", 5, nil); err == nil {
+		t.Error("doc vector search of an unnamed collection succeeded")
+	}
+	if _, _, err := ccs.SearchAcrossCollections(ctx, []string{"legacy", "named"}, snippet, "go", 5); err != nil {
+		t.Fatalf("SearchAcrossCollections: %v", err)
+	}
+
+	if vectorDB.mismatch != 0 {
+		t.Errorf("%d searches used a vector their collection doesn't have; searched %q", vectorDB.mismatch, vectorDB.searched)
+	}
+	if !slices.Contains(vectorDB.searched, "") || !slices.Contains(vectorDB.searched, VectorNameCode) {
+		t.Errorf("searched vectors = %q, want the unnamed vector and the named collection's code vector", vectorDB.searched)
+	}
+}
+
+func TestProcessFileSkipsFilesOverMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	normalPath := filepath.Join(dir, "normal.js")
+	largePath := filepath.Join(dir, "large.js")
+	if err := os.WriteFile(normalPath, []byte(normalJSSource), 0644); err != nil {
+		t.Fatalf("failed to write normal.js: %v", err)
+	}
+	large := normalJSSource + "// " + strings.Repeat("x", 200) + "\n"
+	if err := os.WriteFile(largePath, []byte(large), 0644); err != nil {
+		t.Fatalf("failed to write large.js: %v", err)
+	}
+
+	vectorDB := newMockVectorDB()
+	ccs := NewCodeChunkService(vectorDB, newMockEmbedding("test-model", 4), 5, 5, 0, 0, 0, 1, zap.NewNop())
+	ccs.SetMaxFileSize(int64(len(normalJSSource) + 100))
+
+	ctx := context.Background()
+	chunks, err := ccs.ProcessFile(ctx, largePath, "javascript", "test")
+	if err != nil || chunks != nil {
+		t.Errorf("ProcessFile(large.js) = %d chunks, %v; want it skipped", len(chunks), err)
+	}
+	chunks, err = ccs.ProcessFile(ctx, normalPath, "javascript", "test")
+	if err != nil || len(chunks) == 0 {
+		t.Fatalf("ProcessFile(normal.js) = %d chunks, %v; want chunks", len(chunks), err)
+	}
+
+	chunked := vectorDB.filePaths("test")
+	if len(chunked) != 1 || !chunked[normalPath] {
+		t.Errorf("only normal.js should be chunked, got %v", chunked)
+	}
+}
+
+func TestSearchableTextUsesConfiguredContentLimit(t *testing.T) {
+	content := "func Greet() string {\n\treturn \"" + strings.Repeat("é", 200) + "\"\n}"
+	c := &model.CodeChunk{ChunkType: model.ChunkTypeFunction, Content: content, ModuleName: "greet"}
+
+	ccs := NewCodeChunkService(newMockVectorDB(), newMockEmbedding("test-model", 4), 1000, 1000, 0, 0, 0, 1, zap.NewNop())
+	if text := ccs.chunkSearchableText(c); text != "Module: greet\n"+content {
+		t.Errorf("searchable text under the default limit = %q, want the whole content", text)
+	}
+
+	ccs.SetMaxContentLength(100, "")
+	text := ccs.chunkSearchableText(c)
+	body := strings.TrimPrefix(text, "Module: greet\n")
+	if n := utf8.RuneCountInString(body); n != 100 || !utf8.ValidString(body) {
+		t.Errorf("searchable content is %d characters (valid UTF-8: %v), want 100", n, utf8.ValidString(body))
+	}
+	if !strings.HasSuffix(body, "(truncated)") {
+		t.Errorf("searchable content %q does not mark the truncation", body)
+	}
+}
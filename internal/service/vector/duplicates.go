@@ -0,0 +1,118 @@
+package vector
+
+import (
+	"bot-go/internal/model"
+	"context"
+	"fmt"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// duplicateSearchLimit is how many similar chunks are considered per function
+const duplicateSearchLimit = 10
+
+// DuplicateCluster is a group of functions whose code is near-identical, a
+// copy-paste smell. Functions are linked into a cluster when either one finds
+// the other scoring at least the requested similarity.
+type DuplicateCluster struct {
+	Chunks        []*model.CodeChunk
+	MaxSimilarity float32 // Highest score between two functions of the cluster
+}
+
+// FindDuplicates finds near-duplicate functions in a collection. Each
+// function chunk's code is read from its file under repoRoot and searched for
+// with SearchSimilarCode; other function chunks scoring at least
+// minSimilarity are grouped with it, and a chunk's match with itself is
+// excluded by ID. Clusters are returned largest first. Functions whose code
+// cannot be read are skipped.
+func (ccs *CodeChunkService) FindDuplicates(ctx context.Context, collectionName, repoRoot string, minSimilarity float32) ([]DuplicateCluster, error) {
+	if err := ccs.EnsureCollection(ctx, collectionName); err != nil {
+		return nil, err
+	}
+
+	functions, err := ccs.vectorDB.GetChunksByType(ctx, collectionName, model.ChunkTypeFunction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list function chunks: %w", err)
+	}
+
+	paths := ccs.PathNormalizer(repoRoot)
+	files := ccs.NewFileLineCache()
+	byID := make(map[string]*model.CodeChunk, len(functions))
+	parent := make(map[string]string, len(functions)) // Union-find forest over chunk IDs
+	var find func(id string) string
+	find = func(id string) string {
+		if parent[id] != id {
+			parent[id] = find(parent[id])
+		}
+		return parent[id]
+	}
+	for _, chunk := range functions {
+		byID[chunk.ID] = chunk
+		parent[chunk.ID] = chunk.ID
+	}
+
+	maxSimilarity := make(map[string]float32) // Cluster root -> highest score linking two of its chunks
+	filter := map[string]interface{}{"chunk_type": string(model.ChunkTypeFunction)}
+	for _, chunk := range functions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		code, err := ccs.ReadCodeFromFile(files, paths.Resolve(chunk.FilePath), chunk.StartLine, chunk.EndLine)
+		if err != nil {
+			ccs.logger.Warn("Skipping function whose code cannot be read",
+				zap.String("file", chunk.FilePath),
+				zap.String("name", chunk.Name),
+				zap.Error(err))
+			continue
+		}
+		query := *chunk
+		query.Content = code
+
+		similar, scores, err := ccs.SearchSimilarCode(ctx, collectionName, ccs.chunkSearchableText(&query), duplicateSearchLimit, filter)
+		if err != nil {
+			return nil, err
+		}
+		for i, match := range similar {
+			if match.ID == chunk.ID || scores[i] < minSimilarity {
+				continue
+			}
+			if _, ok := byID[match.ID]; !ok {
+				continue // Indexed after the functions were listed
+			}
+
+			root, matchRoot := find(chunk.ID), find(match.ID)
+			best := max(maxSimilarity[root], maxSimilarity[matchRoot], scores[i])
+			delete(maxSimilarity, matchRoot)
+			parent[matchRoot] = root
+			maxSimilarity[root] = best
+		}
+	}
+
+	members := make(map[string][]*model.CodeChunk)
+	for _, chunk := range functions {
+		root := find(chunk.ID)
+		members[root] = append(members[root], chunk)
+	}
+	var clusters []DuplicateCluster
+	for root, chunks := range members {
+		if len(chunks) < 2 {
+			continue
+		}
+		clusters = append(clusters, DuplicateCluster{Chunks: chunks, MaxSimilarity: maxSimilarity[root]})
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Chunks) != len(clusters[j].Chunks) {
+			return len(clusters[i].Chunks) > len(clusters[j].Chunks)
+		}
+		return clusters[i].MaxSimilarity > clusters[j].MaxSimilarity
+	})
+
+	ccs.logger.Info("Found duplicate functions",
+		zap.String("collection", collectionName),
+		zap.Int("functions", len(functions)),
+		zap.Int("clusters", len(clusters)))
+
+	return clusters, nil
+}
@@ -0,0 +1,157 @@
+package vector
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"bot-go/internal/model"
+
+	"go.uber.org/zap"
+)
+
+// cosineVectorDB is a mockVectorDB whose searches rank the stored chunks of
+// the filtered type by cosine similarity to the query
+type cosineVectorDB struct {
+	*mockVectorDB
+}
+
+func (c *cosineVectorDB) SearchSimilar(ctx context.Context, collectionName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var chunks []*model.CodeChunk
+	for _, chunk := range c.chunks[collectionName] {
+		if chunkType, ok := filter["chunk_type"]; !ok || string(chunk.ChunkType) == chunkType {
+			chunks = append(chunks, chunk)
+		}
+	}
+	scores := make(map[string]float32, len(chunks))
+	for _, chunk := range chunks {
+		scores[chunk.ID] = cosine(queryVector, chunk.Embedding)
+	}
+	sort.Slice(chunks, func(i, j int) bool { return scores[chunks[i].ID] > scores[chunks[j].ID] })
+
+	chunks = chunks[:min(limit, len(chunks))]
+	ranked := make([]float32, len(chunks))
+	for i, chunk := range chunks {
+		ranked[i] = scores[chunk.ID]
+	}
+	return chunks, ranked, nil
+}
+
+func cosine(a, b []float32) float32 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	return float32(dot / math.Sqrt(normA*normB))
+}
+
+// letterEmbedding embeds text as its letter frequencies, so texts differing
+// in a few identifiers get near-identical vectors
+type letterEmbedding struct{}
+
+func (letterEmbedding) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vec := make([]float32, 26)
+	for _, r := range strings.ToLower(text) {
+		if r >= 'a' && r <= 'z' {
+			vec[r-'a']++
+		}
+	}
+	return vec, nil
+}
+
+func (e letterEmbedding) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	vecs := make([][]float32, len(texts))
+	for i, text := range texts {
+		vecs[i], _ = e.GenerateEmbedding(ctx, text)
+	}
+	return vecs, nil
+}
+
+func (letterEmbedding) GetDimension() int    { return 26 }
+func (letterEmbedding) GetModelName() string { return "letters" }
+
+func TestFindDuplicatesGroupsNearIdenticalFunctions(t *testing.T) {
+	source := strings.Join([]string{
+		"func totalPrice(items []Item) float64 {",
+		"\ttotal := 0.0",
+		"\tfor _, item := range items {",
+		"\t\ttotal += item.Price * float64(item.Quantity)",
+		"\t}",
+		"\treturn total",
+		"}",
+		"func sumPrices(items []Item) float64 {",
+		"\ttotal := 0.0",
+		"\tfor _, item := range items {",
+		"\t\ttotal += item.Price * float64(item.Quantity)",
+		"\t}",
+		"\treturn total",
+		"}",
+		"func parseConfig(path string) (*Config, error) {",
+		"\tdata, err := os.ReadFile(path)",
+		"\tif err != nil {",
+		"\t\treturn nil, err",
+		"\t}",
+		"\treturn decode(data)",
+		"}",
+	}, "\n")
+	repoRoot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoRoot, "cart.go"), []byte(source), 0o644); err != nil {
+		t.Fatalf("write cart.go: %v", err)
+	}
+
+	ctx := context.Background()
+	db := &cosineVectorDB{newMockVectorDB()}
+	ccs := NewCodeChunkService(db, letterEmbedding{}, 1000, 1000, 0, 0, 0, 1, zap.NewNop())
+	if err := ccs.CreateCollection(ctx, "shop"); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	// Chunks are stored without content, embedded as indexing would
+	lines := strings.Split(source, "\n")
+	var chunks []*model.CodeChunk
+	for i, name := range []string{"totalPrice", "sumPrices", "parseConfig"} {
+		start, end := i*7, i*7+6
+		chunk := &model.CodeChunk{ID: name, ChunkType: model.ChunkTypeFunction, Name: name, FilePath: "cart.go", StartLine: start, EndLine: end}
+		embedded := *chunk
+		embedded.Content = strings.Join(lines[start:end+1], "\n")
+		chunk.Embedding, _ = letterEmbedding{}.GenerateEmbedding(ctx, ccs.chunkSearchableText(&embedded))
+		chunks = append(chunks, chunk)
+	}
+	chunks = append(chunks, &model.CodeChunk{ID: "file", ChunkType: model.ChunkTypeFile, FilePath: "cart.go", EndLine: 20,
+		Embedding: chunks[0].Embedding})
+	if err := db.UpsertChunks(ctx, "shop", chunks); err != nil {
+		t.Fatalf("UpsertChunks: %v", err)
+	}
+
+	clusters, err := ccs.FindDuplicates(ctx, "shop", repoRoot, 0.95)
+	if err != nil {
+		t.Fatalf("FindDuplicates: %v", err)
+	}
+	if len(clusters) != 1 {
+		t.Fatalf("found %d clusters (%+v), want 1", len(clusters), clusters)
+	}
+	var names []string
+	for _, chunk := range clusters[0].Chunks {
+		names = append(names, chunk.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "sumPrices,totalPrice" {
+		t.Errorf("cluster = %v, want sumPrices and totalPrice", names)
+	}
+	if similarity := clusters[0].MaxSimilarity; similarity < 0.95 || similarity >= 1 {
+		t.Errorf("max similarity = %v, want at least 0.95 and below a self-match's 1", similarity)
+	}
+
+	// Nothing is similar enough at a threshold only self-matches reach
+	if clusters, err := ccs.FindDuplicates(ctx, "shop", repoRoot, 1.01); err != nil || len(clusters) != 0 {
+		t.Errorf("FindDuplicates(1.01) = %+v, %v; want no clusters", clusters, err)
+	}
+}
@@ -0,0 +1,142 @@
+package vector
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// EmbeddingCache is a persistent content-hash -> vector store used to avoid
+// re-embedding text that has already been embedded by the same model.
+// Entries are kept in memory and flushed to a gob file on Save.
+type EmbeddingCache struct {
+	path    string
+	entries map[string][]float32
+	dirty   bool
+	mu      sync.RWMutex
+	logger  *zap.Logger
+}
+
+// NewEmbeddingCache creates an embedding cache backed by the given file.
+// Existing entries are loaded if the file is present.
+func NewEmbeddingCache(path string, logger *zap.Logger) (*EmbeddingCache, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create embedding cache directory: %w", err)
+	}
+
+	cache := &EmbeddingCache{
+		path:    path,
+		entries: make(map[string][]float32),
+		logger:  logger,
+	}
+
+	if err := cache.load(); err != nil {
+		return nil, fmt.Errorf("failed to load embedding cache: %w", err)
+	}
+
+	logger.Info("Embedding cache loaded",
+		zap.String("path", path),
+		zap.Int("entries", len(cache.entries)))
+
+	return cache, nil
+}
+
+// Key returns the cache key for a text embedded by the given model.
+// The model name and dimension are part of the key so that switching
+// models never returns a stale vector.
+func (c *EmbeddingCache) Key(modelName string, dimension int, text string) string {
+	h := sha256.New()
+	h.Write([]byte(modelName))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.Itoa(dimension)))
+	h.Write([]byte{0})
+	h.Write([]byte(text))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the cached vector for a key
+func (c *EmbeddingCache) Get(key string) ([]float32, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	vec, ok := c.entries[key]
+	return vec, ok
+}
+
+// Put stores a vector for a key
+func (c *EmbeddingCache) Put(key string, vec []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = vec
+	c.dirty = true
+}
+
+// Len returns the number of cached vectors
+func (c *EmbeddingCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.entries)
+}
+
+// Save writes the cache to disk if it changed since the last save.
+// The file is written to a temporary path and renamed so a crash never
+// leaves a truncated cache behind.
+func (c *EmbeddingCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.dirty {
+		return nil
+	}
+
+	tmpPath := c.path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create embedding cache file: %w", err)
+	}
+
+	if err := gob.NewEncoder(file).Encode(c.entries); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to encode embedding cache: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to close embedding cache file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		return fmt.Errorf("failed to replace embedding cache file: %w", err)
+	}
+
+	c.dirty = false
+	c.logger.Debug("Saved embedding cache",
+		zap.String("path", c.path),
+		zap.Int("entries", len(c.entries)))
+	return nil
+}
+
+// load reads the cache file from disk, if present
+func (c *EmbeddingCache) load() error {
+	file, err := os.Open(c.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	entries := make(map[string][]float32)
+	if err := gob.NewDecoder(file).Decode(&entries); err != nil {
+		return err
+	}
+	c.entries = entries
+	return nil
+}
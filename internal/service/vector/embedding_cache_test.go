@@ -0,0 +1,107 @@
+package vector
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+const cacheTestSource = `package sample
+
+import "fmt"
+
+type Greeter struct {
+	name string
+}
+
+func (g *Greeter) Greet() string {
+	return fmt.Sprintf("hello %s", g.name)
+}
+
+func Add(a, b int) int {
+	return a + b
+}
+`
+
+func TestEmbeddingCacheSkipsUnchangedChunks(t *testing.T) {
+	ctx := context.Background()
+	logger := zap.NewNop()
+	cachePath := filepath.Join(t.TempDir(), "embeddings.gob")
+
+	cache, err := NewEmbeddingCache(cachePath, logger)
+	if err != nil {
+		t.Fatalf("NewEmbeddingCache failed: %v", err)
+	}
+
+	embedding := newMockEmbedding("test-model", 8)
+	ccs := NewCodeChunkService(newMockVectorDB(), embedding, 5, 5, 0, 0, 0, 1, logger)
+	ccs.SetEmbeddingCache(cache)
+
+	chunks, err := ccs.ProcessFileWithContent(ctx, "sample.go", "go", "test", []byte(cacheTestSource))
+	if err != nil {
+		t.Fatalf("first pass failed: %v", err)
+	}
+	if len(chunks) == 0 {
+		t.Fatalf("expected chunks from first pass")
+	}
+
+	firstCalls, firstTexts := embedding.Calls()
+	if firstCalls == 0 || firstTexts == 0 {
+		t.Fatalf("expected embedding model to be invoked on first pass")
+	}
+
+	if _, err := ccs.ProcessFileWithContent(ctx, "sample.go", "go", "test", []byte(cacheTestSource)); err != nil {
+		t.Fatalf("second pass failed: %v", err)
+	}
+
+	secondCalls, secondTexts := embedding.Calls()
+	if secondCalls != firstCalls || secondTexts != firstTexts {
+		t.Errorf("embedding model invoked on second pass: calls %d -> %d, texts %d -> %d",
+			firstCalls, secondCalls, firstTexts, secondTexts)
+	}
+}
+
+func TestEmbeddingCachePersistsAcrossInstances(t *testing.T) {
+	logger := zap.NewNop()
+	cachePath := filepath.Join(t.TempDir(), "embeddings.gob")
+
+	cache, err := NewEmbeddingCache(cachePath, logger)
+	if err != nil {
+		t.Fatalf("NewEmbeddingCache failed: %v", err)
+	}
+	key := cache.Key("test-model", 3, "func main() {}")
+	cache.Put(key, []float32{1, 2, 3})
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	reloaded, err := NewEmbeddingCache(cachePath, logger)
+	if err != nil {
+		t.Fatalf("reload failed: %v", err)
+	}
+	vec, ok := reloaded.Get(key)
+	if !ok {
+		t.Fatalf("expected cached vector after reload")
+	}
+	if len(vec) != 3 || vec[2] != 3 {
+		t.Errorf("unexpected cached vector: %v", vec)
+	}
+}
+
+func TestEmbeddingCacheKeyIncludesModel(t *testing.T) {
+	cache := &EmbeddingCache{entries: make(map[string][]float32)}
+	text := "func main() {}"
+
+	base := cache.Key("model-a", 768, text)
+	if base == cache.Key("model-b", 768, text) {
+		t.Errorf("expected different keys for different models")
+	}
+	if base == cache.Key("model-a", 1024, text) {
+		t.Errorf("expected different keys for different dimensions")
+	}
+	if base != cache.Key("model-a", 768, text) {
+		t.Errorf("expected stable key for identical inputs")
+	}
+}
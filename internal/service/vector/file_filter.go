@@ -0,0 +1,91 @@
+package vector
+
+import (
+	"bytes"
+	"path/filepath"
+)
+
+// DefaultSkipGlobs are file name patterns of bundled, minified, or generated
+// sources that produce low-value chunks
+var DefaultSkipGlobs = []string{
+	"*.min.js",
+	"*.min.mjs",
+	"*.bundle.js",
+	"*.chunk.js",
+	"*.pb.go",
+	"*_pb2.py",
+	"*_pb2_grpc.py",
+	"*.generated.*",
+}
+
+// generatedMarkers are lowercase markers that tools write near the top of generated files
+var generatedMarkers = [][]byte{
+	[]byte("generated by"),
+	[]byte("code generated"),
+	[]byte("@generated"),
+	[]byte("auto-generated"),
+	[]byte("autogenerated"),
+}
+
+// FileSkipRules decides which source files are not worth chunking
+type FileSkipRules struct {
+	Globs                []string // Patterns matched against the file name and the full slash-separated path
+	MaxAvgLineLength     int      // Files with a longer average line are treated as minified (0 disables)
+	GeneratedMarkerLines int      // Leading lines searched for a generated-code marker (0 disables)
+}
+
+// MatchesGlob reports whether the path matches one of the skip globs
+func (r FileSkipRules) MatchesGlob(path string) bool {
+	name := filepath.Base(path)
+	slashPath := filepath.ToSlash(path)
+	for _, pattern := range r.Globs {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pattern, slashPath); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipReason returns why the content should not be chunked, or "" if it should be
+func (r FileSkipRules) SkipReason(content []byte) string {
+	if len(content) == 0 {
+		return ""
+	}
+
+	if r.MaxAvgLineLength > 0 {
+		lines := bytes.Count(content, []byte("\n"))
+		if content[len(content)-1] != '\n' {
+			lines++
+		}
+		if len(content)/lines > r.MaxAvgLineLength {
+			return "minified"
+		}
+	}
+
+	if r.GeneratedMarkerLines > 0 {
+		lowerHead := bytes.ToLower(leadingLines(content, r.GeneratedMarkerLines))
+		for _, marker := range generatedMarkers {
+			if bytes.Contains(lowerHead, marker) {
+				return "generated"
+			}
+		}
+	}
+
+	return ""
+}
+
+// leadingLines returns the first n lines of content
+func leadingLines(content []byte, n int) []byte {
+	offset := 0
+	for i := 0; i < n; i++ {
+		next := bytes.IndexByte(content[offset:], '\n')
+		if next < 0 {
+			return content
+		}
+		offset += next + 1
+	}
+	return content[:offset]
+}
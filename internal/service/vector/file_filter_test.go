@@ -0,0 +1,116 @@
+package vector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+const normalJSSource = `function add(a, b) {
+  const sum = a + b;
+  return sum;
+}
+
+function greet(name) {
+  const message = "hello " + name;
+  console.log(message);
+  return message;
+}
+`
+
+// minifiedJSSource returns JavaScript whose lines are each 2000 characters long
+func minifiedJSSource() string {
+	line := "var a=1;" + strings.Repeat("a=a+1;", 332)
+	line = line[:2000]
+	return strings.Repeat(line+"\n", 3)
+}
+
+func TestFileSkipRulesMatchesGlob(t *testing.T) {
+	rules := FileSkipRules{Globs: DefaultSkipGlobs}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/repo/static/app.min.js", true},
+		{"/repo/dist/main.bundle.js", true},
+		{"/repo/api/service.pb.go", true},
+		{"/repo/proto/service_pb2.py", true},
+		{"/repo/src/app.js", false},
+		{"/repo/src/minimal.js", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := rules.MatchesGlob(tt.path); got != tt.expected {
+				t.Errorf("MatchesGlob(%q) = %v, want %v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFileSkipRulesSkipReason(t *testing.T) {
+	rules := FileSkipRules{MaxAvgLineLength: 300, GeneratedMarkerLines: 5}
+
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"normal source", normalJSSource, ""},
+		{"2000-char lines", minifiedJSSource(), "minified"},
+		{"generated marker", "// Code generated by protoc-gen-go. DO NOT EDIT.\npackage api\n", "generated"},
+		{"marker past first lines", strings.Repeat("x := 1\n", 10) + "// generated by hand\n", ""},
+		{"empty", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules.SkipReason([]byte(tt.content)); got != tt.expected {
+				t.Errorf("SkipReason() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProcessDirectorySkipsMinifiedFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"app.min.js": normalJSSource,
+		"vendor.js":  minifiedJSSource(),
+		"app.js":     normalJSSource,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	vectorDB := newMockVectorDB()
+	ccs := NewCodeChunkService(vectorDB, newMockEmbedding("test-model", 4), 5, 5, 0, 0, 0, 1, zap.NewNop())
+	ccs.SetFileSkipRules(FileSkipRules{
+		Globs:                DefaultSkipGlobs,
+		MaxAvgLineLength:     300,
+		GeneratedMarkerLines: 5,
+	})
+
+	if _, err := ccs.ProcessDirectory(context.Background(), dir, "test", nil); err != nil {
+		t.Fatalf("ProcessDirectory failed: %v", err)
+	}
+
+	// Chunk paths are stored relative to the processed directory
+	chunked := vectorDB.filePaths("test")
+	if !chunked["app.js"] {
+		t.Errorf("normal file was not chunked; chunked files: %v", chunked)
+	}
+	if chunked["app.min.js"] {
+		t.Error("app.min.js should be skipped by glob")
+	}
+	if chunked["vendor.js"] {
+		t.Error("vendor.js should be skipped as minified")
+	}
+}
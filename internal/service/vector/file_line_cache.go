@@ -0,0 +1,49 @@
+package vector
+
+import (
+	"bot-go/internal/util"
+	"os"
+	"strings"
+)
+
+// FileLineCache holds the lines of the files read while serving one request,
+// so that many results from the same file read and split it only once. It is
+// not safe for concurrent use.
+type FileLineCache struct {
+	files    map[string][]string
+	readFile func(path string) ([]byte, error)
+}
+
+// NewFileLineCache creates an empty cache that reads files from disk
+func NewFileLineCache() *FileLineCache {
+	return &FileLineCache{
+		files:    make(map[string][]string),
+		readFile: os.ReadFile,
+	}
+}
+
+// NewFileLineCacheWithLimit creates an empty cache that refuses files larger
+// than maxBytes with util.ErrFileTooLarge; 0 disables the limit
+func NewFileLineCacheWithLimit(maxBytes int64) *FileLineCache {
+	cache := NewFileLineCache()
+	cache.readFile = func(path string) ([]byte, error) {
+		return util.ReadFileWithLimit(path, maxBytes)
+	}
+	return cache
+}
+
+// Lines returns the file's lines, reading the file on first use. Read errors
+// are not cached.
+func (c *FileLineCache) Lines(path string) ([]string, error) {
+	if lines, ok := c.files[path]; ok {
+		return lines, nil
+	}
+
+	content, err := c.readFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(string(content), "\n")
+	c.files[path] = lines
+	return lines, nil
+}
@@ -0,0 +1,300 @@
+package vector
+
+import (
+	"bot-go/internal/model"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// mockVectorDB is an in-memory VectorDatabase used by tests
+type mockVectorDB struct {
+	mu        sync.Mutex
+	chunks    map[string]map[string]*model.CodeChunk // collection -> id -> chunk
+	distances map[string]DistanceMetric              // collection -> metric it was created with
+	models    map[string]CollectionEmbedding         // collection -> recorded embedding model
+	creates   map[string]int                         // collection -> CreateCollection calls
+	named     map[string][]NamedVector               // collection -> named vectors it was created with
+	searched  []string                               // vector names searched, "" for the unnamed vector
+	mismatch  int                                    // searches of a vector the collection doesn't have
+}
+
+func newMockVectorDB() *mockVectorDB {
+	return &mockVectorDB{
+		chunks:    make(map[string]map[string]*model.CodeChunk),
+		distances: make(map[string]DistanceMetric),
+		models:    make(map[string]CollectionEmbedding),
+		creates:   make(map[string]int),
+		named:     make(map[string][]NamedVector),
+	}
+}
+
+func (m *mockVectorDB) CreateCollection(ctx context.Context, collectionName string, vectorDim int, distance DistanceMetric) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.creates[collectionName]++
+	if _, ok := m.chunks[collectionName]; !ok {
+		m.chunks[collectionName] = make(map[string]*model.CodeChunk)
+		m.distances[collectionName] = distance
+	}
+	return nil
+}
+
+func (m *mockVectorDB) CreateCollectionWithNamedVectors(ctx context.Context, collectionName string, vectors []NamedVector, distance DistanceMetric) error {
+	if err := m.CreateCollection(ctx, collectionName, 0, distance); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.named[collectionName] = vectors
+	return nil
+}
+
+func (m *mockVectorDB) CollectionDistance(ctx context.Context, collectionName string) (DistanceMetric, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	distance, ok := m.distances[collectionName]
+	if !ok {
+		return "", fmt.Errorf("collection %s not found", collectionName)
+	}
+	return distance, nil
+}
+
+// CollectionVectorNames returns the sorted names of the vectors the collection
+// was created with, or nil for an unnamed vector
+func (m *mockVectorDB) CollectionVectorNames(ctx context.Context, collectionName string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for _, vector := range m.named[collectionName] {
+		names = append(names, vector.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *mockVectorDB) SetCollectionEmbedding(ctx context.Context, collectionName string, embedding CollectionEmbedding) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models[collectionName] = embedding
+	return nil
+}
+
+func (m *mockVectorDB) CollectionEmbedding(ctx context.Context, collectionName string) (*CollectionEmbedding, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	embedding, ok := m.models[collectionName]
+	if !ok {
+		return nil, nil
+	}
+	return &embedding, nil
+}
+
+func (m *mockVectorDB) DeleteCollection(ctx context.Context, collectionName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.chunks, collectionName)
+	delete(m.distances, collectionName)
+	delete(m.models, collectionName)
+	return nil
+}
+
+func (m *mockVectorDB) CollectionExists(ctx context.Context, collectionName string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.chunks[collectionName]
+	return ok, nil
+}
+
+func (m *mockVectorDB) UpsertChunks(ctx context.Context, collectionName string, chunks []*model.CodeChunk) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.chunks[collectionName]; !ok {
+		m.chunks[collectionName] = make(map[string]*model.CodeChunk)
+	}
+	for _, c := range chunks {
+		m.chunks[collectionName][c.ID] = c
+	}
+	return nil
+}
+
+func (m *mockVectorDB) SearchSimilar(ctx context.Context, collectionName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	return m.SearchSimilarNamed(ctx, collectionName, "", queryVector, limit, filter)
+}
+
+// SearchSimilarNamed records the vector searched and finds nothing. Like
+// Qdrant, it fails when the vector doesn't match the collection's vectors.
+func (m *mockVectorDB) SearchSimilarNamed(ctx context.Context, collectionName, vectorName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.searched = append(m.searched, vectorName)
+	declared := vectorName == "" && len(m.named[collectionName]) == 0
+	for _, vector := range m.named[collectionName] {
+		declared = declared || vector.Name == vectorName
+	}
+	if !declared {
+		m.mismatch++
+		return nil, nil, fmt.Errorf("collection %s has no vector %q", collectionName, vectorName)
+	}
+	return nil, nil, nil
+}
+
+func (m *mockVectorDB) GetChunkByID(ctx context.Context, collectionName string, chunkID string) (*model.CodeChunk, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.chunks[collectionName][chunkID], nil
+}
+
+func (m *mockVectorDB) DeleteChunk(ctx context.Context, collectionName string, chunkID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.chunks[collectionName], chunkID)
+	return nil
+}
+
+// GetChunksByFilePath always reports no existing chunks so that tests
+// exercise the embedding path rather than the vector DB reuse path
+func (m *mockVectorDB) GetChunksByFilePath(ctx context.Context, collectionName string, filePath string) ([]*model.CodeChunk, error) {
+	return nil, nil
+}
+
+// GetChunksByType returns the stored chunks of a type, in ID order
+func (m *mockVectorDB) GetChunksByType(ctx context.Context, collectionName string, chunkType model.ChunkType) ([]*model.CodeChunk, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var chunks []*model.CodeChunk
+	for _, c := range m.chunks[collectionName] {
+		if c.ChunkType == chunkType {
+			chunks = append(chunks, c)
+		}
+	}
+	sort.Slice(chunks, func(i, j int) bool { return chunks[i].ID < chunks[j].ID })
+	return chunks, nil
+}
+
+// filePaths returns the set of file paths that have chunks stored in a collection
+func (m *mockVectorDB) filePaths(collectionName string) map[string]bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	paths := make(map[string]bool)
+	for _, c := range m.chunks[collectionName] {
+		paths[c.FilePath] = true
+	}
+	return paths
+}
+
+func (m *mockVectorDB) Close() error                     { return nil }
+func (m *mockVectorDB) Health(ctx context.Context) error { return nil }
+
+// scoredVectorDB is a mockVectorDB whose searches return fixed results per collection
+type scoredVectorDB struct {
+	*mockVectorDB
+	results map[string][]scoredChunk // collection -> search results
+}
+
+type scoredChunk struct {
+	chunk *model.CodeChunk
+	score float32
+}
+
+func (s *scoredVectorDB) SearchSimilar(ctx context.Context, collectionName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	results, ok := s.results[collectionName]
+	if !ok {
+		return nil, nil, fmt.Errorf("collection %s not found", collectionName)
+	}
+	var chunks []*model.CodeChunk
+	var scores []float32
+	for _, result := range results[:min(limit, len(results))] {
+		chunks = append(chunks, result.chunk)
+		scores = append(scores, result.score)
+	}
+	return chunks, scores, nil
+}
+
+// mockEmbedding returns deterministic vectors and counts model invocations
+type mockEmbedding struct {
+	mu        sync.Mutex
+	model     string
+	dimension int
+	calls     int
+	texts     int
+}
+
+func newMockEmbedding(model string, dimension int) *mockEmbedding {
+	return &mockEmbedding{model: model, dimension: dimension}
+}
+
+func (m *mockEmbedding) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vecs, err := m.GenerateEmbeddings(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vecs[0], nil
+}
+
+func (m *mockEmbedding) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	m.mu.Lock()
+	m.calls++
+	m.texts += len(texts)
+	m.mu.Unlock()
+
+	vecs := make([][]float32, len(texts))
+	for i, text := range texts {
+		vec := make([]float32, m.dimension)
+		for j := range vec {
+			vec[j] = float32(len(text) + j)
+		}
+		vecs[i] = vec
+	}
+	return vecs, nil
+}
+
+func (m *mockEmbedding) GetDimension() int    { return m.dimension }
+func (m *mockEmbedding) GetModelName() string { return m.model }
+
+func (m *mockEmbedding) Calls() (calls, texts int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls, m.texts
+}
+
+// concurrencyEmbedding wraps mockEmbedding and records the most calls in
+// flight at once. Each call waits briefly for others to join, so concurrent
+// callers overlap instead of racing through one at a time.
+type concurrencyEmbedding struct {
+	*mockEmbedding
+	wantInFlight int
+	inFlight     int
+	maxInFlight  int
+	cond         *sync.Cond
+}
+
+func newConcurrencyEmbedding(wantInFlight int) *concurrencyEmbedding {
+	m := newMockEmbedding("test-model", 4)
+	return &concurrencyEmbedding{mockEmbedding: m, wantInFlight: wantInFlight, cond: sync.NewCond(&m.mu)}
+}
+
+func (e *concurrencyEmbedding) GenerateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.inFlight++
+	e.maxInFlight = max(e.maxInFlight, e.inFlight)
+	e.cond.Broadcast()
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for e.inFlight < e.wantInFlight && time.Now().Before(deadline) {
+		timer := time.AfterFunc(10*time.Millisecond, e.cond.Broadcast)
+		e.cond.Wait()
+		timer.Stop()
+	}
+	e.inFlight--
+	e.mu.Unlock()
+
+	return e.mockEmbedding.GenerateEmbeddings(ctx, texts)
+}
+
+func (e *concurrencyEmbedding) MaxInFlight() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.maxInFlight
+}
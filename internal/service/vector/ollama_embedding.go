@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -27,8 +28,19 @@ type OllamaEmbeddingConfig struct {
 	APIKey    string // Optional API key for authentication
 	Model     string // e.g., "nomic-embed-text", "all-minilm"
 	Dimension int    // Dimension of the embedding vector
+
+	// StrictDimension fails construction when the model's actual vector length
+	// differs from Dimension instead of adopting the model's length
+	StrictDimension bool
 }
 
+// ErrDimensionMismatch is returned by NewOllamaEmbedding in strict mode when the
+// model returns vectors of a different length than the configured dimension
+var ErrDimensionMismatch = errors.New("embedding dimension mismatch")
+
+// dimensionProbeText is embedded once at startup to learn the model's vector length
+const dimensionProbeText = "dimension probe"
+
 // Common Ollama embedding models
 const (
 	// NomicEmbedText is a high-quality 768-dimensional embedding model
@@ -68,7 +80,7 @@ func NewOllamaEmbedding(config OllamaEmbeddingConfig, logger *zap.Logger) (*Olla
 		}
 	}
 
-	return &OllamaEmbedding{
+	embedding := &OllamaEmbedding{
 		apiURL:    config.APIURL,
 		model:     config.Model,
 		dimension: dimension,
@@ -76,7 +88,54 @@ func NewOllamaEmbedding(config OllamaEmbeddingConfig, logger *zap.Logger) (*Olla
 		client: &http.Client{
 			Timeout: 60 * time.Second,
 		},
-	}, nil
+	}
+
+	if err := embedding.verifyDimension(config.Dimension, config.StrictDimension); err != nil {
+		return nil, err
+	}
+
+	return embedding, nil
+}
+
+// verifyDimension embeds a short probe string and compares the returned vector
+// length with the expected dimension. Vectors of the wrong length are rejected
+// by the vector database mid-run, so a mismatch either fails fast (strict) or
+// switches GetDimension to the model's real length. When no dimension was
+// configured the probed length is adopted silently.
+func (o *OllamaEmbedding) verifyDimension(configured int, strict bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), o.client.Timeout)
+	defer cancel()
+
+	probe, err := o.GenerateEmbedding(ctx, dimensionProbeText)
+	if err != nil {
+		if strict {
+			return fmt.Errorf("failed to probe embedding dimension of model %s: %w", o.model, err)
+		}
+		o.logger.Warn("Could not probe embedding dimension, using configured value",
+			zap.String("model", o.model),
+			zap.Int("dimension", o.dimension),
+			zap.Error(err))
+		return nil
+	}
+
+	actual := len(probe)
+	if actual == o.dimension {
+		return nil
+	}
+
+	if configured != 0 {
+		if strict {
+			return fmt.Errorf("%w: model %s returns %d-dimensional vectors but %d is configured",
+				ErrDimensionMismatch, o.model, actual, configured)
+		}
+		o.logger.Warn("Configured embedding dimension does not match model output, using model dimension",
+			zap.String("model", o.model),
+			zap.Int("configured_dimension", configured),
+			zap.Int("model_dimension", actual))
+	}
+
+	o.dimension = actual
+	return nil
 }
 
 // ollamaEmbeddingRequest represents the request body for Ollama embedding API
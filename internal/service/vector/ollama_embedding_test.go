@@ -0,0 +1,67 @@
+package vector
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// newOllamaServer serves /api/embeddings with vectors of the given length
+func newOllamaServer(t *testing.T, dimension int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/embeddings" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(ollamaEmbeddingResponse{Embedding: make([]float64, dimension)})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNewOllamaEmbeddingDimensionProbe(t *testing.T) {
+	server := newOllamaServer(t, 768)
+
+	tests := []struct {
+		name          string
+		model         string
+		dimension     int
+		strict        bool
+		wantErr       error
+		wantDimension int
+	}{
+		{name: "mismatch is corrected", dimension: 1024, wantDimension: 768},
+		{name: "mismatch fails in strict mode", dimension: 1024, strict: true, wantErr: ErrDimensionMismatch},
+		{name: "matching dimension", dimension: 768, strict: true, wantDimension: 768},
+		{name: "unset dimension adopts model", model: "custom-embed", strict: true, wantDimension: 768},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedding, err := NewOllamaEmbedding(OllamaEmbeddingConfig{
+				APIURL:          server.URL,
+				Model:           tt.model,
+				Dimension:       tt.dimension,
+				StrictDimension: tt.strict,
+			}, zap.NewNop())
+
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewOllamaEmbedding failed: %v", err)
+			}
+			if got := embedding.GetDimension(); got != tt.wantDimension {
+				t.Errorf("GetDimension() = %d, want %d", got, tt.wantDimension)
+			}
+		})
+	}
+}
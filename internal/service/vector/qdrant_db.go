@@ -5,12 +5,18 @@ import (
 	"bot-go/pkg/lsp/base"
 	"context"
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/qdrant/go-client/qdrant"
 	"go.uber.org/zap"
 )
 
+// metadataCollection holds one point per collection recording the embedding
+// model it was built with, since Qdrant collections carry no metadata of their own
+const metadataCollection = "bot_go_collection_metadata"
+
 // QdrantDatabase implements VectorDatabase interface using Qdrant
 type QdrantDatabase struct {
 	client *qdrant.Client
@@ -37,40 +43,195 @@ func NewQdrantDatabase(host string, port int, apiKey string, logger *zap.Logger)
 
 // CreateCollection creates a new collection with the specified dimension and distance metric
 func (q *QdrantDatabase) CreateCollection(ctx context.Context, collectionName string, vectorDim int, distance DistanceMetric) error {
-	// Map our distance metric to Qdrant's distance type
-	var qdrantDistance qdrant.Distance
-	switch distance {
-	case DistanceMetricCosine:
-		qdrantDistance = qdrant.Distance_Cosine
-	case DistanceMetricDot:
-		qdrantDistance = qdrant.Distance_Dot
-	case DistanceMetricEuclidean:
-		qdrantDistance = qdrant.Distance_Euclid
-	default:
-		qdrantDistance = qdrant.Distance_Cosine
-	}
-
 	err := q.client.CreateCollection(ctx, &qdrant.CreateCollection{
 		CollectionName: collectionName,
 		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
 			Size:     uint64(vectorDim),
-			Distance: qdrantDistance,
+			Distance: toQdrantDistance(distance),
 		}),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create collection: %w", err)
 	}
 
-	q.logger.Info("Created Qdrant collection", zap.String("collection", collectionName), zap.Int("dim", vectorDim))
+	q.logger.Info("Created Qdrant collection",
+		zap.String("collection", collectionName),
+		zap.Int("dim", vectorDim),
+		zap.String("distance", string(distance)))
+	return nil
+}
+
+// CreateCollectionWithNamedVectors creates a collection with one vector per name
+func (q *QdrantDatabase) CreateCollectionWithNamedVectors(ctx context.Context, collectionName string, vectors []NamedVector, distance DistanceMetric) error {
+	if len(vectors) == 0 {
+		return fmt.Errorf("collection %s needs at least one named vector", collectionName)
+	}
+
+	params := make(map[string]*qdrant.VectorParams, len(vectors))
+	for _, vector := range vectors {
+		params[vector.Name] = &qdrant.VectorParams{
+			Size:     uint64(vector.Dimension),
+			Distance: toQdrantDistance(distance),
+		}
+	}
+
+	err := q.client.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: collectionName,
+		VectorsConfig:  qdrant.NewVectorsConfigMap(params),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create collection: %w", err)
+	}
+
+	q.logger.Info("Created Qdrant collection with named vectors",
+		zap.String("collection", collectionName),
+		zap.Any("vectors", vectors),
+		zap.String("distance", string(distance)))
+	return nil
+}
+
+// CollectionDistance returns the distance metric of the collection's unnamed
+// vector, or of its named vectors, which are created with a shared metric
+func (q *QdrantDatabase) CollectionDistance(ctx context.Context, collectionName string) (DistanceMetric, error) {
+	info, err := q.client.GetCollectionInfo(ctx, collectionName)
+	if err != nil {
+		return "", fmt.Errorf("failed to get collection info: %w", err)
+	}
+
+	vectorsConfig := info.GetConfig().GetParams().GetVectorsConfig()
+	params := vectorsConfig.GetParams()
+	if params == nil {
+		for _, named := range vectorsConfig.GetParamsMap().GetMap() {
+			params = named
+			break
+		}
+	}
+	if params == nil {
+		return "", fmt.Errorf("collection %s has no vectors configured", collectionName)
+	}
+
+	switch params.GetDistance() {
+	case qdrant.Distance_Cosine:
+		return DistanceMetricCosine, nil
+	case qdrant.Distance_Dot:
+		return DistanceMetricDot, nil
+	case qdrant.Distance_Euclid:
+		return DistanceMetricEuclidean, nil
+	default:
+		return DistanceMetric(strings.ToLower(params.GetDistance().String())), nil
+	}
+}
+
+// CollectionVectorNames returns the sorted names of the collection's named
+// vectors, or nil when it was created with a single unnamed vector
+func (q *QdrantDatabase) CollectionVectorNames(ctx context.Context, collectionName string) ([]string, error) {
+	info, err := q.client.GetCollectionInfo(ctx, collectionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collection info: %w", err)
+	}
+
+	var names []string
+	for name := range info.GetConfig().GetParams().GetVectorsConfig().GetParamsMap().GetMap() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SetCollectionEmbedding stores the collection's embedding model as a point in
+// the metadata collection, creating that collection on first use
+func (q *QdrantDatabase) SetCollectionEmbedding(ctx context.Context, collectionName string, embedding CollectionEmbedding) error {
+	exists, err := q.client.CollectionExists(ctx, metadataCollection)
+	if err != nil {
+		return fmt.Errorf("failed to check metadata collection existence: %w", err)
+	}
+	if !exists {
+		// The metadata points only need a placeholder vector
+		if err := q.CreateCollection(ctx, metadataCollection, 1, DistanceMetricDot); err != nil {
+			return fmt.Errorf("failed to create metadata collection: %w", err)
+		}
+	}
+
+	_, err = q.client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: metadataCollection,
+		Points: []*qdrant.PointStruct{{
+			Id:      qdrant.NewIDUUID(metadataPointID(collectionName)),
+			Vectors: qdrant.NewVectors(1),
+			Payload: qdrant.NewValueMap(map[string]any{
+				"collection":          collectionName,
+				"embedding_model":     embedding.Model,
+				"embedding_dimension": embedding.Dimension,
+			}),
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store collection metadata: %w", err)
+	}
 	return nil
 }
 
+// CollectionEmbedding reads the collection's embedding model from the metadata collection
+func (q *QdrantDatabase) CollectionEmbedding(ctx context.Context, collectionName string) (*CollectionEmbedding, error) {
+	exists, err := q.client.CollectionExists(ctx, metadataCollection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check metadata collection existence: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	points, err := q.client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: metadataCollection,
+		Ids:            []*qdrant.PointId{qdrant.NewIDUUID(metadataPointID(collectionName))},
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read collection metadata: %w", err)
+	}
+	if len(points) == 0 {
+		return nil, nil
+	}
+
+	payload := points[0].GetPayload()
+	return &CollectionEmbedding{
+		Model:     getStringValue(payload, "embedding_model"),
+		Dimension: int(getIntValue(payload, "embedding_dimension")),
+	}, nil
+}
+
+// metadataPointID derives a stable point ID from a collection name
+func metadataPointID(collectionName string) string {
+	return uuid.NewSHA1(uuid.NameSpaceOID, []byte(collectionName)).String()
+}
+
+// toQdrantDistance maps our distance metric to Qdrant's distance type, using
+// cosine for unknown metrics
+func toQdrantDistance(distance DistanceMetric) qdrant.Distance {
+	switch distance {
+	case DistanceMetricDot:
+		return qdrant.Distance_Dot
+	case DistanceMetricEuclidean:
+		return qdrant.Distance_Euclid
+	default:
+		return qdrant.Distance_Cosine
+	}
+}
+
 // DeleteCollection deletes a collection
 func (q *QdrantDatabase) DeleteCollection(ctx context.Context, collectionName string) error {
 	err := q.client.DeleteCollection(ctx, collectionName)
 	if err != nil {
 		return fmt.Errorf("failed to delete collection: %w", err)
 	}
+
+	// Forget the collection's embedding model so a recreated collection records its own
+	if exists, err := q.client.CollectionExists(ctx, metadataCollection); err == nil && exists {
+		if err := q.DeleteChunk(ctx, metadataCollection, metadataPointID(collectionName)); err != nil {
+			q.logger.Warn("Failed to delete collection metadata",
+				zap.String("collection", collectionName),
+				zap.Error(err))
+		}
+	}
 	return nil
 }
 
@@ -92,7 +253,8 @@ func (q *QdrantDatabase) UpsertChunks(ctx context.Context, collectionName string
 	points := make([]*qdrant.PointStruct, 0, len(chunks))
 
 	for _, chunk := range chunks {
-		if len(chunk.Embedding) == 0 {
+		vectors := chunkVectors(chunk)
+		if len(vectors) == 0 {
 			q.logger.Warn("Skipping chunk without embedding", zap.String("id", chunk.ID))
 			continue
 		}
@@ -100,10 +262,8 @@ func (q *QdrantDatabase) UpsertChunks(ctx context.Context, collectionName string
 		// Convert CodeChunk to Qdrant point
 		// Note: content is excluded to save storage space - use file_path and line numbers to retrieve content
 		point := &qdrant.PointStruct{
-			Id: qdrant.NewIDUUID(chunk.ID),
-			Vectors: qdrant.NewVectorsMap(map[string]*qdrant.Vector{
-				"": qdrant.NewVector(chunk.Embedding...),
-			}),
+			Id:      qdrant.NewIDUUID(chunk.ID),
+			Vectors: qdrant.NewVectorsMap(vectors),
 			Payload: qdrant.NewValueMap(map[string]any{
 				"chunk_type":  string(chunk.ChunkType),
 				"level":       chunk.Level,
@@ -151,8 +311,33 @@ func (q *QdrantDatabase) UpsertChunks(ctx context.Context, collectionName string
 	return nil
 }
 
+// chunkVectors returns the vectors a chunk's point is stored with: its named
+// embeddings if it has any, otherwise its embedding as the unnamed vector
+func chunkVectors(chunk *model.CodeChunk) map[string]*qdrant.Vector {
+	vectors := make(map[string]*qdrant.Vector, len(chunk.NamedEmbeddings))
+	for name, embedding := range chunk.NamedEmbeddings {
+		if len(embedding) > 0 {
+			vectors[name] = qdrant.NewVector(embedding...)
+		}
+	}
+	if len(chunk.NamedEmbeddings) == 0 && len(chunk.Embedding) > 0 {
+		vectors[""] = qdrant.NewVector(chunk.Embedding...)
+	}
+	return vectors
+}
+
 // SearchSimilar finds similar code chunks using vector similarity search
 func (q *QdrantDatabase) SearchSimilar(ctx context.Context, collectionName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	return q.search(ctx, collectionName, nil, queryVector, limit, filter)
+}
+
+// SearchSimilarNamed finds similar code chunks by comparing against one named vector
+func (q *QdrantDatabase) SearchSimilarNamed(ctx context.Context, collectionName, vectorName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
+	return q.search(ctx, collectionName, qdrant.PtrOf(vectorName), queryVector, limit, filter)
+}
+
+// search queries the vector named by using, or the unnamed vector when using is nil
+func (q *QdrantDatabase) search(ctx context.Context, collectionName string, using *string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error) {
 	// Build Qdrant filter if provided
 	var qdrantFilter *qdrant.Filter
 	if len(filter) > 0 {
@@ -175,6 +360,7 @@ func (q *QdrantDatabase) SearchSimilar(ctx context.Context, collectionName strin
 	searchResult, err := q.client.Query(ctx, &qdrant.QueryPoints{
 		CollectionName: collectionName,
 		Query:          qdrant.NewQuery(queryVector...),
+		Using:          using,
 		Limit:          qdrant.PtrOf(uint64(limit)),
 		Filter:         qdrantFilter,
 		WithPayload:    qdrant.NewWithPayload(true),
@@ -274,6 +460,47 @@ func (q *QdrantDatabase) GetChunksByFilePath(ctx context.Context, collectionName
 	return chunks, nil
 }
 
+// GetChunksByType retrieves all chunks of a type, scrolling through the
+// collection a page at a time
+func (q *QdrantDatabase) GetChunksByType(ctx context.Context, collectionName string, chunkType model.ChunkType) ([]*model.CodeChunk, error) {
+	filter := &qdrant.Filter{
+		Must: []*qdrant.Condition{
+			{
+				ConditionOneOf: &qdrant.Condition_Field{
+					Field: &qdrant.FieldCondition{
+						Key:   "chunk_type",
+						Match: &qdrant.Match{MatchValue: &qdrant.Match_Keyword{Keyword: string(chunkType)}},
+					},
+				},
+			},
+		},
+	}
+
+	var chunks []*model.CodeChunk
+	var offset *qdrant.PointId
+	for {
+		points, next, err := q.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: collectionName,
+			Filter:         filter,
+			Offset:         offset,
+			Limit:          qdrant.PtrOf(uint32(1000)),
+			WithPayload:    qdrant.NewWithPayload(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scroll points: %w", err)
+		}
+		for _, point := range points {
+			if chunk := retrievedPointToCodeChunk(point); chunk != nil {
+				chunks = append(chunks, chunk)
+			}
+		}
+		if next == nil {
+			return chunks, nil
+		}
+		offset = next
+	}
+}
+
 // Close closes the database connection
 func (q *QdrantDatabase) Close() error {
 	if q.client != nil {
@@ -329,7 +556,31 @@ func pointToCodeChunk(point *qdrant.ScoredPoint) *model.CodeChunk {
 
 func retrievedPointToCodeChunk(point *qdrant.RetrievedPoint) *model.CodeChunk {
 	payload := point.GetPayload()
-	return payloadToCodeChunk(point.Id.GetUuid(), payload)
+	chunk := payloadToCodeChunk(point.Id.GetUuid(), payload)
+	if chunk == nil {
+		return nil
+	}
+
+	// Keep the stored vectors so unchanged chunks are re-upserted without re-embedding
+	vectors := point.GetVectors()
+	if vector := vectors.GetVector(); vector != nil {
+		chunk.Embedding = vectorOutputData(vector)
+	}
+	if named := vectors.GetVectors().GetVectors(); len(named) > 0 {
+		chunk.NamedEmbeddings = make(map[string][]float32, len(named))
+		for name, vector := range named {
+			chunk.NamedEmbeddings[name] = vectorOutputData(vector)
+		}
+	}
+	return chunk
+}
+
+// vectorOutputData returns the values of a dense vector read back from Qdrant
+func vectorOutputData(vector *qdrant.VectorOutput) []float32 {
+	if dense := vector.GetDense(); dense != nil {
+		return dense.GetData()
+	}
+	return vector.GetData()
 }
 
 func payloadToCodeChunk(id string, payload map[string]*qdrant.Value) *model.CodeChunk {
@@ -0,0 +1,66 @@
+package vector
+
+import (
+	"bot-go/internal/model"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// selfTestCollectionPrefix names the throwaway collections SelfTest creates
+const selfTestCollectionPrefix = "bot_go_selftest_"
+
+// selfTestText is the string SelfTest embeds
+const selfTestText = "func selfTest() {}"
+
+// SelfTest checks the embedding model and vector database end to end: it
+// embeds a short string, then upserts that vector into a throwaway collection
+// and deletes it again. The error names the step that failed, so unreachable
+// services, auth errors and dimension mismatches surface at startup instead of
+// on the first indexing request. The throwaway collection is always dropped.
+func (ccs *CodeChunkService) SelfTest(ctx context.Context) error {
+	modelName := ccs.embedding.GetModelName()
+	embedding, err := ccs.embedding.GenerateEmbedding(ctx, selfTestText)
+	if err != nil {
+		return fmt.Errorf("self-test failed to embed with model %s: %w", modelName, err)
+	}
+	if dimension := ccs.embedding.GetDimension(); len(embedding) != dimension {
+		return fmt.Errorf("self-test: model %s returned %d dimensions, configured for %d: %w",
+			modelName, len(embedding), dimension, ErrDimensionMismatch)
+	}
+
+	collectionName := selfTestCollectionPrefix + uuid.NewString()
+	if err := ccs.vectorDB.CreateCollection(ctx, collectionName, len(embedding), ccs.distance); err != nil {
+		return fmt.Errorf("self-test failed to create collection %s: %w", collectionName, err)
+	}
+	defer func() {
+		// Dropped even when the test's context was cancelled
+		if err := ccs.vectorDB.DeleteCollection(context.WithoutCancel(ctx), collectionName); err != nil {
+			ccs.logger.Warn("Failed to drop self-test collection",
+				zap.String("collection", collectionName),
+				zap.Error(err))
+		}
+	}()
+
+	chunk := &model.CodeChunk{
+		ID:        uuid.NewString(),
+		ChunkType: model.ChunkTypeFunction,
+		Name:      "selfTest",
+		FilePath:  "selftest.go",
+		Embedding: embedding,
+	}
+	if err := ccs.vectorDB.UpsertChunks(ctx, collectionName, []*model.CodeChunk{chunk}); err != nil {
+		return fmt.Errorf("self-test failed to upsert a %d-dimension vector: %w", len(embedding), err)
+	}
+	if err := ccs.vectorDB.DeleteChunk(ctx, collectionName, chunk.ID); err != nil {
+		return fmt.Errorf("self-test failed to delete the upserted point: %w", err)
+	}
+
+	ccs.logger.Info("Vector services self-test passed",
+		zap.String("model", modelName),
+		zap.Int("dimension", len(embedding)),
+		zap.String("distance", string(ccs.distance)))
+	return nil
+}
@@ -0,0 +1,119 @@
+package vector
+
+import (
+	"bot-go/internal/model"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// recordingVectorDB is a mockVectorDB logging the operations the self-test
+// performs, failing the one named by fail
+type recordingVectorDB struct {
+	*mockVectorDB
+	ops  *[]string
+	fail string
+}
+
+func (r *recordingVectorDB) record(op string) error {
+	*r.ops = append(*r.ops, op)
+	if op == r.fail {
+		return errors.New("unauthorized")
+	}
+	return nil
+}
+
+func (r *recordingVectorDB) CreateCollection(ctx context.Context, collectionName string, vectorDim int, distance DistanceMetric) error {
+	if err := r.record("create"); err != nil {
+		return err
+	}
+	return r.mockVectorDB.CreateCollection(ctx, collectionName, vectorDim, distance)
+}
+
+func (r *recordingVectorDB) UpsertChunks(ctx context.Context, collectionName string, chunks []*model.CodeChunk) error {
+	if err := r.record("upsert"); err != nil {
+		return err
+	}
+	return r.mockVectorDB.UpsertChunks(ctx, collectionName, chunks)
+}
+
+func (r *recordingVectorDB) DeleteChunk(ctx context.Context, collectionName string, chunkID string) error {
+	if err := r.record("delete"); err != nil {
+		return err
+	}
+	return r.mockVectorDB.DeleteChunk(ctx, collectionName, chunkID)
+}
+
+func (r *recordingVectorDB) DeleteCollection(ctx context.Context, collectionName string) error {
+	if err := r.record("drop"); err != nil {
+		return err
+	}
+	return r.mockVectorDB.DeleteCollection(ctx, collectionName)
+}
+
+// recordingEmbedding is a mockEmbedding logging its calls, returning vectors
+// of length returned instead of its configured dimension when set
+type recordingEmbedding struct {
+	*mockEmbedding
+	ops      *[]string
+	fail     bool
+	returned int
+}
+
+func (r *recordingEmbedding) GenerateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	*r.ops = append(*r.ops, "embed")
+	if r.fail {
+		return nil, errors.New("connection refused")
+	}
+	if r.returned > 0 {
+		return make([]float32, r.returned), nil
+	}
+	return r.mockEmbedding.GenerateEmbedding(ctx, text)
+}
+
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		name      string
+		failEmbed bool
+		returned  int
+		failDB    string
+		wantOps   string
+		wantErr   string
+	}{
+		{name: "passes", wantOps: "embed,create,upsert,delete,drop"},
+		{name: "embedding unavailable", failEmbed: true, wantOps: "embed", wantErr: "failed to embed with model code"},
+		{name: "dimension mismatch", returned: 4, wantOps: "embed", wantErr: "returned 4 dimensions, configured for 8"},
+		{name: "upsert rejected", failDB: "upsert", wantOps: "embed,create,upsert,drop", wantErr: "failed to upsert a 8-dimension vector: unauthorized"},
+		{name: "delete rejected", failDB: "delete", wantOps: "embed,create,upsert,delete,drop", wantErr: "failed to delete the upserted point"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []string
+			db := &recordingVectorDB{mockVectorDB: newMockVectorDB(), ops: &ops, fail: tt.failDB}
+			embedding := &recordingEmbedding{mockEmbedding: newMockEmbedding("code", 8), ops: &ops, fail: tt.failEmbed, returned: tt.returned}
+			ccs := NewCodeChunkService(db, embedding, 5, 5, 0, 0, 0, 1, zap.NewNop())
+
+			err := ccs.SelfTest(context.Background())
+			if got := strings.Join(ops, ","); got != tt.wantOps {
+				t.Errorf("operations = %s, want %s", got, tt.wantOps)
+			}
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("SelfTest: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("SelfTest error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if tt.returned > 0 && !errors.Is(err, ErrDimensionMismatch) {
+				t.Errorf("dimension mismatch error %v does not wrap ErrDimensionMismatch", err)
+			}
+			if len(db.chunks) != 0 {
+				t.Errorf("self-test collections left behind: %v", db.chunks)
+			}
+		})
+	}
+}
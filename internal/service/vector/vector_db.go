@@ -3,6 +3,8 @@ package vector
 import (
 	"bot-go/internal/model"
 	"context"
+	"errors"
+	"fmt"
 )
 
 // VectorDatabase represents a generic vector database interface
@@ -11,18 +13,37 @@ type VectorDatabase interface {
 	// CreateCollection creates a new collection with the specified dimension and distance metric
 	CreateCollection(ctx context.Context, collectionName string, vectorDim int, distance DistanceMetric) error
 
+	// CreateCollectionWithNamedVectors creates a collection whose points carry one vector per name,
+	// each with its own dimension; chunks are then upserted with NamedEmbeddings
+	CreateCollectionWithNamedVectors(ctx context.Context, collectionName string, vectors []NamedVector, distance DistanceMetric) error
+
 	// DeleteCollection deletes a collection
 	DeleteCollection(ctx context.Context, collectionName string) error
 
 	// CollectionExists checks if a collection exists
 	CollectionExists(ctx context.Context, collectionName string) (bool, error)
 
+	// CollectionDistance returns the distance metric an existing collection was created with
+	CollectionDistance(ctx context.Context, collectionName string) (DistanceMetric, error)
+
+	// CollectionVectorNames returns the names of a collection's named vectors, or nil for a collection with a single unnamed vector
+	CollectionVectorNames(ctx context.Context, collectionName string) ([]string, error)
+
+	// SetCollectionEmbedding records the embedding model a collection's vectors are produced with
+	SetCollectionEmbedding(ctx context.Context, collectionName string, embedding CollectionEmbedding) error
+
+	// CollectionEmbedding returns the embedding model recorded for a collection, or nil if none was recorded
+	CollectionEmbedding(ctx context.Context, collectionName string) (*CollectionEmbedding, error)
+
 	// UpsertChunks inserts or updates code chunks in the vector database
 	UpsertChunks(ctx context.Context, collectionName string, chunks []*model.CodeChunk) error
 
 	// SearchSimilar finds similar code chunks using vector similarity search
 	SearchSimilar(ctx context.Context, collectionName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error)
 
+	// SearchSimilarNamed finds similar code chunks by comparing the query against one named vector
+	SearchSimilarNamed(ctx context.Context, collectionName, vectorName string, queryVector []float32, limit int, filter map[string]interface{}) ([]*model.CodeChunk, []float32, error)
+
 	// GetChunkByID retrieves a specific chunk by its ID
 	GetChunkByID(ctx context.Context, collectionName string, chunkID string) (*model.CodeChunk, error)
 
@@ -32,6 +53,9 @@ type VectorDatabase interface {
 	// GetChunksByFilePath retrieves all chunks for a specific file path
 	GetChunksByFilePath(ctx context.Context, collectionName string, filePath string) ([]*model.CodeChunk, error)
 
+	// GetChunksByType retrieves all chunks of a type, e.g. every function chunk, without their vectors
+	GetChunksByType(ctx context.Context, collectionName string, chunkType model.ChunkType) ([]*model.CodeChunk, error)
+
 	// Close closes the database connection
 	Close() error
 
@@ -52,3 +76,40 @@ const (
 	// DistanceMetricEuclidean uses Euclidean distance
 	DistanceMetricEuclidean DistanceMetric = "euclidean"
 )
+
+// NamedVector declares one of the vectors stored per point in a collection
+// with named vectors
+type NamedVector struct {
+	Name      string
+	Dimension int
+}
+
+// ErrDistanceMismatch is returned when an existing collection uses a different
+// distance metric than the configured one
+var ErrDistanceMismatch = errors.New("collection distance metric differs from configuration")
+
+// CollectionEmbedding is the collection metadata naming the embedding model
+// and vector dimension the collection was built with
+type CollectionEmbedding struct {
+	Model     string `json:"model"`
+	Dimension int    `json:"dimension"`
+}
+
+// ErrEmbeddingModelUnavailable is returned when a collection was built with an
+// embedding model that is not registered, so it cannot be searched
+var ErrEmbeddingModelUnavailable = errors.New("embedding model unavailable")
+
+// ParseDistanceMetric maps a configured metric name (cosine, dot or euclid) to
+// a DistanceMetric; an empty name selects cosine
+func ParseDistanceMetric(name string) (DistanceMetric, error) {
+	switch name {
+	case "", "cosine":
+		return DistanceMetricCosine, nil
+	case "dot":
+		return DistanceMetricDot, nil
+	case "euclid", "euclidean":
+		return DistanceMetricEuclidean, nil
+	default:
+		return "", fmt.Errorf("unknown distance metric %q", name)
+	}
+}
@@ -0,0 +1,71 @@
+package signals
+
+import "context"
+
+// Capability names an input a signal needs from its SignalContext
+type Capability string
+
+const (
+	CapabilityCodeGraph Capability = "code_graph"
+	CapabilityNGram     Capability = "ngram"
+	CapabilityVectorDB  Capability = "vector_db"
+	CapabilityGit       Capability = "git"
+)
+
+// HasCapability reports whether the context provides a capability
+func (sctx *SignalContext) HasCapability(capability Capability) bool {
+	if sctx == nil {
+		return false
+	}
+	switch capability {
+	case CapabilityCodeGraph:
+		return sctx.CodeGraph != nil
+	case CapabilityNGram:
+		return sctx.NGramService != nil
+	case CapabilityVectorDB:
+		return sctx.VectorDB != nil
+	case CapabilityGit:
+		return sctx.GitHistory
+	default:
+		return false
+	}
+}
+
+// MissingCapabilities returns the capabilities a signal requires that the
+// context does not provide
+func MissingCapabilities(signal Signal, sctx *SignalContext) []Capability {
+	var missing []Capability
+	for _, capability := range signal.RequiredCapabilities() {
+		if !sctx.HasCapability(capability) {
+			missing = append(missing, capability)
+		}
+	}
+	return missing
+}
+
+// ComputeClassSignal computes a class signal, or returns a skipped result
+// without calling it when the context lacks a capability it requires
+func ComputeClassSignal(ctx context.Context, signal ClassSignal, classInfo *ClassInfo, sctx *SignalContext) (SignalResult, error) {
+	if missing := MissingCapabilities(signal, sctx); len(missing) > 0 {
+		return NewSignalResultSkipped(signal.Metadata().Name, missing), nil
+	}
+	return signal.ComputeClass(ctx, classInfo, sctx)
+}
+
+// ComputeMethodSignal computes a method signal, or returns a skipped result
+// without calling it when the context lacks a capability it requires
+func ComputeMethodSignal(ctx context.Context, signal MethodSignal, methodInfo *MethodInfo, sctx *SignalContext) (SignalResult, error) {
+	if missing := MissingCapabilities(signal, sctx); len(missing) > 0 {
+		return NewSignalResultSkipped(signal.Metadata().Name, missing), nil
+	}
+	return signal.ComputeMethod(ctx, methodInfo, sctx)
+}
+
+// ComputeFileSignal computes a file signal, or returns a skipped result
+// without calling it when the context lacks a capability it requires
+func ComputeFileSignal(ctx context.Context, signal FileSignal, fileInfo *FileInfo, sctx *SignalContext) (SignalResult, error) {
+	if missing := MissingCapabilities(signal, sctx); len(missing) > 0 {
+		return NewSignalResultSkipped(signal.Metadata().Name, missing), nil
+	}
+	return signal.ComputeFile(ctx, fileInfo, sctx)
+}
@@ -0,0 +1,57 @@
+package signals_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/signals"
+	"bot-go/internal/signals/coupling"
+	"bot-go/internal/signals/size"
+	"bot-go/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+func TestComputeClassSignalSkipsMissingCapabilities(t *testing.T) {
+	classInfo := &signals.ClassInfo{Name: "Worker"}
+	cg := codegraph.NewCodeGraphWithDatabase(testutil.NewMockGraphDatabase(), &config.Config{}, zap.NewNop())
+
+	tests := []struct {
+		name        string
+		signal      signals.ClassSignal
+		sctx        *signals.SignalContext
+		wantSkipped bool
+	}{
+		{"graph signal without code graph", coupling.NewATFDSignal(), &signals.SignalContext{}, true},
+		{"graph signal without context", coupling.NewATFDSignal(), nil, true},
+		{"graph signal with code graph", coupling.NewATFDSignal(), &signals.SignalContext{CodeGraph: cg}, false},
+		{"signal without requirements", size.NewNOFSignal(), &signals.SignalContext{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := signals.ComputeClassSignal(context.Background(), tt.signal, classInfo, tt.sctx)
+			if err != nil {
+				t.Fatalf("ComputeClassSignal failed: %v", err)
+			}
+			if result.Skipped != tt.wantSkipped {
+				t.Fatalf("Skipped = %v, want %v", result.Skipped, tt.wantSkipped)
+			}
+			if !tt.wantSkipped {
+				return
+			}
+			if result.IsValid() || !errors.Is(result.Error, signals.ErrCapabilityUnavailable) {
+				t.Errorf("skipped result is valid or has error %v, want ErrCapabilityUnavailable", result.Error)
+			}
+
+			set := signals.NewSignalResultSet("class", classInfo.NodeID, classInfo.Name, "")
+			set.AddResult(result)
+			if len(set.GetSkippedResults()) != 1 || len(set.GetValidResults()) != 0 || len(set.GetErrorResults()) != 0 {
+				t.Errorf("skipped result is not reported apart from valid and failed results")
+			}
+		})
+	}
+}
@@ -37,6 +37,11 @@ func (s *CCSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *CCSignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityGit}
+}
+
 // ComputeClass computes CC for a class
 func (s *CCSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -37,6 +37,11 @@ func (s *CMSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *CMSignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityGit}
+}
+
 // ComputeMethod computes CM for a method
 func (s *CMSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -32,6 +32,11 @@ func (s *ATLDSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *ATLDSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeMethod computes ATLD for a method
 func (s *ATLDSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -32,6 +32,11 @@ func (s *LCCSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *LCCSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes LCC for a class
 func (s *LCCSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -3,7 +3,9 @@ package cohesion
 import (
 	"context"
 
+	"bot-go/internal/model/ast"
 	"bot-go/internal/signals"
+	"bot-go/internal/signals/util"
 )
 
 // LCOMSignal computes Lack of Cohesion in Methods (LCOM1)
@@ -32,6 +34,11 @@ func (s *LCOMSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *LCOMSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes LCOM for a class
 func (s *LCOMSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -63,7 +70,138 @@ func (s *LCOM4Signal) Dependencies() []string {
 	return nil
 }
 
-// ComputeClass computes LCOM4 for a class
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *LCOM4Signal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
+// ComputeClass computes LCOM4 for a class: the number of groups of methods
+// linked by sharing a field of the class or by calling one another. Field
+// accesses come from the code graph when the context has one and from the
+// methods' FieldAccesses otherwise.
 func (s *LCOM4Signal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
-	return signals.SignalResult{}, nil
+	if classInfo == nil {
+		return signals.NewSignalResultError("LCOM4", signals.ErrNilInput), nil
+	}
+
+	matrix, err := s.buildFieldAccessMatrix(ctx, classInfo, sctx)
+	if err != nil {
+		return signals.NewSignalResultError("LCOM4", err), nil
+	}
+
+	components := newMethodComponents()
+	for _, method := range classInfo.Methods {
+		components.add(method.NodeID)
+	}
+	for methodID := range matrix.Methods {
+		components.add(methodID)
+	}
+	for _, methods := range matrix.Fields {
+		for _, methodID := range methods[1:] {
+			components.union(methods[0], methodID)
+		}
+	}
+	for _, method := range classInfo.Methods {
+		for _, call := range method.FunctionCalls {
+			// Only calls to methods of this class connect it
+			if components.has(call.TargetMethodID) {
+				components.union(method.NodeID, call.TargetMethodID)
+			}
+		}
+	}
+
+	return signals.NewSignalResultWithMetadata("LCOM4", float64(components.count()), map[string]any{
+		"methods": len(components.parent),
+		"fields":  len(matrix.Fields),
+	}), nil
+}
+
+// buildFieldAccessMatrix maps the class's methods to the class fields they
+// access. In the code graph every access is its own Field node, so accesses
+// on the receiver are grouped by field name, each name keyed by the ID of its
+// first access seen.
+func (s *LCOM4Signal) buildFieldAccessMatrix(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (*util.FieldAccessMatrix, error) {
+	matrix := util.NewFieldAccessMatrix()
+	if sctx.HasCapability(signals.CapabilityCodeGraph) && classInfo.NodeID != ast.InvalidNodeID {
+		accesses, err := sctx.CodeGraph.GetReceiverFieldAccesses(ctx, classInfo.NodeID)
+		if err != nil {
+			return nil, err
+		}
+		fieldIDs := make(map[string]ast.NodeID)
+		for _, access := range accesses {
+			fieldID, ok := fieldIDs[access.Field.Name]
+			if !ok {
+				fieldID = access.Field.ID
+				fieldIDs[access.Field.Name] = fieldID
+			}
+			matrix.AddAccess(access.MethodID, fieldID)
+		}
+		// Receiver accesses are the class's own fields by construction
+		return matrix, nil
+	}
+
+	for _, method := range classInfo.Methods {
+		for _, access := range method.FieldAccesses {
+			matrix.AddAccess(method.NodeID, access.FieldNodeID)
+		}
+	}
+
+	// Fields of other classes do not tie this class's methods together
+	classFields := make(map[ast.NodeID]bool, len(classInfo.Fields))
+	for _, field := range classInfo.Fields {
+		classFields[field.NodeID] = true
+	}
+	if len(classFields) > 0 {
+		for fieldID := range matrix.Fields {
+			if !classFields[fieldID] {
+				delete(matrix.Fields, fieldID)
+			}
+		}
+	}
+	return matrix, nil
+}
+
+// methodComponents is a union-find over method IDs
+type methodComponents struct {
+	parent map[ast.NodeID]ast.NodeID
+}
+
+func newMethodComponents() *methodComponents {
+	return &methodComponents{parent: make(map[ast.NodeID]ast.NodeID)}
+}
+
+func (c *methodComponents) add(id ast.NodeID) {
+	if _, ok := c.parent[id]; !ok {
+		c.parent[id] = id
+	}
+}
+
+func (c *methodComponents) has(id ast.NodeID) bool {
+	_, ok := c.parent[id]
+	return ok
+}
+
+func (c *methodComponents) find(id ast.NodeID) ast.NodeID {
+	for c.parent[id] != id {
+		c.parent[id] = c.parent[c.parent[id]]
+		id = c.parent[id]
+	}
+	return id
+}
+
+func (c *methodComponents) union(a, b ast.NodeID) {
+	c.add(a)
+	c.add(b)
+	c.parent[c.find(a)] = c.find(b)
+}
+
+// count returns the number of disjoint groups
+func (c *methodComponents) count() int {
+	roots := 0
+	for id := range c.parent {
+		if c.find(id) == id {
+			roots++
+		}
+	}
+	return roots
 }
@@ -0,0 +1,80 @@
+package cohesion
+
+import (
+	"context"
+	"testing"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/signals"
+)
+
+// newCohesionClass builds a class whose methods access the given fields and
+// call the given methods, both by node ID
+func newCohesionClass(fields []ast.NodeID, accesses map[ast.NodeID][]ast.NodeID, calls map[ast.NodeID][]ast.NodeID) *signals.ClassInfo {
+	classInfo := &signals.ClassInfo{NodeID: 1, Name: "Account"}
+	for _, fieldID := range fields {
+		classInfo.Fields = append(classInfo.Fields, &signals.FieldInfo{NodeID: fieldID})
+	}
+	for methodID := ast.NodeID(10); methodID < 10+ast.NodeID(len(accesses)); methodID++ {
+		method := &signals.MethodInfo{NodeID: methodID}
+		for _, fieldID := range accesses[methodID] {
+			method.FieldAccesses = append(method.FieldAccesses, &signals.FieldAccessInfo{FieldNodeID: fieldID})
+		}
+		for _, target := range calls[methodID] {
+			method.FunctionCalls = append(method.FunctionCalls, &signals.FunctionCallInfo{TargetMethodID: target})
+		}
+		classInfo.Methods = append(classInfo.Methods, method)
+	}
+	return classInfo
+}
+
+func TestLCOM4Signal(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []ast.NodeID
+		accesses map[ast.NodeID][]ast.NodeID // method -> fields; methods are numbered from 10
+		calls    map[ast.NodeID][]ast.NodeID
+		want     float64
+	}{
+		{
+			name:     "two disjoint field groups",
+			fields:   []ast.NodeID{1001, 1002, 1003},
+			accesses: map[ast.NodeID][]ast.NodeID{10: {1001}, 11: {1001, 1002}, 12: {1003}, 13: {1003}},
+			want:     2,
+		},
+		{
+			name:     "call joins the groups",
+			fields:   []ast.NodeID{1001, 1003},
+			accesses: map[ast.NodeID][]ast.NodeID{10: {1001}, 11: {1001}, 12: {1003}, 13: {1003}},
+			calls:    map[ast.NodeID][]ast.NodeID{11: {12}},
+			want:     1,
+		},
+		{
+			name:     "foreign field does not connect",
+			fields:   []ast.NodeID{1001},
+			accesses: map[ast.NodeID][]ast.NodeID{10: {1001, 2001}, 11: {2001}},
+			want:     2,
+		},
+		{
+			name:     "method without fields stands alone",
+			fields:   []ast.NodeID{1001},
+			accesses: map[ast.NodeID][]ast.NodeID{10: {1001}, 11: {1001}, 12: nil},
+			want:     2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classInfo := newCohesionClass(tt.fields, tt.accesses, tt.calls)
+			sctx := &signals.SignalContext{}
+
+			result, err := NewLCOM4Signal().ComputeClass(context.Background(), classInfo, sctx)
+			if err != nil || result.Error != nil {
+				t.Fatalf("ComputeClass: %v, %v", err, result.Error)
+			}
+			if result.Value != tt.want {
+				t.Errorf("LCOM4 = %v, want %v", result.Value, tt.want)
+			}
+		})
+	}
+}
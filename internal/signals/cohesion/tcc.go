@@ -33,6 +33,11 @@ func (s *TCCSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *TCCSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes TCC for a class
 func (s *TCCSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
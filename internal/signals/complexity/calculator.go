@@ -0,0 +1,97 @@
+package complexity
+
+import (
+	"context"
+	"fmt"
+
+	"bot-go/internal/model/ast"
+)
+
+// ComplexityMetadataKey is the function node metadata key holding the cached
+// cyclomatic complexity (stored in the graph as md_complexity)
+const ComplexityMetadataKey = "complexity"
+
+// LogicalOperatorsMetadataKey is the function node metadata key holding the
+// number of && and || (and/or in Python) operators recorded by the parser
+const LogicalOperatorsMetadataKey = "logical_operators"
+
+// DecisionPointCounter counts decision points of a function in the code graph
+type DecisionPointCounter interface {
+	CountDecisionPoints(ctx context.Context, functionID ast.NodeID) (int, error)
+}
+
+// ComplexityCalculator computes cyclomatic complexity for function nodes,
+// preferring the value cached on the node during post-processing
+type ComplexityCalculator struct {
+	counter DecisionPointCounter
+}
+
+// NewComplexityCalculator creates a new ComplexityCalculator
+func NewComplexityCalculator(counter DecisionPointCounter) *ComplexityCalculator {
+	return &ComplexityCalculator{counter: counter}
+}
+
+// Calculate returns the cyclomatic complexity of a function node. The cached
+// metadata value is used when present; otherwise the graph is queried.
+func (c *ComplexityCalculator) Calculate(ctx context.Context, function *ast.Node) (int, error) {
+	if complexity, ok := CachedComplexity(function); ok {
+		return complexity, nil
+	}
+	return c.Compute(ctx, function.ID)
+}
+
+// Compute always queries the graph for the cyclomatic complexity of a function
+func (c *ComplexityCalculator) Compute(ctx context.Context, functionID ast.NodeID) (int, error) {
+	decisionPoints, err := c.counter.CountDecisionPoints(ctx, functionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute complexity for function %d: %w", functionID, err)
+	}
+	return decisionPoints + 1, nil
+}
+
+// CalculateWithLogicalOps is Calculate counting each short-circuit boolean
+// operator as a decision point, as McCabe complexity does. The cached value is
+// used when present, so post-processing must cache the same variant.
+func (c *ComplexityCalculator) CalculateWithLogicalOps(ctx context.Context, function *ast.Node) (int, error) {
+	if complexity, ok := CachedComplexity(function); ok {
+		return complexity, nil
+	}
+	return c.ComputeWithLogicalOps(ctx, function)
+}
+
+// ComputeWithLogicalOps always queries the graph and adds the function's
+// logical operators to its decision points
+func (c *ComplexityCalculator) ComputeWithLogicalOps(ctx context.Context, function *ast.Node) (int, error) {
+	complexity, err := c.Compute(ctx, function.ID)
+	if err != nil {
+		return 0, err
+	}
+	logicalOps, _ := intMetadata(function, LogicalOperatorsMetadataKey)
+	return complexity + logicalOps, nil
+}
+
+// CachedComplexity returns the complexity stored in a node's metadata, if any
+func CachedComplexity(function *ast.Node) (int, bool) {
+	return intMetadata(function, ComplexityMetadataKey)
+}
+
+// intMetadata reads an integer metadata value, which arrives as int64 from
+// the graph and float64 from JSON
+func intMetadata(function *ast.Node, key string) (int, bool) {
+	if function == nil || function.MetaData == nil {
+		return 0, false
+	}
+
+	switch v := function.MetaData[key].(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,118 @@
+package complexity
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bot-go/internal/model/ast"
+)
+
+// countingGraph is a DecisionPointCounter that records how often it is queried
+type countingGraph struct {
+	decisionPoints int
+	err            error
+	calls          int
+}
+
+func (g *countingGraph) CountDecisionPoints(ctx context.Context, functionID ast.NodeID) (int, error) {
+	g.calls++
+	return g.decisionPoints, g.err
+}
+
+func TestComplexityCalculatorUsesCachedMetadata(t *testing.T) {
+	tests := []struct {
+		name   string
+		cached any
+		want   int
+	}{
+		{"int", 7, 7},
+		{"int64 from graph", int64(4), 4},
+		{"float64 from json", float64(12), 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			graph := &countingGraph{decisionPoints: 99}
+			calculator := NewComplexityCalculator(graph)
+
+			function := &ast.Node{
+				ID:       42,
+				NodeType: ast.NodeTypeFunction,
+				MetaData: map[string]any{ComplexityMetadataKey: tt.cached},
+			}
+
+			got, err := calculator.Calculate(context.Background(), function)
+			if err != nil {
+				t.Fatalf("Calculate returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Calculate = %d, want %d", got, tt.want)
+			}
+			if graph.calls != 0 {
+				t.Errorf("graph queried %d times, want 0", graph.calls)
+			}
+		})
+	}
+}
+
+func TestComplexityCalculatorQueriesGraphOnMiss(t *testing.T) {
+	graph := &countingGraph{decisionPoints: 3}
+	calculator := NewComplexityCalculator(graph)
+
+	function := &ast.Node{ID: 42, NodeType: ast.NodeTypeFunction}
+	got, err := calculator.Calculate(context.Background(), function)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	if got != 4 {
+		t.Errorf("Calculate = %d, want 4 (3 decision points + 1)", got)
+	}
+	if graph.calls != 1 {
+		t.Errorf("graph queried %d times, want 1", graph.calls)
+	}
+
+	graph.err = errors.New("connection refused")
+	if _, err := calculator.Calculate(context.Background(), function); err == nil {
+		t.Error("Calculate should propagate graph errors")
+	}
+}
+
+func TestComplexityCalculatorWithLogicalOps(t *testing.T) {
+	// if a && b && c { ... }: one guarded branch and two && operators
+	graph := &countingGraph{decisionPoints: 1}
+	calculator := NewComplexityCalculator(graph)
+
+	function := &ast.Node{
+		ID:       42,
+		NodeType: ast.NodeTypeFunction,
+		MetaData: map[string]any{LogicalOperatorsMetadataKey: int64(2)},
+	}
+
+	plain, err := calculator.Calculate(context.Background(), function)
+	if err != nil {
+		t.Fatalf("Calculate returned error: %v", err)
+	}
+	if plain != 2 {
+		t.Errorf("Calculate = %d, want 2 (operators ignored)", plain)
+	}
+
+	got, err := calculator.CalculateWithLogicalOps(context.Background(), function)
+	if err != nil {
+		t.Fatalf("CalculateWithLogicalOps returned error: %v", err)
+	}
+	if got != 4 {
+		t.Errorf("CalculateWithLogicalOps = %d, want 4 (1 decision point + 2 operators + 1)", got)
+	}
+
+	// Functions without recorded operators match Calculate
+	function.MetaData = nil
+	if got, _ := calculator.CalculateWithLogicalOps(context.Background(), function); got != 2 {
+		t.Errorf("CalculateWithLogicalOps without operators = %d, want 2", got)
+	}
+
+	graph.err = errors.New("connection refused")
+	if _, err := calculator.CalculateWithLogicalOps(context.Background(), function); err == nil {
+		t.Error("CalculateWithLogicalOps should propagate graph errors")
+	}
+}
@@ -32,7 +32,36 @@ func (s *CYCLOSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *CYCLOSignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityCodeGraph}
+}
+
 // ComputeMethod computes CYCLO for a method
+// Uses the complexity cached on the function node when post-processing stored one
+// Counts && and || as decision points when ComplexityLogicalOps is set
 func (s *CYCLOSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
-	return signals.SignalResult{}, nil
+	if methodInfo == nil || sctx == nil || sctx.CodeGraph == nil {
+		return signals.NewSignalResultError("CYCLO", signals.ErrNilInput), nil
+	}
+
+	function, err := sctx.CodeGraph.ReadFunction(ctx, methodInfo.NodeID)
+	if err != nil {
+		return signals.NewSignalResultError("CYCLO", err), nil
+	}
+
+	calculator := NewComplexityCalculator(sctx.CodeGraph)
+	calculate := calculator.Calculate
+	if sctx.ComplexityLogicalOps {
+		calculate = calculator.CalculateWithLogicalOps
+	}
+	complexity, err := calculate(ctx, function)
+	if err != nil {
+		return signals.NewSignalResultError("CYCLO", err), nil
+	}
+
+	return signals.NewSignalResultWithMetadata("CYCLO", float64(complexity), map[string]any{
+		"method_id":   methodInfo.NodeID,
+		"method_name": methodInfo.Name,
+	}), nil
 }
@@ -32,6 +32,11 @@ func (s *MAXNESTINGSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *MAXNESTINGSignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityCodeGraph}
+}
+
 // ComputeMethod computes MAXNESTING for a method
 func (s *MAXNESTINGSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
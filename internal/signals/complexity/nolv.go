@@ -32,6 +32,11 @@ func (s *NOLVSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *NOLVSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeMethod computes NOLV for a method
 func (s *NOLVSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
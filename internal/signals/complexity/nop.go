@@ -0,0 +1,51 @@
+package complexity
+
+import (
+	"context"
+
+	"bot-go/internal/signals"
+)
+
+// NOPSignal computes Number of Parameters
+type NOPSignal struct{}
+
+// NewNOPSignal creates a new NOP signal
+func NewNOPSignal() *NOPSignal {
+	return &NOPSignal{}
+}
+
+// Metadata returns information about this signal
+func (s *NOPSignal) Metadata() signals.SignalMetadata {
+	return signals.SignalMetadata{
+		Name:        "NOP",
+		FullName:    "Number of Parameters",
+		Category:    signals.CategoryComplexity,
+		Scope:       signals.ScopeMethod,
+		Description: "Count of parameters declared by a method",
+		Unit:        "count",
+		LowerBetter: true,
+	}
+}
+
+// Dependencies returns names of signals this signal depends on
+func (s *NOPSignal) Dependencies() []string {
+	return nil
+}
+
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *NOPSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
+// ComputeMethod computes NOP for a method
+// Counts the parameters extracted from the method's FUNCTION_ARG relations
+func (s *NOPSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
+	if methodInfo == nil {
+		return signals.NewSignalResultError("NOP", signals.ErrNilInput), nil
+	}
+
+	return signals.NewSignalResultWithMetadata("NOP", float64(methodInfo.GetParameterCount()), map[string]any{
+		"method_id":   methodInfo.NodeID,
+		"method_name": methodInfo.Name,
+	}), nil
+}
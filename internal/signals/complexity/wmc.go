@@ -32,6 +32,11 @@ func (s *WMCSignal) Dependencies() []string {
 	return []string{"CYCLO"}
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *WMCSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes WMC for a class
 func (s *WMCSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -39,7 +44,13 @@ func (s *WMCSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassIn
 
 // Aggregate sums CYCLO values from all methods
 func (s *WMCSignal) Aggregate(ctx context.Context, methodResults []signals.SignalResult) (signals.SignalResult, error) {
-	return signals.SignalResult{}, nil
+	total := 0.0
+	for _, result := range methodResults {
+		if result.IsValid() {
+			total += result.Value
+		}
+	}
+	return signals.NewSignalResult("WMC", total), nil
 }
 
 // WMCNAMMSignal computes WMC without Accessor/Mutator methods
@@ -68,6 +79,11 @@ func (s *WMCNAMMSignal) Dependencies() []string {
 	return []string{"CYCLO"}
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *WMCNAMMSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes WMCNAMM for a class
 func (s *WMCNAMMSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -99,6 +115,11 @@ func (s *AMCSignal) Dependencies() []string {
 	return []string{"WMC", "NOM"}
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *AMCSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeWithDependencies computes AMC using WMC and NOM
 func (s *AMCSignal) ComputeWithDependencies(ctx context.Context, target interface{}, deps map[string]signals.SignalResult, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
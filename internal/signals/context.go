@@ -20,9 +20,22 @@ type SignalContext struct {
 	// Vector database for embeddings (optional)
 	VectorDB vector.VectorDatabase
 
+	// GitHistory is set when change signals were registered with a git analyzer
+	GitHistory bool
+
+	// ComplexityLogicalOps makes CYCLO count && and || operators as decision
+	// points (code_graph.complexity_logical_ops)
+	ComplexityLogicalOps bool
+
+	// ExternalPackages are package names or file path prefixes of standard
+	// library and vendored code, whose classes ATFD does not count as foreign
+	// data (code_graph.external_packages)
+	ExternalPackages []string
+
 	// Repository information
 	RepoName string
 	RepoPath string
+	Language string // Configured repository language; "" infers it from file paths
 
 	// Caching
 	Cache *SignalCache
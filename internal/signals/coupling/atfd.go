@@ -2,16 +2,24 @@ package coupling
 
 import (
 	"context"
+	"strings"
 
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
 	"bot-go/internal/signals"
+	"bot-go/internal/signals/util"
 )
 
 // ATFDSignal computes Access To Foreign Data
-type ATFDSignal struct{}
+type ATFDSignal struct {
+	accessorDetector *util.AccessorDetector
+}
 
 // NewATFDSignal creates a new ATFD signal
 func NewATFDSignal() *ATFDSignal {
-	return &ATFDSignal{}
+	return &ATFDSignal{
+		accessorDetector: util.NewAccessorDetector(),
+	}
 }
 
 // Metadata returns information about this signal
@@ -21,7 +29,7 @@ func (s *ATFDSignal) Metadata() signals.SignalMetadata {
 		FullName:    "Access To Foreign Data",
 		Category:    signals.CategoryCoupling,
 		Scope:       signals.ScopeClass,
-		Description: "Number of external class attributes accessed directly or via accessor methods",
+		Description: "Number of external class attributes accessed via accessor methods",
 		Unit:        "count",
 		LowerBetter: true, // Lower ATFD means less coupling to foreign data
 	}
@@ -32,12 +40,107 @@ func (s *ATFDSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *ATFDSignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityCodeGraph}
+}
+
 // ComputeClass computes ATFD for a class
+// Counts the distinct getters and setters of other classes its methods call.
+// Calls to other foreign methods use behavior rather than data and are not
+// counted. Direct reads of foreign fields cannot be attributed to their class
+// in the code graph (see CodeGraph.GetForeignAccesses), so they are not
+// counted either. Classes in the
+// configured external packages (standard library and vendored code) are not
+// part of the repository's design, so accesses to them are excluded and
+// reported in the metadata instead.
 func (s *ATFDSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
-	return signals.SignalResult{}, nil
+	if classInfo == nil || sctx == nil || sctx.CodeGraph == nil {
+		return signals.NewSignalResultError("ATFD", signals.ErrNilInput), nil
+	}
+
+	accesses, err := sctx.CodeGraph.GetForeignAccesses(ctx, classInfo.NodeID)
+	if err != nil {
+		return signals.NewSignalResultError("ATFD", err), nil
+	}
+
+	foreign := make(map[ast.NodeID]bool)
+	excluded := make(map[ast.NodeID]bool)
+	foreignClasses := make(map[ast.NodeID]bool)
+	ownerMethods := make(map[ast.NodeID]map[ast.NodeID]*signals.MethodInfo)
+	for _, access := range accesses {
+		if isExternalPackage(sctx.ExternalPackages, access.OwnerModule, access.OwnerPath) {
+			excluded[access.MemberID] = true
+			continue
+		}
+		accessor, err := s.isAccessor(ctx, access, ownerMethods, sctx)
+		if err != nil {
+			return signals.NewSignalResultError("ATFD", err), nil
+		}
+		if !accessor {
+			continue
+		}
+		foreign[access.MemberID] = true
+		foreignClasses[access.OwnerClassID] = true
+	}
+
+	return signals.NewSignalResultWithMetadata("ATFD", float64(len(foreign)), map[string]any{
+		"foreign_classes":   len(foreignClasses),
+		"external_accesses": len(excluded),
+	}), nil
+}
+
+// isAccessor reports whether a foreign method is a getter or setter of its
+// class. The methods of each owner class are extracted once into ownerMethods.
+func (s *ATFDSignal) isAccessor(ctx context.Context, access codegraph.ForeignAccess,
+	ownerMethods map[ast.NodeID]map[ast.NodeID]*signals.MethodInfo, sctx *signals.SignalContext) (bool, error) {
+	methods, ok := ownerMethods[access.OwnerClassID]
+	if !ok {
+		owner := signals.NewClassInfo(access.OwnerClassID, "", access.OwnerPath, 0)
+		extracted, err := signals.ExtractMethods(ctx, owner, sctx)
+		if err != nil {
+			return false, err
+		}
+		methods = make(map[ast.NodeID]*signals.MethodInfo, len(extracted))
+		for _, method := range extracted {
+			methods[method.NodeID] = method
+		}
+		ownerMethods[access.OwnerClassID] = methods
+	}
+
+	method := methods[access.MemberID]
+	return method != nil && s.accessorDetector.IsAccessor(sctx.Language, method), nil
 }
 
 // ComputeMethod computes ATFD for a method
 func (s *ATFDSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
 }
+
+// isExternalPackage reports whether a class's package (its ModuleScope name)
+// or file path falls under one of the external prefixes. A prefix matches
+// whole segments, so "net" matches "net" and "net/http" but not "network".
+func isExternalPackage(prefixes []string, module, filePath string) bool {
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			continue
+		}
+		if hasSegmentPrefix(module, prefix) || hasSegmentPrefix(filePath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSegmentPrefix reports whether name starts with prefix at a "/" or "."
+// boundary
+func hasSegmentPrefix(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) || strings.HasSuffix(prefix, "/") || strings.HasSuffix(prefix, ".") {
+		return true
+	}
+	next := name[len(prefix)]
+	return next == '/' || next == '.'
+}
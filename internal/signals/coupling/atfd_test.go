@@ -0,0 +1,23 @@
+package coupling
+
+import "testing"
+
+func TestIsExternalPackage(t *testing.T) {
+	prefixes := []string{"net", "golang.org/x/", "vendor/"}
+	tests := []struct {
+		module, path string
+		want         bool
+	}{
+		{"net", "", true},
+		{"net/http", "", true},
+		{"network", "app/network.go", false},
+		{"golang.org/x/sync", "", true},
+		{"errgroup", "vendor/golang.org/x/sync/errgroup/errgroup.go", true},
+		{"shop", "shop/order.go", false},
+	}
+	for _, tt := range tests {
+		if got := isExternalPackage(prefixes, tt.module, tt.path); got != tt.want {
+			t.Errorf("isExternalPackage(%q, %q) = %v, want %v", tt.module, tt.path, got, tt.want)
+		}
+	}
+}
@@ -32,6 +32,11 @@ func (s *CBOSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *CBOSignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityCodeGraph}
+}
+
 // ComputeClass computes CBO for a class
 func (s *CBOSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -32,6 +32,11 @@ func (s *CDISPSignal) Dependencies() []string {
 	return []string{"CINT", "FANOUT"}
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *CDISPSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeWithDependencies computes CDISP using CINT and FANOUT
 func (s *CDISPSignal) ComputeWithDependencies(ctx context.Context, target interface{}, deps map[string]signals.SignalResult, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
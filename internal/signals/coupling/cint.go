@@ -32,6 +32,11 @@ func (s *CINTSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *CINTSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeMethod computes CINT for a method
 func (s *CINTSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -32,6 +32,11 @@ func (s *FANOUTSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *FANOUTSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeMethod computes FANOUT for a method
 func (s *FANOUTSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
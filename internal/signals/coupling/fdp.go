@@ -32,6 +32,11 @@ func (s *FDPSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *FDPSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeMethod computes FDP for a method
 func (s *FDPSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
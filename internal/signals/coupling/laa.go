@@ -32,6 +32,11 @@ func (s *LAASignal) Dependencies() []string {
 	return []string{"ATLD", "ATFD"}
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *LAASignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeWithDependencies calculates ATLD / (ATLD + ATFD)
 func (s *LAASignal) ComputeWithDependencies(ctx context.Context, target interface{}, deps map[string]signals.SignalResult, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -32,6 +32,11 @@ func (s *RFCSignal) Dependencies() []string {
 	return []string{"NOM", "CINT"}
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *RFCSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes RFC for a class
 func (s *RFCSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
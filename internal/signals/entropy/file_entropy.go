@@ -32,6 +32,11 @@ func (s *FileEntropySignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *FileEntropySignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityNGram}
+}
+
 // ComputeFile computes entropy for a file
 func (s *FileEntropySignal) ComputeFile(ctx context.Context, fileInfo *signals.FileInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
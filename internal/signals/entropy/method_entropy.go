@@ -32,6 +32,11 @@ func (s *MethodEntropySignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *MethodEntropySignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityNGram}
+}
+
 // ComputeMethod computes entropy for a method
 func (s *MethodEntropySignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -63,6 +68,11 @@ func (s *ClassEntropySignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *ClassEntropySignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityNGram}
+}
+
 // ComputeClass computes entropy for a class
 func (s *ClassEntropySignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -32,6 +32,11 @@ func (s *ZScoreSignal) Dependencies() []string {
 	return []string{"MethodEntropy"}
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *ZScoreSignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityNGram}
+}
+
 // ComputeMethod computes z-score for a method
 func (s *ZScoreSignal) ComputeMethod(ctx context.Context, methodInfo *signals.MethodInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
@@ -72,6 +77,11 @@ func (s *HighEntropyMethodCountSignal) Dependencies() []string {
 	return []string{"MethodEntropy"}
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *HighEntropyMethodCountSignal) RequiredCapabilities() []signals.Capability {
+	return []signals.Capability{signals.CapabilityNGram}
+}
+
 // ComputeClass computes high entropy method count for a class
 func (s *HighEntropyMethodCountSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
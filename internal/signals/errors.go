@@ -15,4 +15,7 @@ var (
 
 	// ErrDependencyMissing indicates a required dependency signal is missing
 	ErrDependencyMissing = errors.New("required dependency signal missing")
+
+	// ErrCapabilityUnavailable indicates an input required by the signal is not available
+	ErrCapabilityUnavailable = errors.New("required capability unavailable")
 )
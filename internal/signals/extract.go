@@ -0,0 +1,244 @@
+package signals
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/util"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+// ExtractMethods builds the MethodInfo of each method contained by a class
+// from the CodeGraph, with its parameters in declaration order and, when the
+// class's file can be read, its source code
+func ExtractMethods(ctx context.Context, classInfo *ClassInfo, sctx *SignalContext) ([]*MethodInfo, error) {
+	if classInfo == nil || sctx == nil || sctx.CodeGraph == nil {
+		return nil, ErrNilInput
+	}
+
+	nodes, err := sctx.CodeGraph.GetMethodsOfClass(ctx, classInfo.NodeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get methods of class %d: %w", classInfo.NodeID, err)
+	}
+
+	lines := readSourceLines(classInfo.FilePath, sctx)
+	methods := make([]*MethodInfo, 0, len(nodes))
+	for _, node := range nodes {
+		method := NewMethodInfo(node.ID, node.Name, classInfo.NodeID)
+		method.ClassName = classInfo.Name
+		method.FilePath = classInfo.FilePath
+		method.FileID = node.FileID
+		method.Range = node.Range
+		if returnType, ok := node.MetaData["return_type"].(string); ok {
+			method.ReturnType = returnType
+		}
+		method.Decorators = metadataStrings(node.MetaData["decorators"])
+
+		parameters, err := ExtractParameters(ctx, sctx.CodeGraph, node.ID)
+		if err != nil {
+			return nil, err
+		}
+		method.Parameters = parameters
+		method.SourceCode = sourceInRange(lines, node.Range)
+
+		methods = append(methods, method)
+	}
+	return methods, nil
+}
+
+// ExtractFields builds the FieldInfo of each field of a class, ordered by
+// position. Fields come from two places in the CodeGraph: the variables a
+// class declares (HAS_FIELD, e.g. Go struct members) and the attributes its
+// methods access on their receiver, such as self.total in Python. The parser
+// records each receiver access as its own Field node, so a field is
+// identified by name and represented by its first access. Visibility is left
+// for signals to infer.
+func ExtractFields(ctx context.Context, classInfo *ClassInfo, sctx *SignalContext) ([]*FieldInfo, error) {
+	if classInfo == nil || sctx == nil || sctx.CodeGraph == nil {
+		return nil, ErrNilInput
+	}
+
+	nodes, err := sctx.CodeGraph.GetChildNodes(ctx, classInfo.NodeID, "HAS_FIELD", ast.NodeTypeVariable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get fields of class %d: %w", classInfo.NodeID, err)
+	}
+	accesses, err := sctx.CodeGraph.GetReceiverFieldAccesses(ctx, classInfo.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	for _, access := range accesses {
+		nodes = append(nodes, access.Field)
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return positionBefore(nodes[i].Range.Start, nodes[j].Range.Start)
+	})
+
+	fields := make([]*FieldInfo, 0, len(nodes))
+	seen := make(map[string]bool, len(nodes))
+	for _, node := range nodes {
+		if node.Name == "" || seen[node.Name] {
+			continue
+		}
+		seen[node.Name] = true
+
+		field := &FieldInfo{
+			NodeID:      node.ID,
+			Name:        node.Name,
+			ClassNodeID: classInfo.NodeID,
+			Range:       node.Range,
+		}
+		if fieldType, ok := node.MetaData["type"].(string); ok {
+			field.Type = fieldType
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
+
+// positionBefore reports whether position a comes before b
+func positionBefore(a, b base.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Character < b.Character
+}
+
+// ExtractClass builds the ClassInfo of a class node with its methods and
+// fields read from the CodeGraph, so size signals such as NOM and NOF count
+// exactly what the graph holds
+func ExtractClass(ctx context.Context, classNode *ast.Node, filePath string, sctx *SignalContext) (*ClassInfo, error) {
+	if classNode == nil {
+		return nil, ErrNilInput
+	}
+
+	classInfo := NewClassInfo(classNode.ID, classNode.Name, filePath, classNode.FileID)
+	classInfo.Range = classNode.Range
+
+	methods, err := ExtractMethods(ctx, classInfo, sctx)
+	if err != nil {
+		return nil, err
+	}
+	classInfo.Methods = methods
+
+	fields, err := ExtractFields(ctx, classInfo, sctx)
+	if err != nil {
+		return nil, err
+	}
+	classInfo.Fields = fields
+	return classInfo, nil
+}
+
+// ExtractParameters returns the parameters of a function ordered by the
+// position recorded on its FUNCTION_ARG relations
+func ExtractParameters(ctx context.Context, cg *codegraph.CodeGraph, functionID ast.NodeID) ([]*ParameterInfo, error) {
+	relations, err := cg.GetOutgoingRelations(ctx, functionID, "FUNCTION_ARG")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get parameters of function %d: %w", functionID, err)
+	}
+	if len(relations) == 0 {
+		return make([]*ParameterInfo, 0), nil
+	}
+
+	args, err := cg.ReadFunctionArgs(ctx, functionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read parameters of function %d: %w", functionID, err)
+	}
+	argsByID := make(map[ast.NodeID]*ast.Node, len(args))
+	for _, arg := range args {
+		argsByID[arg.ID] = arg
+	}
+
+	parameters := make([]*ParameterInfo, 0, len(relations))
+	for _, relation := range relations {
+		parameter := &ParameterInfo{
+			NodeID:   relation.ToNodeID,
+			Position: relationPosition(relation.Metadata["position"]),
+		}
+		if arg := argsByID[relation.ToNodeID]; arg != nil {
+			parameter.Name = arg.Name
+			if argType, ok := arg.MetaData["type"].(string); ok {
+				parameter.Type = argType
+			}
+		}
+		parameters = append(parameters, parameter)
+	}
+	sort.SliceStable(parameters, func(i, j int) bool {
+		return parameters[i].Position < parameters[j].Position
+	})
+	return parameters, nil
+}
+
+// metadataStrings converts a string list read back from node metadata, which
+// the database returns as a list of values
+func metadataStrings(value any) []string {
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []any:
+		strs := make([]string, 0, len(v))
+		for _, item := range v {
+			if str, ok := item.(string); ok {
+				strs = append(strs, str)
+			}
+		}
+		return strs
+	default:
+		return nil
+	}
+}
+
+// relationPosition converts a position read back from relation metadata
+func relationPosition(value any) int {
+	switch v := value.(type) {
+	case int64:
+		return int(v)
+	case int32:
+		return int(v)
+	case int:
+		return v
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// readSourceLines reads a file's lines, resolving relative paths against the
+// repository. It returns nil when the file cannot be read.
+func readSourceLines(filePath string, sctx *SignalContext) []string {
+	if filePath == "" {
+		return nil
+	}
+	if !filepath.IsAbs(filePath) && sctx.RepoPath != "" {
+		filePath = filepath.Join(sctx.RepoPath, filePath)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		if sctx.Logger != nil {
+			sctx.Logger.Debug("Method source unavailable", zap.String("path", filePath), zap.Error(err))
+		}
+		return nil
+	}
+	return strings.Split(string(util.NormalizeSource(content)), "\n")
+}
+
+// sourceInRange returns the lines a range covers, clamped to the file
+func sourceInRange(lines []string, r base.Range) string {
+	start, end := r.Start.Line, r.End.Line
+	if start < 0 || start >= len(lines) || end < start {
+		return ""
+	}
+	if end >= len(lines) {
+		end = len(lines) - 1
+	}
+	return strings.Join(lines[start:end+1], "\n")
+}
@@ -0,0 +1,102 @@
+package signals_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model/ast"
+	"bot-go/internal/service/codegraph"
+	"bot-go/internal/signals"
+	"bot-go/internal/signals/complexity"
+	"bot-go/internal/testutil"
+
+	"go.uber.org/zap"
+)
+
+func TestExtractMethodsOrdersParameters(t *testing.T) {
+	repoPath := t.TempDir()
+	source := "class Account:\n    def transfer(self, amount, target):\n        return target.deposit(amount)\n"
+	if err := os.WriteFile(filepath.Join(repoPath, "account.py"), []byte(source), 0o644); err != nil {
+		t.Fatalf("write account.py: %v", err)
+	}
+
+	node := func(id int64, nodeType ast.NodeType, name, rangeStr string, metadata map[string]any) map[string]any {
+		record := map[string]any{"id": id, "nodeType": int64(nodeType), "fileId": int64(1), "name": name, "range": rangeStr}
+		for key, value := range metadata {
+			record["md_"+key] = value
+		}
+		return record
+	}
+
+	db := testutil.NewMockGraphDatabase()
+	db.ReadFunc = func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+		switch {
+		case strings.Contains(query, "RETURN m"):
+			// Lists are read back from the database as []any
+			decorators := map[string]any{"decorators": []any{"audited"}}
+			return []map[string]any{{"m": node(10, ast.NodeTypeFunction, "transfer", "(1,4)-(2,37)", decorators)}}, nil
+		case strings.Contains(query, "properties(r)"):
+			// Relations come back out of declaration order
+			return []map[string]any{
+				{"toId": int64(13), "props": map[string]any{"md_position": int64(2)}},
+				{"toId": int64(11), "props": map[string]any{"md_position": int64(0)}},
+				{"toId": int64(12), "props": map[string]any{"md_position": int64(1)}},
+			}, nil
+		case strings.Contains(query, "RETURN arg"):
+			return []map[string]any{
+				{"arg": node(11, ast.NodeTypeVariable, "self", "(1,17)-(1,21)", nil)},
+				{"arg": node(12, ast.NodeTypeVariable, "amount", "(1,23)-(1,29)", map[string]any{"type": "int"})},
+				{"arg": node(13, ast.NodeTypeVariable, "target", "(1,31)-(1,37)", nil)},
+			}, nil
+		}
+		return nil, nil
+	}
+
+	sctx := &signals.SignalContext{
+		CodeGraph: codegraph.NewCodeGraphWithDatabase(db, &config.Config{}, zap.NewNop()),
+		RepoPath:  repoPath,
+		Logger:    zap.NewNop(),
+	}
+	classInfo := signals.NewClassInfo(1, "Account", "account.py", 1)
+
+	methods, err := signals.ExtractMethods(context.Background(), classInfo, sctx)
+	if err != nil {
+		t.Fatalf("ExtractMethods: %v", err)
+	}
+	if len(methods) != 1 {
+		t.Fatalf("extracted %d methods, want 1", len(methods))
+	}
+	method := methods[0]
+
+	if len(method.Parameters) != 3 {
+		t.Fatalf("extracted %d parameters, want 3", len(method.Parameters))
+	}
+	for i, want := range []string{"self", "amount", "target"} {
+		if got := method.Parameters[i]; got.Name != want || got.Position != i {
+			t.Errorf("parameter %d = %s at position %d, want %s at %d", i, got.Name, got.Position, want, i)
+		}
+	}
+	if got := method.Parameters[1].Type; got != "int" {
+		t.Errorf("amount type = %q, want int", got)
+	}
+	if len(method.Decorators) != 1 || method.Decorators[0] != "audited" {
+		t.Errorf("decorators = %v, want [audited]", method.Decorators)
+	}
+
+	wantSource := "    def transfer(self, amount, target):\n        return target.deposit(amount)"
+	if method.SourceCode != wantSource {
+		t.Errorf("source = %q, want %q", method.SourceCode, wantSource)
+	}
+
+	result, err := complexity.NewNOPSignal().ComputeMethod(context.Background(), method, sctx)
+	if err != nil || result.Error != nil {
+		t.Fatalf("NOP: %v %v", err, result.Error)
+	}
+	if result.Value != 3 {
+		t.Errorf("NOP = %v, want 3", result.Value)
+	}
+}
@@ -26,6 +26,7 @@ func RegisterDefaultSignals(registry *signals.SignalRegistry) {
 	registry.Register(size.NewNOFSignal())
 	registry.Register(size.NewNOPASignal())
 	registry.Register(size.NewNOAMSignal())
+	registry.Register(size.NewEncapsulationSignal())
 
 	// Complexity signals
 	registry.Register(complexity.NewCYCLOSignal())
@@ -34,6 +35,7 @@ func RegisterDefaultSignals(registry *signals.SignalRegistry) {
 	registry.Register(complexity.NewAMCSignal())
 	registry.Register(complexity.NewMAXNESTINGSignal())
 	registry.Register(complexity.NewNOLVSignal())
+	registry.Register(complexity.NewNOPSignal())
 
 	// Cohesion signals
 	registry.Register(cohesion.NewTCCSignal())
@@ -84,9 +86,9 @@ func RegisterChangeSignals(registry *signals.SignalRegistry, gitAnalyzer util.Gi
 //	  enabled: true
 //	  mode: "ondemand"  # or "precompute" (not yet implemented)
 //	  lookback_commits: 1000  # optional, defaults to 1000
-func RegisterAllSignals(registry *signals.SignalRegistry, repoPath string, gitConfig *config.GitAnalysisConfig) error {
+func RegisterAllSignals(registry *signals.SignalRegistry, repo *config.Repository, gitConfig *config.GitAnalysisConfig) error {
 	RegisterDefaultSignals(registry)
-	gitAnalyzer, err := util.NewGitAnalyzer(repoPath, gitConfig)
+	gitAnalyzer, err := util.NewGitAnalyzer(repo, gitConfig)
 	if err != nil {
 		return fmt.Errorf("failed to create git analyzer: %w", err)
 	}
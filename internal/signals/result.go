@@ -1,6 +1,7 @@
 package signals
 
 import (
+	"fmt"
 	"time"
 
 	"bot-go/internal/model/ast"
@@ -14,7 +15,8 @@ type SignalResult struct {
 	Normalized float64        // Value normalized to 0-1 range
 	Metadata   map[string]any // Additional context (e.g., breakdown)
 	ComputedAt time.Time      // When the result was computed
-	Error      error          // Non-nil if computation failed
+	Error      error          // Non-nil if computation failed or was skipped
+	Skipped    bool           // Not computed because a required capability was unavailable
 }
 
 // SignalResultSet holds multiple signal results for an entity
@@ -57,6 +59,18 @@ func NewSignalResultError(name string, err error) SignalResult {
 	}
 }
 
+// NewSignalResultSkipped creates a result for a signal that was not computed
+// because the context lacks the missing capabilities
+func NewSignalResultSkipped(name string, missing []Capability) SignalResult {
+	return SignalResult{
+		SignalName: name,
+		Error:      fmt.Errorf("%w: %v", ErrCapabilityUnavailable, missing),
+		Skipped:    true,
+		Metadata:   map[string]any{"missing_capabilities": missing},
+		ComputedAt: time.Now(),
+	}
+}
+
 // IsValid returns true if computation succeeded
 func (r SignalResult) IsValid() bool {
 	return r.Error == nil
@@ -173,17 +187,28 @@ func (s *SignalResultSet) Size() int {
 	return len(s.Results)
 }
 
-// GetErrorResults returns only results with errors
+// GetErrorResults returns only results with errors, excluding skipped signals
 func (s *SignalResultSet) GetErrorResults() map[string]SignalResult {
 	errors := make(map[string]SignalResult)
 	for name, result := range s.Results {
-		if !result.IsValid() {
+		if !result.IsValid() && !result.Skipped {
 			errors[name] = result
 		}
 	}
 	return errors
 }
 
+// GetSkippedResults returns only results of signals skipped for missing capabilities
+func (s *SignalResultSet) GetSkippedResults() map[string]SignalResult {
+	skipped := make(map[string]SignalResult)
+	for name, result := range s.Results {
+		if result.Skipped {
+			skipped[name] = result
+		}
+	}
+	return skipped
+}
+
 // GetValue returns the value for a signal, or 0 if not found
 func (s *SignalResultSet) GetValue(signalName string) float64 {
 	if result, ok := s.GetResult(signalName); ok && result.IsValid() {
@@ -49,6 +49,10 @@ type Signal interface {
 
 	// Dependencies returns names of signals this signal depends on
 	Dependencies() []string
+
+	// RequiredCapabilities returns the SignalContext inputs this signal needs;
+	// without them it is skipped rather than computed from partial data
+	RequiredCapabilities() []Capability
 }
 
 // ClassSignal computes a signal value for a class
@@ -143,6 +147,7 @@ type MethodInfo struct {
 	// Signature
 	Parameters []*ParameterInfo
 	ReturnType string
+	Decorators []string // Decorator expressions without the "@", e.g. "property"
 
 	// Structure
 	LocalVariables []*VariableInfo
@@ -154,13 +159,16 @@ type MethodInfo struct {
 	Loops        []ast.NodeID
 	Blocks       []ast.NodeID
 
-	// Source code (optional)
-	//SourceCode string
+	// Source code (optional, set by ExtractMethods when the file is readable)
+	SourceCode string
 
 	// Flags
 	IsAccessor    bool
 	IsConstructor bool
 	IsStatic      bool
+
+	// Declared visibility from graph metadata; empty when it must be inferred from the name
+	Visibility Visibility
 }
 
 // FieldInfo contains information about a class field
@@ -300,7 +308,7 @@ func (m *MethodInfo) GetLOC() int {
 
 // GetParameterCount returns number of parameters
 func (m *MethodInfo) GetParameterCount() int {
-	return 0
+	return len(m.Parameters)
 }
 
 // GetLocalVariableCount returns count of local variables (excluding params)
@@ -1,9 +1,19 @@
 package size
 
 import (
+	"bot-go/internal/signals"
 	"bot-go/pkg/lsp/base"
 )
 
+// contextLanguage returns the configured repository language, or "" to infer
+// each class's language from its file path
+func contextLanguage(sctx *signals.SignalContext) string {
+	if sctx == nil {
+		return ""
+	}
+	return sctx.Language
+}
+
 // calculateLOCFromRange calculates lines of code from a Range
 // Returns end line - start line + 1 (inclusive count)
 // Returns 0 if the range is invalid (end before start)
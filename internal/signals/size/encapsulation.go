@@ -0,0 +1,63 @@
+package size
+
+import (
+	"context"
+
+	"bot-go/internal/signals"
+	"bot-go/internal/signals/util"
+)
+
+// EncapsulationSignal computes the ratio of public methods to all methods
+type EncapsulationSignal struct{}
+
+// NewEncapsulationSignal creates a new encapsulation signal
+func NewEncapsulationSignal() *EncapsulationSignal {
+	return &EncapsulationSignal{}
+}
+
+// Metadata returns information about this signal
+func (s *EncapsulationSignal) Metadata() signals.SignalMetadata {
+	return signals.SignalMetadata{
+		Name:        "PMR",
+		FullName:    "Public Method Ratio",
+		Category:    signals.CategorySize,
+		Scope:       signals.ScopeClass,
+		Description: "Ratio of public methods to total methods in a class; a large public surface weakens encapsulation",
+		Unit:        "ratio",
+		LowerBetter: true,
+	}
+}
+
+// Dependencies returns names of signals this signal depends on
+func (s *EncapsulationSignal) Dependencies() []string {
+	return nil
+}
+
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *EncapsulationSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
+// ComputeClass computes PMR for a class
+// Method visibility comes from graph metadata when present and is otherwise
+// inferred from the method name using the repository language
+func (s *EncapsulationSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
+	if classInfo == nil {
+		return signals.NewSignalResultError("PMR", signals.ErrNilInput), nil
+	}
+
+	language := contextLanguage(sctx)
+	publicCount := 0
+	for _, method := range classInfo.Methods {
+		if util.MethodVisibility(language, method) == signals.VisibilityPublic {
+			publicCount++
+		}
+	}
+
+	totalMethods := len(classInfo.Methods)
+	return signals.NewSignalResultWithMetadata("PMR", safeRatio(publicCount, totalMethods), map[string]any{
+		"public_methods":     publicCount,
+		"non_public_methods": totalMethods - publicCount,
+		"total_methods":      totalMethods,
+	}), nil
+}
@@ -0,0 +1,40 @@
+package size
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"bot-go/internal/signals"
+)
+
+func TestEncapsulationSignal(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		methods  []string
+		want     float64
+	}{
+		{"go exported", "go", []string{"Run", "Stop", "validate", "reset"}, 0.5},
+		{"python underscores", "python", []string{"__init__", "run", "_validate", "__reset"}, 0.5},
+		{"no methods", "go", nil, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classInfo := &signals.ClassInfo{Name: "Worker"}
+			for _, name := range tt.methods {
+				classInfo.Methods = append(classInfo.Methods, &signals.MethodInfo{Name: name})
+			}
+			sctx := &signals.SignalContext{Language: tt.language}
+
+			result, err := NewEncapsulationSignal().ComputeClass(context.Background(), classInfo, sctx)
+			if err != nil {
+				t.Fatalf("ComputeClass failed: %v", err)
+			}
+			if math.Abs(result.Value-tt.want) > 1e-9 {
+				t.Errorf("PMR = %.3f, want %.3f", result.Value, tt.want)
+			}
+		})
+	}
+}
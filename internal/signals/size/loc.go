@@ -37,6 +37,11 @@ func (s *LOCSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *LOCSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes LOC for a class using Range from the CodeGraph
 func (s *LOCSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	if classInfo == nil {
@@ -97,6 +102,11 @@ func (s *LOCNAMMSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *LOCNAMMSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes LOCNAMM for a class
 // Total class LOC minus the LOC of all accessor methods
 func (s *LOCNAMMSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
@@ -107,11 +117,12 @@ func (s *LOCNAMMSignal) ComputeClass(ctx context.Context, classInfo *signals.Cla
 	// Calculate total class LOC from Range
 	totalLOC := calculateLOCFromRange(classInfo.Range)
 
-	// Subtract LOC of accessor methods
+	// Subtract LOC of accessor methods, detected by the class's language
+	language := contextLanguage(sctx)
 	accessorLOC := 0
 	accessorCount := 0
 	for _, method := range classInfo.Methods {
-		if s.accessorDetector.IsAccessor(method) {
+		if s.accessorDetector.IsAccessor(language, method) {
 			accessorLOC += calculateLOCFromRange(method.Range)
 			accessorCount++
 		}
@@ -37,6 +37,11 @@ func (s *NOAMSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *NOAMSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes NOAM for a class
 // Counts getter and setter methods using the accessor detector
 func (s *NOAMSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
@@ -44,13 +49,14 @@ func (s *NOAMSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassI
 		return signals.NewSignalResultError("NOAM", signals.ErrNilInput), nil
 	}
 
-	// Count accessor methods
+	// Count accessor methods, detected by the class's language
+	language := contextLanguage(sctx)
 	getterCount := 0
 	setterCount := 0
 	for _, method := range classInfo.Methods {
-		if s.accessorDetector.IsGetter(method) {
+		if s.accessorDetector.IsGetter(language, method) {
 			getterCount++
-		} else if s.accessorDetector.IsSetter(method) {
+		} else if s.accessorDetector.IsSetter(language, method) {
 			setterCount++
 		}
 	}
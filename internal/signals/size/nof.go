@@ -32,6 +32,11 @@ func (s *NOFSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *NOFSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes NOF for a class
 // Counts all fields contained in the class from the CodeGraph
 func (s *NOFSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
@@ -39,7 +44,8 @@ func (s *NOFSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassIn
 		return signals.NewSignalResultError("NOF", signals.ErrNilInput), nil
 	}
 
-	// Count fields from ClassInfo (populated from CodeGraph)
+	// Count fields from ClassInfo, populated by signals.ExtractClass from
+	// the class's declared fields and its methods' receiver attributes
 	fieldCount := len(classInfo.Fields)
 
 	return signals.NewSignalResultWithMetadata("NOF", float64(fieldCount), map[string]any{
@@ -74,6 +80,11 @@ func (s *NOPASignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *NOPASignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes NOPA for a class
 // Counts public fields in the class from the CodeGraph
 func (s *NOPASignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
@@ -33,6 +33,11 @@ func (s *NOMSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *NOMSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes NOM for a class
 // Counts all methods contained in the class from the CodeGraph
 func (s *NOMSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
@@ -40,7 +45,8 @@ func (s *NOMSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassIn
 		return signals.NewSignalResultError("NOM", signals.ErrNilInput), nil
 	}
 
-	// Count methods from ClassInfo (populated from CodeGraph)
+	// Count methods from ClassInfo, populated from the CodeGraph's CONTAINS
+	// relations by signals.ExtractClass
 	methodCount := len(classInfo.Methods)
 
 	return signals.NewSignalResultWithMetadata("NOM", float64(methodCount), map[string]any{
@@ -79,6 +85,11 @@ func (s *NOMNAMMSignal) Dependencies() []string {
 	return nil
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *NOMNAMMSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeClass computes NOMNAMM for a class
 // Total method count minus accessor methods
 func (s *NOMNAMMSignal) ComputeClass(ctx context.Context, classInfo *signals.ClassInfo, sctx *signals.SignalContext) (signals.SignalResult, error) {
@@ -89,10 +100,11 @@ func (s *NOMNAMMSignal) ComputeClass(ctx context.Context, classInfo *signals.Cla
 	// Count all methods
 	totalMethods := len(classInfo.Methods)
 
-	// Count accessor methods
+	// Count accessor methods, detected by the class's language
+	language := contextLanguage(sctx)
 	accessorCount := 0
 	for _, method := range classInfo.Methods {
-		if s.accessorDetector.IsAccessor(method) {
+		if s.accessorDetector.IsAccessor(language, method) {
 			accessorCount++
 		}
 	}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"bot-go/internal/signals"
+	langutil "bot-go/internal/util"
 )
 
 // AccessorType represents the type of accessor method
@@ -105,70 +106,81 @@ func (d *AccessorDetector) RegisterPattern(language string, pattern AccessorPatt
 	}
 }
 
-// IsAccessor checks if a method is a simple accessor/mutator
-func (d *AccessorDetector) IsAccessor(methodInfo *signals.MethodInfo) bool {
+// IsAccessor checks if a method is a simple accessor/mutator under the
+// conventions of language. An empty language is detected from the method's
+// file path; a method whose language is unknown is never an accessor.
+func (d *AccessorDetector) IsAccessor(language string, methodInfo *signals.MethodInfo) bool {
 	if methodInfo == nil {
 		return false
 	}
-	return d.IsGetter(methodInfo) || d.IsSetter(methodInfo)
+	return d.IsGetter(language, methodInfo) || d.IsSetter(language, methodInfo)
 }
 
-// IsGetter checks if a method is a getter
-func (d *AccessorDetector) IsGetter(methodInfo *signals.MethodInfo) bool {
+// IsGetter checks if a method is a getter:
+//   - Go: a parameterless method whose body is a single return of a field,
+//     whatever its name, since Go getters drop the Get prefix
+//   - Python: a method decorated with @property, or a get_/is_/has_ method
+//     with a simple body
+//   - Others: a method named with the language's getter prefixes (getX, isX,
+//     hasX) with a simple body
+func (d *AccessorDetector) IsGetter(language string, methodInfo *signals.MethodInfo) bool {
 	if methodInfo == nil {
 		return false
 	}
 
-	// Check if already marked as accessor
-	if methodInfo.IsAccessor {
-		// Verify it's specifically a getter by name pattern
-		language := d.detectLanguage(methodInfo.FilePath)
-		if d.matchesGetterPattern(methodInfo.Name, language) {
+	language = d.resolveLanguage(language, methodInfo)
+	switch language {
+	case "":
+		return false
+	case "go":
+		return d.isGoGetter(methodInfo)
+	case "python":
+		if hasDecorator(methodInfo, isPythonGetterDecorator) {
 			return true
 		}
 	}
 
-	// Check if it matches getter patterns and has simple body
-	language := d.detectLanguage(methodInfo.FilePath)
-	if d.matchesGetterPattern(methodInfo.Name, language) && d.isSimpleGetter(methodInfo) {
-		return true
-	}
-
-	return false
+	// A method already marked as accessor only needs the name to tell its kind
+	return d.matchesGetterPattern(methodInfo.Name, language) &&
+		(methodInfo.IsAccessor || d.isSimpleGetter(methodInfo))
 }
 
-// IsSetter checks if a method is a setter
-func (d *AccessorDetector) IsSetter(methodInfo *signals.MethodInfo) bool {
+// IsSetter checks if a method is a setter:
+//   - Go: a one-parameter method whose body is a single field assignment, or
+//     a simple SetX method when the body is unavailable
+//   - Python: a method decorated with @<name>.setter, or a simple set_ method
+//   - Others: a method named with the language's setter prefix (setX) with a
+//     simple body
+func (d *AccessorDetector) IsSetter(language string, methodInfo *signals.MethodInfo) bool {
 	if methodInfo == nil {
 		return false
 	}
 
-	// Check if already marked as accessor
-	if methodInfo.IsAccessor {
-		// Verify it's specifically a setter by name pattern
-		language := d.detectLanguage(methodInfo.FilePath)
-		if d.matchesSetterPattern(methodInfo.Name, language) {
+	language = d.resolveLanguage(language, methodInfo)
+	switch language {
+	case "":
+		return false
+	case "go":
+		return d.isGoSetter(methodInfo)
+	case "python":
+		if hasDecorator(methodInfo, isPythonSetterDecorator) {
 			return true
 		}
 	}
 
-	// Check if it matches setter patterns and has simple body
-	language := d.detectLanguage(methodInfo.FilePath)
-	if d.matchesSetterPattern(methodInfo.Name, language) && d.isSimpleSetter(methodInfo) {
-		return true
-	}
-
-	return false
+	return d.matchesSetterPattern(methodInfo.Name, language) &&
+		(methodInfo.IsAccessor || d.isSimpleSetter(methodInfo))
 }
 
-// ClassifyMethod returns the accessor type
-func (d *AccessorDetector) ClassifyMethod(methodInfo *signals.MethodInfo) AccessorType {
+// ClassifyMethod returns the accessor type of a method under the conventions
+// of language
+func (d *AccessorDetector) ClassifyMethod(language string, methodInfo *signals.MethodInfo) AccessorType {
 	if methodInfo == nil {
 		return AccessorTypeNone
 	}
 
-	isGetter := d.IsGetter(methodInfo)
-	isSetter := d.IsSetter(methodInfo)
+	isGetter := d.IsGetter(language, methodInfo)
+	isSetter := d.IsSetter(language, methodInfo)
 
 	if isGetter && isSetter {
 		return AccessorTypeGetterSetter
@@ -183,14 +195,14 @@ func (d *AccessorDetector) ClassifyMethod(methodInfo *signals.MethodInfo) Access
 }
 
 // GetAccessorMethods returns accessor methods from a list
-func (d *AccessorDetector) GetAccessorMethods(methods []*signals.MethodInfo) []*signals.MethodInfo {
+func (d *AccessorDetector) GetAccessorMethods(language string, methods []*signals.MethodInfo) []*signals.MethodInfo {
 	if methods == nil {
 		return nil
 	}
 
 	result := make([]*signals.MethodInfo, 0)
 	for _, m := range methods {
-		if d.IsAccessor(m) {
+		if d.IsAccessor(language, m) {
 			result = append(result, m)
 		}
 	}
@@ -198,14 +210,14 @@ func (d *AccessorDetector) GetAccessorMethods(methods []*signals.MethodInfo) []*
 }
 
 // GetNonAccessorMethods returns non-accessor methods from a list
-func (d *AccessorDetector) GetNonAccessorMethods(methods []*signals.MethodInfo) []*signals.MethodInfo {
+func (d *AccessorDetector) GetNonAccessorMethods(language string, methods []*signals.MethodInfo) []*signals.MethodInfo {
 	if methods == nil {
 		return nil
 	}
 
 	result := make([]*signals.MethodInfo, 0)
 	for _, m := range methods {
-		if !d.IsAccessor(m) {
+		if !d.IsAccessor(language, m) {
 			result = append(result, m)
 		}
 	}
@@ -316,12 +328,9 @@ func (d *AccessorDetector) isSimpleSetter(methodInfo *signals.MethodInfo) bool {
 		return false
 	}
 
-	// A setter typically has exactly one parameter
-	if len(methodInfo.Parameters) != 1 {
-		// Allow 0 params for Go where receiver is implicit
-		if len(methodInfo.Parameters) > 1 {
-			return false
-		}
+	// A setter typically has exactly one parameter besides Python's self
+	if explicitParameterCount(methodInfo) > 1 {
+		return false
 	}
 
 	// Simple setter: few lines, no branching, writes a field
@@ -339,21 +348,118 @@ func (d *AccessorDetector) getMethodLOC(methodInfo *signals.MethodInfo) int {
 	return methodInfo.GetLOC()
 }
 
-// detectLanguage detects the programming language from file path
-func (d *AccessorDetector) detectLanguage(filePath string) string {
-	lower := strings.ToLower(filePath)
-	switch {
-	case strings.HasSuffix(lower, ".go"):
+// resolveLanguage normalizes language, detecting it from the method's file
+// path when empty
+func (d *AccessorDetector) resolveLanguage(language string, methodInfo *signals.MethodInfo) string {
+	switch language = strings.ToLower(language); language {
+	case "":
+		return languageForPath(methodInfo.FilePath)
+	case "golang":
 		return "go"
-	case strings.HasSuffix(lower, ".java"):
-		return "java"
-	case strings.HasSuffix(lower, ".py"):
-		return "python"
-	case strings.HasSuffix(lower, ".js"):
-		return "javascript"
-	case strings.HasSuffix(lower, ".ts"), strings.HasSuffix(lower, ".tsx"):
-		return "typescript"
-	default:
-		return "go" // Default to Go patterns
 	}
+	return language
+}
+
+// goFieldReturn matches a Go body returning a field, e.g. "return c.name"
+var goFieldReturn = regexp.MustCompile(`^return\s+\w+(\.\w+)+$`)
+
+// goFieldAssignment matches a Go body assigning a field, e.g. "c.name = name"
+var goFieldAssignment = regexp.MustCompile(`^\w+(\.\w+)+\s*=\s*\w+$`)
+
+// isGoGetter reports whether a Go method is a field-returning one-liner. When
+// the body is unavailable, a method marked as accessor or reading a single
+// field without branching qualifies.
+func (d *AccessorDetector) isGoGetter(methodInfo *signals.MethodInfo) bool {
+	if len(methodInfo.Parameters) > 0 {
+		return false
+	}
+	if body, ok := singleStatementBody(methodInfo.SourceCode); ok {
+		return goFieldReturn.MatchString(body)
+	}
+	if methodInfo.IsAccessor {
+		return true
+	}
+	return len(methodInfo.FieldAccesses) == 1 &&
+		methodInfo.FieldAccesses[0].AccessType == signals.AccessTypeRead &&
+		len(methodInfo.Conditionals)+len(methodInfo.Loops) == 0
+}
+
+// isGoSetter reports whether a Go method assigns its single parameter to a
+// field in a one-liner. When the body is unavailable it falls back to the
+// SetX naming convention.
+func (d *AccessorDetector) isGoSetter(methodInfo *signals.MethodInfo) bool {
+	if len(methodInfo.Parameters) != 1 {
+		return false
+	}
+	if body, ok := singleStatementBody(methodInfo.SourceCode); ok {
+		return goFieldAssignment.MatchString(body)
+	}
+	return d.matchesSetterPattern(methodInfo.Name, "go") &&
+		(methodInfo.IsAccessor || d.isSimpleSetter(methodInfo))
+}
+
+// singleStatementBody returns the statement of a brace-delimited method body
+// holding a single statement, with whitespace collapsed. It reports false when
+// the source has no body or the body is empty or holds more than one line.
+func singleStatementBody(source string) (string, bool) {
+	source = strings.TrimSpace(source)
+	if !strings.HasSuffix(source, "}") {
+		return "", false
+	}
+	source = strings.TrimSuffix(source, "}")
+	// A one-statement accessor body holds no braces, so it follows the last one
+	open := strings.LastIndex(source, "{")
+	if open < 0 {
+		return "", false
+	}
+	body := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(source[open+1:]), ";"))
+	if body == "" || strings.ContainsAny(body, "\n;") {
+		return "", false
+	}
+	return strings.Join(strings.Fields(body), " "), true
+}
+
+// isPythonGetterDecorator reports whether a decorator makes a Python method a
+// property getter
+func isPythonGetterDecorator(decorator string) bool {
+	switch decorator {
+	case "property", "cached_property", "functools.cached_property":
+		return true
+	}
+	return false
+}
+
+// isPythonSetterDecorator reports whether a decorator makes a Python method a
+// property setter, e.g. @name.setter
+func isPythonSetterDecorator(decorator string) bool {
+	return strings.HasSuffix(decorator, ".setter")
+}
+
+// hasDecorator reports whether any of a method's decorators satisfies match
+func hasDecorator(methodInfo *signals.MethodInfo, match func(string) bool) bool {
+	for _, decorator := range methodInfo.Decorators {
+		if match(decorator) {
+			return true
+		}
+	}
+	return false
+}
+
+// explicitParameterCount returns the number of parameters of a method,
+// excluding the self or cls parameter Python methods declare first
+func explicitParameterCount(methodInfo *signals.MethodInfo) int {
+	count := len(methodInfo.Parameters)
+	if count > 0 {
+		switch methodInfo.Parameters[0].Name {
+		case "self", "cls":
+			count--
+		}
+	}
+	return count
+}
+
+// languageForPath detects the language of a method's file, returning "" when
+// it is unknown
+func languageForPath(filePath string) string {
+	return langutil.DetectFileLanguage(filePath)
 }
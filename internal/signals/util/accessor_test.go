@@ -0,0 +1,86 @@
+package util
+
+import (
+	"testing"
+
+	"bot-go/internal/model/ast"
+	"bot-go/internal/signals"
+)
+
+func TestAccessorDetectorClassifyMethod(t *testing.T) {
+	param := func(names ...string) []*signals.ParameterInfo {
+		params := make([]*signals.ParameterInfo, 0, len(names))
+		for i, name := range names {
+			params = append(params, &signals.ParameterInfo{Name: name, Position: i})
+		}
+		return params
+	}
+	read := []*signals.FieldAccessInfo{{FieldName: "name", AccessType: signals.AccessTypeRead}}
+	write := []*signals.FieldAccessInfo{{FieldName: "name", AccessType: signals.AccessTypeWrite}}
+
+	tests := []struct {
+		name     string
+		language string
+		method   *signals.MethodInfo
+		want     AccessorType
+	}{
+		// Go getters drop the Get prefix: a field-returning one-liner is a getter
+		{"go field getter", "go", &signals.MethodInfo{Name: "Name",
+			SourceCode: "func (u *User) Name() string {\n\treturn u.name\n}"}, AccessorTypeGetter},
+		{"go getter with braces in result", "go", &signals.MethodInfo{Name: "Value",
+			SourceCode: "func (c *Cell) Value() interface{} { return c.value }"}, AccessorTypeGetter},
+		{"go computed value", "go", &signals.MethodInfo{Name: "IsEmpty",
+			SourceCode: "func (s *Stack) IsEmpty() bool {\n\treturn len(s.items) == 0\n}"}, AccessorTypeNone},
+		{"go method call", "go", &signals.MethodInfo{Name: "GetConfig",
+			SourceCode: "func (s *Server) GetConfig() *Config {\n\treturn s.loader.Load()\n}"}, AccessorTypeNone},
+		{"go multi-statement", "go", &signals.MethodInfo{Name: "GetName",
+			SourceCode: "func (u *User) GetName() string {\n\tu.reads++\n\treturn u.name\n}"}, AccessorTypeNone},
+		{"go field setter", "go", &signals.MethodInfo{Name: "SetName", Parameters: param("name"),
+			SourceCode: "func (u *User) SetName(name string) {\n\tu.name = name\n}"}, AccessorTypeSetter},
+		{"go setter without source", "go", &signals.MethodInfo{Name: "SetName", Parameters: param("name"),
+			FieldAccesses: write}, AccessorTypeSetter},
+		{"go getter without source", "go", &signals.MethodInfo{Name: "Name", FieldAccesses: read}, AccessorTypeGetter},
+		{"go interface method", "go", &signals.MethodInfo{Name: "Name", SourceCode: "Name() string"}, AccessorTypeNone},
+
+		{"java getter", "java", &signals.MethodInfo{Name: "getName", FieldAccesses: read}, AccessorTypeGetter},
+		{"java boolean getter", "java", &signals.MethodInfo{Name: "isActive",
+			FieldAccesses: []*signals.FieldAccessInfo{{FieldName: "active", AccessType: signals.AccessTypeRead}}}, AccessorTypeGetter},
+		{"java setter", "java", &signals.MethodInfo{Name: "setName", Parameters: param("name"), FieldAccesses: write}, AccessorTypeSetter},
+		{"java branching getter", "java", &signals.MethodInfo{Name: "getName", FieldAccesses: read,
+			Conditionals: []ast.NodeID{1}}, AccessorTypeNone},
+		{"java Go-style name", "java", &signals.MethodInfo{Name: "name", FieldAccesses: read}, AccessorTypeNone},
+
+		{"python property", "python", &signals.MethodInfo{Name: "name", Parameters: param("self"),
+			Decorators: []string{"property"}}, AccessorTypeGetter},
+		{"python property setter", "python", &signals.MethodInfo{Name: "name", Parameters: param("self", "value"),
+			Decorators: []string{"name.setter"}}, AccessorTypeSetter},
+		{"python get_ method", "python", &signals.MethodInfo{Name: "get_name", Parameters: param("self"),
+			FieldAccesses: read}, AccessorTypeGetter},
+		{"python set_ method", "python", &signals.MethodInfo{Name: "set_name", Parameters: param("self", "value"),
+			FieldAccesses: write}, AccessorTypeSetter},
+		{"python other decorator", "python", &signals.MethodInfo{Name: "name", Parameters: param("self"),
+			Decorators: []string{"staticmethod"}}, AccessorTypeNone},
+
+		// An empty language is detected from the file path
+		{"detected python", "", &signals.MethodInfo{Name: "name", FilePath: "user.py",
+			Decorators: []string{"property"}}, AccessorTypeGetter},
+		{"detected java", "", &signals.MethodInfo{Name: "getName", FilePath: "User.java",
+			FieldAccesses: read}, AccessorTypeGetter},
+		{"detected go", "", &signals.MethodInfo{Name: "Name", FilePath: "user.go",
+			SourceCode: "func (u *User) Name() string {\n\treturn u.name\n}"}, AccessorTypeGetter},
+		// A method whose language is unknown is not an accessor, even when it
+		// looks like a Go getter
+		{"unknown extension", "", &signals.MethodInfo{Name: "Name", FilePath: "user.txt",
+			SourceCode: "func (u *User) Name() string {\n\treturn u.name\n}"}, AccessorTypeNone},
+		{"no file path", "", &signals.MethodInfo{Name: "GetName", FieldAccesses: read}, AccessorTypeNone},
+	}
+
+	detector := NewAccessorDetector()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := detector.ClassifyMethod(tt.language, tt.method); got != tt.want {
+				t.Errorf("ClassifyMethod(%q, %s) = %s, want %s", tt.language, tt.method.Name, got, tt.want)
+			}
+		})
+	}
+}
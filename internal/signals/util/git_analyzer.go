@@ -3,12 +3,12 @@ package util
 import (
 	"context"
 	"fmt"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"bot-go/internal/config"
+	gitutil "bot-go/internal/util"
 )
 
 // GitAnalyzer defines the interface for git history analysis
@@ -46,13 +46,14 @@ type ChangeInfo struct {
 	LinesRemoved int
 }
 
-// NewGitAnalyzer creates a new GitAnalyzer based on configuration
+// NewGitAnalyzer creates a new GitAnalyzer for repo based on configuration
+// History is read from repo.Ref, falling back to cfg.Ref and then HEAD
 // Currently only supports "ondemand" mode; "precompute" mode is not yet implemented
 // Returns an error if:
 //   - cfg is nil (git_analysis config section is missing)
 //   - cfg.Enabled is false
 //   - cfg.Mode is invalid or unsupported
-func NewGitAnalyzer(repoPath string, cfg *config.GitAnalysisConfig) (GitAnalyzer, error) {
+func NewGitAnalyzer(repo *config.Repository, cfg *config.GitAnalysisConfig) (GitAnalyzer, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("git analysis configuration is required: add 'git_analysis' section to app.yaml")
 	}
@@ -61,7 +62,7 @@ func NewGitAnalyzer(repoPath string, cfg *config.GitAnalysisConfig) (GitAnalyzer
 		return nil, fmt.Errorf("git analysis is disabled in configuration: set 'git_analysis.enabled: true' to enable")
 	}
 
-	if repoPath == "" {
+	if repo == nil || repo.Path == "" {
 		return nil, fmt.Errorf("repository path is required for git analysis")
 	}
 
@@ -72,7 +73,13 @@ func NewGitAnalyzer(repoPath string, cfg *config.GitAnalysisConfig) (GitAnalyzer
 
 	switch cfg.Mode {
 	case config.GitAnalysisModeOnDemand:
-		return NewOnDemandGitAnalyzer(repoPath, lookback), nil
+		analyzer := NewOnDemandGitAnalyzer(repo.Path, lookback)
+		ref := repo.Ref
+		if ref == "" {
+			ref = cfg.Ref
+		}
+		analyzer.SetRef(ref)
+		return analyzer, nil
 	case "":
 		return nil, fmt.Errorf("git analysis mode is required: set 'git_analysis.mode' to 'ondemand' or 'precompute'")
 	case config.GitAnalysisModePrecompute:
@@ -86,6 +93,8 @@ func NewGitAnalyzer(repoPath string, cfg *config.GitAnalysisConfig) (GitAnalyzer
 type OnDemandGitAnalyzer struct {
 	repoPath        string
 	lookbackCommits int
+	ref             string            // History is read from this ref ("" means HEAD)
+	runner          gitutil.GitRunner // Runs the git commands
 }
 
 // NewOnDemandGitAnalyzer creates a new on-demand git analyzer
@@ -96,9 +105,20 @@ func NewOnDemandGitAnalyzer(repoPath string, lookbackCommits int) *OnDemandGitAn
 	return &OnDemandGitAnalyzer{
 		repoPath:        repoPath,
 		lookbackCommits: lookbackCommits,
+		runner:          gitutil.ExecGitRunner{},
 	}
 }
 
+// SetRef selects the branch, tag or commit whose history is analyzed
+func (g *OnDemandGitAnalyzer) SetRef(ref string) {
+	g.ref = ref
+}
+
+// SetGitRunner replaces the runner git commands are executed with
+func (g *OnDemandGitAnalyzer) SetGitRunner(runner gitutil.GitRunner) {
+	g.runner = runner
+}
+
 // GetRepoPath returns the repository path
 func (g *OnDemandGitAnalyzer) GetRepoPath() string {
 	return g.repoPath
@@ -204,9 +224,7 @@ func (g *OnDemandGitAnalyzer) getRelativePath(filePath string) (string, error) {
 	}
 
 	// Get git root directory
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	cmd.Dir = g.repoPath
-	output, err := cmd.Output()
+	output, err := g.runner.Run(g.repoPath, "rev-parse", "--show-toplevel")
 	if err != nil {
 		return "", fmt.Errorf("failed to get git root: %w", err)
 	}
@@ -223,13 +241,14 @@ func (g *OnDemandGitAnalyzer) getRelativePath(filePath string) (string, error) {
 
 // getCommitsForFile returns commit hashes that modified the given file
 func (g *OnDemandGitAnalyzer) getCommitsForFile(ctx context.Context, relPath string, limit int) ([]string, error) {
-	cmd := exec.CommandContext(ctx, "git", "log", "--follow",
+	ref := g.ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+	output, err := gitutil.RunGit(ctx, g.runner, g.repoPath, "log", "--follow",
 		fmt.Sprintf("-n%d", limit),
 		"--pretty=format:%H",
-		"--", relPath)
-	cmd.Dir = g.repoPath
-
-	output, err := cmd.Output()
+		ref, "--", relPath)
 	if err != nil {
 		return nil, err
 	}
@@ -244,10 +263,7 @@ func (g *OnDemandGitAnalyzer) getCommitsForFile(ctx context.Context, relPath str
 
 // getFilesInCommit returns all files changed in a given commit
 func (g *OnDemandGitAnalyzer) getFilesInCommit(ctx context.Context, commitHash string) ([]string, error) {
-	cmd := exec.CommandContext(ctx, "git", "diff-tree", "--no-commit-id", "--name-only", "-r", commitHash)
-	cmd.Dir = g.repoPath
-
-	output, err := cmd.Output()
+	output, err := gitutil.RunGit(ctx, g.runner, g.repoPath, "diff-tree", "--no-commit-id", "--name-only", "-r", commitHash)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"testing"
+
+	"bot-go/internal/config"
+)
+
+func TestNewGitAnalyzerPrefersRepositoryRef(t *testing.T) {
+	tests := []struct {
+		name      string
+		repoRef   string
+		configRef string
+		want      string
+	}{
+		{"repository ref", "release/2.0", "origin/main", "release/2.0"},
+		{"falls back to configured ref", "", "origin/main", "origin/main"},
+		{"defaults to HEAD", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &config.Repository{Name: "demo", Path: t.TempDir(), Ref: tt.repoRef}
+			cfg := &config.GitAnalysisConfig{Enabled: true, Mode: config.GitAnalysisModeOnDemand, Ref: tt.configRef}
+			analyzer, err := NewGitAnalyzer(repo, cfg)
+			if err != nil {
+				t.Fatalf("NewGitAnalyzer: %v", err)
+			}
+			if got := analyzer.(*OnDemandGitAnalyzer).ref; got != tt.want {
+				t.Errorf("ref = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	cfg := &config.GitAnalysisConfig{Enabled: true, Mode: config.GitAnalysisModeOnDemand}
+	if _, err := NewGitAnalyzer(&config.Repository{Name: "demo"}, cfg); err == nil {
+		t.Error("NewGitAnalyzer without a repository path succeeded")
+	}
+}
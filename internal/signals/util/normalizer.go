@@ -66,6 +66,7 @@ func DefaultRanges() map[string]NormalizationRange {
 		"WMCNAMM":    {Min: 0, Max: 80},
 		"MAXNESTING": {Min: 0, Max: 10},
 		"NOLV":       {Min: 0, Max: 30},
+		"NOP":        {Min: 0, Max: 10},
 		"AMC":        {Min: 1, Max: 20},
 
 		// Cohesion signals (ratios, already 0-1)
@@ -134,6 +135,7 @@ func DefaultThresholds() map[string]float64 {
 		"WMCNAMM": 22,
 		"NOMNAMM": 18,
 		"TCC":     0.33,  // Lower bound (less than this is bad)
+		"LCOM4":   1,     // Upper bound (several method groups mean unrelated responsibilities)
 		"ATFD":    6,
 
 		// Data Class thresholds
@@ -0,0 +1,61 @@
+package util
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"bot-go/internal/signals"
+)
+
+// InferVisibility infers the visibility of a member from its name using the
+// conventions of the language:
+//   - Go: capitalized names are exported (public), others are package-private
+//   - Python: dunder names are public, "__name" is private, "_name" is protected
+//   - JavaScript/TypeScript: "#name" is private, "_name" is private by convention
+//
+// Languages that declare visibility with modifiers (e.g. Java) default to public
+// when no declared visibility is available.
+func InferVisibility(language, name string) signals.Visibility {
+	if name == "" {
+		return signals.VisibilityPublic
+	}
+
+	switch strings.ToLower(language) {
+	case "go", "golang":
+		first, _ := utf8.DecodeRuneInString(name)
+		if unicode.IsUpper(first) {
+			return signals.VisibilityPublic
+		}
+		return signals.VisibilityPackage
+	case "python":
+		switch {
+		case strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__"):
+			return signals.VisibilityPublic
+		case strings.HasPrefix(name, "__"):
+			return signals.VisibilityPrivate
+		case strings.HasPrefix(name, "_"):
+			return signals.VisibilityProtected
+		}
+		return signals.VisibilityPublic
+	case "javascript", "typescript":
+		if strings.HasPrefix(name, "#") || strings.HasPrefix(name, "_") {
+			return signals.VisibilityPrivate
+		}
+		return signals.VisibilityPublic
+	}
+	return signals.VisibilityPublic
+}
+
+// MethodVisibility returns the declared visibility of a method, inferring it
+// from the name when none is recorded. An empty language is detected from the
+// method's file path.
+func MethodVisibility(language string, methodInfo *signals.MethodInfo) signals.Visibility {
+	if methodInfo.Visibility != "" {
+		return methodInfo.Visibility
+	}
+	if language == "" {
+		language = languageForPath(methodInfo.FilePath)
+	}
+	return InferVisibility(language, methodInfo.Name)
+}
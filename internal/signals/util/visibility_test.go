@@ -0,0 +1,46 @@
+package util
+
+import (
+	"testing"
+
+	"bot-go/internal/signals"
+)
+
+func TestInferVisibility(t *testing.T) {
+	tests := []struct {
+		language string
+		name     string
+		want     signals.Visibility
+	}{
+		{"go", "Process", signals.VisibilityPublic},
+		{"go", "process", signals.VisibilityPackage},
+		{"go", "Ünicode", signals.VisibilityPublic},
+		{"go", "_helper", signals.VisibilityPackage},
+		{"python", "process", signals.VisibilityPublic},
+		{"python", "_process", signals.VisibilityProtected},
+		{"python", "__process", signals.VisibilityPrivate},
+		{"python", "__init__", signals.VisibilityPublic},
+		{"typescript", "#secret", signals.VisibilityPrivate},
+		{"java", "process", signals.VisibilityPublic},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.language+"/"+tt.name, func(t *testing.T) {
+			if got := InferVisibility(tt.language, tt.name); got != tt.want {
+				t.Errorf("InferVisibility(%q, %q) = %q, want %q", tt.language, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMethodVisibility(t *testing.T) {
+	declared := &signals.MethodInfo{Name: "process", Visibility: signals.VisibilityPublic}
+	if got := MethodVisibility("go", declared); got != signals.VisibilityPublic {
+		t.Errorf("declared visibility should win, got %q", got)
+	}
+
+	fromPath := &signals.MethodInfo{Name: "_process", FilePath: "pkg/worker.py"}
+	if got := MethodVisibility("", fromPath); got != signals.VisibilityProtected {
+		t.Errorf("language should be detected from the path, got %q", got)
+	}
+}
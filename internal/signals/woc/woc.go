@@ -32,6 +32,11 @@ func (s *WOCSignal) Dependencies() []string {
 	return []string{"NOM", "NOAM"}
 }
 
+// RequiredCapabilities returns the inputs this signal cannot be computed without
+func (s *WOCSignal) RequiredCapabilities() []signals.Capability {
+	return nil
+}
+
 // ComputeWithDependencies computes WOC using NOM and NOAM
 func (s *WOCSignal) ComputeWithDependencies(ctx context.Context, target interface{}, deps map[string]signals.SignalResult, sctx *signals.SignalContext) (signals.SignalResult, error) {
 	return signals.SignalResult{}, nil
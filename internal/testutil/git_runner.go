@@ -0,0 +1,55 @@
+package testutil
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// GitResult is the canned outcome of a git command run by a FakeGitRunner
+type GitResult struct {
+	Output string
+	Err    error
+}
+
+// GitCall records a single git command run by a FakeGitRunner
+type GitCall struct {
+	Dir  string
+	Args []string
+}
+
+// FakeGitRunner is a GitRunner answering git commands with canned results
+// keyed by their space-joined arguments, e.g. "rev-parse --show-toplevel".
+// Commands without a result fail. Every command is recorded.
+type FakeGitRunner struct {
+	Results map[string]GitResult
+
+	mu    sync.Mutex
+	calls []GitCall
+}
+
+// NewFakeGitRunner creates a fake answering commands from results
+func NewFakeGitRunner(results map[string]GitResult) *FakeGitRunner {
+	return &FakeGitRunner{Results: results}
+}
+
+// Run returns the canned result for args
+func (f *FakeGitRunner) Run(dir string, args ...string) ([]byte, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, GitCall{Dir: dir, Args: append([]string(nil), args...)})
+	f.mu.Unlock()
+
+	key := strings.Join(args, " ")
+	result, ok := f.Results[key]
+	if !ok {
+		return nil, fmt.Errorf("fake git: unexpected command %q", key)
+	}
+	return []byte(result.Output), result.Err
+}
+
+// Calls returns the commands run so far
+func (f *FakeGitRunner) Calls() []GitCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]GitCall(nil), f.calls...)
+}
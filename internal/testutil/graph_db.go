@@ -0,0 +1,142 @@
+// Package testutil provides test doubles shared by package tests.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// GraphQuery records a single query issued against a MockGraphDatabase
+type GraphQuery struct {
+	Query  string
+	Params map[string]any
+}
+
+// GraphQueryFunc produces the response for a query issued against a MockGraphDatabase
+type GraphQueryFunc func(ctx context.Context, query string, params map[string]any) ([]map[string]any, error)
+
+// MockGraphDatabase is a GraphDatabase whose read and write responses are
+// stubbed by the test. Every query is recorded so tests can assert on the
+// generated Cypher and parameters.
+type MockGraphDatabase struct {
+	// ReadFunc and WriteFunc answer ExecuteRead(Single) and ExecuteWrite(Single).
+	// A nil func returns no records and no error.
+	ReadFunc  GraphQueryFunc
+	WriteFunc GraphQueryFunc
+
+	// ConnectivityErr is returned by VerifyConnectivity
+	ConnectivityErr error
+
+	mu     sync.Mutex
+	reads  []GraphQuery
+	writes []GraphQuery
+	closed bool
+}
+
+// NewMockGraphDatabase creates a mock that returns no records for every query
+func NewMockGraphDatabase() *MockGraphDatabase {
+	return &MockGraphDatabase{}
+}
+
+// StubRead makes every read return the given records and error
+func (m *MockGraphDatabase) StubRead(records []map[string]any, err error) {
+	m.ReadFunc = func(context.Context, string, map[string]any) ([]map[string]any, error) {
+		return records, err
+	}
+}
+
+// StubWrite makes every write return the given records and error
+func (m *MockGraphDatabase) StubWrite(records []map[string]any, err error) {
+	m.WriteFunc = func(context.Context, string, map[string]any) ([]map[string]any, error) {
+		return records, err
+	}
+}
+
+// Reads returns the read queries issued so far
+func (m *MockGraphDatabase) Reads() []GraphQuery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]GraphQuery(nil), m.reads...)
+}
+
+// Writes returns the write queries issued so far
+func (m *MockGraphDatabase) Writes() []GraphQuery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]GraphQuery(nil), m.writes...)
+}
+
+// Closed reports whether Close was called
+func (m *MockGraphDatabase) Closed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+// ExecuteRead records the query and returns the stubbed read response
+func (m *MockGraphDatabase) ExecuteRead(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	m.mu.Lock()
+	m.reads = append(m.reads, GraphQuery{Query: query, Params: params})
+	fn := m.ReadFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, nil
+	}
+	return fn(ctx, query, params)
+}
+
+// ExecuteWrite records the query and returns the stubbed write response
+func (m *MockGraphDatabase) ExecuteWrite(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	m.mu.Lock()
+	m.writes = append(m.writes, GraphQuery{Query: query, Params: params})
+	fn := m.WriteFunc
+	m.mu.Unlock()
+
+	if fn == nil {
+		return nil, nil
+	}
+	return fn(ctx, query, params)
+}
+
+// ExecuteReadSingle returns the single stubbed read record, mirroring Neo4jDatabase
+func (m *MockGraphDatabase) ExecuteReadSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	records, err := m.ExecuteRead(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	return single(records)
+}
+
+// ExecuteWriteSingle returns the single stubbed write record, mirroring Neo4jDatabase
+func (m *MockGraphDatabase) ExecuteWriteSingle(ctx context.Context, query string, params map[string]any) (map[string]any, error) {
+	records, err := m.ExecuteWrite(ctx, query, params)
+	if err != nil {
+		return nil, err
+	}
+	return single(records)
+}
+
+// Close marks the mock as closed
+func (m *MockGraphDatabase) Close(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+// VerifyConnectivity returns ConnectivityErr
+func (m *MockGraphDatabase) VerifyConnectivity(ctx context.Context) error {
+	return m.ConnectivityErr
+}
+
+func single(records []map[string]any) (map[string]any, error) {
+	if len(records) == 0 {
+		return nil, fmt.Errorf("no records returned")
+	}
+	if len(records) > 1 {
+		return nil, fmt.Errorf("expected single record, got %d", len(records))
+	}
+	return records[0], nil
+}
@@ -0,0 +1,130 @@
+package testutil
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+var (
+	nodeMergePattern     = regexp.MustCompile(`MERGE \(n:(\w+) \{id: \$id\}\)`)
+	relationMergePattern = regexp.MustCompile(`MERGE \(parent\)-\[r:(\w+)\]->\(child\)`)
+)
+
+// GraphRelation is a relation recorded by a GraphRecorder
+type GraphRelation struct {
+	Label    string
+	ParentID int64
+	ChildID  int64
+}
+
+// GraphRecorder is a MockGraphDatabase that keeps the nodes and relations
+// CodeGraph writes with immediate (non-batched) writes, so tests can index real
+// source code and then assert on, or answer reads from, the resulting graph.
+type GraphRecorder struct {
+	*MockGraphDatabase
+
+	mu        sync.Mutex
+	nodes     map[int64]map[string]any
+	labels    map[int64]string
+	relations map[GraphRelation]bool
+}
+
+// NewGraphRecorder creates a recorder with an empty graph. Reads return no
+// records until the test sets ReadFunc.
+func NewGraphRecorder() *GraphRecorder {
+	r := &GraphRecorder{
+		MockGraphDatabase: NewMockGraphDatabase(),
+		nodes:             make(map[int64]map[string]any),
+		labels:            make(map[int64]string),
+		relations:         make(map[GraphRelation]bool),
+	}
+	r.WriteFunc = r.record
+	return r
+}
+
+func (r *GraphRecorder) record(ctx context.Context, query string, params map[string]any) ([]map[string]any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if match := nodeMergePattern.FindStringSubmatch(query); match != nil {
+		id, _ := params["id"].(int64)
+		node := r.nodes[id]
+		if node == nil {
+			node = make(map[string]any)
+			r.nodes[id] = node
+		}
+		for key, value := range params {
+			node[key] = value
+		}
+		r.labels[id] = match[1]
+		return nil, nil
+	}
+	if match := relationMergePattern.FindStringSubmatch(query); match != nil {
+		parentID, _ := params["parentId"].(int64)
+		childID, _ := params["childId"].(int64)
+		r.relations[GraphRelation{Label: match[1], ParentID: parentID, ChildID: childID}] = true
+	}
+	return nil, nil
+}
+
+// Node returns a copy of the properties of the node with the given ID, or nil
+func (r *GraphRecorder) Node(id int64) map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return copyProperties(r.nodes[id])
+}
+
+// Nodes returns copies of the properties of every node with the given label,
+// ordered by ID
+func (r *GraphRecorder) Nodes(label string) []map[string]any {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var ids []int64
+	for id, nodeLabel := range r.labels {
+		if nodeLabel == label {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	nodes := make([]map[string]any, 0, len(ids))
+	for _, id := range ids {
+		nodes = append(nodes, copyProperties(r.nodes[id]))
+	}
+	return nodes
+}
+
+// Relations returns every recorded relation with the given label, ordered by
+// parent and child ID
+func (r *GraphRecorder) Relations(label string) []GraphRelation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var relations []GraphRelation
+	for relation := range r.relations {
+		if relation.Label == label {
+			relations = append(relations, relation)
+		}
+	}
+	sort.Slice(relations, func(i, j int) bool {
+		if relations[i].ParentID != relations[j].ParentID {
+			return relations[i].ParentID < relations[j].ParentID
+		}
+		return relations[i].ChildID < relations[j].ChildID
+	})
+	return relations
+}
+
+func copyProperties(properties map[string]any) map[string]any {
+	if properties == nil {
+		return nil
+	}
+	copied := make(map[string]any, len(properties))
+	for key, value := range properties {
+		copied[key] = value
+	}
+	return copied
+}
@@ -3,72 +3,82 @@ package util
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
+// hunkHeaderRe matches a unified diff hunk header and captures the first line
+// of the hunk in the new file
+var hunkHeaderRe = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// DefaultGitRef is the ref git helpers compare against when none is given
+const DefaultGitRef = "HEAD"
+
 // GitInfo contains git repository information
 type GitInfo struct {
-	HeadCommitSHA  string
+	Ref            string // Ref the working tree is compared against (a branch, tag or commit)
+	HeadCommitSHA  string // Commit Ref resolves to
 	HeadCommitMsg  string
-	ModifiedFiles  map[string]bool // Set of files modified compared to HEAD (absolute paths)
+	ModifiedFiles  map[string]bool // Set of files modified compared to Ref (absolute paths)
+	UntrackedFiles map[string]bool // Set of untracked, non-ignored files (absolute paths)
 	GitRootPath    string          // Absolute path to git repository root
 	IsGitRepo      bool
 }
 
-// GetGitInfo retrieves git information for a repository path
-func GetGitInfo(repoPath string) (*GitInfo, error) {
+// GetGitInfo retrieves git information for a repository path, comparing the
+// working tree against ref. An empty ref means HEAD.
+func GetGitInfo(repoPath, ref string) (*GitInfo, error) {
+	return GetGitInfoWithRunner(ExecGitRunner{}, repoPath, ref)
+}
+
+// GetGitInfoWithRunner is GetGitInfo running git through runner
+func GetGitInfoWithRunner(runner GitRunner, repoPath, ref string) (*GitInfo, error) {
+	if ref == "" {
+		ref = DefaultGitRef
+	}
 	info := &GitInfo{
-		ModifiedFiles: make(map[string]bool),
+		Ref:            ref,
+		ModifiedFiles:  make(map[string]bool),
+		UntrackedFiles: make(map[string]bool),
 	}
 
 	// Check if this is a git repository
-	cmd := exec.Command("git", "rev-parse", "--git-dir")
-	cmd.Dir = repoPath
-	if err := cmd.Run(); err != nil {
+	if _, err := runner.Run(repoPath, "rev-parse", "--git-dir"); err != nil {
 		info.IsGitRepo = false
 		return info, nil
 	}
 	info.IsGitRepo = true
 
-	var output []byte
-	var err error
-
 	// Get the git root directory (absolute path)
-	cmd = exec.Command("git", "rev-parse", "--show-toplevel")
-	cmd.Dir = repoPath
-	output, err = cmd.Output()
+	output, err := runner.Run(repoPath, "rev-parse", "--show-toplevel")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get git root directory: %w", err)
 	}
 	info.GitRootPath = strings.TrimSpace(string(output))
 
-	// Get HEAD commit SHA
-	cmd = exec.Command("git", "rev-parse", "HEAD")
-	cmd.Dir = repoPath
-	output, err = cmd.Output()
+	// Get the commit SHA the ref points to (tags are peeled to their commit)
+	output, err = runner.Run(repoPath, "rev-parse", "--verify", ref+"^{commit}")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD commit SHA: %w", err)
+		return nil, fmt.Errorf("failed to resolve %s to a commit: %w", ref, err)
 	}
 	info.HeadCommitSHA = strings.TrimSpace(string(output))
 
-	// Get HEAD commit message (first line)
-	cmd = exec.Command("git", "log", "-1", "--pretty=%s")
-	cmd.Dir = repoPath
-	output, err = cmd.Output()
+	// Get the commit message (first line)
+	output, err = runner.Run(repoPath, "log", "-1", "--pretty=%s", info.HeadCommitSHA)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD commit message: %w", err)
+		return nil, fmt.Errorf("failed to get %s commit message: %w", ref, err)
 	}
 	info.HeadCommitMsg = strings.TrimSpace(string(output))
 
-	// Get modified files (compared to HEAD)
+	// Get modified files (compared to the ref)
 	// This includes: modified, added, deleted files in working directory and index
-	cmd = exec.Command("git", "diff", "--name-only", "HEAD")
-	cmd.Dir = repoPath
-	output, err = cmd.Output()
+	output, err = runner.Run(repoPath, "diff", "--name-only", info.HeadCommitSHA, "--")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get modified files: %w", err)
 	}
@@ -82,26 +92,46 @@ func GetGitInfo(repoPath string) (*GitInfo, error) {
 		}
 	}
 
+	// Get untracked files that are not ignored, relative to the git root
+	output, err = runner.Run(repoPath, "ls-files", "--others", "--exclude-standard", "--full-name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get untracked files: %w", err)
+	}
+
+	for _, file := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if file != "" {
+			info.UntrackedFiles[filepath.Join(info.GitRootPath, file)] = true
+		}
+	}
+
 	return info, nil
 }
 
-// GetFileContentFromGit retrieves file content from git HEAD
-// Returns error if file is not tracked by git
+// GetFileContentFromGit retrieves file content at a git ref; an empty ref means HEAD
+// Returns error if file is not tracked by git at that ref
 // gitRootPath should be the git repository root (from GitInfo.GitRootPath)
-func GetFileContentFromGit(gitRootPath, filePath string) ([]byte, error) {
+func GetFileContentFromGit(gitRootPath, ref, filePath string) ([]byte, error) {
+	return GetFileContentFromGitWithRunner(ExecGitRunner{}, gitRootPath, ref, filePath)
+}
+
+// GetFileContentFromGitWithRunner is GetFileContentFromGit running git through runner
+func GetFileContentFromGitWithRunner(runner GitRunner, gitRootPath, ref, filePath string) ([]byte, error) {
+	if ref == "" {
+		ref = DefaultGitRef
+	}
+
 	// Get relative path from git root
 	relPath, err := filepath.Rel(gitRootPath, filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get relative path: %w", err)
 	}
 
-	// Use git show to get file content from HEAD
-	cmd := exec.Command("git", "show", fmt.Sprintf("HEAD:%s", relPath))
-	cmd.Dir = gitRootPath
-	output, err := cmd.Output()
+	// Use git show to get file content at the ref
+	output, err := runner.Run(gitRootPath, "show", fmt.Sprintf("%s:%s", ref, filepath.ToSlash(relPath)))
 	if err != nil {
 		// Check if it's because the file doesn't exist in git
-		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 128 {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 128 {
 			return nil, fmt.Errorf("file not tracked by git: %s", relPath)
 		}
 		return nil, fmt.Errorf("failed to get file content from git: %w", err)
@@ -110,7 +140,59 @@ func GetFileContentFromGit(gitRootPath, filePath string) ([]byte, error) {
 	return output, nil
 }
 
-// IsFileModified checks if a file is modified compared to HEAD
+// DiffHunk is a run of consecutive lines added to a file
+type DiffHunk struct {
+	StartLine  int      // Line of the first added line in the working tree file (1-based)
+	AddedLines []string // Added lines without the leading "+"
+}
+
+// GetAddedHunks returns the lines added to a file compared to a git ref (HEAD
+// if empty), staged or not, grouped into hunks. Hunks that only delete lines
+// are left out.
+// gitRootPath should be the git repository root (from GitInfo.GitRootPath)
+func GetAddedHunks(gitRootPath, ref, filePath string) ([]DiffHunk, error) {
+	if ref == "" {
+		ref = DefaultGitRef
+	}
+	relPath, err := filepath.Rel(gitRootPath, filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get relative path: %w", err)
+	}
+
+	output, err := ExecGitRunner{}.Run(gitRootPath, "diff", "--unified=0", "--no-color", "--no-ext-diff", ref, "--", relPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff %s: %w", relPath, err)
+	}
+
+	return parseAddedHunks(string(output)), nil
+}
+
+// parseAddedHunks extracts the added lines of a zero-context unified diff
+func parseAddedHunks(diff string) []DiffHunk {
+	var hunks []DiffHunk
+	for _, line := range strings.Split(diff, "\n") {
+		if match := hunkHeaderRe.FindStringSubmatch(line); match != nil {
+			start, _ := strconv.Atoi(match[1])
+			hunks = append(hunks, DiffHunk{StartLine: start})
+			continue
+		}
+		// File headers ("+++ b/...") come before the first hunk
+		if len(hunks) > 0 && strings.HasPrefix(line, "+") {
+			last := &hunks[len(hunks)-1]
+			last.AddedLines = append(last.AddedLines, line[1:])
+		}
+	}
+
+	added := hunks[:0]
+	for _, hunk := range hunks {
+		if len(hunk.AddedLines) > 0 {
+			added = append(added, hunk)
+		}
+	}
+	return added
+}
+
+// IsFileModified checks if a file is modified compared to the GitInfo's ref
 func IsFileModified(gitInfo *GitInfo, filePath string) bool {
 	if gitInfo == nil || !gitInfo.IsGitRepo {
 		return false
@@ -118,7 +200,7 @@ func IsFileModified(gitInfo *GitInfo, filePath string) bool {
 	return gitInfo.ModifiedFiles[filePath]
 }
 
-// ReadFileOptimized reads file content, using git HEAD if useHead is true and file is unmodified
+// ReadFileOptimized reads file content, using git (at the GitInfo's ref) if useHead is true and file is unmodified
 // In HEAD mode, untracked files are skipped (returns nil content with error)
 func ReadFileOptimized(repoPath, filePath string, useHead bool, gitInfo *GitInfo) ([]byte, error) {
 	// If not using HEAD mode, read from disk
@@ -126,14 +208,14 @@ func ReadFileOptimized(repoPath, filePath string, useHead bool, gitInfo *GitInfo
 		return os.ReadFile(filePath)
 	}
 
-	// If file is modified compared to HEAD, read from disk
+	// If file is modified compared to the ref, read from disk
 	if IsFileModified(gitInfo, filePath) {
 		return os.ReadFile(filePath)
 	}
 
-	// File is unmodified according to git diff, try to read from git HEAD
+	// File is unmodified according to git diff, try to read from git at the ref
 	// Use git root path (not repoPath which might be a subdirectory)
-	content, err := GetFileContentFromGit(gitInfo.GitRootPath, filePath)
+	content, err := GetFileContentFromGit(gitInfo.GitRootPath, gitInfo.Ref, filePath)
 	if err != nil {
 		// If file is not tracked by git (e.g., in .gitignore or new untracked file),
 		// return error to skip processing
@@ -150,9 +232,7 @@ func ReadFileOptimized(repoPath, filePath string, useHead bool, gitInfo *GitInfo
 // GetLastCommitForFile gets the commit SHA of the last commit that modified a file
 func GetLastCommitForFile(repoPath, filePath string) (string, error) {
 	// Get git root directory (in case repoPath is a subdirectory)
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
-	cmd.Dir = repoPath
-	output, err := cmd.Output()
+	output, err := ExecGitRunner{}.Run(repoPath, "rev-parse", "--show-toplevel")
 	if err != nil {
 		return "", fmt.Errorf("failed to get git root: %w", err)
 	}
@@ -165,9 +245,7 @@ func GetLastCommitForFile(repoPath, filePath string) (string, error) {
 	}
 
 	// Get the last commit SHA for this file
-	cmd = exec.Command("git", "log", "-1", "--pretty=%H", "--", relPath)
-	cmd.Dir = gitRoot
-	output, err = cmd.Output()
+	output, err = ExecGitRunner{}.Run(gitRoot, "log", "-1", "--pretty=%H", "--", relPath)
 	if err != nil {
 		return "", fmt.Errorf("failed to get last commit for file: %w", err)
 	}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"context"
+	"os/exec"
+)
+
+// GitRunner runs git commands. The git helpers take one so they can be driven
+// by canned output in tests instead of a real repository.
+type GitRunner interface {
+	// Run runs git with args in dir and returns its standard output
+	Run(dir string, args ...string) ([]byte, error)
+}
+
+// gitContextRunner is a GitRunner that can also stop a command when a
+// context is cancelled
+type gitContextRunner interface {
+	RunContext(ctx context.Context, dir string, args ...string) ([]byte, error)
+}
+
+// ExecGitRunner runs the git executable on PATH
+type ExecGitRunner struct{}
+
+// Run runs git with args in dir. A command exiting non-zero returns an
+// *exec.ExitError holding its standard error.
+func (ExecGitRunner) Run(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd.Output()
+}
+
+// RunContext is Run, killing git when ctx is cancelled
+func (ExecGitRunner) RunContext(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	return cmd.Output()
+}
+
+// RunGit runs git through runner, cancelling the command with ctx when the
+// runner supports it and otherwise checking ctx before running
+func RunGit(ctx context.Context, runner GitRunner, dir string, args ...string) ([]byte, error) {
+	if r, ok := runner.(gitContextRunner); ok {
+		return r.RunContext(ctx, dir, args...)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return runner.Run(dir, args...)
+}
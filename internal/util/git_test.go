@@ -0,0 +1,209 @@
+package util
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"bot-go/internal/testutil"
+)
+
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+}
+
+func writeRepoFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+// newTwoBranchRepo creates a repository whose main branch (also tagged v1)
+// has the original app.go and whose checked-out feature branch changes it
+func newTwoBranchRepo(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q", "-b", "main")
+	writeRepoFile(t, dir, "app.go", "package app // main\n")
+	writeRepoFile(t, dir, "util.go", "package app\n")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-qm", "initial")
+	runGit(t, dir, "tag", "v1")
+
+	runGit(t, dir, "checkout", "-q", "-b", "feature")
+	writeRepoFile(t, dir, "app.go", "package app // feature\n")
+	runGit(t, dir, "commit", "-qam", "feature change")
+	return dir
+}
+
+func TestGetGitInfoComparesAgainstRef(t *testing.T) {
+	dir := newTwoBranchRepo(t)
+
+	tests := []struct {
+		ref          string
+		wantRef      string
+		wantModified bool
+	}{
+		{"", "HEAD", false},
+		{"feature", "feature", false},
+		{"main", "main", true},
+		{"v1", "v1", true},
+	}
+	for _, tt := range tests {
+		info, err := GetGitInfo(dir, tt.ref)
+		if err != nil {
+			t.Fatalf("GetGitInfo(%q): %v", tt.ref, err)
+		}
+		if info.Ref != tt.wantRef {
+			t.Errorf("GetGitInfo(%q).Ref = %q, want %q", tt.ref, info.Ref, tt.wantRef)
+		}
+		appPath := filepath.Join(info.GitRootPath, "app.go")
+		if got := IsFileModified(info, appPath); got != tt.wantModified {
+			t.Errorf("ref %q: app.go modified = %v, want %v", tt.ref, got, tt.wantModified)
+		}
+		if IsFileModified(info, filepath.Join(info.GitRootPath, "util.go")) {
+			t.Errorf("ref %q: util.go reported modified", tt.ref)
+		}
+	}
+
+	onMain, err := GetGitInfo(dir, "main")
+	if err != nil {
+		t.Fatalf("GetGitInfo(main): %v", err)
+	}
+	head, err := GetGitInfo(dir, "")
+	if err != nil {
+		t.Fatalf("GetGitInfo: %v", err)
+	}
+	if onMain.HeadCommitSHA == head.HeadCommitSHA || onMain.HeadCommitMsg != "initial" {
+		t.Errorf("main resolves to %s %q, HEAD to %s; want the initial commit", onMain.HeadCommitSHA, onMain.HeadCommitMsg, head.HeadCommitSHA)
+	}
+
+	if _, err := GetGitInfo(dir, "no-such-branch"); err == nil {
+		t.Error("GetGitInfo with an unknown ref succeeded, want an error")
+	}
+}
+
+func TestGetFileContentFromGitReadsRef(t *testing.T) {
+	dir := newTwoBranchRepo(t)
+	info, err := GetGitInfo(dir, "")
+	if err != nil {
+		t.Fatalf("GetGitInfo: %v", err)
+	}
+	appPath := filepath.Join(info.GitRootPath, "app.go")
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"", "package app // feature\n"},
+		{"main", "package app // main\n"},
+		{"v1", "package app // main\n"},
+	}
+	for _, tt := range tests {
+		content, err := GetFileContentFromGit(info.GitRootPath, tt.ref, appPath)
+		if err != nil {
+			t.Fatalf("GetFileContentFromGit(%q): %v", tt.ref, err)
+		}
+		if string(content) != tt.want {
+			t.Errorf("GetFileContentFromGit(%q) = %q, want %q", tt.ref, content, tt.want)
+		}
+	}
+
+	// A file tracked only on the feature branch is missing at main
+	writeRepoFile(t, dir, "new.go", "package app\n")
+	runGit(t, dir, "add", "new.go")
+	runGit(t, dir, "commit", "-qm", "add new.go")
+	if _, err := GetFileContentFromGit(info.GitRootPath, "main", filepath.Join(info.GitRootPath, "new.go")); err == nil {
+		t.Error("GetFileContentFromGit(main, new.go) succeeded, want an error")
+	}
+}
+
+func TestGetGitInfoWithRunner(t *testing.T) {
+	const sha = "0123456789abcdef0123456789abcdef01234567"
+	repoResults := func() map[string]testutil.GitResult {
+		return map[string]testutil.GitResult{
+			"rev-parse --git-dir":                              {Output: ".git\n"},
+			"rev-parse --show-toplevel":                        {Output: "/src/repo\n"},
+			"rev-parse --verify main^{commit}":                 {Output: sha + "\n"},
+			"log -1 --pretty=%s " + sha:                        {Output: "Fix parser\n"},
+			"diff --name-only " + sha + " --":                  {Output: "app.go\nlib/util.go\n"},
+			"ls-files --others --exclude-standard --full-name": {Output: "notes.txt\n"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		results func() map[string]testutil.GitResult
+		want    *GitInfo
+		wantErr string
+	}{
+		{
+			name:    "repository",
+			results: repoResults,
+			want: &GitInfo{
+				Ref:           "main",
+				HeadCommitSHA: sha,
+				HeadCommitMsg: "Fix parser",
+				ModifiedFiles: map[string]bool{
+					filepath.Join("/src/repo", "app.go"):      true,
+					filepath.Join("/src/repo", "lib/util.go"): true,
+				},
+				UntrackedFiles: map[string]bool{filepath.Join("/src/repo", "notes.txt"): true},
+				GitRootPath:    "/src/repo",
+				IsGitRepo:      true,
+			},
+		},
+		{
+			name: "not a repository",
+			results: func() map[string]testutil.GitResult {
+				return map[string]testutil.GitResult{
+					"rev-parse --git-dir": {Err: errors.New("fatal: not a git repository")},
+				}
+			},
+			want: &GitInfo{Ref: "main", ModifiedFiles: map[string]bool{}, UntrackedFiles: map[string]bool{}},
+		},
+		{
+			name: "unknown ref",
+			results: func() map[string]testutil.GitResult {
+				results := repoResults()
+				results["rev-parse --verify main^{commit}"] = testutil.GitResult{Err: errors.New("fatal: needed a single revision")}
+				return results
+			},
+			wantErr: "failed to resolve main to a commit",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runner := testutil.NewFakeGitRunner(tt.results())
+			info, err := GetGitInfoWithRunner(runner, "/src/repo/lib", "main")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GetGitInfoWithRunner error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetGitInfoWithRunner: %v", err)
+			}
+			if !reflect.DeepEqual(info, tt.want) {
+				t.Errorf("GetGitInfoWithRunner = %+v, want %+v", info, tt.want)
+			}
+			for _, call := range runner.Calls() {
+				if call.Dir != "/src/repo/lib" {
+					t.Errorf("git %v ran in %s, want the repository path", call.Args, call.Dir)
+				}
+			}
+		})
+	}
+}
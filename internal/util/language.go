@@ -0,0 +1,169 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// languageSniffBytes is how much of a file DetectFileLanguage reads when the
+// extension alone is not enough to decide the language
+const languageSniffBytes = 1024
+
+// extensionLanguages maps unambiguous file extensions to languages
+var extensionLanguages = map[string]string{
+	".go":   "go",
+	".py":   "python",
+	".pyw":  "python",
+	".pyi":  "python",
+	".js":   "javascript",
+	".jsx":  "javascript",
+	".mjs":  "javascript",
+	".cjs":  "javascript",
+	".ts":   "typescript",
+	".tsx":  "typescript",
+	".mts":  "typescript",
+	".cts":  "typescript",
+	".java": "java",
+	".rs":   "rust",
+	".c":    "c",
+	".cpp":  "cpp",
+	".cc":   "cpp",
+	".cxx":  "cpp",
+	".hpp":  "cpp",
+	".hxx":  "cpp",
+	".cs":   "csharp",
+	".rb":   "ruby",
+	".php":  "php",
+	".sh":   "shell",
+	".bash": "shell",
+	".zsh":  "shell",
+}
+
+// shebangInterpreters maps interpreter names found on a #! line to languages
+var shebangInterpreters = map[string]string{
+	"python":  "python",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"deno":    "typescript",
+	"ts-node": "typescript",
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+	"dash":    "shell",
+	"ksh":     "shell",
+	"ruby":    "ruby",
+	"php":     "php",
+}
+
+var (
+	cppMarkerRe    = regexp.MustCompile(`(?m)^\s*(class\s+\w+[^;]*\{|namespace\s+\w*\s*\{|template\s*<|(public|private|protected)\s*:)|std::|#include\s*<(iostream|string|vector|memory|map)>`)
+	goMarkerRe     = regexp.MustCompile(`(?m)^package\s+\w+\s*$`)
+	javaMarkerRe   = regexp.MustCompile(`(?m)^(package\s+[\w.]+;|import\s+[\w.]+(\.\*)?;|public\s+(final\s+)?(class|interface|enum)\s+\w+)`)
+	pythonMarkerRe = regexp.MustCompile(`(?m)^(def\s+\w+\(.*\)\s*(->.*)?:|from\s+[\w.]+\s+import\s+|class\s+\w+(\(.*\))?:)`)
+)
+
+// DetectLanguage returns the language of a file from its path and, when the
+// extension is missing or ambiguous (.h), from its leading bytes. Files with
+// any other extension are never sniffed, so an unknown extension stays
+// unknown. Returns "" if the language is unknown.
+func DetectLanguage(path string, firstBytes []byte) string {
+	ext := strings.ToLower(filepath.Ext(path))
+	if language, ok := extensionLanguages[ext]; ok {
+		return language
+	}
+
+	// .h is shared by C and C++; only C++ headers carry C++-only syntax
+	if ext == ".h" {
+		if cppMarkerRe.Match(firstBytes) {
+			return "cpp"
+		}
+		return "c"
+	}
+
+	if ext != "" {
+		return ""
+	}
+	return sniffLanguage(firstBytes)
+}
+
+// DetectFileLanguage is DetectLanguage for a file on disk. The file is only
+// read when it has no extension or a .h one.
+func DetectFileLanguage(path string) string {
+	if ext := strings.ToLower(filepath.Ext(path)); ext != "" && ext != ".h" {
+		return DetectLanguage(path, nil)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return DetectLanguage(path, nil)
+	}
+	defer file.Close()
+
+	head := make([]byte, languageSniffBytes)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return DetectLanguage(path, nil)
+	}
+	return DetectLanguage(path, head[:n])
+}
+
+// sniffLanguage guesses the language from a shebang line or syntactic markers
+func sniffLanguage(content []byte) string {
+	if len(content) == 0 || bytes.IndexByte(content, 0) >= 0 {
+		return ""
+	}
+
+	if language := shebangLanguage(content); language != "" {
+		return language
+	}
+
+	switch {
+	case bytes.HasPrefix(bytes.TrimSpace(content), []byte("<?php")):
+		return "php"
+	case javaMarkerRe.Match(content):
+		return "java"
+	case goMarkerRe.Match(content):
+		return "go"
+	case pythonMarkerRe.Match(content):
+		return "python"
+	default:
+		return ""
+	}
+}
+
+// shebangLanguage returns the language named by a #! line, handling /usr/bin/env
+func shebangLanguage(content []byte) string {
+	if !bytes.HasPrefix(content, []byte("#!")) {
+		return ""
+	}
+
+	line := string(content[2:])
+	if idx := strings.IndexByte(line, '\n'); idx >= 0 {
+		line = line[:idx]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+
+	interpreter := filepath.Base(fields[0])
+	if interpreter == "env" {
+		interpreter = ""
+		for _, field := range fields[1:] {
+			// Skip env flags (e.g. -S) and variable assignments
+			if strings.HasPrefix(field, "-") || strings.Contains(field, "=") {
+				continue
+			}
+			interpreter = filepath.Base(field)
+			break
+		}
+	}
+
+	// Strip version suffixes such as python3 or python3.11
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	return shebangInterpreters[interpreter]
+}
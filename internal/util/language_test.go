@@ -0,0 +1,76 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		content  string
+		expected string
+	}{
+		// Extension wins when unambiguous
+		{"Go by extension", "/repo/main.go", "", "go"},
+		{"Python by extension", "/repo/app.PY", "", "python"},
+		{"Extension beats content", "/repo/tool.py", "#!/bin/bash\necho hi\n", "python"},
+
+		// Shebang scripts without extension
+		{"Python shebang via env", "/repo/bin/manage", "#!/usr/bin/env python3\nimport sys\n", "python"},
+		{"Python shebang absolute", "/repo/bin/run", "#!/usr/bin/python3.11\nprint('x')\n", "python"},
+		{"Node shebang with env flag", "/repo/bin/cli", "#!/usr/bin/env -S node --no-warnings\n", "javascript"},
+		{"Bash shebang", "/repo/bin/setup", "#!/bin/bash\nset -e\n", "shell"},
+
+		// Ambiguous .h headers
+		{"C++ header with class", "/repo/include/widget.h", "#pragma once\n\nclass Widget {\npublic:\n  void draw();\n};\n", "cpp"},
+		{"C++ header with namespace", "/repo/include/util.h", "namespace util {\nint add(int a, int b);\n}\n", "cpp"},
+		{"C header", "/repo/include/list.h", "#ifndef LIST_H\n#define LIST_H\nstruct list { int len; };\n#endif\n", "c"},
+
+		// Syntactic markers without extension or shebang
+		{"Go markers", "/repo/gen/main", "package main\n\nfunc main() {}\n", "go"},
+		{"Java markers", "/repo/gen/App", "package com.example;\n\npublic class App {}\n", "java"},
+		{"Python markers", "/repo/gen/helpers", "from os import path\n\ndef run(x):\n    pass\n", "python"},
+
+		// Unknown
+		{"Plain text", "/repo/README", "This is a readme.\n", ""},
+		{"Binary content", "/repo/blob", "\x7fELF\x00\x01\x02", ""},
+		{"Unknown extension no content", "/repo/notes.txt", "", ""},
+		{"Unknown extension not sniffed", "/repo/notes.txt", "#!/usr/bin/env python3\nimport sys\n", ""},
+		{"Unknown extension with code markers", "/repo/docs/example.md", "package main\n\nfunc main() {}\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := DetectLanguage(tt.path, []byte(tt.content))
+			if result != tt.expected {
+				t.Errorf("DetectLanguage(%q) = %q, want %q", tt.path, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestDetectFileLanguage(t *testing.T) {
+	dir := t.TempDir()
+
+	script := filepath.Join(dir, "manage")
+	if err := os.WriteFile(script, []byte("#!/usr/bin/env python3\nimport sys\nprint(sys.argv)\n"), 0755); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+	header := filepath.Join(dir, "shape.h")
+	if err := os.WriteFile(header, []byte("#include <vector>\n\nclass Shape {\npublic:\n  virtual double area() const = 0;\n};\n"), 0644); err != nil {
+		t.Fatalf("failed to write header: %v", err)
+	}
+
+	if got := DetectFileLanguage(script); got != "python" {
+		t.Errorf("DetectFileLanguage(script) = %q, want python", got)
+	}
+	if got := DetectFileLanguage(header); got != "cpp" {
+		t.Errorf("DetectFileLanguage(header) = %q, want cpp", got)
+	}
+	if got := DetectFileLanguage(filepath.Join(dir, "missing")); got != "" {
+		t.Errorf("DetectFileLanguage(missing) = %q, want empty", got)
+	}
+}
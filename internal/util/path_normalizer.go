@@ -0,0 +1,63 @@
+package util
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// PathNormalizer converts file paths, file:// URIs and repo-relative paths
+// into one canonical form for a repository so results from the vector, graph
+// and LSP subsystems can be correlated
+type PathNormalizer struct {
+	root     string // Absolute repository root ("" when unknown)
+	absolute bool   // Produce absolute paths instead of repo-relative ones
+}
+
+// NewPathNormalizer creates a normalizer for the repository rooted at root.
+// A relative root is resolved against the working directory.
+func NewPathNormalizer(root string, absolute bool) *PathNormalizer {
+	root = ExtractPathFromURI(root)
+	if root != "" {
+		if absRoot, err := filepath.Abs(root); err == nil {
+			root = absRoot
+		}
+	}
+	return &PathNormalizer{root: root, absolute: absolute}
+}
+
+// Normalize returns the path in the configured form: slash-separated and
+// relative to the repository root, or absolute. Paths outside the repository
+// are returned absolute since they cannot be made relative.
+func (p *PathNormalizer) Normalize(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	path = ExtractPathFromURI(path)
+	if p.root == "" {
+		return filepath.Clean(path)
+	}
+
+	absPath := p.Resolve(path)
+	if p.absolute {
+		return absPath
+	}
+
+	relPath, err := filepath.Rel(p.root, absPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return absPath
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// Resolve returns the absolute filesystem path for a path in any accepted form
+func (p *PathNormalizer) Resolve(path string) string {
+	path = filepath.FromSlash(ExtractPathFromURI(path))
+	if path == "" {
+		return ""
+	}
+	if !filepath.IsAbs(path) && p.root != "" {
+		path = filepath.Join(p.root, path)
+	}
+	return filepath.Clean(path)
+}
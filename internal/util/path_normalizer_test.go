@@ -0,0 +1,47 @@
+package util
+
+import "testing"
+
+func TestPathNormalizer(t *testing.T) {
+	tests := []struct {
+		name     string
+		root     string
+		absolute bool
+		path     string
+		want     string
+	}{
+		{"file uri to relative", "/repo", false, "file:///repo/pkg/service.go", "pkg/service.go"},
+		{"absolute to relative", "/repo", false, "/repo/pkg/service.go", "pkg/service.go"},
+		{"already relative", "/repo", false, "pkg/service.go", "pkg/service.go"},
+		{"relative with dot segments", "/repo", false, "./pkg/../cmd/main.go", "cmd/main.go"},
+		{"file uri root", "file:///repo/", false, "/repo/main.go", "main.go"},
+		{"outside repo stays absolute", "/repo", false, "file:///usr/lib/go/src/fmt/print.go", "/usr/lib/go/src/fmt/print.go"},
+		{"sibling with common prefix", "/repo", false, "/repository/main.go", "/repository/main.go"},
+		{"file uri to absolute", "/repo", true, "file:///repo/pkg/service.go", "/repo/pkg/service.go"},
+		{"relative to absolute", "/repo", true, "pkg/service.go", "/repo/pkg/service.go"},
+		{"no root strips uri", "", false, "file:///repo/pkg/service.go", "/repo/pkg/service.go"},
+		{"empty path", "/repo", false, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewPathNormalizer(tt.root, tt.absolute).Normalize(tt.path)
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathNormalizerResolve(t *testing.T) {
+	normalizer := NewPathNormalizer("/repo", false)
+	for path, want := range map[string]string{
+		"pkg/service.go":              "/repo/pkg/service.go",
+		"/repo/pkg/service.go":        "/repo/pkg/service.go",
+		"file:///repo/pkg/service.go": "/repo/pkg/service.go",
+	} {
+		if got := normalizer.Resolve(path); got != want {
+			t.Errorf("Resolve(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -2,11 +2,48 @@ package util
 
 import (
 	"bot-go/internal/config"
+	"bytes"
+	"errors"
+	"fmt"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 )
 
+// ErrFileTooLarge is returned by ReadFileWithLimit for files over the size limit
+var ErrFileTooLarge = errors.New("file exceeds maximum size")
+
+// ReadFileWithLimit reads a file unless it is larger than maxBytes, which is
+// checked with os.Stat before anything is read. A maxBytes of 0 means no limit.
+func ReadFileWithLimit(filePath string, maxBytes int64) ([]byte, error) {
+	if maxBytes > 0 {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() > maxBytes {
+			return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrFileTooLarge, info.Size(), maxBytes)
+		}
+	}
+	return os.ReadFile(filePath)
+}
+
+// utf8BOM is the byte order mark some Windows editors put at the start of UTF-8 files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// NormalizeSource strips a leading UTF-8 byte order mark and converts CRLF
+// line endings to LF, so files authored on Windows split into the same lines
+// and tokens as their LF equivalents. Source that needs neither is returned
+// as is.
+func NormalizeSource(source []byte) []byte {
+	source = bytes.TrimPrefix(source, utf8BOM)
+	if !bytes.Contains(source, []byte("\r\n")) {
+		return source
+	}
+	return bytes.ReplaceAll(source, []byte("\r\n"), []byte("\n"))
+}
+
 func ToUri(path, rootPath string) (string, error) {
 	u, err := url.Parse(path)
 	if err == nil && u.Scheme != "" {
@@ -2,6 +2,7 @@ package util
 
 import (
 	"bot-go/internal/config"
+	"strings"
 	"testing"
 )
 
@@ -151,3 +152,35 @@ func TestShouldSkipFile_WithLanguageFilter(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeSource(t *testing.T) {
+	lf := "package a\n\nfunc A() {\n}\n"
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"lf unchanged", lf},
+		{"crlf", strings.ReplaceAll(lf, "\n", "\r\n")},
+		{"bom", "\xEF\xBB\xBF" + lf},
+		{"bom and crlf", "\xEF\xBB\xBF" + strings.ReplaceAll(lf, "\n", "\r\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(NormalizeSource([]byte(tt.source)))
+			if got != lf {
+				t.Errorf("NormalizeSource(%q) = %q, want %q", tt.source, got, lf)
+			}
+			// Blank lines are only detected once the stray \r is gone
+			lines := strings.Split(got, "\n")
+			if len(lines) != 5 || strings.TrimSpace(lines[1]) != "" || lines[0] != "package a" {
+				t.Errorf("lines = %q, want the LF file's lines", lines)
+			}
+		})
+	}
+
+	// A lone \r is not a line ending and is kept
+	if got := string(NormalizeSource([]byte("a\rb"))); got != "a\rb" {
+		t.Errorf("NormalizeSource(%q) = %q, want it unchanged", "a\rb", got)
+	}
+}
@@ -17,6 +17,7 @@ type LSPClient interface {
 	Close() error
 
 	DidOpenFile(ctx context.Context, uri string) error
+	DidCloseFile(ctx context.Context, uri string) error
 	GetDocumentSymbols(ctx context.Context, uri string) ([]interface{}, error)
 	GetCallHierarchy(ctx context.Context, uri string, fnName string, position Position, inbound bool) (*CallHierarchyIncomingOrgoingCalls, error)
 	GetHover(ctx context.Context, uri string, position Position) (*Hover, error)
@@ -190,6 +190,10 @@ type DidOpenTextDocumentParams struct {
 	TextDocument TextDocumentItem `json:"textDocument"`
 }
 
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
 type TextDocumentItem struct {
 	URI        string `json:"uri"`
 	LanguageId string `json:"languageId"`
@@ -26,6 +26,8 @@ type BaseClient struct {
 	stderr      io.ReadCloser
 	nextID      int64
 	fileHolders map[string]*base.FileHolder
+	openCounts  map[string]int // uri -> DidOpenFile calls not yet matched by DidCloseFile
+	filesMu     sync.Mutex     // Protects fileHolders and openCounts
 	pendingReqs map[int]chan *base.JSONRPCMessage
 	mu          *sync.Mutex
 	initialized bool
@@ -76,6 +78,7 @@ func NewBaseClient(command string, logger *zap.Logger, args ...string) (*BaseCli
 		mu:          &sync.Mutex{},
 		pendingReqs: make(map[int]chan *base.JSONRPCMessage),
 		fileHolders: make(map[string]*base.FileHolder),
+		openCounts:  make(map[string]int),
 		logger:      logger,
 	}
 
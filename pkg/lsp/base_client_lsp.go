@@ -89,6 +89,9 @@ func (t *BaseClient) Initialize(ctx context.Context) (*base.InitializeResult, er
 	return initResult, nil
 }
 
+// DidOpenFile opens a file in the language server. Opens are reference
+// counted: a file already open is only counted again, and stays open until
+// DidCloseFile has been called as often as DidOpenFile.
 func (t *BaseClient) DidOpenFile(ctx context.Context, uri string) error {
 	t.logger.Info("Opening file in TypeScript language server", zap.String("uri", uri))
 
@@ -97,6 +100,14 @@ func (t *BaseClient) DidOpenFile(ctx context.Context, uri string) error {
 		return fmt.Errorf("client not initialized")
 	}
 
+	t.filesMu.Lock()
+	defer t.filesMu.Unlock()
+	if t.openCounts[uri] > 0 {
+		t.openCounts[uri]++
+		t.logger.Debug("File already open in language server", zap.String("uri", uri), zap.Int("opens", t.openCounts[uri]))
+		return nil
+	}
+
 	// Read file content
 	defUri, _ := util.ToUri(uri, t.client.GetRootPath())
 	filePath := strings.TrimPrefix(defUri, "file://")
@@ -106,9 +117,6 @@ func (t *BaseClient) DidOpenFile(ctx context.Context, uri string) error {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
-	fileHolder := base.NewFileHolder(uri, string(content))
-	t.fileHolders[uri] = fileHolder
-
 	params := base.DidOpenTextDocumentParams{
 		TextDocument: base.TextDocumentItem{
 			URI:        uri,
@@ -123,11 +131,61 @@ func (t *BaseClient) DidOpenFile(ctx context.Context, uri string) error {
 		t.logger.Error("Failed to send didOpen notification", zap.String("uri", uri), zap.Error(err))
 		return fmt.Errorf("failed to send didOpen notification: %w", err)
 	}
+	t.fileHolders[uri] = base.NewFileHolder(uri, string(content))
+	t.openCounts[uri] = 1
 
 	t.logger.Info("File opened successfully in language server", zap.String("uri", uri))
 	return nil
 }
 
+// DidCloseFile releases one DidOpenFile of a file, closing it in the language
+// server once every open has been released. Closing a file that is not open
+// does nothing.
+func (t *BaseClient) DidCloseFile(ctx context.Context, uri string) error {
+	t.logger.Info("Closing file in language server", zap.String("uri", uri))
+
+	if !t.initialized {
+		t.logger.Error("language server client not initialized", zap.String("uri", uri))
+		return fmt.Errorf("client not initialized")
+	}
+
+	t.filesMu.Lock()
+	defer t.filesMu.Unlock()
+	switch t.openCounts[uri] {
+	case 0:
+		t.logger.Debug("File not open in language server", zap.String("uri", uri))
+		return nil
+	case 1:
+	default:
+		t.openCounts[uri]--
+		t.logger.Debug("File still open for other requests", zap.String("uri", uri), zap.Int("opens", t.openCounts[uri]))
+		return nil
+	}
+
+	params := base.DidCloseTextDocumentParams{
+		TextDocument: base.TextDocumentIdentifier{
+			URI: uri,
+		},
+	}
+
+	t.logger.Debug("Sending didClose notification to language server", zap.String("uri", uri))
+	if err := t.SendNotification("textDocument/didClose", params); err != nil {
+		t.logger.Error("Failed to send didClose notification", zap.String("uri", uri), zap.Error(err))
+		return fmt.Errorf("failed to send didClose notification: %w", err)
+	}
+
+	delete(t.fileHolders, uri)
+	delete(t.openCounts, uri)
+	return nil
+}
+
+// openFileHolder returns the holder of a file opened with DidOpenFile, or nil
+func (t *BaseClient) openFileHolder(uri string) *base.FileHolder {
+	t.filesMu.Lock()
+	defer t.filesMu.Unlock()
+	return t.fileHolders[uri]
+}
+
 func (t *BaseClient) GetDocumentSymbols(ctx context.Context, uri string) ([]interface{}, error) {
 	t.logger.Info("Getting document symbols from language server", zap.String("uri", uri))
 
@@ -190,7 +248,7 @@ func (t *BaseClient) GetCallHierarchy(ctx context.Context, uri string, fnName st
 		return nil, fmt.Errorf("client not initialized")
 	}
 
-	fileHolder := t.fileHolders[uri]
+	fileHolder := t.openFileHolder(uri)
 	if fileHolder == nil {
 		t.logger.Error("file not opened in language server", zap.String("uri", uri))
 		return nil, fmt.Errorf("file not opened in language server")
@@ -0,0 +1,90 @@
+package lsp
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+// rootClient answers the root path and language of files for a BaseClient
+type rootClient struct {
+	base.LSPClient
+	root string
+}
+
+func (c *rootClient) GetRootPath() string          { return c.root }
+func (c *rootClient) LanguageID(uri string) string { return "go" }
+
+// bufferCloser records everything written to the language server
+type bufferCloser struct {
+	bytes.Buffer
+}
+
+func (b *bufferCloser) Close() error { return nil }
+
+func TestBaseClientReferenceCountsOpenFiles(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	if err := os.WriteFile(path, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	uri := "file://" + filepath.ToSlash(path)
+
+	stdin := &bufferCloser{}
+	c := &BaseClient{
+		client:      &rootClient{root: root},
+		stdin:       stdin,
+		mu:          &sync.Mutex{},
+		fileHolders: make(map[string]*base.FileHolder),
+		openCounts:  make(map[string]int),
+		initialized: true,
+		logger:      zap.NewNop(),
+	}
+	ctx := context.Background()
+	sent := func(method string) int { return strings.Count(stdin.String(), `"method":"`+method+`"`) }
+
+	for i := 0; i < 2; i++ {
+		if err := c.DidOpenFile(ctx, uri); err != nil {
+			t.Fatalf("DidOpenFile: %v", err)
+		}
+	}
+	if got := sent("textDocument/didOpen"); got != 1 {
+		t.Errorf("didOpen sent %d times, want 1", got)
+	}
+
+	if err := c.DidCloseFile(ctx, uri); err != nil {
+		t.Fatalf("DidCloseFile: %v", err)
+	}
+	if c.openFileHolder(uri) == nil {
+		t.Error("file closed while another request still has it open")
+	}
+	if got := sent("textDocument/didClose"); got != 0 {
+		t.Errorf("didClose sent %d times before the last close, want 0", got)
+	}
+
+	if err := c.DidCloseFile(ctx, uri); err != nil {
+		t.Fatalf("DidCloseFile: %v", err)
+	}
+	if c.openFileHolder(uri) != nil {
+		t.Error("file still open after every open was closed")
+	}
+	if got := sent("textDocument/didClose"); got != 1 {
+		t.Errorf("didClose sent %d times, want 1", got)
+	}
+
+	// An unmatched close is ignored
+	if err := c.DidCloseFile(ctx, uri); err != nil {
+		t.Fatalf("DidCloseFile: %v", err)
+	}
+	if got := sent("textDocument/didClose"); got != 1 {
+		t.Errorf("didClose sent %d times after an unmatched close, want 1", got)
+	}
+}
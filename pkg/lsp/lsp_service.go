@@ -7,6 +7,7 @@ import (
 	"bot-go/pkg/lsp/base"
 	"context"
 	"fmt"
+	"strings"
 
 	"go.uber.org/zap"
 )
@@ -68,9 +69,9 @@ func (rs *LspService) getLanguageServerClient(repoName string) (base.LSPClient,
 	return client, nil
 }
 
+// getSymbolsOfType returns the symbols of a kind in a file the caller has
+// opened with DidOpenFile
 func (rs *LspService) getSymbolsOfType(ctx context.Context, lspClient base.LSPClient, fileUri string, symType int) ([]interface{}, error) {
-	lspClient.DidOpenFile(ctx, fileUri)
-
 	symbols, err := lspClient.GetDocumentSymbols(ctx, fileUri)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get document symbols: %w", err)
@@ -237,6 +238,8 @@ func (rs *LspService) populateCallGraph(
 
 	fnCache := make(map[string]model.FunctionDefinition)
 
+	// The file stays open for the call hierarchy requests that follow
+	lspClient.DidOpenFile(ctx, uri)
 	fns, err := rs.getFunctionDefinitions(ctx, lspClient, uri, functionName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get functions in file: %w", err)
@@ -292,52 +295,152 @@ func (rs *LspService) GetFunctionDependencies(ctx context.Context, repoName, rel
 	return callGraph, nil
 }
 
-func (rs *LspService) GetFunctionHovers(ctx context.Context, repoName string, functions []model.FunctionDefinition) ([]string, error) {
+// GetFunctionDetails describes a function from its document symbol and its
+// hover: the code block the hover starts with is taken as the signature and
+// the text after it as the documentation.
+func (rs *LspService) GetFunctionDetails(ctx context.Context, repoName, relativePath, functionName string) (*model.FunctionDetails, error) {
+	lspClient, err := rs.getLanguageServerClient(repoName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get language server client: %w", err)
+	}
+
+	uri, _ := util.ToUri(relativePath, lspClient.GetRootPath())
+	// Opens are reference counted, so closing releases only this request's
+	// open and leaves the file open for concurrent requests
+	if err := lspClient.DidOpenFile(ctx, uri); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", uri, err)
+	}
+	defer func() {
+		if err := lspClient.DidCloseFile(ctx, uri); err != nil {
+			rs.logger.Warn("Failed to close file after hover",
+				zap.String("uri", uri),
+				zap.Error(err))
+		}
+	}()
+
+	functions, err := rs.getFunctionDefinitions(ctx, lspClient, uri, functionName)
+	if err != nil {
+		return nil, err
+	}
+	fn := functions[0]
+
+	// Details are still returned without a hover
+	hover, err := rs.getFunctionHover(ctx, lspClient, fn)
+	if err != nil {
+		rs.logger.Warn("Failed to get hover information",
+			zap.String("function", fn.Name),
+			zap.String("uri", uri),
+			zap.Error(err))
+	}
+
+	signature, documentation := splitHover(hover)
+	return &model.FunctionDetails{
+		Name:          fn.Name,
+		Signature:     signature,
+		Documentation: documentation,
+		Location:      fn.Location,
+	}, nil
+}
+
+// splitHover splits hover text into the fenced code block it starts with,
+// usually the declaration, and the text after it. Text not starting with a
+// code block is all documentation.
+func splitHover(hover string) (signature, documentation string) {
+	text := strings.TrimSpace(hover)
+	if !strings.HasPrefix(text, "```") {
+		return "", text
+	}
+	// The opening fence may carry a language tag
+	newline := strings.IndexByte(text, '\n')
+	if newline < 0 {
+		return "", text
+	}
+	block := text[newline+1:]
+	end := strings.Index(block, "```")
+	if end < 0 {
+		return "", text
+	}
+	return strings.TrimSpace(block[:end]), strings.TrimSpace(block[end+3:])
+}
+
+// HoverResult is the hover text of one function, or the reason it could not
+// be read
+type HoverResult struct {
+	Text string // Empty when the function has no hover
+	Err  error
+}
+
+// GetFunctionHovers returns the hover of each function, in input order.
+// Functions are grouped by file so each file is opened once, all hovers in it
+// are requested, and it is closed again. A file that cannot be opened or a
+// failed hover request only fails the functions affected; the returned error
+// is for when no hover can be requested at all.
+func (rs *LspService) GetFunctionHovers(ctx context.Context, repoName string, functions []model.FunctionDefinition) ([]HoverResult, error) {
 	lspClient, err := rs.getLanguageServerClient(repoName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get language server client: %w", err)
 	}
 
-	hovers := make([]string, len(functions))
+	hovers := make([]HoverResult, len(functions))
 
+	// Indexes of the functions in each file, files in order of first appearance
+	var uris []string
+	byURI := make(map[string][]int)
 	for i, fn := range functions {
+		if _, seen := byURI[fn.Location.URI]; !seen {
+			uris = append(uris, fn.Location.URI)
+		}
+		byURI[fn.Location.URI] = append(byURI[fn.Location.URI], i)
+	}
+
+	for _, uri := range uris {
 		// Ensure the file is open in the LSP client
-		err := lspClient.DidOpenFile(ctx, fn.Location.URI)
+		err := lspClient.DidOpenFile(ctx, uri)
 		if err != nil {
 			rs.logger.Warn("Failed to open file for hover",
-				zap.String("uri", fn.Location.URI),
-				zap.String("function", fn.Name),
+				zap.String("uri", uri),
+				zap.Int("functions", len(byURI[uri])),
 				zap.Error(err))
-			hovers[i] = ""
+			for _, i := range byURI[uri] {
+				hovers[i].Err = fmt.Errorf("failed to open %s: %w", uri, err)
+			}
 			continue
 		}
 
-		// Get hover information at the function's start position
-		hoverInfo, err := lspClient.GetHover(ctx, fn.Location.URI, fn.Location.Range.Start)
-		if err != nil {
-			rs.logger.Warn("Failed to get hover information",
-				zap.String("function", fn.Name),
-				zap.String("uri", fn.Location.URI),
-				zap.Error(err))
-			hovers[i] = ""
-			continue
+		for _, i := range byURI[uri] {
+			hovers[i].Text, hovers[i].Err = rs.getFunctionHover(ctx, lspClient, functions[i])
 		}
 
-		if hoverInfo == nil {
-			hovers[i] = ""
-			continue
+		if err := lspClient.DidCloseFile(ctx, uri); err != nil {
+			rs.logger.Warn("Failed to close file after hover",
+				zap.String("uri", uri),
+				zap.Error(err))
 		}
+	}
 
-		// Convert hover contents to string
-		hoverString := rs.extractHoverContent(hoverInfo.Contents)
-		hovers[i] = hoverString
+	return hovers, nil
+}
 
-		rs.logger.Debug("Retrieved hover for function",
-			zap.String("function", fn.Name),
-			zap.String("hover", hoverString))
+// getFunctionHover returns the hover text at a function's start position in
+// its already opened file, or "" if there is none
+func (rs *LspService) getFunctionHover(ctx context.Context, lspClient base.LSPClient, fn model.FunctionDefinition) (string, error) {
+	hoverInfo, err := lspClient.GetHover(ctx, fn.Location.URI, fn.Location.Range.Start)
+	if err != nil {
+		return "", fmt.Errorf("failed to get hover of %s: %w", fn.Name, err)
 	}
 
-	return hovers, nil
+	if hoverInfo == nil {
+		return "", nil
+	}
+
+	// Convert hover contents to string
+	hoverString := rs.extractHoverContent(hoverInfo.Contents)
+
+	rs.logger.Debug("Retrieved hover for function",
+		zap.String("function", fn.Name),
+		zap.String("hover", hoverString))
+
+	return hoverString, nil
 }
 
 func (rs *LspService) extractHoverContent(contents interface{}) string {
@@ -493,6 +596,8 @@ func (rs *LspService) populateCallerGraph(
 
 	fnCache := make(map[string]model.FunctionDefinition)
 
+	// The file stays open for the call hierarchy requests that follow
+	lspClient.DidOpenFile(ctx, uri)
 	fns, err := rs.getFunctionDefinitions(ctx, lspClient, uri, functionName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get functions in file: %w", err)
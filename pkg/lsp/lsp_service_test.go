@@ -0,0 +1,170 @@
+package lsp
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"bot-go/internal/config"
+	"bot-go/internal/model"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+// hoverClient records file opens and closes and answers hovers with the
+// requested position, failing the hover of failLine when it is set
+type hoverClient struct {
+	base.LSPClient
+	opened   map[string]int
+	closed   map[string]int
+	open     map[string]bool
+	failLine int
+}
+
+func (c *hoverClient) DidOpenFile(ctx context.Context, uri string) error {
+	c.opened[uri]++
+	c.open[uri] = true
+	return nil
+}
+
+func (c *hoverClient) DidCloseFile(ctx context.Context, uri string) error {
+	c.closed[uri]++
+	delete(c.open, uri)
+	return nil
+}
+
+func (c *hoverClient) GetHover(ctx context.Context, uri string, position base.Position) (*base.Hover, error) {
+	if !c.open[uri] {
+		return nil, fmt.Errorf("file not opened: %s", uri)
+	}
+	if c.failLine > 0 && position.Line == c.failLine {
+		return nil, fmt.Errorf("request timed out")
+	}
+	return &base.Hover{Contents: fmt.Sprintf("%s:%d", uri, position.Line)}, nil
+}
+
+func TestGetFunctionHoversOpensEachFileOnce(t *testing.T) {
+	client := &hoverClient{opened: map[string]int{}, closed: map[string]int{}, open: map[string]bool{}}
+	rs := NewLspService(&config.Config{}, zap.NewNop())
+	rs.lspClients.Set("repo", client)
+
+	function := func(uri string, line int) model.FunctionDefinition {
+		return model.FunctionDefinition{
+			Name:     fmt.Sprintf("fn%d", line),
+			Location: base.Location{URI: uri, Range: base.Range{Start: base.Position{Line: line}}},
+		}
+	}
+	functions := []model.FunctionDefinition{
+		function("a.go", 1),
+		function("b.go", 2),
+		function("a.go", 3),
+		function("a.go", 4),
+		function("b.go", 5),
+	}
+
+	hovers, err := rs.GetFunctionHovers(context.Background(), "repo", functions)
+	if err != nil {
+		t.Fatalf("GetFunctionHovers failed: %v", err)
+	}
+
+	want := []HoverResult{{Text: "a.go:1"}, {Text: "b.go:2"}, {Text: "a.go:3"}, {Text: "a.go:4"}, {Text: "b.go:5"}}
+	if !reflect.DeepEqual(hovers, want) {
+		t.Errorf("hovers = %v, want %v", hovers, want)
+	}
+	for _, uri := range []string{"a.go", "b.go"} {
+		if client.opened[uri] != 1 || client.closed[uri] != 1 {
+			t.Errorf("%s opened %d and closed %d times, want once each", uri, client.opened[uri], client.closed[uri])
+		}
+	}
+}
+
+func TestGetFunctionHoversKeepsResolvedHovers(t *testing.T) {
+	client := &hoverClient{opened: map[string]int{}, closed: map[string]int{}, open: map[string]bool{}, failLine: 2}
+	rs := NewLspService(&config.Config{}, zap.NewNop())
+	rs.lspClients.Set("repo", client)
+
+	functions := []model.FunctionDefinition{
+		{Name: "first", Location: base.Location{URI: "a.go", Range: base.Range{Start: base.Position{Line: 1}}}},
+		{Name: "broken", Location: base.Location{URI: "a.go", Range: base.Range{Start: base.Position{Line: 2}}}},
+		{Name: "last", Location: base.Location{URI: "a.go", Range: base.Range{Start: base.Position{Line: 3}}}},
+	}
+	hovers, err := rs.GetFunctionHovers(context.Background(), "repo", functions)
+	if err != nil {
+		t.Fatalf("GetFunctionHovers failed: %v", err)
+	}
+
+	if hovers[0].Text != "a.go:1" || hovers[0].Err != nil || hovers[2].Text != "a.go:3" || hovers[2].Err != nil {
+		t.Errorf("resolved hovers = %+v, %+v; want their text", hovers[0], hovers[2])
+	}
+	if hovers[1].Err == nil || hovers[1].Text != "" {
+		t.Errorf("failed hover = %+v, want an error", hovers[1])
+	}
+}
+
+// symbolClient is a hoverClient that lists a function and a method in every
+// open file
+type symbolClient struct {
+	*hoverClient
+}
+
+func (c *symbolClient) GetRootPath() string { return "/repo" }
+
+func (c *symbolClient) MatchSymbolByName(name, nameInFile string) bool { return name == nameInFile }
+
+func (c *symbolClient) GetDocumentSymbols(ctx context.Context, uri string) ([]interface{}, error) {
+	if !c.open[uri] {
+		return nil, fmt.Errorf("file not opened: %s", uri)
+	}
+	return []interface{}{
+		&base.DocumentSymbol{Name: "Run", Kind: base.SymbolKindFunction},
+		&base.DocumentSymbol{Name: "Stop", Kind: base.SymbolKindMethod},
+	}, nil
+}
+
+func TestGetFunctionDetailsClosesOnlyItsOpen(t *testing.T) {
+	client := &symbolClient{&hoverClient{opened: map[string]int{}, closed: map[string]int{}, open: map[string]bool{}}}
+	rs := NewLspService(&config.Config{}, zap.NewNop())
+	rs.lspClients.Set("repo", client)
+
+	details, err := rs.GetFunctionDetails(context.Background(), "repo", "main.go", "Run")
+	if err != nil {
+		t.Fatalf("GetFunctionDetails failed: %v", err)
+	}
+	if details.Name != "Run" {
+		t.Errorf("details.Name = %q, want Run", details.Name)
+	}
+	uri := "file:///repo/main.go"
+	if client.opened[uri] != 1 || client.closed[uri] != 1 {
+		t.Errorf("%s opened %d and closed %d times, want once each", uri, client.opened[uri], client.closed[uri])
+	}
+
+	if _, err := rs.GetFunctionDetails(context.Background(), "repo", "main.go", "Missing"); err == nil {
+		t.Error("GetFunctionDetails of a missing function succeeded")
+	}
+	if client.opened[uri] != client.closed[uri] {
+		t.Errorf("%s opened %d and closed %d times after a failed lookup", uri, client.opened[uri], client.closed[uri])
+	}
+}
+
+func TestSplitHover(t *testing.T) {
+	tests := []struct {
+		hover         string
+		signature     string
+		documentation string
+	}{
+		{"```go\nfunc Run(ctx context.Context) error\n```\n\nRun starts the server.", "func Run(ctx context.Context) error", "Run starts the server."},
+		{"```python\ndef run(self) -> bool\n```", "def run(self) -> bool", ""},
+		{"Run starts the server.", "", "Run starts the server."},
+		{"```go\nunterminated", "", "```go\nunterminated"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		signature, documentation := splitHover(tt.hover)
+		if signature != tt.signature || documentation != tt.documentation {
+			t.Errorf("splitHover(%q) = %q, %q; want %q, %q", tt.hover, signature, documentation, tt.signature, tt.documentation)
+		}
+	}
+}
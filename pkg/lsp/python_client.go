@@ -65,7 +65,7 @@ func (t *PythonLanguageServerClient) GetCallHierarchy(ctx context.Context, uri s
 		return nil, fmt.Errorf("client not initialized")
 	}
 
-	fileHolder := t.fileHolders[uri]
+	fileHolder := t.openFileHolder(uri)
 	if fileHolder == nil {
 		t.logger.Error("file not opened in Python language server", zap.String("uri", uri))
 		return nil, fmt.Errorf("file not opened in language server")
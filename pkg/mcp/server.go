@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 
 	"bot-go/internal/config"
 	"bot-go/internal/model"
 	"bot-go/internal/service"
+	"bot-go/internal/service/vector"
+	"bot-go/internal/util"
+	"bot-go/pkg/lsp"
 
 	"github.com/gin-gonic/gin"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -25,11 +29,15 @@ type CodeGraphServer struct {
 }
 
 type CallGraphParams struct {
-	RepoName     string `json:"repo_name" jsonschema:"the name of the repository to analyze"`
-	FunctionName string `json:"function_name,omitempty" jsonschema:"specific function to analyze"`
-	FilePath     string `json:"file_path,omitempty" jsonschema:"specific file path containing the function"`
+	RepoName      string `json:"repo_name" jsonschema:"the name of the repository to analyze"`
+	FunctionName  string `json:"function_name,omitempty" jsonschema:"specific function to analyze"`
+	FilePath      string `json:"file_path,omitempty" jsonschema:"specific file path containing the function"`
+	IncludeSource bool   `json:"include_source,omitempty" jsonschema:"include a snippet of each function's source code"`
 }
 
+// maxSourceSnippetLines bounds the source included for each graph node
+const maxSourceSnippetLines = 40
+
 func NewCodeGraphServer(repoService *service.RepoService, cfg *config.Config, logger *zap.Logger) *CodeGraphServer {
 	server := &CodeGraphServer{
 		repoService: repoService,
@@ -84,7 +92,7 @@ func (s *CodeGraphServer) handleCallGraph(ctx context.Context, req *mcp.CallTool
 	}
 
 	//result := fmt.Sprintf("Call graph analysis for repository '%s':\n%v", args.RepoName, callGraph)
-	result := s.formatCallGraph(ctx, args.RepoName, callGraph)
+	result := s.formatCallGraph(ctx, args.RepoName, callGraph, args.IncludeSource)
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{&mcp.TextContent{Text: result}},
 	}, nil, nil
@@ -120,7 +128,7 @@ func (s *CodeGraphServer) handleCallerGraph(ctx context.Context, req *mcp.CallTo
 		}, nil, nil
 	}
 
-	result := s.formatCallerGraph(ctx, args.RepoName, callerGraph)
+	result := s.formatCallerGraph(ctx, args.RepoName, callerGraph, args.IncludeSource)
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{&mcp.TextContent{Text: result}},
 	}, nil, nil
@@ -135,7 +143,109 @@ func (s *CodeGraphServer) generateCallerGraph(ctx context.Context, repo *config.
 	return callerGraph, nil
 }
 
-func (s *CodeGraphServer) formatCallGraph(ctx context.Context, repoName string, cg *model.CallGraph) string {
+// pathNormalizer returns the normalizer for file paths reported for a repository
+func (s *CodeGraphServer) pathNormalizer(repoName string) *util.PathNormalizer {
+	repoRoot := ""
+	if repo, err := s.config.GetRepository(repoName); err == nil {
+		repoRoot = repo.Path
+	}
+	return util.NewPathNormalizer(repoRoot, s.config.App.AbsolutePaths)
+}
+
+// sourceReader reads the source of graph nodes, reading each file once per
+// request and skipping files larger than the configured maximum
+type sourceReader struct {
+	paths *util.PathNormalizer
+	cache *vector.FileLineCache
+}
+
+func newSourceReader(paths *util.PathNormalizer, maxFileSize int64) *sourceReader {
+	return &sourceReader{paths: paths, cache: vector.NewFileLineCacheWithLimit(maxFileSize)}
+}
+
+// snippet returns the lines of the function's range, cut to
+// maxSourceSnippetLines. It returns nil when the file cannot be read or the
+// range lies outside it, so such nodes are shown without source.
+func (r *sourceReader) snippet(fn *model.FunctionDefinition) []string {
+	path := r.paths.Resolve(fn.Location.URI)
+	if path == "" {
+		return nil
+	}
+	lines, err := r.cache.Lines(path)
+	if err != nil {
+		return nil
+	}
+
+	start, end := fn.Location.Range.Start.Line, fn.Location.Range.End.Line
+	lastLine := len(lines) - 1
+	if lastLine > 0 && lines[lastLine] == "" {
+		lastLine-- // The file's trailing newline
+	}
+	if start < 0 || start > lastLine || end < start {
+		return nil
+	}
+	end = min(end, lastLine, start+maxSourceSnippetLines-1)
+
+	snippet := lines[start : end+1]
+	if end < fn.Location.Range.End.Line && end < lastLine {
+		// Clone so that appending leaves the cached lines intact
+		snippet = append(slices.Clone(snippet), "...")
+	}
+	return snippet
+}
+
+// writeSource writes the node's source below its tag when sources is set
+func writeSource(sources *sourceReader, node *model.FunctionDefinition, indent string, result *strings.Builder) {
+	if sources == nil {
+		return
+	}
+	snippet := sources.snippet(node)
+	if len(snippet) == 0 {
+		return
+	}
+	result.WriteString(fmt.Sprintf("%s  Source:\n", indent))
+	for _, line := range snippet {
+		result.WriteString(fmt.Sprintf("%s    %s\n", indent, line))
+	}
+}
+
+// functionHovers returns the hover text of each function by key. Functions
+// whose hover could not be read are left out, so the graph still describes
+// the others.
+func (s *CodeGraphServer) functionHovers(ctx context.Context, repoName string, functions []model.FunctionDefinition) map[string]string {
+	hovers, err := s.repoService.GetFunctionHovers(ctx, repoName, functions)
+	if err != nil {
+		s.logger.Warn("Failed to get hover information for functions", zap.Error(err))
+		return map[string]string{}
+	}
+	return s.hoverMap(functions, hovers)
+}
+
+// hoverMap maps the key of each function whose hover resolved to its text
+func (s *CodeGraphServer) hoverMap(functions []model.FunctionDefinition, hovers []lsp.HoverResult) map[string]string {
+	hoverMap := make(map[string]string, len(functions))
+	failed := 0
+	var firstErr error
+	for i, fn := range functions {
+		if hovers[i].Err != nil {
+			if firstErr == nil {
+				firstErr = hovers[i].Err
+			}
+			failed++
+			continue
+		}
+		hoverMap[fn.ToKey()] = hovers[i].Text
+	}
+	if failed > 0 {
+		s.logger.Warn("Failed to get hover information for some functions",
+			zap.Int("failed", failed),
+			zap.Int("functions", len(functions)),
+			zap.Error(firstErr))
+	}
+	return hoverMap
+}
+
+func (s *CodeGraphServer) formatCallGraph(ctx context.Context, repoName string, cg *model.CallGraph, includeSource bool) string {
 	if cg == nil {
 		return "No call graph available."
 	}
@@ -167,18 +277,7 @@ func (s *CodeGraphServer) formatCallGraph(ctx context.Context, repoName string,
 	}
 
 	// Get hover information for all functions
-	hoverStrings, err := s.repoService.GetFunctionHovers(ctx, repoName, allFunctions)
-	if err != nil {
-		s.logger.Warn("Failed to get hover information for functions", zap.Error(err))
-		// Create empty hover strings as fallback
-		hoverStrings = make([]string, len(allFunctions))
-	}
-
-	// Create hover lookup map
-	hoverMap := make(map[string]string)
-	for i, fn := range allFunctions {
-		hoverMap[fn.ToKey()] = hoverStrings[i]
-	}
+	hoverMap := s.functionHovers(ctx, repoName, allFunctions)
 
 	// Build adjacency map for efficient edge traversal
 	adjacencyMap := make(map[string][]*model.FunctionDefinition)
@@ -189,6 +288,12 @@ func (s *CodeGraphServer) formatCallGraph(ctx context.Context, repoName string,
 		}
 	}
 
+	paths := s.pathNormalizer(repoName)
+	var sources *sourceReader
+	if includeSource {
+		sources = newSourceReader(paths, s.config.App.MaxFileSizeBytes)
+	}
+
 	var result strings.Builder
 
 	// Process each root function
@@ -197,13 +302,13 @@ func (s *CodeGraphServer) formatCallGraph(ctx context.Context, repoName string,
 			result.WriteString("\n\n")
 		}
 		visited := make(map[string]bool)
-		s.formatCallGraphNode(&root, adjacencyMap, hoverMap, visited, 0, &result)
+		s.formatCallGraphNode(&root, adjacencyMap, hoverMap, paths, sources, visited, 0, &result)
 	}
 
 	return result.String()
 }
 
-func (s *CodeGraphServer) formatCallGraphNode(node *model.FunctionDefinition, adjacencyMap map[string][]*model.FunctionDefinition, hoverMap map[string]string, visited map[string]bool, depth int, result *strings.Builder) {
+func (s *CodeGraphServer) formatCallGraphNode(node *model.FunctionDefinition, adjacencyMap map[string][]*model.FunctionDefinition, hoverMap map[string]string, paths *util.PathNormalizer, sources *sourceReader, visited map[string]bool, depth int, result *strings.Builder) {
 	if node == nil {
 		return
 	}
@@ -211,11 +316,7 @@ func (s *CodeGraphServer) formatCallGraphNode(node *model.FunctionDefinition, ad
 	// Create indentation
 	indent := strings.Repeat("    ", depth)
 
-	// Extract file path from URI (remove file:// prefix if present)
-	filePath := node.Location.URI
-	if strings.HasPrefix(filePath, "file://") {
-		filePath = filePath[7:]
-	}
+	filePath := paths.Normalize(node.Location.URI)
 
 	// Get hover information for this node
 	nodeKey := node.ToKey()
@@ -232,6 +333,7 @@ func (s *CodeGraphServer) formatCallGraphNode(node *model.FunctionDefinition, ad
 	} else {
 		result.WriteString(fmt.Sprintf("%s<step> %s (file: %s)\n", indent, node.Name, filePath))
 	}
+	writeSource(sources, node, indent, result)
 
 	// Get children from adjacency map
 	if children, exists := adjacencyMap[nodeKey]; exists && !visited[nodeKey] {
@@ -239,7 +341,7 @@ func (s *CodeGraphServer) formatCallGraphNode(node *model.FunctionDefinition, ad
 
 		// Process each child
 		for _, child := range children {
-			s.formatCallGraphNode(child, adjacencyMap, hoverMap, visited, depth+1, result)
+			s.formatCallGraphNode(child, adjacencyMap, hoverMap, paths, sources, visited, depth+1, result)
 		}
 
 		visited[nodeKey] = false // Allow revisiting in different branches
@@ -249,7 +351,7 @@ func (s *CodeGraphServer) formatCallGraphNode(node *model.FunctionDefinition, ad
 	result.WriteString(fmt.Sprintf("%s</step>\n", indent))
 }
 
-func (s *CodeGraphServer) formatCallerGraph(ctx context.Context, repoName string, cg *model.CallGraph) string {
+func (s *CodeGraphServer) formatCallerGraph(ctx context.Context, repoName string, cg *model.CallGraph, includeSource bool) string {
 	if cg == nil {
 		return "No caller graph available."
 	}
@@ -281,18 +383,7 @@ func (s *CodeGraphServer) formatCallerGraph(ctx context.Context, repoName string
 	}
 
 	// Get hover information for all functions
-	hoverStrings, err := s.repoService.GetFunctionHovers(ctx, repoName, allFunctions)
-	if err != nil {
-		s.logger.Warn("Failed to get hover information for functions", zap.Error(err))
-		// Create empty hover strings as fallback
-		hoverStrings = make([]string, len(allFunctions))
-	}
-
-	// Create hover lookup map
-	hoverMap := make(map[string]string)
-	for i, fn := range allFunctions {
-		hoverMap[fn.ToKey()] = hoverStrings[i]
-	}
+	hoverMap := s.functionHovers(ctx, repoName, allFunctions)
 
 	// Build adjacency map for efficient edge traversal
 	adjacencyMap := make(map[string][]*model.FunctionDefinition)
@@ -303,6 +394,12 @@ func (s *CodeGraphServer) formatCallerGraph(ctx context.Context, repoName string
 		}
 	}
 
+	paths := s.pathNormalizer(repoName)
+	var sources *sourceReader
+	if includeSource {
+		sources = newSourceReader(paths, s.config.App.MaxFileSizeBytes)
+	}
+
 	var result strings.Builder
 
 	// Process each root function
@@ -311,13 +408,13 @@ func (s *CodeGraphServer) formatCallerGraph(ctx context.Context, repoName string
 			result.WriteString("\n\n")
 		}
 		visited := make(map[string]bool)
-		s.formatCallerGraphNode(&root, adjacencyMap, hoverMap, visited, 0, &result)
+		s.formatCallerGraphNode(&root, adjacencyMap, hoverMap, paths, sources, visited, 0, &result)
 	}
 
 	return result.String()
 }
 
-func (s *CodeGraphServer) formatCallerGraphNode(node *model.FunctionDefinition, adjacencyMap map[string][]*model.FunctionDefinition, hoverMap map[string]string, visited map[string]bool, depth int, result *strings.Builder) {
+func (s *CodeGraphServer) formatCallerGraphNode(node *model.FunctionDefinition, adjacencyMap map[string][]*model.FunctionDefinition, hoverMap map[string]string, paths *util.PathNormalizer, sources *sourceReader, visited map[string]bool, depth int, result *strings.Builder) {
 	if node == nil {
 		return
 	}
@@ -325,11 +422,7 @@ func (s *CodeGraphServer) formatCallerGraphNode(node *model.FunctionDefinition,
 	// Create indentation
 	indent := strings.Repeat("    ", depth)
 
-	// Extract file path from URI (remove file:// prefix if present)
-	filePath := node.Location.URI
-	if strings.HasPrefix(filePath, "file://") {
-		filePath = filePath[7:]
-	}
+	filePath := paths.Normalize(node.Location.URI)
 
 	// Get hover information for this node
 	nodeKey := node.ToKey()
@@ -346,6 +439,7 @@ func (s *CodeGraphServer) formatCallerGraphNode(node *model.FunctionDefinition,
 	} else {
 		result.WriteString(fmt.Sprintf("%s<caller> %s (file: %s)\n", indent, node.Name, filePath))
 	}
+	writeSource(sources, node, indent, result)
 
 	// Get children from adjacency map
 	if children, exists := adjacencyMap[nodeKey]; exists && !visited[nodeKey] {
@@ -353,7 +447,7 @@ func (s *CodeGraphServer) formatCallerGraphNode(node *model.FunctionDefinition,
 
 		// Process each child
 		for _, child := range children {
-			s.formatCallerGraphNode(child, adjacencyMap, hoverMap, visited, depth+1, result)
+			s.formatCallerGraphNode(child, adjacencyMap, hoverMap, paths, sources, visited, depth+1, result)
 		}
 
 		visited[nodeKey] = false // Allow revisiting in different branches
@@ -0,0 +1,129 @@
+package mcp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"bot-go/internal/model"
+	"bot-go/internal/util"
+	"bot-go/pkg/lsp"
+	"bot-go/pkg/lsp/base"
+
+	"go.uber.org/zap"
+)
+
+func functionAt(name, uri string, startLine, endLine int) *model.FunctionDefinition {
+	return &model.FunctionDefinition{
+		Name: name,
+		Location: base.Location{
+			URI: uri,
+			Range: base.Range{
+				Start: base.Position{Line: startLine},
+				End:   base.Position{Line: endLine},
+			},
+		},
+	}
+}
+
+func TestFormatCallGraphIncludesSource(t *testing.T) {
+	repoRoot := t.TempDir()
+	source := "package demo\n\nfunc Run() int {\n\treturn helper()\n}\n"
+	if err := os.WriteFile(filepath.Join(repoRoot, "run.go"), []byte(source), 0o644); err != nil {
+		t.Fatalf("write run.go: %v", err)
+	}
+
+	root := functionAt("Run", "file://"+filepath.Join(repoRoot, "run.go"), 2, 4)
+	missing := functionAt("helper", "missing.go", 0, 3)
+	outOfRange := functionAt("Late", "run.go", 50, 60)
+	adjacency := map[string][]*model.FunctionDefinition{
+		root.ToKey(): {missing, outOfRange},
+	}
+
+	s := &CodeGraphServer{logger: zap.NewNop()}
+	paths := util.NewPathNormalizer(repoRoot, false)
+	format := func(sources *sourceReader) string {
+		var result strings.Builder
+		s.formatCallGraphNode(root, adjacency, map[string]string{}, paths, sources, map[string]bool{}, 0, &result)
+		return result.String()
+	}
+
+	want := "<step> Run (file: run.go)\n" +
+		"  Source:\n" +
+		"    func Run() int {\n" +
+		"    \treturn helper()\n" +
+		"    }\n" +
+		"    <step> helper (file: missing.go)\n" +
+		"    </step>\n" +
+		"    <step> Late (file: run.go)\n" +
+		"    </step>\n" +
+		"</step>\n"
+	if got := format(newSourceReader(paths, 0)); got != want {
+		t.Errorf("with source:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := format(nil); strings.Contains(got, "Source:") {
+		t.Errorf("without source:\n%s\nwant no source", got)
+	}
+}
+
+func TestSourceReaderBoundsSnippet(t *testing.T) {
+	repoRoot := t.TempDir()
+	var source strings.Builder
+	for i := 0; i < maxSourceSnippetLines*2; i++ {
+		fmt.Fprintf(&source, "line %d\n", i)
+	}
+	if err := os.WriteFile(filepath.Join(repoRoot, "long.go"), []byte(source.String()), 0o644); err != nil {
+		t.Fatalf("write long.go: %v", err)
+	}
+	sources := newSourceReader(util.NewPathNormalizer(repoRoot, false), 0)
+
+	snippet := sources.snippet(functionAt("Long", "long.go", 0, maxSourceSnippetLines*2-1))
+	if len(snippet) != maxSourceSnippetLines+1 || snippet[maxSourceSnippetLines] != "..." {
+		t.Errorf("long function snippet = %q, want %d lines and \"...\"", snippet, maxSourceSnippetLines)
+	}
+
+	// The range end is clamped to the file without marking a cut
+	snippet = sources.snippet(functionAt("Tail", "long.go", maxSourceSnippetLines*2-2, maxSourceSnippetLines*3))
+	if want := []string{fmt.Sprintf("line %d", maxSourceSnippetLines*2-2), fmt.Sprintf("line %d", maxSourceSnippetLines*2-1)}; strings.Join(snippet, "|") != strings.Join(want, "|") {
+		t.Errorf("tail snippet = %q, want %q", snippet, want)
+	}
+
+	if snippet := sources.snippet(functionAt("Reversed", "long.go", 5, 2)); snippet != nil {
+		t.Errorf("reversed range snippet = %q, want none", snippet)
+	}
+}
+
+func TestFormatCallGraphKeepsResolvedHovers(t *testing.T) {
+	root := functionAt("Run", "run.go", 2, 4)
+	failed := functionAt("helper", "run.go", 6, 8)
+	resolved := functionAt("Stop", "stop.go", 1, 3)
+	functions := []model.FunctionDefinition{*root, *failed, *resolved}
+	hovers := []lsp.HoverResult{
+		{Text: "func Run() int"},
+		{Err: errors.New("request timed out")},
+		{Text: "func Stop()"},
+	}
+	adjacency := map[string][]*model.FunctionDefinition{
+		root.ToKey(): {failed, resolved},
+	}
+
+	s := &CodeGraphServer{logger: zap.NewNop()}
+	var result strings.Builder
+	s.formatCallGraphNode(root, adjacency, s.hoverMap(functions, hovers), util.NewPathNormalizer("", false), nil, map[string]bool{}, 0, &result)
+
+	want := "<step> Run (file: run.go)\n" +
+		"  Description: func Run() int\n" +
+		"    <step> helper (file: run.go)\n" +
+		"    </step>\n" +
+		"    <step> Stop (file: stop.go)\n" +
+		"      Description: func Stop()\n" +
+		"    </step>\n" +
+		"</step>\n"
+	if got := result.String(); got != want {
+		t.Errorf("formatted graph:\n%s\nwant:\n%s", got, want)
+	}
+}